@@ -0,0 +1,84 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/vdobler/ht/suite"
+)
+
+var cmdCompare = &Command{
+	RunArgs:     runCompare,
+	Usage:       "compare [-slower <percent>] [-mindelta <ms>] <old> <new>",
+	Description: "structured diff of the results of two runs",
+	Flag:        flag.NewFlagSet("compare", flag.ContinueOnError),
+	Help: `Compare compares the results of two runs of 'ht exec' and prints the
+difference as JSON to stdout. The arguments old and new are either a
+result.json file of one suite or an output folder of 'ht exec' which
+is searched recursively for result.json files.
+
+Tests are identified by the name of their suite, their sequence number
+and their name. The diff contains
+  - Changed: tests whose status changed
+  - Latency: tests which passed in both runs and whose duration changed
+    by more than -slower percent and more than -mindelta milliseconds
+  - Added and Removed: tests present in only one of the runs
+A test is a new failure if its status in the new run is Fail, Error or
+Bogus and it passed in the old run or is new.
+
+A one line summary is printed to stderr. The exit code is 1 if new
+failures were found and 0 otherwise which allows to gate deployments
+on "no new failures":
+    ht compare last-release/ candidate/ > diff.json
+`,
+}
+
+var (
+	compareSlower   float64
+	compareMinDelta int64
+)
+
+func init() {
+	cmdCompare.Flag.Float64Var(&compareSlower, "slower", 50,
+		"report durations changed by more than `percent`")
+	cmdCompare.Flag.Int64Var(&compareMinDelta, "mindelta", 100,
+		"ignore duration changes of less than `ms` milliseconds")
+}
+
+func runCompare(cmd *Command, args []string) {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "Usage: ht", cmd.Usage)
+		os.Exit(9)
+	}
+	before, err := suite.LoadResults(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(8)
+	}
+	after, err := suite.LoadResults(args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(8)
+	}
+
+	diff := suite.CompareResults(before, after, compareSlower/100, compareMinDelta)
+	data, err := json.MarshalIndent(diff, "", "    ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(8)
+	}
+	fmt.Printf("%s\n", data)
+	fmt.Fprintf(os.Stderr, "%d changed, %d new failures, %d latency changes, %d added, %d removed, %d unchanged\n",
+		len(diff.Changed), diff.NewFailures, len(diff.Latency),
+		len(diff.Added), len(diff.Removed), diff.Unchanged)
+	if diff.NewFailures > 0 {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,227 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+var cmdCompletion = &Command{
+	RunArgs:     runCompletion,
+	Usage:       "completion (bash | zsh | fish)",
+	Description: "generate shell completion script",
+	Flag:        flag.NewFlagSet("completion", flag.ContinueOnError),
+	Help: `Completion prints a script which makes the given shell complete the
+commands and flags of ht. Arguments of doc and help are completed with
+the known types and topics, other arguments with file names.
+
+To load the completion add one of the following lines to your shell's
+startup file:
+    source <(ht completion bash)          # ~/.bashrc
+    source <(ht completion zsh)           # ~/.zshrc
+    ht completion fish | source           # ~/.config/fish/config.fish
+`,
+}
+
+func runCompletion(cmd *Command, args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: ht", cmd.Usage)
+		os.Exit(9)
+	}
+	switch args[0] {
+	case "bash":
+		bashCompletion(os.Stdout)
+	case "zsh":
+		zshCompletion(os.Stdout)
+	case "fish":
+		fishCompletion(os.Stdout)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown shell %q, must be bash, zsh or fish\n", args[0])
+		os.Exit(9)
+	}
+	os.Exit(0)
+}
+
+// completionFlag is a flag of a command as needed for completion.
+type completionFlag struct {
+	Name     string
+	Usage    string
+	IsBool   bool
+	Repeated bool // flag may be given several times
+}
+
+// commandFlags returns the flags of cmd sorted by name.
+func commandFlags(cmd *Command) []completionFlag {
+	flags := []completionFlag{}
+	cmd.Flag.VisitAll(func(f *flag.Flag) {
+		_, usage := flag.UnquoteUsage(f)
+		isBool := false
+		if bf, ok := f.Value.(interface{ IsBoolFlag() bool }); ok {
+			isBool = bf.IsBoolFlag()
+		}
+		repeated := false
+		switch f.Value.(type) {
+		case *cmdlVar, *stringsFlag:
+			repeated = true
+		}
+		flags = append(flags, completionFlag{
+			Name:     f.Name,
+			Usage:    usage,
+			IsBool:   isBool,
+			Repeated: repeated,
+		})
+	})
+	return flags
+}
+
+// argumentWords returns the words completed for the arguments of the
+// command name or nil if file names are completed.
+func argumentWords(name string) []string {
+	words := []string{}
+	switch name {
+	case "doc":
+		for typ := range typeDoc {
+			words = append(words, typ)
+		}
+		words = append(words, "checks", "extractors")
+	case "help":
+		for _, cmd := range commands {
+			words = append(words, cmd.Name())
+		}
+		words = append(words, "checks", "extractors", "archive", "secrets")
+	case "completion":
+		words = append(words, "bash", "zsh", "fish")
+	default:
+		return nil
+	}
+	sort.Strings(words)
+	return words
+}
+
+func bashCompletion(w io.Writer) {
+	names := []string{}
+	for _, cmd := range commands {
+		names = append(names, cmd.Name())
+	}
+	fmt.Fprintf(w, `# bash completion for ht, generated by 'ht completion bash'
+
+_ht() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    if [ "$COMP_CWORD" -eq 1 ]; then
+        COMPREPLY=($(compgen -W %q -- "$cur"))
+        return
+    fi
+    local flags="" words=""
+    case "${COMP_WORDS[1]}" in
+`, strings.Join(names, " "))
+	for _, cmd := range commands {
+		flags := []string{}
+		for _, f := range commandFlags(cmd) {
+			flags = append(flags, "-"+f.Name)
+		}
+		fmt.Fprintf(w, "        %s) flags=%q; words=%q ;;\n", cmd.Name(),
+			strings.Join(flags, " "), strings.Join(argumentWords(cmd.Name()), " "))
+	}
+	fmt.Fprint(w, `    esac
+    if [[ "$cur" == -* ]]; then
+        COMPREPLY=($(compgen -W "$flags" -- "$cur"))
+    elif [ -n "$words" ]; then
+        COMPREPLY=($(compgen -W "$words" -- "$cur"))
+    else
+        COMPREPLY=($(compgen -f -- "$cur"))
+    fi
+}
+
+complete -o filenames -F _ht ht
+`)
+}
+
+func zshCompletion(w io.Writer) {
+	fmt.Fprint(w, `#compdef ht
+# zsh completion for ht, generated by 'ht completion zsh'
+
+_ht() {
+    local -a commands
+    commands=(
+`)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "        '%s:%s'\n", cmd.Name(), zshQuote(cmd.Description))
+	}
+	fmt.Fprint(w, `    )
+    if (( CURRENT == 2 )); then
+        _describe 'command' commands
+        return
+    fi
+    local cmd="$words[2]"
+    shift words
+    (( CURRENT-- ))
+    case "$cmd" in
+`)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "        %s)\n            _arguments \\\n", cmd.Name())
+		for _, f := range commandFlags(cmd) {
+			spec := fmt.Sprintf("-%s[%s]", f.Name, zshQuote(f.Usage))
+			if !f.IsBool {
+				spec += ":value:"
+			}
+			if f.Repeated {
+				spec = "*" + spec
+			}
+			fmt.Fprintf(w, "                '%s' \\\n", spec)
+		}
+		if words := argumentWords(cmd.Name()); words != nil {
+			fmt.Fprintf(w, "                '*:argument:(%s)'\n", strings.Join(words, " "))
+		} else {
+			fmt.Fprint(w, "                '*:file:_files'\n")
+		}
+		fmt.Fprint(w, "            ;;\n")
+	}
+	fmt.Fprint(w, `    esac
+}
+
+compdef _ht ht
+`)
+}
+
+func fishCompletion(w io.Writer) {
+	fmt.Fprint(w, "# fish completion for ht, generated by 'ht completion fish'\n\n")
+	fmt.Fprint(w, "complete -c ht -f -n __fish_use_subcommand\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "complete -c ht -f -n __fish_use_subcommand -a %s -d '%s'\n",
+			cmd.Name(), fishQuote(cmd.Description))
+	}
+	for _, cmd := range commands {
+		cond := fmt.Sprintf("'__fish_seen_subcommand_from %s'", cmd.Name())
+		for _, f := range commandFlags(cmd) {
+			req := " -r"
+			if f.IsBool {
+				req = ""
+			}
+			fmt.Fprintf(w, "complete -c ht -n %s -o %s%s -d '%s'\n",
+				cond, f.Name, req, fishQuote(f.Usage))
+		}
+		if words := argumentWords(cmd.Name()); words != nil {
+			fmt.Fprintf(w, "complete -c ht -f -n %s -a '%s'\n", cond, strings.Join(words, " "))
+		}
+	}
+}
+
+// zshQuote makes s usable as description in a single quoted _arguments
+// or _describe spec.
+func zshQuote(s string) string {
+	s = strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+	return strings.Replace(s, "\n", " ", -1)
+}
+
+// fishQuote makes s usable in a single quoted fish string.
+func fishQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s)
+	return strings.Replace(s, "\n", " ", -1)
+}
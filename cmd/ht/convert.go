@@ -0,0 +1,232 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/vdobler/ht/curl"
+	"github.com/vdobler/ht/internal/convert"
+	"github.com/vdobler/ht/postman"
+	"github.com/vdobler/ht/recorder"
+	"github.com/vdobler/ht/sanitize"
+)
+
+var cmdConvert = &Command{
+	RunArgs:     runConvert,
+	Usage:       "convert [-o <dir>] (postman <collection.json> | har <session.har> | curl <command>)",
+	Description: "convert Postman collections, HAR files and curl commands",
+	Flag:        flag.NewFlagSet("convert", flag.ContinueOnError),
+	Help: `Convert postman translates a Postman collection (exported in format v2.0
+or v2.1) into a suite and one test per request which are written to the
+directory given by -o.
+
+Requests with their headers, bodies and authentication, the collection
+variables and common assertions of the test scripts like
+    pm.response.to.have.status(200)
+    pm.expect(jsonData.user.name).to.eql("alice")
+    pm.environment.set("token", jsonData.token)
+are converted into checks and data extractions. Folders of the collection
+become subdirectories. Script lines which cannot be converted are kept in
+the description of the test and reported as warnings.
+
+Convert har translates a HTTP Archive (HAR) as saved from the developer
+tools of a browser into a suite replaying the captured requests. Like
+with the record command checks are generated from the captured responses.
+Cookies set by responses are kept in the cookie jar of the suite (unless
+-keepcookies=false) and not sent explicitly by later tests. With -hostvars
+the hosts in the URLs are replaced by variables like {{HOST_EXAMPLE_ORG}}
+which allows to replay the session against a different system, e.g.
+    ht exec -D HOST_EXAMPLE_ORG=staging.example.org session.suite
+Requests can be excluded by their path and by the content type of their
+response with the -ignore.path and -ignore.type regular expressions.
+
+Convert curl translates a curl command line (e.g. as copied from the
+developer tools of a browser) into a test checking for status code 200:
+    ht convert curl 'curl -X POST -H "Accept: application/json" -d a=1 http://localhost/x'
+The command may also be read from stdin by giving "-" as command. The
+test is written to the directory given by -o. The reverse direction is
+available by the -curl flag of exec and run which prints the curl commands
+of failed tests.
+`,
+}
+
+var (
+	convertOutput      string
+	convertHostVars    bool
+	convertKeepCookies bool
+	convertIgnPath     string
+	convertIgnCT       string
+)
+
+func init() {
+	cmdConvert.Flag.StringVar(&convertOutput, "o", ".",
+		"write generated files to `directory`")
+	cmdConvert.Flag.BoolVar(&convertHostVars, "hostvars", false,
+		"replace hosts in HAR URLs by variables")
+	cmdConvert.Flag.BoolVar(&convertKeepCookies, "keepcookies", true,
+		"keep cookies from HAR responses in the cookie jar")
+	cmdConvert.Flag.StringVar(&convertIgnPath, "ignore.path", "",
+		"ignore HAR entries with path matching `regexp`")
+	cmdConvert.Flag.StringVar(&convertIgnCT, "ignore.type", "",
+		"ignore HAR entries with content types matching `regexp`")
+}
+
+func runConvert(cmd *Command, args []string) {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "Usage: ht", cmd.Usage)
+		os.Exit(9)
+	}
+	switch args[0] {
+	case "postman":
+		convertPostman(args[1])
+	case "har":
+		convertHAR(args[1])
+	case "curl":
+		convertCurl(args[1])
+	default:
+		fmt.Fprintln(os.Stderr, "Usage: ht", cmd.Usage)
+		os.Exit(9)
+	}
+	os.Exit(0)
+}
+
+func convertPostman(filename string) {
+	file, err := os.Open(filename)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(9)
+	}
+	collection, err := postman.Read(file)
+	file.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot read %s: %s\n", filename, err)
+		os.Exit(9)
+	}
+
+	files, warnings, err := postman.Convert(collection)
+	for _, w := range warnings {
+		fmt.Fprintln(os.Stderr, "Warning:", w)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(8)
+	}
+	for _, f := range files {
+		filename := filepath.Join(convertOutput, filepath.FromSlash(f.Name))
+		err := os.MkdirAll(filepath.Dir(filename), 0777)
+		if err == nil {
+			err = ioutil.WriteFile(filename, f.Data, 0666)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(8)
+		}
+	}
+	fmt.Printf("Wrote suite %s and %d tests to %s\n",
+		files[0].Name, len(files)-1, convertOutput)
+}
+
+func convertHAR(filename string) {
+	opts := recorder.Options{}
+	if convertIgnPath != "" {
+		opts.IgnoredPath = regexp.MustCompile(convertIgnPath)
+	}
+	if convertIgnCT != "" {
+		opts.IgnoredContentType = regexp.MustCompile(convertIgnCT)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(9)
+	}
+	events, err := recorder.ReadHAR(file, opts)
+	file.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot read %s: %s\n", filename, err)
+		os.Exit(9)
+	}
+
+	dumpOpts := recorder.DumpOptions{KeepCookies: convertKeepCookies}
+	if convertHostVars {
+		dumpOpts.HostVariables = hostVariables(events)
+	}
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	err = recorder.DumpEventsWith(events, convertOutput, name, dumpOpts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(8)
+	}
+	fmt.Printf("Wrote suite %s and %d tests to %s\n", name, len(events), convertOutput)
+}
+
+func convertCurl(command string) {
+	if command == "-" {
+		data, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(9)
+		}
+		command = string(data)
+	}
+	test, warnings, err := curl.Parse(command)
+	for _, w := range warnings {
+		fmt.Fprintln(os.Stderr, "Warning:", w)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot convert curl command: %s\n", err)
+		os.Exit(9)
+	}
+
+	data, err := convert.Marshal(test)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(8)
+	}
+	filename := filepath.Join(convertOutput, sanitize.Filename(test.Name)+".ht")
+	err = os.MkdirAll(convertOutput, 0777)
+	if err == nil {
+		err = ioutil.WriteFile(filename, data, 0666)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(8)
+	}
+	fmt.Printf("Wrote test %s\n", filename)
+}
+
+// hostVariables maps the hosts requested in events to variable names like
+// HOST_WWW_EXAMPLE_ORG.
+func hostVariables(events []recorder.Event) map[string]string {
+	hosts := []string{}
+	vars := map[string]string{}
+	for _, e := range events {
+		if _, ok := vars[e.Request.URL.Host]; !ok {
+			vars[e.Request.URL.Host] = ""
+			hosts = append(hosts, e.Request.URL.Host)
+		}
+	}
+	sort.Strings(hosts)
+	nonAlnum := regexp.MustCompile(`[^A-Z0-9]+`)
+	used := map[string]bool{}
+	for _, host := range hosts {
+		name := "HOST_" + nonAlnum.ReplaceAllString(strings.ToUpper(host), "_")
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("HOST_%s_%d",
+				nonAlnum.ReplaceAllString(strings.ToUpper(host), "_"), n)
+		}
+		used[name] = true
+		vars[host] = name
+	}
+	return vars
+}
@@ -0,0 +1,371 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/scope"
+	"github.com/vdobler/ht/suite"
+)
+
+var cmdDebug = &Command{
+	RunArgs:     runDebug,
+	Usage:       "debug [flags] <suite>",
+	Description: "run tests of a suite interactively",
+	Flag:        flag.NewFlagSet("debug", flag.ContinueOnError),
+	Help: `Debug loads the suite and provides an interactive prompt to execute the
+tests of the suite individually and in any order. The variable scope and
+the cookies are kept between the executions like during 'ht exec', so
+variables extracted by one test are available to the following ones.
+
+The following commands are understood (tests are selected by their number
+as shown by list or by their file name):
+    list                  list the tests and the status of their last run
+    run <test>|all        execute the test or all tests in order
+    show <test>           print the test after variable substitution
+    edit <test>           edit the test file with $EDITOR and reload
+    reload                reload the suite and all tests from disk
+    vars [<prefix>]       print the current variable scope
+    set <name> <value>    set variable name to value
+    unset <name>          remove variable name from the scope
+    request               print the last request
+    response              print the last response
+    report                print the full report of the last test
+    curl                  print the last request as a curl command
+    help                  print this list of commands
+    quit                  end the session
+Secret values are masked in the output.
+`,
+}
+
+func init() {
+	addTestFlags(cmdDebug.Flag)
+}
+
+// debugCommands maps the commands of the debug prompt to their handlers.
+var debugCommands map[string]func(d *debugger, args []string) error
+
+func init() {
+	debugCommands = map[string]func(d *debugger, args []string) error{
+		"list":     (*debugger).list,
+		"run":      (*debugger).run,
+		"show":     (*debugger).show,
+		"edit":     (*debugger).edit,
+		"reload":   (*debugger).reload,
+		"vars":     (*debugger).vars,
+		"set":      (*debugger).set,
+		"unset":    (*debugger).unset,
+		"request":  (*debugger).request,
+		"response": (*debugger).response,
+		"report":   (*debugger).report,
+		"curl":     (*debugger).curl,
+		"help":     (*debugger).help,
+	}
+}
+
+// debugger is the state of an interactive debug session.
+type debugger struct {
+	session  *suite.Session
+	fs       suite.FileSystem
+	out      io.Writer
+	statuses map[int]ht.Status // status of the last run of each test
+}
+
+func runDebug(cmd *Command, args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: ht", cmd.Usage)
+		os.Exit(9)
+	}
+	fs, arg := filesystemFor(args[0])
+	rs, err := suite.LoadRawSuite(arg, fs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot read suite %q: %s\n", arg, err)
+		os.Exit(8)
+	}
+	if err := rs.SelectProfile(profileFlag); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(9)
+	}
+	setVerbosity(rs)
+	silent = true
+	prepareHT()
+
+	out := scope.MaskingWriter(os.Stdout)
+	logger := log.New(out, "", 0)
+	session, err := suite.NewSession(rs, variablesFlag, logger)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(8)
+	}
+	d := &debugger{
+		session:  session,
+		fs:       fs,
+		out:      out,
+		statuses: make(map[int]ht.Status),
+	}
+	fmt.Printf("Debugging suite %s with %d tests, type 'help' for a list of commands.\n",
+		rs.File.Name, len(session.RawTests()))
+	d.loop(os.Stdin)
+	os.Exit(0)
+}
+
+// loop reads commands from in and executes them until in is exhausted or
+// quit is entered.
+func (d *debugger) loop(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Print("ht> ")
+		if !scanner.Scan() {
+			fmt.Println()
+			return
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		name, args := fields[0], fields[1:]
+		if name == "quit" || name == "exit" {
+			return
+		}
+		command, ok := debugCommands[name]
+		if !ok {
+			fmt.Printf("Unknown command %q, type 'help' for a list of commands.\n", name)
+			continue
+		}
+		if err := command(d, args); err != nil {
+			fmt.Fprintln(d.out, "Error:", err)
+		}
+	}
+}
+
+// selectTest returns the 0-based index of the test selected by arg which
+// is either the number of the test as shown by list or its file name.
+func (d *debugger) selectTest(args []string) (int, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("need exactly one test (number or file name)")
+	}
+	tests := d.session.RawTests()
+	if n, err := strconv.Atoi(args[0]); err == nil {
+		if n < 1 || n > len(tests) {
+			return 0, fmt.Errorf("no test %d, valid are 1 to %d", n, len(tests))
+		}
+		return n - 1, nil
+	}
+	for i, rt := range tests {
+		if rt.File.Name == args[0] || rt.File.Basename() == args[0] {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no test with file name %q", args[0])
+}
+
+func (d *debugger) list(args []string) error {
+	for i, rt := range d.session.RawTests() {
+		status := "-"
+		if s, ok := d.statuses[i]; ok {
+			status = s.String()
+		}
+		fmt.Fprintf(d.out, "%3d. %-8s %s\n", i+1, status, rt.File.Name)
+	}
+	return nil
+}
+
+func (d *debugger) run(args []string) error {
+	indices := []int{}
+	if len(args) == 1 && args[0] == "all" {
+		for i := range d.session.RawTests() {
+			indices = append(indices, i)
+		}
+	} else {
+		n, err := d.selectTest(args)
+		if err != nil {
+			return err
+		}
+		indices = append(indices, n)
+	}
+	for _, n := range indices {
+		test, err := d.session.Run(n)
+		if err != nil {
+			return err
+		}
+		d.statuses[n] = test.Result.Status
+		if err := test.PrintShortReport(d.out); err != nil {
+			return err
+		}
+		if test.Result.Status != ht.Pass {
+			continue
+		}
+		extracted := test.Extract()
+		names := make([]string, 0, len(extracted))
+		for name := range extracted {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(d.out, "    extracted %s = %q\n", name, extracted[name])
+		}
+	}
+	return nil
+}
+
+func (d *debugger) show(args []string) error {
+	n, err := d.selectTest(args)
+	if err != nil {
+		return err
+	}
+	test, err := d.session.Test(n)
+	if err != nil {
+		return err
+	}
+	data, err := test.AsJSON()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(d.out, string(data))
+	return nil
+}
+
+func (d *debugger) edit(args []string) error {
+	n, err := d.selectTest(args)
+	if err != nil {
+		return err
+	}
+	if d.fs != nil {
+		return fmt.Errorf("cannot edit tests in an archive")
+	}
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	filename := filepath.FromSlash(d.session.RawTests()[n].File.Name)
+	cmd := exec.Command(editor, filename)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+	return d.reload(nil)
+}
+
+func (d *debugger) reload(args []string) error {
+	if err := d.session.Reload(d.fs); err != nil {
+		return err
+	}
+	fmt.Fprintf(d.out, "Reloaded %d tests.\n", len(d.session.RawTests()))
+	return nil
+}
+
+func (d *debugger) vars(args []string) error {
+	prefix := ""
+	if len(args) > 0 {
+		prefix = args[0]
+	}
+	vars := d.session.Scope()
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(d.out, "%s = %q\n", name, vars[name])
+	}
+	return nil
+}
+
+func (d *debugger) set(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: set <name> <value>")
+	}
+	d.session.Scope()[args[0]] = strings.Join(args[1:], " ")
+	return nil
+}
+
+func (d *debugger) unset(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: unset <name>")
+	}
+	delete(d.session.Scope(), args[0])
+	return nil
+}
+
+// last returns the most recently executed test.
+func (d *debugger) last() (*ht.Test, error) {
+	if d.session.Last == nil {
+		return nil, fmt.Errorf("no test executed yet")
+	}
+	return d.session.Last, nil
+}
+
+func (d *debugger) request(args []string) error {
+	test, err := d.last()
+	if err != nil {
+		return err
+	}
+	req := test.Request.Request
+	if req == nil {
+		return fmt.Errorf("no request sent")
+	}
+	fmt.Fprintf(d.out, "%s %s %s\n", req.Method, req.URL, req.Proto)
+	req.Header.Write(d.out)
+	fmt.Fprintln(d.out)
+	if test.Request.SentBody != "" {
+		fmt.Fprintln(d.out, test.Request.SentBody)
+	}
+	return nil
+}
+
+func (d *debugger) response(args []string) error {
+	test, err := d.last()
+	if err != nil {
+		return err
+	}
+	resp := test.Response.Response
+	if resp == nil {
+		return fmt.Errorf("no response received")
+	}
+	fmt.Fprintf(d.out, "%s %s  (%s)\n", resp.Proto, resp.Status, test.Response.Duration)
+	resp.Header.Write(d.out)
+	fmt.Fprintln(d.out)
+	fmt.Fprintln(d.out, test.Response.BodyStr)
+	return nil
+}
+
+func (d *debugger) report(args []string) error {
+	test, err := d.last()
+	if err != nil {
+		return err
+	}
+	return test.PrintReport(d.out)
+}
+
+func (d *debugger) curl(args []string) error {
+	test, err := d.last()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(d.out, test.CurlCall())
+	return nil
+}
+
+func (d *debugger) help(args []string) error {
+	help := cmdDebug.Help
+	i := strings.Index(help, "    list")
+	j := strings.Index(help, "Secret values")
+	fmt.Fprint(d.out, help[i:j])
+	return nil
+}
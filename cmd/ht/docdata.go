@@ -18,6 +18,29 @@ var typeDoc = map[string]string{
 		"            {Check: \"StatusCode\", Expect: 404},\n" +
 		"        ]\n" +
 		"    }",
+	"assets": "type Assets struct {\n" +
+		"\t// AllowBroken turns off the check that all resources were loaded\n" +
+		"\t// successfully.\n" +
+		"\tAllowBroken bool \n" +
+		"\n" +
+		"\t// MaxWeight is the maximal size of the page and all its resources\n" +
+		"\t// in bytes. Zero means unlimited.\n" +
+		"\tMaxWeight int64 \n" +
+		"\n" +
+		"\t// MaxCount is the maximal number of resources. Zero means unlimited.\n" +
+		"\tMaxCount int \n" +
+		"\n" +
+		"\t// MaxTime is the maximal time to load the slowest resource.\n" +
+		"\t// Zero means unlimited.\n" +
+		"\tMaxTime time.Duration \n" +
+		"\n" +
+		"\t// SameOrigin requires that all resources are loaded from the\n" +
+		"\t// origin (scheme, host and port) of the page.\n" +
+		"\tSameOrigin bool \n" +
+		"}\n" +
+		"    Assets checks the resources of a HTML page loaded like a browser does via\n" +
+		"    the LoadResources field of the test. All resources must be loaded without\n" +
+		"    error and with a status code below 400.",
 	"body": "type Body Condition\n" +
 		"    Body provides simple condition checks on the response body.",
 	"bodyextractor": "type BodyExtractor struct {\n" +
@@ -51,15 +74,130 @@ var typeDoc = map[string]string{
 		"    Cache allows to test for HTTP Cache-Control headers. The zero value checks\n" +
 		"    for the existence of a Cache-Control header only. Note that not all\n" +
 		"    combinations are sensible.",
+	"callback": "type Callback struct {\n" +
+		"\t// Delay after sending the response before the callback is made.\n" +
+		"\tDelay time.Duration\n" +
+		"\n" +
+		"\t// Method of the callback request. Defaults to POST.\n" +
+		"\tMethod string\n" +
+		"\n" +
+		"\t// URL to call.\n" +
+		"\tURL string\n" +
+		"\n" +
+		"\t// Header of the callback request.\n" +
+		"\tHeader http.Header\n" +
+		"\n" +
+		"\t// Body of the callback request. The @file syntax is supported.\n" +
+		"\tBody string\n" +
+		"\n" +
+		"\t// Checks are applied to the response to the callback request.\n" +
+		"\tChecks ht.CheckList\n" +
+		"}\n" +
+		"    Callback is an outgoing HTTP request made by a mock after it has sent\n" +
+		"    its response. Callbacks simulate asynchronous notifications (webhooks)\n" +
+		"    of the mocked service, e.g. a payment provider confirming a payment:\n" +
+		"        {\n" +
+		"            Delay: \"2s\"\n" +
+		"            Method: \"POST\"\n" +
+		"            URL: \"http://localhost:8080/payment/{{id}}/notify\"\n" +
+		"            Body: \"{\\\"id\\\": \\\"{{id}}\\\", \\\"status\\\": \\\"paid\\\"}\"\n" +
+		"            Checks: [ {Check: \"StatusCode\", Expect: 200} ]\n" +
+		"        }\n" +
+		"    URL, header values and Body undergo the same variable replacement\n" +
+		"    (or template execution) as the response of the mock.",
+	"charset": "type Charset struct {\n" +
+		"\t// Expect is the expected charset like \"UTF-8\" or \"ISO-8859-1\".\n" +
+		"\t// Aliases of charsets are recognised. The empty string accepts\n" +
+		"\t// any declared charset.\n" +
+		"\tExpect string \n" +
+		"}\n" +
+		"    Charset checks that the response declares its charset in the\n" +
+		"    Content-Type header or in a HTML meta tag and that the body actually is\n" +
+		"    encoded in the declared charset. Declaring e.g. ISO-8859-1 but sending\n" +
+		"    UTF-8 fails, as does declaring UTF-8 but sending invalid UTF-8.\n" +
+		"\n" +
+		"    The check works on the original bytes of the response, even if the body\n" +
+		"    was transcoded to UTF-8.",
 	"checklist": "type CheckList []Check\n" +
 		"    CheckList is a slice of checks with the sole purpose of attaching JSON\n" +
 		"    (un)marshaling methods.",
+	"clientpool": "type ClientPool struct {\n" +
+		"\t// MaxIdleConns limits the number of idle (keep-alive) connections\n" +
+		"\t// in total and MaxIdleConnsPerHost per host. Zero means the limits\n" +
+		"\t// of Transport respectively http.DefaultMaxIdleConnsPerHost.\n" +
+		"\tMaxIdleConns        int `json:\",omitempty\"`\n" +
+		"\tMaxIdleConnsPerHost int `json:\",omitempty\"`\n" +
+		"\n" +
+		"\t// MaxConnsPerHost limits the number of connections (dialing, active\n" +
+		"\t// and idle) per host. Zero means no limit.\n" +
+		"\tMaxConnsPerHost int `json:\",omitempty\"`\n" +
+		"\n" +
+		"\t// IdleConnTimeout is how long an idle connection is kept open.\n" +
+		"\t// Zero means the timeout of Transport.\n" +
+		"\tIdleConnTimeout time.Duration `json:\",omitempty\"`\n" +
+		"\n" +
+		"\t// DisableKeepAlives uses a new connection for each request.\n" +
+		"\tDisableKeepAlives bool `json:\",omitempty\"`\n" +
+		"\n" +
+		"\t// HTTP2 enables HTTP/2 for https connections.\n" +
+		"\tHTTP2 bool `json:\",omitempty\"`\n" +
+		"\n" +
+		"\t// Has unexported fields.\n" +
+		"}\n" +
+		"    ClientPool configures the HTTP connections shared by several tests, e.g.\n" +
+		"    all tests of a suite, and collects statistics about the reuse of the\n" +
+		"    connections. It allows to model a realistic client and to detect\n" +
+		"    connection leaks: A server which does not allow connection reuse shows\n" +
+		"    up with many new connections.\n" +
+		"\n" +
+		"    The zero value uses the settings of Transport. A ClientPool must not be\n" +
+		"    copied after first use.",
+	"comparewith": "type CompareWith struct {\n" +
+		"\t// Reference is the base URL of the reference system, e.g.\n" +
+		"\t// \"https://blue.example.org\" or \"http://localhost:8080/v1\".\n" +
+		"\tReference string\n" +
+		"\n" +
+		"\t// Header is the list of HTTP headers whose values must match,\n" +
+		"\t// e.g. [\"Content-Type\", \"Cache-Control\"].\n" +
+		"\tHeader []string \n" +
+		"\n" +
+		"\t// IgnoreStatus and IgnoreBody turn off comparing the status codes\n" +
+		"\t// and the bodies.\n" +
+		"\tIgnoreStatus bool \n" +
+		"\tIgnoreBody   bool \n" +
+		"\n" +
+		"\t// Ignore is the list of JSON elements excluded from the comparison\n" +
+		"\t// like timestamps or IDs. The elements are given in the notation of\n" +
+		"\t// the JSON check, a \"*\" matches all array elements or object members,\n" +
+		"\t// e.g. \"items.*.id\".\n" +
+		"\tIgnore []string \n" +
+		"\n" +
+		"\t// Sep is the separator in Ignore. A zero value is equivalent to \".\".\n" +
+		"\tSep string \n" +
+		"}\n" +
+		"    CompareWith sends the request of the test a second time to a reference\n" +
+		"    system (e.g. the previous release or the green deployment) and compares the\n" +
+		"    two responses: The status codes, the selected headers and the bodies must\n" +
+		"    match. JSON bodies are compared structurally (ignoring formatting and the\n" +
+		"    order of object members), other bodies textually.\n" +
+		"\n" +
+		"    The reference request is the fully substituted request of the test with\n" +
+		"    scheme, host and port (and path prefix) of the URL replaced by the ones of\n" +
+		"    Reference. The reference request does not send the cookies of the test's\n" +
+		"    cookie jar.",
 	"condition": "type Condition struct {\n" +
 		"\t// Equals is the exact value to be expected.\n" +
 		"\t// No other tests are performed if Equals is non-zero as these\n" +
 		"\t// other tests would be redundant.\n" +
 		"\tEquals string \n" +
 		"\n" +
+		"\t// Tolerance turns Equals into a numerical comparison: The string\n" +
+		"\t// and Equals are parsed as float64 (trimmed like for GreaterThan)\n" +
+		"\t// and may differ by at most Tolerance. A zero Tolerance compares\n" +
+		"\t// numbers exactly so that \"5\" equals \"5.0\".\n" +
+		"\t// Nil compares Equals literally.\n" +
+		"\tTolerance *float64 \n" +
+		"\n" +
 		"\t// Prefix is the required prefix\n" +
 		"\tPrefix string \n" +
 		"\n" +
@@ -72,6 +210,19 @@ var typeDoc = map[string]string{
 		"\t// Regexp is a regular expression to look for.\n" +
 		"\tRegexp string \n" +
 		"\n" +
+		"\t// OneOf is a list of allowed values: The string must equal one\n" +
+		"\t// of them. An empty list disables this test.\n" +
+		"\tOneOf []string \n" +
+		"\n" +
+		"\t// Glob is a shell-style pattern the whole string must match:\n" +
+		"\t//     *      matches any sequence of characters\n" +
+		"\t//     ?      matches any single character\n" +
+		"\t//     [a-z]  matches one character from the class, [!a-z] one not\n" +
+		"\t//            from the class\n" +
+		"\t//     \\*     matches a literal *\n" +
+		"\t// Glob is often simpler than Regexp, e.g. for \"order-*.pdf\".\n" +
+		"\tGlob string \n" +
+		"\n" +
 		"\t// Count determines how many occurrences of Contains or Regexp\n" +
 		"\t// are required for a match:\n" +
 		"\t//     0: Any positive number of matches is okay\n" +
@@ -79,6 +230,17 @@ var typeDoc = map[string]string{
 		"\t//   < 0: No match allowed (invert the condition)\n" +
 		"\tCount int \n" +
 		"\n" +
+		"\t// IgnoreCase makes Equals, Prefix, Suffix, Contains and Regexp\n" +
+		"\t// case-insensitive.\n" +
+		"\tIgnoreCase bool \n" +
+		"\n" +
+		"\t// Normalize the string as well as Equals, Prefix, Suffix and Contains\n" +
+		"\t// before comparing them: The strings are brought to Unicode\n" +
+		"\t// normalization form C, runs of white space (including non-breaking\n" +
+		"\t// spaces) are collapsed to a single space and leading and trailing\n" +
+		"\t// white space is removed.\n" +
+		"\tNormalize bool \n" +
+		"\n" +
 		"\t// Min and Max are the minimum and maximum length the string may\n" +
 		"\t// have. Two zero values disables this test.\n" +
 		"\tMin, Max int \n" +
@@ -120,6 +282,13 @@ var typeDoc = map[string]string{
 		"\t// with Time as the layout string.\n" +
 		"\tTime string \n" +
 		"\n" +
+		"\t// Before and After are exclusive upper and lower bounds on the\n" +
+		"\t// time value of the string. The (dequoted) string as well as Before\n" +
+		"\t// and After are parsed with Time as the layout or as RFC 3339 if\n" +
+		"\t// Time is empty. If the string is not a valid time these conditions\n" +
+		"\t// fail.\n" +
+		"\tBefore, After string \n" +
+		"\n" +
 		"\t// Has unexported fields.\n" +
 		"}\n" +
 		"    Condition is a conjunction of tests against a string. Note that Contains and\n" +
@@ -144,6 +313,26 @@ var typeDoc = map[string]string{
 		"}\n" +
 		"    CookieExtractor extracts the value of a cookie received in a Set-Cookie\n" +
 		"    header. The value of the first cookie with the given name is extracted.",
+	"cookiejar": "type CookieJar struct {\n" +
+		"\t// Domain whose cookies (including the cookies of its subdomains)\n" +
+		"\t// are checked. Empty means the host of the request.\n" +
+		"\tDomain string\n" +
+		"\n" +
+		"\t// Name of the cookie. Empty means any cookie.\n" +
+		"\tName string\n" +
+		"\n" +
+		"\t// Value is applied to the value of all cookies with the given Name.\n" +
+		"\tValue Condition\n" +
+		"\n" +
+		"\t// Absent indicates that no cookie with the given Name (or no cookie\n" +
+		"\t// at all if Name is empty) may be stored.\n" +
+		"\tAbsent bool\n" +
+		"}\n" +
+		"    CookieJar checks the content of the test's cookie jar after the response\n" +
+		"    was received, i.e. the cookies stored after processing all Set-Cookie\n" +
+		"    headers (of redirects too) according to RFC 6265 and the jar's policy.\n" +
+		"    The test must use a cookie jar, e.g. by being part of a suite with\n" +
+		"    KeepCookies set.",
 	"customjs": "type CustomJS struct {\n" +
 		"\t// Script is JavaScript code to be evaluated.\n" +
 		"\t//\n" +
@@ -199,14 +388,133 @@ var typeDoc = map[string]string{
 		"\n" +
 		"\t// Verbosity level in logging.\n" +
 		"\tVerbosity int \n" +
+		"\n" +
+		"\t// CheckConcurrency is the number of checks executed concurrently.\n" +
+		"\t// Zero and one execute the checks sequentially. Concurrent execution\n" +
+		"\t// pays off for heavy checks like Image, Links or ValidHTML.\n" +
+		"\tCheckConcurrency int \n" +
+		"\n" +
+		"\t// FreshCookies, FreshConnection and FreshVariables isolate the tries\n" +
+		"\t// of a test (e.g. when polling an endpoint) from each other:\n" +
+		"\t// FreshCookies removes the cookies stored in the Jar during a failed\n" +
+		"\t// try before retrying, FreshConnection closes the connection after\n" +
+		"\t// each try so that a retry is sent over a newly established connection\n" +
+		"\t// and FreshVariables generates new values for variables like RANDOM or\n" +
+		"\t// NOW for each retry (see Test.Renew).\n" +
+		"\tFreshCookies    bool \n" +
+		"\tFreshConnection bool \n" +
+		"\tFreshVariables  bool \n" +
 		"}\n" +
 		"    Execution contains parameters controlling the test execution.",
+	"external": "type External struct {\n" +
+		"\t// Command is the program to execute and Args its arguments.\n" +
+		"\tCommand string\n" +
+		"\tArgs    []string \n" +
+		"\n" +
+		"\t// Config is passed unchanged to the program.\n" +
+		"\tConfig interface{} \n" +
+		"\n" +
+		"\t// Timeout limits the runtime of the program. Zero means\n" +
+		"\t// DefaultExternalTimeout.\n" +
+		"\tTimeout time.Duration \n" +
+		"}\n" +
+		"    External is a check performed by an external program which allows to write\n" +
+		"    checks in any language without recompiling ht.\n" +
+		"\n" +
+		"    The program Command is started with Args. It receives an ExternalRequest of\n" +
+		"    kind \"check\" as JSON on its standard input and must print an\n" +
+		"    ExternalVerdict as JSON to its standard output, e.g.\n" +
+		"\n" +
+		"        {\"Status\": \"Fail\", \"Message\": \"price 0.00 not allowed\"}\n" +
+		"\n" +
+		"    A nonzero exit status or output which is not a verdict is an error.",
+	"externalextractor": "type ExternalExtractor struct {\n" +
+		"\t// Command is the program to execute and Args its arguments.\n" +
+		"\tCommand string\n" +
+		"\tArgs    []string \n" +
+		"\n" +
+		"\t// Config is passed unchanged to the program.\n" +
+		"\tConfig interface{} \n" +
+		"\n" +
+		"\t// Timeout limits the runtime of the program. Zero means\n" +
+		"\t// DefaultExternalTimeout.\n" +
+		"\tTimeout time.Duration \n" +
+		"}\n" +
+		"    ExternalExtractor extracts a value with an external program. The program is\n" +
+		"    called like the one of an External check but receives an ExternalRequest of\n" +
+		"    kind \"extractor\" and must print a verdict with status \"Pass\" and the\n" +
+		"    extracted Value.",
 	"extractormap": "type ExtractorMap map[string]Extractor\n" +
 		"    ExtractorMap is a map of Extractors with the sole purpose of attaching JSON\n" +
 		"    (un)marshaling methods.",
+	"field": "type Field struct {\n" +
+		"\t// Number is the field number.\n" +
+		"\tNumber int\n" +
+		"\n" +
+		"\t// Type of the field: One of double, float, int32, int64, uint32,\n" +
+		"\t// uint64, sint32, sint64, fixed32, fixed64, sfixed32, sfixed64,\n" +
+		"\t// bool, string, bytes, enum or message. Bytes are base64 encoded in\n" +
+		"\t// JSON, enums are given by their number.\n" +
+		"\tType string\n" +
+		"\n" +
+		"\t// Repeated fields are given as JSON arrays.\n" +
+		"\tRepeated bool\n" +
+		"\n" +
+		"\t// Message describes the fields of Type message.\n" +
+		"\tMessage Message\n" +
+		"}\n" +
+		"    Field describes one field of a protobuf message.",
 	"finalurl": "type FinalURL Condition\n" +
 		"    FinalURL checks the last URL after following all redirects. This check is\n" +
 		"    useful only for tests with Request.FollowRedirects=true",
+	"frame": "type Frame struct {\n" +
+		"\t// Delay before this step.\n" +
+		"\tDelay time.Duration\n" +
+		"\n" +
+		"\t// Send is a text message sent to the client. Variables are replaced\n" +
+		"\t// like in the response body of the mock.\n" +
+		"\tSend string\n" +
+		"\n" +
+		"\t// Receive, if non-zero, waits for the next message from the client\n" +
+		"\t// which must fulfill this condition.\n" +
+		"\tReceive ht.Condition\n" +
+		"\n" +
+		"\t// Timeout to wait for a message to receive. Defaults to 10 seconds.\n" +
+		"\tTimeout time.Duration\n" +
+		"}\n" +
+		"    Frame is one step in a WebSocket script.",
+	"grpc": "type GRPC struct {\n" +
+		"\t// Request describes the fields of the request message. Fields not\n" +
+		"\t// described are ignored.\n" +
+		"\tRequest Message\n" +
+		"\n" +
+		"\t// Response describes the fields of the response message.\n" +
+		"\tResponse Message\n" +
+		"\n" +
+		"\t// Status is the gRPC status code sent. The default 0 is OK.\n" +
+		"\t// No response message is sent for a non-zero Status.\n" +
+		"\tStatus int\n" +
+		"\n" +
+		"\t// Message is sent as grpc-message with a non-zero Status.\n" +
+		"\tMessage string\n" +
+		"}\n" +
+		"    GRPC turns a mock into a unary gRPC method. The URL of such a mock has the\n" +
+		"    path \"/package.Service/Method\" and Method POST. The response body is the\n" +
+		"    JSON form of the response message which is encoded as protobuf according\n" +
+		"    to the Response descriptor. The fields of the request message described by\n" +
+		"    Request are available as variables named like the field, fields of nested\n" +
+		"    messages as \"outer.inner\" and elements of repeated fields as \"name[0]\",\n" +
+		"    \"name[1]\", ... E.g. for a helloworld.Greeter:\n" +
+		"\n" +
+		"        URL: \"http://localhost:50051/helloworld.Greeter/SayHello\"\n" +
+		"        Method: \"POST\"\n" +
+		"        GRPC: {\n" +
+		"            Request:  { \"name\": {Number: 1, Type: \"string\"} }\n" +
+		"            Response: { \"message\": {Number: 1, Type: \"string\"} }\n" +
+		"        }\n" +
+		"        Response: { Body: \"{\\\"message\\\": \\\"Hello {{name}}\\\"}\" }\n" +
+		"\n" +
+		"    Mocks with GRPC are served via HTTP/2, also on plain HTTP (h2c).",
 	"htmlcontains": "type HTMLContains struct {\n" +
 		"\t// Selector is the CSS selector of the HTML elements.\n" +
 		"\tSelector string\n" +
@@ -301,14 +609,69 @@ var typeDoc = map[string]string{
 		"\tAbsent bool \n" +
 		"}\n" +
 		"    Header provides a textual test of single-valued HTTP headers.",
+	"hookscript": "type HookScript struct {\n" +
+		"\t// Command is the program to execute and Args its arguments.\n" +
+		"\tCommand string\n" +
+		"\tArgs    []string \n" +
+		"\n" +
+		"\t// Timeout limits the runtime of the program. Zero means\n" +
+		"\t// DefaultExternalTimeout.\n" +
+		"\tTimeout time.Duration \n" +
+		"}\n" +
+		"    HookScript is a hook implemented by an external program.\n" +
+		"\n" +
+		"    The program Command is started with Args. It receives an ExternalRequest\n" +
+		"    whose Kind is the name of the hook (\"PreRequest\", \"PostResponse\" or\n" +
+		"    \"OnFailure\") as JSON on its standard input and may print a HookOutput as\n" +
+		"    JSON to its standard output:\n" +
+		"\n" +
+		"      - The Header fields of the output are set in the request for a\n" +
+		"        PreRequest hook and in the response for a PostResponse hook.\n" +
+		"      - The Body of the output replaces the response body for a PostResponse\n" +
+		"        hook.\n" +
+		"      - The Diagnostics of the output are logged and stored in\n" +
+		"        Result.Diagnostics for an OnFailure hook.\n" +
+		"\n" +
+		"    A nonzero exit status or output which is not a HookOutput is an error.",
+	"hooks": "type Hooks struct {\n" +
+		"\t// PreRequest is called before each request is sent. The request\n" +
+		"\t// is available in Test.Request.Request.\n" +
+		"\tPreRequest *HookScript \n" +
+		"\n" +
+		"\t// PostResponse is called after each response has been received\n" +
+		"\t// and before the checks are executed.\n" +
+		"\tPostResponse *HookScript \n" +
+		"\n" +
+		"\t// OnFailure is called once after the last try if the test's status\n" +
+		"\t// is Fail or Error.\n" +
+		"\tOnFailure *HookScript \n" +
+		"\n" +
+		"\t// PreRequestFunc, PostResponseFunc and OnFailureFunc are the Go\n" +
+		"\t// functions of the hooks.\n" +
+		"\tPreRequestFunc   HookFunc \n" +
+		"\tPostResponseFunc HookFunc \n" +
+		"\tOnFailureFunc    HookFunc \n" +
+		"}\n" +
+		"    Hooks are called at certain points while a Test is run, e.g. to sign the\n" +
+		"    request, to decrypt the response or to collect diagnostic data once the\n" +
+		"    test failed.\n" +
+		"\n" +
+		"    Each hook is either a Go function (when ht is used as a library) or a\n" +
+		"    script (in test files) or both in which case the function is called first.\n" +
+		"    A failing PreRequest or PostResponse hook results in a test status of\n" +
+		"    Error.",
 	"identity": "type Identity struct {\n" +
 		"\t// SHA1 is the expected hash as shown by sha1sum of the whole body.\n" +
 		"\t// E.g. 2ef7bde608ce5404e97d5f042f95f89f1c232871 for a \"Hello World!\"\n" +
 		"\t// body (no newline).\n" +
 		"\tSHA1 string\n" +
+		"\n" +
+		"\t// SHA256 is the expected hash as shown by sha256sum of the whole\n" +
+		"\t// body. If set, SHA1 is checked only if non-empty.\n" +
+		"\tSHA256 string\n" +
 		"}\n" +
-		"    Identity checks the value of the response body by comparing its SHA1 hash to\n" +
-		"    the expected SHA1 value.",
+		"    Identity checks the value of the response body by comparing its SHA1\n" +
+		"    and/or SHA-256 hash to the expected value.",
 	"image": "type Image struct {\n" +
 		"\t// Format is the format of the image as registered in package image.\n" +
 		"\tFormat string \n" +
@@ -603,6 +966,49 @@ var typeDoc = map[string]string{
 		"    anything written to the file since the preparation of the check.\n" +
 		"\n" +
 		"    Logfile on remote (Unix) machines may be accessed via ssh (experimental).",
+	"message": "type Message map[string]Field\n" +
+		"    Message describes the fields of a protobuf message indexed by the\n" +
+		"    JSON name of the field.",
+	"mockextractor": "type MockExtractor struct {\n" +
+		"\t// Mock is the name of the mock.\n" +
+		"\tMock string\n" +
+		"\n" +
+		"\t// Header is the name of the header to extract. If empty the request\n" +
+		"\t// body is used.\n" +
+		"\tHeader string \n" +
+		"\n" +
+		"\t// Regexp, if non-empty, is applied to the header value or body and\n" +
+		"\t// the match is extracted.\n" +
+		"\tRegexp string \n" +
+		"\n" +
+		"\t// SubMatch selects which submatch (capturing group) of Regexp shall\n" +
+		"\t// be returned. A 0 value indicates the whole match.\n" +
+		"\tSubmatch int \n" +
+		"}\n" +
+		"    MockExtractor extracts data from the latest request received by a mock\n" +
+		"    provided for the test.",
+	"mockrequest": "type MockRequest struct {\n" +
+		"\t// Mock is the name of the mock. The empty string selects all mocks.\n" +
+		"\tMock string \n" +
+		"\n" +
+		"\t// Count is the number of requests Mock must have received. The\n" +
+		"\t// zero value requires at least one request, a negative value none.\n" +
+		"\tCount int \n" +
+		"\n" +
+		"\t// Method is the expected request method.\n" +
+		"\tMethod string \n" +
+		"\n" +
+		"\t// Header maps header names to the condition the first header value\n" +
+		"\t// must fulfill. A missing header is treated as the empty string.\n" +
+		"\tHeader map[string]Condition \n" +
+		"\n" +
+		"\t// Body is the condition the request body must fulfill.\n" +
+		"\tBody Condition \n" +
+		"}\n" +
+		"    MockRequest checks the requests received by the mocks provided for the\n" +
+		"    test, e.g. that the mocked backend got the proper credentials:\n" +
+		"        {Check: \"MockRequest\", Mock: \"Payment\", Header: {Authorization: {Prefix: \"Bearer \"}}}\n" +
+		"    All requests received by Mock must fulfill the given conditions.",
 	"noservererror": "type NoServerError struct{}\n" +
 		"    NoServerError checks the HTTP status code for not being a 5xx server error\n" +
 		"    and that the body could be read without errors or timeouts.",
@@ -747,11 +1153,40 @@ var typeDoc = map[string]string{
 		"\t// Timeout of this request. If zero use DefaultClientTimeout.\n" +
 		"\tTimeout time.Duration \n" +
 		"\n" +
+		"\t// MaxBodySize limits the number of bytes of the response body\n" +
+		"\t// captured in Response.BodyStr. Larger bodies are truncated and\n" +
+		"\t// streamed through the checks which support it (see BodyStreamer)\n" +
+		"\t// instead of being kept in memory. Zero means no limit.\n" +
+		"\tMaxBodySize int64 \n" +
+		"\n" +
+		"\t// Signature describes how to sign the request with a HMAC.\n" +
+		"\tSignature *Signature \n" +
+		"\n" +
+		"\t// StatusMapping maps the outcomes of bash://, sql:// and file://\n" +
+		"\t// pseudo-requests to the status code of the response, e.g.\n" +
+		"\t// {\"exit:3\": 404, \"sqlstate:23\": 409}. See the package documentation\n" +
+		"\t// for the outcomes and the default status codes.\n" +
+		"\tStatusMapping map[string]int \n" +
+		"\n" +
 		"\tRequest    *http.Request  // the 'real' request\n" +
 		"\tSentBody   string         // the 'real' body\n" +
 		"\tSentParams url.Values     // the 'real' parameters\n" +
 		"}\n" +
 		"    Request is a HTTP request.",
+	"requestdata": "type RequestData struct {\n" +
+		"\tMethod   string            // Method of the request.\n" +
+		"\tPath     string            // Path of the request.\n" +
+		"\tSegments []string          // Path split at \"/\" without empty segments.\n" +
+		"\tQuery    map[string]string // First value of each query parameter.\n" +
+		"\tHeader   map[string]string // First value of each header (canonical name).\n" +
+		"\tBody     string            // Body of the request.\n" +
+		"\tJSON     interface{}       // Body decoded as JSON (nil if no JSON).\n" +
+		"\tVars     scope.Variables   // Variables of the mock including path variables.\n" +
+		"}\n" +
+		"    RequestData is the data available to response templates of a mock with\n" +
+		"    Template set. A mock for \"/users/{id}\" can echo the requested id in its\n" +
+		"    response body like this:\n" +
+		"        {\"id\": \"{{.Vars.id}}\", \"name\": \"{{.JSON.name}}\", \"lang\": \"{{.Query.lang}}\"}",
 	"resilience": "type Resilience struct {\n" +
 		"\t// Methods is the space separated list of HTTP methods to check,\n" +
 		"\t// e.g. \"GET POST HEAD\". The empty value will test the original\n" +
@@ -839,6 +1274,41 @@ var typeDoc = map[string]string{
 		"    This check will make a wast amount of request to the given URL including the\n" +
 		"    modifying and non-idempotent methods POST, PUT, and DELETE. Some care using\n" +
 		"    this check is advisable.",
+	"resourceloading": "type ResourceLoading struct {\n" +
+		"\t// Types of resources to load. An empty Types loads stylesheets,\n" +
+		"\t// scripts and images.\n" +
+		"\tTypes []string \n" +
+		"\n" +
+		"\t// Concurrency is the number of resources loaded concurrently.\n" +
+		"\t// Zero means DefaultResourceConcurrency.\n" +
+		"\tConcurrency int \n" +
+		"\n" +
+		"\t// MaxResources limits the number of loaded resources. Zero means\n" +
+		"\t// DefaultMaxResources.\n" +
+		"\tMaxResources int \n" +
+		"\n" +
+		"\t// Timeout for loading one resource. Zero means the timeout of the\n" +
+		"\t// request.\n" +
+		"\tTimeout time.Duration \n" +
+		"\n" +
+		"\t// Ignore resources whose URL fulfills one of these conditions.\n" +
+		"\tIgnore []Condition \n" +
+		"}\n" +
+		"    ResourceLoading makes a test load the resources referenced by a HTML page\n" +
+		"    like a browser does: After the page has been received, all its stylesheets,\n" +
+		"    scripts, images etc. are fetched concurrently (reusing the connections and\n" +
+		"    the cookies of the test). The results are recorded in Response.Resources and\n" +
+		"    can be checked with the Assets check.\n" +
+		"\n" +
+		"    The following Types of resources are available:\n" +
+		"\n" +
+		"        stylesheet  <link rel=\"stylesheet\" href=\"...\">\n" +
+		"        script      <script src=\"...\">\n" +
+		"        image       <img src=\"...\">, <link rel=\"icon\" href=\"...\">\n" +
+		"        media       <video src=\"...\">, <audio src=\"...\">, <source src=\"...\">\n" +
+		"        iframe      <iframe src=\"...\">\n" +
+		"\n" +
+		"    Resources are loaded only for HTML pages.",
 	"responsetime": "type ResponseTime struct {\n" +
 		"\tLower  time.Duration \n" +
 		"\tHigher time.Duration \n" +
@@ -920,7 +1390,8 @@ var typeDoc = map[string]string{
 		"    extracted timestamp e.g. to multiple of hours use a format like \"2006-01-02\n" +
 		"    15:00:00\" with fixed minutes and seconds.\n" +
 		"\n" +
-		"    The test and the response are ignored.",
+		"    The current time is taken from the test variable FROZEN_NOW if set (see\n" +
+		"    scope.FrozenNow), the response is ignored.",
 	"setvariable": "type SetVariable struct {\n" +
 		"\t// To is the constant, fixed value to \"extract\".\n" +
 		"\tTo string \n" +
@@ -928,6 +1399,106 @@ var typeDoc = map[string]string{
 		"    SetVariable allows to progmatically \"extract\" a fixed value.\n" +
 		"\n" +
 		"    The test and the response are ignored.",
+	"signature": "type Signature struct {\n" +
+		"\t// Algorithm is the HMAC algorithm: \"hmac-sha1\", \"hmac-sha256\"\n" +
+		"\t// or \"hmac-sha512\". The empty string means \"hmac-sha256\".\n" +
+		"\tAlgorithm string \n" +
+		"\n" +
+		"\t// Key is the secret key. It typically references a variable like\n" +
+		"\t// \"{{API_SECRET}}\". Keys of the form \"base64:<data>\" are base64\n" +
+		"\t// decoded.\n" +
+		"\tKey string\n" +
+		"\n" +
+		"\t// StringToSign is the template of the string to sign. The empty\n" +
+		"\t// string means \"{method}\\n{uri}\\n{date}\\n{body-sha256}\".\n" +
+		"\tStringToSign string \n" +
+		"\n" +
+		"\t// Header is the request header which receives the signature. The\n" +
+		"\t// empty string means \"X-Signature\".\n" +
+		"\tHeader string \n" +
+		"\n" +
+		"\t// Format is the template of the header value. The empty string\n" +
+		"\t// means \"{signature}\".\n" +
+		"\tFormat string \n" +
+		"\n" +
+		"\t// Encoding of the signature: \"base64\", \"base64url\" or \"hex\".\n" +
+		"\t// The empty string means \"base64\".\n" +
+		"\tEncoding string \n" +
+		"\n" +
+		"\t// Digest sets the Digest header of the request to the base64 encoded\n" +
+		"\t// SHA-256 hash of the body, e.g. to be signed via {header:Digest}.\n" +
+		"\tDigest bool \n" +
+		"}\n" +
+		"    Signature describes how a request is signed with a HMAC, e.g. for APIs\n" +
+		"    authenticated by a custom X-Signature header or by HTTP Signatures.\n" +
+		"\n" +
+		"    The string to sign and the value of the signature header are templates with\n" +
+		"    placeholders in single braces:\n" +
+		"\n" +
+		"        {method}              the request method, e.g. POST\n" +
+		"        {request-target}      lowercase method and path with query, e.g.\n" +
+		"                              \"post /api/items?limit=5\"\n" +
+		"        {host}                the host (and port) of the request\n" +
+		"        {path}                the path of the request URL\n" +
+		"        {query}               the raw query of the request URL\n" +
+		"        {uri}                 path and query of the request URL\n" +
+		"        {date}                the Date header; it is set to the current\n" +
+		"                              time unless the Header of the Request\n" +
+		"                              contains a Date\n" +
+		"        {timestamp}           the current time as Unix seconds\n" +
+		"        {body}                the request body\n" +
+		"        {body-sha256}         the hex encoded SHA-256 hash of the body\n" +
+		"        {body-sha256-base64}  the base64 encoded SHA-256 hash of the body\n" +
+		"        {body-md5}            the hex encoded MD5 hash of the body\n" +
+		"        {header:<Name>}       the value of the request header Name\n" +
+		"        {signature}           the signature (only in Format)\n" +
+		"\n" +
+		"    A HTTP Signature (draft-cavage-http-signatures) can be produced by\n" +
+		"\n" +
+		"        Signature: {\n" +
+		"            Key: \"{{SECRET}}\"\n" +
+		"            Digest: true\n" +
+		"            StringToSign: \"(request-target): {request-target}\\nhost: {host}\\ndate: {date}\\ndigest: {header:Digest}\"\n" +
+		"            Header: \"Authorization\"\n" +
+		"            Format: \"Signature keyId=\\\"{{KEY_ID}}\\\",algorithm=\\\"hmac-sha256\\\",headers=\\\"(request-target) host date digest\\\",signature=\\\"{signature}\\\"\"\n" +
+		"        }\n" +
+		"\n" +
+		"    The signature is computed for each try after the body has been finalised and\n" +
+		"    the PreRequest hook has been called.",
+	"snapshot": "type Snapshot struct {\n" +
+		"\t// Baseline is the file path of the baseline, typically relative to the\n" +
+		"\t// test like in \"{{TEST_DIR}}/snapshots/homepage.snap\".\n" +
+		"\tBaseline string\n" +
+		"\n" +
+		"\t// Select is a list of CSS selectors of the DOM regions of a HTML\n" +
+		"\t// page to record.\n" +
+		"\tSelect []string \n" +
+		"\n" +
+		"\t// Links records the (unresolved) targets of all links, images,\n" +
+		"\t// scripts, stylesheets etc. of a HTML page.\n" +
+		"\tLinks bool \n" +
+		"\n" +
+		"\t// Mask is the list of volatile elements of a JSON document like\n" +
+		"\t// timestamps or IDs whose values are masked. The elements are given\n" +
+		"\t// in the notation of the JSON check, a \"*\" matches all array\n" +
+		"\t// elements or object members, e.g. \"items.*.id\".\n" +
+		"\tMask []string \n" +
+		"\n" +
+		"\t// Sep is the separator in Mask. A zero value is equivalent to \".\".\n" +
+		"\tSep string \n" +
+		"\n" +
+		"\t// Has unexported fields.\n" +
+		"}\n" +
+		"    Snapshot compares the response against a baseline recorded in a file. If the\n" +
+		"    baseline file does not exist (or if UpdateSnapshots is set) the snapshot of\n" +
+		"    the actual response is recorded as the new baseline and the check passes.\n" +
+		"    Otherwise the check fails with a line diff between the baseline and the\n" +
+		"    actual snapshot.\n" +
+		"\n" +
+		"    A snapshot of a HTML page consists of the DOM regions selected by Select\n" +
+		"    and/or the list of links and assets on the page. A snapshot of a JSON\n" +
+		"    document is the indented JSON where the volatile elements listed in Mask are\n" +
+		"    replaced by \"<masked>\". Other responses are snapshot as is.",
 	"sorted": "type Sorted struct {\n" +
 		"\t// Text is the list of text fragments to look for in the\n" +
 		"\t// response body or the normalized text content of the\n" +
@@ -946,6 +1517,15 @@ var typeDoc = map[string]string{
 		"    If the response has a Content-Type header indicating a HTML response the\n" +
 		"    HTML will be parsed and the text content normalized as described in the\n" +
 		"    HTMLContains check.",
+	"statusclass": "type StatusClass struct {\n" +
+		"\t// Expect is a comma separated list of status codes and classes of\n" +
+		"\t// status codes like \"2xx\" or \"2xx, 304\" one of which must match.\n" +
+		"\tExpect string\n" +
+		"\n" +
+		"\t// Has unexported fields.\n" +
+		"}\n" +
+		"    StatusClass checks the HTTP statuscode against a list of status codes and\n" +
+		"    classes of status codes.",
 	"statuscode": "type StatusCode struct {\n" +
 		"\t// Expect is the value to expect, e.g. 302.\n" +
 		"\t//\n" +
@@ -961,6 +1541,10 @@ var typeDoc = map[string]string{
 		"\t// Description what this test's intentions are.\n" +
 		"\tDescription string \n" +
 		"\n" +
+		"\t// Tags are free-form labels of the test like \"smoke\" or \"checkout\".\n" +
+		"\t// They are reported e.g. as labels in Allure results.\n" +
+		"\tTags []string \n" +
+		"\n" +
 		"\t// Request is the HTTP request.\n" +
 		"\tRequest Request\n" +
 		"\n" +
@@ -976,9 +1560,44 @@ var typeDoc = map[string]string{
 		"\t// Execution controls the test execution.\n" +
 		"\tExecution Execution \n" +
 		"\n" +
+		"\t// Transform the response body before the checks and extractors\n" +
+		"\t// are applied, e.g. to decrypt or decompress it.\n" +
+		"\tTransform []Transformation \n" +
+		"\n" +
+		"\t// Hooks are called before each request, after each response and\n" +
+		"\t// on failure of the test.\n" +
+		"\tHooks *Hooks \n" +
+		"\n" +
+		"\t// LoadResources makes the test load the resources referenced by a\n" +
+		"\t// HTML page like a browser.\n" +
+		"\tLoadResources *ResourceLoading \n" +
+		"\n" +
+		"\t// WireLog dumps the requests and responses of the test to files.\n" +
+		"\tWireLog *WireLog \n" +
+		"\n" +
 		"\t// Jar is the cookie jar to use\n" +
 		"\tJar *cookiejar.Jar \n" +
 		"\n" +
+		"\t// ClientPool provides the HTTP connections of this test. Nil uses\n" +
+		"\t// the global Transport.\n" +
+		"\tClientPool *ClientPool \n" +
+		"\n" +
+		"\t// AllowedHosts restricts the hosts HTTP requests (including followed\n" +
+		"\t// redirects) may be sent to. A request to any other host results in\n" +
+		"\t// an Error without being sent. Entries may be hostnames, wildcards\n" +
+		"\t// like \"*.example.org\", IP addresses or CIDR ranges like \"10.0.0.0/8\".\n" +
+		"\t// A nil AllowedHosts allows any host.\n" +
+		"\tAllowedHosts []string \n" +
+		"\n" +
+		"\t// Journal records the requests received by the mocks provided for\n" +
+		"\t// this test. It is accessible to Checks and Extractors.\n" +
+		"\tJournal *Journal \n" +
+		"\n" +
+		"\t// Span is the tracing span of this test. If set, each request sent\n" +
+		"\t// and each check executed is traced in a child span and the request\n" +
+		"\t// carries the W3C traceparent header of its span.\n" +
+		"\tSpan *tracing.Span \n" +
+		"\n" +
 		"\t// Variables contains name/value-pairs used for variable substitution\n" +
 		"\t// in files read in, e.g. for Request.Body = \"@vfile:/path/to/file\".\n" +
 		"\tVariables map[string]string \n" +
@@ -992,12 +1611,55 @@ var typeDoc = map[string]string{
 		"\t\tPrintf(format string, a ...interface{})\n" +
 		"\t} \n" +
 		"\n" +
+		"\t// Logger receives the log messages of the test as structured records\n" +
+		"\t// with the name of the test and its sequence number as fields.\n" +
+		"\t// If Logger is nil the messages are printed to Log.\n" +
+		"\tLogger logging.Logger \n" +
+		"\n" +
+		"\t// Renew produces the test anew with newly generated values for\n" +
+		"\t// variables like RANDOM or NOW. It is used to retry the test with\n" +
+		"\t// Execution.FreshVariables and is set e.g. by package suite.\n" +
+		"\tRenew func() (*Test, error) \n" +
+		"\n" +
 		"\t// Has unexported fields.\n" +
 		"}\n" +
 		"    Test is a single logical test which does one HTTP request and checks a\n" +
 		"    number of Checks on the received Response.",
 	"utf8encoded": "type UTF8Encoded struct{}\n" +
 		"    UTF8Encoded checks that the response body is valid UTF-8 without BOMs.",
+	"transformation": "type Transformation struct {\n" +
+		"\t// Kind of transformation.\n" +
+		"\tKind string\n" +
+		"\n" +
+		"\t// Key is the base64 encoded key for aes-gcm and jwe. It typically\n" +
+		"\t// references a variable like \"{{API_KEY}}\".\n" +
+		"\tKey string \n" +
+		"\n" +
+		"\t// Element selects the string value of this element of a JSON body\n" +
+		"\t// (in the syntax of the JSON check with a \".\" as separator) as the\n" +
+		"\t// input to the transformation. An empty Element uses the whole body.\n" +
+		"\tElement string \n" +
+		"}\n" +
+		"    Transformation is one step in the transformation of the response body\n" +
+		"    before the checks and extractors see it, e.g. to decrypt an encrypted\n" +
+		"    envelope. The following Kinds of transformation are available:\n" +
+		"\n" +
+		"        base64   decode standard or URL base64 (padding optional)\n" +
+		"        gunzip   decompress gzip data\n" +
+		"        latin1   convert ISO-8859-1 to UTF-8\n" +
+		"        charset  convert the charset declared in the Content-Type header\n" +
+		"                 or a HTML meta tag to UTF-8, see Response.Charset\n" +
+		"        aes-gcm  decrypt AES-GCM: a 12 byte nonce followed by the\n" +
+		"                 ciphertext and the tag\n" +
+		"        jwe      decrypt a JWE in compact serialization with alg \"dir\",\n" +
+		"                 \"A128KW\", \"A192KW\" or \"A256KW\" and enc \"A128GCM\",\n" +
+		"                 \"A192GCM\", \"A256GCM\", \"A128CBC-HS256\", \"A192CBC-HS384\"\n" +
+		"                 or \"A256CBC-HS512\"\n" +
+		"\n" +
+		"    The transformations of a test are applied in order, e.g. a base64\n" +
+		"    encoded, gzipped payload is decoded by\n" +
+		"\n" +
+		"        Transform: [ {Kind: \"base64\"}, {Kind: \"gunzip\"} ]",
 	"validhtml": "type ValidHTML struct {\n" +
 		"\t// Ignore is a space separated list of issues to ignore.\n" +
 		"\t// You normally won't skip detection of these issues as all issues\n" +
@@ -1038,6 +1700,45 @@ var typeDoc = map[string]string{
 		"}\n" +
 		"    W3CValidHTML checks for valid HTML but checking the response body via the\n" +
 		"    online checker from W3C which is very strict.",
+	"websocket": "type WebSocket struct {\n" +
+		"\t// Script is the sequence of steps to work through.\n" +
+		"\tScript []Frame\n" +
+		"}\n" +
+		"    WebSocket turns a mock into a WebSocket endpoint which works through\n" +
+		"    a scripted exchange of text messages once the client connected:\n" +
+		"        WebSocket: {\n" +
+		"            Script: [\n" +
+		"                {Receive: {Contains: \"subscribe\"}}\n" +
+		"                {Send: \"{\\\"event\\\": \\\"welcome\\\", \\\"user\\\": \\\"{{user}}\\\"}\"}\n" +
+		"                {Delay: \"1s\", Send: \"{\\\"event\\\": \\\"tick\\\"}\"}\n" +
+		"            ]\n" +
+		"        }\n" +
+		"    The connection is closed after the last step. The transcript of the\n" +
+		"    exchange is reported as the response body of the mock and a message\n" +
+		"    not fulfilling its Receive condition fails the mock invocation.",
+	"wirelog": "type WireLog struct {\n" +
+		"\t// Dir is the directory the files are written to. The files are\n" +
+		"\t// named after a running number unique in Dir, the sequence number\n" +
+		"\t// and the name of the test, e.g. \"0007_Main-03_Login_as_admin.wire\".\n" +
+		"\t// The running number restarts with each run (see ResetWireLogs).\n" +
+		"\tDir string\n" +
+		"\n" +
+		"\t// MaxBody limits the number of bytes dumped of each request and\n" +
+		"\t// response body. Zero means DefaultWireLogMaxBody, a negative value\n" +
+		"\t// dumps the full bodies.\n" +
+		"\tMaxBody int \n" +
+		"\n" +
+		"\t// MaxFiles limits the number of files written to Dir during one\n" +
+		"\t// run, e.g. during a load test. Zero means no limit.\n" +
+		"\tMaxFiles int \n" +
+		"\n" +
+		"\t// Redact lists additional headers to redact like \"X-Api-Key\".\n" +
+		"\tRedact []string \n" +
+		"}\n" +
+		"    WireLog dumps the request and the response of each try of a test as sent\n" +
+		"    and received over the wire to a file, one file per execution of a test.\n" +
+		"    The values of the headers in RedactedHeaders and in Redact are replaced by\n" +
+		"    \"<redacted>\" and all secret values (see scope.MarkSecret) are masked.",
 	"xml": "type XML struct {\n" +
 		"\t// Path is a XPath expression understood by gopkg.in/xmlpath.v2.\n" +
 		"\tPath string\n" +
@@ -1072,6 +1773,28 @@ var typeDoc = map[string]string{
 		"    It would set the variable \"age\" to 30 if first==\"Paul\" && last==\"Brown\".\n" +
 		"    \"John Miller\" would be 45 years old and \"Sue Carter\" 25 because \"*\" matches\n" +
 		"    any value. \"John Brown\" is 45 because matching happens left to right,",
+	"match": "type Match struct {\n" +
+		"\t// Header maps header names to the condition the header value\n" +
+		"\t// must fulfill. A missing header is treated as the empty string.\n" +
+		"\tHeader map[string]ht.Condition\n" +
+		"\n" +
+		"\t// Query maps query parameter names to the condition the parameter\n" +
+		"\t// value must fulfill.\n" +
+		"\tQuery map[string]ht.Condition\n" +
+		"\n" +
+		"\t// Body is the condition the request body must fulfill.\n" +
+		"\tBody ht.Condition\n" +
+		"\n" +
+		"\t// JSON maps elements of a JSON request body to the condition the\n" +
+		"\t// element must fulfill. Elements are given like \"user.roles.0\";\n" +
+		"\t// string elements are matched unquoted, all other elements are\n" +
+		"\t// matched as their JSON encoding.\n" +
+		"\tJSON map[string]ht.Condition\n" +
+		"}\n" +
+		"    Match contains conditions the incoming request must fulfill for a mock\n" +
+		"    to apply in addition to Method and URL. All given conditions must be\n" +
+		"    fulfilled. Overlapping mocks (same Method and URL) are tried in order of\n" +
+		"    decreasing Priority.",
 	"mock": "type Mock struct {\n" +
 		"\t// Name of this mock.\n" +
 		"\tName string\n" +
@@ -1114,6 +1837,34 @@ var typeDoc = map[string]string{
 		"\t// Response to send for this mock.\n" +
 		"\tResponse Response\n" +
 		"\n" +
+		"\t// Responses, if non-empty, is a sequence of responses sent instead\n" +
+		"\t// of Response: The first call gets the first response, the second\n" +
+		"\t// call the second and so on. The last response is repeated once\n" +
+		"\t// the sequence is exhausted.\n" +
+		"\tResponses []Response\n" +
+		"\n" +
+		"\t// Match contains additional conditions on the request (header,\n" +
+		"\t// query parameters and body) which must be fulfilled for this mock\n" +
+		"\t// to apply.\n" +
+		"\tMatch Match\n" +
+		"\n" +
+		"\t// Priority determines the order in which mocks with the same Method\n" +
+		"\t// and URL are considered: Higher priorities first.\n" +
+		"\tPriority int\n" +
+		"\n" +
+		"\t// Template turns the response body and header values into Go\n" +
+		"\t// text/template templates executed with the incoming request as\n" +
+		"\t// RequestData instead of the plain {{VARIABLE}} replacement.\n" +
+		"\tTemplate bool\n" +
+		"\n" +
+		"\t// GRPC, if non-nil, serves this mock as a unary gRPC method: The\n" +
+		"\t// response body is the JSON form of the response message.\n" +
+		"\tGRPC *GRPC\n" +
+		"\n" +
+		"\t// WebSocket, if non-nil, serves this mock as a WebSocket endpoint\n" +
+		"\t// with a scripted exchange of messages. Response is not used.\n" +
+		"\tWebSocket *WebSocket\n" +
+		"\n" +
 		"\t// Variables contains the default variables/values for this mock.\n" +
 		"\tVariables scope.Variables\n" +
 		"\n" +
@@ -1122,6 +1873,22 @@ var typeDoc = map[string]string{
 		"\t// response.\n" +
 		"\tMap []Mapping\n" +
 		"\n" +
+		"\t// Scenario is the name of the state machine this mock takes part in.\n" +
+		"\t// All mocks served together share the state of their scenarios.\n" +
+		"\t// Each scenario starts in state StateStarted.\n" +
+		"\tScenario string\n" +
+		"\n" +
+		"\t// RequiredState, if non-empty, is the state the Scenario must be in\n" +
+		"\t// for this mock to be applicable.\n" +
+		"\tRequiredState string\n" +
+		"\n" +
+		"\t// NewState, if non-empty, is the state the Scenario transitions to\n" +
+		"\t// after this mock was served.\n" +
+		"\tNewState string\n" +
+		"\n" +
+		"\t// Callbacks are made after the response has been sent.\n" +
+		"\tCallbacks []Callback\n" +
+		"\n" +
 		"\t// Monitor is used to report invocations if this mock.\n" +
 		"\t// The incomming request and the outgoing mocked response are encoded\n" +
 		"\t// in a ht.Test. The optional results of the Checks are stored in the\n" +
@@ -1153,15 +1920,45 @@ var typeDoc = map[string]string{
 		"\tVariables map[string]string\n" +
 		"\tMocks     []string\n" +
 		"\n" +
+		"\t// Exports is the list of variables the test may extract into the\n" +
+		"\t// suite scope. A nil Exports allows to extract any variable.\n" +
+		"\tExports []string\n" +
+		"\n" +
 		"\tTest map[string]interface{}\n" +
 		"}\n" +
 		"    RawElement represents one test in a RawSuite.",
+	"abortcondition": "type AbortCondition struct {\n" +
+		"\t// ErrorRate is the maximal tolerable error rate in percent.\n" +
+		"\t// Zero disables this limit.\n" +
+		"\tErrorRate float64\n" +
+		"\n" +
+		"\t// P99 is the maximal tolerable 99th percentile of the request\n" +
+		"\t// duration. Zero disables this limit.\n" +
+		"\tP99 time.Duration\n" +
+		"\n" +
+		"\t// Window over which the error rate and the 99th percentile are\n" +
+		"\t// computed. Defaults to 30 seconds.\n" +
+		"\tWindow time.Duration\n" +
+		"}\n" +
+		"    AbortCondition describes when a throughput test is aborted early: If the\n" +
+		"    error rate or the 99th percentile of the request duration of the requests\n" +
+		"    made during the last Window exceed their limit. The condition is not\n" +
+		"    evaluated before the throughput test has been running for Window so a\n" +
+		"    short burst of errors does not abort the test.",
 	"rawloadtest": "type RawLoadTest struct {\n" +
 		"\t*File\n" +
 		"\tName        string\n" +
 		"\tDescription string\n" +
 		"\tScenarios   []RawScenario\n" +
 		"\tVariables   map[string]string\n" +
+		"\n" +
+		"\t// SLA thresholds like \"p99 < 800ms\" or \"error rate < 1%\" which\n" +
+		"\t// the load test must fulfill.\n" +
+		"\tSLA []string\n" +
+		"\n" +
+		"\t// Abort the load test early if the error rate or the request\n" +
+		"\t// duration exceed the given limits for a sustained period.\n" +
+		"\tAbort AbortCondition\n" +
 		"}\n" +
 		"    RawLoadTest as read from disk.",
 	"rawmock": "type RawMock struct {\n" +
@@ -1178,10 +1975,39 @@ var typeDoc = map[string]string{
 		"\tMaxThreads int               // MaxThreads to use for this scenario. 0 means unlimited.\n" +
 		"\tVariables  map[string]string // Variables used.\n" +
 		"\tOmitChecks bool              // OmitChecks in the tests.\n" +
+		"\tProfile    RateProfile       // Profile of the request rate; overrides Percentage.\n" +
+		"\tThinkTime  ThinkTime         // ThinkTime between two tests.\n" +
+		"\tPacing     time.Duration     // Pacing is the minimal duration of one iteration.\n" +
+		"\tDataPool   string            // DataPool is a CSV or JSON file with records.\n" +
 		"\n" +
 		"\t// Has unexported fields.\n" +
 		"}\n" +
 		"    RawScenario represents a scenario in a load test.",
+	"thinktime": "type ThinkTime struct {\n" +
+		"\t// Distribution of the think time:\n" +
+		"\t//   \"fixed\" (or empty): always Mean\n" +
+		"\t//   \"uniform\":          uniformly distributed between Min and Max\n" +
+		"\t//   \"exponential\":      exponentially distributed with average Mean,\n" +
+		"\t//                       but never longer than Max (if Max > 0)\n" +
+		"\tDistribution string\n" +
+		"\n" +
+		"\tMean, Min, Max time.Duration\n" +
+		"}\n" +
+		"    ThinkTime describes the pause a simulated user takes between two\n" +
+		"    consecutive tests of a scenario.",
+	"ratestage": "type RateStage struct {\n" +
+		"\t// Duration of this stage.\n" +
+		"\tDuration time.Duration\n" +
+		"\n" +
+		"\t// Rate is the request rate (QPS) during this stage.\n" +
+		"\tRate float64\n" +
+		"\n" +
+		"\t// Ramp the request rate linearly from the rate at the end of the\n" +
+		"\t// previous stage (0 for the first stage) to Rate during this stage\n" +
+		"\t// instead of jumping to Rate immediately.\n" +
+		"\tRamp bool\n" +
+		"}\n" +
+		"    A RateStage is one stage of a RateProfile.",
 	"rawsuite": "type RawSuite struct {\n" +
 		"\t*File\n" +
 		"\tName, Description     string\n" +
@@ -1191,6 +2017,57 @@ var typeDoc = map[string]string{
 		"\tVariables             map[string]string\n" +
 		"\tVerbosity             int\n" +
 		"\n" +
+		"\t// AllowedHosts switches on hermetic mode: HTTP requests to hosts\n" +
+		"\t// not in this list are not sent but result in an Error.\n" +
+		"\tAllowedHosts []string\n" +
+		"\n" +
+		"\t// ReadOnly lists variables which must not be changed by a\n" +
+		"\t// DataExtraction of any test in the suite.\n" +
+		"\tReadOnly []string\n" +
+		"\n" +
+		"\t// VariablesFrom lists files (relative to the suite) with additional\n" +
+		"\t// variable defaults. Variables take precedence over these files.\n" +
+		"\tVariablesFrom []string\n" +
+		"\n" +
+		"\t// Profiles are named sets of variables like \"dev\" or \"staging\"\n" +
+		"\t// one of which can be selected during execution via SelectProfile.\n" +
+		"\tProfiles map[string]Profile\n" +
+		"\n" +
+		"\t// Declarations declare the type and constraints of variables the\n" +
+		"\t// suite expects, e.g. that HOST is required. Executions which do not\n" +
+		"\t// satisfy the declarations fail before any test is run.\n" +
+		"\tDeclarations map[string]VarDecl\n" +
+		"\n" +
+		"\t// LoadCookies and SaveCookies are files (relative to the suite) the\n" +
+		"\t// cookie jar is seeded from before the first test and saved to after\n" +
+		"\t// execution of the suite. Both require KeepCookies.\n" +
+		"\tLoadCookies, SaveCookies string\n" +
+		"\n" +
+		"\t// CookiePolicy restricts the cookies stored in the cookie jar, e.g.\n" +
+		"\t// to block third-party cookies. It requires KeepCookies.\n" +
+		"\tCookiePolicy cookiejar.Policy\n" +
+		"\n" +
+		"\t// ClientPool configures the HTTP connections shared by all tests\n" +
+		"\t// of the suite. Nil uses the global ht.Transport.\n" +
+		"\tClientPool *ht.ClientPool\n" +
+		"\n" +
+		"\t// Timeout limits the execution of the suite: Once it expires the\n" +
+		"\t// running test is canceled and the remaining Setup and Main tests\n" +
+		"\t// are skipped. Teardown tests are still run during the\n" +
+		"\t// TeardownGracePeriod. Zero means no limit.\n" +
+		"\tTimeout time.Duration\n" +
+		"\n" +
+		"\t// FreezeNow freezes the time used by the NOW function and by the\n" +
+		"\t// SetTimestamp extractors during the execution of the suite: \"start\"\n" +
+		"\t// freezes it to the start of the execution, other values are a fixed\n" +
+		"\t// time like \"2017-03-24T15:30:00+01:00\". A FROZEN_NOW variable set\n" +
+		"\t// from outside takes precedence.\n" +
+		"\tFreezeNow string\n" +
+		"\n" +
+		"\t// WireLog dumps the requests and responses of all tests which do\n" +
+		"\t// not have their own WireLog. A relative Dir is relative to the suite.\n" +
+		"\tWireLog *ht.WireLog\n" +
+		"\n" +
 		"\t// Has unexported fields.\n" +
 		"}\n" +
 		"    RawSuite represents a suite as represented on disk as a HJSON file.",
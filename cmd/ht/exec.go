@@ -6,16 +6,19 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"html/template"
 	"io/ioutil"
 	"log"
-	"math/rand"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"strings"
@@ -24,9 +27,12 @@ import (
 	"github.com/vdobler/ht/cookiejar"
 	"github.com/vdobler/ht/errorlist"
 	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/logging"
+	"github.com/vdobler/ht/openapi"
 	"github.com/vdobler/ht/sanitize"
 	"github.com/vdobler/ht/scope"
 	"github.com/vdobler/ht/suite"
+	"github.com/vdobler/ht/tracing"
 )
 
 var cmdExec = &Command{
@@ -56,14 +62,149 @@ A suite and the used tests may be given as an archive file like this:
 in the filesytem file <archivefile>. Archivefiles are collection of HJSON
 objects as described in the main help (run '$ ht help').
 
+Suites can also be loaded from http(s) URLs, from zip or tar bundles
+(<bundle.zip>!<suite>, the bundle may be a URL) and from a git repository
+(git:<repository>@<ref>:<suite>). Append #sha256=<digest> to pin the
+downloaded artifact; pinned artifacts are cached in the -remotecache
+directory.
+
 The TestIDs used in the -only and -skip flags have the format <suite>.<test>
 with <suite> and <test> the sequential numbers of the suite and the test
 inside the suite.  <test> maybe a single number like "3" or a range like
 "3-7" and the "<suite>." part can be omitted if only one suite is executed.
+
+The outcome of each test is recorded in a checkpoint file in the output
+folder while the suites execute. An interrupted execution can be resumed
+with the -resume flag (which requires -output to name the folder of the
+interrupted run): Main tests which passed in the interrupted run are
+skipped and their extracted variables and cookies are reused; Setup and
+Teardown tests are executed again.
+
+Interrupting exec (e.g. with Ctrl-C) cancels the running requests, bash
+scripts and SQL queries and skips the remaining Setup and Main tests; the
+Teardown tests of the started suites are still executed but get canceled
+after a grace period of 10 seconds. A second interrupt terminates exec
+immediately.
+
+With the -log flag the log messages of the suites, tests and mocks are
+written as structured records with the fields suite, test and seqno:
+"-log text" prints them as lines like 'INFO  Running suite="..." test="..."',
+"-log json" as one JSON object per line for ingestion by a CI system.
+All other output of exec to stdout is logged as records too, e.g. the
+report of each suite in the field report.
+
+The request/response traffic of each suite is saved as a HTTP Archive
+(traffic.har) which can be loaded into the developer tools of a browser.
+The result of each suite is saved as result.json in a stable, versioned
+format (see type SuiteResult in package suite) intended to be consumed
+by other tools. Besides the error messages it lists each error's
+code (like "check.StatusCode" or "request.timeout"), category (network,
+check, validation, bogus or hook) and offending field (like "Checks[2]") which
+allows to group failures without matching messages. With the -allure flag the tests are additionally saved
+as Allure results in the folder allure-results: One result per test with
+the checks as steps, the request and response as attachments and the
+tags of the test as labels.
+
+All suites of one execution are also summarised in the single, self-
+contained file report.html in the output folder: It needs no other
+files, can be filtered by status and searched and shows the request,
+response (bodies cut to 16 KiB), checks, variables and a timing
+waterfall of each test which makes it suitable to be attached to a ticket.
+
+With the -otel flag the execution is traced with OpenTelemetry: Each suite
+is a trace with spans for its tests and their requests and checks. The
+requests carry the W3C traceparent header so the spans created by the
+backend join the trace. The spans are sent via OTLP/HTTP (JSON) to the
+collector at the given URL which defaults to $OTEL_EXPORTER_OTLP_ENDPOINT.
+The service name is taken from $OTEL_SERVICE_NAME (default "ht"). The
+trace ID of each test is reported in result.json.
+
+With the -notify flag a summary of the run (the overall status, the
+status of each suite and the errors of the failed tests, truncated to
+2000 bytes) is posted to a Slack, Microsoft Teams or generic webhook once
+all suites have been executed. The payload format is guessed from the
+webhook URL unless given with -notifyformat: Generic webhooks receive a
+JSON object with the status, the message text and the result of each
+suite (as in result.json). The message is produced by a Go text/template
+read from the -notifytmpl file (see type NotifyData in package suite for
+the available data). With -notifyworse the overall status is recorded in
+the given file and the notification is posted only if the status is worse
+than the one of the previous run, e.g.:
+    ht exec -notify https://hooks.slack.com/services/... \
+            -notifyworse .ht-status.json shop.suite
+
+With the -ci flag the outcome of the run is reported to the commit under
+test on GitHub or GitLab: For -ci github a check run is created with an
+annotation for each failed test pointing to the line of the failing check
+in the test (or mixin) file so failures show up directly in the pull
+request. For -ci gitlab the commit status is set and (up to 10) failed
+tests are commented on their definition. Repository, commit and API are
+taken from the environment of GitHub Actions (GITHUB_REPOSITORY,
+GITHUB_SHA, GITHUB_API_URL) or GitLab CI (CI_PROJECT_ID, CI_COMMIT_SHA,
+CI_API_V4_URL); the access token must be provided in GITHUB_TOKEN or
+GITLAB_TOKEN respectively.
+
+With the -es flag the result of each test is indexed as one document into
+Elasticsearch or OpenSearch (see type ElasticDocument in package suite for
+the fields) which allows to build e.g. Kibana dashboards over the results
+of many runs. Each document contains the name of the output folder as
+run_id and the name given with -esenv as environment. User and password
+may be given in the URL, an API key in the environment variable
+ES_API_KEY.
+
+With the -history flag the status and duration of each test is appended
+to the given history file. Use 'ht history' to show trends, newly failing
+and slow tests and flaky tests recorded in this file.
+
+With the -openapi flag the requests made by all executed tests are matched
+against the operations of the given OpenAPI 3 or Swagger 2 specification
+(in JSON or YAML format) and a coverage report is saved as
+openapi-coverage.txt in the output folder: It lists which operations and
+which of their documented response status codes were exercised, received
+status codes which are not documented and requests not described by the
+specification. With -mincoverage the run fails (with exit code 1) if less
+than the given percentage of the operations were exercised, which is also
+reported to the -ci and -notify targets, e.g.:
+    ht exec -openapi api.yaml -mincoverage 80 contract.suite
+
+With the -curl flag the request of each failed test is printed as a curl
+command (after variable substitution) which allows to reproduce it manually.
+Values of secret variables are masked in these commands.
+
+With the -watch flag exec keeps running after executing the suites and
+watches the files used by each suite (the suite itself, its tests, mixins,
+mocks and variable files; files inside an archive via the archive). Once
+one of these files is saved the suite is loaded again and re-executed.
+All suites are re-executed if the file given with -Dfile changes. Each
+run starts with the cookies loaded via -cookie only. Only a compact summary
+listing the tests which did not pass is printed and no reports are saved
+in watch mode.
+
+With the -j flag up to N suites are executed concurrently. Each suite
+gets its own cookiejar (a copy of the one loaded via -cookie) so the
+suites cannot interfere via cookies; the suites must not depend on each
+other in any other way, e.g. on data created by a previous suite. The
+reports and the log output are still printed and saved in the order of
+the suites on the command line. Flag -j cannot be combined with -carry.
 `,
 }
 
 var carryVars bool
+var resumeExec bool
+var watchMode bool
+var parallelJobs = 1
+var allureResults bool
+var otelEndpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+var tracer *tracing.Tracer
+var notifier = &suite.Notifier{}
+var notifyTemplate string
+var ciProvider string
+var historyFile string
+var elastic = &suite.ElasticReporter{APIKey: os.Getenv("ES_API_KEY")}
+var harBodyLimit = 1 << 20
+var openapiSpec string
+var minCoverage float64
+var apiSpec *openapi.Spec
 
 func init() {
 	addOnlyFlag(cmdExec.Flag)
@@ -72,19 +213,101 @@ func init() {
 	addTestFlags(cmdExec.Flag)
 	addOutputFlag(cmdExec.Flag)
 	addShowFlag(cmdExec.Flag)
+	addLogFlag(cmdExec.Flag)
 
 	cmdExec.Flag.BoolVar(&carryVars, "carry", false,
 		"carry variables from finished suite to next suite")
-
+	cmdExec.Flag.BoolVar(&resumeExec, "resume", false,
+		"resume interrupted execution recorded in the -output folder")
+	cmdExec.Flag.IntVar(&harBodyLimit, "harlimit", harBodyLimit,
+		"truncate bodies in traffic.har to `bytes` (-1: no limit)")
+	cmdExec.Flag.BoolVar(&watchMode, "watch", false,
+		"re-execute suites whenever one of their files changes")
+	cmdExec.Flag.IntVar(&parallelJobs, "j", parallelJobs,
+		"execute up to `N` suites in parallel")
+	cmdExec.Flag.BoolVar(&allureResults, "allure", false,
+		"save results also in Allure format")
+	cmdExec.Flag.StringVar(&otelEndpoint, "otel", otelEndpoint,
+		"export traces to the OpenTelemetry collector at `URL`")
+	cmdExec.Flag.StringVar(&notifier.URL, "notify", "",
+		"post a summary of the run to the webhook at `URL`")
+	cmdExec.Flag.StringVar(&notifier.Format, "notifyformat", "",
+		"payload `format` of -notify: slack, teams or generic")
+	cmdExec.Flag.StringVar(&notifyTemplate, "notifytmpl", "",
+		"read message template of -notify from `file`")
+	cmdExec.Flag.StringVar(&notifier.StateFile, "notifyworse", "",
+		"notify only if status is worse than recorded in `statefile`")
+	cmdExec.Flag.StringVar(&ciProvider, "ci", "",
+		"report status and annotations to `provider` github or gitlab")
+	cmdExec.Flag.StringVar(&elastic.URL, "es", "",
+		"index test results into Elasticsearch/OpenSearch at `URL`")
+	cmdExec.Flag.StringVar(&elastic.Index, "esindex", "ht-results",
+		"name of the `index` used by -es")
+	cmdExec.Flag.StringVar(&elastic.Environment, "esenv", "",
+		"`environment` name stored with the results indexed by -es")
+	cmdExec.Flag.StringVar(&historyFile, "history", "",
+		"append results to the history `file` (see ht history)")
+	cmdExec.Flag.StringVar(&openapiSpec, "openapi", "",
+		"report coverage of the OpenAPI specification in `file`")
+	cmdExec.Flag.Float64Var(&minCoverage, "mincoverage", 0,
+		"fail if less than `percent` of the -openapi operations are covered")
 }
 
 func runExecute(cmd *Command, suites []*suite.RawSuite) {
+	if parallelJobs > 1 && carryVars {
+		fmt.Fprintln(os.Stderr, "Flag -j cannot be combined with -carry")
+		os.Exit(9)
+	}
 	if ssilent {
 		silent = true
 	}
+	if allureResults {
+		reporters = append(reporters, suite.AllureReporter)
+	}
+	if ciProvider != "" && ciProvider != "github" && ciProvider != "gitlab" {
+		fmt.Fprintf(os.Stderr, "Unknown CI provider %q, must be github or gitlab\n", ciProvider)
+		os.Exit(9)
+	}
+	if notifier.URL != "" && notifyTemplate != "" {
+		tmpl, err := ioutil.ReadFile(notifyTemplate)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot read notification template: %s\n", err)
+			os.Exit(9)
+		}
+		notifier.Template = string(tmpl)
+	}
+	if minCoverage > 0 && openapiSpec == "" {
+		fmt.Fprintln(os.Stderr, "Flag -mincoverage requires -openapi")
+		os.Exit(9)
+	}
+	if openapiSpec != "" {
+		file, err := os.Open(openapiSpec)
+		if err == nil {
+			apiSpec, err = openapi.Read(file)
+			file.Close()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot read %s: %s\n", openapiSpec, err)
+			os.Exit(9)
+		}
+	}
+	if otelEndpoint != "" {
+		service := os.Getenv("OTEL_SERVICE_NAME")
+		if service == "" {
+			service = "ht"
+		}
+		tracer = tracing.NewTracer(service)
+	}
 	prepareHT()
 	jar := loadCookies()
 
+	if resumeExec && (outputDir == "" || outputDir == "/dev/null") {
+		fmt.Fprintln(os.Stderr, "Flag -resume requires the -output folder of the interrupted run")
+		os.Exit(9)
+	}
+	if watchMode {
+		watch(suites, variablesFlag, jar)
+	}
 	prepareOutputDir()
 	var errors errorlist.List
 
@@ -103,18 +326,18 @@ func prepareHT() {
 		randomSeed = time.Now().UnixNano()
 	}
 	scope.ResetCounter <- counterSeed
-	scope.Random = rand.New(rand.NewSource(randomSeed))
+	scope.Seed(randomSeed)
 	ht.PhantomJSExecutable = phantomjs
 	ht.DefaultClientTimeout = timeout
 	if !silent {
-		fmt.Printf("Seeding random number generator with %d.\n", randomSeed)
-		fmt.Printf("Resetting global counter to %d.\n", counterSeed)
-		fmt.Printf("Using %q as PhantomJS executable.\n", phantomjs)
-		fmt.Printf("Default client timeout is %s.\n", timeout)
+		printf(nil, "Seeding random number generator with %d.\n", randomSeed)
+		printf(nil, "Resetting global counter to %d.\n", counterSeed)
+		printf(nil, "Using %q as PhantomJS executable.\n", phantomjs)
+		printf(nil, "Default client timeout is %s.\n", timeout)
 	}
 	if skipTLSVerify {
 		if !silent {
-			fmt.Println("Skipping verification of TLS certificates presented by any server.")
+			printf(nil, "Skipping verification of TLS certificates presented by any server.\n")
 		}
 		ht.Transport.TLSClientConfig.InsecureSkipVerify = true
 	}
@@ -126,7 +349,7 @@ func prepareHT() {
 			variablesFlag["CWD"] = cwd
 		}
 		if !silent {
-			fmt.Printf("Setting CWD (current working dir) to %s.\n",
+			printf(nil, "Setting CWD (current working dir) to %s.\n",
 				variablesFlag["CWD"])
 		}
 	}
@@ -139,7 +362,7 @@ func prepareHT() {
 		}
 		sort.Strings(varnames)
 		for _, v := range varnames {
-			fmt.Printf("Variable %s = %q\n", v, variablesFlag[v])
+			printf(nil, "Variable %s = %q\n", v, scope.Mask(variablesFlag[v]))
 		}
 	}
 }
@@ -160,14 +383,15 @@ func prepareOutputDir() {
 	}
 }
 
-// execute suites one by one saving each suite to disk once finished.
-// Returns the accumulated overall result.
+// execute suites one by one (or up to parallelJobs concurrently) saving
+// each suite to disk once finished. Returns the accumulated overall result.
 func executeSuites(suites []*suite.RawSuite, variables map[string]string, jar *cookiejar.Jar) (*accumulator, error) {
 	bufferedStdout := bufio.NewWriterSize(os.Stdout, 256)
 	defer bufferedStdout.Flush()
-	logger := log.New(bufferedStdout, "", 0)
+	logger := log.New(scope.MaskingWriter(bufferedStdout), "", 0)
 	errors := errorlist.List{}
 	var err error
+	ht.ResetWireLogs()
 
 	if !mute {
 		if outputDir == "" {
@@ -177,49 +401,255 @@ func executeSuites(suites []*suite.RawSuite, variables map[string]string, jar *c
 		errors = errors.Append(err)
 	}
 
+	ctx, cancel := interruptContext()
+	defer cancel()
+
 	accum := newAccumulator()
-	multipleSuites := len(suites) > 1
-	for i, s := range suites {
-		if !ssilent {
-			logger.Println("Starting Suite", i+1, s.Name, s.File.Name)
+	if parallelJobs > 1 && len(suites) > 1 {
+		results := executeParallel(ctx, suites, variables, jar)
+		for i := range suites {
+			r := <-results[i]
+			bufferedStdout.Write(r.log)
+			bufferedStdout.Flush()
+			errors = errors.Append(r.err)
+			err = reportSuite(accum, i, len(suites), r.outcome)
+			errors = errors.Append(err)
 		}
-		outcome := s.Execute(variables, jar, logger)
-		bufferedStdout.Flush()
+		return accum, errors.AsError()
+	}
 
-		accum.update(outcome)
+	for i, s := range suites {
+		outcome, err := executeSuite(ctx, i, s, variables, jar, logger)
+		errors = errors.Append(err)
+		bufferedStdout.Flush()
 
 		if carryVars {
 			variables = outcome.FinalVariables // carry over variables ???
 		}
-		if !silent {
-			err = outcome.PrintReport(os.Stdout)
-		} else if !ssilent {
-			err = outcome.PrintShortReport(os.Stdout)
-			fmt.Println()
+		err = reportSuite(accum, i, len(suites), outcome)
+		errors = errors.Append(err)
+	}
+	return accum, errors.AsError()
+}
+
+// executeSuite executes the i'th (0-based) suite s recording the outcome
+// of its tests in a checkpoint file in the output folder.
+func executeSuite(ctx context.Context, i int, s *suite.RawSuite, variables map[string]string, jar *cookiejar.Jar, logger *log.Logger) (*suite.Suite, error) {
+	errors := errorlist.List{}
+	slogger := structuredLogger(logger.Writer())
+	if !ssilent {
+		if slogger != nil {
+			slogger.Log(logging.Info, fmt.Sprintf("Starting Suite %d", i+1),
+				logging.F(logging.SuiteKey, s.Name), logging.F("file", s.File.Name))
+		} else {
+			logger.Println("Starting Suite", i+1, s.Name, s.File.Name)
 		}
+	}
+	var cp *suite.Checkpoint
+	if !mute {
+		var err error
+		cpName := path.Join(outputDir, fmt.Sprintf("checkpoint-%d.jsonl", i+1))
+		cp, err = suite.OpenCheckpoint(cpName, resumeExec)
 		errors = errors.Append(err)
+	}
+	var observers []suite.Observer
+	if tracer != nil {
+		observers = append(observers, suite.NewTracingObserver(tracer))
+	}
+	s.SetLogger(slogger)
+	outcome := s.ExecuteWith(variables, suite.ExecOptions{
+		Context:    ctx,
+		Jar:        jar,
+		Logger:     logger,
+		Checkpoint: cp,
+		Observers:  observers,
+	})
+	errors = errors.Append(cp.Close())
+	if tracer != nil && len(outcome.Tests) > 0 && !ssilent {
+		traceID := outcome.Tests[0].GetStringMetadata("TraceID")
+		if slogger != nil {
+			slogger.Log(logging.Info, fmt.Sprintf("Trace ID of suite %d", i+1),
+				logging.F(logging.SuiteKey, s.Name), logging.F("traceid", traceID))
+		} else {
+			logger.Printf("Trace ID of suite %d: %s\n", i+1, traceID)
+		}
+	}
+	return outcome, errors.AsError()
+}
+
+// parallelResult is the outcome of a suite executed by executeParallel
+// together with its log output.
+type parallelResult struct {
+	outcome *suite.Suite
+	log     []byte
+	err     error
+}
+
+// executeParallel executes suites in parallelJobs many workers. Each suite
+// uses its own copy of jar so the suites cannot influence each other via
+// cookies. The result of the i'th suite is delivered on the i'th channel
+// which allows to report the results in the original order.
+func executeParallel(ctx context.Context, suites []*suite.RawSuite, variables map[string]string, jar *cookiejar.Jar) []chan parallelResult {
+	results := make([]chan parallelResult, len(suites))
+	for i := range results {
+		results[i] = make(chan parallelResult, 1)
+	}
+	jobs := make(chan int)
+	go func() {
+		for i := range suites {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+	for w := 0; w < parallelJobs; w++ {
+		go func() {
+			for i := range jobs {
+				buf := &bytes.Buffer{}
+				logger := log.New(scope.MaskingWriter(buf), "", 0)
+				outcome, err := executeSuite(ctx, i, suites[i], variables, copyJar(jar), logger)
+				results[i] <- parallelResult{outcome: outcome, log: buf.Bytes(), err: err}
+			}
+		}()
+	}
+	return results
+}
+
+// interruptContext returns a context which is canceled on the first
+// interrupt. The signal handler is removed afterwards so that a second
+// interrupt terminates the process.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		select {
+		case <-sig:
+			fmt.Fprintln(os.Stderr, "Interrupted: canceling tests, running teardown (interrupt again to quit).")
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(sig)
+	}()
+	return ctx, cancel
+}
 
-		err = saveSingle(accum, outputDir, outcome, showBrowser && (!multipleSuites))
+// copyJar returns a new jar with the cookies of jar. A nil jar is not
+// copied.
+func copyJar(jar *cookiejar.Jar) *cookiejar.Jar {
+	if jar == nil {
+		return nil
+	}
+	entries := []cookiejar.Entry{}
+	for _, tld := range jar.ETLDsPlus1(nil) {
+		entries = append(entries, jar.Entries(tld, nil)...)
+	}
+	cp, _ := cookiejar.New(nil)
+	cp.LoadEntries(entries)
+	return cp
+}
+
+// reportSuite adds the outcome of the i'th of n suites to accum, prints
+// its report and saves it to the output folder.
+func reportSuite(accum *accumulator, i, n int, outcome *suite.Suite) error {
+	errors := errorlist.List{}
+	var err error
+	accum.update(outcome)
+	if tracer != nil {
+		err = tracer.Export(otelEndpoint)
 		errors = errors.Append(err)
-		if multipleSuites {
-			err := saveOverallReport(outputDir, accum, showBrowser && i == 0)
-			errors = errors.Append(err)
+	}
+	if elastic.URL != "" {
+		if elastic.RunID == "" {
+			elastic.RunID = time.Now().Format("2006-01-02_15h04m05s")
+			if !mute {
+				elastic.RunID = filepath.Base(outputDir)
+			}
 		}
+		err = elastic.Report("", outcome)
+		errors = errors.Append(err)
+	}
 
+	if stdoutLogger != nil && !ssilent {
+		err = logReport(outcome)
+	} else if !silent {
+		err = outcome.PrintReport(os.Stdout)
+	} else if !ssilent {
+		err = outcome.PrintShortReport(os.Stdout)
+		fmt.Println()
 	}
-	return accum, errors.AsError()
+	errors = errors.Append(err)
+	if curlFailed {
+		printCurlCalls(outcome)
+	}
+
+	multipleSuites := n > 1
+	err = saveSingle(accum, outputDir, outcome, showBrowser && (!multipleSuites))
+	errors = errors.Append(err)
+	if multipleSuites {
+		err := saveOverallReport(outputDir, accum, showBrowser && i == 0)
+		errors = errors.Append(err)
+	}
+	return errors.AsError()
 }
 
 // ----------------------------------------------------------------------------
 // Reporting functions
 
+// logReport logs the outcome of suite s to stdoutLogger: The report (or
+// the short report with -ss) is logged as the field report of a single
+// record.
+func logReport(s *suite.Suite) error {
+	buf := &bytes.Buffer{}
+	var err error
+	if !silent {
+		err = s.PrintReport(buf)
+	} else {
+		err = s.PrintShortReport(buf)
+	}
+	if err != nil {
+		return err
+	}
+	stdoutLogger.Log(logging.Info, "Suite finished",
+		logging.F(logging.SuiteKey, s.Name),
+		logging.F("status", s.Status.String()),
+		logging.F("report", buf.String()))
+	return nil
+}
+
+// printCurlCalls prints the curl command reproducing the request of each
+// failed or errored test in s. Secret values are masked.
+func printCurlCalls(s *suite.Suite) {
+	w := scope.MaskingWriter(os.Stdout)
+	for i, test := range s.Tests {
+		if test.Result.Status != ht.Fail && test.Result.Status != ht.Error {
+			continue
+		}
+		if stdoutLogger != nil {
+			stdoutLogger.Log(logging.Info, fmt.Sprintf("Curl call for test %d", i+1),
+				logging.F(logging.SuiteKey, s.Name),
+				logging.F(logging.TestKey, test.Name),
+				logging.F("status", test.Result.Status.String()),
+				logging.F("curl", test.CurlCall()))
+			continue
+		}
+		fmt.Fprintf(w, "Curl call for test %d %q (%s):\n%s\n\n",
+			i+1, test.Name, test.Result.Status, test.CurlCall())
+	}
+}
+
+// reporters write additional reports of each suite to its result folder.
+var reporters = []suite.Reporter{suite.JSONReporter}
+
 // saveSingle takes care of dumping the suite s into a subfolder of
 // outputdir. It will produce:
 //     _Report_.html  with accomaning files for the response bodies
 //     junit-report.xml
+//     result.json
 //     result.txt
 //     variables.json
 //     cookies.json
+//     traffic.har
+// and the reports of the reporters.
 func saveSingle(accum *accumulator, outputDir string, s *suite.Suite, openBrowser bool) error {
 	if mute || outputDir == "/dev/null" {
 		return nil
@@ -227,7 +657,8 @@ func saveSingle(accum *accumulator, outputDir string, s *suite.Suite, openBrowse
 
 	num := len(accum.Suites)
 	dirname := path.Join(outputDir, accum.Suites[num-1].Dirname)
-	fmt.Printf("Saving result of %d. suite %q to folder %q.\n", num, s.Name, dirname)
+	printf([]logging.Field{logging.F(logging.SuiteKey, s.Name), logging.F("folder", dirname)},
+		"Saving result of %d. suite %q to folder %q.\n", num, s.Name, dirname)
 	err := os.MkdirAll(dirname, 0766)
 	if err != nil {
 		return err
@@ -248,7 +679,8 @@ func saveSingle(accum *accumulator, outputDir string, s *suite.Suite, openBrowse
 	cwd, err := os.Getwd()
 	errors = errors.Append(err)
 	reportURL := "file://" + path.Join(cwd, dirname, "_Report_.html")
-	fmt.Printf("See %s\n", reportURL)
+	printf([]logging.Field{logging.F(logging.SuiteKey, s.Name), logging.F("report", reportURL)},
+		"See %s\n", reportURL)
 	if openBrowser {
 		startBrowser(reportURL)
 	}
@@ -261,6 +693,17 @@ func saveSingle(accum *accumulator, outputDir string, s *suite.Suite, openBrowse
 		errors = errors.Append(err)
 	}
 
+	for _, reporter := range reporters {
+		errors = errors.Append(reporter.Report(dirname, s))
+	}
+
+	har, err := s.HAR(harBodyLimit)
+	errors = errors.Append(err)
+	if err == nil {
+		err = ioutil.WriteFile(path.Join(dirname, "traffic.har"), har, 0666)
+		errors = errors.Append(err)
+	}
+
 	// TODO: handle errors
 	err = saveVariables(s.FinalVariables, path.Join(dirname, "variables.json"))
 	errors = errors.Append(err)
@@ -288,6 +731,19 @@ func startBrowser(file string) error {
 func reportOverall(a *accumulator) error {
 	var errors errorlist.List
 	var err error
+
+	// Evaluate the coverage first: Falling below -mincoverage fails the
+	// run which must be visible in all reports below.
+	var problems []string
+	if apiSpec != nil {
+		var problem string
+		problem, err = reportCoverage(a)
+		errors = errors.Append(err)
+		if problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+
 	// Save consolidated variables if required.
 	if vardump != "" && !mute {
 		err = saveVariables(a.Vars, vardump)
@@ -300,7 +756,38 @@ func reportOverall(a *accumulator) error {
 		errors = errors.Append(err)
 	}
 
-	if !ssilent {
+	// Save the single file report of all suites.
+	if !mute && len(a.suites) > 0 {
+		err = saveSingleFileReport(outputDir, a)
+		errors = errors.Append(err)
+	}
+
+	if historyFile != "" && len(a.suites) > 0 {
+		started := a.suites[0].Started
+		err = suite.AppendHistory(historyFile, suite.RunID(started), started, a.suites)
+		errors = errors.Append(err)
+	}
+
+	if ciProvider != "" {
+		err = newCIReporter().Report(a.suites, problems...)
+		errors = errors.Append(err)
+	}
+
+	if notifier.URL != "" {
+		posted, err := notifier.Notify(a.suites, problems...)
+		errors = errors.Append(err)
+		if posted && !ssilent {
+			printf(nil, "Posted notification to %s\n", notifier.Redacted())
+		}
+	}
+
+	if !ssilent && stdoutLogger != nil {
+		stdoutLogger.Log(logging.Info, "Finished",
+			logging.F("status", a.Status.String()),
+			logging.F("total", a.Total), logging.F("passed", a.Pass),
+			logging.F("skipped", a.Skip), logging.F("errored", a.Err),
+			logging.F("failed", a.Fail), logging.F("bogus", a.Bogus))
+	} else if !ssilent {
 		fmt.Println()
 		fmt.Printf("Total %d,  Passed %d,  Skipped %d,  Errored %d,  Failed %d,  Bogus %d\n",
 			a.Total, a.Pass, a.Skip, a.Err, a.Fail, a.Bogus)
@@ -310,6 +797,43 @@ func reportOverall(a *accumulator) error {
 	return errors.AsError()
 }
 
+// reportCoverage reports the coverage of the -openapi specification by the
+// executed suites and fails the run if it is below -mincoverage. The
+// returned problem describes the insufficient coverage.
+func reportCoverage(a *accumulator) (problem string, err error) {
+	cov := openapi.NewCoverage(apiSpec)
+	for _, s := range a.suites {
+		cov.RecordTests(s.Tests)
+	}
+	buf := &bytes.Buffer{}
+	if err := cov.WriteReport(buf); err != nil {
+		return "", err
+	}
+	if !ssilent {
+		if stdoutLogger != nil {
+			stdoutLogger.Log(logging.Info, "OpenAPI coverage",
+				logging.F("coverage", cov.Summary()),
+				logging.F("report", buf.String()))
+		} else if mute || outputDir == "/dev/null" {
+			fmt.Print(buf.String())
+		} else {
+			fmt.Printf("OpenAPI coverage: %s\n", cov.Summary())
+		}
+	}
+	if cov.Percent() < minCoverage {
+		problem = fmt.Sprintf("OpenAPI coverage of %.1f%% is below the required %.1f%%",
+			cov.Percent(), minCoverage)
+		printf(nil, "%s\n", problem)
+		if a.Status < ht.Fail {
+			a.Status = ht.Fail
+		}
+	}
+	if mute || outputDir == "/dev/null" {
+		return problem, nil
+	}
+	return problem, ioutil.WriteFile(path.Join(outputDir, "openapi-coverage.txt"), buf.Bytes(), 0666)
+}
+
 var overallReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
 <html>
 <head>
@@ -380,7 +904,59 @@ func saveOverallReport(dirname string, accum *accumulator, openBrowser bool) err
 		if openBrowser {
 			startBrowser(reportURL)
 		}
-		fmt.Printf("Overall: %s\n", reportURL)
+		printf([]logging.Field{logging.F("report", reportURL)}, "Overall: %s\n", reportURL)
+	}
+	return err
+}
+
+// newCIReporter returns the reporter for ciProvider configured from the
+// environment variables of GitHub Actions or GitLab CI.
+func newCIReporter() *suite.CIReporter {
+	env := func(name, fallback string) string {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+		return fallback
+	}
+	if ciProvider == "github" {
+		target := ""
+		if run := os.Getenv("GITHUB_RUN_ID"); run != "" {
+			target = fmt.Sprintf("%s/%s/actions/runs/%s",
+				env("GITHUB_SERVER_URL", "https://github.com"),
+				os.Getenv("GITHUB_REPOSITORY"), run)
+		}
+		return &suite.CIReporter{
+			Provider:  "github",
+			API:       env("GITHUB_API_URL", "https://api.github.com"),
+			Project:   os.Getenv("GITHUB_REPOSITORY"),
+			Commit:    os.Getenv("GITHUB_SHA"),
+			Token:     os.Getenv("GITHUB_TOKEN"),
+			TargetURL: target,
+			Root:      os.Getenv("GITHUB_WORKSPACE"),
+		}
+	}
+	return &suite.CIReporter{
+		Provider:  "gitlab",
+		API:       env("CI_API_V4_URL", "https://gitlab.com/api/v4"),
+		Project:   os.Getenv("CI_PROJECT_ID"),
+		Commit:    os.Getenv("CI_COMMIT_SHA"),
+		Token:     os.Getenv("GITLAB_TOKEN"),
+		TargetURL: os.Getenv("CI_JOB_URL"),
+		Root:      os.Getenv("CI_PROJECT_DIR"),
+	}
+}
+
+// saveSingleFileReport writes the self-contained report.html of all
+// suites to dirname.
+func saveSingleFileReport(dirname string, accum *accumulator) error {
+	file, err := os.Create(path.Join(dirname, "report.html"))
+	if err != nil {
+		return err
+	}
+	title := "Results " + filepath.Base(dirname)
+	err = suite.SingleFileReport(file, title, accum.suites)
+	if cerr := file.Close(); err == nil {
+		err = cerr
 	}
 	return err
 }
@@ -416,7 +992,7 @@ func saveVariables(vars map[string]string, filename string) error {
 	if mute {
 		return nil
 	}
-	b, err := json.MarshalIndent(vars, "    ", "")
+	b, err := json.MarshalIndent(scope.Variables(vars).MaskedCopy(), "    ", "")
 	if err != nil {
 		return nil
 	}
@@ -484,8 +1060,13 @@ type accumulator struct {
 	Total, Notrun, Skip, Pass, Err, Fail, Bogus int
 
 	Suites []suiteInfo
+	suites []*suite.Suite // the executed suites with trimmed response bodies
 }
 
+// maxAccumulatedBody is the number of bytes of each response body kept
+// in the accumulator and shown in the single file report.
+const maxAccumulatedBody = 16 << 10
+
 type suiteInfo struct {
 	Name    string    // the Name field in the raw suite file
 	Dirname string    // directory of result ~ sanitized and uniqified name
@@ -508,6 +1089,9 @@ func (a *accumulator) update(s *suite.Suite) {
 		Dirname: dirname,
 		Status:  s.Status,
 	})
+	if !mute || notifier.URL != "" || ciProvider != "" || historyFile != "" || openapiSpec != "" {
+		a.suites = append(a.suites, s.Trimmed(maxAccumulatedBody))
+	}
 
 	// Status
 	if s.Status > a.Status {
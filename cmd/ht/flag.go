@@ -7,8 +7,16 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
+	"os"
 	"strings"
 	"time"
+
+	"github.com/vdobler/ht/gui"
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/logging"
+	"github.com/vdobler/ht/scope"
+	"github.com/vdobler/ht/suite"
 )
 
 // cmdlVar captures name=value pairs settable on the command line
@@ -31,6 +39,7 @@ func (v *cmdlVar) Set(s string) error {
 var (
 	variablesFlag    = make(cmdlVar) // flag -D
 	variablesFile    string          // -Dfile
+	profileFlag      string          // flag -profile
 	onlyFlag         string          // flag -only
 	skipFlag         string          // flag -skip
 	verbosity        int             // flag -verbosity
@@ -48,11 +57,15 @@ var (
 	port             string          // flag -port
 	timeout          time.Duration   // flag -timeout
 	showBrowser      bool            // flag -show
+	curlFailed       bool            // flag -curl
+	logFormat        string          // flag -log
 )
 
 func addVarsFlags(fs *flag.FlagSet) {
 	addVariablesFlag(fs)
 	addDfileFlag(fs)
+	addProfileFlag(fs)
+	addVaultFlags(fs)
 }
 
 func addTestFlags(fs *flag.FlagSet) {
@@ -65,6 +78,73 @@ func addTestFlags(fs *flag.FlagSet) {
 	addDumpFlag(fs)
 	addCookieFlag(fs)
 	addTimeoutFlag(fs)
+	addRemoteCacheFlag(fs)
+	addSchemaFlag(fs)
+	addCurlFlag(fs)
+	addUpdateSnapshotsFlag(fs)
+}
+
+func addRemoteCacheFlag(fs *flag.FlagSet) {
+	fs.StringVar(&suite.RemoteCacheDir, "remotecache", "",
+		"cache pinned remote suites and tests in `directory`")
+}
+
+func addSchemaFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&suite.ValidateSchema, "schema", false,
+		"validate files against their JSON Schema before loading")
+}
+
+func addCurlFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&curlFailed, "curl", false,
+		"print equivalent curl commands of failed tests")
+}
+
+func addUpdateSnapshotsFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&ht.UpdateSnapshots, "update-snapshots", false,
+		"record the actual responses as new baselines of Snapshot checks")
+}
+
+func addLogFlag(fs *flag.FlagSet) {
+	fs.StringVar(&logFormat, "log", "",
+		"log structured records in `format` text or json")
+}
+
+// checkLogFlag terminates ht if the -log flag has an unknown format and
+// sets up stdoutLogger.
+func checkLogFlag() {
+	if logFormat != "" && logFormat != "text" && logFormat != "json" {
+		fmt.Fprintf(os.Stderr, "Unknown log format %q, must be text or json\n", logFormat)
+		os.Exit(9)
+	}
+	stdoutLogger = structuredLogger(scope.MaskingWriter(os.Stdout))
+	gui.Logger = stdoutLogger
+}
+
+// stdoutLogger is the logger selected with the -log flag for the output of
+// ht to stdout; nil if plain output is requested.
+var stdoutLogger logging.Logger
+
+// printf prints the formatted message to stdout or, with the -log flag,
+// logs it as an Info record with the given fields.
+func printf(fields []logging.Field, format string, a ...interface{}) {
+	if stdoutLogger != nil {
+		msg := strings.TrimSuffix(fmt.Sprintf(format, a...), "\n")
+		stdoutLogger.Log(logging.Info, msg, fields...)
+		return
+	}
+	fmt.Printf(format, a...)
+}
+
+// structuredLogger returns the logger selected with the -log flag writing
+// to w or nil if plain log messages are requested.
+func structuredLogger(w io.Writer) logging.Logger {
+	switch logFormat {
+	case "text":
+		return logging.NewText(w)
+	case "json":
+		return logging.NewJSON(w)
+	}
+	return nil
 }
 
 func addDfileFlag(fs *flag.FlagSet) {
@@ -72,6 +152,18 @@ func addDfileFlag(fs *flag.FlagSet) {
 		"read variables from `file.json`")
 }
 
+func addProfileFlag(fs *flag.FlagSet) {
+	fs.StringVar(&profileFlag, "profile", "",
+		"use variables from the suites' profile `name`")
+}
+
+func addVaultFlags(fs *flag.FlagSet) {
+	fs.StringVar(&scope.VaultKeyFile, "vaultkeyfile", "",
+		"read passphrase of encrypted variable files from `file`")
+	fs.StringVar(&scope.VaultKeyCommand, "vaultkeycmd", "",
+		"obtain passphrase of encrypted variable files from output of `command`")
+}
+
 func addOutputFlag(fs *flag.FlagSet) {
 	fs.StringVar(&outputDir, "output", "",
 		"save results to `dirname` instead of timestamp")
@@ -84,7 +176,7 @@ func addShowFlag(fs *flag.FlagSet) {
 
 func addSeedFlag(fs *flag.FlagSet) {
 	fs.Int64Var(&randomSeed, "seed", 0,
-		"use `num` as seed for PRNG (0 will take seed from time)")
+		"use `num` as seed for PRNG of RANDOM, UUID and load tests (0 will take seed from time)")
 }
 
 func addCounterFlag(fs *flag.FlagSet) {
@@ -0,0 +1,92 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLogJSONOutput(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ok" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "logjson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "s.suite"), []byte(`{
+    Name: "Logged"
+    Main: [
+        {Test: {Name: "Ok", Request: {URL: "`+ts.URL+`/ok"}, Checks: [{Check: "StatusCode", Expect: 200}]}}
+        {Test: {Name: "Bad", Request: {URL: "`+ts.URL+`/bad"}, Checks: [{Check: "StatusCode", Expect: 200}]}}
+    ]
+}`), 0644)
+
+	defer func(lf, od string, s, ss, cf bool) {
+		logFormat, outputDir, silent, ssilent, curlFailed = lf, od, s, ss, cf
+		checkLogFlag()
+	}(logFormat, outputDir, silent, ssilent, curlFailed)
+	defer func(stdout *os.File) { os.Stdout = stdout }(os.Stdout)
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	lines := make(chan []string)
+	go func() {
+		var got []string
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			got = append(got, scanner.Text())
+		}
+		lines <- got
+	}()
+
+	logFormat, outputDir, silent, ssilent, curlFailed = "json", filepath.Join(dir, "out"), false, false, true
+	checkLogFlag()
+	suites, err := loadSuites([]string{filepath.Join(dir, "s.suite")})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	prepareHT()
+	accum, err := executeSuites(suites, variablesFlag, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := reportOverall(accum); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	w.Close()
+	got := <-lines
+
+	msgs := map[string]bool{}
+	for _, line := range got {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Errorf("Unstructured output %q", line)
+			continue
+		}
+		msgs[record["msg"].(string)] = true
+	}
+	for _, msg := range []string{"Starting Suite 1", "Suite finished",
+		"Curl call for test 2", "Finished"} {
+		if !msgs[msg] {
+			t.Errorf("Missing record %q in\n%v", msg, got)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/internal/hjson"
+	"github.com/vdobler/ht/mock"
+	"github.com/vdobler/ht/suite"
+)
+
+var cmdFmt = &Command{
+	RunArgs:     runFmt,
+	Usage:       "fmt [-l] [-w] <file>...",
+	Description: "format suite, test, mixin and mock files canonically",
+	Flag:        flag.NewFlagSet("fmt", flag.ContinueOnError),
+	Help: `Fmt formats HJSON files in a canonical layout and prints the result to
+standard output:
+  - members and elements are indented by four spaces, one per line
+  - no commas between members and elements
+  - objects and arrays of simple values are put on one line if short
+  - the keys of suites (*.suite), tests (*.ht), mixins (*.mix), mocks
+    (*.mock) and load tests (*.load) are ordered like the fields in the
+    documentation (see 'ht doc'), e.g. Name, Description and Request
+    before Checks
+All comments are kept, unknown keys stay after the known ones.
+
+Flag -w writes the result back to the file instead of printing it. Flag
+-l just lists the files whose formatting differs from the canonical one
+and exits with status 1 if there are such files which allows to enforce
+canonical formatting in CI:
+    ht fmt -l tests/*.suite tests/*.ht || exit 1
+`,
+}
+
+var (
+	fmtWrite bool
+	fmtList  bool
+)
+
+func init() {
+	cmdFmt.Flag.BoolVar(&fmtWrite, "w", false,
+		"write result to file instead of standard output")
+	cmdFmt.Flag.BoolVar(&fmtList, "l", false,
+		"list files whose formatting differs")
+}
+
+func runFmt(cmd *Command, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: ht", cmd.Usage)
+		os.Exit(9)
+	}
+
+	differ := false
+	for _, filename := range args {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(9)
+		}
+		formatted, err := formatFile(filename, data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot format %s: %s\n", filename, err)
+			os.Exit(8)
+		}
+		switch {
+		case fmtList:
+			if !bytes.Equal(data, formatted) {
+				fmt.Println(filename)
+				differ = true
+			}
+		case fmtWrite:
+			if bytes.Equal(data, formatted) {
+				continue
+			}
+			err := ioutil.WriteFile(filename, formatted, 0666)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(8)
+			}
+		default:
+			os.Stdout.Write(formatted)
+		}
+	}
+	if differ {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// testFile describes the keys of test and mixin files: A ht.Test with
+// its mixins listed after the description.
+type testFile struct {
+	Name, Description string
+	Mixin             []suite.MixinRef
+	ht.Test
+}
+
+// fileTypes maps the extension of a file to the type its content is
+// populated to.
+var fileTypes = map[string]reflect.Type{
+	".suite": reflect.TypeOf(suite.RawSuite{}),
+	".ht":    reflect.TypeOf(testFile{}),
+	".mix":   reflect.TypeOf(testFile{}),
+	".mock":  reflect.TypeOf(mock.Mock{}),
+	".load":  reflect.TypeOf(suite.RawLoadTest{}),
+}
+
+// formatFile returns data formatted canonically. The keys are ordered
+// according to the type selected by the extension of filename.
+func formatFile(filename string, data []byte) ([]byte, error) {
+	doc, err := hjson.ParseDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	if typ, ok := fileTypes[filepath.Ext(filename)]; ok {
+		reorder(doc.Root, typ)
+	}
+	return doc.Format(), nil
+}
+
+var (
+	fileType      = reflect.TypeOf(&suite.File{})
+	rawElemType   = reflect.TypeOf(suite.RawElement{})
+	checkListType = reflect.TypeOf(ht.CheckList{})
+	extractorType = reflect.TypeOf(ht.ExtractorMap{})
+)
+
+// reorder orders the keys of the objects in n like the fields of typ.
+func reorder(n *hjson.Node, typ reflect.Type) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch {
+	case typ == checkListType:
+		for i := 0; i < n.Len(); i++ {
+			reorderRegistered(n.Index(i), "Check", ht.CheckRegistry)
+		}
+		return
+	case typ == extractorType:
+		if n.Kind != hjson.Object {
+			return
+		}
+		for _, key := range n.Keys() {
+			reorderRegistered(n.Get(key), "Extractor", ht.ExtractorRegistry)
+		}
+		return
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		if n.Kind != hjson.Object {
+			return
+		}
+		fields := map[string]reflect.Type{}
+		keys := structFields(typ, fields)
+		if typ == rawElemType {
+			fields["Test"] = reflect.TypeOf(testFile{})
+		}
+		n.Reorder(keys)
+		for _, key := range n.Keys() {
+			if ft, ok := fields[key]; ok {
+				reorder(n.Get(key), ft)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if n.Kind != hjson.Array {
+			return
+		}
+		for i := 0; i < n.Len(); i++ {
+			reorder(n.Index(i), typ.Elem())
+		}
+	case reflect.Map:
+		if n.Kind != hjson.Object {
+			return
+		}
+		for _, key := range n.Keys() {
+			reorder(n.Get(key), typ.Elem())
+		}
+	}
+}
+
+// reorderRegistered orders the keys of n which selects one of the types
+// in registry by its typeKey.
+func reorderRegistered(n *hjson.Node, typeKey string, registry map[string]reflect.Type) {
+	if n == nil || n.Kind != hjson.Object {
+		return
+	}
+	if t := n.Get(typeKey); t != nil {
+		if typ, ok := registry[fmt.Sprint(t.Interface())]; ok {
+			reorder(n, typ)
+		}
+	}
+	n.Reorder([]string{typeKey})
+}
+
+// structFields returns the names of the exported fields of the struct typ
+// including the promoted fields of embedded structs and records their
+// types in fields. Embedded *suite.File is skipped as it is not part of
+// the file content.
+func structFields(typ reflect.Type, fields map[string]reflect.Type) []string {
+	keys := []string{}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		if f.Anonymous {
+			if f.Type == fileType {
+				continue
+			}
+			et := f.Type
+			if et.Kind() == reflect.Ptr {
+				et = et.Elem()
+			}
+			if et.Kind() == reflect.Struct {
+				keys = append(keys, structFields(et, fields)...)
+				continue
+			}
+		}
+		if f.PkgPath != "" || strings.HasPrefix(f.Tag.Get("json"), "-") {
+			continue
+		}
+		if _, ok := fields[f.Name]; ok {
+			continue
+		}
+		fields[f.Name] = f.Type
+		keys = append(keys, f.Name)
+	}
+	return keys
+}
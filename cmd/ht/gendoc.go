@@ -25,6 +25,8 @@ func allTypes() []string {
 	ftypes := []string{
 		"Test", "Request", "Cookie", "Execution",
 		"CheckList", "ExtractorMap", "Condition",
+		"Hooks", "HookScript", "Transformation", "Signature",
+		"ResourceLoading", "WireLog",
 	}
 	for _, name := range ftypes {
 		t = append(t, "github.com/vdobler/ht/ht."+name)
@@ -40,12 +42,14 @@ func allTypes() []string {
 
 	rtypes := []string{
 		"RawTest", "RawSuite", "RawElement", "RawScenario",
-		"RawLoadTest", "RawMock",
+		"RawLoadTest", "RawMock", "RateStage", "ThinkTime",
+		"AbortCondition",
 	}
 	for _, name := range rtypes {
 		t = append(t, "github.com/vdobler/ht/suite."+name)
 	}
-	for _, name := range []string{"Mock", "Mapping"} {
+	for _, name := range []string{"Mock", "Mapping", "Match", "RequestData", "Callback",
+		"GRPC", "Message", "Field", "WebSocket", "Frame"} {
 		t = append(t, "github.com/vdobler/ht/mock."+name)
 	}
 	t = append(t, "github.com/vdobler/ht/scope.Variables")
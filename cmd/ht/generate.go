@@ -0,0 +1,91 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/vdobler/ht/openapi"
+)
+
+var cmdGenerate = &Command{
+	RunArgs:     runGenerate,
+	Usage:       "generate [-o <dir>] openapi <spec>",
+	Description: "generate skeleton suites from OpenAPI specifications",
+	Flag:        flag.NewFlagSet("generate", flag.ContinueOnError),
+	Help: `Generate openapi creates a suite and one test per operation from an
+OpenAPI 3 or Swagger 2 specification given in JSON or YAML format. The
+files are written to the directory given by -o, tests are grouped into
+subdirectories named after the first tag of the operation.
+
+The requests are filled with example values for all path parameters and
+required query, header and cookie parameters and with an example request
+body. The values are taken from the examples and defaults in the
+specification or generated from the schemas. The tests check the status
+code and the content type of the documented success response and the
+structure of JSON responses. The base URL of the API is taken from the
+specification and stored in the suite variable BASE_URL and path
+parameters are variables too. This allows to run the generated suite
+against different systems, e.g.:
+    ht exec -D BASE_URL=https://staging.example.org/v1 Pet_Store.suite
+
+The generated suite is a starting point: Adjust the example values and
+add checks for the actual content of the responses.
+`,
+}
+
+var generateOutput string
+
+func init() {
+	cmdGenerate.Flag.StringVar(&generateOutput, "o", ".",
+		"write generated files to `directory`")
+}
+
+func runGenerate(cmd *Command, args []string) {
+	if len(args) != 2 || args[0] != "openapi" {
+		fmt.Fprintln(os.Stderr, "Usage: ht", cmd.Usage)
+		os.Exit(9)
+	}
+	filename := args[1]
+
+	file, err := os.Open(filename)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(9)
+	}
+	spec, err := openapi.Read(file)
+	file.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot read %s: %s\n", filename, err)
+		os.Exit(9)
+	}
+
+	files, warnings, err := openapi.Generate(spec)
+	for _, w := range warnings {
+		fmt.Fprintln(os.Stderr, "Warning:", w)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(8)
+	}
+	for _, f := range files {
+		filename := filepath.Join(generateOutput, filepath.FromSlash(f.Name))
+		err := os.MkdirAll(filepath.Dir(filename), 0777)
+		if err == nil {
+			err = ioutil.WriteFile(filename, f.Data, 0666)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(8)
+		}
+	}
+	fmt.Printf("Wrote suite %s and %d tests to %s\n",
+		files[0].Name, len(files)-1, generateOutput)
+	os.Exit(0)
+}
@@ -72,10 +72,17 @@ func init() {
 func dumpCommonTypes(buf *bytes.Buffer) {
 	dumpData(buf, reflect.TypeOf(ht.Test{}))
 	dumpData(buf, reflect.TypeOf(ht.Request{}))
+	dumpData(buf, reflect.TypeOf(ht.Signature{}))
 	dumpData(buf, reflect.TypeOf(ht.Response{}))
 	dumpData(buf, reflect.TypeOf(ht.CheckList{}))
 	dumpData(buf, reflect.TypeOf(ht.ExtractorMap{}))
 	dumpData(buf, reflect.TypeOf(ht.Extraction{}))
+	dumpData(buf, reflect.TypeOf(ht.Hooks{}))
+	dumpData(buf, reflect.TypeOf(ht.HookScript{}))
+	dumpData(buf, reflect.TypeOf(ht.Transformation{}))
+	dumpData(buf, reflect.TypeOf(ht.ResourceLoading{}))
+	dumpData(buf, reflect.TypeOf(ht.WireLog{}))
+	dumpData(buf, reflect.TypeOf(ht.Resource{}))
 	dumpData(buf, reflect.TypeOf(ht.Execution{}))
 	dumpData(buf, reflect.TypeOf(ht.Cookie{}))
 	dumpData(buf, reflect.TypeOf(ht.Condition{}))
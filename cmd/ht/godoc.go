@@ -7,10 +7,16 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"reflect"
+	"sort"
 	"strings"
+
+	"github.com/vdobler/ht/gui"
+	"github.com/vdobler/ht/ht"
 )
 
 var cmdDoc = &Command{
@@ -23,9 +29,25 @@ var cmdDoc = &Command{
 Only the most relevant type documentation is available. The doc
 subcommand outputs the list of checks and extractors if called with
 argument 'checks' or 'extractors'.
+
+With flag -json the checks or extractors are printed as a JSON array
+with the documentation, fields, field types and field documentation of
+each check or extractor. This allows editors and scripts to introspect
+the available checks:
+    ht doc -json checks | jq '.[] | select(.Name == "Body") | .Fields'
+
+Checks and extractors loaded from Go plugins (see 'ht help plugins')
+are listed and documented like the builtin ones.
 `,
 }
 
+var docJSON bool
+
+func init() {
+	cmdDoc.Flag.BoolVar(&docJSON, "json", false,
+		"print checks or extractors with their fields as JSON")
+}
+
 func runDoc(cmd *Command, args []string) {
 	if len(args) != 1 {
 		fmt.Fprintln(os.Stderr, "Wrong number of arguments.")
@@ -38,10 +60,27 @@ func runDoc(cmd *Command, args []string) {
 	// Special case of list of checks/extractors.
 	if typ == "check" || typ == "checks" ||
 		typ == "extractor" || typ == "extractors" {
-		displayChecksOrExtractors(typ)
+		if !docJSON {
+			displayChecksOrExtractors(typ)
+			os.Exit(0)
+		}
+		registry := ht.CheckRegistry
+		if typ[0] == 'e' {
+			registry = ht.ExtractorRegistry
+		}
+		data, err := json.MarshalIndent(registryDoc(registry), "", "    ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(8)
+		}
+		fmt.Println(string(data))
+		os.Exit(0)
 	}
 
 	doc, ok := typeDoc[typ]
+	if !ok {
+		doc, ok = registeredTypeDoc(typ)
+	}
 	if !ok {
 		fmt.Fprintf(os.Stderr, "No information available for type %q\n", typ)
 		fmt.Fprintf(os.Stderr, "For serialization types try 'raw%s'.\n", typ)
@@ -52,3 +91,65 @@ func runDoc(cmd *Command, args []string) {
 
 	os.Exit(0)
 }
+
+// typeDocumentation is the machine-readable documentation of a check or
+// an extractor.
+type typeDocumentation struct {
+	Name   string
+	Doc    string
+	Fields []fieldDocumentation
+}
+
+// fieldDocumentation documents one field of a check or extractor.
+type fieldDocumentation struct {
+	Name string
+	Type string
+	Doc  string
+}
+
+// registryDoc returns the documentation of all types in registry sorted
+// by name. The documentation is taken from gui.Typedata.
+func registryDoc(registry map[string]reflect.Type) []typeDocumentation {
+	docs := []typeDocumentation{}
+	for name, typ := range registry {
+		for typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		docs = append(docs, typeDocumentation{
+			Name:   name,
+			Doc:    gui.Typedata[typ].Doc,
+			Fields: fieldDoc(typ, map[string]bool{}),
+		})
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Name < docs[j].Name })
+	return docs
+}
+
+// fieldDoc returns the documentation of the exported fields of the struct
+// typ including the promoted fields of embedded structs. Fields already
+// in seen are skipped.
+func fieldDoc(typ reflect.Type, seen map[string]bool) []fieldDocumentation {
+	fields := []fieldDocumentation{}
+	if typ.Kind() != reflect.Struct {
+		return fields
+	}
+	info := gui.Typedata[typ]
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			fields = append(fields, fieldDoc(f.Type, seen)...)
+			continue
+		}
+		if f.PkgPath != "" || strings.HasPrefix(f.Tag.Get("json"), "-") ||
+			seen[f.Name] || info.Field[f.Name].Omit {
+			continue
+		}
+		seen[f.Name] = true
+		fields = append(fields, fieldDocumentation{
+			Name: f.Name,
+			Type: f.Type.String(),
+			Doc:  info.Field[f.Name].Doc,
+		})
+	}
+	return fields
+}
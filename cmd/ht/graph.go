@@ -0,0 +1,82 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/vdobler/ht/suite"
+)
+
+var cmdGraph = &Command{
+	RunArgs:     runGraph,
+	Usage:       "graph [-o <file>] <suite>",
+	Description: "draw the structure of a suite as a graph",
+	Flag:        flag.NewFlagSet("graph", flag.ContinueOnError),
+	Help: `Graph draws the structure of a suite to help navigating large suites:
+  - the setup, main and teardown tests in execution order
+  - the mixins used by the tests (dashed lines)
+  - the mocks attached to the tests (dotted lines)
+  - the flow of variables (blue arrows) from the test extracting a
+    variable to the tests using it
+The graph is printed in the DOT language of Graphviz to standard output
+or written to the file given by -o. If the file ends in .svg, .png or
+.pdf the graph is rendered with the dot program of Graphviz which must
+be installed:
+    ht graph -o graph.svg tests/shop.suite
+`,
+}
+
+var graphOutput string
+
+func init() {
+	cmdGraph.Flag.StringVar(&graphOutput, "o", "",
+		"write graph to `file` (.dot, .svg, .png or .pdf)")
+}
+
+func runGraph(cmd *Command, args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: ht", cmd.Usage)
+		os.Exit(9)
+	}
+	fs, arg := filesystemFor(args[0])
+	rs, err := suite.LoadRawSuite(arg, fs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot read suite %q: %s\n", arg, err)
+		os.Exit(8)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := rs.Graph(buf); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(8)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(graphOutput)); {
+	case graphOutput == "":
+		_, err = os.Stdout.Write(buf.Bytes())
+	case ext == ".svg" || ext == ".png" || ext == ".pdf":
+		dot := exec.Command("dot", "-T"+ext[1:], "-o", graphOutput)
+		dot.Stdin = buf
+		dot.Stderr = os.Stderr
+		if err = dot.Run(); err != nil {
+			err = fmt.Errorf("cannot render graph with Graphviz dot (write a .dot file instead): %s", err)
+		}
+	default:
+		err = ioutil.WriteFile(graphOutput, buf.Bytes(), 0666)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(8)
+	}
+	os.Exit(0)
+}
@@ -9,15 +9,17 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"html"
 	"log"
 	"net/http"
-	"net/url"
 	"os"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/vdobler/ht/errorlist"
@@ -48,6 +50,35 @@ Please note that the exported tests are not suitable for direct execution:
 All durations are in nanoseconds you have to change these manually,
 variables have been replaced unconditionally during loading of the test
 and have to be reintroduced.
+
+The suite browser on /suites lists all suites found below the directory
+given by -dir. Suites can be executed from there; they run in the
+background and the results of the latest run can be inspected test by
+test.
+
+Tests can be written back to disk with "Save as .ht" which keeps the
+Mixin and Variables blocks of the loaded file and produces a test
+suitable for execution. Comments and the formatting of unchanged parts of
+the file are kept; values of secret variables are never written. "Load from file" replaces the test in the GUI
+with the one read from the given file.
+
+Checks can be developed without executing the test's request: "Paste or
+Fetch Response" on /trychecks takes a pasted response (e.g. the output of
+curl -i or just a body) or fetches one from an URL and tries the Checks on
+it; -response loads such a response from a file on startup. After working
+on the Checks with "Try Checks" they can be exported as Hjson with
+"Export Checks" or written into the test file with "Save Checks to .ht"
+which keeps everything else in the file.
+
+"Execute Live" runs the test in the background and streams its log and the
+check results to the browser while it runs. Live execution works on the
+single test shown in the GUI only; suites cannot be executed live, use the
+suite browser to run them. The test cannot be edited until the live
+execution has finished.
+
+The look of the GUI can be switched between the themes light, dark,
+high-contrast and print with the links at the top of each page; the
+selection is remembered in a cookie.
 	`,
 }
 
@@ -59,11 +90,16 @@ func init() {
 	addCounterFlag(cmdGUI.Flag)
 	addSkiptlsverifyFlag(cmdGUI.Flag)
 	addPhantomJSFlag(cmdGUI.Flag)
-
-	registerGUIImplements()
+	addLogFlag(cmdGUI.Flag)
+	cmdGUI.Flag.StringVar(&guiSuiteDir, "dir", ".",
+		"browse and run the suites found below `directory`")
+	cmdGUI.Flag.StringVar(&guiResponseFile, "response", "",
+		"try the checks on the response read from `file`")
 }
 
 func runGUI(cmd *Command, tests []*suite.RawTest) {
+	// Registered here and not in init to include checks from plugins.
+	registerGUIImplements()
 	registerGUITypes()
 
 	test := &ht.Test{Name: "New Test"}
@@ -75,40 +111,90 @@ func runGUI(cmd *Command, tests []*suite.RawTest) {
 
 	prepareHT()
 
-	jar := loadCookies()
+	source := &guiSource{jar: loadCookies()}
 
 	if len(tests) == 1 {
 		rt := tests[0]
-		testScope := scope.New(scope.Variables(variablesFlag), rt.Variables, false)
-		testScope["TEST_DIR"] = rt.File.Dirname()
-		testScope["TEST_NAME"] = rt.File.Basename()
 		var err error
-		test, err = rt.ToTest(testScope)
+		test, err = source.toTest(rt)
 		if err != nil {
 			log.Println(err)
 			os.Exit(9)
 		}
-		test.SetMetadata("Filename", rt.File.Name)
-		if u, err := url.Parse(test.Request.URL); err != nil {
-			test.PopulateCookies(jar, u)
+		source.raw, source.name = rt, rt.File.Name
+	}
+
+	if guiResponseFile != "" {
+		resp, err := readResponseFile(guiResponseFile)
+		if err != nil {
+			log.Println(err)
+			os.Exit(9)
 		}
+		test.Response = resp
 	}
 
 	testValue := gui.NewValue(*test, "Test")
+	if guiResponseFile != "" {
+		executeChecks(testValue)
+	}
+	live := newLiveExecution()
 
 	http.HandleFunc("/favicon.ico", faviconHandler)
-	http.HandleFunc("/update", updateHandler(testValue))
-	http.HandleFunc("/export", exportHandler(testValue))
-	http.HandleFunc("/binary", binaryHandler(testValue))
-	http.HandleFunc("/", displayHandler(testValue))
+	http.HandleFunc("/update", locked(updateHandler(testValue, live, source)))
+	http.HandleFunc("/events", eventsHandler(live))
+	http.HandleFunc("/export", locked(exportHandler(testValue)))
+	http.HandleFunc("/export/checks", locked(checksExportHandler(testValue)))
+	http.HandleFunc("/trychecks", locked(tryChecksHandler(testValue)))
+	http.HandleFunc("/binary", locked(binaryHandler(testValue)))
+	http.HandleFunc("/response", locked(responseHandler(testValue)))
+	http.HandleFunc("/response/body", locked(responseBodyHandler(testValue)))
+	http.HandleFunc("/diff", locked(diffHandler(testValue, source)))
+	browser := gui.NewSuiteBrowser(guiSuiteDir)
+	browser.Variables = variablesFlag
+	browser.Jar = source.jar
+	browser.Logger = stdoutLogger
+	browser.Edit = editSuiteTest(testValue, live)
+	browser.Register()
+	http.HandleFunc("/", locked(displayHandler(testValue, source)))
 	guiURL := fmt.Sprintf("http://localhost%s/", port)
-	fmt.Println("GUI accessible on", guiURL)
+	printf(nil, "GUI accessible on %s\n", guiURL)
 	startBrowser(guiURL)
 	log.Fatal(http.ListenAndServe(port, nil))
 
 	os.Exit(0)
 }
 
+var guiSuiteDir string // flag -dir of gui
+
+// guiMu protects the gui.Value of the test shown in the GUI which is read
+// and modified by the handlers and by a live execution.
+var guiMu sync.Mutex
+
+// locked wraps handler so that it runs with guiMu held.
+func locked(handler func(w http.ResponseWriter, req *http.Request)) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		guiMu.Lock()
+		defer guiMu.Unlock()
+		handler(w, req)
+	}
+}
+
+// editSuiteTest returns the function used by the suite browser to replace
+// the current test in val with a test from a suite run.
+func editSuiteTest(val *gui.Value, live *liveExecution) func(test *ht.Test) error {
+	return func(test *ht.Test) error {
+		guiMu.Lock()
+		defer guiMu.Unlock()
+		if live.isRunning() {
+			return errors.New("Test is still running.")
+		}
+		val.PushCurrent()
+		val.Current = *test
+		augmentMessages(test, val)
+		return nil
+	}
+}
+
 // ----------------------------------------------------------------------------
 // Export
 
@@ -181,12 +267,13 @@ func exportHandler(val *gui.Value) func(w http.ResponseWriter, req *http.Request
 
 		// Output all three variants.
 		w.WriteHeader(200)
-		w.Write([]byte("Raw Data\n========\n\n"))
-		w.Write(rawdata)
-		w.Write([]byte("\n\n\nSensible Durations\n==================\n\n"))
-		w.Write(durdata)
-		w.Write([]byte("\n\n\nWith Variables\n==============\n\n"))
-		w.Write(vardata)
+		mw := scope.MaskingWriter(w)
+		mw.Write([]byte("Raw Data\n========\n\n"))
+		mw.Write(rawdata)
+		mw.Write([]byte("\n\n\nSensible Durations\n==================\n\n"))
+		mw.Write(durdata)
+		mw.Write([]byte("\n\n\nWith Variables\n==============\n\n"))
+		mw.Write(vardata)
 	}
 }
 
@@ -310,27 +397,51 @@ func binaryHandler(val *gui.Value) func(w http.ResponseWriter, req *http.Request
 	}
 }
 
-func updateHandler(val *gui.Value) func(w http.ResponseWriter, req *http.Request) {
+func updateHandler(val *gui.Value, live *liveExecution, source *guiSource) func(w http.ResponseWriter, req *http.Request) {
 	return func(w http.ResponseWriter, req *http.Request) {
-		req.ParseForm()
+		if err := gui.ParseUploadForm(req); err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte("Bad form: " + err.Error()))
+			return
+		}
+		if live.isRunning() {
+			// The running test shares its Checks and Request with
+			// val.Current, so val must not be modified now.
+			w.WriteHeader(409)
+			w.Write([]byte("Test is still running."))
+			return
+		}
 		fragment, _ := val.Update(req.Form)
 
 		switch req.Form.Get("action") {
 		case "save":
 			val.PushCurrent()
 		case "undo":
-			n := len(val.Last)
-			if n == 0 {
+			if !val.Undo() {
 				w.WriteHeader(400)
 				w.Write([]byte("No more undoable states."))
 				return
 			}
-			val.Current, val.Last = val.Last[n-1], val.Last[:n-1]
+		case "redo":
+			if !val.Redo() {
+				w.WriteHeader(400)
+				w.Write([]byte("No more redoable states."))
+				return
+			}
 		case "execute":
 			val.PushCurrent()
 			executeTest(val)
 			extractVars(val)
 			fragment = "Test.Response"
+		case "live":
+			// Started from JavaScript which reads the progress from /events.
+			if err := live.start(val); err != nil {
+				w.WriteHeader(409)
+				w.Write([]byte(err.Error()))
+				return
+			}
+			w.WriteHeader(202)
+			return
 		case "runchecks":
 			if val.Current.(ht.Test).Response.Response == nil {
 				w.WriteHeader(400)
@@ -347,6 +458,15 @@ func updateHandler(val *gui.Value) func(w http.ResponseWriter, req *http.Request
 			}
 			extractVars(val)
 			fragment = "Test.ExValues"
+		case "savefile":
+			saveTest(val, source, strings.TrimSpace(req.Form.Get("filename")))
+			fragment = "Test"
+		case "loadfile":
+			loadTest(val, source, strings.TrimSpace(req.Form.Get("filename")))
+			fragment = "Test"
+		case "savechecks":
+			saveChecks(val, source, strings.TrimSpace(req.Form.Get("filename")))
+			fragment = "Test.Checks"
 		case "export":
 			val.PushCurrent()
 			w.Header().Set("Location", "/export")
@@ -401,17 +521,17 @@ func executeTest(val *gui.Value) {
 // ----------------------------------------------------------------------------
 // Display
 
-func displayHandler(val *gui.Value) func(w http.ResponseWriter, req *http.Request) {
+func displayHandler(val *gui.Value, source *guiSource) func(w http.ResponseWriter, req *http.Request) {
 	return func(w http.ResponseWriter, req *http.Request) {
 		buf := &bytes.Buffer{}
-		writePreamble(buf, "Test Builder")
+		writePreamble(buf, "Test Builder", gui.SelectTheme(w, req))
 
 		data, err := val.Render()
 		buf.Write(data)
-		writeEpilogue(buf, val)
+		writeEpilogue(buf, val, source)
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		w.WriteHeader(200)
-		w.Write(buf.Bytes())
+		w.Write([]byte(scope.Mask(buf.String())))
 
 		if err != nil {
 			log.Fatal(err)
@@ -460,6 +580,9 @@ func augmentPrepareMessages(err error, val *gui.Value) {
 	}
 
 	for _, err := range el {
+		if se, ok := err.(errorlist.Error); ok {
+			err = se.Err
+		}
 		if pe, ok := err.(ht.ErrCheckPrepare); ok {
 			path := fmt.Sprintf("Test.Checks.%d", pe.Nr)
 			val.Messages[path] = []gui.Message{{
@@ -470,7 +593,20 @@ func augmentPrepareMessages(err error, val *gui.Value) {
 	}
 }
 
-func writePreamble(buf *bytes.Buffer, title string) {
+// themeLinks returns links to switch to the registered themes.
+func themeLinks(current string) string {
+	links := ""
+	for _, name := range gui.Themes() {
+		if name == current {
+			links += " | " + name
+		} else {
+			links += ` | <a href="?theme=` + name + `">` + name + `</a>`
+		}
+	}
+	return links
+}
+
+func writePreamble(buf *bytes.Buffer, title string, theme string) {
 	buf.WriteString(`<!doctype html>
 <html>
 <head>
@@ -478,7 +614,7 @@ func writePreamble(buf *bytes.Buffer, title string) {
     <title>Test Builder</title>
     <style>
  `)
-	buf.WriteString(gui.CSS)
+	buf.WriteString(gui.ThemeCSS(theme))
 	buf.WriteString(`
 .valueform {
   margin-right: 240px;
@@ -488,23 +624,42 @@ h1 {
   margin-top: 0px;
 }
     </style>
+    <script>
+`)
+	buf.WriteString(gui.JS)
+	buf.WriteString(liveJS)
+	buf.WriteString(`
+    </script>
 </head>
 <body>
+  <p><a href="/suites">Suites</a>` + themeLinks(theme) + `</p>
   <h1>` + title + `</h1>
   <div class="valueform">
-  <form action="/update" method="post">
+  <form action="/update" method="post" enctype="multipart/form-data">
 `)
 }
 
-func writeEpilogue(buf *bytes.Buffer, val *gui.Value) {
+func writeEpilogue(buf *bytes.Buffer, val *gui.Value, source *guiSource) {
 	buf.WriteString(`
     <div style="position: fixed; top:2%; right:2%;">
       </p>
         <button class="actionbutton" name="action" value="execute" style="background-color: #DDA0DD;" title="Execute the HTTP request, capture the response and execute the Checks."> Execute Test </button>
       </p>
+      <p>
+        <button class="actionbutton" type="button" onclick="guiExecuteLive(this)" style="background-color: #BA55D3;" title="Execute the Test in the background and watch progress and check results live."> Execute Live </button>
+      </p>
+      <p>
+        <a class="actionbutton" href="/response" target="_blank" style="background-color: #B0C4DE;" title="View headers and pretty printed body of the Response."> View Response </a>
+      </p>
+      <p>
+        <a class="actionbutton" href="/trychecks" style="background-color: #FFA07A;" title="Paste or fetch a response to try the Checks on."> Paste or Fetch Response </a>
+      </p>
       <p>
         <button class="actionbutton" name="action" value="runchecks" style="background-color: #FF8C00;" title="Execute the Checks. Requires a valid response."> Try Checks </button>
       </p>
+      <p>
+        <a class="actionbutton" href="/export/checks" target="_blank" style="background-color: #FFE4B5;" title="Export the Checks of the current Test as Hjson."> Export Checks </a>
+      </p>
       <p>
         <button class="actionbutton" name="action" value="extractvars" style="background-color: #87CEEB;" title="Extract variables from Response. Requires a valid response."> Extract Vars </button>
       </p>
@@ -514,18 +669,37 @@ func writeEpilogue(buf *bytes.Buffer, val *gui.Value) {
       <p>
         <button class="actionbutton" name="action" value="export" style="background-color: #FFE4B5;" title="Export current Test as Hjson."> Export Test </button>
       </p>
+      <p>
+        <a class="actionbutton" href="/diff" target="_blank" style="background-color: #D3D3D3;" title="Show changes relative to the file the Test was loaded from."> Show Changes </a>
+      </p>
+      <p>
+        <input type="text" name="filename" value="` + html.EscapeString(source.filename()) + `" style="width: 230px;" title="File on the server to save the Test to or load it from."><br/>
+        <button name="action" value="savefile" title="Save current Test as Hjson to the file."> Save as .ht </button>
+        <button name="action" value="loadfile" title="Load the Test from the file."> Load from file </button>
+        <button name="action" value="savechecks" title="Write the Checks into the file keeping the rest of the file."> Save Checks to .ht </button>
+      </p>
 `)
 
 	if len(val.Last) > 0 {
 		buf.WriteString(`
       <p>
-        <button class="actionbutton" name="action" value="undo" style="background-color: #E6E600;" title="Go back to last saved/executed test."> Undo </button>
+        <button class="actionbutton" name="action" value="undo" style="background-color: #E6E600;" title="Go back to the state before the last change."> Undo </button>
+      </p>
+`)
+
+	}
+
+	if len(val.Next) > 0 {
+		buf.WriteString(`
+      <p>
+        <button class="actionbutton" name="action" value="redo" style="background-color: #E6E600;" title="Redo the last undone change."> Redo </button>
       </p>
 `)
 
 	}
 
 	buf.WriteString(`
+      <pre id="liveprogress" style="display: none; width: 240px; max-height: 300px; overflow: auto; white-space: pre-wrap; font-size: 11px; background-color: #F4F4F4;"></pre>
     </div>
 
     <div style="height: 600px"> &nbsp; </div>
@@ -6,10 +6,20 @@ package main
 
 import (
 	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/vdobler/ht/gui"
+	"github.com/vdobler/ht/ht"
 	"github.com/vdobler/ht/internal/hjson"
+	"github.com/vdobler/ht/scope"
 )
 
 func TestFixDuration(t *testing.T) {
@@ -91,3 +101,454 @@ func TestInvertVars(t *testing.T) {
 		t.Error("got", got)
 	}
 }
+
+func TestLiveExecution(t *testing.T) {
+	release := make(chan bool)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte("Hello World"))
+	}))
+	defer ts.Close()
+
+	test := ht.Test{
+		Name:    "Live",
+		Request: ht.Request{URL: ts.URL},
+		Checks: ht.CheckList{
+			ht.StatusCode{Expect: 200},
+			&ht.Body{Contains: "Goodbye"},
+		},
+	}
+	val := gui.NewValue(test, "Test")
+	live := newLiveExecution()
+	if err := live.start(val); err != nil {
+		t.Fatal(err)
+	}
+
+	// The test must not be modified while it is running.
+	update := locked(updateHandler(val, live, &guiSource{}))
+	req := httptest.NewRequest("POST", "/update", strings.NewReader("action=save"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	update(rec, req)
+	if rec.Code != 409 {
+		t.Errorf("Got %d for update during execution", rec.Code)
+	}
+	close(release)
+
+	// The event stream ends once the execution is done.
+	rec = httptest.NewRecorder()
+	eventsHandler(live)(rec, httptest.NewRequest("GET", "/events", nil))
+	stream := rec.Body.String()
+	for _, want := range []string{
+		"event: log\ndata: DEBUG Check 2 Body Fail: Cannot find",
+		`event: check` + "\n" + `data: {"Path":"Test.Checks.0","Status":"pass","Text":"Pass"}`,
+		`"Path":"Test.Checks.1","Status":"fail"`,
+		`event: status` + "\n" + `data: {"Path":"Test","Status":"fail"`,
+		"event: done\n",
+	} {
+		if !strings.Contains(stream, want) {
+			t.Errorf("Missing %q in stream:\n%s", want, stream)
+		}
+	}
+
+	if got := val.Current.(ht.Test).Result.Status; got != ht.Fail {
+		t.Errorf("Got status %s", got)
+	}
+	if msgs := val.Messages["Test.Checks.1"]; len(msgs) != 1 || msgs[0].Type != "fail" {
+		t.Errorf("Got messages %v", msgs)
+	}
+	if len(val.Last) != 1 {
+		t.Errorf("Executed test not pushed to history")
+	}
+}
+
+func TestHighlightJSON(t *testing.T) {
+	got := string(highlightJSON(`{"a":[1,"x<y",true],"b":null}`))
+	want := `{
+    <span class="hl-key">&#34;a&#34;</span>: [
+        <span class="hl-literal">1</span>,
+        <span class="hl-string">&#34;x&lt;y&#34;</span>,
+        <span class="hl-literal">true</span>
+    ],
+    <span class="hl-key">&#34;b&#34;</span>: <span class="hl-literal">null</span>
+}`
+	if got != want {
+		t.Errorf("Got\n%s\nwant\n%s", got, want)
+	}
+
+	if got := string(highlightJSON(`{"broken`)); got != "{&#34;broken" {
+		t.Errorf("Got %q", got)
+	}
+}
+
+func TestHighlightMarkup(t *testing.T) {
+	got := string(highlightMarkup(`<html><body class="x"><p>Hi<br>there</p><!-- c --></body></html>`, true))
+	want := `<span class="hl-tag">&lt;html</span><span class="hl-tag">&gt;</span>
+    <span class="hl-tag">&lt;body</span> <span class="hl-attr">class</span>=<span class="hl-string">&#34;x&#34;</span><span class="hl-tag">&gt;</span>
+        <span class="hl-tag">&lt;p</span><span class="hl-tag">&gt;</span>
+            Hi
+            <span class="hl-tag">&lt;br</span><span class="hl-tag">&gt;</span>
+            there
+        <span class="hl-tag">&lt;/p&gt;</span>
+        <span class="hl-comment">&lt;!-- c --&gt;</span>
+    <span class="hl-tag">&lt;/body&gt;</span>
+<span class="hl-tag">&lt;/html&gt;</span>`
+	if got != want {
+		t.Errorf("Got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestResponseHandler(t *testing.T) {
+	test := ht.Test{
+		Response: ht.Response{
+			Response: &http.Response{
+				Proto:      "HTTP/1.1",
+				Status:     "200 OK",
+				StatusCode: 200,
+				Header: http.Header{
+					"Content-Type": {"application/json"},
+					"X-Foo":        {"bar"},
+				},
+			},
+			BodyStr: `{"Hello":"World"}`,
+		},
+	}
+	val := gui.NewValue(test, "Test")
+	handler := responseHandler(val)
+
+	for _, tc := range []struct {
+		view string
+		want []string
+	}{
+		{"", []string{"<th>X-Foo</th><td>bar</td>",
+			`<span class="hl-key">&#34;Hello&#34;</span>`}},
+		{"raw", []string{`<pre class="body">{&#34;Hello&#34;:&#34;World&#34;}</pre>`}},
+		{"hex", []string{"00000000  7b 22 48 65 6c 6c 6f 22"}},
+	} {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/response?view="+tc.view, nil))
+		for _, want := range tc.want {
+			if body := rec.Body.String(); !strings.Contains(body, want) {
+				t.Errorf("view=%q: missing %q in\n%s", tc.view, want, body)
+			}
+		}
+	}
+}
+
+func TestResponseSecretsMasked(t *testing.T) {
+	scope.MarkSecret(`gui"s3cr<t`)
+	test := ht.Test{
+		Response: ht.Response{
+			Response: &http.Response{
+				StatusCode: 200,
+				Header: http.Header{
+					"Content-Type": {"text/html"},
+					"X-Token":      {`gui"s3cr<t`},
+				},
+			},
+			BodyStr: `<p>gui"s3cr<t</p>`,
+		},
+	}
+	val := gui.NewValue(test, "Test")
+
+	rec := httptest.NewRecorder()
+	responseHandler(val)(rec, httptest.NewRequest("GET", "/response?view=raw", nil))
+	if body := rec.Body.String(); strings.Contains(body, "s3cr") {
+		t.Errorf("Secret not masked in\n%s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	responseBodyHandler(val)(rec, httptest.NewRequest("GET", "/response/body", nil))
+	if body := rec.Body.String(); body != "<p>***</p>" {
+		t.Errorf("Got body %q", body)
+	}
+	if csp := rec.Header().Get("Content-Security-Policy"); csp != "sandbox" {
+		t.Errorf("Got Content-Security-Policy %q", csp)
+	}
+}
+
+func TestGUISaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gui-save")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(filepath.Join(dir, "common.mix"), []byte(`{
+    Request: { Header: { Accept: "text/html" } }
+    Checks: [ {Check: "StatusCode", Expect: 200} ]
+}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "orig.ht"), []byte(`{
+    # The original test.
+    Name: "Original"
+    Mixin: [ "common.mix" ]
+    Variables: {
+        HOST: "www.example.org"
+        PASS: "@secret:env:GUI_SAVE_PASS"
+    }
+    Request: {
+        URL: "http://{{HOST}}/path"
+        Header: { X-Pass: "{{PASS}}", X-Tag: "{{TAG}}" }
+    }
+    Checks: [ {Check: "Body", Contains: "Hello"} ]  # keep
+}`), 0644)
+	os.Setenv("GUI_SAVE_PASS", "gui-s3cret-pw")
+	defer os.Unsetenv("GUI_SAVE_PASS")
+
+	// Command line variables would override the ones from the file.
+	defer func(vars cmdlVar) { variablesFlag = vars }(variablesFlag)
+	variablesFlag = cmdlVar{"TAG": "release-7", "UNUSED": "foo"}
+
+	src := &guiSource{}
+	test, err := src.load(filepath.Join(dir, "orig.ht"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(test.Checks) != 2 || test.Request.Header.Get("Accept") != "text/html" {
+		t.Fatalf("Bad loaded test %+v", test)
+	}
+
+	test.Name = "Edited"
+	saved := filepath.Join(dir, "saved.ht")
+	if err := src.save(*test, saved); err != nil {
+		t.Fatal(err)
+	}
+	if src.filename() != saved {
+		t.Errorf("Got filename %q", src.filename())
+	}
+	data, _ := ioutil.ReadFile(saved)
+	got := string(data)
+	for _, want := range []string{
+		"# The original test.",
+		"Name: Edited",
+		`Mixin: [ "common.mix" ]`,
+		`HOST: "www.example.org"`,
+		`PASS: "@secret:env:GUI_SAVE_PASS"`,
+		"TAG: release-7",
+		`URL: "http://{{HOST}}/path"`,
+		`Contains: "Hello"} ]  # keep`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Missing %q in\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"StatusCode", "Accept", "Result", "Response",
+		"gui-s3cret-pw", "UNUSED", "TEST_DIR"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("Unexpected %q in\n%s", unwanted, got)
+		}
+	}
+
+	// The saved test must load to the same test.
+	reloaded, err := (&guiSource{}).load(saved)
+	if err != nil {
+		t.Fatalf("Cannot load saved test: %s\n%s", err, got)
+	}
+	if len(reloaded.Checks) != 2 || reloaded.Request.URL != "http://www.example.org/path" ||
+		reloaded.Request.Header.Get("Accept") != "text/html" ||
+		reloaded.Request.Header.Get("X-Pass") != "gui-s3cret-pw" {
+		t.Errorf("Bad reloaded test %+v", reloaded)
+	}
+
+	// A new test keeps its Variables but never writes secrets.
+	fresh := ht.Test{
+		Name:      "Fresh",
+		Request:   ht.Request{URL: "http://localhost/gui-s3cret-pw"},
+		Variables: map[string]string{"FOO": "foo-bar", "PASS": "gui-s3cret-pw"},
+	}
+	data, err = (&guiSource{}).testFileData(fresh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = string(data)
+	if !strings.Contains(got, `FOO: "foo-bar"`) || !strings.Contains(got, "{{PASS}}") ||
+		strings.Contains(got, "gui-s3cret-pw") {
+		t.Errorf("Bad new test\n%s", got)
+	}
+}
+
+func TestGUIDiff(t *testing.T) {
+	defer func(vars cmdlVar) { variablesFlag = vars }(variablesFlag)
+	variablesFlag = cmdlVar{}
+
+	dir, err := ioutil.TempDir("", "gui-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	orig := filepath.Join(dir, "orig.ht")
+	ioutil.WriteFile(orig, []byte(`{
+    Name: "Original"
+    Request: { URL: "http://localhost/path" }
+    Checks: [ {Check: "StatusCode", Expect: 200} ]
+}`), 0644)
+
+	src := &guiSource{}
+	test, err := src.load(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Request.URL = "http://localhost/other"
+	test.Checks = append(test.Checks, ht.Body{Contains: "Hello"})
+	val := gui.NewValue(*test, "Test")
+
+	rec := httptest.NewRecorder()
+	diffHandler(val, src)(rec, httptest.NewRequest("GET", "/diff", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`<a href="/#Test.Request.URL">Test.Request.URL</a>`,
+		`&#34;http://localhost/path&#34;`,
+		`&#34;http://localhost/other&#34;`,
+		`<tr class="added"><th><a href="/#Test.Checks.1">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Missing %q in\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "Test.Name") || strings.Contains(body, "Test.Checks.0") {
+		t.Errorf("Unchanged fields reported:\n%s", body)
+	}
+}
+
+func TestEditSuiteTest(t *testing.T) {
+	val := gui.NewValue(ht.Test{Name: "Old"}, "Test")
+	live := newLiveExecution()
+	edit := editSuiteTest(val, live)
+	if err := edit(&ht.Test{Name: "Hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := val.Current.(ht.Test).Name; got != "Hello" || len(val.Last) != 1 {
+		t.Errorf("Editing wrong test %q", got)
+	}
+
+	live.running = true
+	if err := edit(&ht.Test{Name: "Bye"}); err == nil {
+		t.Errorf("Test replaced during live execution")
+	}
+}
+
+func TestGUITryChecks(t *testing.T) {
+	defer func(vars cmdlVar) { variablesFlag = vars }(variablesFlag)
+	variablesFlag = cmdlVar{}
+
+	resp, err := parseResponse("HTTP/1.1 404 Not Found\r\nContent-Type: text/plain\r\n"+
+		"Content-Length: 99\r\n\r\nNo such page\r\nat all", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Response.StatusCode != 404 || resp.Response.Header.Get("Content-Type") != "text/plain" ||
+		resp.BodyStr != "No such page\nat all" {
+		t.Errorf("Bad parsed response %+v %q", resp.Response, resp.BodyStr)
+	}
+	resp, err = parseResponse(`{"Hello": "World"}`, "application/json")
+	if err != nil || resp.Response.StatusCode != 200 ||
+		resp.Response.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Bad plain body response %+v, %v", resp.Response, err)
+	}
+	if _, err := parseResponse("HTTP/1.1 four-hundred\n\n", ""); err == nil {
+		t.Errorf("Missing error for malformed status line")
+	}
+	for _, raw := range []string{
+		"HTTP/2 201\r\ncontent-type: text/plain\r\n\r\nCreated",
+		"HTTP/3 201\ncontent-type: text/plain\n\nCreated",
+		"HTTP/1.1 100 Continue\r\n\r\nHTTP/1.1 201 Created\r\nContent-Type: text/plain\r\n\r\nCreated",
+		"HTTP/1.1 200 Connection established\r\n\r\n" +
+			"HTTP/2 201\r\ncontent-type: text/plain\r\n\r\nCreated",
+	} {
+		resp, err := parseResponse(raw, "")
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", raw, err)
+			continue
+		}
+		if resp.Response.Status != "201 Created" ||
+			resp.Response.Header.Get("Content-Type") != "text/plain" || resp.BodyStr != "Created" {
+			t.Errorf("%q: bad parsed response %+v %q", raw, resp.Response, resp.BodyStr)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "gui-checks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "common.mix"), []byte(`{
+    Checks: [ {Check: "StatusCode", Expect: 200} ]
+}`), 0644)
+	orig := filepath.Join(dir, "orig.ht")
+	ioutil.WriteFile(orig, []byte(`{
+    Name: "Original"
+    Mixin: [ "common.mix" ]
+    Request: { URL: "http://www.example.org/path" }
+    Checks: [ {Check: "Body", Contains: "Hello"} ]
+}`), 0644)
+
+	src := &guiSource{}
+	test, err := src.load(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	val := gui.NewValue(*test, "Test")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><body><h1>Hello World</h1></body></html>"))
+	}))
+	defer ts.Close()
+
+	handler := tryChecksHandler(val)
+	for i, tc := range []struct {
+		form url.Values
+		want []ht.Status
+	}{
+		{url.Values{"action": {"paste"}, "raw": {"HTTP/1.1 500 Oops\n\nHello"}},
+			[]ht.Status{ht.Pass, ht.Fail}}, // Body and StatusCode from mixin
+		{url.Values{"action": {"paste"}, "raw": {"Goodbye"}},
+			[]ht.Status{ht.Fail, ht.Pass}},
+		{url.Values{"action": {"fetch"}, "url": {ts.URL}},
+			[]ht.Status{ht.Pass, ht.Pass}},
+	} {
+		req := httptest.NewRequest("POST", "/trychecks", strings.NewReader(tc.form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != 303 {
+			t.Errorf("%d. Got status %d: %s", i, rec.Code, rec.Body)
+			continue
+		}
+		crs := val.Current.(ht.Test).Result.CheckResults
+		if len(crs) != len(tc.want) {
+			t.Errorf("%d. Got %d check results", i, len(crs))
+			continue
+		}
+		for j, cr := range crs {
+			if cr.Status != tc.want[j] {
+				t.Errorf("%d. Check %s: got %s, want %s", i, cr.Name, cr.Status, tc.want[j])
+			}
+		}
+	}
+
+	// Change the checks and save them back into the original file.
+	current := val.Current.(ht.Test)
+	current.Checks = append(current.Checks, ht.ContentType{Is: "html"})
+	if err := src.saveChecks(current, orig); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadFile(orig)
+	got := string(data)
+	for _, want := range []string{"Name: Original", "common.mix", "Contains: Hello", "Is: html"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Missing %q in\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "StatusCode") {
+		t.Errorf("Checks from mixin saved in\n%s", got)
+	}
+
+	rec := httptest.NewRecorder()
+	val.Current = current
+	checksExportHandler(val)(rec, httptest.NewRequest("GET", "/export/checks", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "Check: StatusCode") ||
+		!strings.Contains(body, "Check: ContentType") {
+		t.Errorf("Bad exported checks\n%s", body)
+	}
+}
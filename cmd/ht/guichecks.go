@@ -0,0 +1,362 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/vdobler/ht/gui"
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/internal/hjson"
+	"github.com/vdobler/ht/scope"
+)
+
+// ----------------------------------------------------------------------------
+// Check builder
+
+// The check builder allows to work on the Checks of the current test
+// against a response which was pasted or fetched instead of produced by
+// the test's own request. The working Checks can be exported as Hjson or
+// saved into a test file.
+
+var guiResponseFile string // flag -response of gui
+
+// tryChecksHandler shows the form to paste or fetch a response. A posted
+// response replaces the response of the current test and the Checks are
+// tried on it.
+func tryChecksHandler(val *gui.Value) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		data := tryChecksData{
+			Raw:         req.FormValue("raw"),
+			ContentType: strings.TrimSpace(req.FormValue("contenttype")),
+			URL:         strings.TrimSpace(req.FormValue("url")),
+		}
+		if req.Method == "POST" {
+			var resp ht.Response
+			var err error
+			origin := ""
+			switch req.FormValue("action") {
+			case "paste":
+				resp, err = parseResponse(data.Raw, data.ContentType)
+				origin = "pasted response"
+			case "fetch":
+				resp, err = fetchResponse(data.URL)
+				origin = data.URL
+			default:
+				err = fmt.Errorf("unknown action %q", req.FormValue("action"))
+			}
+			if err == nil {
+				useResponse(val, resp, origin)
+				w.Header().Set("Location", "/#Test.Checks")
+				w.WriteHeader(303)
+				return
+			}
+			data.Error = err.Error()
+		}
+
+		buf := &bytes.Buffer{}
+		if err := tryChecksTmpl.Execute(buf, data); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(200)
+		w.Write([]byte(scope.Mask(buf.String())))
+	}
+}
+
+type tryChecksData struct {
+	Raw         string // the pasted response
+	ContentType string // of a pasted body
+	URL         string // to fetch the response from
+	Error       string
+}
+
+// useResponse makes resp the response of the current test in val and
+// tries the Checks on it.
+func useResponse(val *gui.Value, resp ht.Response, origin string) {
+	val.PushCurrent()
+	test := val.Current.(ht.Test)
+	test.Response = resp
+	test.Result.CheckResults = nil
+	val.Current = test
+	executeChecks(val)
+	val.Messages["Test.Response"] = []gui.Message{{
+		Type: "info",
+		Text: "Response taken from " + origin,
+	}}
+}
+
+// parseResponse parses raw as a HTTP response as dumped e.g. by curl -i.
+// Everything after the header is the body, a Content-Length header is
+// not honoured. Interim 1xx responses and the answer of a proxy to
+// CONNECT preceding the actual response are skipped and the status lines
+// "HTTP/2 200" and "HTTP/3 200" of curl are understood. If raw does not
+// start with a status line it is taken as the body of a 200 response
+// with the given or detected contentType.
+func parseResponse(raw, contentType string) (ht.Response, error) {
+	if !strings.HasPrefix(strings.TrimLeft(raw, " \t\r\n"), "HTTP/") {
+		if contentType == "" {
+			contentType = http.DetectContentType([]byte(raw))
+		}
+		return ht.Response{
+			Response: &http.Response{
+				Status:     "200 OK",
+				StatusCode: 200,
+				Proto:      "HTTP/1.1",
+				ProtoMajor: 1,
+				ProtoMinor: 1,
+				Header:     http.Header{"Content-Type": {contentType}},
+			},
+			BodyStr:  raw,
+			BodySize: int64(len(raw)),
+		}, nil
+	}
+
+	raw = strings.Replace(strings.TrimLeft(raw, " \t\r\n"), "\r\n", "\n", -1)
+	head, body := splitResponse(raw)
+	for strings.HasPrefix(body, "HTTP/") && isPreamble(head) {
+		head, body = splitResponse(body)
+	}
+	for _, major := range []string{"HTTP/2 ", "HTTP/3 "} {
+		if strings.HasPrefix(head, major) {
+			head = major[:len(major)-1] + ".0 " + head[len(major):]
+		}
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(head+"\n")), nil)
+	if err != nil {
+		return ht.Response{}, fmt.Errorf("malformed response: %s", err)
+	}
+	if resp.Status == strconv.Itoa(resp.StatusCode) {
+		// HTTP/2 and HTTP/3 status lines lack the reason phrase.
+		resp.Status += " " + http.StatusText(resp.StatusCode)
+	}
+	resp.Body.Close()
+	resp.Body = nil
+	resp.ContentLength = int64(len(body))
+	if contentType != "" && resp.Header.Get("Content-Type") == "" {
+		resp.Header.Set("Content-Type", contentType)
+	}
+	return ht.Response{Response: resp, BodyStr: body, BodySize: int64(len(body))}, nil
+}
+
+// splitResponse splits raw into the status line with the header and the
+// body.
+func splitResponse(raw string) (head, body string) {
+	if i := strings.Index(raw, "\n\n"); i >= 0 {
+		return raw[:i+1], raw[i+2:]
+	}
+	return raw + "\n", ""
+}
+
+// isPreamble reports whether head belongs to an interim 1xx response or
+// to the answer "200 Connection established" of a proxy to CONNECT.
+func isPreamble(head string) bool {
+	status := strings.Fields(strings.SplitN(head, "\n", 2)[0])
+	if len(status) < 2 {
+		return false
+	}
+	if len(status[1]) == 3 && status[1][0] == '1' {
+		return true
+	}
+	reason := strings.ToLower(strings.Join(status[2:], " "))
+	return reason == "connection established"
+}
+
+// fetchResponse fetches the response for a GET request of rawurl.
+func fetchResponse(rawurl string) (ht.Response, error) {
+	if rawurl == "" {
+		return ht.Response{}, fmt.Errorf("missing URL")
+	}
+	test := &ht.Test{
+		Name:    "Fetch " + rawurl,
+		Request: ht.Request{Method: "GET", URL: rawurl},
+	}
+	test.Run()
+	if test.Response.Response == nil {
+		return ht.Response{}, fmt.Errorf("cannot fetch %s: %v", rawurl, test.Result.Error)
+	}
+	test.Response.Response.Request = nil
+	test.Response.Response.TLS = nil
+	return test.Response, nil
+}
+
+// readResponseFile reads a response (with or without status line and
+// header) from filename.
+func readResponseFile(filename string) (ht.Response, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return ht.Response{}, err
+	}
+	return parseResponse(string(data), mime.TypeByExtension(filepath.Ext(filename)))
+}
+
+// checksSoup returns the Checks of test as a raw Hjson list with durations
+// made readable and variable substitution inverted.
+func checksSoup(test ht.Test) ([]interface{}, error) {
+	if len(test.Checks) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(test.Checks)
+	if err != nil {
+		return nil, err
+	}
+	var checks []interface{}
+	if err := hjson.Unmarshal(data, &checks); err != nil {
+		return nil, err
+	}
+	for _, check := range checks {
+		if c, ok := check.(map[string]interface{}); ok {
+			fixDuration(c)
+		}
+	}
+	soup, err := invertVars(map[string]interface{}{"Checks": checks}, test.Variables)
+	if err != nil {
+		return nil, err
+	}
+	checks, _ = soup["Checks"].([]interface{})
+	return checks, nil
+}
+
+// checksExportHandler serves the Checks of the current test as Hjson
+// ready to be pasted into a test file.
+func checksExportHandler(val *gui.Value) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		checks, err := checksSoup(val.Current.(ht.Test))
+		if err == nil && checks == nil {
+			checks = []interface{}{}
+		}
+		var data []byte
+		if err == nil {
+			data, err = hjson.Marshal(map[string]interface{}{"Checks": checks})
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			fmt.Fprintf(w, "Cannot export Checks: %s\n", err)
+			return
+		}
+		w.WriteHeader(200)
+		scope.MaskingWriter(w).Write(data)
+	}
+}
+
+// saveChecks writes the Checks of test into the test file filename: The
+// Checks of an existing file are replaced, everything else is kept. If
+// filename does not exist a new test consisting of the Checks is written.
+// Checks provided by the mixins of the file the test was loaded from are
+// not written to that file.
+func (src *guiSource) saveChecks(test ht.Test, filename string) error {
+	checks, err := checksSoup(test)
+	if err != nil {
+		return err
+	}
+
+	soup := map[string]interface{}{"Name": test.Name}
+	data, err := ioutil.ReadFile(filename)
+	if err == nil {
+		var s interface{}
+		if err := hjson.Unmarshal(data, &s); err != nil {
+			return fmt.Errorf("cannot parse %s: %s", filename, err)
+		}
+		var ok bool
+		if soup, ok = s.(map[string]interface{}); !ok {
+			return fmt.Errorf("%s is not a test", filename)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if src.raw != nil && src.raw.File.Name == filename {
+		for _, mixin := range src.raw.Mixins {
+			var raw map[string]interface{}
+			if err := hjson.Unmarshal([]byte(mixin.File.Data), &raw); err != nil {
+				return err
+			}
+			provided, _ := raw["Checks"].([]interface{})
+			rest := []interface{}{}
+			for _, check := range checks {
+				if !containsElement(provided, check) {
+					rest = append(rest, check)
+				}
+			}
+			checks = rest
+		}
+	}
+
+	if len(checks) == 0 {
+		delete(soup, "Checks")
+	} else {
+		soup["Checks"] = checks
+	}
+	if data, err = hjson.Marshal(soup); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0666)
+}
+
+// saveChecks writes the Checks of the current test in val to filename.
+func saveChecks(val *gui.Value, src *guiSource, filename string) {
+	err := src.saveChecks(val.Current.(ht.Test), filename)
+	if err != nil {
+		val.Messages["Test.Checks"] = []gui.Message{{
+			Type: "error",
+			Text: fmt.Sprintf("Cannot save Checks to %s: %s", filename, err),
+		}}
+		return
+	}
+	val.Messages["Test.Checks"] = []gui.Message{{
+		Type: "info",
+		Text: "Saved Checks to " + filename,
+	}}
+}
+
+var tryChecksTmpl = template.Must(template.New("trychecks").Parse(`<!doctype html>
+<html>
+<head>
+  <meta charset="UTF-8">
+  <title>Try Checks</title>
+  <style>
+body { margin: 40px; font-family: sans-serif; }
+textarea { width: 90%; font-family: monospace; }
+p.error { color: darkred; font-weight: bold; }
+  </style>
+</head>
+<body>
+  <h1>Try Checks</h1>
+  <p><a href="/#Test.Checks">Back to test</a></p>
+  <p>The response pasted or fetched here replaces the response of the
+    current test and its Checks are tried on it. Iterate on the Checks with
+    "Try Checks" and export them with "Export Checks" or "Save Checks".</p>
+  {{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+  <form action="/trychecks" method="post">
+    <h2>Paste Response</h2>
+    <p>A full response including status line and header (e.g. from
+      <code>curl -i</code>) or just the body.</p>
+    <textarea name="raw" rows="20">{{.Raw}}</textarea>
+    <p>Content-Type of a plain body:
+      <input type="text" name="contenttype" value="{{.ContentType}}" style="width: 300px;"
+        placeholder="detected if empty"></p>
+    <p><button name="action" value="paste"> Use pasted Response </button></p>
+  </form>
+  <form action="/trychecks" method="post">
+    <h2>Fetch Response</h2>
+    <p>URL: <input type="text" name="url" value="{{.URL}}" style="width: 500px;">
+      <button name="action" value="fetch"> Fetch Response </button></p>
+  </form>
+</body>
+</html>
+`))
@@ -17,9 +17,33 @@ func init() {
 				Doc: "Of is the list of checks to execute.\n",
 			}}})
 
+	gui.RegisterType(ht.Assets{}, gui.Typeinfo{
+		Doc: "Assets checks the resources of a HTML page loaded like a browser does via the\nLoadResources field of the test. All resources must be loaded without error and\nwith a status code below 400.\n",
+		Field: map[string]gui.Fieldinfo{
+			"AllowBroken": gui.Fieldinfo{
+				Doc: "AllowBroken turns off the check that all resources were loaded successfully.\n",
+			},
+			"MaxCount": gui.Fieldinfo{
+				Doc: "MaxCount is the maximal number of resources. Zero means unlimited.\n",
+			},
+			"MaxTime": gui.Fieldinfo{
+				Doc: "MaxTime is the maximal time to load the slowest resource. Zero means unlimited.\n",
+			},
+			"MaxWeight": gui.Fieldinfo{
+				Doc: "MaxWeight is the maximal size of the page and all its resources in bytes.\nZero means unlimited.\n",
+			},
+			"SameOrigin": gui.Fieldinfo{
+				Doc: "SameOrigin requires that all resources are loaded from the origin (scheme,\nhost and port) of the page.\n",
+			}}})
 	gui.RegisterType(ht.Body{}, gui.Typeinfo{
 		Doc: "Body provides simple condition checks on the response body.\n",
 		Field: map[string]gui.Fieldinfo{
+			"After": gui.Fieldinfo{
+				Doc: "Before and After are exclusive upper and lower bounds on the time value of the\nstring. The (dequoted) string as well as Before and After are parsed with Time\nas the layout or as RFC 3339 if Time is empty. If the string is not a valid time\nthese conditions fail.\n",
+			},
+			"Before": gui.Fieldinfo{
+				Doc: "Before and After are exclusive upper and lower bounds on the time value of the\nstring. The (dequoted) string as well as Before and After are parsed with Time\nas the layout or as RFC 3339 if Time is empty. If the string is not a valid time\nthese conditions fail.\n",
+			},
 			"Contains": gui.Fieldinfo{
 				Doc: "Contains must be contained in the string.\n",
 			},
@@ -29,9 +53,15 @@ func init() {
 			"Equals": gui.Fieldinfo{
 				Doc: "Equals is the exact value to be expected. No other tests are performed if Equals\nis non-zero as these other tests would be redundant.\n",
 			},
+			"Glob": gui.Fieldinfo{
+				Doc: "Glob is a shell-style pattern the whole string must match:\n\n    *      matches any sequence of characters\n    ?      matches any single character\n    [a-z]  matches one character from the class, [!a-z] one not\n           from the class\n    \\*     matches a literal *\n\nGlob is often simpler than Regexp, e.g. for \"order-*.pdf\".\n",
+			},
 			"GreaterThan": gui.Fieldinfo{
 				Doc: "GreaterThan and LessThan are lower and upper bound on the numerical value of the\nstring: The string is trimmed from spaces as well as from single and double\nquotes before parsed as a float64. If the string is not float value these\nconditions fail. Nil disables these conditions.\n",
 			},
+			"IgnoreCase": gui.Fieldinfo{
+				Doc: "IgnoreCase makes Equals, Prefix, Suffix, Contains and Regexp case-insensitive.\n",
+			},
 			"Is": gui.Fieldinfo{
 				Doc: "Is checks whether the string under test matches one of a given list of given\ntypes. Double quotes are trimmed from the string before validation its type.\n\nThe following types are available:\n\n    Alpha          Alphanumeric  ASCII             Base64\n    CIDR           CreditCard    DataURI           DialString\n    DNSName        Email         FilePath          Float\n    FullWidth      HalfWidth     Hexadecimal       Hexcolor\n    Host           Int           IP                IPv4\n    IPv6           ISBN10        ISBN13            ISO3166Alpha2\n    ISO3166Alpha3  JSON          Latitude          Longitude\n    LowerCase      MAC           MongoID           Multibyte\n    Null           Numeric       Port              PrintableASCII\n    RequestURI     RequestURL    RFC3339           RGBcolor\n    Semver         SSN           UpperCase         URL\n    UTFDigit       UTFLetter     UTFLetterNumeric  UTFNumeric\n    UUID           UUIDv3        UUIDv4            UUIDv5\n    VariableWidth\n\nSee github.com/asaskevich/govalidator for a detailed description.\n\nThe string \"OR\" is ignored an can be used to increase the readability of this\ncondition in situations like\n\n    Condition{Is: \"Hexcolor OR RGBColor OR MongoID\"}\n",
 			},
@@ -44,6 +74,12 @@ func init() {
 			"Min": gui.Fieldinfo{
 				Doc: "Min and Max are the minimum and maximum length the string may have. Two zero\nvalues disables this test.\n",
 			},
+			"Normalize": gui.Fieldinfo{
+				Doc: "Normalize the string as well as Equals, Prefix, Suffix and Contains before\ncomparing them: The strings are brought to Unicode normalization form C, runs of\nwhite space (including non-breaking spaces) are collapsed to a single space and\nleading and trailing white space is removed.\n",
+			},
+			"OneOf": gui.Fieldinfo{
+				Doc: "OneOf is a list of allowed values: The string must equal one of them. An empty\nlist disables this test.\n",
+			},
 			"Prefix": gui.Fieldinfo{
 				Doc: "Prefix is the required prefix\n",
 			},
@@ -53,6 +89,9 @@ func init() {
 			"Suffix": gui.Fieldinfo{
 				Doc: "Suffix is the required suffix.\n",
 			},
+			"Tolerance": gui.Fieldinfo{
+				Doc: "Tolerance turns Equals into a numerical comparison: The string and Equals are\nparsed as float64 (trimmed like for GreaterThan) and may differ by at most\nTolerance. A zero Tolerance compares numbers exactly so that \"5\" equals \"5.0\".\nNil compares Equals literally.\n",
+			},
 			"Time": gui.Fieldinfo{
 				Doc: "Time checks whether the string is a valid time if parsed with Time as the layout\nstring.\n",
 			}}})
@@ -76,6 +115,34 @@ func init() {
 				Doc: "Private checks for the \"private\" directive\n",
 			}}})
 
+	gui.RegisterType(ht.Charset{}, gui.Typeinfo{
+		Doc: "Charset checks that the response declares its charset in the Content-Type\nheader or in a HTML meta tag and that the body actually is encoded in the\ndeclared charset. Declaring e.g. ISO-8859-1 but sending UTF-8 fails, as does\ndeclaring UTF-8 but sending invalid UTF-8.\n\nThe check works on the original bytes of the response, even if the body was\ntranscoded to UTF-8.\n",
+		Field: map[string]gui.Fieldinfo{
+			"Expect": gui.Fieldinfo{
+				Doc: "Expect is the expected charset like \"UTF-8\" or \"ISO-8859-1\". Aliases of\ncharsets are recognised. The empty string accepts any declared charset.\n",
+			}}})
+
+	gui.RegisterType(ht.CompareWith{}, gui.Typeinfo{
+		Doc: "CompareWith sends the request of the test a second time to a reference system\n(e.g. the previous release or the green deployment) and compares the two\nresponses: The status codes, the selected headers and the bodies must match.\nJSON bodies are compared structurally (ignoring formatting and the order of\nobject members), other bodies textually.\n\nThe reference request is the fully substituted request of the test with scheme,\nhost and port (and path prefix) of the URL replaced by the ones of Reference.\nThe reference request does not send the cookies of the test's cookie jar.\n",
+		Field: map[string]gui.Fieldinfo{
+			"Header": gui.Fieldinfo{
+				Doc: "Header is the list of HTTP headers whose values must match, e.g.\n[\"Content-Type\", \"Cache-Control\"].\n",
+			},
+			"Ignore": gui.Fieldinfo{
+				Doc: "Ignore is the list of JSON elements excluded from the comparison like timestamps\nor IDs. The elements are given in the notation of the JSON check, a \"*\" matches\nall array elements or object members, e.g. \"items.*.id\".\n",
+			},
+			"IgnoreBody": gui.Fieldinfo{
+				Doc: "",
+			},
+			"IgnoreStatus": gui.Fieldinfo{
+				Doc: "IgnoreStatus and IgnoreBody turn off comparing the status codes and the bodies.\n",
+			},
+			"Reference": gui.Fieldinfo{
+				Doc: "Reference is the base URL of the reference system, e.g.\n\"https://blue.example.org\" or \"http://localhost:8080/v1\".\n",
+			},
+			"Sep": gui.Fieldinfo{
+				Doc: "Sep is the separator in Ignore. A zero value is equivalent to \".\".\n",
+			}}})
 	gui.RegisterType(ht.ContentType{}, gui.Typeinfo{
 		Doc: "ContentType checks the Content-Type header.\n",
 		Field: map[string]gui.Fieldinfo{
@@ -86,6 +153,22 @@ func init() {
 				Doc: "Is is the wanted content type. It may be abrevated, e.g. \"json\" would match\n\"application/json\"\n",
 			}}})
 
+	gui.RegisterType(ht.CookieJar{}, gui.Typeinfo{
+		Doc: "CookieJar checks the content of the test's cookie jar after the response\nwas received, i.e. the cookies stored after processing all Set-Cookie\nheaders (of redirects too) according to RFC 6265 and the jar's policy.\nThe test must use a cookie jar, e.g. by being part of a suite with\nKeepCookies set.\n",
+		Field: map[string]gui.Fieldinfo{
+			"Absent": gui.Fieldinfo{
+				Doc: "Absent indicates that no cookie with the given Name (or no cookie\nat all if Name is empty) may be stored.\n",
+			},
+			"Domain": gui.Fieldinfo{
+				Doc: "Domain whose cookies (including the cookies of its subdomains)\nare checked. Empty means the host of the request.\n",
+			},
+			"Name": gui.Fieldinfo{
+				Doc: "Name of the cookie. Empty means any cookie.\n",
+			},
+			"Value": gui.Fieldinfo{
+				Doc: "Value is applied to the value of all cookies with the given Name.\n",
+			}}})
+
 	gui.RegisterType(ht.CustomJS{}, gui.Typeinfo{
 		Doc: "CustomJS executes the provided JavaScript.\n\nThe current Test is present in the JavaScript VM via binding the name \"Test\" at\ntop-level to the current Test being checked.\n\nThe Script's last value indicates success or failure:\n\n    - Success: true, 0, \"\"\n    - Failure: false, any number != 0, any string != \"\"\n\nCustomJS can be useful to log an excerpt of response (or the request) via\nconsole.log.\n\nThe JavaScript code is interpreted by otto. See the documentation at\nhttps://godoc.org/github.com/robertkrimen/otto for details.\n",
 		Field: map[string]gui.Fieldinfo{
@@ -113,9 +196,31 @@ func init() {
 				Doc: "// Weak allows weak ETags too.",
 			}}})
 
+	gui.RegisterType(ht.External{}, gui.Typeinfo{
+		Doc: "External is a check performed by an external program which allows to write\nchecks in any language without recompiling ht.\n\nThe program Command is started with Args. It receives an ExternalRequest of kind\n\"check\" as JSON on its standard input and must print an ExternalVerdict as JSON\nto its standard output, e.g.\n\n    {\"Status\": \"Fail\", \"Message\": \"price 0.00 not allowed\"}\n\nA nonzero exit status or output which is not a verdict is an error.\n",
+		Field: map[string]gui.Fieldinfo{
+			"Args": gui.Fieldinfo{
+				Doc: "Command is the program to execute and Args its arguments.\n",
+			},
+			"Command": gui.Fieldinfo{
+				Doc: "Command is the program to execute and Args its arguments.\n",
+			},
+			"Config": gui.Fieldinfo{
+				Doc: "Config is passed unchanged to the program.\n",
+			},
+			"Timeout": gui.Fieldinfo{
+				Doc: "Timeout limits the runtime of the program. Zero means DefaultExternalTimeout.\n",
+			}}})
+
 	gui.RegisterType(ht.FinalURL{}, gui.Typeinfo{
 		Doc: "FinalURL checks the last URL after following all redirects. This check is useful\nonly for tests with Request.FollowRedirects=true\n",
 		Field: map[string]gui.Fieldinfo{
+			"After": gui.Fieldinfo{
+				Doc: "Before and After are exclusive upper and lower bounds on the time value of the\nstring. The (dequoted) string as well as Before and After are parsed with Time\nas the layout or as RFC 3339 if Time is empty. If the string is not a valid time\nthese conditions fail.\n",
+			},
+			"Before": gui.Fieldinfo{
+				Doc: "Before and After are exclusive upper and lower bounds on the time value of the\nstring. The (dequoted) string as well as Before and After are parsed with Time\nas the layout or as RFC 3339 if Time is empty. If the string is not a valid time\nthese conditions fail.\n",
+			},
 			"Contains": gui.Fieldinfo{
 				Doc: "Contains must be contained in the string.\n",
 			},
@@ -125,9 +230,15 @@ func init() {
 			"Equals": gui.Fieldinfo{
 				Doc: "Equals is the exact value to be expected. No other tests are performed if Equals\nis non-zero as these other tests would be redundant.\n",
 			},
+			"Glob": gui.Fieldinfo{
+				Doc: "Glob is a shell-style pattern the whole string must match:\n\n    *      matches any sequence of characters\n    ?      matches any single character\n    [a-z]  matches one character from the class, [!a-z] one not\n           from the class\n    \\*     matches a literal *\n\nGlob is often simpler than Regexp, e.g. for \"order-*.pdf\".\n",
+			},
 			"GreaterThan": gui.Fieldinfo{
 				Doc: "GreaterThan and LessThan are lower and upper bound on the numerical value of the\nstring: The string is trimmed from spaces as well as from single and double\nquotes before parsed as a float64. If the string is not float value these\nconditions fail. Nil disables these conditions.\n",
 			},
+			"IgnoreCase": gui.Fieldinfo{
+				Doc: "IgnoreCase makes Equals, Prefix, Suffix, Contains and Regexp case-insensitive.\n",
+			},
 			"Is": gui.Fieldinfo{
 				Doc: "Is checks whether the string under test matches one of a given list of given\ntypes. Double quotes are trimmed from the string before validation its type.\n\nThe following types are available:\n\n    Alpha          Alphanumeric  ASCII             Base64\n    CIDR           CreditCard    DataURI           DialString\n    DNSName        Email         FilePath          Float\n    FullWidth      HalfWidth     Hexadecimal       Hexcolor\n    Host           Int           IP                IPv4\n    IPv6           ISBN10        ISBN13            ISO3166Alpha2\n    ISO3166Alpha3  JSON          Latitude          Longitude\n    LowerCase      MAC           MongoID           Multibyte\n    Null           Numeric       Port              PrintableASCII\n    RequestURI     RequestURL    RFC3339           RGBcolor\n    Semver         SSN           UpperCase         URL\n    UTFDigit       UTFLetter     UTFLetterNumeric  UTFNumeric\n    UUID           UUIDv3        UUIDv4            UUIDv5\n    VariableWidth\n\nSee github.com/asaskevich/govalidator for a detailed description.\n\nThe string \"OR\" is ignored an can be used to increase the readability of this\ncondition in situations like\n\n    Condition{Is: \"Hexcolor OR RGBColor OR MongoID\"}\n",
 			},
@@ -140,6 +251,12 @@ func init() {
 			"Min": gui.Fieldinfo{
 				Doc: "Min and Max are the minimum and maximum length the string may have. Two zero\nvalues disables this test.\n",
 			},
+			"Normalize": gui.Fieldinfo{
+				Doc: "Normalize the string as well as Equals, Prefix, Suffix and Contains before\ncomparing them: The strings are brought to Unicode normalization form C, runs of\nwhite space (including non-breaking spaces) are collapsed to a single space and\nleading and trailing white space is removed.\n",
+			},
+			"OneOf": gui.Fieldinfo{
+				Doc: "OneOf is a list of allowed values: The string must equal one of them. An empty\nlist disables this test.\n",
+			},
 			"Prefix": gui.Fieldinfo{
 				Doc: "Prefix is the required prefix\n",
 			},
@@ -149,6 +266,9 @@ func init() {
 			"Suffix": gui.Fieldinfo{
 				Doc: "Suffix is the required suffix.\n",
 			},
+			"Tolerance": gui.Fieldinfo{
+				Doc: "Tolerance turns Equals into a numerical comparison: The string and Equals are\nparsed as float64 (trimmed like for GreaterThan) and may differ by at most\nTolerance. A zero Tolerance compares numbers exactly so that \"5\" equals \"5.0\".\nNil compares Equals literally.\n",
+			},
 			"Time": gui.Fieldinfo{
 				Doc: "Time checks whether the string is a valid time if parsed with Time as the layout\nstring.\n",
 			}}})
@@ -196,10 +316,13 @@ func init() {
 			}}})
 
 	gui.RegisterType(ht.Identity{}, gui.Typeinfo{
-		Doc: "Identity checks the value of the response body by comparing its SHA1 hash to the\nexpected SHA1 value.\n",
+		Doc: "Identity checks the value of the response body by comparing its SHA1 and/or\nSHA-256 hash to the expected value.\n",
 		Field: map[string]gui.Fieldinfo{
 			"SHA1": gui.Fieldinfo{
 				Doc: "SHA1 is the expected hash as shown by sha1sum of the whole body. E.g.\n2ef7bde608ce5404e97d5f042f95f89f1c232871 for a \"Hello World!\" body (no newline).\n",
+			},
+			"SHA256": gui.Fieldinfo{
+				Doc: "SHA256 is the expected hash as shown by sha256sum of the whole body. If set,\nSHA1 is checked only if non-empty.\n",
 			}}})
 
 	gui.RegisterType(ht.Image{}, gui.Typeinfo{
@@ -443,6 +566,24 @@ func init() {
 				Doc: "// Value is applied to the cookie value",
 			}}})
 
+	gui.RegisterType(ht.Snapshot{}, gui.Typeinfo{
+		Doc: "Snapshot compares the response against a baseline recorded in a file. If the\nbaseline file does not exist (or if UpdateSnapshots is set) the snapshot of the\nactual response is recorded as the new baseline and the check passes. Otherwise\nthe check fails with a line diff between the baseline and the actual snapshot.\n\nA snapshot of a HTML page consists of the DOM regions selected by Select and/or\nthe list of links and assets on the page. A snapshot of a JSON document is\nthe indented JSON where the volatile elements listed in Mask are replaced by\n\"<masked>\". Other responses are snapshot as is.\n",
+		Field: map[string]gui.Fieldinfo{
+			"Baseline": gui.Fieldinfo{
+				Doc: "Baseline is the file path of the baseline, typically relative to the test like\nin \"{{TEST_DIR}}/snapshots/homepage.snap\".\n",
+			},
+			"Links": gui.Fieldinfo{
+				Doc: "Links records the (unresolved) targets of all links, images, scripts,\nstylesheets etc. of a HTML page.\n",
+			},
+			"Mask": gui.Fieldinfo{
+				Doc: "Mask is the list of volatile elements of a JSON document like timestamps or\nIDs whose values are masked. The elements are given in the notation of the JSON\ncheck, a \"*\" matches all array elements or object members, e.g. \"items.*.id\".\n",
+			},
+			"Select": gui.Fieldinfo{
+				Doc: "Select is a list of CSS selectors of the DOM regions of a HTML page to record.\n",
+			},
+			"Sep": gui.Fieldinfo{
+				Doc: "Sep is the separator in Mask. A zero value is equivalent to \".\".\n",
+			}}})
 	gui.RegisterType(ht.Sorted{}, gui.Typeinfo{
 		Doc: "Sorted checks for an ordered occurrence of items. The check Sorted could be\nreplaced by a Regexp based Body test without loss of functionality; Sorted just\nmakes the idea of \"looking for a sorted occurrence\" clearer.\n\nIf the response has a Content-Type header indicating a HTML response the HTML\nwill be parsed and the text content normalized as described in the HTMLContains\ncheck.\n",
 		Field: map[string]gui.Fieldinfo{
@@ -453,6 +594,13 @@ func init() {
 				Doc: "Text is the list of text fragments to look for in the response body or the\nnormalized text content of the HTML page.\n",
 			}}})
 
+	gui.RegisterType(ht.StatusClass{}, gui.Typeinfo{
+		Doc: "StatusClass checks the HTTP statuscode against a list of status codes and\nclasses of status codes.\n",
+		Field: map[string]gui.Fieldinfo{
+			"Expect": gui.Fieldinfo{
+				Doc: "Expect is a comma separated list of status codes and classes of status codes\nlike \"2xx\" or \"2xx, 304\" one of which must match.\n",
+			}}})
+
 	gui.RegisterType(ht.StatusCode{}, gui.Typeinfo{
 		Doc: "StatusCode checks the HTTP statuscode.\n",
 		Field: map[string]gui.Fieldinfo{
@@ -508,6 +656,22 @@ func init() {
 				Doc: "// Name is the name of the cookie.",
 			}}})
 
+	gui.RegisterType(ht.ExternalExtractor{}, gui.Typeinfo{
+		Doc: "ExternalExtractor extracts a value with an external program. The program is\ncalled like the one of an External check but receives an ExternalRequest of kind\n\"extractor\" and must print a verdict with status \"Pass\" and the extracted\nValue.\n",
+		Field: map[string]gui.Fieldinfo{
+			"Args": gui.Fieldinfo{
+				Doc: "Command is the program to execute and Args its arguments.\n",
+			},
+			"Command": gui.Fieldinfo{
+				Doc: "Command is the program to execute and Args its arguments.\n",
+			},
+			"Config": gui.Fieldinfo{
+				Doc: "Config is passed unchanged to the program.\n",
+			},
+			"Timeout": gui.Fieldinfo{
+				Doc: "Timeout limits the runtime of the program. Zero means DefaultExternalTimeout.\n",
+			}}})
+
 	gui.RegisterType(ht.HTMLExtractor{}, gui.Typeinfo{
 		Doc: "HTMLExtractor allows to extract data from an executed Test. It supports\nextracting HTML attribute values and HTML text node values. Examples for CSRF\ntoken in the HTML:\n\n    <meta name=\"_csrf\" content=\"18f0ca3f-a50a-437f-9bd1-15c0caa28413\" />\n    <input type=\"hidden\" name=\"_csrf\"\n        value=\"18f0ca3f-a50a-437f-9bd1-15c0caa28413\"/>\n",
 		Field: map[string]gui.Fieldinfo{
@@ -539,7 +703,7 @@ func init() {
 			}}})
 
 	gui.RegisterType(ht.SetTimestamp{}, gui.Typeinfo{
-		Doc: "SetTimestamp allows to progmatically extract the current time optionaly offset\nby a certain duration in a user selected layout. To round the extracted\ntimestamp e.g. to multiple of hours use a format like \"2006-01-02 15:00:00\" with\nfixed minutes and seconds.\n\nThe test and the response are ignored.\n",
+		Doc: "SetTimestamp allows to progmatically extract the current time optionaly\noffset by a certain duration in a user selected layout. To round the extracted\ntimestamp e.g. to multiple of hours use a format like \"2006-01-02 15:00:00\" with\nfixed minutes and seconds.\n\nThe current time is taken from the test variable FROZEN_NOW if set (see\nscope.FrozenNow), the response is ignored.\n",
 		Field: map[string]gui.Fieldinfo{
 			"DeltaDay": gui.Fieldinfo{
 				Doc: "DeltaYear, DeltaMonth and DeltaDay are deltas to now but for whole years, month\nand days.\n",
@@ -579,6 +743,12 @@ func init() {
 			"Execution": gui.Fieldinfo{
 				Doc: "Execution controls the test execution.\n",
 			},
+			"Hooks": gui.Fieldinfo{
+				Doc: "Hooks are called before each request, after each response and on failure of\nthe test.\n",
+			},
+			"LoadResources": gui.Fieldinfo{
+				Doc: "LoadResources makes the test load the resources referenced by a HTML page like a\nbrowser.\n",
+			},
 			"Jar": gui.Fieldinfo{
 				Doc: "Jar is the cookie jar to use\n",
 			},
@@ -597,8 +767,17 @@ func init() {
 			"Result": gui.Fieldinfo{
 				Doc: "Result contains details of a test run. It is filled by the Run method and\nExecuteChecks.\n",
 			},
+			"Tags": gui.Fieldinfo{
+				Doc: "Tags are free-form labels of the test like \"smoke\" or \"checkout\". They are\nreported e.g. as labels in Allure results.\n",
+			},
+			"Transform": gui.Fieldinfo{
+				Doc: "Transform the response body before the checks and extractors are applied, e.g.\nto decrypt or decompress it.\n",
+			},
 			"Variables": gui.Fieldinfo{
 				Doc: "Variables contains name/value-pairs used for variable substitution in files read\nin, e.g. for Request.Body = \"@vfile:/path/to/file\".\n",
+			},
+			"WireLog": gui.Fieldinfo{
+				Doc: "WireLog dumps the requests and responses of the test to files.\n",
 			}}})
 
 	gui.RegisterType(ht.Request{}, gui.Typeinfo{
@@ -625,6 +804,9 @@ func init() {
 			"Header": gui.Fieldinfo{
 				Doc: "Header contains the specific http headers to be sent in this request. User-Agent\nand Accept headers are set automaticaly to the global default values if not set\nexplicitly.\n",
 			},
+			"MaxBodySize": gui.Fieldinfo{
+				Doc: "MaxBodySize limits the number of bytes of the response body captured in\nResponse.BodyStr. Larger bodies are truncated and streamed through the checks\nwhich support it (see BodyStreamer) instead of being kept in memory. Zero means\nno limit.\n",
+			},
 			"Method": gui.Fieldinfo{
 				Doc: "Method is the HTTP method to use. A empty method is equivalent to \"GET\"\n",
 			},
@@ -643,6 +825,12 @@ func init() {
 			"SentParams": gui.Fieldinfo{
 				Doc: "// the 'real' parameters",
 			},
+			"Signature": gui.Fieldinfo{
+				Doc: "Signature describes how to sign the request with a HMAC.\n",
+			},
+			"StatusMapping": gui.Fieldinfo{
+				Doc: "StatusMapping maps the outcomes of bash://, sql:// and file:// pseudo-requests\nto the status code of the response, e.g. {\"exit:3\": 404, \"sqlstate:23\": 409}.\nSee the package documentation for the outcomes and the default status codes.\n",
+			},
 			"Timeout": gui.Fieldinfo{
 				Doc: "Timeout of this request. If zero use DefaultClientTimeout.\n",
 			},
@@ -650,21 +838,58 @@ func init() {
 				Doc: "URL ist the URL of the request.\n",
 			}}})
 
+	gui.RegisterType(ht.Signature{}, gui.Typeinfo{
+		Doc: "Signature describes how a request is signed with a HMAC, e.g. for APIs\nauthenticated by a custom X-Signature header or by HTTP Signatures.\n\nThe string to sign and the value of the signature header are templates with\nplaceholders in single braces:\n\n    {method}              the request method, e.g. POST\n    {request-target}      lowercase method and path with query, e.g.\n                          \"post /api/items?limit=5\"\n    {host}                the host (and port) of the request\n    {path}                the path of the request URL\n    {query}               the raw query of the request URL\n    {uri}                 path and query of the request URL\n    {date}                the Date header; it is set to the current\n                          time unless the Header of the Request\n                          contains a Date\n    {timestamp}           the current time as Unix seconds\n    {body}                the request body\n    {body-sha256}         the hex encoded SHA-256 hash of the body\n    {body-sha256-base64}  the base64 encoded SHA-256 hash of the body\n    {body-md5}            the hex encoded MD5 hash of the body\n    {header:<Name>}       the value of the request header Name\n    {signature}           the signature (only in Format)\n\nA HTTP Signature (draft-cavage-http-signatures) can be produced by\n\n    Signature: {\n        Key: \"{{SECRET}}\"\n        Digest: true\n        StringToSign: \"(request-target): {request-target}\\nhost: {host}\\ndate: {date}\\ndigest: {header:Digest}\"\n        Header: \"Authorization\"\n        Format: \"Signature keyId=\\\"{{KEY_ID}}\\\",algorithm=\\\"hmac-sha256\\\",headers=\\\"(request-target) host date digest\\\",signature=\\\"{signature}\\\"\"\n    }\n\nThe signature is computed for each try after the body has been finalised and the\nPreRequest hook has been called.\n",
+		Field: map[string]gui.Fieldinfo{
+			"Algorithm": gui.Fieldinfo{
+				Doc: "Algorithm is the HMAC algorithm: \"hmac-sha1\", \"hmac-sha256\" or \"hmac-sha512\".\nThe empty string means \"hmac-sha256\".\n",
+			},
+			"Digest": gui.Fieldinfo{
+				Doc: "Digest sets the Digest header of the request to the base64 encoded SHA-256 hash\nof the body, e.g. to be signed via {header:Digest}.\n",
+			},
+			"Encoding": gui.Fieldinfo{
+				Doc: "Encoding of the signature: \"base64\", \"base64url\" or \"hex\". The empty string\nmeans \"base64\".\n",
+			},
+			"Format": gui.Fieldinfo{
+				Doc: "Format is the template of the header value. The empty string means\n\"{signature}\".\n",
+			},
+			"Header": gui.Fieldinfo{
+				Doc: "Header is the request header which receives the signature. The empty string\nmeans \"X-Signature\".\n",
+			},
+			"Key": gui.Fieldinfo{
+				Doc: "Key is the secret key. It typically references a variable like \"{{API_SECRET}}\".\nKeys of the form \"base64:<data>\" are base64 decoded.\n",
+			},
+			"StringToSign": gui.Fieldinfo{
+				Doc: "StringToSign is the template of the string to sign. The empty string means\n\"{method}\\n{uri}\\n{date}\\n{body-sha256}\".\n",
+			}}})
+
 	gui.RegisterType(ht.Response{}, gui.Typeinfo{
 		Doc: "Response captures information about a http response.\n",
 		Field: map[string]gui.Fieldinfo{
 			"BodyErr": gui.Fieldinfo{
 				Doc: "",
 			},
+			"BodySHA256": gui.Fieldinfo{
+				Doc: "",
+			},
+			"BodySize": gui.Fieldinfo{
+				Doc: "BodySize is the size of the whole body. It exceeds len(BodyStr) if the body was\ntruncated to Request.MaxBodySize in which case BodyErr is ErrBodyTruncated and\nBodySHA256 is the hex encoded SHA-256 hash of the whole body.\n",
+			},
 			"BodyStr": gui.Fieldinfo{
 				Doc: "The received body and the error got while reading it.\n",
 			},
 			"Duration": gui.Fieldinfo{
 				Doc: "Duration to receive response and read the whole body.\n",
 			},
+			"Charset": gui.Fieldinfo{
+				Doc: "Charset is the original charset of a body which was transcoded to UTF-8.\nBodies are transcoded if the response declares a charset other than UTF-8 or\nby a \"charset\" Transformation. Charset is empty if the body was not\ntranscoded.\n",
+			},
 			"Redirections": gui.Fieldinfo{
 				Doc: "Redirections records the URLs of automatic GET requests due to redirects.\n",
 			},
+			"Resources": gui.Fieldinfo{
+				Doc: "Resources are the resources of a HTML page loaded due to Test.LoadResources.\n",
+			},
 			"Response": gui.Fieldinfo{
 				Doc: "Response is the received HTTP response. Its body has bean read and closed\nalready.\n",
 			}}})
@@ -677,6 +902,102 @@ func init() {
 		Doc:   "ExtractorMap is a map of Extractors with the sole purpose of attaching JSON\n(un)marshaling methods.\n",
 		Field: map[string]gui.Fieldinfo{}})
 
+	gui.RegisterType(ht.Hooks{}, gui.Typeinfo{
+		Doc: "Hooks are called at certain points while a Test is run, e.g. to sign the\nrequest, to decrypt the response or to collect diagnostic data once the test\nfailed.\n\nEach hook is either a Go function (when ht is used as a library) or a script (in\ntest files) or both in which case the function is called first. A failing\nPreRequest or PostResponse hook results in a test status of Error.\n",
+		Field: map[string]gui.Fieldinfo{
+			"OnFailure": gui.Fieldinfo{
+				Doc: "OnFailure is called once after the last try if the test's status is Fail or\nError.\n",
+			},
+			"PostResponse": gui.Fieldinfo{
+				Doc: "PostResponse is called after each response has been received and before the\nchecks are executed.\n",
+			},
+			"PreRequest": gui.Fieldinfo{
+				Doc: "PreRequest is called before each request is sent. The request is available in\nTest.Request.Request.\n",
+			}}})
+
+	gui.RegisterType(ht.HookScript{}, gui.Typeinfo{
+		Doc: "HookScript is a hook implemented by an external program.\n",
+		Field: map[string]gui.Fieldinfo{
+			"Args": gui.Fieldinfo{
+				Doc: "Command is the program to execute and Args its arguments.\n",
+			},
+			"Command": gui.Fieldinfo{
+				Doc: "Command is the program to execute and Args its arguments.\n",
+			},
+			"Timeout": gui.Fieldinfo{
+				Doc: "Timeout limits the runtime of the program. Zero means DefaultExternalTimeout.\n",
+			}}})
+
+	gui.RegisterType(ht.Transformation{}, gui.Typeinfo{
+		Doc: "Transformation is one step in the transformation of the response body before the\nchecks and extractors see it, e.g. to decrypt an encrypted envelope. The\nfollowing Kinds of transformation are available:\n\n    base64   decode standard or URL base64 (padding optional)\n    gunzip   decompress gzip data\n    latin1   convert ISO-8859-1 to UTF-8\n    charset  convert the charset declared in the Content-Type header\n             or a HTML meta tag to UTF-8, see Response.Charset\n    aes-gcm  decrypt AES-GCM: a 12 byte nonce followed by the\n             ciphertext and the tag\n    jwe      decrypt a JWE in compact serialization with alg \"dir\",\n             \"A128KW\", \"A192KW\" or \"A256KW\" and enc \"A128GCM\",\n             \"A192GCM\", \"A256GCM\", \"A128CBC-HS256\", \"A192CBC-HS384\"\n             or \"A256CBC-HS512\"\n\nThe transformations of a test are applied in order, e.g. a base64 encoded,\ngzipped payload is decoded by\n\n    Transform: [ {Kind: \"base64\"}, {Kind: \"gunzip\"} ]\n",
+		Field: map[string]gui.Fieldinfo{
+			"Element": gui.Fieldinfo{
+				Doc: "Element selects the string value of this element of a JSON body (in the syntax\nof the JSON check with a \".\" as separator) as the input to the transformation.\nAn empty Element uses the whole body.\n",
+			},
+			"Key": gui.Fieldinfo{
+				Doc: "Key is the base64 encoded key for aes-gcm and jwe. It typically references a\nvariable like \"{{API_KEY}}\".\n",
+			},
+			"Kind": gui.Fieldinfo{
+				Doc: "Kind of transformation.\n",
+			}}})
+
+	gui.RegisterType(ht.ResourceLoading{}, gui.Typeinfo{
+		Doc: "ResourceLoading makes a test load the resources referenced by a HTML page like\na browser does: After the page has been received, all its stylesheets, scripts,\nimages etc. are fetched concurrently (reusing the connections and the cookies\nof the test). The results are recorded in Response.Resources and can be checked\nwith the Assets check.\n\nThe following Types of resources are available:\n\n    stylesheet  <link rel=\"stylesheet\" href=\"...\">\n    script      <script src=\"...\">\n    image       <img src=\"...\">, <link rel=\"icon\" href=\"...\">\n    media       <video src=\"...\">, <audio src=\"...\">, <source src=\"...\">\n    iframe      <iframe src=\"...\">\n\nResources are loaded only for HTML pages.\n",
+		Field: map[string]gui.Fieldinfo{
+			"Concurrency": gui.Fieldinfo{
+				Doc: "Concurrency is the number of resources loaded concurrently. Zero means\nDefaultResourceConcurrency.\n",
+			},
+			"Ignore": gui.Fieldinfo{
+				Doc: "Ignore resources whose URL fulfills one of these conditions.\n",
+			},
+			"MaxResources": gui.Fieldinfo{
+				Doc: "MaxResources limits the number of loaded resources. Zero means\nDefaultMaxResources.\n",
+			},
+			"Timeout": gui.Fieldinfo{
+				Doc: "Timeout for loading one resource. Zero means the timeout of the request.\n",
+			},
+			"Types": gui.Fieldinfo{
+				Doc: "Types of resources to load. An empty Types loads stylesheets, scripts and\nimages.\n",
+			}}})
+	gui.RegisterType(ht.WireLog{}, gui.Typeinfo{
+		Doc: "WireLog dumps the request and the response of each try of a test as sent and\nreceived over the wire to a file, one file per execution of a test. The values\nof the headers in RedactedHeaders and in Redact are replaced by \"<redacted>\"\nand all secret values (see scope.MarkSecret) are masked.\n",
+		Field: map[string]gui.Fieldinfo{
+			"Dir": gui.Fieldinfo{
+				Doc: "Dir is the directory the files are written to. The files are named after a\nrunning number unique in Dir, the sequence number and the name of the test,\ne.g. \"0007_Main-03_Login_as_admin.wire\". The running number restarts with each\nrun (see ResetWireLogs).\n",
+			},
+			"MaxBody": gui.Fieldinfo{
+				Doc: "MaxBody limits the number of bytes dumped of each request and response body.\nZero means DefaultWireLogMaxBody, a negative value dumps the full bodies.\n",
+			},
+			"MaxFiles": gui.Fieldinfo{
+				Doc: "MaxFiles limits the number of files written to Dir during one run, e.g. during\na load test. Zero means no limit.\n",
+			},
+			"Redact": gui.Fieldinfo{
+				Doc: "Redact lists additional headers to redact like \"X-Api-Key\".\n",
+			}}})
+	gui.RegisterType(ht.Resource{}, gui.Typeinfo{
+		Doc: "Resource is a loaded resource of a HTML page.\n",
+		Field: map[string]gui.Fieldinfo{
+			"ContentType": gui.Fieldinfo{
+				Doc: "",
+			},
+			"Duration": gui.Fieldinfo{
+				Doc: "",
+			},
+			"Error": gui.Fieldinfo{
+				Doc: "",
+			},
+			"Size": gui.Fieldinfo{
+				Doc: "",
+			},
+			"StatusCode": gui.Fieldinfo{
+				Doc: "",
+			},
+			"Type": gui.Fieldinfo{
+				Doc: "",
+			},
+			"URL": gui.Fieldinfo{
+				Doc: "",
+			}}})
 	gui.RegisterType(ht.Extraction{}, gui.Typeinfo{
 		Doc: "Extraction captures the result of a variable extraction.\n",
 		Field: map[string]gui.Fieldinfo{
@@ -690,6 +1011,18 @@ func init() {
 	gui.RegisterType(ht.Execution{}, gui.Typeinfo{
 		Doc: "Execution contains parameters controlling the test execution.\n",
 		Field: map[string]gui.Fieldinfo{
+			"CheckConcurrency": gui.Fieldinfo{
+				Doc: "CheckConcurrency is the number of checks executed concurrently. Zero and one\nexecute the checks sequentially. Concurrent execution pays off for heavy checks\nlike Image, Links or ValidHTML.\n",
+			},
+			"FreshConnection": gui.Fieldinfo{
+				Doc: "FreshCookies, FreshConnection and FreshVariables isolate the tries of a test\n(e.g. when polling an endpoint) from each other: FreshCookies removes the\ncookies stored in the Jar during a failed try before retrying, FreshConnection\ncloses the connection after each try so that a retry is sent over a newly\nestablished connection and FreshVariables generates new values for variables\nlike RANDOM or NOW for each retry (see Test.Renew).\n",
+			},
+			"FreshCookies": gui.Fieldinfo{
+				Doc: "FreshCookies, FreshConnection and FreshVariables isolate the tries of a test\n(e.g. when polling an endpoint) from each other: FreshCookies removes the\ncookies stored in the Jar during a failed try before retrying, FreshConnection\ncloses the connection after each try so that a retry is sent over a newly\nestablished connection and FreshVariables generates new values for variables\nlike RANDOM or NOW for each retry (see Test.Renew).\n",
+			},
+			"FreshVariables": gui.Fieldinfo{
+				Doc: "FreshCookies, FreshConnection and FreshVariables isolate the tries of a test\n(e.g. when polling an endpoint) from each other: FreshCookies removes the\ncookies stored in the Jar during a failed try before retrying, FreshConnection\ncloses the connection after each try so that a retry is sent over a newly\nestablished connection and FreshVariables generates new values for variables\nlike RANDOM or NOW for each retry (see Test.Renew).\n",
+			},
 			"InterSleep": gui.Fieldinfo{
 				Doc: "Pre-, Inter- and PostSleep are the sleep durations made before the request,\nbetween request and the checks and after the checks.\n",
 			},
@@ -722,6 +1055,12 @@ func init() {
 	gui.RegisterType(ht.Condition{}, gui.Typeinfo{
 		Doc: "Condition is a conjunction of tests against a string. Note that Contains and\nRegexp conditions both use the same Count; most likely one would use either\nContains or Regexp but not both.\n",
 		Field: map[string]gui.Fieldinfo{
+			"After": gui.Fieldinfo{
+				Doc: "Before and After are exclusive upper and lower bounds on the time value of the\nstring. The (dequoted) string as well as Before and After are parsed with Time\nas the layout or as RFC 3339 if Time is empty. If the string is not a valid time\nthese conditions fail.\n",
+			},
+			"Before": gui.Fieldinfo{
+				Doc: "Before and After are exclusive upper and lower bounds on the time value of the\nstring. The (dequoted) string as well as Before and After are parsed with Time\nas the layout or as RFC 3339 if Time is empty. If the string is not a valid time\nthese conditions fail.\n",
+			},
 			"Contains": gui.Fieldinfo{
 				Doc: "Contains must be contained in the string.\n",
 			},
@@ -731,9 +1070,15 @@ func init() {
 			"Equals": gui.Fieldinfo{
 				Doc: "Equals is the exact value to be expected. No other tests are performed if Equals\nis non-zero as these other tests would be redundant.\n",
 			},
+			"Glob": gui.Fieldinfo{
+				Doc: "Glob is a shell-style pattern the whole string must match:\n\n    *      matches any sequence of characters\n    ?      matches any single character\n    [a-z]  matches one character from the class, [!a-z] one not\n           from the class\n    \\*     matches a literal *\n\nGlob is often simpler than Regexp, e.g. for \"order-*.pdf\".\n",
+			},
 			"GreaterThan": gui.Fieldinfo{
 				Doc: "GreaterThan and LessThan are lower and upper bound on the numerical value of the\nstring: The string is trimmed from spaces as well as from single and double\nquotes before parsed as a float64. If the string is not float value these\nconditions fail. Nil disables these conditions.\n",
 			},
+			"IgnoreCase": gui.Fieldinfo{
+				Doc: "IgnoreCase makes Equals, Prefix, Suffix, Contains and Regexp case-insensitive.\n",
+			},
 			"Is": gui.Fieldinfo{
 				Doc: "Is checks whether the string under test matches one of a given list of given\ntypes. Double quotes are trimmed from the string before validation its type.\n\nThe following types are available:\n\n    Alpha          Alphanumeric  ASCII             Base64\n    CIDR           CreditCard    DataURI           DialString\n    DNSName        Email         FilePath          Float\n    FullWidth      HalfWidth     Hexadecimal       Hexcolor\n    Host           Int           IP                IPv4\n    IPv6           ISBN10        ISBN13            ISO3166Alpha2\n    ISO3166Alpha3  JSON          Latitude          Longitude\n    LowerCase      MAC           MongoID           Multibyte\n    Null           Numeric       Port              PrintableASCII\n    RequestURI     RequestURL    RFC3339           RGBcolor\n    Semver         SSN           UpperCase         URL\n    UTFDigit       UTFLetter     UTFLetterNumeric  UTFNumeric\n    UUID           UUIDv3        UUIDv4            UUIDv5\n    VariableWidth\n\nSee github.com/asaskevich/govalidator for a detailed description.\n\nThe string \"OR\" is ignored an can be used to increase the readability of this\ncondition in situations like\n\n    Condition{Is: \"Hexcolor OR RGBColor OR MongoID\"}\n",
 			},
@@ -746,6 +1091,12 @@ func init() {
 			"Min": gui.Fieldinfo{
 				Doc: "Min and Max are the minimum and maximum length the string may have. Two zero\nvalues disables this test.\n",
 			},
+			"Normalize": gui.Fieldinfo{
+				Doc: "Normalize the string as well as Equals, Prefix, Suffix and Contains before\ncomparing them: The strings are brought to Unicode normalization form C, runs of\nwhite space (including non-breaking spaces) are collapsed to a single space and\nleading and trailing white space is removed.\n",
+			},
+			"OneOf": gui.Fieldinfo{
+				Doc: "OneOf is a list of allowed values: The string must equal one of them. An empty\nlist disables this test.\n",
+			},
 			"Prefix": gui.Fieldinfo{
 				Doc: "Prefix is the required prefix\n",
 			},
@@ -755,6 +1106,9 @@ func init() {
 			"Suffix": gui.Fieldinfo{
 				Doc: "Suffix is the required suffix.\n",
 			},
+			"Tolerance": gui.Fieldinfo{
+				Doc: "Tolerance turns Equals into a numerical comparison: The string and Equals are\nparsed as float64 (trimmed like for GreaterThan) and may differ by at most\nTolerance. A zero Tolerance compares numbers exactly so that \"5\" equals \"5.0\".\nNil compares Equals literally.\n",
+			},
 			"Time": gui.Fieldinfo{
 				Doc: "Time checks whether the string is a valid time if parsed with Time as the layout\nstring.\n",
 			}}})
@@ -0,0 +1,134 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/vdobler/ht/gui"
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/internal/hjson"
+	"github.com/vdobler/ht/scope"
+)
+
+// ----------------------------------------------------------------------------
+// Diff view
+
+// diffHandler shows the changes of the current test relative to the file it
+// was loaded from. If the parameters a and b are given, the two Hjson or
+// JSON files are compared instead, e.g. two variables.json result files.
+func diffHandler(val *gui.Value, source *guiSource) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		data := diffData{
+			A: strings.TrimSpace(req.FormValue("a")),
+			B: strings.TrimSpace(req.FormValue("b")),
+		}
+		var err error
+		if data.A != "" || data.B != "" {
+			data.Old, data.New = data.A, data.B
+			data.Changes, err = diffFiles(data.A, data.B)
+		} else {
+			data.Old, data.New = source.filename(), "current test"
+			data.Changes, err = diffOriginal(val, source)
+		}
+		if err != nil {
+			data.Error = err.Error()
+		}
+
+		buf := &bytes.Buffer{}
+		if err := diffTmpl.Execute(buf, data); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(200)
+		w.Write([]byte(scope.Mask(buf.String())))
+	}
+}
+
+type diffData struct {
+	A, B     string // A and B are the files to compare
+	Old, New string // Old and New describe the compared objects
+	Changes  []gui.Change
+	Error    string
+}
+
+// diffOriginal compares the current test in val with its on-disk original.
+func diffOriginal(val *gui.Value, source *guiSource) ([]gui.Change, error) {
+	if source.name == "" {
+		return nil, fmt.Errorf("test was not loaded from or saved to a file")
+	}
+	orig, err := (&guiSource{jar: source.jar}).load(source.name)
+	if err != nil {
+		return nil, err
+	}
+	current := val.Current.(ht.Test)
+	for _, t := range []*ht.Test{orig, &current} {
+		t.Response = ht.Response{}
+		t.Result = ht.Result{}
+	}
+	return gui.Diff(*orig, current, "Test"), nil
+}
+
+// diffFiles compares the two Hjson or JSON files a and b.
+func diffFiles(a, b string) ([]gui.Change, error) {
+	soups := make([]interface{}, 2)
+	for i, name := range []string{a, b} {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		if err := hjson.Unmarshal(data, &soups[i]); err != nil {
+			return nil, fmt.Errorf("cannot parse %s: %s", name, err)
+		}
+	}
+	return gui.Diff(soups[0], soups[1], "File"), nil
+}
+
+var diffTmpl = template.Must(template.New("diff").Parse(`<!doctype html>
+<html>
+<head>
+  <meta charset="UTF-8">
+  <title>Changes</title>
+  <style>
+body { margin: 40px; font-family: sans-serif; }
+table.diff td, table.diff th { border-bottom: 1px solid #ccc; padding: 2px 8px; text-align: left; vertical-align: top; }
+table.diff td { font-family: monospace; white-space: pre-wrap; }
+tr.added td.new { background-color: #CCFFCC; }
+tr.removed td.old { background-color: #FFCCCC; }
+tr.changed td.old { background-color: #FFE4CC; }
+tr.changed td.new { background-color: #E4FFCC; }
+p.error { color: red; }
+  </style>
+</head>
+<body>
+  <h1>Changes</h1>
+  <p><a href="/">Back to test</a></p>
+  <form action="/diff" method="get">
+    Compare file <input type="text" name="a" value="{{.A}}" size="40">
+    with <input type="text" name="b" value="{{.B}}" size="40">
+    <button>Compare</button>
+  </form>
+  <h2>{{.Old}} &rarr; {{.New}}</h2>
+{{if .Error}}
+  <p class="error">{{.Error}}</p>
+{{else if .Changes}}
+  <table class="diff">
+    <tr><th>Field</th><th>Old</th><th>New</th></tr>
+  {{range .Changes}}
+    <tr class="{{.Kind}}"><th>{{if eq $.A ""}}<a href="/#{{.Path}}">{{.Path}}</a>{{else}}{{.Path}}{{end}}</th><td class="old">{{.Old}}</td><td class="new">{{.New}}</td></tr>
+  {{end}}
+  </table>
+{{else}}
+  <p>No changes.</p>
+{{end}}
+</body>
+</html>
+`))
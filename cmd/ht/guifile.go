@@ -0,0 +1,338 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/vdobler/ht/cookiejar"
+	"github.com/vdobler/ht/gui"
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/internal/hjson"
+	"github.com/vdobler/ht/scope"
+	"github.com/vdobler/ht/suite"
+)
+
+// ----------------------------------------------------------------------------
+// Loading and saving tests
+
+// guiSource keeps track of the file the test in the GUI was loaded from.
+type guiSource struct {
+	raw  *suite.RawTest // raw is nil for tests not loaded from a file
+	jar  *cookiejar.Jar
+	name string // name of the file last loaded or saved
+}
+
+// filename of the last loaded or saved test or a sensible default.
+func (src *guiSource) filename() string {
+	if src.name == "" {
+		return "test.ht"
+	}
+	return src.name
+}
+
+// load reads the test from filename.
+func (src *guiSource) load(filename string) (*ht.Test, error) {
+	rt, err := suite.LoadRawTest(filename, nil)
+	if err != nil {
+		return nil, err
+	}
+	test, err := src.toTest(rt)
+	if err != nil {
+		return nil, err
+	}
+	src.raw, src.name = rt, filename
+	return test, nil
+}
+
+// toTest converts rt to a Test using the variables from the command line.
+func (src *guiSource) toTest(rt *suite.RawTest) (*ht.Test, error) {
+	testScope := scope.New(scope.Variables(variablesFlag), rt.Variables, false)
+	testScope["TEST_DIR"] = rt.File.Dirname()
+	testScope["TEST_NAME"] = rt.File.Basename()
+	test, err := rt.ToTest(testScope)
+	if err != nil {
+		return nil, err
+	}
+	test.SetMetadata("Filename", rt.File.Name)
+	if u, err := url.Parse(test.Request.URL); err == nil {
+		test.PopulateCookies(src.jar, u)
+	}
+	return test, nil
+}
+
+// save writes test in Hjson format to filename. The Variables and Mixin
+// blocks of the file the test was loaded from are kept and everything
+// provided by the mixins is dropped from the written test.
+func (src *guiSource) save(test ht.Test, filename string) error {
+	data, err := src.testFileData(test)
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(filename, data, 0666); err != nil {
+		return err
+	}
+	src.name = filename
+	return nil
+}
+
+// builtinVars are variables set while loading a test which are not
+// written to a test file.
+var builtinVars = map[string]bool{"TEST_DIR": true, "TEST_NAME": true}
+
+// testFileData produces the content of a test file for test. If the test
+// was loaded from a file that file is updated: Its Variables and Mixin
+// blocks are kept as they are (so secrets stay references like
+// "@secret:env:PASSWORD") and unchanged parts keep their comments and
+// formatting. Variables not defined in the file are added unless they are
+// secret; secret values are never written.
+func (src *guiSource) testFileData(test ht.Test) ([]byte, error) {
+	data, err := json.Marshal(test)
+	if err != nil {
+		return nil, err
+	}
+	var s interface{}
+	if err = hjson.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	soup := s.(map[string]interface{})
+	delete(soup, "Response")
+	delete(soup, "Result")
+	fixDuration(soup)
+	soup, err = invertVars(soup, test.Variables)
+	if err != nil {
+		return nil, err
+	}
+	delete(soup, "Variables")
+
+	file := "{\n}\n"
+	if src.raw != nil {
+		file = src.raw.File.Data
+	}
+	doc, err := hjson.ParseDocument([]byte(file))
+	if err != nil {
+		return nil, err
+	}
+	if src.raw != nil {
+		for _, mixin := range src.raw.Mixins {
+			var raw map[string]interface{}
+			if err := hjson.Unmarshal([]byte(mixin.File.Data), &raw); err != nil {
+				return nil, err
+			}
+			subtractSoup(soup, raw)
+		}
+		for _, key := range []string{"Mixin", "Variables"} {
+			if n := doc.Root.Get(key); n != nil {
+				soup[key] = n.Interface()
+			}
+		}
+	}
+	pruneSoup(soup)
+	if _, err := updateNode(doc.Root, soup); err != nil {
+		return nil, err
+	}
+
+	// Add the variables not defined in the file. For a loaded test only
+	// the ones used in the test are added as test.Variables contains
+	// all variables, e.g. the ones from the command line.
+	marshaled := string(doc.Bytes())
+	vars := doc.Root.Get("Variables")
+	names := make([]string, 0, len(test.Variables))
+	for name := range test.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := test.Variables[name]
+		if builtinVars[name] || scope.IsSecret(value) ||
+			(vars != nil && vars.Get(name) != nil) ||
+			(src.raw != nil && !strings.Contains(marshaled, "{{"+name+"}}")) {
+			continue
+		}
+		if vars == nil {
+			if err := doc.Root.Set("Variables", map[string]interface{}{}); err != nil {
+				return nil, err
+			}
+			vars = doc.Root.Get("Variables")
+		}
+		if err := vars.Set(name, value); err != nil {
+			return nil, err
+		}
+	}
+
+	if src.raw == nil {
+		reorder(doc.Root, reflect.TypeOf(testFile{}))
+		data = doc.Format()
+	} else {
+		data = doc.Bytes()
+	}
+	if scope.Mask(string(data)) != string(data) {
+		return nil, errors.New("test contains secret values which are not written to disk")
+	}
+	return data, nil
+}
+
+// updateNode updates n to the value v (as produced by hjson.Unmarshal)
+// keeping everything in n which does not change. It reports false if n
+// cannot be updated but must be replaced by v.
+func updateNode(n *hjson.Node, v interface{}) (bool, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if n.Kind != hjson.Object {
+			return false, nil
+		}
+		for _, key := range n.Keys() {
+			if _, ok := v[key]; !ok {
+				n.Delete(key)
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if elem := n.Get(key); elem != nil {
+				ok, err := updateNode(elem, v[key])
+				if err != nil {
+					return false, err
+				}
+				if ok {
+					continue
+				}
+			}
+			if err := n.Set(key, v[key]); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case []interface{}:
+		if n.Kind != hjson.Array || n.Len() != len(v) {
+			return false, nil
+		}
+		for i, elem := range v {
+			ok, err := updateNode(n.Index(i), elem)
+			if err != nil {
+				return false, err
+			}
+			if !ok {
+				if err := n.SetIndex(i, elem); err != nil {
+					return false, err
+				}
+			}
+		}
+		return true, nil
+	}
+	return n.Kind == hjson.Scalar && fmt.Sprint(n.Interface()) == fmt.Sprint(v), nil
+}
+
+// subtractSoup removes everything from soup which is provided by mixin:
+// Equal values are deleted, elements of lists are removed if mixin's list
+// contains them and maps are subtracted recursively.
+func subtractSoup(soup, mixin map[string]interface{}) {
+	for key, mv := range mixin {
+		if key == "Name" || key == "Description" {
+			continue // Not merged but taken from the test.
+		}
+		sv, ok := soup[key]
+		if !ok {
+			continue
+		}
+		switch sv := sv.(type) {
+		case map[string]interface{}:
+			if mv, ok := mv.(map[string]interface{}); ok {
+				subtractSoup(sv, mv)
+				if len(sv) == 0 {
+					delete(soup, key)
+				}
+			}
+		case []interface{}:
+			list, ok := mv.([]interface{})
+			if !ok {
+				list = []interface{}{mv} // e.g. a single header value
+			}
+			rest := []interface{}{}
+			for _, elem := range sv {
+				if !containsElement(list, elem) {
+					rest = append(rest, elem)
+				}
+			}
+			soup[key] = rest
+		default:
+			if fmt.Sprint(sv) == fmt.Sprint(mv) {
+				delete(soup, key)
+			}
+		}
+	}
+}
+
+func containsElement(list []interface{}, elem interface{}) bool {
+	for _, e := range list {
+		if reflect.DeepEqual(e, elem) || fmt.Sprint(e) == fmt.Sprint(elem) {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneSoup drops nil values and empty maps and lists from soup.
+func pruneSoup(soup map[string]interface{}) {
+	for key, value := range soup {
+		switch v := value.(type) {
+		case nil:
+			delete(soup, key)
+		case map[string]interface{}:
+			pruneSoup(v)
+			if len(v) == 0 {
+				delete(soup, key)
+			}
+		case []interface{}:
+			if len(v) == 0 {
+				delete(soup, key)
+			}
+		}
+	}
+}
+
+// loadTest replaces the current test in val with the one from filename.
+func loadTest(val *gui.Value, src *guiSource, filename string) {
+	test, err := src.load(filename)
+	if err != nil {
+		val.Messages["Test"] = []gui.Message{{
+			Type: "error",
+			Text: fmt.Sprintf("Cannot load %s: %s", filename, err),
+		}}
+		return
+	}
+	val.PushCurrent()
+	val.Current = *test
+	val.Messages["Test"] = []gui.Message{{
+		Type: "info",
+		Text: "Loaded test from " + filename,
+	}}
+}
+
+// saveTest writes the current test in val to filename.
+func saveTest(val *gui.Value, src *guiSource, filename string) {
+	err := src.save(val.Current.(ht.Test), filename)
+	if err != nil {
+		val.Messages["Test"] = []gui.Message{{
+			Type: "error",
+			Text: fmt.Sprintf("Cannot save to %s: %s", filename, err),
+		}}
+		return
+	}
+	val.Messages["Test"] = []gui.Message{{
+		Type: "info",
+		Text: "Saved test to " + filename,
+	}}
+}
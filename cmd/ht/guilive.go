@@ -0,0 +1,241 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/vdobler/ht/gui"
+	"github.com/vdobler/ht/ht"
+)
+
+// ----------------------------------------------------------------------------
+// Live execution
+
+// liveEvent is a single server-sent event of a live execution.
+type liveEvent struct {
+	Name string // Name is the event type: log, check, status or done
+	Data string
+}
+
+// liveExecution runs the current test in the background and records the
+// progress as a sequence of events which can be streamed to the browser.
+type liveExecution struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	events  []liveEvent
+	running bool
+}
+
+func newLiveExecution() *liveExecution {
+	live := &liveExecution{}
+	live.cond = sync.NewCond(&live.mu)
+	return live
+}
+
+// checkEvent is the data of a check event.
+type checkEvent struct {
+	Path   string
+	Status string
+	Text   string
+}
+
+func (live *liveExecution) emit(name, data string) {
+	live.mu.Lock()
+	live.events = append(live.events, liveEvent{Name: name, Data: data})
+	live.mu.Unlock()
+	live.cond.Broadcast()
+}
+
+func (live *liveExecution) emitJSON(name string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data = []byte(fmt.Sprintf("%q", err.Error()))
+	}
+	live.emit(name, string(data))
+}
+
+// Printf makes live usable as the logger of a ht.Test.
+func (live *liveExecution) Printf(format string, a ...interface{}) {
+	live.emit("log", strings.TrimSpace(fmt.Sprintf(format, a...)))
+}
+
+// isRunning reports whether a live execution is in progress.
+func (live *liveExecution) isRunning() bool {
+	live.mu.Lock()
+	defer live.mu.Unlock()
+	return live.running
+}
+
+// start executes the current test of val in the background. Only one
+// execution may run at a time. The caller must hold guiMu.
+func (live *liveExecution) start(val *gui.Value) error {
+	live.mu.Lock()
+	defer live.mu.Unlock()
+	if live.running {
+		return errors.New("test is already running")
+	}
+	live.running = true
+	live.events = nil
+	val.PushCurrent()
+	test := val.Current.(ht.Test)
+	go live.run(test, val)
+	return nil
+}
+
+func (live *liveExecution) run(test ht.Test, val *gui.Value) {
+	defer func() {
+		live.mu.Lock()
+		live.running = false
+		live.mu.Unlock()
+		live.cond.Broadcast()
+	}()
+
+	// Log with at least debug verbosity to report each check as it executes.
+	log, verbosity := test.Log, test.Execution.Verbosity
+	test.Log = live
+	if verbosity < 2 {
+		test.Execution.Verbosity = 2
+	}
+	test.Run()
+	test.Log, test.Execution.Verbosity = log, verbosity
+
+	if test.Response.Response != nil {
+		test.Response.Response.Request = nil
+		test.Response.Response.TLS = nil
+	}
+	for i, cr := range test.Result.CheckResults {
+		text := cr.Status.String()
+		if len(cr.Error) != 0 {
+			text = cr.Error.Error()
+		}
+		live.emitJSON("check", checkEvent{
+			Path:   fmt.Sprintf("Test.Checks.%d", i),
+			Status: strings.ToLower(cr.Status.String()),
+			Text:   text,
+		})
+	}
+	text := test.Result.Status.String()
+	if test.Result.Error != nil {
+		text += ": " + test.Result.Error.Error()
+	}
+	live.emitJSON("status", checkEvent{
+		Path:   "Test",
+		Status: strings.ToLower(test.Result.Status.String()),
+		Text:   text,
+	})
+
+	test.Extract()
+	guiMu.Lock()
+	augmentMessages(&test, val)
+	val.Current = test
+	guiMu.Unlock()
+	live.emit("done", "")
+}
+
+// eventsHandler streams the events of the current (or last) live execution
+// as server-sent events. The stream ends once the execution is done.
+func eventsHandler(live *liveExecution) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming unsupported", 500)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(200)
+		flusher.Flush()
+
+		for next := 0; ; {
+			live.mu.Lock()
+			for next == len(live.events) && live.running {
+				live.cond.Wait()
+			}
+			events := live.events[next:]
+			running := live.running
+			live.mu.Unlock()
+
+			for _, ev := range events {
+				writeEvent(w, ev)
+			}
+			flusher.Flush()
+			next += len(events)
+			if !running && len(events) == 0 {
+				return
+			}
+		}
+	}
+}
+
+// writeEvent writes ev in the text/event-stream format.
+func writeEvent(w io.Writer, ev liveEvent) {
+	fmt.Fprintf(w, "event: %s\n", ev.Name)
+	for _, line := range strings.Split(ev.Data, "\n") {
+		fmt.Fprintf(w, "data: %s\n", line)
+	}
+	io.WriteString(w, "\n")
+}
+
+// liveJS submits the form for a live execution and displays the streamed
+// progress: Log lines are shown in the progress box and check results are
+// shown inline next to the check definition.
+var liveJS = `
+function guiExecuteLive(button) {
+  var form = button.closest("form");
+  var data = new FormData(form);
+  data.set("action", "live");
+  var box = document.getElementById("liveprogress");
+  box.style.display = "block";
+  box.textContent = "";
+  fetch("/update", {method: "POST", body: data}).then(function(resp) {
+    if (!resp.ok) {
+      resp.text().then(function(t) { box.textContent = t; });
+      return;
+    }
+    var es = new EventSource("/events");
+    es.addEventListener("log", function(ev) {
+      box.textContent += ev.data + "\n";
+      box.scrollTop = box.scrollHeight;
+    });
+    es.addEventListener("check", function(ev) { guiMark(JSON.parse(ev.data)); });
+    es.addEventListener("status", function(ev) {
+      var st = JSON.parse(ev.data);
+      box.textContent += "Result: " + st.Text + "\n";
+      guiMark(st);
+    });
+    es.addEventListener("done", function(ev) {
+      es.close();
+      var link = document.createElement("a");
+      link.href = "/response";
+      link.target = "_blank";
+      link.textContent = "Show response";
+      box.appendChild(link);
+    });
+  });
+}
+
+function guiMark(result) {
+  var row = document.getElementById(result.Path);
+  if (!row) {
+    return;
+  }
+  var cell = row.lastElementChild;
+  var old = cell.querySelectorAll("p.live");
+  for (var i = 0; i < old.length; i++) {
+    old[i].remove();
+  }
+  var p = document.createElement("p");
+  p.className = "live msg-" + result.Status;
+  p.textContent = result.Text;
+  cell.insertBefore(p, cell.firstChild);
+}
+`
@@ -0,0 +1,314 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"html/template"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/vdobler/ht/gui"
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/scope"
+)
+
+// ----------------------------------------------------------------------------
+// Response viewer
+
+// responseHandler displays the response of the current test: The headers are
+// shown in a table and the body is pretty printed and highlighted, rendered
+// as an image or dumped raw or as hex depending on the view parameter.
+func responseHandler(val *gui.Value) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		test := val.Current.(ht.Test)
+		view := req.FormValue("view")
+		if view != "raw" && view != "hex" {
+			view = "pretty"
+		}
+
+		data := responseData{View: view, Response: test.Response}
+		if resp := test.Response.Response; resp != nil {
+			data.Kind = bodyKind(resp.Header.Get("Content-Type"), test.Response.BodyStr)
+			data.Header = sortedHeader(resp.Header)
+			for i := range data.Header {
+				data.Header[i].Value = scope.Mask(data.Header[i].Value)
+			}
+		}
+		// Secrets are masked before escaping and highlighting which
+		// would change their textual representation.
+		body := scope.Mask(test.Response.BodyStr)
+		switch {
+		case view == "hex":
+			data.Body = template.HTML(template.HTMLEscapeString(hex.Dump([]byte(body))))
+		case view == "raw" || data.Kind == "text":
+			data.Body = template.HTML(template.HTMLEscapeString(body))
+		case data.Kind == "json":
+			data.Body = highlightJSON(body)
+		case data.Kind == "xml" || data.Kind == "html":
+			data.Body = highlightMarkup(body, data.Kind == "html")
+		}
+
+		buf := &bytes.Buffer{}
+		if err := responseTmpl.Execute(buf, data); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(200)
+		w.Write([]byte(scope.Mask(buf.String())))
+	}
+}
+
+// responseBodyHandler serves the response body of the current test with
+// its original Content-Type, e.g. to display images inline. The body is
+// sandboxed so that scripts in a HTML response cannot act on the GUI.
+func responseBodyHandler(val *gui.Value) func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		test := val.Current.(ht.Test)
+		if test.Response.Response == nil {
+			http.Error(w, "No response", 404)
+			return
+		}
+		ct := test.Response.Response.Header.Get("Content-Type")
+		if ct == "" {
+			ct = http.DetectContentType([]byte(test.Response.BodyStr))
+		}
+		w.Header().Set("Content-Type", ct)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Content-Security-Policy", "sandbox")
+		io.WriteString(w, scope.Mask(test.Response.BodyStr))
+	}
+}
+
+type responseData struct {
+	View     string // View is one of pretty, raw or hex
+	Kind     string // Kind of the body: json, xml, html, image, text or binary
+	Response ht.Response
+	Header   []headerLine
+	Body     template.HTML
+}
+
+type headerLine struct {
+	Name  string
+	Value string
+}
+
+func sortedHeader(header http.Header) []headerLine {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := []headerLine{}
+	for _, name := range names {
+		for _, value := range header[name] {
+			lines = append(lines, headerLine{Name: name, Value: value})
+		}
+	}
+	return lines
+}
+
+// bodyKind determines how to display body with the given Content-Type.
+func bodyKind(contentType, body string) string {
+	if contentType == "" {
+		contentType = http.DetectContentType([]byte(body))
+	}
+	mt, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case strings.Contains(mt, "json"):
+		return "json"
+	case mt == "text/html" || mt == "application/xhtml+xml":
+		return "html"
+	case strings.Contains(mt, "xml"):
+		return "xml"
+	case strings.HasPrefix(mt, "image/"):
+		return "image"
+	case strings.HasPrefix(mt, "text/") || mt == "application/javascript":
+		return "text"
+	}
+	return "binary"
+}
+
+// highlightJSON pretty prints the JSON in body and marks up object keys,
+// strings and literals. Invalid JSON is returned unchanged.
+func highlightJSON(body string) template.HTML {
+	buf := &bytes.Buffer{}
+	if err := json.Indent(buf, []byte(body), "", "    "); err != nil {
+		return template.HTML(template.HTMLEscapeString(body))
+	}
+	s := buf.String()
+
+	out := &bytes.Buffer{}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+			}
+			j++
+			class := "hl-string"
+			if strings.HasPrefix(strings.TrimLeft(s[j:], " "), ":") {
+				class = "hl-key"
+			}
+			writeSpan(out, class, s[i:j])
+			i = j
+		case c == '-' || (c >= '0' && c <= '9') || c == 't' || c == 'f' || c == 'n':
+			j := i + 1
+			for ; j < len(s) && strings.IndexByte(",]} \n", s[j]) == -1; j++ {
+			}
+			writeSpan(out, "hl-literal", s[i:j])
+			i = j
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return template.HTML(out.String())
+}
+
+// voidElements are HTML elements without content and end tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"param": true, "source": true, "track": true, "wbr": true,
+}
+
+// highlightMarkup pretty prints the XML or HTML document in body and marks
+// up tags, attributes and comments. Bodies which cannot be tokenized are
+// returned unchanged.
+func highlightMarkup(body string, html bool) template.HTML {
+	decoder := xml.NewDecoder(strings.NewReader(body))
+	if html {
+		decoder.Strict = false
+		decoder.Entity = xml.HTMLEntity
+	}
+
+	out := &bytes.Buffer{}
+	depth := 0
+	newline := func() {
+		if out.Len() > 0 {
+			out.WriteByte('\n')
+		}
+		out.WriteString(strings.Repeat("    ", depth))
+	}
+	for {
+		tok, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return template.HTML(template.HTMLEscapeString(body))
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			newline()
+			writeSpan(out, "hl-tag", "<"+qualified(t.Name))
+			for _, attr := range t.Attr {
+				out.WriteByte(' ')
+				writeSpan(out, "hl-attr", qualified(attr.Name))
+				out.WriteByte('=')
+				writeSpan(out, "hl-string", `"`+attr.Value+`"`)
+			}
+			writeSpan(out, "hl-tag", ">")
+			if !html || !voidElements[strings.ToLower(t.Name.Local)] {
+				depth++
+			}
+		case xml.EndElement:
+			if html && voidElements[strings.ToLower(t.Name.Local)] {
+				continue
+			}
+			if depth > 0 {
+				depth--
+			}
+			newline()
+			writeSpan(out, "hl-tag", "</"+qualified(t.Name)+">")
+		case xml.CharData:
+			if text := strings.TrimSpace(string(t)); text != "" {
+				newline()
+				out.WriteString(template.HTMLEscapeString(text))
+			}
+		case xml.Comment:
+			newline()
+			writeSpan(out, "hl-comment", "<!--"+string(t)+"-->")
+		case xml.ProcInst:
+			newline()
+			writeSpan(out, "hl-comment", "<?"+t.Target+" "+string(t.Inst)+"?>")
+		case xml.Directive:
+			newline()
+			writeSpan(out, "hl-comment", "<!"+string(t)+">")
+		}
+	}
+	return template.HTML(out.String())
+}
+
+func qualified(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+func writeSpan(out *bytes.Buffer, class, text string) {
+	out.WriteString(`<span class="` + class + `">`)
+	out.WriteString(template.HTMLEscapeString(text))
+	out.WriteString(`</span>`)
+}
+
+var responseTmpl = template.Must(template.New("response").Parse(`<!doctype html>
+<html>
+<head>
+  <meta charset="UTF-8">
+  <title>Response</title>
+  <style>
+body { margin: 40px; font-family: sans-serif; }
+table.header td, table.header th { border-bottom: 1px solid #ccc; padding: 2px 8px; text-align: left; vertical-align: top; }
+pre.body { background-color: #F8F8F8; padding: 8px; white-space: pre-wrap; }
+span.hl-key { color: darkblue; }
+span.hl-string { color: darkgreen; }
+span.hl-literal { color: darkorange; }
+span.hl-tag { color: purple; }
+span.hl-attr { color: darkblue; }
+span.hl-comment { color: grey; font-style: italic; }
+a.current { font-weight: bold; }
+  </style>
+</head>
+<body>
+  <h1>Response</h1>
+  <p><a href="/#Test.Response">Back to test</a></p>
+{{if .Response.Response}}
+  <p>{{.Response.Response.Proto}} <strong>{{.Response.Response.Status}}</strong>
+    &nbsp; received in {{.Response.Duration}}</p>
+  {{range .Response.Redirections}}<p>Redirected from {{.}}</p>{{end}}
+  <h2>Header</h2>
+  <table class="header">
+  {{range .Header}}<tr><th>{{.Name}}</th><td>{{.Value}}</td></tr>
+  {{end}}</table>
+  <h2>Body</h2>
+  <p>
+    {{if eq .View "pretty"}}<a class="current">Pretty</a>{{else}}<a href="/response?view=pretty">Pretty</a>{{end}} |
+    {{if eq .View "raw"}}<a class="current">Raw</a>{{else}}<a href="/response?view=raw">Raw</a>{{end}} |
+    {{if eq .View "hex"}}<a class="current">Hex</a>{{else}}<a href="/response?view=hex">Hex</a>{{end}}
+    &nbsp; {{len .Response.BodyStr}} bytes
+  </p>
+  {{if .Response.BodyErr}}<p>Error reading body: {{.Response.BodyErr}}</p>{{end}}
+  {{if and (eq .View "pretty") (eq .Kind "image")}}<img src="/response/body" alt="Response body">
+  {{else if and (eq .View "pretty") (eq .Kind "binary")}}<p>Binary body, use the raw or hex view.</p>
+  {{else}}<pre class="body">{{.Body}}</pre>{{end}}
+{{else}}
+  <p>No response yet: Execute the test first.</p>
+{{end}}
+</body>
+</html>
+`))
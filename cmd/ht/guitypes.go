@@ -7,6 +7,7 @@ package main
 import (
 	"net/http"
 	"reflect"
+	"regexp"
 	"sort"
 	"strings"
 
@@ -20,15 +21,23 @@ import (
 func registerGUITypes() {
 	setFieldSpecials(
 		ht.Test{},
-		"Jar,Log",
+		"Jar,Log,AllowedHosts",
 		"Result",
 		"Description",
 	)
 
+	setFieldSpecials(ht.Hooks{}, "PreRequestFunc,PostResponseFunc,OnFailureFunc", "", "")
+	setFieldOnly(ht.Transformation{}, "Kind", "base64,gunzip,latin1,charset,aes-gcm,jwe")
+
 	setFieldSpecials(ht.Request{}, "",
 		"Request,SentBody,SentParams", "Body,SentBody")
 	setFieldOnly(ht.Request{}, "Method", ",GET,POST,HEAD,PUT,DELETE,PATCH")
 	setFieldOnly(ht.Request{}, "ParamsAs", ",URL,body,multipart")
+	setFieldUpload(ht.Request{}, "Body")
+	setFieldValidate(ht.Request{}, "URL", `(https?|file|bash|sql)://\S*`)
+	setFieldSpecials(ht.Signature{}, "", "", "StringToSign")
+	setFieldOnly(ht.Signature{}, "Algorithm", ",hmac-sha1,hmac-sha256,hmac-sha512")
+	setFieldOnly(ht.Signature{}, "Encoding", ",base64,base64url,hex")
 
 	setFieldSpecials(
 		http.Request{},
@@ -94,6 +103,22 @@ func setFieldAny(t interface{}, field, values string) {
 	ti.Field[field] = fi
 }
 
+func setFieldValidate(t interface{}, field, pattern string) {
+	typ := reflect.TypeOf(t)
+	ti := gui.Typedata[typ]
+	fi := ti.Field[field]
+	fi.Validate = regexp.MustCompile(pattern)
+	ti.Field[field] = fi
+}
+
+func setFieldUpload(t interface{}, field string) {
+	typ := reflect.TypeOf(t)
+	ti := gui.Typedata[typ]
+	fi := ti.Field[field]
+	fi.Upload = true
+	ti.Field[field] = fi
+}
+
 func setFieldSpecials(t interface{}, omit, readonly, multiline string) {
 	typ := reflect.TypeOf(t)
 	ti := gui.Typedata[typ]
@@ -25,6 +25,8 @@ The available help topics are:
     checks       displays the list of builtin checks
     extractors   displays the builtin variable extractors
     archive      explains archive files
+    secrets      explains secret variables
+    plugins      explains third-party checks and extractors
 `,
 }
 
@@ -49,6 +51,12 @@ func runHelp(cmd *Command, args []string) {
 	case "archive", "archives":
 		displayArchiveHelp()
 		os.Exit(0)
+	case "secret", "secrets":
+		displaySecretsHelp()
+		os.Exit(0)
+	case "plugin", "plugins":
+		displayPluginsHelp()
+		os.Exit(0)
 	}
 
 	for _, cmd := range commands {
@@ -117,3 +125,58 @@ The following example demonstrates using this feature:
     $ ht exec some.suite@archive
 `)
 }
+
+func displaySecretsHelp() {
+	fmt.Print(`
+Variables set via -D or -Dfile and the variables of suites, profiles,
+variable files and tests may reference secrets stored outside of the suite
+and test files. Such values are loaded once when the variables are read and
+masked as *** in all output: logs, reports, dumped variables and the GUI.
+Secrets cannot be referenced from files loaded from URLs or git.
+Secret values have the form:
+
+    @secret:env:NAME                   environment variable NAME
+    @secret:file:/path/to/file         content of file (without trailing
+                                       whitespace)
+    @secret:vault:path/to/secret#field field of a HashiCorp Vault secret;
+                                       VAULT_ADDR and VAULT_TOKEN must be set
+    @secret:value:plainvalue           plainvalue itself, just masked
+    @secret:plainvalue                 same, if plainvalue contains no ':'
+
+Example:
+
+    $ ht exec -D 'PASSWORD=@secret:env:APP_PASSWORD' login.suite
+`)
+}
+
+func displayPluginsHelp() {
+	fmt.Print(`
+Checks and extractors not builtin into ht can be provided in two ways.
+
+The External check and the ExternalExtractor run an arbitrary program which
+receives the executed test as JSON on stdin and prints its verdict as JSON
+to stdout, see 'ht doc External'. A small check written as shell script:
+
+    $ cat price.sh
+    #!/bin/sh
+    if jq -e '.Test.Response.Body | fromjson | .price > 0' >/dev/null; then
+        echo '{"Status": "Pass"}'
+    else
+        echo '{"Status": "Fail", "Message": "price not positive"}'
+    fi
+
+    Checks: [ {Check: "External", Command: "./price.sh"} ]
+
+Go plugins (built with 'go build -buildmode=plugin') listed in the
+environment variable HTPLUGINS (separated like PATH) are loaded during
+startup. Their init functions register their checks and extractors with
+ht.RegisterCheck and ht.RegisterExtractor and may register the
+documentation with gui.RegisterType. Such checks can be used by name in
+all tests and show up in 'ht help checks', 'ht doc' and the GUI:
+
+    $ HTPLUGINS=./pricecheck.so ht exec shop.suite
+
+A plugin must be built with exactly the same version of ht and its
+dependencies as the ht binary loading it.
+`)
+}
@@ -0,0 +1,129 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/vdobler/ht/suite"
+)
+
+var cmdHistory = &Command{
+	RunArgs:     runHistory,
+	Usage:       "history [-n <runs>] [-slower <percent>] <historyfile>",
+	Description: "show trends of results recorded by exec -history",
+	Flag:        flag.NewFlagSet("history", flag.ContinueOnError),
+	Help: `History analyses the results of the last runs recorded with
+'ht exec -history <historyfile>' and reports
+  - newly failing tests: tests which passed in the previous run but not
+    in the last run
+  - newly slow tests: passing tests whose duration in the last run
+    exceeds the median duration of the previous runs by more than the
+    percentage given by -slower
+  - flaky tests: tests which changed between passing and not passing
+    in consecutive runs; the flakiness score is the fraction of such
+    changes (0: stable, 1: changes on every run)
+  - the trend of each test: pass rate, median and last duration and the
+    status in each run ('.' Pass, 'F' Fail, 'E' Error, 'B' Bogus,
+    's' Skipped, ' ' not executed), oldest run first.
+The exit code is 1 if newly failing or newly slow tests were found and 0
+otherwise which allows to use history in CI pipelines:
+    ht exec -history results.jsonl tests/*.suite
+    ht history -n 20 -slower 50 results.jsonl
+`,
+}
+
+var (
+	historyRuns   int
+	historySlower float64
+)
+
+func init() {
+	cmdHistory.Flag.IntVar(&historyRuns, "n", 10,
+		"analyse the last `runs` runs (0: all)")
+	cmdHistory.Flag.Float64Var(&historySlower, "slower", 50,
+		"report tests slower by more than `percent` as newly slow")
+}
+
+func runHistory(cmd *Command, args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: ht", cmd.Usage)
+		os.Exit(9)
+	}
+	entries, err := suite.LoadHistory(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(8)
+	}
+	trends := suite.AnalyseHistory(entries, historyRuns)
+	if printHistory(os.Stdout, trends, historySlower/100) {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// printHistory prints trends and reports whether newly failing or slow
+// tests were found.
+func printHistory(w io.Writer, trends *suite.Trends, slower float64) bool {
+	if len(trends.Runs) == 0 {
+		fmt.Fprintln(w, "No runs recorded.")
+		return false
+	}
+	fmt.Fprintf(w, "Analysed %d runs from %s to %s, last run %s.\n",
+		len(trends.Runs), trends.First.Format("2006-01-02 15:04"),
+		trends.Last.Format("2006-01-02 15:04"), trends.Runs[len(trends.Runs)-1])
+
+	found := false
+	section := func(title string, show func(suite.TestTrend) string) {
+		printed := false
+		for _, tt := range trends.Tests {
+			msg := show(tt)
+			if msg == "" {
+				continue
+			}
+			if !printed {
+				fmt.Fprintf(w, "\n%s:\n", title)
+				printed = true
+			}
+			fmt.Fprintf(w, "  %s: %s\n", tt.Key, msg)
+		}
+	}
+
+	section("Newly failing tests", func(tt suite.TestTrend) string {
+		if !tt.NewlyFailing {
+			return ""
+		}
+		found = true
+		return tt.LastStatus + ", passed in previous run"
+	})
+	section("Newly slow tests", func(tt suite.TestTrend) string {
+		if tt.Slowdown <= slower {
+			return ""
+		}
+		found = true
+		return fmt.Sprintf("%d ms, %.0f%% slower than median %d ms",
+			tt.LastDurationMS, 100*tt.Slowdown, tt.MedianMS)
+	})
+	section("Flaky tests", func(tt suite.TestTrend) string {
+		if tt.Flakiness == 0 {
+			return ""
+		}
+		return fmt.Sprintf("flakiness %.2f  [%s]", tt.Flakiness, tt.Statuses)
+	})
+
+	fmt.Fprintf(w, "\nTrends:\n")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "  Test\tPass Rate\tMedian\tLast\tStatuses")
+	for _, tt := range trends.Tests {
+		fmt.Fprintf(tw, "  %s\t%3.0f%%\t%d ms\t%d ms\t[%s]\n", tt.Key,
+			100*tt.PassRate, tt.MedianMS, tt.LastDurationMS, tt.Statuses)
+	}
+	tw.Flush()
+	return found
+}
@@ -0,0 +1,86 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/vdobler/ht/suite"
+)
+
+var cmdLint = &Command{
+	RunArgs:     runLint,
+	Usage:       "lint <suite>...",
+	Description: "report problems in suites and their tests",
+	Flag:        flag.NewFlagSet("lint", flag.ContinueOnError),
+	Help: `Lint checks the given suites and all tests, mixins and mocks used by
+them for problems which do not prevent execution but are likely mistakes
+or make maintenance harder:
+  - unknown fields, e.g. misspelled ones, and deprecated fields
+  - suite variables which are not used anywhere
+  - variables passed to a test which the test does not use
+  - different tests with the same name in one suite
+  - tests (*.ht) and mixins (*.mix) in the directories of the suites
+    which are not used by any of the suites
+Each problem is reported on its own line. Lint exits with status 1 if
+problems were found which allows to enforce suite hygiene in CI:
+    ht lint tests/...
+`,
+}
+
+func runLint(cmd *Command, args []string) {
+	args = expandTrippleDots(args)
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: ht", cmd.Usage)
+		os.Exit(9)
+	}
+
+	problems := []string{}
+	used := map[string]bool{}
+	dirs := map[string]bool{}
+	for _, arg := range args {
+		fs, arg := filesystemFor(arg)
+		rs, err := suite.LoadRawSuite(arg, fs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot read suite %q: %s\n", arg, err)
+			os.Exit(8)
+		}
+		problems = append(problems, rs.Lint().AsStrings()...)
+		if fs != nil {
+			continue // Unused files are detected in the OS file system only.
+		}
+		for _, name := range rs.Referenced() {
+			used[name] = true
+		}
+		dirs[rs.File.Dirname()] = true
+	}
+
+	for dir := range dirs {
+		for _, pattern := range []string{"*.ht", "*.mix"} {
+			files, _ := filepath.Glob(filepath.Join(filepath.FromSlash(dir), pattern))
+			for _, file := range files {
+				name := path.Clean(filepath.ToSlash(file))
+				if !used[name] {
+					problems = append(problems,
+						fmt.Sprintf("%s: not used by any suite", name))
+				}
+			}
+		}
+	}
+
+	if len(problems) == 0 {
+		os.Exit(0)
+	}
+	sort.Strings(problems)
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	os.Exit(1)
+}
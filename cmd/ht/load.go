@@ -11,6 +11,7 @@ import (
 	"io/ioutil"
 	"log"
 	"math"
+	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
@@ -35,14 +36,95 @@ flag. The desired target rate of requests/seconds (QPS) is set with the
 The results are collected in the folder setable via the -output flag, the
 main performance results are stored to 'live.csv' which can be analysed by
 running 'ht stat <live.csv>'.
+
+Instead of contributing a Percentage of the overall rate a scenario may
+follow its own rate Profile: A list of stages with a Duration and a Rate;
+a stage with Ramp set changes the rate linearly from the previous rate to
+its Rate. The following scenario ramps up to 20 QPS during the first
+minute, keeps this rate for five minutes with a spike of 200 QPS for ten
+seconds in between:
+
+    {
+        File: "user.suite"
+        Profile: [
+            { Duration: "1m",  Rate: 20, Ramp: true }
+            { Duration: "2m",  Rate: 20 }
+            { Duration: "10s", Rate: 200 }
+            { Duration: "3m",  Rate: 20 }
+        ]
+    }
+
+The request durations are summarised per scenario and per test as
+percentiles (p50, p90, p99, p99.9). The load test may declare SLA
+thresholds; a violated SLA makes the load test fail:
+
+    SLA: [
+        "p99 < 800ms"
+        "error rate < 1%"
+        "Login: p99.9 <= 2s"    // restricted to scenario Login
+    ]
+
+To protect the system under test a load test may be aborted early (and
+fail) if the error rate (in percent) or the p99 request duration of the
+requests made during the last Window exceed a limit:
+
+    Abort: { ErrorRate: 5, P99: "2s", Window: "30s" }
+
+A scenario may model real user behaviour by pausing between its tests with
+a ThinkTime and by limiting how fast one iteration of its Main tests may
+be done with Pacing:
+
+    {
+        File: "user.suite"
+        Percentage: 50
+        ThinkTime: { Distribution: "uniform", Min: "1s", Max: "3s" }
+        Pacing: "20s"
+    }
+
+Scenarios which must not share data between their threads (e.g. test
+accounts or order ids) can use a DataPool: A CSV file (first line are the
+field names) or a JSON array of objects. Each thread checks out one
+record exclusively for one iteration of the Main tests; the fields of the
+record are available as variables like {{USER}}. The number of threads of
+such a scenario is limited to the number of records.
+
+    {
+        File: "login.suite"
+        Percentage: 30
+        DataPool: "users.csv"
+    }
+
+A warm-up phase can be run before the actual load test with flag -warmup:
+During the warm-up the full load is generated (the ramp starts at the
+beginning of the warm-up) but requests made during the warm-up are not
+part of the statistics, the collected failures or the SLA evaluation.
+They are still logged to live.csv. The load test runs for the sum of the
+warm-up and the duration.
+
+Live metrics (request rate, error ratio, latency percentiles and number of
+threads per scenario) can be scraped by Prometheus while the load test is
+running (flag -metrics) or pushed to a StatsD server (flag -statsd).
+
+A machine readable summary of the load test (throughput and per test
+latency percentiles and error rates) is written to summary.json in the
+output folder. The summary.json of a previous run can be used as a
+baseline (flag -baseline): The load test fails if a latency (mean, p50,
+p90 or p99) is more than -tolerance percent higher than in the baseline,
+if an error rate increased by more than -tolerance percentage points or
+if the throughput dropped by more than -tolerance percent.
 `,
 }
 
 var queryPerSecond float64
 var testDuration time.Duration
 var rampDuration time.Duration
+var warmupDuration time.Duration
 var collectFrom string
 var maxErrorRate float64
+var metricsAddr string
+var statsdAddr string
+var baselineFile string
+var baselineTolerance float64
 
 func init() {
 	cmdLoad.Flag.Float64Var(&queryPerSecond, "rate", 20,
@@ -51,10 +133,20 @@ func init() {
 		"duration of throughput test")
 	cmdLoad.Flag.DurationVar(&rampDuration, "ramp", 5*time.Second,
 		"ramp duration to reach desired request rate")
+	cmdLoad.Flag.DurationVar(&warmupDuration, "warmup", 0,
+		"run full load for `warmup` before recording statistics")
 	cmdLoad.Flag.StringVar(&collectFrom, "collect", "FAIL",
 		"collect Test with status at least `limit`")
 	cmdLoad.Flag.Float64Var(&maxErrorRate, "errors", 0.9,
 		"abort load test if error rate exceeds `rate`")
+	cmdLoad.Flag.StringVar(&metricsAddr, "metrics", "",
+		"serve live metrics for Prometheus on `addr`/metrics")
+	cmdLoad.Flag.StringVar(&statsdAddr, "statsd", "",
+		"push live metrics to StatsD server at `host:port`")
+	cmdLoad.Flag.StringVar(&baselineFile, "baseline", "",
+		"compare result to baseline summary.json `file`")
+	cmdLoad.Flag.Float64Var(&baselineTolerance, "tolerance", 10,
+		"tolerate regressions to baseline of `percent`")
 	addOutputFlag(cmdLoad.Flag)
 	addVarsFlags(cmdLoad.Flag)
 	addSeedFlag(cmdLoad.Flag)
@@ -108,6 +200,12 @@ func runLoad(cmd *Command, args []string) {
 	bufferedStdout := bufio.NewWriterSize(os.Stdout, 512)
 	defer bufferedStdout.Flush()
 	for i, scen := range scenarios {
+		if len(scen.Profile) > 0 {
+			fmt.Printf("%d. %q at %s (max %d threads, verbosity %d)\n",
+				i+1, scen.RawSuite.Name, scen.Profile, scen.MaxThreads,
+				scen.Verbosity)
+			continue
+		}
 		fmt.Printf("%d. %3d%% %q (max %d threads, verbosity %d)\n",
 			i+1, scen.Percentage, scen.RawSuite.Name, scen.MaxThreads,
 			scen.Verbosity)
@@ -120,14 +218,32 @@ func runLoad(cmd *Command, args []string) {
 	}
 	defer livefile.Close()
 
+	var baseline suite.LoadSummary
+	if baselineFile != "" {
+		baseline, err = suite.ReadLoadSummary(baselineFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot read baseline: %s\n", err)
+			os.Exit(9)
+		}
+	}
+
 	// Action here.
 	prepareHT()
+	ctx, cancel := interruptContext()
+	defer cancel()
 	opts := suite.ThroughputOptions{
 		Rate:         queryPerSecond,
 		Duration:     testDuration,
 		Ramp:         rampDuration,
+		Warmup:       warmupDuration,
 		CollectFrom:  collectStatus,
 		MaxErrorRate: maxErrorRate,
+		SLA:          raw.SLA,
+		Abort:        raw.Abort,
+		Context:      ctx,
+	}
+	if metricsAddr != "" || statsdAddr != "" {
+		opts.Metrics = startLiveMetrics()
 	}
 	data, failures, lterr := suite.Throughput(scenarios, opts, livefile)
 
@@ -136,6 +252,17 @@ func runLoad(cmd *Command, args []string) {
 		os.Exit(8)
 	}
 
+	summary := suite.Summarise(data)
+	suite.PrintLatencies(os.Stdout, summary.Latencies)
+	saveSummary(summary)
+	if baselineFile != "" {
+		fmt.Println()
+		summary.PrintComparison(os.Stdout, baseline)
+		if err := summary.Compare(baseline, baselineTolerance); err != nil {
+			lterr = errorlist.List{}.Append(lterr).Append(err)
+		}
+	}
+
 	if failures != nil {
 		failures.Name = "Failures of throughput test " + args[0]
 	}
@@ -144,6 +271,29 @@ func runLoad(cmd *Command, args []string) {
 	interpretLTerrors(lterr)
 }
 
+// startLiveMetrics serves the live metrics on metricsAddr and pushes them
+// to statsdAddr.
+func startLiveMetrics() *suite.LiveMetrics {
+	metrics := suite.NewLiveMetrics(10 * time.Second)
+	if metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics)
+		go func() {
+			err := http.ListenAndServe(metricsAddr, mux)
+			fmt.Fprintf(os.Stderr, "Cannot serve metrics: %s\n", err)
+		}()
+		fmt.Printf("Serving live metrics on http://%s/metrics\n", metricsAddr)
+	}
+	if statsdAddr != "" {
+		err := metrics.PushStatsD(statsdAddr, time.Second, make(chan bool))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot push metrics to StatsD: %s\n", err)
+			os.Exit(9)
+		}
+	}
+	return metrics
+}
+
 type sdata struct {
 	n                       int
 	fail, erred, bogus      int
@@ -245,6 +395,18 @@ func interpretLTerrors(lterr error) {
 	os.Exit(1)
 }
 
+// saveSummary writes summary to summary.json in the output folder.
+func saveSummary(summary suite.LoadSummary) {
+	file, err := os.Create(filepath.Join(outputDir, "summary.json"))
+	if err != nil {
+		log.Panic(err)
+	}
+	defer file.Close()
+	if err := summary.WriteJSON(file); err != nil {
+		log.Panic(err)
+	}
+}
+
 func saveLoadtestData(data []suite.TestData, failures *suite.Suite, scenarios []suite.Scenario) {
 	if failures != nil {
 		err := suite.HTMLReport(outputDir, failures)
@@ -16,8 +16,7 @@ import (
 	"strings"
 
 	"github.com/vdobler/ht/errorlist"
-	"github.com/vdobler/ht/internal/hjson"
-	"github.com/vdobler/ht/populate"
+	"github.com/vdobler/ht/scope"
 	"github.com/vdobler/ht/suite"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -69,13 +68,24 @@ func init() {
 		cmdQuick,
 		cmdRun,
 		cmdExec,
+		cmdDebug,
 		// cmdBench,
 		// cmdMonitor,
 		cmdFingerprint,
 		cmdReconstruct,
 		cmdLoad,
 		cmdStat,
+		cmdHistory,
+		cmdCompare,
 		cmdMock,
+		cmdSchema,
+		cmdVault,
+		cmdConvert,
+		cmdGenerate,
+		cmdFmt,
+		cmdLint,
+		cmdGraph,
+		cmdCompletion,
 		cmdGUI,
 	}
 }
@@ -106,6 +116,11 @@ func main() {
 	flag.Usage = usage
 	flag.Parse()
 
+	if err := loadPlugins(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(8)
+	}
+
 	args := flag.Args()
 	if len(args) < 1 {
 		usage()
@@ -126,7 +141,12 @@ func main() {
 			}
 			os.Exit(9)
 		}
+		checkLogFlag()
 		fillVariablesFlagFrom(variablesFile)
+		if err := scope.ResolveSecrets(scope.Variables(variablesFlag)); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(8)
+		}
 		args = cmd.Flag.Args()
 		switch {
 		case cmd.RunSuites != nil:
@@ -187,7 +207,7 @@ func expandTrippleDots(args []string) []string {
 
 func filesystemFor(arg string) (suite.FileSystem, string) {
 	i := strings.Index(arg, "@")
-	if i == -1 {
+	if i == -1 || strings.HasPrefix(arg, "git:") || strings.Contains(arg, "://") {
 		return nil, arg // Not an archive, use real file system from OS.
 	}
 
@@ -223,6 +243,11 @@ func loadSuites(args []string) ([]*suite.RawSuite, error) {
 			el = el.Append(fmt.Errorf("Cannot read suite %q: %s\n", arg, err))
 			continue
 		}
+		err = s.SelectProfile(profileFlag)
+		if err != nil {
+			el = el.Append(fmt.Errorf("Cannot select profile: %s\n", err))
+			continue
+		}
 		err = s.Validate(variablesFlag)
 		if err != nil {
 			el = el.Append(fmt.Errorf("Cannot validate suite %q: %s\n", arg, err))
@@ -251,7 +276,7 @@ func loadSuites(args []string) ([]*suite.RawSuite, error) {
 			id := fmt.Sprintf("%d.%d", sNo+1, tNo+1)
 			if skip[id] {
 				rt.Disable()
-				fmt.Printf("Skipping test %s %q\n", id, rt.Name)
+				printf(nil, "Skipping test %s %q\n", id, rt.Name)
 			}
 		}
 	}
@@ -344,35 +369,33 @@ func loadTests(args []string) ([]*suite.RawTest, error) {
 // variablesFlag looks like the variablesFile was loaded first and the
 // -D flags overwrite the ones loaded from file.
 func fillVariablesFlagFrom(variablesFile string) {
+	cmdlineVariables = make(map[string]string, len(variablesFlag))
+	for n, v := range variablesFlag {
+		cmdlineVariables[n] = v
+	}
 	if variablesFile == "" {
 		return
 	}
-	data, err := ioutil.ReadFile(variablesFile)
-	if err != nil {
+	if err := mergeVariablesFrom(variablesFlag, variablesFile); err != nil {
 		fmt.Fprintf(os.Stderr, "Cannot read variable file %q: %s\n", variablesFile, err)
 		os.Exit(8)
 	}
-	v := map[string]interface{}{}
-	err = hjson.Unmarshal(data, &v)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Cannot unmarshal variable file %q: %s\n", variablesFile, err)
-		os.Exit(8)
-	}
-	vv := map[string]string{}
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Malformed variable file %q: %s\n", variablesFile, err)
-		os.Exit(8)
-	}
+}
+
+// cmdlineVariables are the variables set via -D only.
+var cmdlineVariables map[string]string
 
-	err = populate.Strict(&vv, v)
+// mergeVariablesFrom sets the variables of vars which are unset to the
+// values read from variablesFile.
+func mergeVariablesFrom(vars map[string]string, variablesFile string) error {
+	vv, err := suite.LoadVariables(variablesFile, nil)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Malformed variable file %q: %s\n", variablesFile, err)
-		os.Exit(8)
+		return err
 	}
-
 	for n, k := range vv {
-		if _, ok := variablesFlag[n]; !ok {
-			variablesFlag[n] = k
+		if _, ok := vars[n]; !ok {
+			vars[n] = k
 		}
 	}
+	return nil
 }
@@ -10,25 +10,49 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/vdobler/ht/ht"
 	"github.com/vdobler/ht/mock"
-	"github.com/vdobler/ht/scope"
-	"github.com/vdobler/ht/suite"
 )
 
 var cmdMock = &Command{
 	RunArgs:     runMock,
-	Usage:       "mock <mock>...",
+	Usage:       "mock [-record <backend>] <mock>...",
 	Description: "run a mock server",
 	Flag:        flag.NewFlagSet("stat", flag.ContinueOnError),
 	Help: `Mock starts a HTTP server providing the given mocks.
+
+The mocks are served until ht is terminated. Each mock is served on the
+port given in its URL unless -port is given in which case all mocks are
+served on this port. The mock files are reloaded whenever one of them
+changes (disable with -reload=false).
+
+An admin endpoint can be started with -admin <addr> which provides
+    GET  /mappings      list of the served mocks
+    GET  /invocations   the recorded invocations (DELETE also clears them)
+    POST /reload        reload all mocks
+
+With -record <backend> no mocks are served. Instead a recording reverse
+proxy is started on the address given by -local which forwards all requests
+to <backend> and writes each request/response pair as a mock file to the
+output folder. Serving the recorded mocks with 'ht mock' on the same
+address replays the backend:
+
+    ht mock -record https://api.example.org -local localhost:8880 -output apimocks
+    ...
+    ht mock apimocks/*.mock
 `,
 }
 
 var (
-	certFile string
-	keyFile  string
+	certFile     string
+	keyFile      string
+	recordTarget string
+	recordLocal  string
+	mockPort     string
+	mockReload   bool
+	mockAdmin    string
 )
 
 func init() {
@@ -37,9 +61,25 @@ func init() {
 		"load server certificate for https mocks from `file`")
 	cmdMock.Flag.StringVar(&keyFile, "key", "",
 		"load private key for https mocks from `file`")
+	cmdMock.Flag.StringVar(&recordTarget, "record", "",
+		"record mocks by proxying to `backend`")
+	cmdMock.Flag.StringVar(&recordLocal, "local", "localhost:8880",
+		"local address of the recording proxy")
+	cmdMock.Flag.StringVar(&mockPort, "port", "",
+		"serve all mocks on `port` instead of the port in their URL")
+	cmdMock.Flag.BoolVar(&mockReload, "reload", true,
+		"reload mocks when a mock file changes")
+	cmdMock.Flag.StringVar(&mockAdmin, "admin", "",
+		"serve admin endpoint on `addr`")
+	addOutputFlag(cmdMock.Flag)
 }
 
 func runMock(cmd *Command, args []string) {
+	if recordTarget != "" {
+		recordMocks()
+		return
+	}
+
 	// Sanity check.
 	if len(args) == 0 {
 		fmt.Fprintln(os.Stderr, "No mock given")
@@ -47,42 +87,46 @@ func runMock(cmd *Command, args []string) {
 		os.Exit(9)
 	}
 
-	monitor := make(chan *ht.Test)
-
-	mocks := []*mock.Mock{}
-	for _, arg := range args {
-		raw, err := suite.LoadRawMock(arg, nil)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err.Error())
-			os.Exit(8)
-		}
-		mockScope := scope.New(scope.Variables(variablesFlag), raw.Variables, false)
-		mockScope["MOCK_DIR"] = raw.Dirname()
-		mockScope["MOCK_NAME"] = raw.Basename()
-		m, err := raw.ToMock(mockScope, false)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err.Error())
-			os.Exit(8)
-		}
-
-		m.Monitor = monitor
-		mocks = append(mocks, m)
-	}
-
-	logger := log.New(os.Stdout, "", 0)
-	nfh := func(w http.ResponseWriter, r *http.Request) {
-		fmt.Printf("Mock not found: 404 for %s %s\n", r.Method, r.URL)
-		fmt.Println("===========================================================")
-		http.Error(w, "Not found", 404)
+	ms := &mockServer{
+		files:   args,
+		port:    mockPort,
+		monitor: make(chan *ht.Test),
+		logger:  log.New(os.Stdout, "", 0),
 	}
-	_, err := mock.Serve(mocks, http.HandlerFunc(nfh), logger, certFile, keyFile)
-	if err != nil {
+	if err := ms.start(); err != nil {
 		fmt.Fprintf(os.Stderr, "Problems staring server: %s\n", err)
 		os.Exit(9)
 	}
+	if mockReload {
+		go ms.watch(time.Second)
+	}
+	if mockAdmin != "" {
+		go func() {
+			err := http.ListenAndServe(mockAdmin, ms.adminHandler())
+			fmt.Fprintf(os.Stderr, "Cannot serve admin endpoint: %s\n", err)
+		}()
+		fmt.Printf("Admin endpoint on http://%s/mappings and /invocations\n", mockAdmin)
+	}
 
-	for {
-		report := <-monitor
+	for report := range ms.monitor {
 		fmt.Println(mock.PrintReport(report))
+		ms.report(report)
+	}
+}
+
+// recordMocks runs a recording reverse proxy to recordTarget.
+func recordMocks() {
+	rec, err := mock.NewRecorder(recordTarget)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(9)
 	}
+	prepareOutputDir()
+	rec.Dir = outputDir
+	rec.Log = log.New(os.Stdout, "", 0)
+	fmt.Printf("Recording mocks of %s on http://%s to %s\n",
+		recordTarget, recordLocal, outputDir)
+	err = http.ListenAndServe(recordLocal, rec)
+	fmt.Fprintf(os.Stderr, "Problems running recorder: %s\n", err)
+	os.Exit(9)
 }
@@ -0,0 +1,254 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/mock"
+	"github.com/vdobler/ht/scope"
+	"github.com/vdobler/ht/suite"
+)
+
+// mockServer serves the mocks read from a set of files and keeps track of
+// their invocations.
+type mockServer struct {
+	files   []string
+	port    string // if non-empty: serve all mocks on this port
+	monitor chan *ht.Test
+	logger  mock.Log
+
+	serving sync.Mutex // serializes (re)starting
+	stop    chan bool
+
+	mu          sync.Mutex // protects the fields below
+	mocks       []*mock.Mock
+	modified    map[string]time.Time
+	invocations []invocation
+}
+
+// invocation is the record of one call to a mock.
+type invocation struct {
+	Time     time.Time
+	Mock     string
+	Method   string
+	URL      string
+	Status   string
+	Response int
+	Error    string `json:",omitempty"`
+}
+
+// maxInvocations is the number of invocations kept by a mockServer.
+const maxInvocations = 1000
+
+// load reads all mock files.
+func (ms *mockServer) load() ([]*mock.Mock, map[string]time.Time, error) {
+	mocks := []*mock.Mock{}
+	modified := make(map[string]time.Time, len(ms.files))
+	for _, file := range ms.files {
+		if fi, err := os.Stat(file); err == nil {
+			modified[file] = fi.ModTime()
+		}
+		raw, err := suite.LoadRawMock(file, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		mockScope := scope.New(scope.Variables(variablesFlag), raw.Variables, false)
+		mockScope["MOCK_DIR"] = raw.Dirname()
+		mockScope["MOCK_NAME"] = raw.Basename()
+		m, err := raw.ToMock(mockScope, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ms.port != "" {
+			m.URL, err = withPort(m.URL, ms.port)
+			if err != nil {
+				return nil, nil, fmt.Errorf("mock %s: %s", file, err)
+			}
+		}
+		m.Monitor = ms.monitor
+		mocks = append(mocks, m)
+	}
+	return mocks, modified, nil
+}
+
+// withPort replaces the port of rawurl by port.
+func withPort(rawurl, port string) (string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", err
+	}
+	u.Host = net.JoinHostPort(u.Hostname(), port)
+	return u.String(), nil
+}
+
+// start (or restart) serving the mocks.
+func (ms *mockServer) start() error {
+	mocks, modified, err := ms.load()
+	if err != nil {
+		return err
+	}
+
+	ms.serving.Lock()
+	defer ms.serving.Unlock()
+	if ms.stop != nil {
+		ms.stop <- true
+		<-ms.stop
+		ms.stop = nil
+	}
+	stop, err := mock.Serve(mocks, http.HandlerFunc(ms.notFound), ms.logger, certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	ms.stop = stop
+	ms.mu.Lock()
+	ms.mocks, ms.modified = mocks, modified
+	ms.mu.Unlock()
+	return nil
+}
+
+// changed reports whether one of the mock files changed since loading.
+func (ms *mockServer) changed() bool {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for _, file := range ms.files {
+		fi, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		if !fi.ModTime().Equal(ms.modified[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+// watch the mock files and reload them on change.
+func (ms *mockServer) watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		if !ms.changed() {
+			continue
+		}
+		if err := ms.start(); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot reload mocks: %s\n", err)
+			// Do not retry until the next change.
+			ms.mu.Lock()
+			for _, file := range ms.files {
+				if fi, err := os.Stat(file); err == nil {
+					ms.modified[file] = fi.ModTime()
+				}
+			}
+			ms.mu.Unlock()
+			continue
+		}
+		fmt.Println("Reloaded mocks")
+	}
+}
+
+func (ms *mockServer) notFound(w http.ResponseWriter, r *http.Request) {
+	fmt.Printf("Mock not found: 404 for %s %s\n", r.Method, r.URL)
+	fmt.Println("===========================================================")
+	http.Error(w, "Not found", 404)
+	ms.record(invocation{
+		Time:     time.Now(),
+		Method:   r.Method,
+		URL:      r.URL.String(),
+		Status:   ht.Fail.String(),
+		Response: 404,
+		Error:    "no mock found",
+	})
+}
+
+// report records the invocation reported by a mock.
+func (ms *mockServer) report(report *ht.Test) {
+	inv := invocation{
+		Time:   report.Result.Started,
+		Mock:   report.Name,
+		Method: report.Request.Method,
+		URL:    report.Request.URL,
+		Status: report.Result.Status.String(),
+	}
+	if report.Response.Response != nil {
+		inv.Response = report.Response.Response.StatusCode
+	}
+	if report.Result.Error != nil {
+		inv.Error = report.Result.Error.Error()
+	}
+	ms.record(inv)
+}
+
+func (ms *mockServer) record(inv invocation) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.invocations = append(ms.invocations, inv)
+	if n := len(ms.invocations); n > maxInvocations {
+		ms.invocations = ms.invocations[n-maxInvocations:]
+	}
+}
+
+// adminHandler serves the admin endpoints:
+//     /mappings      the served mocks
+//     /invocations   the recorded invocations (DELETE also clears them)
+//     /reload        reload all mocks (POST)
+func (ms *mockServer) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mappings", func(w http.ResponseWriter, r *http.Request) {
+		type mapping struct {
+			Name, Description, Method, URL string
+			Scenario, RequiredState        string `json:",omitempty"`
+			Priority                       int    `json:",omitempty"`
+		}
+		ms.mu.Lock()
+		mappings := make([]mapping, len(ms.mocks))
+		for i, m := range ms.mocks {
+			mappings[i] = mapping{
+				Name:          m.Name,
+				Description:   m.Description,
+				Method:        m.Method,
+				URL:           m.URL,
+				Scenario:      m.Scenario,
+				RequiredState: m.RequiredState,
+				Priority:      m.Priority,
+			}
+		}
+		ms.mu.Unlock()
+		writeJSON(w, mappings)
+	})
+	mux.HandleFunc("/invocations", func(w http.ResponseWriter, r *http.Request) {
+		ms.mu.Lock()
+		defer ms.mu.Unlock()
+		writeJSON(w, ms.invocations)
+		if r.Method == http.MethodDelete {
+			ms.invocations = nil
+		}
+	})
+	mux.HandleFunc("/reload", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "use POST to reload", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := ms.start(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintln(w, "reloaded")
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	enc.Encode(v)
+}
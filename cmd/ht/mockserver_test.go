@@ -0,0 +1,48 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vdobler/ht/mock"
+)
+
+func TestWithPort(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"http://localhost:8880/foo", "http://localhost:9999/foo"},
+		{"http://localhost/foo/{id}", "http://localhost:9999/foo/%7Bid%7D"},
+	} {
+		got, err := withPort(tc.in, "9999")
+		if err != nil || got != tc.want {
+			t.Errorf("withPort(%q) = %q, %v; want %q", tc.in, got, err, tc.want)
+		}
+	}
+}
+
+func TestMockServerAdmin(t *testing.T) {
+	ms := &mockServer{
+		mocks: []*mock.Mock{{Name: "Greet", Method: "GET", URL: "http://localhost:8880/greet"}},
+	}
+	ms.notFound(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+	admin := ms.adminHandler()
+
+	rr := httptest.NewRecorder()
+	admin.ServeHTTP(rr, httptest.NewRequest("GET", "/mappings", nil))
+	if body := rr.Body.String(); !strings.Contains(body, `"Name": "Greet"`) {
+		t.Errorf("Got mappings %s", body)
+	}
+
+	rr = httptest.NewRecorder()
+	admin.ServeHTTP(rr, httptest.NewRequest("DELETE", "/invocations", nil))
+	if body := rr.Body.String(); !strings.Contains(body, `"URL": "/missing"`) {
+		t.Errorf("Got invocations %s", body)
+	}
+	if len(ms.invocations) != 0 {
+		t.Errorf("Invocations not cleared")
+	}
+}
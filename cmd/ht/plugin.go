@@ -0,0 +1,86 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"plugin"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/vdobler/ht/gui"
+	"github.com/vdobler/ht/ht"
+)
+
+// pluginsEnv is the environment variable listing the Go plugins to load.
+const pluginsEnv = "HTPLUGINS"
+
+// loadPlugins opens the Go plugins listed in the HTPLUGINS environment
+// variable. The init functions of a plugin register its checks and
+// extractors with ht.RegisterCheck and ht.RegisterExtractor and their
+// documentation with gui.RegisterType.
+func loadPlugins() error {
+	for _, path := range filepath.SplitList(os.Getenv(pluginsEnv)) {
+		if path == "" {
+			continue
+		}
+		if _, err := plugin.Open(path); err != nil {
+			return fmt.Errorf("cannot load plugin %s: %s", path, err)
+		}
+	}
+	return nil
+}
+
+// registeredTypeDoc returns the documentation of the registered check or
+// extractor with the given lowercase name. It is used for checks and
+// extractors without an entry in typeDoc, e.g. those loaded from plugins.
+func registeredTypeDoc(name string) (string, bool) {
+	for _, registry := range []map[string]reflect.Type{
+		ht.CheckRegistry, ht.ExtractorRegistry} {
+		for regName, typ := range registry {
+			if strings.ToLower(regName) != name {
+				continue
+			}
+			for typ.Kind() == reflect.Ptr {
+				typ = typ.Elem()
+			}
+			return formatTypeDoc(regName, typ), true
+		}
+	}
+	return "", false
+}
+
+// formatTypeDoc formats the documentation of typ in gui.Typedata similar
+// to the entries in typeDoc.
+func formatTypeDoc(name string, typ reflect.Type) string {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "type %s struct {\n", name)
+	fields := fieldDoc(typ, map[string]bool{})
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		for _, line := range strings.Split(strings.TrimSpace(f.Doc), "\n") {
+			if line = strings.TrimPrefix(line, "// "); line != "" {
+				fmt.Fprintf(buf, "\t// %s\n", line)
+			}
+		}
+		fmt.Fprintf(buf, "\t%s %s\n", f.Name, f.Type)
+	}
+	buf.WriteString("}\n")
+	for _, line := range strings.Split(strings.TrimSpace(gui.Typedata[typ].Doc), "\n") {
+		if line == "" {
+			buf.WriteString("\n")
+			continue
+		}
+		fmt.Fprintf(buf, "    %s\n", line)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -5,32 +5,58 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"net/url"
+	"os"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/internal/hjson"
 	"github.com/vdobler/ht/suite"
 )
 
 var cmdQuick = &Command{
 	RunArgs:     runQuick,
-	Usage:       "quick <URL>...",
-	Description: "do quick checking of HTML pages",
+	Usage:       "quick (<URL>... | <METHOD> <URL> [-check <check>]...)",
+	Description: "do quick checking of HTML pages or a single request",
 	Flag:        flag.NewFlagSet("run", flag.ContinueOnError),
 	Help: `Quick performs a standard test on URL.
 
 Quick's standard test is useful for HTML pages only (and not images, JSON
 files, scripts or that like).
+
+If the first argument is a HTTP method like GET or POST quick executes
+just one request to URL and checks the response with the checks given by
+the -check flags. This allows to replace curl and grep in shell scripts
+without writing a test file:
+    ht quick GET https://example.org -check 'Status 200' \
+        -check 'BodyContains Example Domain'
+The flags may follow the URL. The exit code is the same as for exec.
+The following short forms of checks are understood:
+    Status <code>           status code is <code>
+    ContentType <type>      content type is <type>, e.g. json
+    BodyContains <text>     body contains <text>
+    BodyMatches <regexp>    body matches <regexp>
+    Header <name> [<text>]  header <name> is present (and contains <text>)
+    ResponseTime <dur>      response is received faster than <dur>
+Any other check is given by its name followed by its fields in HJSON:
+    -check 'JSON {Element: "user.name", Equals: "\"Joe\""}'
+Request headers are set with -H 'Name: value' and a request body with
+-body. Results are saved only if -output is given.
 `,
 }
 
 var defaultHeader = http.Header{}
 var fullChecksFlag = false
+var quickChecks, quickHeaders stringsFlag
+var quickBody string
 
 func init() {
 	addOutputFlag(cmdQuick.Flag)
@@ -38,6 +64,12 @@ func init() {
 	addShowFlag(cmdQuick.Flag)
 	cmdQuick.Flag.BoolVar(&fullChecksFlag, "full", false,
 		"check links, latency and resilience too")
+	cmdQuick.Flag.Var(&quickChecks, "check",
+		"check the response with `check`, may be repeated")
+	cmdQuick.Flag.Var(&quickHeaders, "H",
+		"send `header` 'Name: value' in the request, may be repeated")
+	cmdQuick.Flag.StringVar(&quickBody, "body", "",
+		"send `data` as request body")
 
 	defaultHeader.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
 	defaultHeader.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Ubuntu Chromium/47.0.2526.73 Chrome/47.0.2526.73 Safari/537.36")
@@ -47,6 +79,16 @@ func init() {
 	defaultHeader.Set("Upgrade-Insecure-Requests", "1")
 }
 
+// stringsFlag collects the values of a repeated flag. It satisfies the
+// flag.Value interface.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string { return strings.Join(*s, ", ") }
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
 func sameHostCondition(s string) ht.Condition {
 	u, err := url.Parse(s)
 	if err != nil {
@@ -120,6 +162,10 @@ func makeChecks(u string) ht.CheckList {
 }
 
 func runQuick(cmd *Command, urls []string) {
+	if len(urls) >= 2 && isMethod(urls[0]) {
+		runQuickRequest(cmd, urls)
+		return
+	}
 	prepareHT()
 	prepareOutputDir()
 
@@ -162,3 +208,124 @@ func runQuick(cmd *Command, urls []string) {
 	saveSingle(accum, outputDir, s, showBrowser)
 	reportOverall(accum)
 }
+
+// isMethod reports whether s looks like a HTTP method.
+func isMethod(s string) bool {
+	if s == "" || strings.Contains(s, "/") {
+		return false
+	}
+	return strings.ToUpper(s) == s && strings.IndexFunc(s, func(r rune) bool {
+		return r < 'A' || r > 'Z'
+	}) == -1
+}
+
+// runQuickRequest executes the single request given by args (method, URL
+// and flags) with the checks given by the -check flags as a test.
+func runQuickRequest(cmd *Command, args []string) {
+	method, u := args[0], args[1]
+	if err := cmd.Flag.Parse(args[2:]); err != nil {
+		os.Exit(9)
+	}
+	if cmd.Flag.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "Unexpected arguments %q\n", cmd.Flag.Args())
+		fmt.Fprintln(os.Stderr, "Usage: ht", cmd.Usage)
+		os.Exit(9)
+	}
+
+	checks := []interface{}{}
+	for _, spec := range quickChecks {
+		check, err := parseQuickCheck(spec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Bad check %q: %s\n", spec, err)
+			os.Exit(9)
+		}
+		checks = append(checks, check)
+	}
+	header := map[string][]string{}
+	for _, h := range quickHeaders {
+		i := strings.Index(h, ":")
+		if i <= 0 {
+			fmt.Fprintf(os.Stderr, "Bad header %q, must be of the form 'Name: value'\n", h)
+			os.Exit(9)
+		}
+		name := http.CanonicalHeaderKey(strings.TrimSpace(h[:i]))
+		header[name] = append(header[name], strings.TrimSpace(h[i+1:]))
+	}
+	test := map[string]interface{}{
+		"Name": method + " " + u,
+		"Request": map[string]interface{}{
+			"Method": method,
+			"URL":    u,
+			"Header": header,
+			"Body":   quickBody,
+		},
+		"Checks": checks,
+	}
+	data, err := json.MarshalIndent(test, "", "    ")
+	if err != nil {
+		log.Fatal(err) // cannot happen
+	}
+	const filename = "<quick>.ht"
+	fs := suite.FileSystem{filename: &suite.File{Name: filename, Data: string(data)}}
+	rt, err := suite.LoadRawTest(filename, fs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(9)
+	}
+	if outputDir == "" {
+		outputDir = "/dev/null"
+	}
+	runRun(cmd, []*suite.RawTest{rt})
+}
+
+// parseQuickCheck parses the check given as "<Name> <arguments>" to the
+// form used in test files.
+func parseQuickCheck(spec string) (map[string]interface{}, error) {
+	spec = strings.TrimSpace(spec)
+	name, arg := spec, ""
+	if i := strings.IndexAny(spec, " \t"); i != -1 {
+		name, arg = spec[:i], strings.TrimSpace(spec[i+1:])
+	}
+
+	switch name {
+	case "Status":
+		code, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("status code %q is not a number", arg)
+		}
+		return map[string]interface{}{"Check": "StatusCode", "Expect": code}, nil
+	case "ContentType":
+		return map[string]interface{}{"Check": "ContentType", "Is": arg}, nil
+	case "BodyContains":
+		return map[string]interface{}{"Check": "Body", "Contains": arg}, nil
+	case "BodyMatches":
+		return map[string]interface{}{"Check": "Body", "Regexp": arg}, nil
+	case "Header":
+		parts := strings.SplitN(arg, " ", 2)
+		if parts[0] == "" {
+			return nil, fmt.Errorf("missing header name")
+		}
+		check := map[string]interface{}{"Check": "Header", "Header": parts[0]}
+		if len(parts) == 2 {
+			check["Contains"] = strings.TrimSpace(parts[1])
+		}
+		return check, nil
+	case "ResponseTime":
+		if _, err := time.ParseDuration(arg); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"Check": "ResponseTime", "Lower": arg}, nil
+	}
+
+	if _, ok := ht.CheckRegistry[name]; !ok {
+		return nil, fmt.Errorf("no such check %q", name)
+	}
+	check := map[string]interface{}{}
+	if arg != "" {
+		if err := hjson.Unmarshal([]byte(arg), &check); err != nil {
+			return nil, err
+		}
+	}
+	check["Check"] = name
+	return check, nil
+}
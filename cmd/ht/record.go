@@ -24,8 +24,8 @@ import (
 
 var cmdRecord = &Command{
 	RunArgs:     runRecord,
-	Usage:       "record [flags] <remote-target>",
-	Description: "run reverse proxy to record tests",
+	Usage:       "record [flags] [<remote-target>]",
+	Description: "run (reverse) proxy to record tests",
 	Flag:        flag.NewFlagSet("record", flag.ContinueOnError),
 	Help: `Record request to target and create suitable tests based on the response.
 
@@ -36,6 +36,20 @@ Tests can be generated for the captured reqest/response pairs.
 To see which request have been captured, to rename or delete some and
 to dump appropriate test stubs to disk use the web gui reachable under
 http://<value-of-local-flag>/-ADMIN-
+
+Without <remote-target> but with flag -port record acts as a capturing
+HTTP(S) proxy: Configure a browser or any other client to use the proxy
+localhost:<port> and each observed request/response pair is written
+immediately as a test to the output directory. The tests contain default
+checks for the status code, the content type and a few anchors in the
+body like the title of HTML pages or the top-level members of JSON
+objects. A suite executing all recorded tests in order is kept up to date:
+    ht record -port 8080 -o recorded/
+HTTPS traffic is intercepted with certificates issued by a CA which is
+generated on first use and stored in the files given by flag -ca (the
+private key is stored next to it with suffix -key.pem). The client must
+trust this CA, e.g. by importing ht-ca.pem into the browser or via
+    curl --cacert ht-ca.pem --proxy localhost:8080 https://example.org
 `,
 }
 
@@ -49,21 +63,33 @@ func init() {
 		"disarm recorder for `period` after last capture")
 	cmdRecord.Flag.IntVar(&recorderRewrite, "rewrite", 3,
 		"rewrite RespHeader=1 RespBody=2 ReqHeader=4 ReqBody=8")
+	cmdRecord.Flag.StringVar(&recorderProxyPort, "port", "",
+		"run capturing proxy on `port`")
+	cmdRecord.Flag.StringVar(&recorderCA, "ca", "ht-ca.pem",
+		"certificate `file` of the CA used to intercept HTTPS")
 	addOutputFlag(cmdRecord.Flag)
+	cmdRecord.Flag.StringVar(&outputDir, "o", "",
+		"save tests to `dir` (short for -output)")
 }
 
 var (
-	recorderPort    string
-	recorderLocal   string
-	recorderDisarm  time.Duration
-	recorderIgnPath string
-	recorderIgnCT   string
-	recorderRewrite int
+	recorderPort      string
+	recorderLocal     string
+	recorderDisarm    time.Duration
+	recorderIgnPath   string
+	recorderIgnCT     string
+	recorderRewrite   int
+	recorderProxyPort string
+	recorderCA        string
 )
 
 func runRecord(cmd *Command, args []string) {
+	if len(args) == 0 && recorderProxyPort != "" {
+		runCapturingProxy()
+		return
+	}
 	if len(args) != 1 {
-		fmt.Fprintln(os.Stderr, "Missing <remote-target> for record")
+		fmt.Fprintln(os.Stderr, "Missing <remote-target> or -port for record")
 		fmt.Fprintf(os.Stderr, "Usage: %s\n", cmd.Usage)
 		os.Exit(1)
 	}
@@ -102,6 +128,57 @@ func runRecord(cmd *Command, args []string) {
 	}
 }
 
+// runCapturingProxy runs a forward proxy on recorderProxyPort which
+// writes each captured request/response pair as a test to outputDir.
+func runCapturingProxy() {
+	keyFile := strings.TrimSuffix(recorderCA, ".pem") + "-key.pem"
+	ca, created, err := recorder.LoadOrCreateCA(recorderCA, keyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot load CA: %s\n", err)
+		os.Exit(1)
+	}
+	if created {
+		log.Printf("Generated new CA %s, clients must trust it to record HTTPS", recorderCA)
+	}
+
+	if outputDir == "" {
+		outputDir = time.Now().Format("2006-01-02_15h04m05s")
+	}
+	writer := &recorder.Writer{
+		Directory: outputDir,
+		Suite:     "Recorded",
+	}
+	proxy := &recorder.Proxy{
+		CA: ca,
+		Record: func(e recorder.Event) {
+			filename, err := writer.Write(e)
+			if err != nil {
+				log.Printf("Cannot write test for %s %s: %s", e.Request.Method, e.Request.URL, err)
+				return
+			}
+			log.Println("Recorded", e.Request.Method, e.Request.URL, " --> ",
+				e.Response.Code, " ", filename)
+		},
+	}
+	if recorderIgnPath != "" {
+		proxy.Options.IgnoredPath = regexp.MustCompile(recorderIgnPath)
+	}
+	if recorderIgnCT != "" {
+		proxy.Options.IgnoredContentType = regexp.MustCompile(recorderIgnCT)
+	}
+
+	addr := recorderProxyPort
+	if !strings.Contains(addr, ":") {
+		addr = ":" + addr
+	}
+	log.Printf("Capturing proxy listening on %s, writing tests to %s", addr, outputDir)
+	err = http.ListenAndServe(addr, proxy)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot launch proxy: %s\n", err)
+		os.Exit(1)
+	}
+}
+
 func registerAdminHandlers() {
 	http.HandleFunc("/-ADMIN-", adminHandler)
 	log.Printf("Point browser to http://localhost%s/-ADMIN- to access recorder admin interface", recorderPort)
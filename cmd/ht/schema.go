@@ -0,0 +1,78 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/vdobler/ht/suite"
+)
+
+var cmdSchema = &Command{
+	RunArgs:     runSchema,
+	Usage:       "schema [-kind <kind>] [<file>...]",
+	Description: "print JSON Schema or validate files against it",
+	Flag:        flag.NewFlagSet("schema", flag.ContinueOnError),
+	Help: `Schema prints the JSON Schema of test, mixin, suite, mock or load test
+files if called without arguments. The schema can be used by editors for
+autocompletion and inline validation of the HJSON files.
+
+If files are given they are validated against the schema of the selected
+kind and all violations are reported.
+
+The kind of file is selected with the -kind flag and can be one of
+` + strings.Join(suite.SchemaKinds, ", ") + `.
+
+Suites, tests, mocks and load tests can be validated against their schema
+during loading for the other commands by using the -schema flag.
+`,
+}
+
+var schemaKind string
+
+func init() {
+	cmdSchema.Flag.StringVar(&schemaKind, "kind", "test",
+		"produce schema for files of kind `kind`")
+}
+
+func runSchema(cmd *Command, args []string) {
+	if len(args) == 0 {
+		schema, err := suite.Schema(schemaKind)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(9)
+		}
+		data, err := json.MarshalIndent(schema, "", "    ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(9)
+		}
+		fmt.Println(string(data))
+		os.Exit(0)
+	}
+
+	okay := true
+	for _, arg := range args {
+		fs, arg := filesystemFor(arg)
+		file, err := fs.Load(arg)
+		if err == nil {
+			err = file.CheckSchema(schemaKind)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			okay = false
+			continue
+		}
+		fmt.Printf("%s: okay\n", arg)
+	}
+	if okay {
+		os.Exit(0)
+	}
+	os.Exit(8)
+}
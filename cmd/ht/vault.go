@@ -0,0 +1,83 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/vdobler/ht/scope"
+)
+
+var cmdVault = &Command{
+	RunArgs:     runVault,
+	Usage:       "vault [-vaultkeyfile <file>] encrypt|decrypt <file>",
+	Description: "encrypt or decrypt a variables file",
+	Flag:        flag.NewFlagSet("vault", flag.ContinueOnError),
+	Help: `Vault encrypts a variables file in place or prints the decrypted content
+of an encrypted variables file. Encrypted variable files can be committed
+alongside the suites: They are decrypted transparently when loaded via the
+-Dfile flag or the VariablesFrom field of a suite and all their values are
+treated as secrets which are masked in the output.
+
+The passphrase is taken from the first of the following sources:
+  - the environment variable HT_VAULT_PASSPHRASE
+  - the file given by the -vaultkeyfile flag
+  - the output of the shell command given by the -vaultkeycmd flag
+The last one allows to fetch the passphrase from a key management service,
+e.g. -vaultkeycmd "aws kms decrypt --ciphertext-blob fileb://key.enc
+--output text --query Plaintext | base64 -d".
+`,
+}
+
+func init() {
+	addVaultFlags(cmdVault.Flag)
+}
+
+func runVault(cmd *Command, args []string) {
+	if len(args) != 2 || (args[0] != "encrypt" && args[0] != "decrypt") {
+		fmt.Fprintln(os.Stderr, "Usage: ht", cmd.Usage)
+		os.Exit(9)
+	}
+	filename := args[1]
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(9)
+	}
+	passphrase, err := scope.VaultKey()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(9)
+	}
+
+	if args[0] == "decrypt" {
+		plaintext, err := scope.Decrypt(data, passphrase)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot decrypt %s: %s\n", filename, err)
+			os.Exit(8)
+		}
+		os.Stdout.Write(plaintext)
+		os.Exit(0)
+	}
+
+	if scope.IsEncrypted(data) {
+		fmt.Fprintf(os.Stderr, "File %s is already encrypted\n", filename)
+		os.Exit(9)
+	}
+	encrypted, err := scope.Encrypt(data, passphrase)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(8)
+	}
+	err = ioutil.WriteFile(filename, encrypted, 0600)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(8)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,239 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vdobler/ht/cookiejar"
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/scope"
+	"github.com/vdobler/ht/suite"
+)
+
+// watchInterval is the interval in which watched files are checked for
+// modifications.
+var watchInterval = 500 * time.Millisecond
+
+// watchedSuite is a suite executed in watch mode together with the
+// modification times of the files it uses.
+type watchedSuite struct {
+	rs    *suite.RawSuite
+	files fileTimes
+}
+
+// watcher re-executes suites once one of the files they use changes.
+type watcher struct {
+	out       io.Writer
+	color     bool
+	jar       *cookiejar.Jar    // Cookies each run starts with.
+	variables map[string]string // Variables from -D and -Dfile.
+	varsFile  fileTimes         // The -Dfile.
+	suites    []*watchedSuite
+}
+
+// watch executes suites and re-executes each suite once one of the files
+// it uses changes. All suites are re-executed if the file given with
+// -Dfile changes. It never returns.
+func watch(suites []*suite.RawSuite, variables map[string]string, jar *cookiejar.Jar) {
+	w := newWatcher(os.Stdout, suites, variables, jar)
+	w.color = isTerminal(os.Stdout)
+	for _, ws := range w.suites {
+		w.run(ws)
+	}
+	fmt.Printf("Watching %d suites for changes, press Ctrl-C to stop.\n", len(suites))
+
+	for {
+		time.Sleep(watchInterval)
+		w.check()
+	}
+}
+
+func newWatcher(out io.Writer, suites []*suite.RawSuite, variables map[string]string, jar *cookiejar.Jar) *watcher {
+	w := &watcher{
+		out:       out,
+		jar:       jar,
+		variables: variables,
+		varsFile:  fileTimes{},
+		suites:    make([]*watchedSuite, len(suites)),
+	}
+	if variablesFile != "" {
+		w.varsFile = modTimes([]string{variablesFile})
+	}
+	for i, rs := range suites {
+		w.suites[i] = &watchedSuite{rs: rs, files: modTimes(rs.Referenced())}
+	}
+	return w
+}
+
+// run executes the watched suite ws with a fresh copy of the cookie jar.
+func (w *watcher) run(ws *watchedSuite) {
+	runWatched(w.out, ws.rs, w.variables, copyJar(w.jar), w.color)
+}
+
+// check re-executes the suites whose files changed since the last check.
+func (w *watcher) check() {
+	if changed := w.varsFile.changed(); len(changed) > 0 {
+		fmt.Fprintf(w.out, "\n%s changed\n", changed[0])
+		w.varsFile = modTimes(changed)
+		variables, err := reloadVariables()
+		if err != nil {
+			fmt.Fprintln(w.out, colored(w.color, ht.Bogus, "BOGUS"), variablesFile)
+			fmt.Fprintln(w.out, "    ", err)
+		} else {
+			w.variables = variables
+			for _, ws := range w.suites {
+				ws.files = modTimes(ws.rs.Referenced())
+				w.run(ws)
+			}
+			return
+		}
+	}
+
+	for _, ws := range w.suites {
+		changed := ws.files.changed()
+		if len(changed) == 0 {
+			continue
+		}
+		fmt.Fprintf(w.out, "\n%s changed\n", strings.Join(changed, ", "))
+		rs, err := reloadSuite(ws.rs.File.Name)
+		if err != nil {
+			fmt.Fprintln(w.out, colored(w.color, ht.Bogus, "BOGUS"), ws.rs.File.Name)
+			fmt.Fprintln(w.out, "    ", err)
+			ws.files = modTimes(ws.rs.Referenced())
+			continue
+		}
+		ws.rs = rs
+		ws.files = modTimes(rs.Referenced())
+		w.run(ws)
+	}
+}
+
+// reloadVariables reads the variables from the -D flags and the -Dfile
+// again.
+func reloadVariables() (map[string]string, error) {
+	variables := make(map[string]string, len(cmdlineVariables))
+	for n, v := range cmdlineVariables {
+		variables[n] = v
+	}
+	if err := mergeVariablesFrom(variables, variablesFile); err != nil {
+		return nil, err
+	}
+	if err := scope.ResolveSecrets(scope.Variables(variables)); err != nil {
+		return nil, err
+	}
+	return variables, nil
+}
+
+// fileTimes maps local files to their modification time.
+type fileTimes map[string]time.Time
+
+// changed returns the files which have been modified, created or
+// deleted since the modification times were recorded.
+func (ft fileTimes) changed() []string {
+	changed := []string{}
+	for name, last := range ft {
+		mtime := time.Time{}
+		if fi, err := os.Stat(filepath.FromSlash(name)); err == nil {
+			mtime = fi.ModTime()
+		}
+		if !mtime.Equal(last) {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// modTimes returns the modification times of the local files the given
+// files are read from: Files inside an archive are watched via the archive,
+// remote files are not watched as they do not change. Nonexisting files
+// have the zero time.
+func modTimes(names []string) fileTimes {
+	times := make(fileTimes)
+	for _, name := range names {
+		local, ok := suite.LocalFile(name)
+		if !ok {
+			continue
+		}
+		times[local] = time.Time{}
+		if fi, err := os.Stat(filepath.FromSlash(local)); err == nil {
+			times[local] = fi.ModTime()
+		}
+	}
+	return times
+}
+
+// reloadSuite loads the suite filename from disk and prepares it like
+// loadSuites does.
+func reloadSuite(filename string) (*suite.RawSuite, error) {
+	rs, err := suite.LoadRawSuite(filename, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := rs.SelectProfile(profileFlag); err != nil {
+		return nil, err
+	}
+	if err := rs.Validate(variablesFlag); err != nil {
+		return nil, err
+	}
+	setVerbosity(rs)
+	return rs, nil
+}
+
+// runWatched executes rs and prints a compact summary to w: One line with
+// the overall status and one line for each test which did not pass.
+func runWatched(w io.Writer, rs *suite.RawSuite, variables map[string]string, jar *cookiejar.Jar, color bool) {
+	logger := log.New(ioutil.Discard, "", 0)
+	outcome := rs.Execute(variables, jar, logger)
+	_, skipped, passed, failed, errored, bogus := outcome.Stats()
+	w = scope.MaskingWriter(w)
+	fmt.Fprintf(w, "%s %s  (%d passed, %d failed, %d errored, %d bogus, %d skipped in %s)\n",
+		colored(color, outcome.Status, fmt.Sprintf("%-5s", strings.ToUpper(outcome.Status.String()))),
+		rs.File.Name, passed, failed, errored, bogus, skipped,
+		outcome.Duration)
+	for _, test := range outcome.Tests {
+		status := test.Result.Status
+		if status == ht.Pass || status == ht.Skipped || status == ht.NotRun {
+			continue
+		}
+		fmt.Fprintf(w, "    %s %s %s\n", colored(color, status, fmt.Sprintf("%-5s", status)),
+			test.GetStringMetadata("Filename"), test.Name)
+		if test.Result.Error != nil {
+			fmt.Fprintf(w, "          %s\n", test.Result.Error)
+		}
+	}
+}
+
+// colored returns s in the color of status if color is true.
+func colored(color bool, status ht.Status, s string) string {
+	if !color {
+		return s
+	}
+	code := "32" // green
+	switch status {
+	case ht.Fail:
+		code = "31" // red
+	case ht.Error, ht.Bogus:
+		code = "35" // magenta
+	case ht.NotRun, ht.Skipped:
+		code = "33" // yellow
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
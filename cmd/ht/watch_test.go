@@ -0,0 +1,125 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vdobler/ht/cookiejar"
+	"github.com/vdobler/ht/suite"
+)
+
+func TestWatcher(t *testing.T) {
+	// The server rejects requests sending the cookie it sets.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("session"); err == nil {
+			http.Error(w, "cookie reused", http.StatusConflict)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "1", Path: "/"})
+		if r.URL.Path != "/ok" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	f, _ := zw.Create("a.ht")
+	f.Write([]byte(`{
+    Name: "Test A"
+    Request: { URL: "{{HOST}}{{PATH}}" }
+    Checks: [ {Check: "StatusCode", Expect: 200} ]
+}`))
+	zw.Close()
+	archive := filepath.Join(dir, "tests.zip")
+	ioutil.WriteFile(archive, buf.Bytes(), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "s.suite"), []byte(`{
+    Name: "Watched"
+    KeepCookies: true
+    Main: [ {File: "`+filepath.ToSlash(archive)+`!a.ht"} ]
+}`), 0644)
+	varsFile := filepath.Join(dir, "vars.json")
+	ioutil.WriteFile(varsFile, []byte(`{PATH: "/bad"}`), 0644)
+
+	defer func(vf string, cv map[string]string) {
+		variablesFile, cmdlineVariables = vf, cv
+	}(variablesFile, cmdlineVariables)
+	variablesFile = varsFile
+	cmdlineVariables = map[string]string{"HOST": ts.URL}
+	variables, err := reloadVariables()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	rs, err := suite.LoadRawSuite(filepath.ToSlash(filepath.Join(dir, "s.suite")), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	jar, _ := cookiejar.New(nil)
+	out := &bytes.Buffer{}
+	w := newWatcher(out, []*suite.RawSuite{rs}, variables, jar)
+	if _, ok := w.suites[0].files[filepath.ToSlash(archive)]; !ok ||
+		len(w.suites[0].files) != 2 {
+		t.Errorf("Got watched files %v", w.suites[0].files)
+	}
+
+	w.run(w.suites[0])
+	if got := out.String(); !strings.HasPrefix(got, "FAIL ") {
+		t.Errorf("Got first run\n%s", got)
+	}
+
+	// Changing the -Dfile re-executes the suite with the new variables
+	// and without the cookie of the previous run.
+	out.Reset()
+	ioutil.WriteFile(varsFile, []byte(`{PATH: "/ok"}`), 0644)
+	touch(t, varsFile)
+	w.check()
+	if got := out.String(); !strings.Contains(got, "vars.json changed") ||
+		!strings.Contains(got, "PASS ") {
+		t.Errorf("Got run after -Dfile change\n%s", got)
+	}
+
+	out.Reset()
+	w.check()
+	if got := out.String(); got != "" {
+		t.Errorf("Got run without change\n%s", got)
+	}
+
+	// Tests inside an archive are watched via the archive.
+	touch(t, archive)
+	w.check()
+	if got := out.String(); !strings.Contains(got, "tests.zip changed") ||
+		!strings.Contains(got, "PASS ") {
+		t.Errorf("Got run after archive change\n%s", got)
+	}
+}
+
+// touch sets the modification time of name to a fresh time in the future.
+func touch(t *testing.T, name string) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mtime := fi.ModTime().Add(time.Second)
+	if err := os.Chtimes(name, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -50,6 +50,9 @@ type Options struct {
 	// NotifyOn is the bitmap which determines which changes to the
 	// jar will result in a notification message on the Notify channel.
 	NotifyOn Notification
+
+	// Policy restricts the cookies accepted by the jar.
+	Policy Policy
 }
 
 // Jar implements the http.CookieJar interface from the net/http package.
@@ -68,6 +71,9 @@ type Jar struct {
 	// nextSeqNum is the next sequence number assigned to a new cookie
 	// created SetCookies.
 	nextSeqNum uint64
+
+	// policy restricts the accepted cookies.
+	policy Policy
 }
 
 // New returns a new cookie jar. A nil *Options is equivalent to a zero
@@ -80,6 +86,7 @@ func New(o *Options) (*Jar, error) {
 		jar.psList = o.PublicSuffixList
 		jar.notify = o.Notify
 		jar.notifyOn = o.NotifyOn
+		jar.policy = o.Policy.normalized()
 	}
 	return jar, nil
 }
@@ -277,6 +284,9 @@ func (j *Jar) setCookies(u *url.URL, cookies []*http.Cookie, now time.Time) {
 		if err != nil {
 			continue
 		}
+		if !j.policy.accepts(host, e.Domain, j.psList) {
+			continue
+		}
 		id := e.ID()
 		if remove {
 			if submap != nil {
@@ -570,7 +580,6 @@ func (j *Jar) listEntries(eTLDplus1 string, dst []Entry, now time.Time) []Entry
 			// Do not delete like in func cookies. Like this we
 			// do not have to decide whether or not to send a
 			// modification signal on the Notify channel.
-			println("TTTT", e.Name, e.Value)
 			continue
 		}
 		dst = append(dst, e)
@@ -580,7 +589,8 @@ func (j *Jar) listEntries(eTLDplus1 string, dst []Entry, now time.Time) []Entry
 
 // LoadEntries stores the values in entries in the jar j. Existing entries are updated.
 // Invalied entries like expired entries or entries not acceptable due to the
-// public suffix list in use are dropped silently.
+// public suffix list in use or the Allow and Reject lists of the jar's Policy
+// are dropped silently.
 // LoadEntries does not generate modification notifications on the Notify
 // channel.
 func (j *Jar) LoadEntries(entries []Entry) {
@@ -589,7 +599,7 @@ func (j *Jar) LoadEntries(entries []Entry) {
 	defer j.mu.Unlock()
 
 	for _, e := range entries {
-		if !isValidJarEntry(e, now) {
+		if !isValidJarEntry(e, now) || !j.policy.allows(e.Domain) {
 			continue
 		}
 		key := jarKey(e.Domain, j.psList)
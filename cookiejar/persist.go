@@ -0,0 +1,212 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cookiejar
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AllEntries returns all entries stored in j sorted by domain, path and
+// name. Expired cookies are not returned.
+func (j *Jar) AllEntries() []Entry {
+	if j == nil {
+		return nil
+	}
+	now := time.Now()
+	entries := []Entry{}
+	for _, etldp1 := range j.ETLDsPlus1(nil) {
+		entries = j.listEntries(etldp1, entries, now)
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		ea, eb := entries[a], entries[b]
+		if ea.Domain != eb.Domain {
+			return ea.Domain < eb.Domain
+		}
+		if ea.Path != eb.Path {
+			return ea.Path < eb.Path
+		}
+		return ea.Name < eb.Name
+	})
+	return entries
+}
+
+// SetEntries replaces all entries stored in the jar j by entries which
+// should have been obtained from j by AllEntries, e.g. to undo the changes
+// made to j since. SetEntries does not generate modification notifications
+// on the Notify channel.
+func (j *Jar) SetEntries(entries []Entry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.entries = make(map[string]map[string]Entry)
+	for _, e := range entries {
+		key := jarKey(e.Domain, j.psList)
+		submap := j.entries[key]
+		if submap == nil {
+			submap = make(map[string]Entry)
+			j.entries[key] = submap
+		}
+		submap[e.ID()] = e
+	}
+}
+
+// WriteJSON writes all entries of j as a JSON array to w.
+func (j *Jar) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(j.AllEntries(), "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// ReadJSON loads the entries of the JSON array read from r (as written by
+// WriteJSON) into j like LoadEntries.
+func (j *Jar) ReadJSON(r io.Reader) error {
+	entries := []Entry{}
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return fmt.Errorf("cookiejar: malformed JSON: %s", err)
+	}
+	j.LoadEntries(entries)
+	return nil
+}
+
+// httpOnlyPrefix marks HttpOnly cookies in the Netscape format.
+const httpOnlyPrefix = "#HttpOnly_"
+
+// WriteNetscape writes all entries of j in the Netscape cookies.txt format
+// used by curl and wget to w. Session cookies are written with an
+// expiration of 0.
+func (j *Jar) WriteNetscape(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	buf.WriteString("# Netscape HTTP Cookie File\n\n")
+	for _, e := range j.AllEntries() {
+		domain, subdomains := e.Domain, "FALSE"
+		if !e.HostOnly {
+			domain, subdomains = "."+domain, "TRUE"
+		}
+		if e.HttpOnly {
+			domain = httpOnlyPrefix + domain
+		}
+		var expires int64
+		if e.Persistent {
+			expires = e.Expires.Unix()
+		}
+		fmt.Fprintf(buf, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", domain, subdomains,
+			e.Path, netscapeBool(e.Secure), expires, e.Name, e.Value)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func netscapeBool(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// ReadNetscape loads the cookies read in the Netscape cookies.txt format
+// from r into j like LoadEntries. Cookies with an expiration of 0 are
+// session cookies.
+func (j *Jar) ReadNetscape(r io.Reader) error {
+	now := time.Now()
+	entries := []Entry{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := strings.HasPrefix(text, httpOnlyPrefix)
+		if httpOnly {
+			text = text[len(httpOnlyPrefix):]
+		}
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) == 6 {
+			fields = append(fields, "") // empty value
+		}
+		if len(fields) != 7 {
+			return fmt.Errorf("cookiejar: line %d: got %d fields, want 7",
+				line, len(fields))
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return fmt.Errorf("cookiejar: line %d: bad expiration %q",
+				line, fields[4])
+		}
+		e := Entry{
+			Name:       fields[5],
+			Value:      fields[6],
+			Domain:     strings.ToLower(strings.TrimPrefix(fields[0], ".")),
+			Path:       fields[2],
+			Secure:     strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly:   httpOnly,
+			HostOnly:   !strings.EqualFold(fields[1], "TRUE"),
+			Persistent: expires != 0,
+			Expires:    endOfTime,
+			Creation:   now,
+			LastAccess: now,
+		}
+		if e.Persistent {
+			e.Expires = time.Unix(expires, 0)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	j.LoadEntries(entries)
+	return nil
+}
+
+// Save writes all entries of j to the file filename: Files with the
+// extension ".json" are written with WriteJSON, all others in the Netscape
+// cookies.txt format with WriteNetscape.
+func (j *Jar) Save(filename string) error {
+	buf := &bytes.Buffer{}
+	var err error
+	if strings.ToLower(filepath.Ext(filename)) == ".json" {
+		err = j.WriteJSON(buf)
+	} else {
+		err = j.WriteNetscape(buf)
+	}
+	if err != nil {
+		return err
+	}
+	// Cookies may contain session secrets.
+	return ioutil.WriteFile(filename, buf.Bytes(), 0600)
+}
+
+// Load loads the cookies stored in the file filename into j. The format
+// (JSON or Netscape cookies.txt) is detected from the content.
+func (j *Jar) Load(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		err = j.ReadJSON(bytes.NewReader(data))
+	} else {
+		err = j.ReadNetscape(bytes.NewReader(data))
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s", filename, strings.TrimPrefix(err.Error(), "cookiejar: "))
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cookiejar
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const curlCookies = `# Netscape HTTP Cookie File
+# https://curl.se/docs/http-cookies.html
+
+.example.org	TRUE	/	FALSE	0	lang	en
+#HttpOnly_www.example.org	FALSE	/shop	TRUE	4102444800	session	abc123
+www.example.org	FALSE	/	FALSE	1	expired	x
+www.example.org	FALSE	/	FALSE	0	empty
+`
+
+func TestReadNetscape(t *testing.T) {
+	jar, _ := New(nil)
+	if err := jar.ReadNetscape(strings.NewReader(curlCookies)); err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	entries := jar.AllEntries()
+	if len(entries) != 3 {
+		t.Fatalf("Got %d entries: %+v", len(entries), entries)
+	}
+	if e := entries[0]; e.Name != "lang" || e.Domain != "example.org" ||
+		e.HostOnly || e.Persistent || e.HttpOnly {
+		t.Errorf("Got %+v", e)
+	}
+	if e := entries[1]; e.Name != "empty" || e.Value != "" || !e.HostOnly {
+		t.Errorf("Got %+v", e)
+	}
+	if e := entries[2]; e.Name != "session" || e.Value != "abc123" ||
+		e.Domain != "www.example.org" || !e.HostOnly || !e.Secure ||
+		!e.HttpOnly || !e.Persistent || e.Expires.Year() != 2100 {
+		t.Errorf("Got %+v", e)
+	}
+
+	u, _ := url.Parse("https://www.example.org/shop/cart")
+	got := []string{}
+	for _, c := range jar.Cookies(u) {
+		got = append(got, c.String())
+	}
+	if strings.Join(got, "; ") != "session=abc123; lang=en; empty=" {
+		t.Errorf("Got cookies %v", got)
+	}
+
+	err := jar.ReadNetscape(strings.NewReader("example.org\tTRUE\t/\n"))
+	if err == nil || err.Error() != "cookiejar: line 1: got 3 fields, want 7" {
+		t.Errorf("Got error %v", err)
+	}
+}
+
+func TestSetEntries(t *testing.T) {
+	jar, _ := New(nil)
+	if err := jar.ReadNetscape(strings.NewReader(curlCookies)); err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	want := &bytes.Buffer{}
+	jar.WriteNetscape(want)
+	saved := jar.AllEntries()
+
+	u, _ := url.Parse("http://www.example.org/")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "lang", Value: "de"},
+		{Name: "session", Value: "", MaxAge: -1},
+		{Name: "tracking", Value: "t1"},
+	})
+	jar.SetEntries(saved)
+	got := &bytes.Buffer{}
+	jar.WriteNetscape(got)
+	if got.String() != want.String() {
+		t.Errorf("Got\n%s\nWant\n%s", got, want)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cookiejar")
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	jar, _ := New(nil)
+	if err := jar.ReadNetscape(strings.NewReader(curlCookies)); err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	u, _ := url.Parse("http://api.example.org/")
+	jar.SetCookies(u, []*http.Cookie{{Name: "token", Value: "t1",
+		Expires: time.Now().Add(time.Hour)}})
+
+	for _, name := range []string{"cookies.txt", "cookies.json"} {
+		filename := filepath.Join(dir, name)
+		if err := jar.Save(filename); err != nil {
+			t.Fatalf("%s: Unexpected error %s", name, err)
+		}
+		loaded, _ := New(nil)
+		if err := loaded.Load(filename); err != nil {
+			t.Fatalf("%s: Unexpected error %s", name, err)
+		}
+		want, got := &bytes.Buffer{}, &bytes.Buffer{}
+		jar.WriteNetscape(want)
+		loaded.WriteNetscape(got)
+		if got.String() != want.String() {
+			t.Errorf("%s: Got\n%s\nWant\n%s", name, got, want)
+		}
+	}
+
+	if err := jar.Load(filepath.Join(dir, "missing.txt")); !os.IsNotExist(err) {
+		t.Errorf("Got error %v", err)
+	}
+}
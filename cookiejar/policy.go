@@ -0,0 +1,142 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cookiejar
+
+import (
+	"net/http/cookiejar"
+	"strings"
+)
+
+// Policy restricts the cookies a Jar accepts beyond the rules of RFC 6265.
+// Domains in the lists include their subdomains, i.e. "example.org" covers
+// "www.example.org" too. The zero value accepts all cookies.
+type Policy struct {
+	// Allow lists the domains cookies may be stored for. An empty
+	// list allows all domains.
+	Allow []string `json:",omitempty"`
+
+	// Reject lists the domains no cookies are stored for. Reject takes
+	// precedence over Allow.
+	Reject []string `json:",omitempty"`
+
+	// FirstParty lists domains of the system under test. If non-empty,
+	// cookies set by hosts which do not belong to the site (the eTLD+1)
+	// of one of these domains are third-party cookies and are rejected.
+	FirstParty []string `json:",omitempty"`
+}
+
+// IsZero reports whether p accepts all cookies.
+func (p Policy) IsZero() bool {
+	return len(p.Allow) == 0 && len(p.Reject) == 0 && len(p.FirstParty) == 0
+}
+
+// normalized returns a copy of p with all domains in canonical form.
+func (p Policy) normalized() Policy {
+	canonical := func(domains []string) []string {
+		if len(domains) == 0 {
+			return nil
+		}
+		c := make([]string, 0, len(domains))
+		for _, d := range domains {
+			d = strings.TrimPrefix(strings.TrimSpace(d), ".")
+			if host, err := canonicalHost(d); err == nil {
+				d = host
+			}
+			c = append(c, d)
+		}
+		return c
+	}
+	return Policy{
+		Allow:      canonical(p.Allow),
+		Reject:     canonical(p.Reject),
+		FirstParty: canonical(p.FirstParty),
+	}
+}
+
+// allows reports whether cookies for domain are allowed by the Allow and
+// Reject lists of p.
+func (p Policy) allows(domain string) bool {
+	if inDomains(domain, p.Reject) {
+		return false
+	}
+	return len(p.Allow) == 0 || inDomains(domain, p.Allow)
+}
+
+// accepts reports whether a cookie for domain set in a response from host
+// is accepted by p.
+func (p Policy) accepts(host, domain string, psl cookiejar.PublicSuffixList) bool {
+	if !p.allows(domain) {
+		return false
+	}
+	if len(p.FirstParty) == 0 {
+		return true
+	}
+	site := jarKey(host, psl)
+	for _, fp := range p.FirstParty {
+		if jarKey(fp, psl) == site {
+			return true
+		}
+	}
+	return false
+}
+
+// inDomains reports whether domain is one of domains or a subdomain thereof.
+func inDomains(domain string, domains []string) bool {
+	for _, d := range domains {
+		if domain == d || hasDotSuffix(domain, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetPolicy sets the policy of j. Cookies already stored are not affected.
+func (j *Jar) SetPolicy(p Policy) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.policy = p.normalized()
+}
+
+// Policy returns the policy of j.
+func (j *Jar) Policy() Policy {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.policy
+}
+
+// DomainEntries returns the entries stored for domain or one of its
+// subdomains sorted like AllEntries.
+func (j *Jar) DomainEntries(domain string) []Entry {
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	entries := []Entry{}
+	for _, e := range j.AllEntries() {
+		if inDomains(e.Domain, []string{domain}) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Delete removes the cookies with the given name stored for domain or one
+// of its subdomains; an empty name removes all these cookies. It returns
+// the number of removed cookies. Delete does not generate notifications.
+func (j *Jar) Delete(name, domain string) int {
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	n := 0
+	for key, submap := range j.entries {
+		for id, e := range submap {
+			if (name == "" || e.Name == name) && inDomains(e.Domain, []string{domain}) {
+				delete(submap, id)
+				n++
+			}
+		}
+		if len(submap) == 0 {
+			delete(j.entries, key)
+		}
+	}
+	return n
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cookiejar
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func jarContent(jar *Jar) string {
+	ids := []string{}
+	for _, e := range jar.AllEntries() {
+		ids = append(ids, e.ID())
+	}
+	return strings.Join(ids, " ")
+}
+
+func TestPolicy(t *testing.T) {
+	policy := Policy{
+		Allow:      []string{"example.org", ".Tracker.NET"},
+		Reject:     []string{"ads.example.org"},
+		FirstParty: []string{"www.example.org"},
+	}
+	jar, _ := New(&Options{Policy: policy})
+	for _, set := range []struct{ u, domain string }{
+		{"http://www.example.org/", ""},
+		{"http://www.example.org/", "example.org"},
+		{"http://ads.example.org/", ""},
+		{"http://api.example.org/", ""},
+		{"http://tracker.net/", ""}, // third-party
+		{"http://other.com/", ""},   // not allowed
+	} {
+		u, _ := url.Parse(set.u)
+		jar.SetCookies(u, []*http.Cookie{{Name: "c", Value: "1", Domain: set.domain}})
+	}
+	want := "api.example.org;/;c example.org;/;c www.example.org;/;c"
+	if got := jarContent(jar); got != want {
+		t.Errorf("Got  %s\nWant %s", got, want)
+	}
+
+	jar.SetPolicy(Policy{Reject: []string{"example.org"}})
+	if p := jar.Policy(); len(p.Reject) != 1 || len(p.Allow) != 0 {
+		t.Errorf("Got policy %+v", p)
+	}
+	u, _ := url.Parse("http://tracker.net/")
+	jar.SetCookies(u, []*http.Cookie{{Name: "t", Value: "2"}})
+	jar.LoadEntries([]Entry{{Name: "l", Value: "3", Domain: "www.example.org", Path: "/"}})
+	want = "api.example.org;/;c example.org;/;c tracker.net;/;t www.example.org;/;c"
+	if got := jarContent(jar); got != want {
+		t.Errorf("Got %s", got)
+	}
+}
+
+func TestDomainEntriesAndDelete(t *testing.T) {
+	jar, _ := New(nil)
+	for _, s := range []string{"http://www.example.org/", "http://api.example.org/",
+		"http://example.org/", "http://other.com/"} {
+		u, _ := url.Parse(s)
+		jar.SetCookies(u, []*http.Cookie{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}})
+	}
+	if got := len(jar.DomainEntries("example.org")); got != 6 {
+		t.Errorf("Got %d entries for example.org", got)
+	}
+	if got := len(jar.DomainEntries(".WWW.example.org")); got != 2 {
+		t.Errorf("Got %d entries for www.example.org", got)
+	}
+	if n := jar.Delete("a", "example.org"); n != 3 {
+		t.Errorf("Deleted %d cookies", n)
+	}
+	if n := jar.Delete("", "api.example.org"); n != 1 {
+		t.Errorf("Deleted %d cookies", n)
+	}
+	want := "example.org;/;b other.com;/;a other.com;/;b www.example.org;/;b"
+	if got := jarContent(jar); got != want {
+		t.Errorf("Got  %s\nWant %s", got, want)
+	}
+}
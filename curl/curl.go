@@ -0,0 +1,448 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package curl converts curl command lines into ht tests.
+//
+// The command line is split into words like a POSIX shell would do it
+// (including $'...' quoting as used by the "Copy as cURL" function of
+// browsers) and the options describing the request are translated.
+// The generated request is the counterpart of ht.Test.CurlCall.
+package curl
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/internal/convert"
+)
+
+// Split splits the command line s into words like a POSIX shell.
+func Split(s string) ([]string, error) {
+	words := []string{}
+	word := []byte{}
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, string(word))
+				word, inWord = word[:0], false
+			}
+		case c == '\\':
+			if i+1 < len(s) {
+				i++
+				if s[i] != '\n' {
+					word = append(word, s[i])
+					inWord = true
+				}
+			}
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote")
+			}
+			word = append(word, s[i+1:i+1+end]...)
+			i += end + 1
+			inWord = true
+		case c == '$' && i+1 < len(s) && s[i+1] == '\'':
+			n, text, err := ansiC(s[i+2:])
+			if err != nil {
+				return nil, err
+			}
+			word = append(word, text...)
+			i += n + 2
+			inWord = true
+		case c == '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("$`\"\\\n", s[i+1]) >= 0 {
+					i++
+					if s[i] == '\n' {
+						continue
+					}
+				}
+				word = append(word, s[i])
+			}
+			if i == len(s) {
+				return nil, fmt.Errorf("unterminated double quote")
+			}
+			inWord = true
+		default:
+			word = append(word, c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, string(word))
+	}
+	return words, nil
+}
+
+// ansiC decodes the content of a $'...' string starting after the opening
+// quote. It returns the number of bytes consumed including the closing
+// quote.
+func ansiC(s string) (int, []byte, error) {
+	text := []byte{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\'' {
+			return i, text, nil
+		}
+		if c != '\\' || i+1 == len(s) {
+			text = append(text, c)
+			continue
+		}
+		i++
+		switch e := s[i]; e {
+		case 'n':
+			text = append(text, '\n')
+		case 't':
+			text = append(text, '\t')
+		case 'r':
+			text = append(text, '\r')
+		case 'x', 'u':
+			digits := 2
+			if e == 'u' {
+				digits = 4
+			}
+			j := i + 1
+			for j < len(s) && j < i+1+digits && strings.IndexByte("0123456789abcdefABCDEF", s[j]) >= 0 {
+				j++
+			}
+			n, err := strconv.ParseUint(s[i+1:j], 16, 32)
+			if err != nil {
+				return 0, nil, fmt.Errorf("bad escape \\%c in $'...'", e)
+			}
+			if e == 'x' {
+				text = append(text, byte(n))
+			} else {
+				text = append(text, string(rune(n))...)
+			}
+			i = j - 1
+		default:
+			text = append(text, e)
+		}
+	}
+	return 0, nil, fmt.Errorf("unterminated $' quote")
+}
+
+// Options of curl which do not influence the request and are ignored.
+var ignoredFlags = map[string]bool{
+	"-s": true, "--silent": true, "-S": true, "--show-error": true,
+	"-v": true, "--verbose": true, "-i": true, "--include": true,
+	"-k": true, "--insecure": true, "--compressed": true,
+	"-g": true, "--globoff": true, "-N": true, "--no-buffer": true,
+	"-f": true, "--fail": true, "--http1.1": true, "--http2": true,
+	"-#": true, "--progress-bar": true,
+}
+
+// Options of curl which take an argument but are ignored.
+var ignoredOptions = map[string]bool{
+	"-o": true, "--output": true, "-w": true, "--write-out": true,
+	"--connect-timeout": true, "--retry": true, "-c": true, "--cookie-jar": true,
+}
+
+// Options of curl which take an argument and are translated.
+var argOptions = map[string]bool{
+	"-X": true, "--request": true, "--url": true, "-H": true, "--header": true,
+	"-A": true, "--user-agent": true, "-e": true, "--referer": true,
+	"-b": true, "--cookie": true, "-u": true, "--user": true,
+	"-d": true, "--data": true, "--data-ascii": true, "--data-raw": true,
+	"--data-binary": true, "--data-urlencode": true, "-F": true, "--form": true,
+	"-m": true, "--max-time": true,
+}
+
+// Test is a reduced version of ht.Test suitable to serialization to JSON.
+type Test = convert.Test
+
+// Parse translates the curl command line into a test checking for a
+// status code of 200. Options which cannot be translated are reported
+// as warnings.
+func Parse(command string) (test *Test, warnings []string, err error) {
+	words, err := Split(command)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(words) == 0 || path.Base(words[0]) != "curl" {
+		return nil, nil, fmt.Errorf("not a curl command")
+	}
+
+	req := ht.Request{Header: http.Header{}}
+	var data, forms []string
+	binary, get, head := false, false, false
+	arg := func(i int) (string, error) {
+		if i+1 >= len(words) {
+			return "", fmt.Errorf("missing argument to %s", words[i])
+		}
+		return words[i+1], nil
+	}
+
+	words = expandShortFlags(words[1:])
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		if !strings.HasPrefix(w, "-") || w == "-" {
+			if req.URL != "" {
+				warnings = append(warnings, "ignoring additional URL "+w)
+				continue
+			}
+			req.URL = w
+			continue
+		}
+		if ignoredFlags[w] {
+			continue
+		}
+		a, err := arg(i)
+		switch w {
+		case "-I", "--head":
+			head = true
+			continue
+		case "-G", "--get":
+			get = true
+			continue
+		case "-L", "--location":
+			req.FollowRedirects = true
+			continue
+		}
+		if !argOptions[w] && !ignoredOptions[w] {
+			warnings = append(warnings, "ignoring unknown option "+w)
+			continue // assume it takes no argument
+		}
+		if err != nil {
+			return nil, warnings, err
+		}
+		i++
+
+		switch w {
+		case "-X", "--request":
+			req.Method = strings.ToUpper(a)
+		case "--url":
+			req.URL = a
+		case "-H", "--header":
+			if err := addHeader(&req, a); err != nil {
+				warnings = append(warnings, err.Error())
+			}
+		case "-A", "--user-agent":
+			req.Header.Set("User-Agent", a)
+		case "-e", "--referer":
+			req.Header.Set("Referer", a)
+		case "-b", "--cookie":
+			if !strings.Contains(a, "=") {
+				warnings = append(warnings, "ignoring cookie file "+a)
+				continue
+			}
+			addCookies(&req, a)
+		case "-u", "--user":
+			parts := strings.SplitN(a, ":", 2)
+			req.BasicAuthUser = parts[0]
+			if len(parts) == 2 {
+				req.BasicAuthPass = parts[1]
+			}
+		case "-d", "--data", "--data-ascii", "--data-raw":
+			if w != "--data-raw" && strings.HasPrefix(a, "@") {
+				binary = true
+				a = "@file:" + a[1:]
+			}
+			data = append(data, a)
+		case "--data-binary":
+			binary = true
+			if strings.HasPrefix(a, "@") {
+				a = "@file:" + a[1:]
+			}
+			data = append(data, a)
+		case "--data-urlencode":
+			if j := strings.Index(a, "="); j >= 0 {
+				a = a[:j+1] + url.QueryEscape(a[j+1:])
+			} else {
+				a = url.QueryEscape(a)
+			}
+			data = append(data, a)
+		case "-F", "--form":
+			forms = append(forms, a)
+		case "-m", "--max-time":
+			secs, err := strconv.ParseFloat(a, 64)
+			if err != nil {
+				return nil, warnings, fmt.Errorf("bad --max-time %q", a)
+			}
+			req.Timeout = time.Duration(secs * float64(time.Second))
+		}
+	}
+	if req.URL == "" {
+		return nil, warnings, fmt.Errorf("missing URL")
+	}
+	if !strings.Contains(req.URL, "://") {
+		req.URL = "http://" + req.URL
+	}
+
+	if len(forms) > 0 {
+		req.ParamsAs = "multipart"
+		req.Params = url.Values{}
+		for _, f := range forms {
+			parts := strings.SplitN(f, "=", 2)
+			if len(parts) != 2 {
+				return nil, warnings, fmt.Errorf("bad form field %q", f)
+			}
+			value := parts[1]
+			if strings.HasPrefix(value, "@") {
+				value = "@file:" + value[1:]
+			}
+			req.Params.Add(parts[0], value)
+		}
+	}
+	if len(data) > 0 {
+		body := strings.Join(data, "&")
+		switch {
+		case get:
+			if strings.Contains(req.URL, "?") {
+				req.URL += "&" + body
+			} else {
+				req.URL += "?" + body
+			}
+		case !binary && isForm(req.Header) && len(forms) == 0:
+			if params, ok := formParams(body); ok {
+				req.Params = params
+				req.ParamsAs = "body"
+				break
+			}
+			fallthrough
+		default:
+			req.Body = body
+			if req.Header.Get("Content-Type") == "" {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+		}
+	}
+
+	switch {
+	case req.Method != "":
+	case head:
+		req.Method = "HEAD"
+	case (len(data) > 0 && !get) || len(forms) > 0:
+		req.Method = "POST"
+	default:
+		req.Method = "GET"
+	}
+	if len(req.Header) == 0 {
+		req.Header = nil
+	}
+
+	test = &Test{Name: name(req), Request: req}
+	test.AddCheck("StatusCode", "Expect", 200)
+	return test, warnings, nil
+}
+
+// expandShortFlags splits combined short flags like -sSL.
+func expandShortFlags(words []string) []string {
+	expanded := make([]string, 0, len(words))
+	for _, w := range words {
+		if len(w) <= 2 || w[0] != '-' || w[1] == '-' {
+			expanded = append(expanded, w)
+			continue
+		}
+		flags := []string{}
+		for _, c := range w[1:] {
+			f := "-" + string(c)
+			if !ignoredFlags[f] && f != "-L" && f != "-I" && f != "-G" {
+				flags = nil
+				break
+			}
+			flags = append(flags, f)
+		}
+		if flags == nil {
+			expanded = append(expanded, w)
+			continue
+		}
+		expanded = append(expanded, flags...)
+	}
+	return expanded
+}
+
+func addHeader(req *ht.Request, h string) error {
+	if strings.HasSuffix(h, ";") && !strings.Contains(h, ":") {
+		// curl's syntax for a header without value.
+		req.Header[http.CanonicalHeaderKey(strings.TrimSuffix(h, ";"))] = []string{""}
+		return nil
+	}
+	parts := strings.SplitN(h, ":", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("ignoring malformed header %q", h)
+	}
+	name, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+	if value == "" {
+		// curl removes internal headers given without value.
+		return nil
+	}
+	if http.CanonicalHeaderKey(name) == "Cookie" {
+		addCookies(req, value)
+		return nil
+	}
+	req.Header.Add(name, value)
+	return nil
+}
+
+// addCookies adds the cookies in "name1=value1; name2=value2".
+func addCookies(req *ht.Request, s string) {
+	for _, nv := range strings.Split(s, ";") {
+		nv = strings.TrimSpace(nv)
+		if nv == "" {
+			continue
+		}
+		parts := strings.SplitN(nv, "=", 2)
+		cookie := ht.Cookie{Name: parts[0]}
+		if len(parts) == 2 {
+			cookie.Value = parts[1]
+		}
+		req.Cookies = append(req.Cookies, cookie)
+	}
+}
+
+// isForm reports whether the Content-Type in header (if any) is
+// application/x-www-form-urlencoded.
+func isForm(header http.Header) bool {
+	ct := header.Get("Content-Type")
+	return ct == "" || strings.HasPrefix(ct, "application/x-www-form-urlencoded")
+}
+
+// formParams parses body as url encoded parameters if each name and value
+// is either properly encoded or contains nothing which needs decoding.
+func formParams(body string) (url.Values, bool) {
+	params := url.Values{}
+	for _, pair := range strings.Split(body, "&") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, false
+		}
+		name, err1 := url.QueryUnescape(parts[0])
+		value, err2 := url.QueryUnescape(parts[1])
+		if err1 != nil || err2 != nil ||
+			!plainOrEscaped(name, parts[0]) || !plainOrEscaped(value, parts[1]) {
+			return nil, false
+		}
+		params.Add(name, value)
+	}
+	return params, true
+}
+
+func plainOrEscaped(s, raw string) bool {
+	return url.QueryEscape(s) == raw || !strings.ContainsAny(raw, "%+")
+}
+
+// name returns a name for the test made of the method and the path of the
+// requested URL.
+func name(req ht.Request) string {
+	p := req.URL
+	if u, err := url.Parse(req.URL); err == nil {
+		p = u.Path
+		if p == "" {
+			p = "/"
+		}
+	}
+	return req.Method + " " + p
+}
@@ -0,0 +1,137 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curl
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vdobler/ht/ht"
+)
+
+func TestSplit(t *testing.T) {
+	for i, tc := range []struct {
+		in   string
+		want []string
+	}{
+		{`curl  http://x`, []string{"curl", "http://x"}},
+		{`a 'b c' "d \"e\" $f" g\ h`, []string{"a", "b c", `d "e" $f`, "g h"}},
+		{"a \\\n  b", []string{"a", "b"}},
+		{`a 'foo'"'"'bar'`, []string{"a", "foo'bar"}},
+		{`a $'x\ny\x41\'z'`, []string{"a", "x\nyA'z"}},
+		{`a '' b`, []string{"a", "", "b"}},
+	} {
+		got, err := Split(tc.in)
+		if err != nil {
+			t.Errorf("%d. Unexpected error: %s", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%d. Got %q, want %q", i, got, tc.want)
+		}
+	}
+
+	if _, err := Split(`a 'b`); err == nil {
+		t.Errorf("Missing error for unterminated quote")
+	}
+}
+
+func TestParse(t *testing.T) {
+	test, warnings, err := Parse(`curl -sSL -X put 'http://localhost:8080/api/pets/42' \
+  -H 'Content-Type: application/json' -H 'X-Empty;' -H 'Cookie: a=1; b=2' \
+  -u admin:secret --max-time 2.5 --data-binary '{"name": "Rex"}' --foo`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(warnings) != 1 || warnings[0] != "ignoring unknown option --foo" {
+		t.Errorf("Got warnings %q", warnings)
+	}
+	want := ht.Request{
+		Method: "PUT",
+		URL:    "http://localhost:8080/api/pets/42",
+		Header: http.Header{
+			"Content-Type": {"application/json"},
+			"X-Empty":      {""},
+		},
+		Cookies:         []ht.Cookie{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}},
+		Body:            `{"name": "Rex"}`,
+		FollowRedirects: true,
+		BasicAuthUser:   "admin",
+		BasicAuthPass:   "secret",
+		Timeout:         2500 * time.Millisecond,
+	}
+	if !reflect.DeepEqual(test.Request, want) {
+		t.Errorf("Got  %+v\nwant %+v", test.Request, want)
+	}
+	if test.Name != "PUT /api/pets/42" {
+		t.Errorf("Got name %q", test.Name)
+	}
+
+	test, _, err = Parse(`curl localhost/search -G -d q=go --data-urlencode 'x=a b'`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if test.Request.Method != "GET" || test.Request.URL != "http://localhost/search?q=go&x=a+b" {
+		t.Errorf("Got %+v", test.Request)
+	}
+
+	test, _, err = Parse(`curl -F name=Rex -F photo=@rex.jpg http://localhost/pets`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if test.Request.Method != "POST" || test.Request.ParamsAs != "multipart" ||
+		!reflect.DeepEqual(test.Request.Params, url.Values{
+			"name": {"Rex"}, "photo": {"@file:rex.jpg"}}) {
+		t.Errorf("Got %+v", test.Request)
+	}
+
+	for _, bad := range []string{`wget http://x`, `curl -X`, `curl -H 'A: b'`} {
+		if _, _, err := Parse(bad); err == nil {
+			t.Errorf("Missing error for %s", bad)
+		}
+	}
+}
+
+// Converting a test to a curl call and back must result in the same request.
+func TestRoundTrip(t *testing.T) {
+	orig := &ht.Test{
+		Name: "Round Trip",
+		Request: ht.Request{
+			Method: "POST",
+			URL:    "http://localhost:9999/form",
+			Params: url.Values{
+				"abc": {"12", "34"},
+				"x":   {"it's"},
+			},
+			ParamsAs: "body",
+			Header: http.Header{
+				"Accept": {"*/*"},
+			},
+			Cookies:       []ht.Cookie{{Name: "session", Value: "deadbeef"}},
+			BasicAuthUser: "root",
+			BasicAuthPass: "secret",
+		},
+	}
+	call := orig.CurlCall()
+	test, warnings, err := Parse(call)
+	if err != nil || len(warnings) != 0 {
+		t.Fatalf("Unexpected error %v or warnings %q for %s", err, warnings, call)
+	}
+	got := test.Request
+	if got.Method != "POST" || got.URL != orig.Request.URL || got.ParamsAs != "body" ||
+		!reflect.DeepEqual(got.Params, orig.Request.Params) ||
+		!reflect.DeepEqual(got.Cookies, orig.Request.Cookies) ||
+		got.Header.Get("Accept") != "*/*" ||
+		got.BasicAuthUser != "root" || got.BasicAuthPass != "secret" {
+		t.Errorf("Got %+v from %s", got, call)
+	}
+	if !strings.HasPrefix(call, "curl ") {
+		t.Errorf("Bad call %s", call)
+	}
+}
@@ -2,7 +2,8 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// Package error contains a type to collect errors.
+// Package errorlist contains a type to collect errors and a structured
+// error type with a code, category and field path.
 package errorlist
 
 import (
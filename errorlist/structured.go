@@ -0,0 +1,180 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errorlist
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Category is a coarse classification of an Error.
+type Category string
+
+// The categories of errors.
+const (
+	Network    Category = "network"    // Sending the request or receiving the response failed.
+	Check      Category = "check"      // A check on the response (or its transformation) failed.
+	Validation Category = "validation" // Input like variables violated a constraint.
+	Bogus      Category = "bogus"      // The test or a check is malformed.
+	Hook       Category = "hook"       // A hook script or callback failed.
+)
+
+// Error is an error with machine-readable information about the failure
+// which allows reports and CI integrations to group and filter failures
+// without matching on the error message.
+type Error struct {
+	// Code identifies the kind of failure, e.g. "check.StatusCode"
+	// or "network.timeout".
+	Code string
+
+	// Category classifies the failure.
+	Category Category
+
+	// Field is the path of the offending field, e.g. "Checks[2]" or
+	// "Request.URL". It is empty if unknown.
+	Field string
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Detailer is implemented by error types which provide their code,
+// category and field themselves.
+type Detailer interface {
+	Detail() Error
+}
+
+// New returns an Error of the given category and code wrapping err.
+// New returns nil if err is nil. If err is an Error itself, code and field
+// are used only if err's are empty and cat only if err's category is
+// empty; if err is a List each element is wrapped. Errors implementing
+// Detailer are returned unchanged.
+func New(cat Category, code, field string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(Detailer); ok {
+		return err
+	}
+	if list, ok := err.(List); ok {
+		wrapped := make(List, 0, len(list))
+		for _, e := range list {
+			wrapped = append(wrapped, New(cat, code, field, e))
+		}
+		return wrapped
+	}
+	e, ok := err.(Error)
+	if !ok {
+		return Error{Code: code, Category: cat, Field: field, Err: err}
+	}
+	if e.Code == "" {
+		e.Code = code
+	}
+	if e.Category == "" {
+		e.Category = cat
+	}
+	if e.Field == "" {
+		e.Field = field
+	}
+	return e
+}
+
+// Error implements the Error method of error.
+func (e Error) Error() string {
+	if e.Err == nil {
+		return e.Code
+	}
+	return e.Err.Error()
+}
+
+// errorJSON is the JSON representation of an Error.
+type errorJSON struct {
+	Code     string   `json:",omitempty"`
+	Category Category `json:",omitempty"`
+	Field    string   `json:",omitempty"`
+	Message  string
+}
+
+// MarshalJSON implements json.Marshaler. The underlying error is
+// marshalled as its message.
+func (e Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(errorJSON{
+		Code:     e.Code,
+		Category: e.Category,
+		Field:    e.Field,
+		Message:  e.Error(),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (e *Error) UnmarshalJSON(data []byte) error {
+	var ej errorJSON
+	if err := json.Unmarshal(data, &ej); err != nil {
+		return err
+	}
+	*e = Error{
+		Code:     ej.Code,
+		Category: ej.Category,
+		Field:    ej.Field,
+		Err:      errors.New(ej.Message),
+	}
+	return nil
+}
+
+// Details returns the errors in err as a flat slice of Errors. Errors
+// which carry no structured information are returned with empty Code,
+// Category and Field. Details returns nil if err is nil.
+func Details(err error) []Error {
+	if err == nil {
+		return nil
+	}
+	if list, ok := err.(List); ok {
+		return list.Details()
+	}
+	if e, ok := err.(Error); ok {
+		return []Error{e}
+	}
+	if d, ok := err.(Detailer); ok {
+		return []Error{d.Detail()}
+	}
+	return []Error{{Err: err}}
+}
+
+// Details returns the errors in el as a flat slice of Errors, see the
+// function Details.
+func (el List) Details() []Error {
+	if len(el) == 0 {
+		return nil
+	}
+	details := make([]Error, 0, len(el))
+	for _, e := range el {
+		details = append(details, Details(e)...)
+	}
+	return details
+}
+
+// MarshalJSON implements json.Marshaler: el is marshalled as the JSON
+// array of its Details.
+func (el List) MarshalJSON() ([]byte, error) {
+	if el == nil {
+		return []byte("null"), nil
+	}
+	details := el.Details()
+	if details == nil {
+		details = []Error{}
+	}
+	return json.Marshal(details)
+}
+
+// Filter returns the errors in el of category cat.
+func (el List) Filter(cat Category) List {
+	var filtered List
+	for _, e := range el.Details() {
+		if e.Category == cat {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
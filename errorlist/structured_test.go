@@ -0,0 +1,85 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errorlist
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	if New(Check, "check.Body", "Checks[0]", nil) != nil {
+		t.Errorf("Wrapped nil error")
+	}
+
+	err := New(Check, "check.Body", "Checks[0]", fmt.Errorf("too short"))
+	e, ok := err.(Error)
+	if !ok || e.Code != "check.Body" || e.Category != Check || e.Field != "Checks[0]" ||
+		e.Error() != "too short" {
+		t.Errorf("Got %#v", err)
+	}
+
+	// Information already present is kept.
+	inner := Error{Code: "body.length", Err: fmt.Errorf("too short")}
+	err = New(Check, "check.Body", "Checks[1]", List{inner, fmt.Errorf("bad")})
+	details := Details(err)
+	if len(details) != 2 ||
+		details[0].Code != "body.length" || details[0].Category != Check ||
+		details[0].Field != "Checks[1]" ||
+		details[1].Code != "check.Body" || details[1].Error() != "bad" {
+		t.Errorf("Got %+v", details)
+	}
+}
+
+type hostError string
+
+func (e hostError) Error() string { return string(e) }
+func (e hostError) Detail() Error {
+	return Error{Code: "host", Category: Validation, Err: e}
+}
+
+func TestDetailer(t *testing.T) {
+	err := New(Network, "request.http", "Request", hostError("bad host"))
+	if _, ok := err.(hostError); !ok {
+		t.Fatalf("Got %T", err)
+	}
+	if d := Details(err); len(d) != 1 || d[0].Code != "host" || d[0].Category != Validation {
+		t.Errorf("Got %+v", d)
+	}
+}
+
+func TestListJSON(t *testing.T) {
+	el := List{}.Append(fmt.Errorf("plain"))
+	el = el.Append(List{Error{Code: "request.timeout", Category: Network,
+		Field: "Request", Err: fmt.Errorf("i/o timeout")}})
+
+	data, err := json.Marshal(el)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	want := `[{"Message":"plain"},` +
+		`{"Code":"request.timeout","Category":"network","Field":"Request","Message":"i/o timeout"}]`
+	if string(data) != want {
+		t.Errorf("Got  %s\nWant %s", data, want)
+	}
+
+	var details []Error
+	if err := json.Unmarshal(data, &details); err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	if len(details) != 2 || details[1].Category != Network || details[1].Error() != "i/o timeout" {
+		t.Errorf("Got %+v", details)
+	}
+
+	if got := el.Filter(Network); len(got) != 1 || got.Error() != "i/o timeout" {
+		t.Errorf("Got %v", got)
+	}
+
+	data, _ = json.Marshal(struct{ L List }{})
+	if string(data) != `{"L":null}` {
+		t.Errorf("Got %s", data)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gui
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// ----------------------------------------------------------------------------
+// Structural diff
+
+// Change is a single difference between two values found by Diff.
+type Change struct {
+	Path string // Path of the changed element, same format as in the form
+	Kind string // Kind of change: added, removed or changed
+	Old  string // Old value, empty for added elements
+	New  string // New value, empty for removed elements
+}
+
+// Diff compares old and new structurally and returns the changes in the
+// order the fields are rendered. Struct fields are compared individually
+// (fields omitted by Typedata are ignored), maps key by key and slices
+// element by element. Diff works on arbitrary values including generic
+// soups like map[string]interface{} decoded from JSON.
+func Diff(old, new interface{}, path string) []Change {
+	changes := []Change{}
+	diff(path, reflect.ValueOf(old), reflect.ValueOf(new), &changes)
+	return changes
+}
+
+func diff(path string, a, b reflect.Value, changes *[]Change) {
+	switch {
+	case !a.IsValid() && !b.IsValid():
+		return
+	case !a.IsValid():
+		*changes = append(*changes, Change{Path: path, Kind: "added", New: formatValue(b)})
+		return
+	case !b.IsValid():
+		*changes = append(*changes, Change{Path: path, Kind: "removed", Old: formatValue(a)})
+		return
+	case a.Type() != b.Type():
+		*changes = append(*changes, Change{Path: path, Kind: "changed",
+			Old: formatValue(a), New: formatValue(b)})
+		return
+	}
+
+	switch a.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if a.IsNil() && b.IsNil() {
+			return
+		} else if a.IsNil() {
+			diff(path, reflect.Value{}, b.Elem(), changes)
+		} else if b.IsNil() {
+			diff(path, a.Elem(), reflect.Value{}, changes)
+		} else {
+			diff(path, a.Elem(), b.Elem(), changes)
+		}
+		return
+	case reflect.Struct:
+		if hasExportedFields(a.Type()) {
+			diffStruct(path, a, b, changes)
+			return
+		}
+	case reflect.Map:
+		diffMap(path, a, b, changes)
+		return
+	case reflect.Slice:
+		if a.Type().Elem().Kind() != reflect.Uint8 {
+			diffSlice(path, a, b, changes)
+			return
+		}
+	}
+
+	if unwalkable(a) || !a.CanInterface() {
+		return
+	}
+	if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+		*changes = append(*changes, Change{Path: path, Kind: "changed",
+			Old: formatValue(a), New: formatValue(b)})
+	}
+}
+
+func diffStruct(path string, a, b reflect.Value, changes *[]Change) {
+	typ := a.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		name := typ.Field(i).Name
+		if unexported(name) || Typedata[typ].Field[name].Omit {
+			continue
+		}
+		diff(path+"."+name, a.Field(i), b.Field(i), changes)
+	}
+}
+
+func diffMap(path string, a, b reflect.Value, changes *[]Change) {
+	keys := a.MapKeys()
+	for _, k := range b.MapKeys() {
+		if !a.MapIndex(k).IsValid() {
+			keys = append(keys, k)
+		}
+	}
+	sortMapKeys(keys)
+	for _, k := range keys {
+		name, err := keyString(k)
+		if err != nil {
+			name = fmt.Sprint(k.Interface())
+		}
+		diff(path+"."+mangleKey(name), a.MapIndex(k), b.MapIndex(k), changes)
+	}
+}
+
+func diffSlice(path string, a, b reflect.Value, changes *[]Change) {
+	n := a.Len()
+	if b.Len() > n {
+		n = b.Len()
+	}
+	for i := 0; i < n; i++ {
+		var ea, eb reflect.Value
+		if i < a.Len() {
+			ea = a.Index(i)
+		}
+		if i < b.Len() {
+			eb = b.Index(i)
+		}
+		diff(fmt.Sprintf("%s.%d", path, i), ea, eb, changes)
+	}
+}
+
+func hasExportedFields(typ reflect.Type) bool {
+	for i := 0; i < typ.NumField(); i++ {
+		if !unexported(typ.Field(i).Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatValue formats val for display in a diff. Strings are quoted to make
+// changes in whitespace visible.
+func formatValue(val reflect.Value) string {
+	if !val.CanInterface() {
+		return "?"
+	}
+	if val.Kind() == reflect.String {
+		return strconv.Quote(val.String())
+	}
+	if val.Kind() == reflect.Slice && val.Type().Elem().Kind() == reflect.Uint8 {
+		return fmt.Sprintf("%d bytes", val.Len())
+	}
+	return fmt.Sprintf("%+v", val.Interface())
+}
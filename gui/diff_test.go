@@ -0,0 +1,86 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gui
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	type Inner struct {
+		N int
+	}
+	type Outer struct {
+		Name    string
+		Tags    []string
+		Header  map[string]string
+		Inner   *Inner
+		Any     interface{}
+		When    time.Time
+		Ignored string
+		hidden  int
+	}
+	defer delete(Typedata, reflect.TypeOf(Outer{}))
+	RegisterType(Outer{}, Typeinfo{Field: map[string]Fieldinfo{
+		"Ignored": {Omit: true},
+	}})
+
+	old := Outer{
+		Name:    "old",
+		Tags:    []string{"a", "b"},
+		Header:  map[string]string{"Accept": "text/html", "X.Y": "1"},
+		Inner:   &Inner{N: 1},
+		Any:     3,
+		When:    time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC),
+		Ignored: "x",
+		hidden:  1,
+	}
+	new := Outer{
+		Name:    "new",
+		Tags:    []string{"a"},
+		Header:  map[string]string{"Accept": "text/html", "Z": "2"},
+		Any:     "three",
+		When:    time.Date(2017, 1, 2, 0, 0, 0, 0, time.UTC),
+		Ignored: "y",
+		hidden:  2,
+	}
+
+	got := Diff(old, new, "O")
+	want := []Change{
+		{Path: "O.Name", Kind: "changed", Old: `"old"`, New: `"new"`},
+		{Path: "O.Tags.1", Kind: "removed", Old: `"b"`},
+		{Path: "O.Header.X%2EY", Kind: "removed", Old: `"1"`},
+		{Path: "O.Header.Z", Kind: "added", New: `"2"`},
+		{Path: "O.Inner", Kind: "removed", Old: "{N:1}"},
+		{Path: "O.Any", Kind: "changed", Old: "3", New: `"three"`},
+		{Path: "O.When", Kind: "changed", Old: "2017-01-01 00:00:00 +0000 UTC",
+			New: "2017-01-02 00:00:00 +0000 UTC"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got\n%+v\nwant\n%+v", got, want)
+	}
+
+	if got := Diff(old, old, "O"); len(got) != 0 {
+		t.Errorf("Unexpected changes %+v", got)
+	}
+}
+
+func TestDiffSoup(t *testing.T) {
+	old := map[string]interface{}{
+		"Name":   "Test",
+		"Checks": []interface{}{map[string]interface{}{"Check": "StatusCode", "Expect": 200.0}},
+	}
+	new := map[string]interface{}{
+		"Name":   "Test",
+		"Checks": []interface{}{map[string]interface{}{"Check": "StatusCode", "Expect": 404.0}},
+	}
+	got := Diff(old, new, "S")
+	want := []Change{{Path: "S.Checks.0.Expect", Kind: "changed", Old: "200", New: "404"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %+v", got)
+	}
+}
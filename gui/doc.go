@@ -18,4 +18,9 @@
 //
 //
 //
+// Suite Browser
+//
+// A SuiteBrowser serves pages below /suites which list the suites found in
+// a directory tree, execute them in the background and show the results of
+// the latest run of each suite test by test.
 package gui
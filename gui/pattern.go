@@ -0,0 +1,145 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gui
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"unicode"
+)
+
+// jsPattern translates re to the syntax of a JavaScript regular expression
+// as used in the pattern attribute of HTML input elements (which the
+// browser compiles with the u or v flag). The second return value is false
+// if re uses features which cannot be expressed in JavaScript, e.g.
+// multi-line anchors.
+func jsPattern(re *regexp.Regexp) (string, bool) {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+	buf := &bytes.Buffer{}
+	if !writeJS(buf, parsed) {
+		return "", false
+	}
+	return buf.String(), true
+}
+
+func writeJS(buf *bytes.Buffer, re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpNoMatch:
+		buf.WriteString("[]")
+	case syntax.OpEmptyMatch:
+		buf.WriteString("(?:)")
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if re.Flags&syntax.FoldCase != 0 && unicode.SimpleFold(r) != r {
+				buf.WriteString("[")
+				for f := r; ; {
+					writeJSRune(buf, f)
+					if f = unicode.SimpleFold(f); f == r {
+						break
+					}
+				}
+				buf.WriteString("]")
+				continue
+			}
+			writeJSRune(buf, r)
+		}
+	case syntax.OpCharClass:
+		buf.WriteString("[")
+		for i := 0; i < len(re.Rune); i += 2 {
+			writeJSRune(buf, re.Rune[i])
+			if re.Rune[i+1] != re.Rune[i] {
+				buf.WriteString("-")
+				writeJSRune(buf, re.Rune[i+1])
+			}
+		}
+		buf.WriteString("]")
+	case syntax.OpAnyCharNotNL:
+		buf.WriteString(".")
+	case syntax.OpAnyChar:
+		buf.WriteString(`[\s\S]`)
+	case syntax.OpBeginText:
+		buf.WriteString("^")
+	case syntax.OpEndText:
+		buf.WriteString("$")
+	case syntax.OpWordBoundary:
+		buf.WriteString(`\b`)
+	case syntax.OpNoWordBoundary:
+		buf.WriteString(`\B`)
+	case syntax.OpCapture:
+		return writeJSGroup(buf, re.Sub[0])
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		if !writeJSGroup(buf, re.Sub[0]) {
+			return false
+		}
+		switch re.Op {
+		case syntax.OpStar:
+			buf.WriteString("*")
+		case syntax.OpPlus:
+			buf.WriteString("+")
+		case syntax.OpQuest:
+			buf.WriteString("?")
+		default:
+			if re.Max == -1 {
+				fmt.Fprintf(buf, "{%d,}", re.Min)
+			} else if re.Max == re.Min {
+				fmt.Fprintf(buf, "{%d}", re.Min)
+			} else {
+				fmt.Fprintf(buf, "{%d,%d}", re.Min, re.Max)
+			}
+		}
+		if re.Flags&syntax.NonGreedy != 0 {
+			buf.WriteString("?")
+		}
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if sub.Op == syntax.OpAlternate {
+				if !writeJSGroup(buf, sub) {
+					return false
+				}
+				continue
+			}
+			if !writeJS(buf, sub) {
+				return false
+			}
+		}
+	case syntax.OpAlternate:
+		for i, sub := range re.Sub {
+			if i > 0 {
+				buf.WriteString("|")
+			}
+			if !writeJS(buf, sub) {
+				return false
+			}
+		}
+	default:
+		// OpBeginLine and OpEndLine have no equivalent without the m flag.
+		return false
+	}
+	return true
+}
+
+// writeJSGroup writes re as a non-capturing group.
+func writeJSGroup(buf *bytes.Buffer, re *syntax.Regexp) bool {
+	buf.WriteString("(?:")
+	ok := writeJS(buf, re)
+	buf.WriteString(")")
+	return ok
+}
+
+// writeJSRune writes r literally if it is a letter or digit and as a
+// \u{...} escape otherwise which is valid inside and outside of character
+// classes.
+func writeJSRune(buf *bytes.Buffer, r rune) {
+	if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+		buf.WriteRune(r)
+		return
+	}
+	fmt.Fprintf(buf, `\u{%x}`, r)
+}
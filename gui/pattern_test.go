@@ -0,0 +1,33 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gui
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestJSPattern(t *testing.T) {
+	for i, tc := range []struct {
+		re   string
+		want string
+		ok   bool
+	}{
+		{`a|ab`, `a(?:(?:)|b)`, true},
+		{`^[[:xdigit:]]{4,8}$`, `^(?:[0-9A-Fa-f]){4,8}$`, true},
+		{`\d+\.\d*`, `(?:[0-9])+\u{2e}(?:[0-9])*`, true},
+		{`(?i)ok`, `[Oo][Kk\u{212a}]`, true},
+		{`(?P<name>x)+?`, `(?:(?:x))+?`, true},
+		{`\Ax\z`, `^x$`, true},
+		{`[^/]`, `[\u{0}-\u{2e}0-\u{10ffff}]`, true},
+		{`(?m)^x$`, ``, false},
+	} {
+		got, ok := jsPattern(regexp.MustCompile(tc.re))
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("%d. %s: got %q, %t; want %q, %t",
+				i, tc.re, got, ok, tc.want, tc.ok)
+		}
+	}
+}
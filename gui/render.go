@@ -13,6 +13,7 @@ import (
 	"net/url"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 	"unicode"
@@ -87,7 +88,7 @@ func (v *Value) render(path string, depth int, readonly bool, val reflect.Value)
 		return v.renderInterface(path, depth, readonly, val)
 	}
 
-	fmt.Println("gui: won't render", val.Kind().String(), "in", path)
+	infof("gui: won't render %s in %s", val.Kind().String(), path)
 
 	return nil
 }
@@ -254,11 +255,24 @@ func (v *Value) renderString(path string, depth int, readonly bool, val reflect.
 		v.printf("%s<input type=\"hidden\" name=\"%s\" value=\"\"/>\n",
 			indent(depth+1), name)
 
+	} else if re := v.nextfieldinfo.Validate; re != nil {
+		// Patterns which cannot be checked in the browser are
+		// validated on the server only.
+		pattern := ""
+		if js, ok := jsPattern(re); ok {
+			pattern = fmt.Sprintf(` pattern="%s"`, template.HTMLEscapeString(js))
+		}
+		v.printf("<input type=\"text\" name=\"%s\" value=\"%s\"%s title=\"Must match %s\" />\n",
+			template.HTMLEscapeString(path),
+			escVal, pattern, template.HTMLEscapeString(re.String()))
 	} else {
 		v.printf("<input type=\"text\" name=\"%s\" value=\"%s\" />\n",
 			template.HTMLEscapeString(path),
 			escVal)
 	}
+	if v.nextfieldinfo.Upload {
+		v.renderUpload(path, depth)
+	}
 	return nil
 }
 
@@ -325,11 +339,26 @@ func (v *Value) renderBinaryData(path string, depth int, readonly bool, data []b
 	v.printf("%s<a target=\"_blank\" href=\"/binary?path=%s\">Open</a>\n",
 		indent(depth), q)
 
-	// TODO handle non-readonly binaries, e.g. via file upload.
+	if !readonly {
+		v.renderUpload(path, depth)
+	}
 
 	return nil
 }
 
+// renderUpload renders controls to set the field path from an uploaded
+// file or from a file on the server. The form must be sent as
+// multipart/form-data and parsed with ParseUploadForm.
+func (v *Value) renderUpload(path string, depth int) {
+	escPath := template.HTMLEscapeString(path)
+	v.printf("%s<div class=\"upload\">\n", indent(depth))
+	v.printf("%s<input type=\"file\" name=\"%s.__UPLOAD__\" />\n",
+		indent(depth+1), escPath)
+	v.printf("%s<input type=\"text\" name=\"%s.__LOAD__\" placeholder=\"or path of file on server\" />\n",
+		indent(depth+1), escPath)
+	v.printf("%s</div>\n", indent(depth))
+}
+
 // ----------------------------------------------------------------------------
 // Special Types
 
@@ -505,20 +534,34 @@ func (v *Value) renderSlice(path string, depth int, readonly bool, val reflect.V
 		field := val.Index(i)
 		fieldPath := fmt.Sprintf("%s.%d", path, i)
 
-		v.printf("%s<tr id=\"%s\">\n",
-			indent(depth+1),
-			template.HTMLEscapeString(fieldPath))
+		if readonly {
+			v.printf("%s<tr id=\"%s\">\n",
+				indent(depth+1),
+				template.HTMLEscapeString(fieldPath))
+		} else {
+			v.printf("%s<tr id=\"%s\" ondragover=\"event.preventDefault()\" ondrop=\"guiDrop(event)\">\n",
+				indent(depth+1),
+				template.HTMLEscapeString(fieldPath))
+		}
 
 		// Index number and controls.
 		v.printf("%s<td>%d:</td>\n", indent(depth+2), i)
 		if !readonly {
-			v.printf("%s<td><button name=\"%s\" value=\"Remove\">-</button></td>\n",
-				indent(depth+2),
-				template.HTMLEscapeString(fieldPath+".__OP__"),
-			)
-			if false && i > 0 {
-				v.printf("<button>↑</button> ")
+			op := template.HTMLEscapeString(fieldPath + ".__OP__")
+			v.printf("%s<td>\n", indent(depth+2))
+			v.printf("%s<span class=\"draghandle\" draggable=\"true\" ondragstart=\"guiDragStart(event)\" title=\"Drag to reorder\">&#x2630;</span>\n",
+				indent(depth+3))
+			v.printf("%s<button name=\"%s\" value=\"Remove\">-</button>\n",
+				indent(depth+3), op)
+			if i > 0 {
+				v.printf("%s<button name=\"%s\" value=\"Up\" title=\"Move up\">&uarr;</button>\n",
+					indent(depth+3), op)
+			}
+			if i < val.Len()-1 {
+				v.printf("%s<button name=\"%s\" value=\"Down\" title=\"Move down\">&darr;</button>\n",
+					indent(depth+3), op)
 			}
+			v.printf("%s</td>\n", indent(depth+2))
 		}
 
 		// The field itself.
@@ -629,7 +672,11 @@ func (v *Value) renderMap(path string, depth int, readonly bool, val reflect.Val
 
 	for _, k := range keys {
 		mv := val.MapIndex(k)
-		name := k.String() // BUG: panics if map is indexed by anything else than strings
+		name, e := keyString(k)
+		if e != nil {
+			v.printf("%s</table>\n", indent(depth))
+			return e
+		}
 		elemPath := path + "." + mangleKey(name)
 		v.printf("%s<tr id=\"%s\">\n",
 			indent(depth+1), template.HTMLEscapeString(elemPath))
@@ -642,8 +689,7 @@ func (v *Value) renderMap(path string, depth int, readonly bool, val reflect.Val
 			template.HTMLEscapeString(name))
 
 		v.printf("%s<td>\n", indent(depth+2))
-		e := v.render(elemPath, depth+3, readonly, mv)
-		if e != nil {
+		if e := v.render(elemPath, depth+3, readonly, mv); e != nil {
 			err = e
 		}
 		v.printf("%s</td>\n", indent(depth+2))
@@ -672,27 +718,95 @@ func (v *Value) renderMap(path string, depth int, readonly bool, val reflect.Val
 	return err
 }
 
-// mangleName takes an arbitrary key of a map and produces a string
-// suitable as a HTML form parameter.
+// keyString formats the map key k. Only maps indexed by strings, integers
+// and bools are supported.
+func keyString(k reflect.Value) (string, error) {
+	switch k.Kind() {
+	case reflect.String:
+		return k.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	case reflect.Bool:
+		return strconv.FormatBool(k.Bool()), nil
+	}
+	return "", fmt.Errorf("gui: cannot handle map key of kind %s", k.Kind())
+}
+
+// parseKey is the inverse of keyString: It parses s into a map key of
+// type typ.
+func parseKey(s string, typ reflect.Type) (reflect.Value, error) {
+	key := reflect.New(typ).Elem()
+	switch typ.Kind() {
+	case reflect.String:
+		key.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, typ.Bits())
+		if err != nil {
+			return key, err
+		}
+		key.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, typ.Bits())
+		if err != nil {
+			return key, err
+		}
+		key.SetUint(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return key, err
+		}
+		key.SetBool(b)
+	default:
+		return key, fmt.Errorf("gui: cannot handle map key of kind %s", typ.Kind())
+	}
+	return key, nil
+}
+
+// mangleKey takes an arbitrary key of a map and produces a string
+// suitable as a HTML form parameter and as an element of a path: All
+// bytes except ASCII letters, digits, '-' and '_' are %-encoded. A
+// leading '_' is encoded too to prevent collisions with __OP__ and
+// __NEW__.
 func mangleKey(n string) string {
-	return n // TODO
+	buf := make([]byte, 0, len(n))
+	for i := 0; i < len(n); i++ {
+		c := n[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') ||
+			('0' <= c && c <= '9') || c == '-' || (c == '_' && i > 0) {
+			buf = append(buf, c)
+			continue
+		}
+		buf = append(buf, fmt.Sprintf("%%%02X", c)...)
+	}
+	return string(buf)
 }
 
 // demangleKey is the inverse of mangleKey
-func demangleKey(n string) string {
-	return n // TODO
+func demangleKey(n string) (string, error) {
+	return url.PathUnescape(n)
 }
 
+// sortMapKeys sorts keys in their natural order.
 func sortMapKeys(keys []reflect.Value) {
 	if len(keys) == 0 {
 		return
 	}
 
-	if keys[0].Kind() == reflect.String {
-		sort.Slice(keys, func(i, j int) bool {
-			return keys[i].String() < keys[j].String()
-		})
+	var less func(a, b reflect.Value) bool
+	switch keys[0].Kind() {
+	case reflect.String:
+		less = func(a, b reflect.Value) bool { return a.String() < b.String() }
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		less = func(a, b reflect.Value) bool { return a.Int() < b.Int() }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		less = func(a, b reflect.Value) bool { return a.Uint() < b.Uint() }
+	case reflect.Bool:
+		less = func(a, b reflect.Value) bool { return !a.Bool() && b.Bool() }
+	default:
+		return
 	}
-
-	// TODO at least ints too.
+	sort.Slice(keys, func(i, j int) bool { return less(keys[i], keys[j]) })
 }
@@ -5,6 +5,7 @@
 package gui
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -28,3 +29,61 @@ func TestBinaryString(t *testing.T) {
 	}
 
 }
+
+func TestMangleKey(t *testing.T) {
+	for i, tc := range []struct {
+		key, want string
+	}{
+		{"Foo", "Foo"},
+		{"foo_bar-7", "foo_bar-7"},
+		{"a.b", "a%2Eb"},
+		{"__OP__", "%5F_OP__"},
+		{"x y/z%", "x%20y%2Fz%25"},
+		{"Ä", "%C3%84"},
+	} {
+		got := mangleKey(tc.key)
+		if got != tc.want {
+			t.Errorf("%d: mangleKey(%q)=%q, want %q", i, tc.key, got, tc.want)
+		}
+		if back, err := demangleKey(got); err != nil || back != tc.key {
+			t.Errorf("%d: demangleKey(%q)=%q, %v", i, got, back, err)
+		}
+	}
+}
+
+func TestRenderIntMap(t *testing.T) {
+	v := NewValue(map[int]string{10: "ten", -2: "minus two", 3: "three"}, "M")
+	out, err := v.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(out)
+	a := strings.Index(html, `id="M.-2"`)
+	b := strings.Index(html, `id="M.3"`)
+	c := strings.Index(html, `id="M.10"`)
+	if a == -1 || !(a < b && b < c) {
+		t.Errorf("Bad rendering or order %d %d %d:\n%s", a, b, c, html)
+	}
+
+	v = NewValue(map[float64]string{1.5: "x"}, "F")
+	if _, err := v.Render(); err == nil {
+		t.Errorf("Missing error for float keys")
+	}
+}
+
+func TestRenderReadonly(t *testing.T) {
+	type S struct {
+		Name string
+		List []int
+	}
+	v := NewValue(S{Name: "Hello", List: []int{1, 2}}, "S")
+	out, err := v.RenderReadonly()
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(out)
+	if !strings.Contains(html, "Hello") || strings.Contains(html, "<input") ||
+		strings.Contains(html, "<button") {
+		t.Errorf("Bad readonly rendering:\n%s", html)
+	}
+}
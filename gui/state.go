@@ -58,6 +58,7 @@ type Fieldinfo struct {
 	Any       []string       // Any contains the set of allowed values (pick any)
 	Validate  *regexp.Regexp // Validate this field
 	Omit      bool           // Omit this field
+	Upload    bool           // Upload allows to set this field from a file
 }
 
 // ----------------------------------------------------------------------------
@@ -113,6 +114,11 @@ p.msg-notrun {
   margin: 2px 0px 2px 10px;
 }
 
+p.msg-info {
+  color: steelblue;
+  margin: 2px 0px 2px 10px;
+}
+
 
 table {
   border-collapse: collapse;
@@ -171,6 +177,10 @@ input[type="text"] {
   width: 400px;
 }
 
+input:invalid {
+  border: 2px solid red;
+}
+
 label {
   display: inline-block;
   width: 7em;
@@ -196,6 +206,40 @@ label {
 div.implements-buttons {
   margin-right: 250px;
 }
+
+span.draghandle {
+  cursor: move;
+  color: #777;
+}
+`
+
+// ----------------------------------------------------------------------------
+// JavaScript
+
+// JS contains the JavaScript needed to reorder slice elements by drag and
+// drop: Dropping a slice element on an other element of the same slice
+// submits the form with a move operation.
+var JS = `
+function guiDragStart(ev) {
+  ev.dataTransfer.setData("text/plain", ev.currentTarget.closest("tr").id);
+}
+
+function guiDrop(ev) {
+  ev.preventDefault();
+  var from = ev.dataTransfer.getData("text/plain");
+  var to = ev.currentTarget.id;
+  var i = from.lastIndexOf("."), j = to.lastIndexOf(".");
+  if (i < 0 || from.substring(0, i) != to.substring(0, j)) {
+    return; // not an element of the same slice
+  }
+  var input = document.createElement("input");
+  input.type = "hidden";
+  input.name = from.substring(0, i) + ".__MOVE__";
+  input.value = from.substring(i + 1) + ":" + to.substring(j + 1);
+  var form = ev.currentTarget.closest("form");
+  form.appendChild(input);
+  form.submit();
+}
 `
 
 // ----------------------------------------------------------------------------
@@ -0,0 +1,480 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gui
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/vdobler/ht/cookiejar"
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/logging"
+	"github.com/vdobler/ht/scope"
+	"github.com/vdobler/ht/suite"
+)
+
+// ----------------------------------------------------------------------------
+// Suite browser and result dashboard
+
+// SuiteBrowser lists all suites below Dir, executes them on request in
+// the background and keeps the result of the latest run of each suite.
+// Its pages are served below /suites.
+type SuiteBrowser struct {
+	Dir string
+
+	// Variables, Jar and Logger are used for each execution of a suite.
+	Variables map[string]string
+	Jar       *cookiejar.Jar
+	Logger    logging.Logger
+
+	// Edit is called to open a test of a suite run in the test editor.
+	// Edit is not called concurrently.
+	Edit func(test *ht.Test) error
+
+	mu      sync.Mutex
+	results map[string]*suite.Suite // latest results indexed by filename
+	running map[string]bool         // suites currently executed
+	runs    sync.WaitGroup
+}
+
+// NewSuiteBrowser returns a SuiteBrowser for the suites below dir.
+func NewSuiteBrowser(dir string) *SuiteBrowser {
+	return &SuiteBrowser{
+		Dir:     dir,
+		results: make(map[string]*suite.Suite),
+		running: make(map[string]bool),
+	}
+}
+
+// Register the handlers of the suite browser with http.DefaultServeMux.
+func (sb *SuiteBrowser) Register() {
+	http.HandleFunc("/suites", sb.listHandler)
+	http.HandleFunc("/suites/run", sb.runHandler)
+	http.HandleFunc("/suites/show", sb.showHandler)
+	http.HandleFunc("/suites/test", sb.testHandler)
+	http.HandleFunc("/suites/edit", sb.editHandler)
+	http.HandleFunc("/suites/inspect", sb.inspectHandler)
+}
+
+// suiteFiles returns all suite files below sb.Dir. Hidden and vendor
+// directories are skipped.
+func (sb *SuiteBrowser) suiteFiles() ([]string, error) {
+	files := []string{}
+	err := filepath.Walk(sb.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // ignore unreadable parts
+		}
+		name := info.Name()
+		if info.IsDir() {
+			if path != sb.Dir && (strings.HasPrefix(name, ".") || name == "vendor") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(name, ".suite") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// known reports whether filename is one of the suites below sb.Dir.
+func (sb *SuiteBrowser) known(filename string) bool {
+	files, _ := sb.suiteFiles()
+	for _, f := range files {
+		if f == filename {
+			return true
+		}
+	}
+	return false
+}
+
+func (sb *SuiteBrowser) result(filename string) *suite.Suite {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.results[filename]
+}
+
+// start executes the suite read from filename in the background. The
+// result is recorded once the execution is done.
+func (sb *SuiteBrowser) start(filename string) error {
+	rs, err := suite.LoadRawSuite(filename, nil)
+	if err != nil {
+		return err
+	}
+	if err := rs.Validate(sb.Variables); err != nil {
+		return err
+	}
+	rs.SetLogger(sb.Logger)
+
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	if sb.running[filename] {
+		return fmt.Errorf("suite %s is already running", filename)
+	}
+	sb.running[filename] = true
+	sb.runs.Add(1)
+	go func() {
+		defer sb.runs.Done()
+		outcome := rs.Execute(sb.Variables, sb.Jar, nil)
+		sb.mu.Lock()
+		sb.results[filename] = outcome
+		delete(sb.running, filename)
+		sb.mu.Unlock()
+	}()
+	return nil
+}
+
+// isRunning reports whether the suite filename is currently executed.
+func (sb *SuiteBrowser) isRunning(filename string) bool {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.running[filename]
+}
+
+// suiteTest returns the test number n (1-based) of the latest run of the
+// suite given in the request.
+func (sb *SuiteBrowser) suiteTest(req *http.Request) (*ht.Test, error) {
+	filename := req.FormValue("suite")
+	s := sb.result(filename)
+	if s == nil {
+		return nil, fmt.Errorf("no results for suite %s", filename)
+	}
+	n, err := strconv.Atoi(req.FormValue("n"))
+	if err != nil || n < 1 || n > len(s.Tests) {
+		return nil, fmt.Errorf("no test %q in suite %s", req.FormValue("n"), filename)
+	}
+	return s.Tests[n-1], nil
+}
+
+type suiteEntry struct {
+	File    string
+	Result  *suite.Suite
+	Running bool
+}
+
+func (sb *SuiteBrowser) listHandler(w http.ResponseWriter, req *http.Request) {
+	files, err := sb.suiteFiles()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	entries := make([]suiteEntry, len(files))
+	running := false
+	for i, f := range files {
+		entries[i] = suiteEntry{File: f, Result: sb.result(f), Running: sb.isRunning(f)}
+		running = running || entries[i].Running
+	}
+	sb.writePage(w, req, "SUITES", struct {
+		Dir     string
+		Entries []suiteEntry
+		Running bool
+		Error   string
+	}{sb.Dir, entries, running, req.FormValue("error")})
+}
+
+func (sb *SuiteBrowser) runHandler(w http.ResponseWriter, req *http.Request) {
+	filename := req.FormValue("suite")
+	if req.Method != "POST" || !sb.known(filename) {
+		http.Error(w, "Bad request", 400)
+		return
+	}
+	if err := sb.start(filename); err != nil {
+		http.Redirect(w, req, "/suites?error="+url.QueryEscape(err.Error()), 303)
+		return
+	}
+	http.Redirect(w, req, "/suites", 303)
+}
+
+func (sb *SuiteBrowser) showHandler(w http.ResponseWriter, req *http.Request) {
+	filename := req.FormValue("suite")
+	s := sb.result(filename)
+	if s == nil {
+		http.Error(w, "No results for suite "+filename, 404)
+		return
+	}
+	sb.writePage(w, req, "SUITE", struct {
+		File  string
+		Suite *suite.Suite
+	}{filename, s})
+}
+
+func (sb *SuiteBrowser) testHandler(w http.ResponseWriter, req *http.Request) {
+	test, err := sb.suiteTest(req)
+	if err != nil {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+	details, err := NewValue(*test, "Test").RenderReadonly()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	sb.writePage(w, req, "TEST", struct {
+		File    string
+		N       string
+		Test    *ht.Test
+		Details template.HTML
+	}{req.FormValue("suite"), req.FormValue("n"), test, template.HTML(details)})
+}
+
+type scopeRow struct {
+	Name  string
+	Cells []scopeCell
+}
+
+// scopeCell is the state of one variable in one layer of the scope.
+type scopeCell struct {
+	Value   string // Value is the effective value.
+	Set     bool   // Set if variable is set in the effective scope.
+	Defined bool   // Defined in this layer.
+	Ignored string // Ignored is the definition overruled by an outer layer.
+}
+
+// scopeTable arranges the variables of all layers in a table.
+func scopeTable(layers []suite.ScopeLayer) []scopeRow {
+	names := []string{}
+	for name := range layers[len(layers)-1].Effective {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rows := make([]scopeRow, len(names))
+	for i, name := range names {
+		rows[i].Name = name
+		for l, layer := range layers {
+			cell := scopeCell{}
+			cell.Value, cell.Set = layer.Effective[name]
+			defined, ok := layer.Defined[name]
+			cell.Defined = ok
+			if ok && l > 0 && defined != cell.Value {
+				// Definitions do not override the outer scope.
+				if outer, set := layers[l-1].Effective[name]; set && outer == cell.Value {
+					cell.Ignored = defined
+				}
+			}
+			rows[i].Cells = append(rows[i].Cells, cell)
+		}
+	}
+	return rows
+}
+
+// inspectHandler shows the variable scope and the substituted test file of
+// a test in a suite without executing anything.
+func (sb *SuiteBrowser) inspectHandler(w http.ResponseWriter, req *http.Request) {
+	filename := req.FormValue("suite")
+	if !sb.known(filename) {
+		http.Error(w, "Unknown suite "+filename, 404)
+		return
+	}
+	rs, err := suite.LoadRawSuite(filename, nil)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	n, err := strconv.Atoi(req.FormValue("n"))
+	if err != nil {
+		n = 1
+	}
+	insp, err := rs.Inspect(sb.Variables, n-1)
+	if err != nil {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+
+	// Mark unresolved variables in the substituted test.
+	preview := template.HTMLEscapeString(insp.Substituted)
+	for _, v := range insp.Unresolved {
+		esc := template.HTMLEscapeString(v)
+		preview = strings.Replace(preview, esc, "<mark>"+esc+"</mark>", -1)
+	}
+
+	sb.writePage(w, req, "INSPECT", struct {
+		File    string
+		N       int
+		Tests   []*suite.RawTest
+		Layers  []suite.ScopeLayer
+		Rows    []scopeRow
+		Insp    *suite.Inspection
+		Preview template.HTML
+	}{filename, n, rs.RawTests(), insp.Layers, scopeTable(insp.Layers),
+		insp, template.HTML(preview)})
+}
+
+// editHandler opens the selected test of a suite run in the test editor.
+func (sb *SuiteBrowser) editHandler(w http.ResponseWriter, req *http.Request) {
+	test, err := sb.suiteTest(req)
+	if err != nil || req.Method != "POST" || sb.Edit == nil {
+		http.Error(w, "Bad request", 400)
+		return
+	}
+	if err := sb.Edit(test); err != nil {
+		http.Error(w, err.Error(), 409)
+		return
+	}
+	http.Redirect(w, req, "/#Test", 303)
+}
+
+func (sb *SuiteBrowser) writePage(w http.ResponseWriter, req *http.Request, name string, data interface{}) {
+	theme := SelectTheme(w, req)
+	tmpl := template.Must(suitesTmpl.Clone()).Funcs(template.FuncMap{
+		"css": func() template.CSS { return template.CSS(ThemeCSS(theme)) },
+	})
+	buf := &bytes.Buffer{}
+	if err := tmpl.ExecuteTemplate(buf, name, data); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(200)
+	w.Write([]byte(scope.Mask(buf.String())))
+}
+
+var suitesTmpl = template.Must(template.New("suites").Funcs(template.FuncMap{
+	"css":   func() template.CSS { return template.CSS(CSS) },
+	"plus1": func(i int) int { return i + 1 },
+	"head": func(title string, refresh bool) interface{} {
+		return struct {
+			Title   string
+			Refresh bool // Refresh the page while suites are running.
+		}{title, refresh}
+	},
+}).Parse(`
+{{define "HEAD"}}<!doctype html>
+<html>
+<head>
+  <meta charset="UTF-8">
+  <title>{{.Title}}</title>{{if .Refresh}}
+  <meta http-equiv="refresh" content="2">{{end}}
+  <style>
+{{css}}
+table.dashboard td, table.dashboard th { border-bottom: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+  </style>
+</head>
+<body>
+  <p><a href="/">Test Builder</a> | <a href="/suites">Suites</a></p>
+{{end}}
+
+{{define "TAIL"}}
+</body>
+</html>
+{{end}}
+
+{{define "SUITES"}}{{template "HEAD" (head "Suites" .Running)}}
+  <h1>Suites in {{.Dir}}</h1>
+  {{if .Error}}<p class="msg-error">{{.Error}}</p>{{end}}
+  <table class="dashboard">
+    <tr><th>Suite</th><th>Status</th><th>Started</th><th>Duration</th><th></th></tr>
+  {{range .Entries}}
+    <tr>
+      <td>{{if .Result}}<a href="/suites/show?suite={{.File}}">{{.File}}</a>{{else}}{{.File}}{{end}}</td>
+      {{if .Running}}
+        <td class="Notrun">running</td><td></td><td></td>
+      {{else if .Result}}
+        <td class="{{.Result.Status}}">{{.Result.Status}}</td>
+        <td>{{.Result.Started.Format "2006-01-02 15:04:05"}}</td>
+        <td>{{.Result.Duration}}</td>
+      {{else}}
+        <td class="Notrun">not run</td><td></td><td></td>
+      {{end}}
+      <td><form action="/suites/run" method="post"><input type="hidden" name="suite" value="{{.File}}"><button>Run</button>
+        <a href="/suites/inspect?suite={{.File}}">Variables</a></form></td>
+    </tr>
+  {{else}}
+    <tr><td colspan="5">No suites found.</td></tr>
+  {{end}}
+  </table>
+{{template "TAIL"}}{{end}}
+
+{{define "SUITE"}}{{template "HEAD" (head .File false)}}
+  <h1>{{.Suite.Name}}</h1>
+  <p>{{.File}}: <strong class="{{.Suite.Status}}">{{.Suite.Status}}</strong>
+    started {{.Suite.Started.Format "2006-01-02 15:04:05"}}, took {{.Suite.Duration}}
+    {{if .Suite.Error}}<br>{{.Suite.Error}}{{end}}</p>
+  {{if .Suite.Description}}<pre>{{.Suite.Description}}</pre>{{end}}
+  <form action="/suites/run" method="post"><input type="hidden" name="suite" value="{{.File}}"><button>Run again</button></form>
+  <table class="dashboard">
+    <tr><th>#</th><th>Test</th><th>Status</th><th>Duration</th><th>Error</th></tr>
+  {{$file := .File}}
+  {{range $i, $t := .Suite.Tests}}
+    <tr>
+      <td>{{plus1 $i}}</td>
+      <td><a href="/suites/test?suite={{$file}}&amp;n={{plus1 $i}}">{{$t.Name}}</a></td>
+      <td class="{{$t.Result.Status}}">{{$t.Result.Status}}</td>
+      <td>{{$t.Result.Duration}}</td>
+      <td>{{if $t.Result.Error}}{{$t.Result.Error}}{{end}}</td>
+    </tr>
+  {{end}}
+  </table>
+  {{if .Suite.FinalVariables}}
+  <h2>Final Variables</h2>
+  <table class="dashboard">
+  {{range $name, $value := .Suite.FinalVariables}}<tr><th>{{$name}}</th><td>{{$value}}</td></tr>
+  {{end}}</table>
+  {{end}}
+{{template "TAIL"}}{{end}}
+
+{{define "INSPECT"}}{{template "HEAD" (head "Variables" false)}}
+  <h1>Variables</h1>
+  <form action="/suites/inspect" method="get">
+    <input type="hidden" name="suite" value="{{.File}}">
+    {{.File}}: <select name="n" onchange="this.form.submit()">
+    {{$n := .N}}
+    {{range $i, $t := .Tests}}<option value="{{plus1 $i}}"{{if eq (plus1 $i) $n}} selected{{end}}>{{plus1 $i}}: {{$t.File.Name}}</option>
+    {{end}}</select>
+    <noscript><button>Inspect</button></noscript>
+  </form>
+  <h2>Scope</h2>
+  <p>Variables defined in a layer are shown in bold; definitions overruled by an
+    outer layer are struck through.</p>
+  <table class="dashboard">
+    <tr><th>Variable</th>{{range .Layers}}<th>{{.Name}}</th>{{end}}</tr>
+  {{range .Rows}}
+    <tr><th>{{.Name}}</th>{{range .Cells}}<td>{{if .Ignored}}<del>{{.Ignored}}</del> {{end}}{{if .Set}}{{if and .Defined (not .Ignored)}}<strong>{{.Value}}</strong>{{else}}{{.Value}}{{end}}{{end}}</td>{{end}}</tr>
+  {{end}}
+  </table>
+  <h2>Substituted Test</h2>
+  {{if .Insp.Unresolved}}<p class="msg-error">Unresolved: {{range .Insp.Unresolved}}{{.}} {{end}}</p>{{end}}
+  {{if .Insp.Error}}<p class="msg-error">{{.Insp.Error}}</p>{{end}}
+  <pre>{{.Preview}}</pre>
+{{template "TAIL"}}{{end}}
+
+{{define "TEST"}}{{template "HEAD" (head .Test.Name false)}}
+  <p><a href="/suites/show?suite={{.File}}">{{.File}}</a> |
+    <a href="/suites/inspect?suite={{.File}}&amp;n={{.N}}">Variables</a></p>
+  <h1>{{.Test.Name}}: <span class="{{.Test.Result.Status}}">{{.Test.Result.Status}}</span></h1>
+  <form action="/suites/edit" method="post">
+    <input type="hidden" name="suite" value="{{.File}}"><input type="hidden" name="n" value="{{.N}}">
+    <button>Open in Test Builder</button>
+  </form>
+  {{if .Test.Result.CheckResults}}
+  <h2>Checks</h2>
+  <table class="dashboard">
+  {{range .Test.Result.CheckResults}}
+    <tr><td>{{.Name}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{.Duration}}</td><td>{{if .Error}}{{.Error}}{{end}}</td></tr>
+  {{end}}
+  </table>
+  {{end}}
+  {{if .Test.Result.Extractions}}
+  <h2>Extracted Variables</h2>
+  <table class="dashboard">
+  {{range $name, $ex := .Test.Result.Extractions}}<tr><th>{{$name}}</th><td>{{$ex.Value}}</td><td>{{if $ex.Error}}{{$ex.Error}}{{end}}</td></tr>
+  {{end}}</table>
+  {{end}}
+  <h2>Details</h2>
+  {{.Details}}
+{{template "TAIL"}}{{end}}
+`))
@@ -0,0 +1,103 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gui
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vdobler/ht/ht"
+)
+
+func TestSuiteBrowser(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Hello World"))
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "gui-suites")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	os.Mkdir(filepath.Join(dir, ".hidden"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, ".hidden", "x.suite"), []byte("{}"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "sub", "hello.ht"), []byte(`{
+    Name: "Hello"
+    Request: { URL: "`+ts.URL+`" }
+    Checks: [ {Check: "Body", Contains: "Hello"} ]
+}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "sub", "bye.ht"), []byte(`{
+    Name: "Bye"
+    Request: { URL: "`+ts.URL+`" }
+    Checks: [ {Check: "Body", Contains: "Bye"} ]
+}`), 0644)
+	suiteFile := filepath.Join(dir, "sub", "main.suite")
+	ioutil.WriteFile(suiteFile, []byte(`{
+    Name: "Main Suite"
+    Main: [ {File: "hello.ht"}, {File: "bye.ht"} ]
+}`), 0644)
+
+	sb := NewSuiteBrowser(dir)
+	var edited *ht.Test
+	sb.Edit = func(test *ht.Test) error { edited = test; return nil }
+	files, err := sb.suiteFiles()
+	if err != nil || len(files) != 1 || files[0] != suiteFile {
+		t.Fatalf("Got %v %v", files, err)
+	}
+
+	get := func(handler http.HandlerFunc, method, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(method, target, nil))
+		return rec
+	}
+	q := url.QueryEscape(suiteFile)
+
+	if body := get(sb.listHandler, "GET", "/suites").Body.String(); !strings.Contains(body, "not run") {
+		t.Errorf("Bad suite list:\n%s", body)
+	}
+	if rec := get(sb.runHandler, "POST", "/suites/run?suite="+q); rec.Code != 303 {
+		t.Fatalf("Got %d: %s", rec.Code, rec.Body)
+	}
+	sb.runs.Wait()
+	if rec := get(sb.runHandler, "POST", "/suites/run?suite=/etc/passwd"); rec.Code != 400 {
+		t.Errorf("Unknown suite run: %d", rec.Code)
+	}
+
+	body := get(sb.showHandler, "GET", "/suites/show?suite="+q).Body.String()
+	for _, want := range []string{"Main Suite", `class="Fail">Fail`, ">Hello</a>", `class="Pass">Pass`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Missing %q in suite page:\n%s", want, body)
+		}
+	}
+
+	body = get(sb.testHandler, "GET", "/suites/test?suite="+q+"&n=2").Body.String()
+	for _, want := range []string{"Bye", "Cannot find &#34;Bye&#34;", "Hello World"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Missing %q in test page:\n%s", want, body)
+		}
+	}
+
+	body = get(sb.inspectHandler, "GET", "/suites/inspect?suite="+q+"&n=2").Body.String()
+	for _, want := range []string{"<th>TEST_NAME</th>", "<strong>bye.ht</strong>", `<option value="2" selected>`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Missing %q in inspect page:\n%s", want, body)
+		}
+	}
+
+	if rec := get(sb.editHandler, "POST", "/suites/edit?suite="+q+"&n=1"); rec.Code != 303 {
+		t.Fatalf("Got %d: %s", rec.Code, rec.Body)
+	}
+	if edited == nil || edited.Name != "Hello" {
+		t.Errorf("Editing wrong test %v", edited)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gui
+
+import (
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+// Themes
+
+// ThemeRegistry contains the CSS of all known themes indexed by theme name.
+// The CSS of a theme is applied on top of the basic definitions in CSS.
+var ThemeRegistry = make(map[string]string)
+
+// DefaultTheme is the name of the theme used if none is selected.
+var DefaultTheme = "light"
+
+// ThemeCookie is the name of the cookie storing the selected theme.
+var ThemeCookie = "ht-gui-theme"
+
+// RegisterTheme registers css as the theme with the given name. Registering
+// an existing name replaces the theme.
+func RegisterTheme(name, css string) {
+	ThemeRegistry[name] = css
+}
+
+// Themes returns the sorted names of all registered themes.
+func Themes() []string {
+	names := make([]string, 0, len(ThemeRegistry))
+	for name := range ThemeRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ThemeCSS returns the full CSS for the named theme: The basic CSS followed
+// by the theme's definitions. Unknown themes yield the DefaultTheme.
+func ThemeCSS(name string) string {
+	css, ok := ThemeRegistry[name]
+	if !ok {
+		css = ThemeRegistry[DefaultTheme]
+	}
+	return CSS + css
+}
+
+// SelectTheme determines the theme for req: A known theme given in the
+// query parameter "theme" is selected and remembered in a cookie set on w,
+// otherwise the theme from the cookie or DefaultTheme is used.
+func SelectTheme(w http.ResponseWriter, req *http.Request) string {
+	if name := req.URL.Query().Get("theme"); name != "" {
+		if _, ok := ThemeRegistry[name]; ok {
+			http.SetCookie(w, &http.Cookie{
+				Name:    ThemeCookie,
+				Value:   name,
+				Path:    "/",
+				Expires: time.Now().Add(365 * 24 * time.Hour),
+			})
+			return name
+		}
+	}
+	if cookie, err := req.Cookie(ThemeCookie); err == nil {
+		if _, ok := ThemeRegistry[cookie.Value]; ok {
+			return cookie.Value
+		}
+	}
+	return DefaultTheme
+}
+
+func init() {
+	RegisterTheme("light", "")
+
+	RegisterTheme("dark", `
+body, input, textarea, select, button {
+  background-color: #1E1E1E;
+  color: #D4D4D4;
+}
+input, textarea, select { border: 1px solid #555; }
+a { color: #6CB6FF; }
+table.map>tbody>tr>th, table.map>tbody>tr>td { border-color: #555; }
+.tooltip .tooltiptext { background-color: #D4D4D4; color: #1E1E1E; }
+.actionbutton { color: black; }
+.Pass { color: #6A9955; }
+.Fail { color: #F48771; }
+p.msg-pass { color: #6A9955; }
+p.msg-fail { color: #F48771; }
+p.msg-info { color: #6CB6FF; }
+`)
+
+	RegisterTheme("high-contrast", `
+body, input, textarea, select, button {
+  background-color: black;
+  color: white;
+  font-size: 110%;
+}
+input, textarea, select, button { border: 2px solid white; }
+a { color: yellow; }
+table.map>tbody>tr>th, table.map>tbody>tr>td { border-color: white; }
+.tooltip .tooltiptext { background-color: white; color: black; }
+.actionbutton { background-color: yellow !important; color: black; font-weight: bold; }
+.Pass, p.msg-pass { color: lime; }
+.Fail, .Error, .Bogus, p.msg-fail, p.msg-error, p.msg-bogus { color: #FF6060; font-weight: bold; }
+p.msg-info { color: cyan; }
+`)
+
+	RegisterTheme("print", `
+body { margin: 1cm; color: black; background-color: white; }
+button, .actionbutton, span.draghandle, input[type="file"] { display: none !important; }
+input, textarea, select { border: none; }
+.valueform { margin-right: 0px !important; }
+.tooltip .tooltiptext { display: none; }
+a { color: black; text-decoration: none; }
+`)
+}
@@ -0,0 +1,58 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSelectTheme(t *testing.T) {
+	for _, name := range []string{"light", "dark", "high-contrast", "print"} {
+		if _, ok := ThemeRegistry[name]; !ok {
+			t.Errorf("Missing builtin theme %q", name)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/?theme=dark", nil)
+	if got := SelectTheme(rec, req); got != "dark" {
+		t.Errorf("Got %q, want dark", got)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != ThemeCookie || cookies[0].Value != "dark" {
+		t.Fatalf("Got cookies %v", cookies)
+	}
+
+	// Cookie is honoured, unknown themes are ignored.
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/?theme=nonexisting", nil)
+	req.AddCookie(&http.Cookie{Name: ThemeCookie, Value: "dark"})
+	if got := SelectTheme(rec, req); got != "dark" {
+		t.Errorf("Got %q, want dark", got)
+	}
+	if len(rec.Result().Cookies()) != 0 {
+		t.Errorf("Unexpected cookie set")
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	if got := SelectTheme(httptest.NewRecorder(), req); got != DefaultTheme {
+		t.Errorf("Got %q, want %q", got, DefaultTheme)
+	}
+}
+
+func TestThemeCSS(t *testing.T) {
+	defer delete(ThemeRegistry, "test")
+	RegisterTheme("test", "body { color: pink; }")
+	css := ThemeCSS("test")
+	if !strings.HasPrefix(css, CSS) || !strings.HasSuffix(css, "color: pink; }") {
+		t.Errorf("Bad theme CSS %q", css)
+	}
+	if got := ThemeCSS("nonexisting"); got != CSS+ThemeRegistry[DefaultTheme] {
+		t.Errorf("Unknown theme did not fall back to default")
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gui
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ----------------------------------------------------------------------------
+// File uploads
+
+// MaxUploadSize is the maximum size of data uploaded or loaded from the
+// server into a field.
+var MaxUploadSize int64 = 10 << 20
+
+// ParseUploadForm parses the form in req like req.ParseForm but handles
+// multipart forms too: The content of uploaded files is added to req.Form
+// under the name of the file input so that it is available to Update.
+func ParseUploadForm(req *http.Request) error {
+	ct, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if ct != "multipart/form-data" {
+		return req.ParseForm()
+	}
+
+	if err := req.ParseMultipartForm(MaxUploadSize); err != nil {
+		return err
+	}
+	for name, files := range req.MultipartForm.File {
+		for _, fh := range files {
+			if fh.Filename == "" {
+				continue // no file selected
+			}
+			file, err := fh.Open()
+			if err != nil {
+				return err
+			}
+			// Read one byte more than allowed to detect oversized uploads.
+			data, err := ioutil.ReadAll(io.LimitReader(file, MaxUploadSize+1))
+			file.Close()
+			if err != nil {
+				return err
+			}
+			req.Form.Add(name, string(data))
+		}
+	}
+	return nil
+}
+
+// uploadNotice reports the successful upload of data to Path.
+type uploadNotice struct {
+	Path string
+	Text string
+}
+
+func (n uploadNotice) Error() string { return n.Text }
+
+// uploaded returns the data uploaded or loaded from a server file for the
+// field with the given path. The returned error is either a ValueError or
+// an uploadNotice.
+func uploaded(form url.Values, path string) ([]byte, error) {
+	var data []byte
+	source := ""
+
+	upload, load := path+".__UPLOAD__", path+".__LOAD__"
+	if vals := form[upload]; len(vals) > 0 && vals[0] != "" {
+		data, source = []byte(vals[0]), "upload"
+	} else if filename := strings.TrimSpace(form.Get(load)); filename != "" {
+		file, err := os.Open(filename)
+		if err != nil {
+			return nil, ValueError{Path: path, Err: err}
+		}
+		data, err = ioutil.ReadAll(io.LimitReader(file, MaxUploadSize+1))
+		file.Close()
+		if err != nil {
+			return nil, ValueError{Path: path, Err: err}
+		}
+		source = filename
+	}
+	delete(form, upload)
+	delete(form, load)
+	if source == "" {
+		return nil, nil
+	}
+
+	if int64(len(data)) > MaxUploadSize {
+		return nil, ValueError{
+			Path: path,
+			Err:  fmt.Errorf("%s exceeds maximum size of %d bytes", source, MaxUploadSize),
+		}
+	}
+	text := fmt.Sprintf("Loaded %d bytes of %s from %s", len(data),
+		http.DetectContentType(data), source)
+	return data, uploadNotice{Path: path, Text: text}
+}
@@ -0,0 +1,93 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gui
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseUploadForm(t *testing.T) {
+	body := &bytes.Buffer{}
+	mpw := multipart.NewWriter(body)
+	mpw.WriteField("Test.Name", "Upload")
+	fw, _ := mpw.CreateFormFile("Test.Body.__UPLOAD__", "image.png")
+	fw.Write([]byte("\x89PNG\x0d\x0a\x1a\x0a"))
+	mpw.CreateFormFile("Test.Other.__UPLOAD__", "") // no file selected
+	mpw.Close()
+
+	req := httptest.NewRequest("POST", "/update", body)
+	req.Header.Set("Content-Type", mpw.FormDataContentType())
+	if err := ParseUploadForm(req); err != nil {
+		t.Fatal(err)
+	}
+	if got := req.Form.Get("Test.Name"); got != "Upload" {
+		t.Errorf("Got name %q", got)
+	}
+	if got := req.Form.Get("Test.Body.__UPLOAD__"); got != "\x89PNG\x0d\x0a\x1a\x0a" {
+		t.Errorf("Got upload %q", got)
+	}
+	if got := req.Form.Get("Test.Other.__UPLOAD__"); got != "" {
+		t.Errorf("Unexpected upload %q for Test.Other", got)
+	}
+}
+
+func TestWalkUpload(t *testing.T) {
+	type Upload struct {
+		Body string
+		Data []byte
+	}
+
+	dir, err := ioutil.TempDir("", "gui-upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "data.txt")
+	ioutil.WriteFile(file, []byte("Hello from server"), 0644)
+
+	v := NewValue(Upload{Body: "old", Data: []byte{1, 2}}, "U")
+	form := url.Values{
+		"U.Body":            {"old"},
+		"U.Body.__UPLOAD__": {"\x89PNG\x0d\x0a\x1a\x0a"},
+		"U.Data.__LOAD__":   {file},
+	}
+	if _, err := v.Update(form); len(err) != 2 {
+		t.Fatalf("Got %v", err)
+	}
+	got := v.Current.(Upload)
+	if got.Body != "\x89PNG\x0d\x0a\x1a\x0a" || string(got.Data) != "Hello from server" {
+		t.Errorf("Got %q %q", got.Body, got.Data)
+	}
+	if msgs := v.Messages["U.Body"]; len(msgs) != 1 || msgs[0].Type != "info" ||
+		!strings.Contains(msgs[0].Text, "8 bytes of image/png") {
+		t.Errorf("Got messages %v", msgs)
+	}
+
+	// Too large and missing files.
+	defer func(max int64) { MaxUploadSize = max }(MaxUploadSize)
+	MaxUploadSize = 4
+	form = url.Values{
+		"U.Body.__UPLOAD__": {"0123456789"},
+		"U.Data.__LOAD__":   {filepath.Join(dir, "missing")},
+	}
+	v.Update(form)
+	got = v.Current.(Upload)
+	if got.Body != "\x89PNG\x0d\x0a\x1a\x0a" || string(got.Data) != "Hello from server" {
+		t.Errorf("Got %q %q", got.Body, got.Data)
+	}
+	for _, path := range []string{"U.Body", "U.Data"} {
+		if msgs := v.Messages[path]; len(msgs) != 1 || msgs[0].Type != "error" {
+			t.Errorf("%s: got messages %v", path, msgs)
+		}
+	}
+}
@@ -13,8 +13,23 @@ import (
 	"strings"
 
 	"github.com/vdobler/ht/errorlist"
+	"github.com/vdobler/ht/logging"
 )
 
+// Logger receives the diagnostic messages of package gui, e.g. about values
+// which cannot be rendered. If Logger is nil the messages are printed to
+// stdout.
+var Logger logging.Logger
+
+// infof logs the formatted message to Logger or stdout.
+func infof(format string, a ...interface{}) {
+	if Logger != nil {
+		Logger.Log(logging.Info, fmt.Sprintf(format, a...))
+		return
+	}
+	fmt.Printf(format+"\n", a...)
+}
+
 // Message is a typed text message.
 type Message struct {
 	Type string
@@ -29,9 +44,12 @@ type Value struct {
 	// Current is the current value.
 	Current interface{}
 
-	// Last contains the last values
+	// Last contains the last values, the most recent one last.
 	Last []interface{}
 
+	// Next contains the values undone, the most recently undone last.
+	Next []interface{}
+
 	// Path is the path prefix applied to this value.
 	Path string
 
@@ -63,15 +81,60 @@ func (v *Value) Render() ([]byte, error) {
 	return v.buf.Bytes(), err
 }
 
+// RenderReadonly renders v's Current value like Render but without any
+// form inputs, e.g. to display results.
+func (v *Value) RenderReadonly() ([]byte, error) {
+	val := reflect.ValueOf(v.Current)
+	v.buf.Reset()
+	err := v.render(v.Path, 0, true, val)
+	return v.buf.Bytes(), err
+}
+
+// MaxHistory is the maximum number of values kept in Last.
+var MaxHistory = 100
+
 // PushCurrent stores the Current value in v to the list of Last
 // values. This allows to checkpoint the state of v for subsequent
-// undoes to one of the Pushed states.
+// undoes to one of the Pushed states. Pushing a new state clears the
+// values available for redo.
 func (v *Value) PushCurrent() {
+	v.Next = nil
+	if n := len(v.Last); n > 0 && reflect.DeepEqual(v.Last[n-1], v.Current) {
+		return // already checkpointed
+	}
+	v.Last = append(v.Last, v.Current)
+	if n := len(v.Last); n > MaxHistory {
+		v.Last = append([]interface{}(nil), v.Last[n-MaxHistory:]...)
+	}
+}
+
+// Undo goes back to the last pushed state. It reports whether there was
+// a state to go back to.
+func (v *Value) Undo() bool {
+	n := len(v.Last)
+	if n == 0 {
+		return false
+	}
+	v.Next = append(v.Next, v.Current)
+	v.Current, v.Last = v.Last[n-1], v.Last[:n-1]
+	return true
+}
+
+// Redo reverts the last Undo. It reports whether there was an undone
+// state to redo.
+func (v *Value) Redo() bool {
+	n := len(v.Next)
+	if n == 0 {
+		return false
+	}
 	v.Last = append(v.Last, v.Current)
+	v.Current, v.Next = v.Next[n-1], v.Next[:n-1]
+	return true
 }
 
 // Update v with data from the received HTML form. It returns the path of the
-// most prominent field (TODO: explain better).
+// most prominent field (TODO: explain better). The state before the update
+// is pushed to Last if the update changes the Current value.
 func (v *Value) Update(form url.Values) (string, errorlist.List) {
 	val := reflect.ValueOf(v.Current)
 	v.Messages = make(map[string][]Message) // clear errors // TODO: really automaticall here?
@@ -92,10 +155,21 @@ func (v *Value) Update(form url.Values) (string, errorlist.List) {
 			}}
 		case addNoticeError:
 			firstErrorPath = string(ve)
+		case uploadNotice:
+			if firstErrorPath == "" {
+				firstErrorPath = ve.Path
+			}
+			v.Messages[ve.Path] = []Message{{
+				Type: "info",
+				Text: ve.Text,
+			}}
 		}
 	}
 
-	v.Current = updated.Interface()
+	if current := updated.Interface(); !reflect.DeepEqual(current, v.Current) {
+		v.PushCurrent()
+		v.Current = current
+	}
 
 	return firstErrorPath, err
 }
@@ -135,9 +209,14 @@ func binData(path string, val reflect.Value) ([]byte, error) {
 		}
 		return binData(part[1], field)
 	case reflect.Map:
-		name := demangleKey(part[0])
-		key := reflect.ValueOf(name)
-		// TODO handel maps index by other stuff han strings
+		name, err := demangleKey(part[0])
+		if err != nil {
+			return nil, fmt.Errorf("gui: bad key %s: %s", part[0], err)
+		}
+		key, err := parseKey(name, val.Type().Key())
+		if err != nil {
+			return nil, fmt.Errorf("gui: bad key %s: %s", part[0], err)
+		}
 		v := val.MapIndex(key)
 		if !v.IsValid() {
 			return nil, fmt.Errorf("gui: no such key %s", part[0])
@@ -0,0 +1,71 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gui
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestUndoRedo(t *testing.T) {
+	v := NewValue([]int{1, 2, 3}, "L")
+
+	// An update without changes is not recorded.
+	v.Update(url.Values{"L.0": {"1"}, "L.1": {"2"}, "L.2": {"3"}})
+	if len(v.Last) != 0 {
+		t.Fatalf("Unchanged update recorded: %v", v.Last)
+	}
+
+	// Destructive remove can be undone and redone.
+	v.Update(url.Values{"L.0": {"1"}, "L.1": {"2"}, "L.2": {"3"}, "L.1.__OP__": {"Remove"}})
+	if got := v.Current.([]int); !reflect.DeepEqual(got, []int{1, 3}) {
+		t.Fatalf("Got %v", got)
+	}
+	v.Update(url.Values{"L.0": {"7"}, "L.1": {"3"}})
+	if !v.Undo() || !v.Undo() {
+		t.Fatal("Cannot undo")
+	}
+	if got := v.Current.([]int); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Fatalf("Got %v after undo", got)
+	}
+	if v.Undo() {
+		t.Errorf("Undo beyond initial state")
+	}
+	if !v.Redo() || !v.Redo() {
+		t.Fatal("Cannot redo")
+	}
+	if got := v.Current.([]int); !reflect.DeepEqual(got, []int{7, 3}) {
+		t.Fatalf("Got %v after redo", got)
+	}
+	if v.Redo() {
+		t.Errorf("Redo beyond last state")
+	}
+
+	// A new change clears the redo states.
+	v.Undo()
+	v.Update(url.Values{"L.0": {"5"}, "L.1": {"3"}})
+	if len(v.Next) != 0 || v.Redo() {
+		t.Errorf("Redo possible after new change: %v", v.Next)
+	}
+}
+
+func TestHistoryBounded(t *testing.T) {
+	defer func(max int) { MaxHistory = max }(MaxHistory)
+	MaxHistory = 3
+
+	v := NewValue(0, "N")
+	for i := 1; i <= 5; i++ {
+		v.Current = i
+		v.PushCurrent()
+	}
+	if !reflect.DeepEqual(v.Last, []interface{}{3, 4, 5}) {
+		t.Errorf("Got %v", v.Last)
+	}
+	v.PushCurrent() // duplicate
+	if len(v.Last) != 3 {
+		t.Errorf("Duplicate state recorded: %v", v.Last)
+	}
+}
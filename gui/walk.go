@@ -9,6 +9,7 @@ import (
 	"html/template"
 	"net/url"
 	"reflect"
+	"regexp"
 	"strconv"
 	"time"
 
@@ -73,7 +74,7 @@ func walk(form url.Values, path string, val reflect.Value) (reflect.Value, error
 		return walkPtr(form, path, val)
 	}
 
-	fmt.Println("gui: won't walk over", val.Kind().String(), "in", path)
+	infof("gui: won't walk over %s in %s", val.Kind().String(), path)
 
 	return reflect.Value{}, nil
 }
@@ -120,6 +121,14 @@ func walkString(form url.Values, path string, val reflect.Value) (reflect.Value,
 		}
 	}
 
+	data, err := uploaded(form, path)
+	if data != nil {
+		cpy.SetString(string(data))
+	}
+	if err != nil {
+		return cpy, errorlist.List{err}
+	}
+
 	return cpy, nil
 }
 
@@ -318,35 +327,105 @@ func walkStruct(form url.Values, path string, val reflect.Value) (reflect.Value,
 		if err != nil {
 			el = el.Append(err)
 		}
+		if err := validate(path+"."+name, val.Type(), name, fieldCpy); err != nil {
+			el = el.Append(err)
+			fieldCpy = field // keep the old value
+		}
 		cpy.Field(i).Set(fieldCpy)
 	}
 
 	return cpy, el
 }
 
+// validate checks the string value of the named field of a struct of type
+// typ against the Validate regexp from Typedata. The regexp must match the
+// whole value; empty values are not validated.
+func validate(path string, typ reflect.Type, name string, val reflect.Value) error {
+	re := Typedata[typ].Field[name].Validate
+	if re == nil || val.Kind() != reflect.String || val.String() == "" {
+		return nil
+	}
+	full, err := regexp.Compile(`^(?:` + re.String() + `)$`)
+	if err != nil {
+		return ValueError{Path: path, Err: err}
+	}
+	if !full.MatchString(val.String()) {
+		return ValueError{
+			Path: path,
+			Err:  fmt.Errorf("%q does not match %s", val.String(), re),
+		}
+	}
+	return nil
+}
+
 // ----------------------------------------------------------------------------
 // Slices
 
 func walkSlice(form url.Values, path string, val reflect.Value) (reflect.Value, errorlist.List) {
+	if val.Type().Elem().Kind() == reflect.Uint8 {
+		return walkBytes(form, path, val)
+	}
+
 	cpy := reflect.New(val.Type()).Elem()
 	cpy.Set(reflect.MakeSlice(val.Type(), 0, val.Len()))
 
 	var err errorlist.List
 
+	elems := make([]reflect.Value, 0, val.Len())
+	origs := make([]int, 0, val.Len()) // original index of elems
+	from, to := -1, -1                 // position of element to move
 	for i := 0; i < val.Len(); i++ {
 		elemPath := fmt.Sprintf("%s.%d", path, i)
 		op := elemPath + ".__OP__"
-		if form.Get(op) == "Remove" {
+		switch form.Get(op) {
+		case "Remove":
 			delete(form, elemPath)
 			delete(form, op)
 			continue
+		case "Up":
+			delete(form, op)
+			from, to = len(elems), len(elems)-1
+		case "Down":
+			delete(form, op)
+			from, to = len(elems), len(elems)+1
 		}
 
 		elemCpy, e := walk(form, elemPath, val.Index(i))
 		if e != nil {
 			err = err.Append(e)
 		}
-		cpy.Set(reflect.Append(cpy, elemCpy))
+		elems = append(elems, elemCpy)
+		origs = append(origs, i)
+	}
+
+	// Element dragged from one original index to an other.
+	move := path + ".__MOVE__"
+	if m := form.Get(move); m != "" {
+		delete(form, move)
+		var a, b int
+		if _, e := fmt.Sscanf(m, "%d:%d", &a, &b); e != nil {
+			err = err.Append(ValueError{Path: path, Err: e})
+		} else {
+			from, to = -1, -1
+			for p, o := range origs {
+				if o == a {
+					from = p
+				}
+				if o == b {
+					to = p
+				}
+			}
+		}
+	}
+
+	if from >= 0 && to >= 0 && from < len(elems) && to < len(elems) && from != to {
+		moved := elems[from]
+		elems = append(elems[:from], elems[from+1:]...)
+		elems = append(elems[:to], append([]reflect.Value{moved}, elems[to:]...)...)
+		err = err.Append(addNoticeError(fmt.Sprintf("%s.%d", path, to)))
+	}
+	for _, elem := range elems {
+		cpy.Set(reflect.Append(cpy, elem))
 	}
 
 	// New elements.
@@ -362,6 +441,23 @@ func walkSlice(form url.Values, path string, val reflect.Value) (reflect.Value,
 	return cpy, err
 }
 
+// walkBytes handles byte slices: They are rendered as binary data and can
+// be changed by uploads only.
+func walkBytes(form url.Values, path string, val reflect.Value) (reflect.Value, errorlist.List) {
+	cpy := reflect.New(val.Type()).Elem()
+	cpy.SetBytes(append([]byte(nil), val.Bytes()...))
+
+	data, err := uploaded(form, path)
+	if data != nil {
+		cpy.SetBytes(data)
+	}
+	if err != nil {
+		return cpy, errorlist.List{err}
+	}
+
+	return cpy, nil
+}
+
 // ----------------------------------------------------------------------------
 // Maps
 
@@ -371,9 +467,12 @@ func walkMap(form url.Values, path string, val reflect.Value) (reflect.Value, er
 
 	var err errorlist.List
 	for _, k := range val.MapKeys() {
-		name := k.String() // BUG: panics if map is indexed by anything else than strings
-		elemName := mangleKey(name)
-		elemPath := path + "." + elemName
+		name, e := keyString(k)
+		if e != nil {
+			cpy.Set(val)
+			return cpy, newValueErrorList(path, e)
+		}
+		elemPath := path + "." + mangleKey(name)
 
 		// Remove key?
 		op := elemPath + ".__OP__"
@@ -395,11 +494,15 @@ func walkMap(form url.Values, path string, val reflect.Value) (reflect.Value, er
 	if form.Get(op) == "Add" {
 		delete(form, op)
 		if key := form.Get(path + ".__NEW__"); key != "" {
-			delete(form, path+".__KEY__")
-			newKey := reflect.ValueOf(key) // Bug, works only for string keys
+			delete(form, path+".__NEW__")
+			newKey, e := parseKey(key, val.Type().Key())
+			if e != nil {
+				return cpy, err.Append(ValueError{Path: path, Err: e})
+			}
+			name, _ := keyString(newKey) // normalized, e.g. "007" --> "7"
 			newElem := reflect.Zero(val.Type().Elem())
 			cpy.SetMapIndex(newKey, newElem)
-			ap := fmt.Sprintf("%s.%s", path, mangleKey(key))
+			ap := fmt.Sprintf("%s.%s", path, mangleKey(name))
 			err = err.Append(addNoticeError(ap))
 		}
 	}
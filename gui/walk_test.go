@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"net/url"
 	"reflect"
+	"regexp"
 	"sort"
 	"strings"
 	"testing"
@@ -167,6 +168,55 @@ func TestWalkIntSliceRemove(t *testing.T) {
 	}
 }
 
+func TestWalkIntSliceMove(t *testing.T) {
+	s := []int{2, 3, 5, 7}
+
+	for i, tc := range []struct {
+		key, op string
+		want    string
+		focus   string
+	}{
+		{"s.1.__OP__", "Up", "[3 2 5 7]", "s.0"},
+		{"s.0.__OP__", "Up", "[2 3 5 7]", ""},
+		{"s.2.__OP__", "Down", "[2 3 7 5]", "s.3"},
+		{"s.3.__OP__", "Down", "[2 3 5 7]", ""},
+		{"s.__MOVE__", "0:2", "[3 5 2 7]", "s.2"},
+		{"s.__MOVE__", "3:0", "[7 2 3 5]", "s.0"},
+		{"s.__MOVE__", "1:9", "[2 3 5 7]", ""},
+	} {
+		form := make(url.Values)
+		form.Set(tc.key, tc.op)
+		cpy, err := walkSlice(form, "s", reflect.ValueOf(s))
+		focus := ""
+		for _, e := range err {
+			if path, ok := e.(addNoticeError); ok {
+				focus = string(path)
+			} else {
+				t.Errorf("%d: unexpected error %s", i, e)
+			}
+		}
+		if got := fmt.Sprintf("%v", cpy.Interface()); got != tc.want || focus != tc.focus {
+			t.Errorf("%d: %s=%s: got %s %q, want %s %q",
+				i, tc.key, tc.op, got, focus, tc.want, tc.focus)
+		}
+	}
+
+	// Dragging uses original indices, even if elements are removed.
+	form := make(url.Values)
+	form.Set("s.0.__OP__", "Remove")
+	form.Set("s.__MOVE__", "3:1")
+	cpy, _ := walkSlice(form, "s", reflect.ValueOf(s))
+	if got := fmt.Sprintf("%v", cpy.Interface()); got != "[7 3 5]" {
+		t.Errorf("Got %s", got)
+	}
+
+	form = make(url.Values)
+	form.Set("s.__MOVE__", "up")
+	if _, err := walkSlice(form, "s", reflect.ValueOf(s)); len(err) != 1 {
+		t.Errorf("Got %v", err)
+	}
+}
+
 func TestWalkStringSlice(t *testing.T) {
 	form := make(url.Values)
 	s := []string{"2", "3", "5", "7"}
@@ -305,6 +355,40 @@ func TestWalkMap(t *testing.T) {
 	}
 }
 
+func TestWalkIntMap(t *testing.T) {
+	form := make(url.Values)
+	s := map[int]string{
+		7:  "seven",
+		-1: "minus one",
+	}
+
+	form.Set("s.7", "SEVEN")
+	form.Set("s.-1.__OP__", "Remove")
+	form.Set("s.__OP__", "Add")
+	form.Set("s.__NEW__", "042")
+	cpy, err := walkMap(form, "s", reflect.ValueOf(s))
+	if len(err) != 1 {
+		t.Fatal(err)
+	}
+	if path, ok := err[0].(addNoticeError); !ok || path != "s.42" {
+		t.Fatal(err[0])
+	}
+	if got := fmt.Sprint(cpy.Interface()); got != "map[7:SEVEN 42:]" {
+		t.Fatal(got)
+	}
+
+	// Bad new key.
+	form.Set("s.__OP__", "Add")
+	form.Set("s.__NEW__", "seven")
+	_, err = walkMap(form, "s", reflect.ValueOf(s))
+	if len(err) != 1 {
+		t.Fatal(err)
+	}
+	if ve, ok := err[0].(ValueError); !ok || ve.Path != "s" {
+		t.Fatal(err[0])
+	}
+}
+
 func TestWalkNonNilPtr(t *testing.T) {
 	form := make(url.Values)
 	x := "Hello"
@@ -430,6 +514,7 @@ func TestBinData(t *testing.T) {
 		}
 		Y []string
 		Z map[string]string
+		I map[int]string
 	}
 
 	mt := MyType{
@@ -437,7 +522,8 @@ func TestBinData(t *testing.T) {
 		B: []byte("bar"),
 		X: struct{ T string }{T: "wuz"},
 		Y: []string{"abc", "xyz"},
-		Z: map[string]string{"key": "val"},
+		Z: map[string]string{"key": "val", "a.b": "dotted"},
+		I: map[int]string{-3: "minus three"},
 	}
 
 	v := NewValue(mt, "Object")
@@ -451,6 +537,8 @@ func TestBinData(t *testing.T) {
 		{"Object.Y.0", "abc"},
 		{"Object.Y.1", "xyz"},
 		{"Object.Z.key", "val"},
+		{"Object.Z.a%2Eb", "dotted"},
+		{"Object.I.-3", "minus three"},
 	} {
 		data, err := v.BinaryData(tc.path)
 		if err != nil {
@@ -487,10 +575,56 @@ func TestWalkErrors(t *testing.T) {
 		t.Fatal("nil error")
 	}
 
-	if n := len(err); n != 2 {
+	// Tries, Wait and the Hash not matching Validate.
+	if n := len(err); n != 3 {
 		t.Fatalf("got %d errors:\n%s", n, strings.Join(err.AsStrings(), "\n"))
 	}
+	if hash := cpy.Interface().(Execution).Hash; hash != "deadbeef" {
+		t.Errorf("Invalid hash %q accepted", hash)
+	}
 
 	fmt.Println(err)
 	fmt.Println(cpy)
 }
+
+func TestWalkValidate(t *testing.T) {
+	type Host struct {
+		Name string
+	}
+	defer delete(Typedata, reflect.TypeOf(Host{}))
+	RegisterType(Host{}, Typeinfo{
+		Field: map[string]Fieldinfo{
+			"Name": {Validate: regexp.MustCompile(`a|ab`)},
+		},
+	})
+
+	for i, tc := range []struct {
+		in, want string
+		ok       bool
+	}{
+		{"a", "a", true},
+		{"ab", "ab", true}, // whole value must match
+		{"", "", true},     // empty values are not validated
+		{"abc", "old", false},
+		{"xa", "old", false},
+	} {
+		form := url.Values{"H.Name": {tc.in}}
+		cpy, err := walk(form, "H", reflect.ValueOf(Host{Name: "old"}))
+		if got := cpy.Interface().(Host).Name; got != tc.want {
+			t.Errorf("%d: got %q, want %q", i, got, tc.want)
+		}
+		if tc.ok != (err == nil) {
+			t.Errorf("%d: unexpected error state %v", i, err)
+		} else if !tc.ok {
+			if ve, ok := err[0].(ValueError); !ok || ve.Path != "H.Name" {
+				t.Errorf("%d: bad error %#v", i, err[0])
+			}
+		}
+	}
+
+	v := NewValue(Host{Name: "a"}, "H")
+	out, _ := v.Render()
+	if !strings.Contains(string(out), `pattern="a(?:(?:)|b)" title="Must match a|ab"`) {
+		t.Errorf("Missing pattern attribute in\n%s", out)
+	}
+}
@@ -81,7 +81,7 @@ func (e ETag) Execute(t *Test) error {
 		&StatusCode{Expect: 304},
 	}
 
-	second.Run()
+	second.RunContext(t.context())
 	if second.Result.Status == Fail {
 		return errETagIgnored
 	}
@@ -0,0 +1,226 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// charset.go contains the charset handling of response bodies.
+
+package ht
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+)
+
+func init() {
+	RegisterCheck(Charset{})
+}
+
+// transcodeBody transcodes the body of a HTTP response to UTF-8 if the
+// response declares a different charset via a BOM, the Content-Type header
+// or a meta tag of a HTML document. Bodies of tests with a Transform are
+// left untouched as the declared charset applies to the envelope; such
+// tests can use the "charset" Transformation.
+func (t *Test) transcodeBody() {
+	if t.Response.Response == nil || t.Response.BodyErr != nil || len(t.Transform) > 0 {
+		return
+	}
+	body := []byte(t.Response.BodyStr)
+	enc, name, certain := charset.DetermineEncoding(body, t.Response.contentType())
+	if !certain {
+		// Not from a BOM or the Content-Type header: Accept only
+		// a charset declared in a meta tag, not a guess.
+		declared := declaredCharset(t.Response.contentType(), body)
+		if _, dname := charset.Lookup(declared); declared == "" || dname != name {
+			return
+		}
+	}
+	if name == "utf-8" {
+		return
+	}
+	decoded, err := t.decode(enc, name, body)
+	if err != nil {
+		t.debugf("Cannot transcode body from %s: %s", name, err)
+		return
+	}
+	t.Response.BodyStr = string(decoded)
+	t.debugf("Transcoded body from %s to UTF-8", name)
+}
+
+// decodeCharset transcodes data to UTF-8. The charset is determined from
+// a BOM, the Content-Type header of the response or a meta tag in the first
+// 1024 bytes of a HTML document (in this order) and falls back to UTF-8 or
+// windows-1252 if none is declared.
+func (t *Test) decodeCharset(data []byte) ([]byte, error) {
+	enc, name, _ := charset.DetermineEncoding(data, t.Response.contentType())
+	if name == "utf-8" {
+		return data, nil
+	}
+	return t.decode(enc, name, data)
+}
+
+// decode transcodes data in the charset name to UTF-8 and records the
+// charset and the original data in the Response of t.
+func (t *Test) decode(enc encoding.Encoding, name string, data []byte) ([]byte, error) {
+	decoded, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return nil, err
+	}
+	t.Response.Charset = name
+	t.Response.rawBody = data
+	return decoded, nil
+}
+
+// contentType returns the Content-Type header of the response.
+func (resp *Response) contentType() string {
+	if resp.Response == nil {
+		return ""
+	}
+	return resp.Response.Header.Get("Content-Type")
+}
+
+// rawBytes returns the response body before it was transcoded to UTF-8.
+func (resp *Response) rawBytes() []byte {
+	if resp.rawBody != nil {
+		return resp.rawBody
+	}
+	return []byte(resp.BodyStr)
+}
+
+// declaredCharset returns the charset declared in the Content-Type header
+// contentType or, for HTML documents, in a meta tag in the first 1024 bytes
+// of body.
+func declaredCharset(contentType string, body []byte) string {
+	mediatype, params, err := mime.ParseMediaType(contentType)
+	if err == nil {
+		if cs := params["charset"]; cs != "" {
+			return cs
+		}
+	}
+	if mediatype != "" && mediatype != "text/html" && mediatype != "application/xhtml+xml" {
+		return ""
+	}
+	if len(body) > 1024 {
+		body = body[:1024]
+	}
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			if tok.Data != "meta" {
+				continue
+			}
+			httpEquiv, content := "", ""
+			for _, a := range tok.Attr {
+				switch strings.ToLower(a.Key) {
+				case "charset":
+					return a.Val
+				case "http-equiv":
+					httpEquiv = strings.ToLower(a.Val)
+				case "content":
+					content = a.Val
+				}
+			}
+			if httpEquiv == "content-type" {
+				if _, params, err := mime.ParseMediaType(content); err == nil && params["charset"] != "" {
+					return params["charset"]
+				}
+			}
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Charset
+
+// Charset checks that the response declares its charset in the Content-Type
+// header or in a HTML meta tag and that the body actually is encoded in the
+// declared charset. Declaring e.g. ISO-8859-1 but sending UTF-8 fails, as
+// does declaring UTF-8 but sending invalid UTF-8.
+//
+// The check works on the original bytes of the response, even if the body
+// was transcoded to UTF-8.
+type Charset struct {
+	// Expect is the expected charset like "UTF-8" or "ISO-8859-1".
+	// Aliases of charsets are recognised. The empty string accepts
+	// any declared charset.
+	Expect string `json:",omitempty"`
+}
+
+// Prepare implements Check's Prepare method.
+func (c Charset) Prepare(*Test) error {
+	if c.Expect == "" {
+		return nil
+	}
+	if enc, _ := charset.Lookup(c.Expect); enc == nil {
+		return fmt.Errorf("unknown charset %q", c.Expect)
+	}
+	return nil
+}
+
+var _ Preparable = Charset{}
+
+// Execute implements Check's Execute method.
+func (c Charset) Execute(t *Test) error {
+	if t.Response.BodyErr != nil {
+		return ErrBadBody
+	}
+	body := t.Response.rawBytes()
+	declared := declaredCharset(t.Response.contentType(), body)
+	if declared == "" {
+		return errors.New("no charset declared")
+	}
+	enc, name := charset.Lookup(declared)
+	if enc == nil {
+		return fmt.Errorf("unknown charset %q declared", declared)
+	}
+	if c.Expect != "" {
+		if _, expect := charset.Lookup(c.Expect); expect != name {
+			return fmt.Errorf("got charset %s, want %s", name, expect)
+		}
+	}
+
+	if name == "utf-8" {
+		if !utf8.Valid(body) {
+			return fmt.Errorf("body is not valid %s", name)
+		}
+		return nil
+	}
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return fmt.Errorf("body is not valid %s: %s", name, err)
+	}
+	if bytes.ContainsRune(decoded, utf8.RuneError) {
+		return fmt.Errorf("body is not valid %s", name)
+	}
+	if isASCII(body) || !utf8.Valid(body) {
+		return nil
+	}
+	if strings.HasPrefix(name, "windows-") || strings.HasPrefix(name, "iso-8859-") ||
+		strings.HasPrefix(name, "koi8") || name == "macintosh" {
+		// Bytes which are valid UTF-8 are very unlikely in
+		// a text encoded with a single byte charset.
+		return fmt.Errorf("declared charset %s but body is UTF-8", name)
+	}
+	return nil
+}
+
+// isASCII reports whether data consists of ASCII characters only.
+func isASCII(data []byte) bool {
+	for _, b := range data {
+		if b >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,125 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const (
+	latin1Body = "Gr\xfc\xdfe aus M\xfcnchen"
+	utf8Body   = "Grüße aus München"
+)
+
+var charsetTests = []TC{
+	{Response{Response: &http.Response{Header: http.Header{
+		"Content-Type": {"text/plain; charset=utf-8"}}}, BodyStr: utf8Body},
+		Charset{}, nil},
+	{Response{Response: &http.Response{Header: http.Header{
+		"Content-Type": {"text/plain; charset=UTF-8"}}}, BodyStr: utf8Body},
+		Charset{Expect: "utf8"}, nil},
+	{Response{Response: &http.Response{Header: http.Header{
+		"Content-Type": {"text/plain; charset=iso-8859-1"}}}, BodyStr: latin1Body},
+		Charset{Expect: "latin1"}, nil},
+	{Response{Response: &http.Response{Header: http.Header{
+		"Content-Type": {"text/plain; charset=iso-8859-1"}}}, BodyStr: latin1Body},
+		Charset{Expect: "utf-8"}, errCheck},
+	{Response{Response: &http.Response{Header: http.Header{
+		"Content-Type": {"text/plain; charset=utf-8"}}}, BodyStr: latin1Body},
+		Charset{}, errCheck},
+	{Response{Response: &http.Response{Header: http.Header{
+		"Content-Type": {"text/plain; charset=iso-8859-1"}}}, BodyStr: utf8Body},
+		Charset{}, errCheck},
+	{Response{Response: &http.Response{Header: http.Header{
+		"Content-Type": {"text/plain; charset=shift_jis"}}}, BodyStr: "\x82\xa0\x82"},
+		Charset{}, errCheck},
+	{Response{Response: &http.Response{Header: http.Header{
+		"Content-Type": {"text/plain"}}}, BodyStr: utf8Body},
+		Charset{}, errCheck},
+	{Response{Response: &http.Response{Header: http.Header{
+		"Content-Type": {"text/html"}}},
+		BodyStr: `<html><head><meta charset="ISO-8859-1"></head><body>` + latin1Body},
+		Charset{Expect: "iso-8859-1"}, nil},
+	{Response{Response: &http.Response{Header: http.Header{
+		"Content-Type": {"text/html"}}},
+		BodyStr: `<html><head><meta http-equiv="Content-Type" content="text/html; charset=utf-8">` + latin1Body},
+		Charset{}, errCheck},
+}
+
+func TestCharset(t *testing.T) {
+	for i, tc := range charsetTests {
+		runTest(t, i, tc)
+	}
+}
+
+func TestCharsetTransformation(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=ISO-8859-1")
+		w.Write([]byte(latin1Body))
+	}))
+	defer ts.Close()
+
+	test := Test{
+		Request:   Request{URL: ts.URL + "/"},
+		Transform: []Transformation{{Kind: "charset"}},
+		Checks: CheckList{
+			&Body{Contains: "Grüße"},
+			UTF8Encoded{},
+			Charset{Expect: "ISO-8859-1"},
+		},
+	}
+	test.Run()
+	if test.Result.Status != Pass {
+		t.Errorf("Got status %s: %v", test.Result.Status, test.Result.Error)
+	}
+	if test.Response.Charset != "windows-1252" {
+		t.Errorf("Got charset %q", test.Response.Charset)
+	}
+}
+
+func TestCharsetAutomatic(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/header":
+			w.Header().Set("Content-Type", "text/plain; charset=ISO-8859-1")
+			w.Write([]byte(latin1Body))
+		case "/meta":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><meta charset="ISO-8859-1"></head><body>` + latin1Body))
+		case "/utf8":
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte(utf8Body))
+		case "/undeclared":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(latin1Body))
+		}
+	}))
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		path    string
+		charset string
+		body    string
+	}{
+		{"/header", "windows-1252", utf8Body},
+		{"/meta", "windows-1252", utf8Body},
+		{"/utf8", "", utf8Body},
+		{"/undeclared", "", latin1Body},
+	} {
+		test := Test{
+			Request: Request{URL: ts.URL + tc.path},
+			Checks:  CheckList{&Body{Contains: tc.body}},
+		}
+		test.Run()
+		if test.Result.Status != Pass {
+			t.Errorf("%s: Got status %s: %v", tc.path, test.Result.Status, test.Result.Error)
+		}
+		if test.Response.Charset != tc.charset {
+			t.Errorf("%s: Got charset %q, want %q", tc.path, test.Response.Charset, tc.charset)
+		}
+	}
+}
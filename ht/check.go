@@ -135,11 +135,16 @@ func (cl CheckList) MarshalJSON() ([]byte, error) {
 
 // Populate implements populate.Populator.Populate.
 func (cl *CheckList) Populate(src interface{}) error {
+	return cl.PopulateWith(src, populate.Options{})
+}
+
+// PopulateWith implements populate.OptionsPopulator.PopulateWith.
+func (cl *CheckList) PopulateWith(src interface{}, opts populate.Options) error {
 	types := []struct {
 		Check string
 	}{}
 
-	err := populate.Lax(&types, src)
+	err := populate.Options{Lax: true, FoldCase: opts.FoldCase}.Populate(&types, src)
 	if err != nil {
 		return fmt.Errorf("ht: cannot determine type of check: %s", err)
 	}
@@ -155,7 +160,11 @@ func (cl *CheckList) Populate(src interface{}) error {
 		if !ok {
 			return fmt.Errorf("ht: unable to construct check, cannot deserialise %T", src)
 		}
-		delete(r, "Check")
+		for key := range r {
+			if key == "Check" || (opts.FoldCase && strings.EqualFold(key, "Check")) {
+				delete(r, key)
+			}
+		}
 		raw[i] = r
 	}
 
@@ -164,16 +173,15 @@ func (cl *CheckList) Populate(src interface{}) error {
 		checkName := t.Check
 		typ, ok := CheckRegistry[checkName]
 		if !ok {
-			return noSuchCheckError(checkName)
+			return populate.Within(noSuchCheckError(checkName), i, "Check")
 		}
 		if typ.Kind() == reflect.Ptr {
 			typ = typ.Elem()
 		}
 		rcheck := reflect.New(typ)
-		err = populate.Strict(rcheck.Interface(), raw[i])
+		err = opts.Populate(rcheck.Interface(), raw[i])
 		if err != nil {
-			return fmt.Errorf("ht: problems constructing check %d %s: %s",
-				i+1, checkName, err)
+			return populate.Within(err, i)
 		}
 		list[i] = rcheck.Interface().(Check)
 	}
@@ -181,6 +189,52 @@ func (cl *CheckList) Populate(src interface{}) error {
 	return nil
 }
 
+// PopulateSchema implements populate.Schemer: A list of objects whose
+// field "Check" selects the type of check.
+func (cl *CheckList) PopulateSchema(g *populate.SchemaGenerator) map[string]interface{} {
+	names := make([]string, 0, len(CheckRegistry))
+	for name := range CheckRegistry {
+		names = append(names, name)
+	}
+	return map[string]interface{}{
+		"type":  "array",
+		"items": registrySchema(g, "Check", names, CheckRegistry),
+	}
+}
+
+// registrySchema produces the schema of an object whose type field selects
+// one of the types registered in registry under names.
+func registrySchema(g *populate.SchemaGenerator, field string, names []string, registry map[string]reflect.Type) map[string]interface{} {
+	sort.Strings(names)
+	enum := make([]interface{}, len(names))
+	conditions := make([]interface{}, len(names))
+	for i, name := range names {
+		enum[i] = name
+		typ := registry[name]
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		then := g.StructSchema(reflect.New(typ).Elem().Interface())
+		then["properties"].(map[string]interface{})[field] = map[string]interface{}{}
+		conditions[i] = map[string]interface{}{
+			"if": map[string]interface{}{
+				"properties": map[string]interface{}{
+					field: map[string]interface{}{"const": name},
+				},
+			},
+			"then": then,
+		}
+	}
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{field},
+		"properties": map[string]interface{}{
+			field: map[string]interface{}{"enum": enum},
+		},
+		"allOf": conditions,
+	}
+}
+
 // ----------------------------------------------------------------------------
 // Handling misspelled checks
 
@@ -0,0 +1,134 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// clientpool.go provides a configurable HTTP transport shared by tests.
+
+package ht
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ClientPool configures the HTTP connections shared by several tests, e.g.
+// all tests of a suite, and collects statistics about the reuse of the
+// connections. It allows to model a realistic client and to detect
+// connection leaks: A server which does not allow connection reuse shows
+// up with many new connections.
+//
+// The zero value uses the settings of Transport. A ClientPool must not be
+// copied after first use.
+type ClientPool struct {
+	// MaxIdleConns limits the number of idle (keep-alive) connections
+	// in total and MaxIdleConnsPerHost per host. Zero means the limits
+	// of Transport respectively http.DefaultMaxIdleConnsPerHost.
+	MaxIdleConns        int `json:",omitempty"`
+	MaxIdleConnsPerHost int `json:",omitempty"`
+
+	// MaxConnsPerHost limits the number of connections (dialing, active
+	// and idle) per host. Zero means no limit.
+	MaxConnsPerHost int `json:",omitempty"`
+
+	// IdleConnTimeout is how long an idle connection is kept open.
+	// Zero means the timeout of Transport.
+	IdleConnTimeout time.Duration `json:",omitempty"`
+
+	// DisableKeepAlives uses a new connection for each request.
+	DisableKeepAlives bool `json:",omitempty"`
+
+	// HTTP2 enables HTTP/2 for https connections.
+	HTTP2 bool `json:",omitempty"`
+
+	once      sync.Once
+	transport *http.Transport
+	stats     ConnStats
+}
+
+// ConnStats are statistics about the connections used by requests.
+type ConnStats struct {
+	Requests int64 // Requests is the number of requests made.
+	New      int64 // New is the number of newly established connections.
+	Reused   int64 // Reused is the number of reused connections.
+}
+
+// Clone returns a new, unused ClientPool with the configuration of p.
+func (p *ClientPool) Clone() *ClientPool {
+	return &ClientPool{
+		MaxIdleConns:        p.MaxIdleConns,
+		MaxIdleConnsPerHost: p.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     p.MaxConnsPerHost,
+		IdleConnTimeout:     p.IdleConnTimeout,
+		DisableKeepAlives:   p.DisableKeepAlives,
+		HTTP2:               p.HTTP2,
+	}
+}
+
+// Transport returns the transport of p.
+func (p *ClientPool) Transport() *http.Transport {
+	p.once.Do(func() {
+		t := Transport.Clone()
+		if p.MaxIdleConns > 0 {
+			t.MaxIdleConns = p.MaxIdleConns
+		}
+		if p.MaxIdleConnsPerHost > 0 {
+			t.MaxIdleConnsPerHost = p.MaxIdleConnsPerHost
+		}
+		t.MaxConnsPerHost = p.MaxConnsPerHost
+		if p.IdleConnTimeout > 0 {
+			t.IdleConnTimeout = p.IdleConnTimeout
+		}
+		t.DisableKeepAlives = p.DisableKeepAlives
+		t.ForceAttemptHTTP2 = p.HTTP2
+		p.transport = t
+	})
+	return p.transport
+}
+
+// Stats returns the connection statistics of p.
+func (p *ClientPool) Stats() ConnStats {
+	return ConnStats{
+		Requests: atomic.LoadInt64(&p.stats.Requests),
+		New:      atomic.LoadInt64(&p.stats.New),
+		Reused:   atomic.LoadInt64(&p.stats.Reused),
+	}
+}
+
+// Close closes the idle connections of p.
+func (p *ClientPool) Close() {
+	if p.transport != nil {
+		p.transport.CloseIdleConnections()
+	}
+}
+
+// connTrace returns a trace which counts the connections used by the
+// requests of t in t.Response and in the statistics of t's ClientPool.
+func (t *Test) connTrace() *httptrace.ClientTrace {
+	var pool *ConnStats
+	if t.ClientPool != nil {
+		pool = &t.ClientPool.stats
+	}
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			// Redirects are followed sequentially so no locking needed.
+			t.Response.Connections.Requests++
+			if info.Reused {
+				t.Response.Connections.Reused++
+			} else {
+				t.Response.Connections.New++
+			}
+			if pool == nil {
+				return
+			}
+			atomic.AddInt64(&pool.Requests, 1)
+			if info.Reused {
+				atomic.AddInt64(&pool.Reused, 1)
+			} else {
+				atomic.AddInt64(&pool.New, 1)
+			}
+		},
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientPool(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		name       string
+		pool       *ClientPool
+		new, reuse int64
+	}{
+		{"default", &ClientPool{}, 1, 2},
+		{"idle", &ClientPool{MaxIdleConnsPerHost: 4}, 1, 2},
+		{"no-keep-alive", &ClientPool{DisableKeepAlives: true}, 3, 0},
+	} {
+		for i := 0; i < 3; i++ {
+			test := Test{
+				Request:    Request{URL: ts.URL + "/"},
+				Checks:     CheckList{StatusCode{Expect: 200}},
+				ClientPool: tc.pool,
+			}
+			test.Run()
+			if test.Result.Status != Pass {
+				t.Fatalf("Unexpected error: %s", test.Result.Error)
+			}
+			if c := test.Response.Connections; c.Requests != 1 || c.New+c.Reused != 1 {
+				t.Errorf("%s: got test connections %+v", tc.name, c)
+			}
+		}
+		got := tc.pool.Stats()
+		if got.Requests != 3 || got.New != tc.new || got.Reused != tc.reuse {
+			t.Errorf("%s: got %+v", tc.name, got)
+		}
+		tc.pool.Close()
+	}
+}
+
+func TestClientPoolClone(t *testing.T) {
+	pool := &ClientPool{MaxIdleConns: 7, MaxConnsPerHost: 3, HTTP2: true}
+	transport := pool.Transport()
+	if transport == Transport || transport.MaxIdleConns != 7 ||
+		transport.MaxConnsPerHost != 3 || !transport.ForceAttemptHTTP2 {
+		t.Errorf("Bad transport %+v", transport)
+	}
+	clone := pool.Clone()
+	if clone.transport != nil || clone.MaxIdleConns != 7 || clone.MaxConnsPerHost != 3 {
+		t.Errorf("Bad clone %+v", clone)
+	}
+	if clone.Transport() == transport {
+		t.Errorf("Clone shares transport")
+	}
+}
@@ -0,0 +1,295 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// compare.go contains the comparison of responses with a reference system.
+
+package ht
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vdobler/ht/errorlist"
+)
+
+func init() {
+	RegisterCheck(CompareWith{})
+}
+
+// ----------------------------------------------------------------------------
+// CompareWith
+
+// CompareWith sends the request of the test a second time to a reference
+// system (e.g. the previous release or the green deployment) and compares
+// the two responses: The status codes, the selected headers and the bodies
+// must match. JSON bodies are compared structurally (ignoring formatting and
+// the order of object members), other bodies textually.
+//
+// The reference request is the fully substituted request of the test with
+// scheme, host and port (and path prefix) of the URL replaced by the ones
+// of Reference. The reference request does not send the cookies of the
+// test's cookie jar.
+type CompareWith struct {
+	// Reference is the base URL of the reference system, e.g.
+	// "https://blue.example.org" or "http://localhost:8080/v1".
+	Reference string
+
+	// Header is the list of HTTP headers whose values must match,
+	// e.g. ["Content-Type", "Cache-Control"].
+	Header []string `json:",omitempty"`
+
+	// IgnoreStatus and IgnoreBody turn off comparing the status codes
+	// and the bodies.
+	IgnoreStatus bool `json:",omitempty"`
+	IgnoreBody   bool `json:",omitempty"`
+
+	// Ignore is the list of JSON elements excluded from the comparison
+	// like timestamps or IDs. The elements are given in the notation of
+	// the JSON check, a "*" matches all array elements or object members,
+	// e.g. "items.*.id".
+	Ignore []string `json:",omitempty"`
+
+	// Sep is the separator in Ignore. A zero value is equivalent to ".".
+	Sep string `json:",omitempty"`
+}
+
+// Prepare implements Check's Prepare method.
+func (c CompareWith) Prepare(*Test) error {
+	u, err := url.Parse(c.Reference)
+	if err != nil {
+		return fmt.Errorf("bad Reference: %s", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("bad Reference %q: need a http or https URL", c.Reference)
+	}
+	return nil
+}
+
+var _ Preparable = CompareWith{}
+
+// Execute implements Check's Execute method.
+func (c CompareWith) Execute(t *Test) error {
+	if t.Response.BodyErr != nil {
+		return ErrBadBody
+	}
+	ref, err := c.referenceTest(t)
+	if err != nil {
+		return CantCheck{err}
+	}
+	ref.RunContext(t.context())
+	if ref.Result.Status != Pass {
+		return CantCheck{fmt.Errorf("reference request to %s failed: %s",
+			ref.Request.URL, ref.Result.Error)}
+	}
+	t.debugf("Comparing with reference %s", ref.Request.URL)
+
+	el := errorlist.List{}
+	resp, refResp := t.Response.Response, ref.Response.Response
+	if !c.IgnoreStatus && resp.StatusCode != refResp.StatusCode {
+		el = append(el, fmt.Errorf("status code: reference %d, got %d",
+			refResp.StatusCode, resp.StatusCode))
+	}
+	for _, h := range c.Header {
+		rv, v := refResp.Header[http.CanonicalHeaderKey(h)], resp.Header[http.CanonicalHeaderKey(h)]
+		if strings.Join(rv, ", ") != strings.Join(v, ", ") {
+			el = append(el, fmt.Errorf("header %s: reference %q, got %q", h, rv, v))
+		}
+	}
+	if !c.IgnoreBody {
+		el = append(el, c.compareBodies(ref.Response.BodyStr, t.Response.BodyStr,
+			strings.Contains(ref.Response.contentType(), "json") &&
+				strings.Contains(t.Response.contentType(), "json"))...)
+	}
+	if len(el) > 0 {
+		return el
+	}
+	return nil
+}
+
+// referenceTest returns the test which sends the request of t to the
+// reference system.
+func (c CompareWith) referenceTest(t *Test) (*Test, error) {
+	orig, err := url.Parse(t.Request.URL)
+	if err != nil {
+		return nil, err
+	}
+	if orig.Scheme != "http" && orig.Scheme != "https" {
+		return nil, fmt.Errorf("cannot compare %s requests", orig.Scheme)
+	}
+	refURL, err := url.Parse(c.Reference)
+	if err != nil {
+		return nil, err
+	}
+	refURL.Path = strings.TrimSuffix(refURL.Path, "/") + orig.Path
+	refURL.RawPath = ""
+	refURL.RawQuery = orig.RawQuery
+
+	ref := &Test{
+		Name:         "Reference for " + t.Name,
+		Request:      t.Request,
+		Transform:    t.Transform,
+		AllowedHosts: t.AllowedHosts,
+		Execution: Execution{
+			Verbosity: t.Execution.Verbosity - 1,
+		},
+	}
+	ref.Request.URL = refURL.String()
+	ref.Request.Request = nil
+	ref.Request.SentBody, ref.Request.SentParams = "", nil
+	ref.Request.Header = make(http.Header)
+	for h, v := range t.Request.Header {
+		ref.Request.Header[h] = append([]string(nil), v...)
+	}
+	ref.Request.Params = make(url.Values)
+	for p, v := range t.Request.Params {
+		ref.Request.Params[p] = append([]string(nil), v...)
+	}
+	return ref, nil
+}
+
+// compareBodies compares the reference body with the actual body.
+func (c CompareWith) compareBodies(refBody, body string, isJSON bool) errorlist.List {
+	if !isJSON {
+		if diff := lineDiff(refBody, body); diff != "" {
+			return errorlist.List{fmt.Errorf("body differs (-reference +actual):\n%s", diff)}
+		}
+		return nil
+	}
+
+	var refV, v interface{}
+	for _, x := range []struct {
+		name string
+		body string
+		v    *interface{}
+	}{{"reference", refBody, &refV}, {"actual", body, &v}} {
+		dec := json.NewDecoder(strings.NewReader(x.body))
+		dec.UseNumber()
+		if err := dec.Decode(x.v); err != nil {
+			return errorlist.List{fmt.Errorf("malformed JSON in %s body: %s", x.name, err)}
+		}
+	}
+	sep := c.Sep
+	if sep == "" {
+		sep = "."
+	}
+	ignore := make([][]string, len(c.Ignore))
+	for i, ign := range c.Ignore {
+		ignore[i] = strings.Split(ign, sep)
+	}
+	el := errorlist.List{}
+	compareJSON(nil, refV, v, ignore, sep, &el)
+	return el
+}
+
+// compareJSON appends the differences between the reference ref and the
+// actual value v to el. Elements matching one of the ignore paths are
+// skipped.
+func compareJSON(path []string, ref, v interface{}, ignore [][]string, sep string, el *errorlist.List) {
+	if jsonPathIgnored(path, ignore) {
+		return
+	}
+	name := strings.Join(path, sep)
+	if name == "" {
+		name = "(top level)"
+	}
+
+	switch r := ref.(type) {
+	case map[string]interface{}:
+		a, ok := v.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(r)+len(a))
+		for k := range r {
+			keys = append(keys, k)
+		}
+		for k := range a {
+			if _, ok := r[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			sub := append(path[:len(path):len(path)], k)
+			rv, inRef := r[k]
+			av, inAct := a[k]
+			switch {
+			case jsonPathIgnored(sub, ignore):
+			case !inRef:
+				*el = append(*el, fmt.Errorf("%s: not in reference, got %s",
+					strings.Join(sub, sep), jsonValue(av)))
+			case !inAct:
+				*el = append(*el, fmt.Errorf("%s: missing, reference %s",
+					strings.Join(sub, sep), jsonValue(rv)))
+			default:
+				compareJSON(sub, rv, av, ignore, sep, el)
+			}
+		}
+		return
+	case []interface{}:
+		a, ok := v.([]interface{})
+		if !ok {
+			break
+		}
+		if len(r) != len(a) {
+			*el = append(*el, fmt.Errorf("%s: reference has %d elements, got %d",
+				name, len(r), len(a)))
+		}
+		for i := 0; i < len(r) && i < len(a); i++ {
+			compareJSON(append(path[:len(path):len(path)], strconv.Itoa(i)),
+				r[i], a[i], ignore, sep, el)
+		}
+		return
+	case json.Number:
+		if n, ok := v.(json.Number); ok {
+			if n == r {
+				return
+			}
+			rf, err1 := r.Float64()
+			nf, err2 := n.Float64()
+			if err1 == nil && err2 == nil && rf == nf {
+				return
+			}
+		}
+	default:
+		if ref == v {
+			return
+		}
+	}
+	*el = append(*el, fmt.Errorf("%s: reference %s, got %s", name, jsonValue(ref), jsonValue(v)))
+}
+
+// jsonPathIgnored reports whether path matches one of the ignore paths.
+func jsonPathIgnored(path []string, ignore [][]string) bool {
+outer:
+	for _, ign := range ignore {
+		if len(ign) != len(path) {
+			continue
+		}
+		for i, elem := range ign {
+			if elem != "*" && elem != path[i] {
+				continue outer
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// jsonValue formats v for error messages.
+func jsonValue(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	if len(data) > 60 {
+		return string(data[:57]) + "..."
+	}
+	return string(data)
+}
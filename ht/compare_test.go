@@ -0,0 +1,148 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vdobler/ht/errorlist"
+)
+
+func TestCompareWith(t *testing.T) {
+	// The "green" system under test and the "blue" reference system
+	// serve the same API under different path prefixes.
+	green := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		switch r.URL.Path {
+		case "/api/items":
+			fmt.Fprintf(w, `{"id": "g-17", "q": %q, "total": 2.5,
+				"items": [{"id": 8, "name": "a"}, {"id": 9, "name": "b"}]}`,
+				r.URL.Query().Get("q"))
+		case "/api/user":
+			fmt.Fprint(w, `{"name": "Joe", "roles": ["admin"], "new": true}`)
+		default:
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(404)
+			fmt.Fprint(w, "Not found\nSorry\n")
+		}
+	}))
+	defer green.Close()
+	blue := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=60")
+		switch r.URL.Path {
+		case "/v1/api/items":
+			fmt.Fprintf(w, `{"items": [{"name": "a", "id": 1}, {"name": "b", "id": 2}],
+				"q": %q, "total": 2.50, "id": "b-3"}`, r.URL.Query().Get("q"))
+		case "/v1/api/user":
+			fmt.Fprint(w, `{"name": "Joe", "roles": ["admin", "dev"]}`)
+		default:
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(404)
+			fmt.Fprint(w, "Not found\n")
+		}
+	}))
+	defer blue.Close()
+
+	for i, tc := range []struct {
+		path  string
+		check CompareWith
+		want  []string
+	}{
+		{"/api/items?q=foo", CompareWith{Ignore: []string{"id", "items.*.id"}}, nil},
+		{"/api/items?q=foo", CompareWith{Ignore: []string{"id"}},
+			[]string{"items.0.id: reference 1, got 8", "items.1.id: reference 2, got 9"}},
+		{"/api/items?q=foo", CompareWith{Ignore: []string{"id", "items.*.id"},
+			Header: []string{"cache-control"}},
+			[]string{`header cache-control: reference ["max-age=60"], got ["no-cache"]`}},
+		{"/api/user", CompareWith{},
+			[]string{"new: not in reference, got true",
+				"roles: reference has 2 elements, got 1"}},
+		{"/api/user", CompareWith{IgnoreBody: true}, nil},
+		{"/nothing", CompareWith{},
+			[]string{"body differs (-reference +actual):\n Not found\n+Sorry\n"}},
+	} {
+		tc.check.Reference = blue.URL + "/v1/"
+		test := Test{
+			Request: Request{URL: green.URL + tc.path},
+			Checks:  CheckList{tc.check},
+		}
+		test.Run()
+		got := []string{}
+		for _, err := range errorlist.Details(test.Result.Error) {
+			got = append(got, strings.TrimPrefix(err.Err.Error(), "Check CompareWith: "))
+		}
+		if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+			t.Errorf("%d: got %q, want %q", i, got, tc.want)
+		}
+	}
+}
+
+func TestCompareWithContext(t *testing.T) {
+	green := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer green.Close()
+	blue := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(5 * time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer blue.Close()
+
+	test := Test{
+		Request: Request{URL: green.URL + "/"},
+		Checks:  CheckList{CompareWith{Reference: blue.URL}},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := test.RunContext(ctx); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("Reference request not canceled, took %s", d)
+	}
+	if test.Result.Status != Fail ||
+		!strings.Contains(test.Result.Error.Error(), "reference request") {
+		t.Errorf("Got status %s, error %v", test.Result.Status, test.Result.Error)
+	}
+}
+
+func TestCompareWithAllowedHosts(t *testing.T) {
+	green := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer green.Close()
+	calls := 0
+	blue := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer blue.Close()
+
+	// The reference is the same server but addressed as localhost which
+	// is not an allowed host.
+	reference := strings.Replace(blue.URL, "127.0.0.1", "localhost", 1)
+	test := Test{
+		Request: Request{
+			URL:    green.URL + "/",
+			Header: http.Header{"Authorization": {"Bearer token"}},
+		},
+		Checks:       CheckList{CompareWith{Reference: reference}},
+		AllowedHosts: []string{"127.0.0.1"},
+	}
+	test.Run()
+	if calls != 0 {
+		t.Errorf("Reference request sent to disallowed host")
+	}
+	if test.Result.Status != Fail ||
+		!strings.Contains(test.Result.Error.Error(), "reference request") {
+		t.Errorf("Got status %s, error %v", test.Result.Status, test.Result.Error)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// compilecache.go provides a process-wide cache of compiled artifacts.
+
+package ht
+
+import (
+	"regexp"
+	"sync"
+
+	"github.com/andybalholm/cascadia"
+	hjson "github.com/vdobler/ht/internal/hjson"
+	"gopkg.in/xmlpath.v2"
+)
+
+// maxCompiled limits the number of entries in the compile cache. The cache
+// is flushed once it grows beyond this size which keeps the memory bounded
+// even if variable substitution produces an endless stream of patterns.
+const maxCompiled = 2000
+
+// compileKey identifies a compiled artifact by its kind and its source.
+type compileKey struct {
+	kind, source string
+}
+
+// compiled is the result (or error) of compiling a source.
+type compiled struct {
+	value interface{}
+	err   error
+}
+
+// compileCache caches compiled regular expressions, CSS selectors, XPath
+// expressions and JSON schemas keyed by their source: Repeated tests (e.g.
+// polling or load testing) would otherwise recompile them during each
+// Prepare. The cached values must not be modified.
+var compileCache = struct {
+	sync.Mutex
+	m            map[compileKey]compiled
+	hits, misses int
+}{m: make(map[compileKey]compiled)}
+
+// cachedCompile returns the cached result of compiling source of the given
+// kind, calling compile on a cache miss. Errors are cached too.
+func cachedCompile(kind, source string, compile func() (interface{}, error)) (interface{}, error) {
+	key := compileKey{kind, source}
+	compileCache.Lock()
+	c, ok := compileCache.m[key]
+	if ok {
+		compileCache.hits++
+	}
+	compileCache.Unlock()
+	if ok {
+		return c.value, c.err
+	}
+
+	c.value, c.err = compile()
+
+	compileCache.Lock()
+	compileCache.misses++
+	if len(compileCache.m) >= maxCompiled {
+		compileCache.m = make(map[compileKey]compiled)
+	}
+	compileCache.m[key] = c
+	compileCache.Unlock()
+	return c.value, c.err
+}
+
+// CompileCacheStats returns the number of cache hits and misses of the
+// process-wide cache of compiled regular expressions, selectors, etc.
+func CompileCacheStats() (hits, misses int) {
+	compileCache.Lock()
+	defer compileCache.Unlock()
+	return compileCache.hits, compileCache.misses
+}
+
+// compileRegexp is a cached regexp.Compile.
+func compileRegexp(expr string) (*regexp.Regexp, error) {
+	v, err := cachedCompile("regexp", expr, func() (interface{}, error) {
+		return regexp.Compile(expr)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*regexp.Regexp), nil
+}
+
+// compileSelector is a cached cascadia.Compile.
+func compileSelector(sel string) (cascadia.Selector, error) {
+	v, err := cachedCompile("selector", sel, func() (interface{}, error) {
+		return cascadia.Compile(sel)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(cascadia.Selector), nil
+}
+
+// compileXPath is a cached xmlpath.Compile.
+func compileXPath(path string) (*xmlpath.Path, error) {
+	v, err := cachedCompile("xpath", path, func() (interface{}, error) {
+		return xmlpath.Compile(path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*xmlpath.Path), nil
+}
+
+// parseSchema is a cached unmarshaling of the (H)JSON schema.
+func parseSchema(schema string) (interface{}, error) {
+	return cachedCompile("schema", schema, func() (interface{}, error) {
+		var v interface{}
+		err := hjson.Unmarshal([]byte(schema), &v)
+		return v, err
+	})
+}
@@ -0,0 +1,64 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCompileCache(t *testing.T) {
+	hits, misses := CompileCacheStats()
+	re1, err := compileRegexp("^compile-cache-[0-9]+$")
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	re2, _ := compileRegexp("^compile-cache-[0-9]+$")
+	if re1 != re2 {
+		t.Errorf("Regexp not cached")
+	}
+	if _, err := compileRegexp("compile-cache-("); err == nil {
+		t.Errorf("Missing error")
+	}
+	if _, err := compileRegexp("compile-cache-("); err == nil {
+		t.Errorf("Missing error for cached error")
+	}
+	h, m := CompileCacheStats()
+	if h-hits != 2 || m-misses != 2 {
+		t.Errorf("Got %d hits and %d misses", h-hits, m-misses)
+	}
+
+	c1 := &HTMLTag{Selector: "div.compile-cache"}
+	c2 := &HTMLTag{Selector: "div.compile-cache"}
+	c1.Prepare(nil)
+	c2.Prepare(nil)
+	if c1.sel == nil || c2.sel == nil {
+		t.Errorf("Selector not compiled")
+	}
+	if _, err := parseSchema(`{"a": 1}`); err != nil {
+		t.Errorf("Unexpected error %s", err)
+	}
+}
+
+func TestPrepareDuration(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer ts.Close()
+
+	test := Test{
+		Request: Request{URL: ts.URL + "/"},
+		Checks: []Check{
+			StatusCode{Expect: 200},
+			&Body{Regexp: "prepare-duration"},
+		},
+	}
+	test.Run()
+	cr := test.Result.CheckResults
+	if len(cr) != 2 || cr[0].PrepareDuration != 0 || cr[1].PrepareDuration <= 0 ||
+		cr[1].PrepareDuration > time.Second || cr[1].Duration <= 0 {
+		t.Errorf("Got %+v", cr)
+	}
+}
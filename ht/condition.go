@@ -6,6 +6,8 @@ package ht
 
 import (
 	"fmt"
+	"math"
+	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
@@ -14,6 +16,7 @@ import (
 	"unicode/utf8"
 
 	"github.com/asaskevich/govalidator"
+	"golang.org/x/text/unicode/norm"
 )
 
 // Condition is a conjunction of tests against a string. Note that Contains and
@@ -25,6 +28,13 @@ type Condition struct {
 	// other tests would be redundant.
 	Equals string `json:",omitempty"`
 
+	// Tolerance turns Equals into a numerical comparison: The string
+	// and Equals are parsed as float64 (trimmed like for GreaterThan)
+	// and may differ by at most Tolerance. A zero Tolerance compares
+	// numbers exactly so that "5" equals "5.0".
+	// Nil compares Equals literally.
+	Tolerance *float64 `json:",omitempty"`
+
 	// Prefix is the required prefix
 	Prefix string `json:",omitempty"`
 
@@ -37,6 +47,19 @@ type Condition struct {
 	// Regexp is a regular expression to look for.
 	Regexp string `json:",omitempty"`
 
+	// OneOf is a list of allowed values: The string must equal one
+	// of them. An empty list disables this test.
+	OneOf []string `json:",omitempty"`
+
+	// Glob is a shell-style pattern the whole string must match:
+	//     *      matches any sequence of characters
+	//     ?      matches any single character
+	//     [a-z]  matches one character from the class, [!a-z] one not
+	//            from the class
+	//     \*     matches a literal *
+	// Glob is often simpler than Regexp, e.g. for "order-*.pdf".
+	Glob string `json:",omitempty"`
+
 	// Count determines how many occurrences of Contains or Regexp
 	// are required for a match:
 	//     0: Any positive number of matches is okay
@@ -44,6 +67,17 @@ type Condition struct {
 	//   < 0: No match allowed (invert the condition)
 	Count int `json:",omitempty"`
 
+	// IgnoreCase makes Equals, Prefix, Suffix, Contains and Regexp
+	// case-insensitive.
+	IgnoreCase bool `json:",omitempty"`
+
+	// Normalize the string as well as Equals, Prefix, Suffix and Contains
+	// before comparing them: The strings are brought to Unicode
+	// normalization form C, runs of white space (including non-breaking
+	// spaces) are collapsed to a single space and leading and trailing
+	// white space is removed.
+	Normalize bool `json:",omitempty"`
+
 	// Min and Max are the minimum and maximum length the string may
 	// have. Two zero values disables this test.
 	Min, Max int `json:",omitempty"`
@@ -85,7 +119,15 @@ type Condition struct {
 	// with Time as the layout string.
 	Time string `json:",omitempty"`
 
-	re *regexp.Regexp
+	// Before and After are exclusive upper and lower bounds on the
+	// time value of the string. The (dequoted) string as well as Before
+	// and After are parsed with Time as the layout or as RFC 3339 if
+	// Time is empty. If the string is not a valid time these conditions
+	// fail.
+	Before, After string `json:",omitempty"`
+
+	re   *regexp.Regexp
+	glob *regexp.Regexp
 }
 
 func isFilePath(s string) bool {
@@ -150,51 +192,106 @@ var ValidationMap = map[string]func(string) bool{
 	"VariableWidth":    govalidator.IsVariableWidth,
 }
 
+// IsZero reports whether c is the zero Condition which imposes no
+// requirements on a string.
+func (c Condition) IsZero() bool {
+	if len(c.OneOf) > 0 {
+		return false
+	}
+	c.OneOf = nil
+	return reflect.DeepEqual(c, Condition{})
+}
+
 // Fulfilled returns whether s matches all requirements of c.
 // A nil return value indicates that s matches the defined conditions.
 // A non-nil return indicates missmatch.
 func (c Condition) Fulfilled(s string) error {
+	if c.Equals != "" && c.Tolerance != nil {
+		want, err := parseNumber(c.Equals)
+		if err != nil {
+			return err
+		}
+		got, err := parseNumber(s)
+		if err != nil {
+			return err
+		}
+		if math.Abs(got-want) > *c.Tolerance {
+			return fmt.Errorf("Unequal, was %g, want %g±%g", got, want, *c.Tolerance)
+		}
+		return nil
+	}
+
+	// Text comparisons work on the prepared string.
+	text := c.prepare(s)
+
 	if c.Equals != "" {
-		if s == c.Equals {
+		if text == c.prepare(c.Equals) {
 			return nil
 		}
-		ls, le := len(s), len(c.Equals)
+		ls, le := len(text), len(c.Equals)
 		if ls <= (15*le)/10 {
 			// Show full value if not 50% longer.
-			return fmt.Errorf("Unequal, was %q", s)
+			return fmt.Errorf("Unequal, was %q", text)
 		}
 		// Show 10 more characters
 		end := le + 10
 		if end > ls {
 			end = ls
-			return fmt.Errorf("Unequal, was %q", s)
+			return fmt.Errorf("Unequal, was %q", text)
 		}
-		return fmt.Errorf("Unequal, was %q...", s[:end])
+		return fmt.Errorf("Unequal, was %q...", text[:end])
 	}
 
-	if c.Prefix != "" && !strings.HasPrefix(s, c.Prefix) {
+	if len(c.OneOf) > 0 {
+		found := false
+		for _, alt := range c.OneOf {
+			if text == c.prepare(alt) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("Not one of %q, was %q", c.OneOf, LimitString(text))
+		}
+	}
+
+	if c.Glob != "" {
+		glob := c.glob
+		if glob == nil {
+			var err error
+			if glob, err = globRegexp(c.Glob, c.IgnoreCase); err != nil {
+				return err
+			}
+		}
+		if !glob.MatchString(text) {
+			return fmt.Errorf("Does not match glob %q, was %q", c.Glob, LimitString(text))
+		}
+	}
+
+	if c.Prefix != "" && !strings.HasPrefix(text, c.prepare(c.Prefix)) {
 		n := len(c.Prefix)
-		if len(s) < n {
-			n = len(s)
+		if len(text) < n {
+			n = len(text)
 		}
-		return fmt.Errorf("Bad prefix, got %q", s[:n])
+		return fmt.Errorf("Bad prefix, got %q", text[:n])
 	}
 
-	if c.Suffix != "" && !strings.HasSuffix(s, c.Suffix) {
+	if c.Suffix != "" && !strings.HasSuffix(text, c.prepare(c.Suffix)) {
 		n := len(c.Suffix)
-		if len(s) < n {
-			n = len(s)
+		if len(text) < n {
+			n = len(text)
 		}
-		return fmt.Errorf("Bad suffix, got %q", s[len(s)-n:])
+		return fmt.Errorf("Bad suffix, got %q", text[len(text)-n:])
 	}
 
 	if c.Contains != "" {
-		if c.Count == 0 && !strings.Contains(s, c.Contains) {
+		contains := c.prepare(c.Contains)
+		if c.Count == 0 && !strings.Contains(text, contains) {
 			return fmt.Errorf("Cannot find %q", c.Contains)
-		} else if c.Count < 0 && strings.Contains(s, c.Contains) {
+		} else if c.Count < 0 && strings.Contains(text, contains) {
 			return fmt.Errorf("Found forbidden %q", c.Contains)
 		} else if c.Count > 0 {
-			if cnt := strings.Count(s, c.Contains); cnt != c.Count {
+			if cnt := strings.Count(text, contains); cnt != c.Count {
 				return fmt.Errorf("Found %d occurrences of %q, want %d",
 					cnt, c.Contains, c.Count)
 			}
@@ -202,12 +299,12 @@ func (c Condition) Fulfilled(s string) error {
 	}
 
 	if c.re != nil {
-		if c.Count == 0 && c.re.FindStringIndex(s) == nil {
+		if c.Count == 0 && c.re.FindStringIndex(text) == nil {
 			return fmt.Errorf("Cannot find match for regexp %q", c.re.String())
-		} else if c.Count < 0 && c.re.FindStringIndex(s) != nil {
+		} else if c.Count < 0 && c.re.FindStringIndex(text) != nil {
 			return fmt.Errorf("Found forbidden match of regexp %q", c.re.String())
 		} else if c.Count > 0 {
-			if m := c.re.FindAllString(s, -1); len(m) != c.Count {
+			if m := c.re.FindAllString(text, -1); len(m) != c.Count {
 				return fmt.Errorf("Found %d matches of regexp %q, want %d",
 					len(m), c.re.String(), c.Count)
 			}
@@ -227,12 +324,7 @@ func (c Condition) Fulfilled(s string) error {
 	}
 
 	if c.GreaterThan != nil || c.LessThan != nil {
-		// Trim and parse s.
-		trim := func(r rune) bool {
-			return unicode.IsSpace(r) || r == '"' || r == '\''
-		}
-		t := strings.TrimFunc(s, trim)
-		numericVal, err := strconv.ParseFloat(t, 64)
+		numericVal, err := parseNumber(s)
 		if err != nil {
 			return err
 		}
@@ -252,7 +344,11 @@ func (c Condition) Fulfilled(s string) error {
 		}
 	}
 
-	if c.Time != "" {
+	if c.Before != "" || c.After != "" {
+		if err := c.checkTime(s); err != nil {
+			return err
+		}
+	} else if c.Time != "" {
 		if _, err := time.Parse(c.Time, dequoteString(s)); err != nil {
 			return err
 		}
@@ -261,24 +357,160 @@ func (c Condition) Fulfilled(s string) error {
 	return nil
 }
 
+// prepare returns s normalized and case folded if requested by c.
+func (c Condition) prepare(s string) string {
+	if c.Normalize {
+		s = strings.Join(strings.Fields(norm.NFC.String(s)), " ")
+	}
+	if c.IgnoreCase {
+		s = strings.ToLower(s)
+	}
+	return s
+}
+
+// parseNumber parses s as a float64 after trimming spaces as well as single
+// and double quotes.
+func parseNumber(s string) (float64, error) {
+	trim := func(r rune) bool {
+		return unicode.IsSpace(r) || r == '"' || r == '\''
+	}
+	return strconv.ParseFloat(strings.TrimFunc(s, trim), 64)
+}
+
+// timeLayout returns the layout used to parse times in c.
+func (c Condition) timeLayout() string {
+	if c.Time == "" {
+		return time.RFC3339
+	}
+	return c.Time
+}
+
+// checkTime checks the Before and After conditions.
+func (c Condition) checkTime(s string) error {
+	layout := c.timeLayout()
+	t, err := time.Parse(layout, dequoteString(s))
+	if err != nil {
+		return err
+	}
+	if c.Before != "" {
+		before, err := time.Parse(layout, c.Before)
+		if err != nil {
+			return err
+		}
+		if !t.Before(before) {
+			return fmt.Errorf("Not before %s, was %s", c.Before, dequoteString(s))
+		}
+	}
+	if c.After != "" {
+		after, err := time.Parse(layout, c.After)
+		if err != nil {
+			return err
+		}
+		if !t.After(after) {
+			return fmt.Errorf("Not after %s, was %s", c.After, dequoteString(s))
+		}
+	}
+	return nil
+}
+
 // FulfilledBytes provides a optimized version for Fulfilled(string(byteSlice)).
 // TODO: Make this a non-lie.
 func (c Condition) FulfilledBytes(b []byte) error {
 	return c.Fulfilled(string(b))
 }
 
-// Compile pre-compiles the regular expression if part of c.
+// Compile pre-compiles the regular expression if part of c and validates
+// the numbers and times used in the comparisons.
 func (c *Condition) Compile() (err error) {
 	if c.Regexp != "" {
-		c.re, err = regexp.Compile(c.Regexp)
+		pattern := c.Regexp
+		if c.IgnoreCase {
+			pattern = "(?i)" + pattern
+		}
+		c.re, err = compileRegexp(pattern)
 		if err != nil {
 			c.re = nil
 			return err
 		}
 	}
+	if c.Glob != "" {
+		c.glob, err = globRegexp(c.Glob, c.IgnoreCase)
+		if err != nil {
+			c.glob = nil
+			return err
+		}
+	}
+	if c.Tolerance != nil {
+		if *c.Tolerance < 0 {
+			return fmt.Errorf("negative Tolerance %g", *c.Tolerance)
+		}
+		if _, err := parseNumber(c.Equals); err != nil {
+			return fmt.Errorf("Equals is not a number: %s", err)
+		}
+	}
+	for _, t := range []string{c.Before, c.After} {
+		if t == "" {
+			continue
+		}
+		if _, err := time.Parse(c.timeLayout(), t); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// globRegexp translates the shell-style pattern glob into a regular
+// expression matching the whole string.
+func globRegexp(glob string, ignoreCase bool) (*regexp.Regexp, error) {
+	buf := &strings.Builder{}
+	if ignoreCase {
+		buf.WriteString("(?i)")
+	}
+	buf.WriteString(`(?s)\A`)
+	runes := []rune(glob)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '*':
+			buf.WriteString(".*")
+		case '?':
+			buf.WriteString(".")
+		case '\\':
+			if i+1 == len(runes) {
+				return nil, fmt.Errorf("trailing \\ in glob %q", glob)
+			}
+			i++
+			buf.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case '[':
+			// A ']' directly after '[' or '[!' is part of the class.
+			j := i + 1
+			if j < len(runes) && runes[j] == '!' {
+				j++
+			}
+			if j < len(runes) && runes[j] == ']' {
+				j++
+			}
+			for j < len(runes) && runes[j] != ']' {
+				j++
+			}
+			if j == len(runes) {
+				return nil, fmt.Errorf("unterminated [ in glob %q", glob)
+			}
+			class := string(runes[i+1 : j])
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			} else if strings.HasPrefix(class, "^") {
+				class = `\^` + class[1:]
+			}
+			buf.WriteString("[" + strings.Replace(class, "[", `\[`, -1) + "]")
+			i = j
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	buf.WriteString(`\z`)
+	return compileRegexp(buf.String())
+}
+
 // Dequote s:  "foobar"  -->  fobar
 func dequoteString(s string) string {
 	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
@@ -5,12 +5,13 @@
 package ht
 
 import (
-	"regexp"
 	"testing"
 )
 
 var float12_3 float64 = 12.3
 var float456 float64 = 456
+var float0 float64
+var float0_01 float64 = 0.01
 
 var conditionTests = []struct {
 	s string
@@ -66,6 +67,35 @@ var conditionTests = []struct {
 		`Found forbidden match of regexp "[aeiou]."`},
 	{"frtgbwu", Condition{Regexp: "[aeiou]."}, `Cannot find match for regexp "[aeiou]."`},
 
+	// IgnoreCase and Normalize
+	{"FooBar", Condition{Equals: "foobar", IgnoreCase: true}, ``},
+	{"FooBar", Condition{Prefix: "FOO", Suffix: "bAr", IgnoreCase: true}, ``},
+	{"FooBarFOO", Condition{Contains: "foo", Count: 2, IgnoreCase: true}, ``},
+	{"FooBar", Condition{Regexp: "^foo", IgnoreCase: true}, ``},
+	{"FooBar", Condition{Contains: "baz", IgnoreCase: true}, `Cannot find "baz"`},
+	{"Hello\u00a0 \t World ", Condition{Equals: "Hello World", Normalize: true}, ``},
+	{"Caf\u0065\u0301 au lait", Condition{Prefix: "Caf\u00e9 au", Normalize: true}, ``},
+	{"  Total:\u00a012 EUR", Condition{Contains: "TOTAL: 12 eur", Normalize: true, IgnoreCase: true}, ``},
+	{"Hello\u00a0World", Condition{Equals: "Hello World"}, `Unequal, was "Hello\u00a0World"`},
+
+	// OneOf
+	{"red", Condition{OneOf: []string{"red", "green", "blue"}}, ``},
+	{"Green", Condition{OneOf: []string{"red", "green"}, IgnoreCase: true}, ``},
+	{"pink", Condition{OneOf: []string{"red", "green"}}, `Not one of ["red" "green"], was "pink"`},
+
+	// Glob
+	{"order-123.pdf", Condition{Glob: "order-*.pdf"}, ``},
+	{"order-123.pdf", Condition{Glob: "order-???.pdf"}, ``},
+	{"order-12.pdf", Condition{Glob: "order-???.pdf"}, `Does not match glob "order-???.pdf", was "order-12.pdf"`},
+	{"a/b/c", Condition{Glob: "a*c"}, ``},
+	{"ORDER-1.PDF", Condition{Glob: "order-[0-9].pdf", IgnoreCase: true}, ``},
+	{"order-x.pdf", Condition{Glob: "order-[!0-9].pdf"}, ``},
+	{"order-1.pdf", Condition{Glob: "order-[!0-9].pdf"}, `Does not match glob "order-[!0-9].pdf", was "order-1.pdf"`},
+	{"a*b", Condition{Glob: `a\*b`}, ``},
+	{"axb", Condition{Glob: `a\*b`}, `Does not match glob "a\\*b", was "axb"`},
+	{"a.b", Condition{Glob: "a?b"}, ``},
+	{"prefix-order-1.pdf", Condition{Glob: "order-*"}, `Does not match glob "order-*", was "prefix-order-1.pdf"`},
+
 	// Min and Max
 	{"foobar", Condition{Min: 2}, ``},
 	{"foobar", Condition{Min: 20}, `Too short, was 6`},
@@ -83,6 +113,14 @@ var conditionTests = []struct {
 		`strconv.ParseFloat: parsing "XYZ": invalid syntax`},
 	{"200", Condition{GreaterThan: &float12_3, LessThan: &float456}, ``},
 
+	// Equals with Tolerance
+	{"5.0", Condition{Equals: "5", Tolerance: &float0}, ``},
+	{`"5"`, Condition{Equals: "5.00", Tolerance: &float0}, ``},
+	{"5.001", Condition{Equals: "5", Tolerance: &float0_01}, ``},
+	{"5.1", Condition{Equals: "5", Tolerance: &float0_01}, `Unequal, was 5.1, want 5±0.01`},
+	{"five", Condition{Equals: "5", Tolerance: &float0},
+		`strconv.ParseFloat: parsing "five": invalid syntax`},
+
 	// Is (type check)
 	{"name.name@domain.org", Condition{Is: "Email"}, ``},
 	{"CH", Condition{Is: "ISO3166Alpha2"}, ``},
@@ -106,12 +144,25 @@ var conditionTests = []struct {
 	{`"2009-11-10 23:00:00"`, Condition{Time: "2006-01-02 15:04:05"}, ``},
 	{"2009-NOV-10 11:00 pm", Condition{Time: "2006-01-02 15:04:05"},
 		`parsing time "2009-NOV-10 11:00 pm": month out of range`},
+
+	// Before and After
+	{`"2017-05-01T12:00:00Z"`, Condition{After: "2017-01-01T00:00:00Z"}, ``},
+	{"2017-05-01T12:00:00+02:00", Condition{Before: "2017-05-01T12:00:00Z"}, ``},
+	{"2017-05-01T12:00:00Z", Condition{Before: "2017-05-01T12:00:00Z"},
+		`Not before 2017-05-01T12:00:00Z, was 2017-05-01T12:00:00Z`},
+	{"2017-05-01", Condition{Time: "2006-01-02", After: "2017-01-01", Before: "2018-01-01"}, ``},
+	{"2016-05-01", Condition{Time: "2006-01-02", After: "2017-01-01"},
+		`Not after 2017-01-01, was 2016-05-01`},
+	{"yesterday", Condition{Time: "2006-01-02", After: "2017-01-01"},
+		`parsing time "yesterday" as "2006-01-02": cannot parse "yesterday" as "2006"`},
 }
 
 func TestCondition(t *testing.T) {
 	for i, tc := range conditionTests {
-		if tc.c.Regexp != "" {
-			tc.c.re = regexp.MustCompile(tc.c.Regexp)
+		if tc.c.Regexp != "" || tc.c.Glob != "" {
+			if err := tc.c.Compile(); err != nil {
+				t.Fatalf("%d. %s, unexpected error %s", i, tc.s, err)
+			}
 		}
 		err := tc.c.Fulfilled(tc.s)
 		switch {
@@ -126,6 +177,36 @@ func TestCondition(t *testing.T) {
 	}
 }
 
+func TestConditionCompile(t *testing.T) {
+	negative := -1.0
+	for i, c := range []Condition{
+		{Equals: "five", Tolerance: &float0},
+		{Glob: "file[0-9"},
+		{Equals: "5", Tolerance: &negative},
+		{Before: "2017-05-01"},
+		{Time: "2006-01-02", After: "May 2017"},
+	} {
+		if err := c.Compile(); err == nil {
+			t.Errorf("%d. missing error for %+v", i, c)
+		}
+	}
+	c := Condition{Equals: "5", Tolerance: &float0, Time: "2006-01-02", Before: "2017-05-01"}
+	if err := c.Compile(); err != nil {
+		t.Errorf("Unexpected error %s", err)
+	}
+}
+
+func TestConditionIsZero(t *testing.T) {
+	if !(Condition{}).IsZero() || !(Condition{OneOf: []string{}}).IsZero() {
+		t.Errorf("Zero condition not reported as zero")
+	}
+	for i, c := range []Condition{{Equals: "a"}, {OneOf: []string{"a"}}, {IgnoreCase: true}} {
+		if c.IsZero() {
+			t.Errorf("%d. %+v reported as zero", i, c)
+		}
+	}
+}
+
 func TestLimitString(t *testing.T) {
 	for i, tc := range []struct {
 		in   string
@@ -11,11 +11,14 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/vdobler/ht/cookiejar"
 )
 
 func init() {
 	RegisterCheck(&SetCookie{})
 	RegisterCheck(&DeleteCookie{})
+	RegisterCheck(&CookieJar{})
 }
 
 func findCookiesByName(t *Test, name string) (cookies []*http.Cookie) {
@@ -239,3 +242,72 @@ func (c DeleteCookie) Execute(t *Test) error {
 	}
 	return nil
 }
+
+// ----------------------------------------------------------------------------
+// CookieJar
+
+// CookieJar checks the content of the test's cookie jar after the response
+// was received, i.e. the cookies stored after processing all Set-Cookie
+// headers (of redirects too) according to RFC 6265 and the jar's policy.
+// The test must use a cookie jar, e.g. by being part of a suite with
+// KeepCookies set.
+type CookieJar struct {
+	// Domain whose cookies (including the cookies of its subdomains)
+	// are checked. Empty means the host of the request.
+	Domain string `json:",omitempty"`
+
+	// Name of the cookie. Empty means any cookie.
+	Name string `json:",omitempty"`
+
+	// Value is applied to the value of all cookies with the given Name.
+	Value Condition `json:",omitempty"`
+
+	// Absent indicates that no cookie with the given Name (or no cookie
+	// at all if Name is empty) may be stored.
+	Absent bool `json:",omitempty"`
+}
+
+// Execute implements Check's Execute method.
+func (c CookieJar) Execute(t *Test) error {
+	if t.Jar == nil {
+		return fmt.Errorf("test uses no cookie jar")
+	}
+	domain := c.Domain
+	if domain == "" && t.Request.Request != nil {
+		domain = t.Request.Request.URL.Hostname()
+	}
+
+	var cookies []cookiejar.Entry
+	for _, e := range t.Jar.DomainEntries(domain) {
+		if c.Name == "" || e.Name == c.Name {
+			cookies = append(cookies, e)
+		}
+	}
+
+	if c.Absent {
+		if len(cookies) > 0 {
+			return fmt.Errorf("Found cookie %s=%s for %s",
+				cookies[0].Name, cookies[0].Value, cookies[0].Domain)
+		}
+		return nil
+	}
+	if len(cookies) == 0 {
+		if c.Name == "" {
+			return fmt.Errorf("No cookies stored for %s", domain)
+		}
+		return fmt.Errorf("No cookie %s stored for %s", c.Name, domain)
+	}
+	for _, e := range cookies {
+		if err := c.Value.Fulfilled(e.Value); err != nil {
+			return fmt.Errorf("Bad value of cookie %s for %s: %s", e.Name, e.Domain, err)
+		}
+	}
+	return nil
+}
+
+// Prepare implements Check's Prepare method.
+func (c *CookieJar) Prepare(*Test) error {
+	return c.Value.Compile()
+}
+
+var _ Preparable = &CookieJar{}
@@ -9,6 +9,8 @@ import (
 	"net/url"
 	"testing"
 	"time"
+
+	"github.com/vdobler/ht/cookiejar"
 )
 
 var cookieResp = Response{Response: &http.Response{
@@ -76,3 +78,48 @@ func TestDeleteCookie(t *testing.T) {
 		runTest(t, i, tc)
 	}
 }
+
+func TestCookieJar(t *testing.T) {
+	jar, _ := cookiejar.New(nil)
+	u, _ := url.Parse("http://www.example.org/login")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "abc123"},
+		{Name: "lang", Value: "en", Domain: "example.org"},
+	})
+	request, _ := http.NewRequest("GET", u.String(), nil)
+	test := &Test{Jar: jar}
+	test.Request.Request = request
+
+	for i, tc := range []struct {
+		c    CookieJar
+		want string
+	}{
+		{CookieJar{Name: "session"}, ""},
+		{CookieJar{Name: "session", Value: Condition{Prefix: "abc"}}, ""},
+		{CookieJar{Name: "session", Value: Condition{Prefix: "xyz"}},
+			`Bad value of cookie session for www.example.org: Bad prefix, got "abc"`},
+		{CookieJar{Domain: "example.org", Name: "lang", Value: Condition{Equals: "en"}}, ""},
+		{CookieJar{Domain: "example.org"}, ""},
+		{CookieJar{Domain: "other.org"}, "No cookies stored for other.org"},
+		{CookieJar{Name: "token"}, "No cookie token stored for www.example.org"},
+		{CookieJar{Name: "token", Absent: true}, ""},
+		{CookieJar{Domain: "example.org", Name: "session", Absent: true},
+			"Found cookie session=abc123 for www.example.org"},
+		{CookieJar{Domain: "other.org", Absent: true}, ""},
+	} {
+		if err := tc.c.Prepare(test); err != nil {
+			t.Fatalf("%d. Unexpected error %s", i, err)
+		}
+		got := ""
+		if err := tc.c.Execute(test); err != nil {
+			got = err.Error()
+		}
+		if got != tc.want {
+			t.Errorf("%d. Got %q, want %q", i, got, tc.want)
+		}
+	}
+
+	if err := (CookieJar{}).Execute(&Test{}); err == nil {
+		t.Errorf("Missing error for test without jar")
+	}
+}
@@ -29,18 +29,22 @@
 //
 // The following checks are provided
 //     * AnyOne          logical OR of several tests
+//     * Assets          resources of a HTML page loaded like a browser
 //     * Body            text in the response body
 //     * Cache           Cache-Control header
+//     * Charset         declared charset matches the body's bytes
+//     * CompareWith     same response as a reference system
 //     * ContentType     Content-Type header
 //     * CustomJS        performed by your own JavaScript code
 //     * DeleteCookie    for proper deletion of cookies
 //     * ETag            presence of working ETag header
+//     * External        performed by an external program
 //     * FinalURL        final URL after a redirect chain
 //     * Header          presence and values of received HTTP header
 //     * HTMLContains    text content of CSS-selected elements
 //     * HTMLTag         occurrence HTML elements chosen via CSS-selectors
 //     * Header          HTTP header fields
-//     * Identity        the SHA1 hash of the HTTP body
+//     * Identity        the SHA1 or SHA-256 hash of the HTTP body
 //     * Image           image format, size and content
 //     * JSON            structure and content of a JSON body
 //     * JSONExpr        structure and content of a JSON body
@@ -57,7 +61,9 @@
 //     * ResponseTime    lower and higher bounds on the response time
 //     * Screenshot      render screen via PhantomJS and compare to reference
 //     * SetCookie       properties of received cookies
+//     * Snapshot        response against a recorded baseline
 //     * Sorted          sorted occurrence of text on body
+//     * StatusClass     the received HTTP status code against status classes
 //     * StatusCode      the received HTTP status code
 //     * UTF8Encoded     that the HTTP body is UTF-8 encoded
 //     * ValidHTML       not obviousely malformed HTML
@@ -81,6 +87,8 @@
 // Tests can be retried for a given maximum number of times. If all executions
 // fail the tests fails. This behaviour and detail timing can be controlled with
 // Test's Execution field.
+// The tries can be isolated from each other by using fresh cookies,
+// connections and variables for each try.
 //
 // Sometimes a response provides information necessary for subsequent requests.
 // Cookie handling can be delegated to a cookie jar by providing all Tests with
@@ -88,11 +96,41 @@
 // Other values can be extracted from the response via a set of Extractors:
 //   * BodyExtractor    via regular expression from body
 //   * CookieExtractor  a cookie value
+//   * ExternalExtractor via an external program
 //   * HTMLExtractor    value of a HTML attribute or HTML text
 //   * JSExtractor      custom via interpreded JavaScript script
 //   * JSONExtractor    from a JSON document
 //   * SetVariable      not extracted but set manually
 //
+// Response bodies in encrypted or encoded envelopes can be decrypted
+// or decoded with the Transform of a test before checks and extractors
+// see them. Bodies declaring a charset other than UTF-8 in the Content-Type
+// header or a HTML meta tag are transcoded to UTF-8 automatically, see
+// Response.Charset.
+//
+// Hooks allow to run Go code or external scripts before each request (e.g.
+// to sign it), after each response (e.g. to decrypt the body) and once a
+// test failed (e.g. to collect server logs).
+//
+// LoadResources makes a test fetch the stylesheets, scripts and images of
+// a HTML page concurrently like a browser; the Assets check reports broken,
+// slow or foreign resources and the total page weight.
+//
+// The Snapshot check records the first response (selected DOM regions,
+// links and assets or JSON with masked volatile elements) as a baseline
+// file and compares later responses against it; ht's -update-snapshots
+// flag (or UpdateSnapshots) refreshes the baselines.
+//
+// The CompareWith check sends the request a second time to a reference
+// system (e.g. the previous release) and reports differences in the status,
+// headers and (structurally compared JSON) bodies of the two responses.
+//
+// A WireLog dumps the requests and responses of a test as sent and received
+// over the wire to a file. Credentials are redacted: The values of headers
+// like Authorization or Set-Cookie are never dumped and secret values are
+// masked. The bodies are capped and MaxFiles throttles the number of files
+// written e.g. during load tests.
+//
 //
 // Requests
 //
@@ -103,6 +141,17 @@
 // All the ugly stuff like parameter encoding, generation of multipart
 // bodies, etc. are hidden from the user.
 //
+// Requests to APIs authenticated by a HMAC signature (like HTTP Signatures
+// or custom X-Signature headers) can be signed declaratively via the
+// Signature of the request.
+//
+// Response bodies are read completely into memory unless the request
+// limits this with MaxBodySize: Larger bodies are truncated (and hashed)
+// and the checks UTF8Encoded, Identity and Body (if only Contains, Min and
+// Max are used) operate on the whole body as a stream while reading it.
+// Other checks see only the truncated body with BodyErr set to
+// ErrBodyTruncated; most of them fail with ErrBadBody.
+//
 //
 // Pseudo Request
 //
@@ -193,6 +242,29 @@
 //         - "text/plain":               plain text file columns separated by \t
 //         - "text/plain; fieldsep=X":   plain text file columns separated by X
 //     The result if the query is returned in the Response.BodyStr
+//   * A failing query or statement results in an Error of the test
+//     unless its outcome is mapped to a status code (see below).
+//
+//
+// Status Mapping of Pseudo-Requests
+//
+// The status codes of pseudo-requests can be configured through
+// Request.StatusMapping which maps outcomes to status codes. This allows to
+// write checks for pseudo-requests like for HTTP requests, e.g. with
+// StatusClass{Expect: "2xx"}. The following outcomes are available, the most
+// specific one mapped is used:
+//    - "ok"              success of any pseudo-request
+//    - "notexist"        file:// file does not exist
+//    - "permission"      file:// missing permissions
+//    - "exist"           file:// file exists
+//    - "error"           file:// any other problem, sql:// any failure
+//    - "exit:N"          bash:// exit code N, e.g. "exit:0" or "exit:3"
+//    - "exit:*"          bash:// any nonzero exit code
+//    - "timeout"         bash:// script canceled due to timeout
+//    - "sqlstate:XXXXX"  sql:// failure with the given SQLSTATE (if the
+//                        database driver reports it)
+//    - "sqlstate:XX"     sql:// failure with the given SQLSTATE class
+// A mapped sql:// failure returns the error message as a text/plain body.
 //
 //
 // Rendered Webpages
@@ -0,0 +1,249 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// external.go contains checks and extractors implemented by external programs.
+
+package ht
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterCheck(External{})
+	RegisterExtractor(ExternalExtractor{})
+}
+
+// DefaultExternalTimeout is the time an external program may run if its
+// Timeout is zero.
+var DefaultExternalTimeout = 10 * time.Second
+
+// ExternalRequest is the JSON document written to the standard input of an
+// external check or extractor program.
+type ExternalRequest struct {
+	// Kind is "check", "extractor" or the name of a hook like
+	// "PreRequest", see Hooks.
+	Kind string
+
+	// Config is the Config of the External check or extractor.
+	Config interface{} `json:",omitempty"`
+
+	// Test is the executed test.
+	Test ExternalTest
+}
+
+// ExternalTest is the part of an executed Test passed to external programs.
+type ExternalTest struct {
+	Name      string
+	Variables map[string]string `json:",omitempty"`
+	Request   struct {
+		Method string
+		URL    string
+		Header http.Header `json:",omitempty"`
+		Body   string      `json:",omitempty"`
+	}
+	Response struct {
+		StatusCode int         `json:",omitempty"`
+		Header     http.Header `json:",omitempty"`
+		Body       string      `json:",omitempty"`
+		Duration   time.Duration
+	}
+
+	// Status and Error of a failed test, e.g. for the OnFailure hook.
+	Status string `json:",omitempty"`
+	Error  string `json:",omitempty"`
+}
+
+// ExternalVerdict is the JSON document an external program must print to
+// its standard output.
+type ExternalVerdict struct {
+	// Status is "Pass", "Fail" or "Error". Error signals that the check
+	// or extractor could not be performed at all.
+	Status string
+
+	// Message explains a Fail or Error.
+	Message string `json:",omitempty"`
+
+	// Value is the extracted value of an external extractor.
+	Value string `json:",omitempty"`
+}
+
+// External is a check performed by an external program which allows to
+// write checks in any language without recompiling ht.
+//
+// The program Command is started with Args. It receives an ExternalRequest
+// of kind "check" as JSON on its standard input and must print an
+// ExternalVerdict as JSON to its standard output, e.g.
+//     {"Status": "Fail", "Message": "price 0.00 not allowed"}
+// A nonzero exit status or output which is not a verdict is an error.
+type External struct {
+	// Command is the program to execute and Args its arguments.
+	Command string
+	Args    []string `json:",omitempty"`
+
+	// Config is passed unchanged to the program.
+	Config interface{} `json:",omitempty"`
+
+	// Timeout limits the runtime of the program. Zero means
+	// DefaultExternalTimeout.
+	Timeout time.Duration `json:",omitempty"`
+}
+
+// Prepare implements Check's Prepare method.
+func (e External) Prepare(*Test) error {
+	if e.Command == "" {
+		return errors.New("missing Command")
+	}
+	return nil
+}
+
+var _ Preparable = External{}
+
+// Execute implements Check's Execute method.
+func (e External) Execute(t *Test) error {
+	if t.Response.BodyErr != nil {
+		return ErrBadBody
+	}
+	verdict, err := runExternal(t, "check", e.Command, e.Args, e.Config, e.Timeout)
+	if err != nil {
+		return CantCheck{err}
+	}
+	switch verdict.Status {
+	case "Pass":
+		return nil
+	case "Fail":
+		if verdict.Message == "" {
+			return errors.New("failed")
+		}
+		return errors.New(verdict.Message)
+	}
+	return CantCheck{verdict.err()}
+}
+
+// ExternalExtractor extracts a value with an external program. The program
+// is called like the one of an External check but receives an ExternalRequest
+// of kind "extractor" and must print a verdict with status "Pass" and the
+// extracted Value.
+type ExternalExtractor struct {
+	// Command is the program to execute and Args its arguments.
+	Command string
+	Args    []string `json:",omitempty"`
+
+	// Config is passed unchanged to the program.
+	Config interface{} `json:",omitempty"`
+
+	// Timeout limits the runtime of the program. Zero means
+	// DefaultExternalTimeout.
+	Timeout time.Duration `json:",omitempty"`
+}
+
+// Extract implements Extractor's Extract method.
+func (e ExternalExtractor) Extract(t *Test) (string, error) {
+	if e.Command == "" {
+		return "", errors.New("missing Command")
+	}
+	verdict, err := runExternal(t, "extractor", e.Command, e.Args, e.Config, e.Timeout)
+	if err != nil {
+		return "", err
+	}
+	if verdict.Status != "Pass" {
+		return "", verdict.err()
+	}
+	return verdict.Value, nil
+}
+
+func (v ExternalVerdict) err() error {
+	msg := v.Message
+	if msg == "" {
+		msg = "no message"
+	}
+	if v.Status == "Error" || v.Status == "Fail" {
+		return errors.New(msg)
+	}
+	return fmt.Errorf("bad status %q in verdict (%s)", v.Status, msg)
+}
+
+// runExternal executes command with args, passes the test t to it and
+// returns its verdict.
+func runExternal(t *Test, kind, command string, args []string, config interface{}, timeout time.Duration) (ExternalVerdict, error) {
+	verdict := ExternalVerdict{}
+	output, err := runExternalCommand(t, kind, command, args, config, timeout)
+	if err != nil {
+		return verdict, err
+	}
+	if err := json.Unmarshal(output, &verdict); err != nil {
+		return verdict, fmt.Errorf("%s: malformed verdict: %s", command, err)
+	}
+	return verdict, nil
+}
+
+// runExternalCommand executes command with args, writes an ExternalRequest
+// for t to its standard input and returns its standard output.
+func runExternalCommand(t *Test, kind, command string, args []string, config interface{}, timeout time.Duration) ([]byte, error) {
+	input, err := json.Marshal(ExternalRequest{
+		Kind:   kind,
+		Config: config,
+		Test:   externalTest(t),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if timeout <= 0 {
+		timeout = DefaultExternalTimeout
+	}
+	ctx, cancel := context.WithTimeout(t.context(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.WaitDelay = 100 * time.Millisecond
+	cmd.Stdin = bytes.NewReader(input)
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	t.debugf("Running external %s %s", kind, command)
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("%s timed out after %s", command, timeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %s (%s)", command, err, msg)
+		}
+		return nil, fmt.Errorf("%s: %s", command, err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// externalTest extracts the data passed to external programs from t.
+func externalTest(t *Test) ExternalTest {
+	et := ExternalTest{
+		Name:      t.Name,
+		Variables: t.Variables,
+	}
+	et.Request.Method = t.Request.Method
+	et.Request.URL = t.Request.URL
+	et.Request.Body = t.Request.SentBody
+	if req := t.Request.Request; req != nil {
+		et.Request.Method = req.Method
+		et.Request.URL = req.URL.String()
+		et.Request.Header = req.Header
+	}
+	if resp := t.Response.Response; resp != nil {
+		et.Response.StatusCode = resp.StatusCode
+		et.Response.Header = resp.Header
+	}
+	et.Response.Body = t.Response.BodyStr
+	et.Response.Duration = t.Response.Duration
+	if t.Result.Error != nil {
+		et.Status = t.Result.Status.String()
+		et.Error = t.Result.Error.Error()
+	}
+	return et
+}
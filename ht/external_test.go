@@ -0,0 +1,77 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// verdictScript prints a Pass verdict if the request piped to it contains
+// want and a Fail verdict otherwise.
+func verdictScript(want string) []string {
+	return []string{"-c", `if grep -q '` + want + `'; then
+    echo '{"Status": "Pass", "Value": "found"}'
+else
+    echo '{"Status": "Fail", "Message": "pattern not found"}'
+fi`}
+}
+
+var externalTests = []struct {
+	args []string
+	err  string
+}{
+	{verdictScript(`"Kind":"check"`), ""},
+	{verdictScript(`"Body":"Hello World"`), ""},
+	{verdictScript(`"Config":{"Price":12}`), ""},
+	{verdictScript(`"StatusCode":404`), "pattern not found"},
+	{[]string{"-c", `echo '{"Status": "Error", "Message": "no db"}'`}, "cannot do check: no db"},
+	{[]string{"-c", `echo 'PASS'`}, "malformed verdict"},
+	{[]string{"-c", `echo oops >&2; exit 3`}, "exit status 3 (oops)"},
+	{[]string{"-c", `sleep 5`}, "timed out"},
+}
+
+func TestExternal(t *testing.T) {
+	test := &Test{
+		Name: "External",
+		Response: Response{
+			Response: &http.Response{StatusCode: 200},
+			BodyStr:  "Hello World",
+		},
+	}
+	for i, tc := range externalTests {
+		check := External{
+			Command: "sh",
+			Args:    tc.args,
+			Config:  map[string]interface{}{"Price": 12},
+			Timeout: 200 * time.Millisecond,
+		}
+		err := check.Execute(test)
+		switch {
+		case tc.err == "" && err != nil:
+			t.Errorf("%d: unexpected error %s", i, err)
+		case tc.err != "" && err == nil:
+			t.Errorf("%d: missing error, want %s", i, tc.err)
+		case tc.err != "" && !strings.Contains(err.Error(), tc.err):
+			t.Errorf("%d: got error %q, want %q", i, err, tc.err)
+		}
+	}
+}
+
+func TestExternalExtractor(t *testing.T) {
+	test := &Test{Response: Response{BodyStr: "Hello World"}}
+	ex := ExternalExtractor{Command: "sh", Args: verdictScript(`"Kind":"extractor"`)}
+	got, err := ex.Extract(test)
+	if err != nil || got != "found" {
+		t.Errorf("Got %q, %v", got, err)
+	}
+
+	ex.Args = verdictScript("Goodbye")
+	if _, err := ex.Extract(test); err == nil || err.Error() != "pattern not found" {
+		t.Errorf("Got error %v", err)
+	}
+}
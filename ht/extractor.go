@@ -10,13 +10,12 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
-	"regexp"
 	"strings"
 	"time"
 
-	"github.com/andybalholm/cascadia"
 	"github.com/robertkrimen/otto"
 	"github.com/vdobler/ht/populate"
+	"github.com/vdobler/ht/scope"
 	"golang.org/x/net/html"
 )
 
@@ -115,11 +114,16 @@ func (em ExtractorMap) MarshalJSON() ([]byte, error) {
 
 // Populate implements populate.Populator.Populate.
 func (em *ExtractorMap) Populate(src interface{}) error {
+	return em.PopulateWith(src, populate.Options{})
+}
+
+// PopulateWith implements populate.OptionsPopulator.PopulateWith.
+func (em *ExtractorMap) PopulateWith(src interface{}, opts populate.Options) error {
 	types := make(map[string]struct {
 		Extractor string
 	})
 
-	err := populate.Lax(&types, src)
+	err := populate.Options{Lax: true, FoldCase: opts.FoldCase}.Populate(&types, src)
 	if err != nil {
 		return err
 	}
@@ -135,7 +139,11 @@ func (em *ExtractorMap) Populate(src interface{}) error {
 		if !ok {
 			return fmt.Errorf("ht: cannot populate extractor for %q from %T", name, srcMap[name])
 		}
-		delete(r, "Extractor")
+		for key := range r {
+			if key == "Extractor" || (opts.FoldCase && strings.EqualFold(key, "Extractor")) {
+				delete(r, key)
+			}
+		}
 		raw[name] = r
 	}
 
@@ -145,19 +153,19 @@ func (em *ExtractorMap) Populate(src interface{}) error {
 		if exName == "" {
 			// This happens if Extractor was misspelled, e.g. in
 			//    {Extratcor: "CookieExtractor", Name: "sessionid"}
-			return fmt.Errorf("missing Extractor name")
+			return populate.Within(fmt.Errorf("missing Extractor name"), name)
 		}
 		typ, ok := ExtractorRegistry[exName]
 		if !ok {
-			return noSuchExtractorError(exName)
+			return populate.Within(noSuchExtractorError(exName), name, "Extractor")
 		}
 		if typ.Kind() == reflect.Ptr {
 			typ = typ.Elem()
 		}
 		extractor := reflect.New(typ)
-		err = populate.Strict(extractor.Interface(), raw[name])
+		err = opts.Populate(extractor.Interface(), raw[name])
 		if err != nil {
-			return fmt.Errorf("cannot build extractor for %q: %s", name, err)
+			return populate.Within(err, name)
 		}
 		exes[name] = extractor.Interface().(Extractor)
 
@@ -166,6 +174,20 @@ func (em *ExtractorMap) Populate(src interface{}) error {
 	return nil
 }
 
+// PopulateSchema implements populate.Schemer: An object mapping variable
+// names to extractors whose field "Extractor" selects the type.
+func (em *ExtractorMap) PopulateSchema(g *populate.SchemaGenerator) map[string]interface{} {
+	names := make([]string, 0, len(ExtractorRegistry))
+	for name := range ExtractorRegistry {
+		names = append(names, name)
+	}
+	return map[string]interface{}{
+		"type": "object",
+		"additionalProperties": registrySchema(g, "Extractor", names,
+			ExtractorRegistry),
+	}
+}
+
 func noSuchExtractorError(name string) error {
 	exNames := make([]string, 0, len(ExtractorRegistry))
 	for en := range ExtractorRegistry {
@@ -208,7 +230,7 @@ type HTMLExtractor struct {
 // Extract implements Extractor's Extract method.
 func (e HTMLExtractor) Extract(t *Test) (string, error) {
 	if e.Selector != "" {
-		sel, err := cascadia.Compile(e.Selector)
+		sel, err := compileSelector(e.Selector)
 		if err != nil {
 			return "", err
 		}
@@ -257,7 +279,7 @@ func (e BodyExtractor) Extract(t *Test) (string, error) {
 		return "", ErrBadBody
 	}
 
-	re, err := regexp.Compile(e.Regexp)
+	re, err := compileRegexp(e.Regexp)
 	if err != nil {
 		return "", err
 	}
@@ -508,7 +530,8 @@ func (e SetVariable) Extract(test *Test) (string, error) {
 // To round the extracted timestamp e.g. to multiple of hours use
 // a format like "2006-01-02 15:00:00" with fixed minutes and seconds.
 //
-// The test and the response are ignored.
+// The current time is taken from the test variable FROZEN_NOW if set
+// (see scope.FrozenNow), the response is ignored.
 type SetTimestamp struct {
 	// DeltaT is the difference to now.
 	DeltaT time.Duration `json:",omitempty"`
@@ -523,8 +546,14 @@ type SetTimestamp struct {
 }
 
 // Extract implements Extractor's Extract method.
-func (t SetTimestamp) Extract(*Test) (string, error) {
+func (t SetTimestamp) Extract(test *Test) (string, error) {
 	now := time.Now()
+	if test != nil && test.Variables[scope.FrozenNow] != "" {
+		var err error
+		if now, err = scope.ParseTime(test.Variables[scope.FrozenNow]); err != nil {
+			return "", err
+		}
+	}
 
 	now = now.AddDate(t.DeltaYear, t.DeltaMonth, t.DeltaDay)
 	now = now.Add(t.DeltaT)
@@ -467,6 +467,11 @@ func TestSetTimestamp(t *testing.T) {
 		})
 	}
 
+	frozen := &Test{Variables: map[string]string{"FROZEN_NOW": "2017-03-24T15:30:00Z"}}
+	got, err := SetTimestamp{DeltaDay: 1, Format: "2006-01-02 15:04"}.Extract(frozen)
+	if err != nil || got != "2017-03-25 15:30" {
+		t.Errorf("Frozen now: got %q, %v", got, err)
+	}
 }
 
 func TestHeaderExtractor(t *testing.T) {
@@ -516,3 +521,32 @@ func TestHeaderExtractor(t *testing.T) {
 
 	})
 }
+
+func TestFoldCaseChecksAndExtractors(t *testing.T) {
+	j := []byte(`{
+    request: { url: "http://example.org" }
+    checks: [ {check: "StatusCode", expect: 404} ]
+    dataExtraction: {
+        Foo: { extractor: "BodyExtractor", regexp: "[0-9]+" }
+    }
+}`)
+	var raw interface{}
+	if err := hjson.Unmarshal(j, &raw); err != nil {
+		t.Fatalf("Unexpected error: %#v", err)
+	}
+
+	test := Test{}
+	if err := populate.Strict(&test, raw); err == nil {
+		t.Errorf("Missing error in case sensitive mode")
+	}
+	err := populate.Options{FoldCase: true}.Populate(&test, raw)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if sc, ok := test.Checks[0].(*StatusCode); !ok || sc.Expect != 404 {
+		t.Errorf("Got check %#v", test.Checks[0])
+	}
+	if ex, ok := test.DataExtraction["Foo"].(*BodyExtractor); !ok || ex.Regexp != "[0-9]+" {
+		t.Errorf("Got extractor %#v", test.DataExtraction["Foo"])
+	}
+}
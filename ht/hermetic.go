@@ -0,0 +1,111 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// hermetic.go provides restriction of the hosts a test may talk to.
+
+package ht
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/vdobler/ht/errorlist"
+)
+
+// ErrHostNotAllowed is returned if a HTTP request would be sent to a
+// host which is not in the list of allowed hosts of a Test.
+type ErrHostNotAllowed struct {
+	Host string
+}
+
+func (e ErrHostNotAllowed) Error() string {
+	return fmt.Sprintf("hermetic mode: host %q is not in the list of allowed hosts", e.Host)
+}
+
+// Detail implements errorlist.Detailer.
+func (e ErrHostNotAllowed) Detail() errorlist.Error {
+	return errorlist.Error{
+		Code:     "request.host-not-allowed",
+		Category: errorlist.Validation,
+		Field:    "Request.URL",
+		Err:      e,
+	}
+}
+
+// hostAllowed checks whether the host of u matches at least one of the
+// entries in allowed. An entry is either
+//     - a hostname like "localhost" or "www.example.org"
+//     - a wildcard hostname like "*.example.org" which matches all
+//       subdomains (but not example.org itself)
+//     - an IP address like "127.0.0.1" or "::1"
+//     - a CIDR range like "10.0.0.0/8"
+// Hostnames are resolved if CIDR entries are present and the hostname is
+// not matched by name: All resolved addresses must lie in an allowed range.
+// A nil allowed permits any host.
+func hostAllowed(allowed []string, u *url.URL) error {
+	if allowed == nil {
+		return nil
+	}
+
+	host := strings.ToLower(u.Hostname())
+	var nets []*net.IPNet
+	for _, entry := range allowed {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if strings.Contains(entry, "/") {
+			_, ipnet, err := net.ParseCIDR(entry)
+			if err != nil {
+				return fmt.Errorf("hermetic mode: malformed allowed host %q: %s",
+					entry, err)
+			}
+			nets = append(nets, ipnet)
+			continue
+		}
+		if strings.HasPrefix(entry, "*.") {
+			if strings.HasSuffix(host, entry[1:]) {
+				return nil
+			}
+			continue
+		}
+		if ip := net.ParseIP(entry); ip != nil {
+			if hip := net.ParseIP(host); hip != nil && hip.Equal(ip) {
+				return nil
+			}
+			continue
+		}
+		if host == entry {
+			return nil
+		}
+	}
+
+	if len(nets) == 0 {
+		return ErrHostNotAllowed{Host: host}
+	}
+
+	ips := []net.IP{}
+	if ip := net.ParseIP(host); ip != nil {
+		ips = append(ips, ip)
+	} else {
+		addrs, err := net.LookupIP(host)
+		if err != nil || len(addrs) == 0 {
+			return ErrHostNotAllowed{Host: host}
+		}
+		ips = addrs
+	}
+	for _, ip := range ips {
+		inRange := false
+		for _, ipnet := range nets {
+			if ipnet.Contains(ip) {
+				inRange = true
+				break
+			}
+		}
+		if !inRange {
+			return ErrHostNotAllowed{Host: host}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/vdobler/ht/errorlist"
+)
+
+var hostAllowedTests = []struct {
+	allowed []string
+	url     string
+	ok      bool
+}{
+	{nil, "http://www.example.org/", true},
+	{[]string{}, "http://www.example.org/", false},
+	{[]string{"localhost"}, "http://localhost:8080/foo", true},
+	{[]string{"localhost"}, "http://LocalHost/foo", true},
+	{[]string{"localhost"}, "http://www.example.org/", false},
+	{[]string{"*.example.org"}, "https://www.example.org/", true},
+	{[]string{"*.example.org"}, "https://a.b.example.org/", true},
+	{[]string{"*.example.org"}, "https://example.org/", false},
+	{[]string{"*.example.org"}, "https://badexample.org/", false},
+	{[]string{"127.0.0.1"}, "http://127.0.0.1:9090/", true},
+	{[]string{"::1"}, "http://[::1]:9090/", true},
+	{[]string{"10.0.0.0/8"}, "http://10.20.30.40/", true},
+	{[]string{"10.0.0.0/8"}, "http://11.20.30.40/", false},
+	{[]string{"10.0.0.0/8", "localhost"}, "http://localhost/", true},
+	{[]string{"10.0.0.0/33"}, "http://10.20.30.40/", false},
+}
+
+func TestHostAllowed(t *testing.T) {
+	for i, tc := range hostAllowedTests {
+		u, err := url.Parse(tc.url)
+		if err != nil {
+			t.Fatalf("%d. Unexpected error: %s", i, err)
+		}
+		err = hostAllowed(tc.allowed, u)
+		if tc.ok && err != nil {
+			t.Errorf("%d. %v %s: unexpected error %s", i, tc.allowed, tc.url, err)
+		} else if !tc.ok && err == nil {
+			t.Errorf("%d. %v %s: missing error", i, tc.allowed, tc.url)
+		}
+	}
+}
+
+func TestHermeticTest(t *testing.T) {
+	test := &Test{
+		Name: "Hermetic",
+		Request: Request{
+			URL: "http://www.example.org/",
+		},
+		AllowedHosts: []string{"localhost"},
+	}
+	test.Run()
+	if test.Result.Status != Error {
+		t.Fatalf("Got status %s, want Error", test.Result.Status)
+	}
+	if _, ok := test.Result.Error.(ErrHostNotAllowed); !ok {
+		t.Errorf("Got error %T %v", test.Result.Error, test.Result.Error)
+	}
+	if d := errorlist.Details(test.Result.Error); len(d) != 1 ||
+		d[0].Code != "request.host-not-allowed" || d[0].Category != errorlist.Validation {
+		t.Errorf("Got details %+v", d)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// hook.go contains the hooks called while a test is run.
+
+package ht
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Hooks are called at certain points while a Test is run, e.g. to sign
+// the request, to decrypt the response or to collect diagnostic data
+// once the test failed.
+//
+// Each hook is either a Go function (when ht is used as a library) or a
+// script (in test files) or both in which case the function is called
+// first. A failing PreRequest or PostResponse hook results in a test
+// status of Error.
+type Hooks struct {
+	// PreRequest is called before each request is sent. The request
+	// is available in Test.Request.Request.
+	PreRequest *HookScript `json:",omitempty"`
+
+	// PostResponse is called after each response has been received
+	// and before the checks are executed.
+	PostResponse *HookScript `json:",omitempty"`
+
+	// OnFailure is called once after the last try if the test's status
+	// is Fail or Error.
+	OnFailure *HookScript `json:",omitempty"`
+
+	// PreRequestFunc, PostResponseFunc and OnFailureFunc are the Go
+	// functions of the hooks.
+	PreRequestFunc   HookFunc `json:"-"`
+	PostResponseFunc HookFunc `json:"-"`
+	OnFailureFunc    HookFunc `json:"-"`
+}
+
+// HookFunc is a hook implemented in Go. It may modify t.
+type HookFunc func(t *Test) error
+
+// HookScript is a hook implemented by an external program.
+//
+// The program Command is started with Args. It receives an ExternalRequest
+// whose Kind is the name of the hook ("PreRequest", "PostResponse" or
+// "OnFailure") as JSON on its standard input and may print a HookOutput
+// as JSON to its standard output:
+//
+//   - The Header fields of the output are set in the request for a
+//     PreRequest hook and in the response for a PostResponse hook.
+//   - The Body of the output replaces the response body for a PostResponse
+//     hook.
+//   - The Diagnostics of the output are logged and stored in
+//     Result.Diagnostics for an OnFailure hook.
+//
+// A nonzero exit status or output which is not a HookOutput is an error.
+type HookScript struct {
+	// Command is the program to execute and Args its arguments.
+	Command string
+	Args    []string `json:",omitempty"`
+
+	// Timeout limits the runtime of the program. Zero means
+	// DefaultExternalTimeout.
+	Timeout time.Duration `json:",omitempty"`
+}
+
+// HookOutput is the JSON document a HookScript may print.
+type HookOutput struct {
+	Header      http.Header `json:",omitempty"`
+	Body        *string     `json:",omitempty"`
+	Diagnostics string      `json:",omitempty"`
+}
+
+// The names of the hooks.
+const (
+	preRequestHook   = "PreRequest"
+	postResponseHook = "PostResponse"
+	onFailureHook    = "OnFailure"
+)
+
+// runHook calls the function and the script of the named hook of t.
+func (t *Test) runHook(name string) error {
+	if t.Hooks == nil {
+		return nil
+	}
+	var fn HookFunc
+	var script *HookScript
+	switch name {
+	case preRequestHook:
+		fn, script = t.Hooks.PreRequestFunc, t.Hooks.PreRequest
+	case postResponseHook:
+		fn, script = t.Hooks.PostResponseFunc, t.Hooks.PostResponse
+	case onFailureHook:
+		fn, script = t.Hooks.OnFailureFunc, t.Hooks.OnFailure
+	}
+
+	if fn != nil {
+		t.debugf("Calling %s hook", name)
+		if err := fn(t); err != nil {
+			return fmt.Errorf("%s hook: %s", name, err)
+		}
+	}
+	if script == nil || script.Command == "" {
+		return nil
+	}
+
+	data, err := runExternalCommand(t, name, script.Command, script.Args, nil, script.Timeout)
+	if err != nil {
+		return fmt.Errorf("%s hook: %s", name, err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	output := HookOutput{}
+	if err := json.Unmarshal(data, &output); err != nil {
+		return fmt.Errorf("%s hook: malformed output: %s", name, err)
+	}
+
+	switch name {
+	case preRequestHook:
+		if t.Request.Request.Header == nil {
+			t.Request.Request.Header = make(http.Header)
+		}
+		addHeader(t.Request.Request.Header, output.Header)
+	case postResponseHook:
+		if t.Response.Response != nil {
+			if t.Response.Response.Header == nil {
+				t.Response.Response.Header = make(http.Header)
+			}
+			addHeader(t.Response.Response.Header, output.Header)
+		}
+		if output.Body != nil {
+			t.Response.BodyStr = *output.Body
+			t.Response.BodySize = int64(len(t.Response.BodyStr))
+		}
+	case onFailureHook:
+		if output.Diagnostics != "" {
+			t.Result.Diagnostics += output.Diagnostics
+			t.errorf("Diagnostics: %s", output.Diagnostics)
+		}
+	}
+	return nil
+}
+
+// addHeader sets the header fields of add in h.
+func addHeader(h, add http.Header) {
+	for name, values := range add {
+		h.Del(name)
+		for _, v := range values {
+			h.Add(name, v)
+		}
+	}
+}
+
+// mergeHooks merges the hooks h into the hooks of m.
+func mergeHooks(m *Test, h *Hooks) error {
+	if h == nil {
+		return nil
+	}
+	if m.Hooks == nil {
+		m.Hooks = &Hooks{}
+	}
+	mh := m.Hooks
+	for _, hs := range []struct {
+		name     string
+		dst, src **HookScript
+	}{
+		{preRequestHook, &mh.PreRequest, &h.PreRequest},
+		{postResponseHook, &mh.PostResponse, &h.PostResponse},
+		{onFailureHook, &mh.OnFailure, &h.OnFailure},
+	} {
+		if *hs.src == nil {
+			continue
+		}
+		if *hs.dst != nil {
+			return fmt.Errorf("wont overwrite %s hook", hs.name)
+		}
+		*hs.dst = *hs.src
+	}
+	for _, hf := range []struct {
+		name     string
+		dst, src *HookFunc
+	}{
+		{preRequestHook, &mh.PreRequestFunc, &h.PreRequestFunc},
+		{postResponseHook, &mh.PostResponseFunc, &h.PostResponseFunc},
+		{onFailureHook, &mh.OnFailureFunc, &h.OnFailureFunc},
+	} {
+		if *hf.src == nil {
+			continue
+		}
+		if *hf.dst != nil {
+			return fmt.Errorf("wont overwrite %s hook function", hf.name)
+		}
+		*hf.dst = *hf.src
+	}
+	return nil
+}
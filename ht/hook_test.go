@@ -0,0 +1,156 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vdobler/ht/errorlist"
+)
+
+func TestHookFuncs(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Signature") != "signed" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("olleH"))
+	}))
+	defer ts.Close()
+
+	calls := []string{}
+	test := Test{
+		Request: Request{URL: ts.URL + "/"},
+		Checks: CheckList{
+			StatusCode{Expect: 200},
+			Body{Equals: "Hello"},
+		},
+		Hooks: &Hooks{
+			PreRequestFunc: func(t *Test) error {
+				calls = append(calls, "pre")
+				t.Request.Request.Header.Set("X-Signature", "signed")
+				return nil
+			},
+			PostResponseFunc: func(t *Test) error {
+				calls = append(calls, "post")
+				r := []rune(t.Response.BodyStr)
+				for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+					r[i], r[j] = r[j], r[i]
+				}
+				t.Response.BodyStr = string(r)
+				return nil
+			},
+			OnFailureFunc: func(t *Test) error {
+				calls = append(calls, "failure")
+				return nil
+			},
+		},
+	}
+	test.Run()
+	if test.Result.Status != Pass {
+		t.Errorf("Got status %s: %v", test.Result.Status, test.Result.Error)
+	}
+	if got := strings.Join(calls, " "); got != "pre post" {
+		t.Errorf("Got calls %q", got)
+	}
+
+	// A failing PostResponse hook results in an Error and triggers
+	// the OnFailure hook once after all tries.
+	calls = calls[:0]
+	test.Execution.Tries = 2
+	test.Hooks.PostResponseFunc = func(t *Test) error {
+		calls = append(calls, "post")
+		return errors.New("cannot decrypt")
+	}
+	test.Run()
+	if test.Result.Status != Error {
+		t.Errorf("Got status %s", test.Result.Status)
+	}
+	details := errorlist.Details(test.Result.Error)
+	if len(details) != 1 || details[0].Code != "hook.postresponse" ||
+		details[0].Category != errorlist.Hook ||
+		!strings.Contains(details[0].Err.Error(), "cannot decrypt") {
+		t.Errorf("Got details %v", details)
+	}
+	if got := strings.Join(calls, " "); got != "pre post pre post failure" {
+		t.Errorf("Got calls %q", got)
+	}
+
+	// A failing PreRequest hook is a hook error, not a network error.
+	test.Hooks.PreRequestFunc = func(t *Test) error {
+		return errors.New("cannot sign")
+	}
+	test.Run()
+	details = errorlist.Details(test.Result.Error)
+	if test.Result.Status != Error || len(details) != 1 ||
+		details[0].Code != "hook.prerequest" || details[0].Category != errorlist.Hook {
+		t.Errorf("Got status %s: %v", test.Result.Status, details)
+	}
+}
+
+func TestHookScripts(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Signature") != "signed" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+		w.Write([]byte("secret"))
+	}))
+	defer ts.Close()
+
+	sh := func(script string) *HookScript {
+		return &HookScript{Command: "sh", Args: []string{"-c", script}}
+	}
+	test := Test{
+		Request: Request{URL: ts.URL + "/"},
+		Checks: CheckList{
+			StatusCode{Expect: 200},
+			Body{Equals: "plain"},
+		},
+		Hooks: &Hooks{
+			PreRequest:   sh(`grep -q '"Kind":"PreRequest"' && echo '{"Header": {"X-Signature": ["signed"]}}'`),
+			PostResponse: sh(`grep -q '"Body":"secret"' && echo '{"Body": "plain"}'`),
+			OnFailure:    sh(`echo '{"Diagnostics": "server log"}'`),
+		},
+	}
+	test.Run()
+	if test.Result.Status != Pass {
+		t.Errorf("Got status %s: %v", test.Result.Status, test.Result.Error)
+	}
+	if test.Result.Diagnostics != "" {
+		t.Errorf("Got diagnostics %q", test.Result.Diagnostics)
+	}
+
+	test.Hooks.PreRequest = nil
+	test.Run()
+	if test.Result.Status != Fail {
+		t.Errorf("Got status %s: %v", test.Result.Status, test.Result.Error)
+	}
+	if test.Result.Diagnostics != "server log" {
+		t.Errorf("Got diagnostics %q", test.Result.Diagnostics)
+	}
+}
+
+func TestMergeHooks(t *testing.T) {
+	pre := &HookScript{Command: "sign"}
+	post := &HookScript{Command: "decrypt"}
+	a := &Test{Hooks: &Hooks{PreRequest: pre}}
+	b := &Test{Hooks: &Hooks{PostResponse: post}}
+	m, err := Merge(a, &Test{}, b)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	if m.Hooks == nil || m.Hooks.PreRequest != pre || m.Hooks.PostResponse != post ||
+		m.Hooks.OnFailure != nil {
+		t.Errorf("Got hooks %+v", m.Hooks)
+	}
+
+	if _, err := Merge(a, a); err == nil || err.Error() != "wont overwrite PreRequest hook" {
+		t.Errorf("Got error %v", err)
+	}
+}
@@ -7,6 +7,7 @@ package ht
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
@@ -17,15 +18,20 @@ import (
 	"mime/multipart"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/textproto"
 	"net/url"
 	"path"
 	"strings"
+	"sync"
 	"time"
 	"unicode/utf8"
 
 	"github.com/vdobler/ht/cookiejar"
 	"github.com/vdobler/ht/errorlist"
+	"github.com/vdobler/ht/logging"
+	"github.com/vdobler/ht/scope"
+	"github.com/vdobler/ht/tracing"
 )
 
 var (
@@ -127,6 +133,21 @@ type Request struct {
 	// Timeout of this request. If zero use DefaultClientTimeout.
 	Timeout time.Duration `json:",omitempty"`
 
+	// MaxBodySize limits the number of bytes of the response body
+	// captured in Response.BodyStr. Larger bodies are truncated and
+	// streamed through the checks which support it (see BodyStreamer)
+	// instead of being kept in memory. Zero means no limit.
+	MaxBodySize int64 `json:",omitempty"`
+
+	// Signature describes how to sign the request with a HMAC.
+	Signature *Signature `json:",omitempty"`
+
+	// StatusMapping maps the outcomes of bash://, sql:// and file://
+	// pseudo-requests to the status code of the response, e.g.
+	// {"exit:3": 404, "sqlstate:23": 409}. See the package documentation
+	// for the outcomes and the default status codes.
+	StatusMapping map[string]int `json:",omitempty"`
+
 	Request    *http.Request `json:"-"` // the 'real' request
 	SentBody   string        `json:"-"` // the 'real' body
 	SentParams url.Values    `json:"-"` // the 'real' parameters
@@ -145,8 +166,31 @@ type Response struct {
 	BodyStr string `json:",omitempty"`
 	BodyErr error  `json:",omitempty"`
 
+	// BodySize is the size of the whole body. It exceeds len(BodyStr)
+	// if the body was truncated to Request.MaxBodySize in which case
+	// BodyErr is ErrBodyTruncated and BodySHA256 is the hex encoded
+	// SHA-256 hash of the whole body.
+	BodySize   int64  `json:",omitempty"`
+	BodySHA256 string `json:",omitempty"`
+
+	// Connections counts the connections used for the request and
+	// followed redirects.
+	Connections ConnStats `json:"-"`
+
 	// Redirections records the URLs of automatic GET requests due to redirects.
 	Redirections []string `json:",omitempty"`
+
+	// Charset is the original charset of a body which was transcoded to
+	// UTF-8. Bodies are transcoded if the response declares a charset
+	// other than UTF-8 or by a "charset" Transformation. Charset is empty
+	// if the body was not transcoded.
+	Charset string `json:",omitempty"`
+
+	// Resources are the resources of a HTML page loaded due to
+	// Test.LoadResources.
+	Resources []Resource `json:",omitempty"`
+
+	rawBody []byte // the body before transcoding to UTF-8
 }
 
 // Body returns a reader of the response body.
@@ -154,6 +198,22 @@ func (resp *Response) Body() io.Reader {
 	return strings.NewReader(resp.BodyStr)
 }
 
+// Trimmed returns a copy of resp whose body is cut to at most max bytes.
+// BodySize still reports the size of the whole body. The copy does not
+// reference the original body so it can be kept to report on a test
+// without holding on to large bodies.
+func (resp *Response) Trimmed(max int) Response {
+	trimmed := *resp
+	trimmed.rawBody = nil
+	if len(resp.BodyStr) > max {
+		for max > 0 && !utf8.RuneStart(resp.BodyStr[max]) {
+			max--
+		}
+		trimmed.BodyStr = string([]byte(resp.BodyStr[:max]))
+	}
+	return trimmed
+}
+
 // Cookie is a HTTP cookie.
 type Cookie struct {
 	Name  string
@@ -178,6 +238,22 @@ type Execution struct {
 
 	// Verbosity level in logging.
 	Verbosity int `json:",omitempty"`
+
+	// CheckConcurrency is the number of checks executed concurrently.
+	// Zero and one execute the checks sequentially. Concurrent execution
+	// pays off for heavy checks like Image, Links or ValidHTML.
+	CheckConcurrency int `json:",omitempty"`
+
+	// FreshCookies, FreshConnection and FreshVariables isolate the tries
+	// of a test (e.g. when polling an endpoint) from each other:
+	// FreshCookies removes the cookies stored in the Jar during a failed
+	// try before retrying, FreshConnection closes the connection after
+	// each try so that a retry is sent over a newly established connection
+	// and FreshVariables generates new values for variables like RANDOM or
+	// NOW for each retry (see Test.Renew).
+	FreshCookies    bool `json:",omitempty"`
+	FreshConnection bool `json:",omitempty"`
+	FreshVariables  bool `json:",omitempty"`
 }
 
 // ----------------------------------------------------------------------------
@@ -192,6 +268,10 @@ type Test struct {
 	// Description what this test's intentions are.
 	Description string `json:",omitempty"`
 
+	// Tags are free-form labels of the test like "smoke" or "checkout".
+	// They are reported e.g. as labels in Allure results.
+	Tags []string `json:",omitempty"`
+
 	// Request is the HTTP request.
 	Request Request
 
@@ -207,9 +287,44 @@ type Test struct {
 	// Execution controls the test execution.
 	Execution Execution `json:",omitempty"`
 
+	// Transform the response body before the checks and extractors
+	// are applied, e.g. to decrypt or decompress it.
+	Transform []Transformation `json:",omitempty"`
+
+	// Hooks are called before each request, after each response and
+	// on failure of the test.
+	Hooks *Hooks `json:",omitempty"`
+
+	// LoadResources makes the test load the resources referenced by a
+	// HTML page like a browser.
+	LoadResources *ResourceLoading `json:",omitempty"`
+
+	// WireLog dumps the requests and responses of the test to files.
+	WireLog *WireLog `json:",omitempty"`
+
 	// Jar is the cookie jar to use
 	Jar *cookiejar.Jar `json:"-"`
 
+	// ClientPool provides the HTTP connections of this test. Nil uses
+	// the global Transport.
+	ClientPool *ClientPool `json:"-"`
+
+	// AllowedHosts restricts the hosts HTTP requests (including followed
+	// redirects) may be sent to. A request to any other host results in
+	// an Error without being sent. Entries may be hostnames, wildcards
+	// like "*.example.org", IP addresses or CIDR ranges like "10.0.0.0/8".
+	// A nil AllowedHosts allows any host.
+	AllowedHosts []string `json:"-"`
+
+	// Journal records the requests received by the mocks provided for
+	// this test. It is accessible to Checks and Extractors.
+	Journal *Journal `json:"-"`
+
+	// Span is the tracing span of this test. If set, each request sent
+	// and each check executed is traced in a child span and the request
+	// carries the W3C traceparent header of its span.
+	Span *tracing.Span `json:"-"`
+
 	// Variables contains name/value-pairs used for variable substitution
 	// in files read in, e.g. for Request.Body = "@vfile:/path/to/file".
 	Variables map[string]string `json:",omitempty"`
@@ -223,8 +338,25 @@ type Test struct {
 		Printf(format string, a ...interface{})
 	} `json:"-"`
 
+	// Logger receives the log messages of the test as structured records
+	// with the name of the test and its sequence number as fields.
+	// If Logger is nil the messages are printed to Log.
+	Logger logging.Logger `json:"-"`
+
+	// Renew produces the test anew with newly generated values for
+	// variables like RANDOM or NOW. It is used to retry the test with
+	// Execution.FreshVariables and is set e.g. by package suite.
+	Renew func() (*Test, error) `json:"-"`
+
 	client *http.Client
 
+	// ctx is the context the test is run in, see RunContext.
+	ctx context.Context
+
+	// streams are the BodyStreams of the checks (by index) the
+	// response body was streamed through.
+	streams map[int]BodyStream
+
 	// metadata allows to attach additional data to a Test.
 	metadata map[string]interface{}
 }
@@ -237,8 +369,31 @@ type Result struct {
 	Duration     time.Duration         `json:"-"` // Duration of last execution/last try
 	FullDuration time.Duration         `json:"-"` // Full duration of all tries.
 	Tries        int                   `json:"-"` // Number of tries executed.
+	TryStarted   time.Time             `json:"-"` // Start of the last try.
+	PrevTries    []Try                 `json:"-"` // Tries before the last one.
 	CheckResults []CheckResult         `json:"-"` // The individual checks result.
 	Extractions  map[string]Extraction `json:"-"` // Result of DataExtractions
+	Diagnostics  string                `json:"-"` // Collected by the OnFailure hook.
+}
+
+// A Try records the request and the response of a try of a Test which
+// was followed by an other try.
+type Try struct {
+	Started  time.Time
+	Status   Status
+	Error    error
+	Request  Request
+	Response Response
+}
+
+// hasTag reports whether t is tagged with tag.
+func (t *Test) hasTag(tag string) bool {
+	for _, tt := range t.Tags {
+		if tt == tag {
+			return true
+		}
+	}
+	return false
 }
 
 // Disable disables t by setting the maximum number of tries to -1.
@@ -296,6 +451,9 @@ type CheckResult struct {
 	Status   Status         // Outcome of check. All status but Error
 	Duration time.Duration  // How long the check took.
 	Error    errorlist.List // For a Status of Bogus or Fail.
+
+	// PrepareDuration is how long preparing the check took.
+	PrepareDuration time.Duration
 }
 
 // Extraction captures the result of a variable extraction.
@@ -370,6 +528,23 @@ outer:
 		return err
 	}
 
+	if r.Signature != nil {
+		if m.Signature != nil {
+			return errors.New("Won't overwrite Signature")
+		}
+		m.Signature = r.Signature
+	}
+
+	for outcome, code := range r.StatusMapping {
+		if old, ok := m.StatusMapping[outcome]; ok && old != code {
+			return fmt.Errorf("Won't overwrite status mapping of %s", outcome)
+		}
+		if m.StatusMapping == nil {
+			m.StatusMapping = make(map[string]int)
+		}
+		m.StatusMapping[outcome] = code
+	}
+
 	return nil
 }
 
@@ -377,6 +552,7 @@ outer:
 // following way.
 //     Name         Join all names
 //     Description  Join all descriptions
+//     Tags         Union of all tags
 //     Request
 //       Method     All nonempty must be the same
 //       URL        Only one may be nonempty
@@ -387,6 +563,8 @@ outer:
 //       Body       Only one may be nonempty
 //       FollowRdr  Last wins
 //       Chunked    Last wins
+//       Signature  Only one may be set
+//       StatusMapping  Merge, same outcomes must have same code
 //     Checks       Append all checks
 //     DataExtraction Merge, same keys must have same value
 //     TestVars     Use values from first only.
@@ -396,6 +574,12 @@ outer:
 //     Timeout      Use largets
 //     Verbosity    Use largets
 //     PreSleep     Summ of all;  same for InterSleep and PostSleep
+//     FreshCookies True if any is true; same for FreshConnection and
+//                  FreshVariables
+//     Transform    Append all transformations
+//     Hooks        Merge, each hook may be set only once
+//     LoadResources  Only one may be set
+//     WireLog      Only one may be set
 //     ClientPool   ignore
 func Merge(tests ...*Test) (*Test, error) {
 	m := Test{}
@@ -411,6 +595,13 @@ func Merge(tests ...*Test) (*Test, error) {
 		s = append(s, t.Description)
 	}
 	m.Description = strings.TrimSpace(strings.Join(s, "\n"))
+	for _, t := range tests {
+		for _, tag := range t.Tags {
+			if !m.hasTag(tag) {
+				m.Tags = append(m.Tags, tag)
+			}
+		}
+	}
 
 	m.Variables = make(map[string]string)
 	for n, v := range tests[0].Variables {
@@ -435,18 +626,43 @@ func Merge(tests ...*Test) (*Test, error) {
 		if t.Request.Timeout > m.Request.Timeout {
 			m.Request.Timeout = t.Request.Timeout
 		}
+		if t.Request.MaxBodySize > m.Request.MaxBodySize {
+			m.Request.MaxBodySize = t.Request.MaxBodySize
+		}
 		if t.Execution.Verbosity > m.Execution.Verbosity {
 			m.Execution.Verbosity = t.Execution.Verbosity
 		}
+		if t.Execution.CheckConcurrency > m.Execution.CheckConcurrency {
+			m.Execution.CheckConcurrency = t.Execution.CheckConcurrency
+		}
 		m.Execution.PreSleep += t.Execution.PreSleep
 		m.Execution.InterSleep += t.Execution.InterSleep
 		m.Execution.PostSleep += t.Execution.PostSleep
+		m.Execution.FreshCookies = m.Execution.FreshCookies || t.Execution.FreshCookies
+		m.Execution.FreshConnection = m.Execution.FreshConnection || t.Execution.FreshConnection
+		m.Execution.FreshVariables = m.Execution.FreshVariables || t.Execution.FreshVariables
 		for name, value := range t.DataExtraction {
 			if old, ok := m.DataExtraction[name]; ok && old != value {
 				return &m, fmt.Errorf("wont overwrite extractor for %s", name)
 			}
 			m.DataExtraction[name] = value
 		}
+		m.Transform = append(m.Transform, t.Transform...)
+		if err := mergeHooks(&m, t.Hooks); err != nil {
+			return &m, err
+		}
+		if t.LoadResources != nil {
+			if m.LoadResources != nil {
+				return &m, errors.New("wont overwrite LoadResources")
+			}
+			m.LoadResources = t.LoadResources
+		}
+		if t.WireLog != nil {
+			if m.WireLog != nil {
+				return &m, errors.New("wont overwrite WireLog")
+			}
+			m.WireLog = t.WireLog
+		}
 	}
 
 	return &m, nil
@@ -475,6 +691,7 @@ func (t *Test) AsJSON() ([]byte, error) {
 	t.Jar = nil
 	t.client = nil
 	t.Log = nil
+	t.Logger = nil
 	if t.Request.Request != nil {
 		t.Request.Request.Body = nil
 		t.Request.Request.GetBody = nil
@@ -505,7 +722,17 @@ func (t *Test) AsJSON() ([]byte, error) {
 // request, problems reading the body or any failing checks do not trigger a
 // non-nil return value.
 func (t *Test) Run() error {
+	return t.RunContext(context.Background())
+}
+
+// RunContext works like Run but aborts the test once ctx is done: In-flight
+// HTTP requests, bash scripts and SQL queries are canceled, pending sleeps
+// are cut short, no further tries are made and the test's status is Error.
+func (t *Test) RunContext(ctx context.Context) error {
+	t.ctx = ctx
 	t.Result.Started = time.Now()
+	t.Result.Diagnostics = ""
+	t.Result.PrevTries = nil
 	defer func() { t.Result.FullDuration = time.Since(t.Result.Started) }()
 
 	t.infof("Running")
@@ -526,37 +753,67 @@ func (t *Test) Run() error {
 	}
 	err = t.prepareRequest()
 	if err != nil {
+		err = errorlist.New(errorlist.Bogus, "request.invalid", "Request", err)
 		t.Result.Status, t.Result.Error = Bogus, err
 		return err
 	}
 
 	if t.Execution.PreSleep > 0 {
 		t.debugf("PreSleep %s", t.Execution.PreSleep)
-		time.Sleep(t.Execution.PreSleep)
+		t.sleep(t.Execution.PreSleep)
 	}
 
 	// Try until first success.
+	var cookies []cookiejar.Entry
+	if t.Execution.FreshCookies && t.Jar != nil {
+		cookies = t.Jar.AllEntries()
+	}
 	start := time.Now()
 	try := 1
 	for ; try <= t.Execution.Tries; try++ {
-		t.Result.Tries = try
 		if try > 1 {
 			t.infof("Retry %d", try)
 			if t.Execution.Wait > 0 {
 				t.debugf("Waiting %s", t.Execution.Wait)
-				time.Sleep(t.Execution.Wait)
+				t.sleep(t.Execution.Wait)
 			}
 		}
+		if err := t.context().Err(); err != nil {
+			t.Result.Status = Error
+			t.Result.Error = errorlist.New(errorlist.Network, "request.canceled",
+				"Request", fmt.Errorf("ht: test canceled: %s", err))
+			break
+		}
+		if try > 1 {
+			t.Result.PrevTries = append(t.Result.PrevTries, t.lastTry())
+			if err := t.freshTry(cookies); err != nil {
+				t.Result.Status, t.Result.Error = Bogus, err
+				break
+			}
+		}
+		t.Result.Tries = try
 		t.resetRequest()
+		if t.Execution.FreshConnection {
+			// Do not keep the connection of this try for the next
+			// one. The idle connections of the shared Transport are
+			// left alone as they may be in use by other tests.
+			t.Request.Request.Close = true
+		}
 		// Clear status and error; is updated in executeChecks.
 		t.Result.Status, t.Result.Error = NotRun, nil
 		t.Response = Response{}
+		t.Result.TryStarted = time.Now()
 		t.execute()
 		if t.Result.Status == Pass {
 			break
 		}
 	}
 	t.Result.Duration = time.Since(start)
+	if t.Result.Status == Fail || t.Result.Status == Error {
+		if err := t.runHook(onFailureHook); err != nil {
+			t.errorf("%s", err)
+		}
+	}
 	if t.Execution.Tries > 1 {
 		if t.Result.Status == Pass {
 			t.debugf("Trying succeeded after %d tries", t.Result.Tries)
@@ -570,48 +827,176 @@ func (t *Test) Run() error {
 
 	if t.Execution.PostSleep > 0 {
 		t.debugf("PostSleep %s", t.Execution.PostSleep)
-		time.Sleep(t.Execution.PostSleep)
+		t.sleep(t.Execution.PostSleep)
+	}
+
+	return nil
+}
+
+// lastTry returns the outcome of the last try of t. The http.Request is
+// cloned as it is reused in the next try.
+func (t *Test) lastTry() Try {
+	try := Try{
+		Started:  t.Result.TryStarted,
+		Status:   t.Result.Status,
+		Error:    t.Result.Error,
+		Request:  t.Request,
+		Response: t.Response,
+	}
+	if t.Request.Request != nil {
+		try.Request.Request = t.Request.Request.Clone(context.Background())
+	}
+	return try
+}
+
+// freshTry resets the state accumulated in the previous tries of t
+// according to the Fresh... fields of t.Execution. The cookies are
+// the entries of t.Jar before the first try.
+func (t *Test) freshTry(cookies []cookiejar.Entry) error {
+	if t.Execution.FreshCookies && t.Jar != nil {
+		t.debugf("Removing cookies stored during previous try")
+		t.Jar.SetEntries(cookies)
+	}
+	if !t.Execution.FreshVariables || t.Renew == nil {
+		return nil
 	}
 
+	t.debugf("Renewing variables")
+	fresh, err := t.Renew()
+	if err != nil {
+		return errorlist.New(errorlist.Bogus, "request.invalid", "Variables", err)
+	}
+	t.Request = fresh.Request
+	t.Checks = fresh.Checks
+	t.DataExtraction = fresh.DataExtraction
+	t.Transform = fresh.Transform
+	t.Variables = fresh.Variables
+	if fresh.Hooks != nil && t.Hooks != nil {
+		// Hook functions are set in code, not in the test file.
+		fresh.Hooks.PreRequestFunc = t.Hooks.PreRequestFunc
+		fresh.Hooks.PostResponseFunc = t.Hooks.PostResponseFunc
+		fresh.Hooks.OnFailureFunc = t.Hooks.OnFailureFunc
+	}
+	if fresh.Hooks != nil {
+		t.Hooks = fresh.Hooks
+	}
+	// Tries and Verbosity may have been adjusted for this run.
+	tries, verbosity := t.Execution.Tries, t.Execution.Verbosity
+	t.Execution = fresh.Execution
+	t.Execution.Tries, t.Execution.Verbosity = tries, verbosity
+	if err := t.PrepareChecks(); err != nil {
+		return err
+	}
+	if err := t.prepareRequest(); err != nil {
+		return errorlist.New(errorlist.Bogus, "request.invalid", "Request", err)
+	}
 	return nil
 }
 
 // execute does a single request and check the response.
 func (t *Test) execute() {
+	if err := t.runHook(preRequestHook); err != nil {
+		t.Result.Status = Error
+		t.Result.Error = errorlist.New(errorlist.Hook, "hook.prerequest", "Hooks.PreRequest", err)
+		return
+	}
+
+	if err := t.signRequest(); err != nil {
+		// The Signature was valid when the request was prepared but
+		// was broken since, e.g. by a PreRequest hook.
+		t.Result.Status = Bogus
+		t.Result.Error = errorlist.New(errorlist.Bogus, "request.signature", "Request.Signature", err)
+		return
+	}
+
 	var err error
 	switch t.Request.Request.URL.Scheme {
 	case "file":
 		err = t.executeFile()
 	case "http", "https":
-		err = t.executeRequest()
+		err = hostAllowed(t.AllowedHosts, t.Request.Request.URL)
+		if err == nil {
+			span := t.startRequestSpan()
+			err = t.executeRequest()
+			t.finishRequestSpan(span, err)
+		}
 	case "bash":
 		err = t.executeBash()
 	case "sql":
 		err = t.executeSQL()
 		if _, ok := err.(bogusSQLQuery); ok {
 			t.Result.Status = Bogus
-			t.Result.Error = err
+			t.Result.Error = errorlist.New(errorlist.Bogus, "request.sql", "Request", err)
 			return
 		}
 	default:
 		t.Result.Status = Bogus
-		t.Result.Error = fmt.Errorf("ht: unrecognized URL scheme %q", t.Request.Request.URL.Scheme)
+		t.Result.Error = errorlist.New(errorlist.Bogus, "request.scheme", "Request.URL",
+			fmt.Errorf("ht: unrecognized URL scheme %q", t.Request.Request.URL.Scheme))
 		return
 	}
+	if err == nil {
+		t.transcodeBody()
+		if err := t.transformBody(); err != nil {
+			t.Result.Status = Error
+			t.Result.Error = errorlist.New(errorlist.Check, "response.transform", "Transform", err)
+			return
+		}
+		err = t.runHook(postResponseHook)
+		if err != nil {
+			t.Result.Status = Error
+			t.Result.Error = errorlist.New(errorlist.Hook, "hook.postresponse", "Hooks.PostResponse", err)
+			return
+		}
+		t.loadResources()
+	}
 	if err == nil {
 		if len(t.Checks) > 0 {
 			if t.Execution.InterSleep > 0 {
 				t.debugf("InterSleep %s", t.Execution.InterSleep)
-				time.Sleep(t.Execution.InterSleep)
+				t.sleep(t.Execution.InterSleep)
 			}
+			start := time.Now()
 			t.ExecuteChecks()
+			t.traceChecks(start)
 		} else {
 			t.Result.Status = Pass
 		}
 	} else {
+		code := requestErrorCode(t.Request.Request.URL.Scheme, err)
+		if t.context().Err() != nil {
+			code = "request.canceled"
+		}
 		t.Result.Status = Error
-		t.Result.Error = err
+		t.Result.Error = errorlist.New(errorlist.Network, code, "Request", err)
+	}
+}
+
+// context returns the context t is run in.
+func (t *Test) context() context.Context {
+	if t.ctx == nil {
+		return context.Background()
 	}
+	return t.ctx
+}
+
+// sleep pauses for d or until the context of t is done.
+func (t *Test) sleep(d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-t.context().Done():
+	}
+}
+
+// requestErrorCode returns the errorlist code for err encountered while
+// executing a request with the given URL scheme.
+func requestErrorCode(scheme string, err error) string {
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return "request.timeout"
+	}
+	return "request." + scheme
 }
 
 // ErrCheckPrepare wraps the Err encountered during preparing Check Nr.
@@ -631,15 +1016,20 @@ func (e ErrCheckPrepare) Error() string {
 func (t *Test) PrepareChecks() error {
 	// Compile the checks.
 	var cel errorlist.List
+	prepared := make([]time.Duration, len(t.Checks))
 	for i := range t.Checks {
 		if prep, ok := t.Checks[i].(Preparable); ok {
+			start := time.Now()
 			e := prep.Prepare(t)
+			prepared[i] = time.Since(start)
 			// TODO: use errorlist.List.Append
 			if e != nil {
-				cel = cel.Append(ErrCheckPrepare{
-					Nr:  i,
-					Err: e,
-				})
+				cel = cel.Append(errorlist.New(errorlist.Bogus,
+					"prepare."+NameOf(t.Checks[i]), fmt.Sprintf("Checks[%d]", i),
+					ErrCheckPrepare{
+						Nr:  i,
+						Err: e,
+					}))
 				t.errorf("preparing check %d %q: %s",
 					i, NameOf(t.Checks[i]), e.Error())
 			}
@@ -653,6 +1043,7 @@ func (t *Test) PrepareChecks() error {
 	t.Result.CheckResults = make([]CheckResult, len(t.Checks)) // Zero value is NotRun
 	for i, c := range t.Checks {
 		t.Result.CheckResults[i].Name = NameOf(c)
+		t.Result.CheckResults[i].PrepareDuration = prepared[i]
 		buf, err := json.Marshal(c)
 		if err != nil {
 			buf = []byte(err.Error())
@@ -663,8 +1054,19 @@ func (t *Test) PrepareChecks() error {
 	return nil
 }
 
-// resetRequest rewinds the request body reader.
+// resetRequest rewinds the request body reader and resets the cookies.
 func (t *Test) resetRequest() {
+	// The http.Client adds the cookies from the jar to the request;
+	// drop them to not send them twice.
+	header := t.Request.Request.Header
+	header.Del("Cookie")
+	for _, c := range t.Request.Header["Cookie"] {
+		header.Add("Cookie", c)
+	}
+	for _, cookie := range t.Request.Cookies {
+		t.Request.Request.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+	}
+
 	if t.Request.SentBody == "" {
 		return
 	}
@@ -681,6 +1083,25 @@ func (t *Test) prepareRequest() error {
 		t.errorf("%s", err.Error())
 		return err
 	}
+	if t.Request.Signature != nil {
+		if err := t.Request.Signature.validate(); err != nil {
+			err = fmt.Errorf("bad Signature: %s", err)
+			t.errorf("%s", err.Error())
+			return err
+		}
+	}
+	if t.LoadResources != nil {
+		if err := t.LoadResources.validate(); err != nil {
+			err = fmt.Errorf("bad LoadResources: %s", err)
+			t.errorf("%s", err.Error())
+			return err
+		}
+	}
+	if err := validateStatusMapping(t.Request.StatusMapping); err != nil {
+		err = fmt.Errorf("bad StatusMapping: %s", err)
+		t.errorf("%s", err.Error())
+		return err
+	}
 
 	// Prepare the HTTP header. TODO: Deep Coppy??
 	for h, v := range t.Request.Header {
@@ -710,11 +1131,18 @@ func (t *Test) prepareRequest() error {
 		t.Request.Timeout = DefaultClientTimeout
 	}
 
+	var transport http.RoundTripper = Transport
+	if t.ClientPool != nil {
+		transport = t.ClientPool.Transport()
+	}
 	if t.Request.FollowRedirects {
 		cr := func(req *http.Request, via []*http.Request) error {
 			if len(via) >= 10 {
 				return errors.New("stopped after 10 redirects")
 			}
+			if err := hostAllowed(t.AllowedHosts, req.URL); err != nil {
+				return err
+			}
 			if req.URL.Host == t.Request.Request.URL.Host &&
 				t.Request.BasicAuthUser != "" {
 				if user, pass, ok := t.Request.Request.BasicAuth(); ok {
@@ -725,13 +1153,13 @@ func (t *Test) prepareRequest() error {
 			return nil
 		}
 		t.client = &http.Client{
-			Transport:     Transport,
+			Transport:     transport,
 			CheckRedirect: cr,
 			Timeout:       t.Request.Timeout,
 		}
 	} else {
 		t.client = &http.Client{
-			Transport:     Transport,
+			Transport:     transport,
 			CheckRedirect: dontFollowRedirects,
 			Jar:           nil,
 			Timeout:       t.Request.Timeout,
@@ -876,7 +1304,7 @@ func FileData(s string, variables map[string]string) (data string, basename stri
 	}
 	data = string(raw)
 	if typ == "@vfile" {
-		data = newReplacer(variables).Replace(data)
+		data = scope.Variables(variables).Replacer().Replace(data)
 	}
 	return data, basename, nil
 }
@@ -896,15 +1324,17 @@ func (t *Test) executeRequest() error {
 
 	start := time.Now()
 
-	if t.Execution.Verbosity >= 4 {
-		buf := &bytes.Buffer{}
-		t.Request.Request.Write(buf)
-		t.tracef(" Full Request\n%s\n", buf.String())
-		// "Rewind body"
-		t.Request.Request.Body = ioutil.NopCloser(strings.NewReader(t.Request.SentBody))
+	dump := ""
+	if t.WireLog != nil || t.Execution.Verbosity >= 4 {
+		dump = t.WireLog.dumpRequest(t.Request.Request, t.Request.SentBody)
+		if t.Execution.Verbosity >= 4 {
+			t.tracef(" Full Request\n%s\n", dump)
+		}
 	}
 
-	resp, err := t.client.Do(t.Request.Request)
+	req := t.Request.Request.WithContext(
+		httptrace.WithClientTrace(t.context(), t.connTrace()))
+	resp, err := t.client.Do(req)
 	if ue, ok := err.(*url.Error); ok && ue.Err == errRedirectNofollow &&
 		!t.Request.FollowRedirects {
 		// Clear err if it is just our redirect non-following policy.
@@ -931,19 +1361,8 @@ func (t *Test) executeRequest() error {
 		default:
 			reader = resp.Body
 		}
-		bb, be := ioutil.ReadAll(reader)
-		t.Response.BodyStr = string(bb)
-		t.Response.BodyErr = be
+		t.readBody(reader)
 		reader.Close()
-		if t.Execution.Verbosity >= 4 {
-			buf := &bytes.Buffer{}
-			t.Response.Response.Header.Write(buf)
-
-			t.tracef(" Full Response\n%s %s\n\n%s",
-				t.Response.Response.Proto,
-				t.Response.Response.Status,
-				t.Response.BodyStr)
-		}
 	} else {
 		msg = fmt.Sprintf("fail %s", err.Error())
 	}
@@ -951,6 +1370,17 @@ func (t *Test) executeRequest() error {
 done:
 	t.Response.Duration = time.Since(start)
 
+	if t.WireLog != nil || t.Execution.Verbosity >= 4 {
+		respDump := scope.Mask(fmt.Sprintf("Error: %s\n", err))
+		if resp != nil {
+			respDump = t.WireLog.dumpResponse(resp, t.Response.BodyStr)
+		}
+		if t.Execution.Verbosity >= 4 {
+			t.tracef(" Full Response\n%s", respDump)
+		}
+		t.writeWireLog(dump + "\n" + respDump)
+	}
+
 	for i, via := range t.Response.Redirections {
 		t.infof("Redirection %d: %s", i+1, via)
 	}
@@ -969,67 +1399,143 @@ done:
 // Normally all checks in t.Checks are executed. If the first check in
 // t.Checks is a StatusCode check against 200 and it fails, then the rest of
 // the tests are skipped.
+//
+// If t.Execution.CheckConcurrency is greater than one the checks (after
+// the first one) are executed concurrently by that many workers. The
+// results are recorded in the order of the checks nevertheless.
 func (t *Test) ExecuteChecks() {
-	done := false
-	for i, ck := range t.Checks {
-		start := time.Now()
-		err := ck.Execute(t)
-		t.Result.CheckResults[i].Duration = time.Since(start)
-		t.Result.CheckResults[i].Error = t.Result.CheckResults[i].Error.Append(err)
-		if err != nil {
-			t.debugf("Check %d %s Fail: %s", i+1, NameOf(ck), err)
-			if _, ok := err.(MalformedCheck); ok {
-				t.Result.CheckResults[i].Status = Bogus
-			} else {
-				t.Result.CheckResults[i].Status = Fail
-			}
-			var errlist errorlist.List
-			if el, ok := t.Result.Error.(errorlist.List); ok {
-				errlist = el
-			}
-			for _, pce := range t.Result.CheckResults[i].Error {
-				errlist = append(errlist, fmt.Errorf("Check %s: %s",
-					t.Result.CheckResults[i].Name, pce))
-			}
-			if len(errlist) != 0 {
-				t.Result.Error = errlist
+	n := len(t.Checks)
+	if n == 0 {
+		return
+	}
+	workers := t.Execution.CheckConcurrency
+	if workers <= 1 {
+		for i := range t.Checks {
+			if t.recordCheck(i, t.executeCheck(i)) {
+				break
 			}
+		}
+		return
+	}
 
-			// Abort needles checking if all went wrong.
-			if i == 0 { // only first check is checked against StatusCode/200.
-				sc, ok := ck.(StatusCode)
-				if !ok {
-					if psc, pok := ck.(*StatusCode); pok {
-						ok = true
-						sc = *psc
-					}
-				}
-				if ok && sc.Expect == 200 {
-					t.debugf("skipping remaining tests as bad StatusCode %s", t.Response.Response.Status)
-					// Clear Status and Error field as these might be
-					// populated from a prior try run of the test.
-					for j := 1; j < len(t.Result.CheckResults); j++ {
-						t.Result.CheckResults[j].Status = Skipped
-						t.Result.CheckResults[j].Error = nil
-					}
-					done = true
-				}
+	// The first check is executed alone as it might render the
+	// remaining ones useless.
+	if t.recordCheck(0, t.executeCheck(0)) {
+		return
+	}
+	errs := make([]error, n)
+	next := make(chan int)
+	wg := &sync.WaitGroup{}
+	if workers > n-1 {
+		workers = n - 1
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				errs[i] = t.executeCheck(i)
 			}
+		}()
+	}
+	for i := 1; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	for i := 1; i < n; i++ {
+		t.recordCheck(i, errs[i])
+	}
+}
+
+// executeCheck executes check i of t (or takes its result from the
+// body stream) and records its duration.
+func (t *Test) executeCheck(i int) error {
+	start := time.Now()
+	var err error
+	if stream, ok := t.streams[i]; ok && t.Response.BodyErr == ErrBodyTruncated {
+		err = stream.Result()
+	} else {
+		err = t.Checks[i].Execute(t)
+	}
+	t.Result.CheckResults[i].Duration = time.Since(start)
+	return err
+}
+
+// recordCheck records the outcome err of check i in the results of t.
+// It reports whether the remaining checks are skipped.
+func (t *Test) recordCheck(i int, err error) (done bool) {
+	ck := t.Checks[i]
+	if err != nil {
+		t.debugf("Check %d %s Fail: %s", i+1, NameOf(ck), err)
+		field := fmt.Sprintf("Checks[%d]", i)
+		if _, ok := err.(MalformedCheck); ok {
+			t.Result.CheckResults[i].Status = Bogus
+			err = errorlist.New(errorlist.Bogus, "malformed."+NameOf(ck), field, err)
 		} else {
-			t.Result.CheckResults[i].Status = Pass
-			t.debugf("Check %d %s: Pass", i+1, NameOf(ck))
+			t.Result.CheckResults[i].Status = Fail
+			err = errorlist.New(errorlist.Check, "check."+NameOf(ck), field, err)
+		}
+		t.Result.CheckResults[i].Error = t.Result.CheckResults[i].Error.Append(err)
+		var errlist errorlist.List
+		if el, ok := t.Result.Error.(errorlist.List); ok {
+			errlist = el
 		}
-		if t.Result.CheckResults[i].Status > t.Result.Status {
-			t.Result.Status = t.Result.CheckResults[i].Status
+		for _, pce := range t.Result.CheckResults[i].Error {
+			detail := errorlist.Details(pce)[0]
+			detail.Err = fmt.Errorf("Check %s: %s",
+				t.Result.CheckResults[i].Name, pce)
+			errlist = append(errlist, detail)
 		}
-		if done {
-			break
+		if len(errlist) != 0 {
+			t.Result.Error = errlist
+		}
+
+		// Abort needles checking if all went wrong.
+		if i == 0 { // only first check is checked against StatusCode/200.
+			sc, ok := ck.(StatusCode)
+			if !ok {
+				if psc, pok := ck.(*StatusCode); pok {
+					ok = true
+					sc = *psc
+				}
+			}
+			if ok && sc.Expect == 200 {
+				t.debugf("skipping remaining tests as bad StatusCode %s", t.Response.Response.Status)
+				// Clear Status and Error field as these might be
+				// populated from a prior try run of the test.
+				for j := 1; j < len(t.Result.CheckResults); j++ {
+					t.Result.CheckResults[j].Status = Skipped
+					t.Result.CheckResults[j].Error = nil
+				}
+				done = true
+			}
 		}
+	} else {
+		t.Result.CheckResults[i].Status = Pass
+		t.debugf("Check %d %s: Pass", i+1, NameOf(ck))
 	}
+	if t.Result.CheckResults[i].Status > t.Result.Status {
+		t.Result.Status = t.Result.CheckResults[i].Status
+	}
+	return done
+}
+
+// logf logs to t.Logger if set and reports whether it did.
+func (t *Test) logf(level logging.Level, format string, v ...interface{}) bool {
+	if t.Logger == nil {
+		return false
+	}
+	fields := []logging.Field{logging.F(logging.TestKey, t.Name)}
+	if seqno := t.GetStringMetadata("SeqNo"); seqno != "" {
+		fields = append(fields, logging.F(logging.SeqNoKey, seqno))
+	}
+	t.Logger.Log(level, fmt.Sprintf(format, v...), fields...)
+	return true
 }
 
 func (t *Test) errorf(format string, v ...interface{}) {
-	if t.Execution.Verbosity >= 0 && t.Log != nil {
+	if t.Execution.Verbosity >= 0 && !t.logf(logging.Error, format, v...) && t.Log != nil {
 		format = "ERROR " + format + " [%q]"
 		v = append(v, t.Name)
 		t.Log.Printf(format, v...)
@@ -1037,7 +1543,7 @@ func (t *Test) errorf(format string, v ...interface{}) {
 }
 
 func (t *Test) infof(format string, v ...interface{}) {
-	if t.Execution.Verbosity >= 1 && t.Log != nil {
+	if t.Execution.Verbosity >= 1 && !t.logf(logging.Info, format, v...) && t.Log != nil {
 		format = "INFO  " + format + " [%q]"
 		v = append(v, t.Name)
 		t.Log.Printf(format, v...)
@@ -1045,7 +1551,7 @@ func (t *Test) infof(format string, v ...interface{}) {
 }
 
 func (t *Test) debugf(format string, v ...interface{}) {
-	if t.Execution.Verbosity >= 2 && t.Log != nil {
+	if t.Execution.Verbosity >= 2 && !t.logf(logging.Debug, format, v...) && t.Log != nil {
 		format = "DEBUG " + format + " [%q]"
 		v = append(v, t.Name)
 		t.Log.Printf(format, v...)
@@ -1053,7 +1559,7 @@ func (t *Test) debugf(format string, v ...interface{}) {
 }
 
 func (t *Test) tracef(format string, v ...interface{}) {
-	if t.Execution.Verbosity >= 3 && t.Log != nil {
+	if t.Execution.Verbosity >= 3 && !t.logf(logging.Trace, format, v...) && t.Log != nil {
 		format = "TRACE Begin [%q]" + format + "TRACE End"
 		v = append([]interface{}{t.Name}, v...)
 		t.Log.Printf(format, v...)
@@ -1160,21 +1666,6 @@ func dontFollowRedirects(*http.Request, []*http.Request) error {
 	return errRedirectNofollow
 }
 
-// newReplacer produces a strings.Replacer which
-// given variables with their values. A key of the form "#123" (i.e. hash
-// followed by literal decimal integer) is treated as an integer substitution.
-// Other keys are treated as string variables which subsitutes "{{k}}" with
-// vars[k] for a key k. Maybe just have a look at the code.
-func newReplacer(vars map[string]string) *strings.Replacer {
-	oldnew := []string{}
-	for k, v := range vars {
-		// A string substitution.
-		oldnew = append(oldnew, "{{"+k+"}}") // TODO: make configurable ??
-		oldnew = append(oldnew, v)
-	}
-	return strings.NewReplacer(oldnew...)
-}
-
 // ----------------------------------------------------------------------------
 // Generating curl calls
 
@@ -1282,6 +1773,10 @@ func (t *Test) CurlCall() string {
 		}
 	}
 
+	if t.Request.FollowRedirects {
+		call += " -L"
+	}
+
 	// BasicAuth
 	if t.Request.BasicAuthUser != "" {
 		arg := fmt.Sprintf("%s:%s", t.Request.BasicAuthUser, t.Request.BasicAuthPass)
@@ -1324,8 +1819,7 @@ func (t *Test) CurlCall() string {
 	}
 
 	// The Body
-	if t.Request.Body != "" &&
-		(t.Request.Method == "POST" || t.Request.Method == "PUT") {
+	if t.Request.Body != "" {
 		if nontrivial {
 			call += ` --data-binary "@$tmp"`
 		} else {
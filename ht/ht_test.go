@@ -5,6 +5,7 @@
 package ht
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -22,6 +23,10 @@ import (
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/vdobler/ht/cookiejar"
+	"github.com/vdobler/ht/errorlist"
+	"github.com/vdobler/ht/tracing"
 )
 
 var verboseTest = flag.Bool("ht.verbose", false, "be verbose during testing")
@@ -390,6 +395,228 @@ func TestPolling(t *testing.T) {
 	}
 }
 
+func TestFreshTries(t *testing.T) {
+	var mu sync.Mutex
+	cookies, addrs, ids := []string{}, []string{}, []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		cookies = append(cookies, r.Header.Get("Cookie"))
+		addrs = append(addrs, r.RemoteAddr)
+		ids = append(ids, r.FormValue("id"))
+		http.SetCookie(w, &http.Cookie{Name: "try", Value: strconv.Itoa(len(ids))})
+		if len(ids) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	newTest := func(id int, fresh bool) *Test {
+		return &Test{
+			Request: Request{
+				URL:    ts.URL + "/",
+				Params: url.Values{"id": {strconv.Itoa(id)}},
+			},
+			Checks: CheckList{StatusCode{Expect: 200}},
+			Hooks:  &Hooks{},
+			Execution: Execution{
+				Tries:            3,
+				CheckConcurrency: id + 1,
+				FreshCookies:     fresh,
+				FreshConnection:  fresh,
+				FreshVariables:   fresh,
+			},
+		}
+	}
+
+	for _, fresh := range []bool{false, true} {
+		cookies, addrs, ids = cookies[:0], addrs[:0], ids[:0]
+		jar, _ := cookiejar.New(nil)
+		u, _ := url.Parse(ts.URL)
+		jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "s1"}})
+		id := 0
+		test := newTest(id, fresh)
+		test.Jar = jar
+		test.ClientPool = &ClientPool{}
+		hooked := 0
+		test.Hooks.PreRequestFunc = func(*Test) error { hooked++; return nil }
+		test.Renew = func() (*Test, error) {
+			id++
+			return newTest(id, fresh), nil
+		}
+		test.Run()
+		if test.Result.Status != Pass || test.Result.Tries != 3 {
+			t.Fatalf("fresh=%t: got status %s after %d tries: %v", fresh,
+				test.Result.Status, test.Result.Tries, test.Result.Error)
+		}
+
+		gotCookies := strings.Join(cookies, " | ")
+		gotIDs := strings.Join(ids, " ")
+		newConns := addrs[0] != addrs[1] && addrs[1] != addrs[2]
+		if fresh {
+			if gotCookies != "session=s1 | session=s1 | session=s1" {
+				t.Errorf("fresh: got cookies %q", gotCookies)
+			}
+			if !newConns {
+				t.Errorf("fresh: got connections %v", addrs)
+			}
+			if gotIDs != "0 1 2" {
+				t.Errorf("fresh: got ids %q", gotIDs)
+			}
+		} else {
+			if gotCookies != "session=s1 | session=s1; try=1 | session=s1; try=2" {
+				t.Errorf("got cookies %q", gotCookies)
+			}
+			if newConns {
+				t.Errorf("got connections %v", addrs)
+			}
+			if gotIDs != "0 0 0" {
+				t.Errorf("got ids %q", gotIDs)
+			}
+		}
+		if e := jar.AllEntries(); len(e) != 2 {
+			t.Errorf("fresh=%t: got %d cookies in jar", fresh, len(e))
+		}
+		if hooked != 3 {
+			t.Errorf("fresh=%t: hook function called %d times", fresh, hooked)
+		}
+		want := 1
+		if fresh {
+			want = 3 // from the last renewed test
+		}
+		if test.Execution.CheckConcurrency != want || test.Execution.Tries != 3 {
+			t.Errorf("fresh=%t: got execution %+v", fresh, test.Execution)
+		}
+	}
+}
+
+func TestTraceparentPerTry(t *testing.T) {
+	var mu sync.Mutex
+	parents := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		parents = append(parents, r.Header.Get("traceparent"))
+		if len(parents)%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	tracer := tracing.NewTracer("test")
+	test := &Test{
+		Request:   Request{URL: ts.URL + "/"},
+		Checks:    CheckList{StatusCode{Expect: 200}},
+		Execution: Execution{Tries: 2},
+		Span:      tracer.Start("Test"),
+	}
+	test.Run()
+	if test.Result.Status != Pass || len(parents) != 2 {
+		t.Fatalf("Got status %s after %d requests", test.Result.Status, len(parents))
+	}
+	if parents[0] == "" || parents[0] == parents[1] {
+		t.Errorf("Got traceparents %q", parents)
+	}
+
+	parents = parents[:0]
+	test.Request.Header = http.Header{"Traceparent": {"00-explicit"}}
+	test.Run()
+	if len(parents) != 2 || parents[0] != "00-explicit" || parents[1] != "00-explicit" {
+		t.Errorf("Got traceparents %q", parents)
+	}
+}
+
+// sleepCheck is a slow check which fails if Fail is set.
+type sleepCheck struct {
+	Sleep time.Duration
+	Fail  bool
+}
+
+func (c sleepCheck) Execute(t *Test) error {
+	time.Sleep(c.Sleep)
+	if c.Fail {
+		return fmt.Errorf("failed after %s", c.Sleep)
+	}
+	return nil
+}
+
+func TestRunContext(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(5 * time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer ts.Close()
+
+	test := Test{
+		Request:   Request{URL: ts.URL + "/"},
+		Checks:    CheckList{StatusCode{Expect: 200}},
+		Execution: Execution{Tries: 3, Wait: 5 * time.Second},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := test.RunContext(ctx); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Took too long: %s", d)
+	}
+	if test.Result.Status != Error || test.Result.Tries != 1 {
+		t.Errorf("Got status %s after %d tries", test.Result.Status, test.Result.Tries)
+	}
+	details := errorlist.Details(test.Result.Error)
+	if len(details) != 1 || details[0].Code != "request.canceled" {
+		t.Errorf("Got details %v", details)
+	}
+}
+
+func TestConcurrentChecks(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer ts.Close()
+
+	test := Test{
+		Request: Request{URL: ts.URL + "/"},
+		Checks: CheckList{
+			StatusCode{Expect: 200},
+			sleepCheck{Sleep: 60 * time.Millisecond, Fail: true},
+			sleepCheck{Sleep: 50 * time.Millisecond},
+			sleepCheck{Sleep: 40 * time.Millisecond, Fail: true},
+			sleepCheck{Sleep: 30 * time.Millisecond},
+		},
+		Execution: Execution{CheckConcurrency: 4},
+	}
+	start := time.Now()
+	test.Run()
+	if d := time.Since(start); d > 150*time.Millisecond {
+		t.Errorf("Took too long: %s", d)
+	}
+	if test.Result.Status != Fail {
+		t.Errorf("Got status %s", test.Result.Status)
+	}
+	want := []Status{Pass, Fail, Pass, Fail, Pass}
+	for i, cr := range test.Result.CheckResults {
+		if cr.Status != want[i] {
+			t.Errorf("Check %d: got %s, want %s", i, cr.Status, want[i])
+		}
+	}
+	if got := test.Result.Error.Error(); got != "Check sleepCheck: failed after 60ms; \u2029"+
+		"Check sleepCheck: failed after 40ms" {
+		t.Errorf("Got error %q", got)
+	}
+
+	// A failing first StatusCode 200 check skips the rest.
+	test.Request.Params = url.Values{"status": {"404"}}
+	test.Run()
+	want = []Status{Fail, Skipped, Skipped, Skipped, Skipped}
+	for i, cr := range test.Result.CheckResults {
+		if cr.Status != want[i] {
+			t.Errorf("Check %d: got %s, want %s", i, cr.Status, want[i])
+		}
+	}
+}
+
 func TestClientTimeout(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(echoHandler))
 	defer ts.Close()
@@ -418,6 +645,47 @@ func TestClientTimeout(t *testing.T) {
 	if test.Result.Status != Error {
 		t.Errorf("Got status %s, want Error", test.Result.Status)
 	}
+	if d := errorlist.Details(test.Result.Error); len(d) != 1 ||
+		d[0].Category != errorlist.Network || d[0].Code != "request.timeout" {
+		t.Errorf("Got details %+v", d)
+	}
+}
+
+func TestErrorDetails(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer ts.Close()
+
+	test := Test{
+		Request: Request{URL: ts.URL + "/", Params: url.Values{"status": {"404"}}},
+		Checks: []Check{
+			StatusCode{Expect: 404},
+			&Body{Contains: "foo"},
+		},
+	}
+	test.Run()
+	if test.Result.Status != Fail {
+		t.Fatalf("Got status %s", test.Result.Status)
+	}
+	d := errorlist.Details(test.Result.Error)
+	if len(d) != 1 || d[0].Category != errorlist.Check || d[0].Code != "check.Body" ||
+		d[0].Field != "Checks[1]" || !strings.HasPrefix(d[0].Error(), "Check Body: ") {
+		t.Errorf("Got details %+v", d)
+	}
+	if d := test.Result.CheckResults[1].Error.Details(); len(d) != 1 ||
+		d[0].Code != "check.Body" || strings.HasPrefix(d[0].Error(), "Check") {
+		t.Errorf("Got check details %+v", d)
+	}
+
+	test = Test{
+		Request: Request{URL: ts.URL + "/"},
+		Checks:  []Check{&Body{Regexp: "("}},
+	}
+	test.Run()
+	if d := errorlist.Details(test.Result.Error); test.Result.Status != Bogus ||
+		len(d) != 1 || d[0].Category != errorlist.Bogus || d[0].Code != "prepare.Body" ||
+		d[0].Field != "Checks[0]" {
+		t.Errorf("Got %s %+v", test.Result.Status, d)
+	}
 }
 
 func TestMerge(t *testing.T) {
@@ -65,7 +65,7 @@ func (w W3CValidHTML) Execute(t *Test) error {
 	// TODO: properly limit global rate at which we fire to W3C validator
 	time.Sleep(100 * time.Millisecond)
 
-	err := test.Run()
+	err := test.RunContext(t.context())
 	if err != nil {
 		return CantCheck{err}
 	}
@@ -186,7 +186,7 @@ func (c *HTMLTag) Execute(t *Test) error {
 
 // Prepare implements Check's Prepare method.
 func (c *HTMLTag) Prepare(*Test) (err error) {
-	c.sel, err = cascadia.Compile(c.Selector)
+	c.sel, err = compileSelector(c.Selector)
 	if err != nil {
 		c.sel = nil
 		return err
@@ -294,7 +294,7 @@ var errTagNotFound = fmt.Errorf("tag not found")
 
 // Prepare implements Check's Prepare method.
 func (c *HTMLContains) Prepare(*Test) (err error) {
-	c.sel, err = cascadia.Compile(c.Selector)
+	c.sel, err = compileSelector(c.Selector)
 	if err != nil {
 		c.sel = nil
 		return err
@@ -550,7 +550,7 @@ func (c *Links) Execute(t *Test) error {
 		conc = c.Concurrency
 	}
 	started := time.Now()
-	suite.ExecuteConcurrent(conc, nil)
+	suite.ExecuteConcurrentContext(t.context(), conc, nil)
 	if suite.Status != Pass {
 		for _, test := range suite.Tests {
 			if test.Result.Status == Error || test.Result.Status == Bogus {
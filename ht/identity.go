@@ -8,6 +8,7 @@ package ht
 
 import (
 	"crypto/sha1"
+	"crypto/sha256"
 	"fmt"
 )
 
@@ -18,13 +19,17 @@ func init() {
 // ----------------------------------------------------------------------------
 // Identity
 
-// Identity checks the value of the response body by comparing its SHA1 hash
-// to the expected SHA1 value.
+// Identity checks the value of the response body by comparing its SHA1
+// and/or SHA-256 hash to the expected value.
 type Identity struct {
 	// SHA1 is the expected hash as shown by sha1sum of the whole body.
 	// E.g. 2ef7bde608ce5404e97d5f042f95f89f1c232871 for a "Hello World!"
 	// body (no newline).
 	SHA1 string
+
+	// SHA256 is the expected hash as shown by sha256sum of the whole
+	// body. If set, SHA1 is checked only if non-empty.
+	SHA256 string `json:",omitempty"`
 }
 
 // Execute implements Check's Execute method.
@@ -32,8 +37,22 @@ func (i Identity) Execute(t *Test) error {
 	if t.Response.BodyErr != nil {
 		return CantCheck{t.Response.BodyErr}
 	}
-	hash := sha1.Sum([]byte(t.Response.BodyStr))
-	s := fmt.Sprintf("%02x", hash)
+	h1 := sha1.Sum([]byte(t.Response.BodyStr))
+	h256 := sha256.Sum256([]byte(t.Response.BodyStr))
+	return i.compare(h1[:], h256[:])
+}
+
+// compare the hashes of the body with the expected ones.
+func (i Identity) compare(h1, h256 []byte) error {
+	if i.SHA256 != "" {
+		if s := fmt.Sprintf("%02x", h256); s != i.SHA256 {
+			return fmt.Errorf("Got SHA256 %s", s)
+		}
+		if i.SHA1 == "" {
+			return nil
+		}
+	}
+	s := fmt.Sprintf("%02x", h1)
 	if s == i.SHA1 {
 		return nil
 	}
@@ -0,0 +1,192 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+func init() {
+	RegisterCheck(&MockRequest{})
+	RegisterExtractor(MockExtractor{})
+}
+
+// ----------------------------------------------------------------------------
+// Journal
+
+// Journal records the requests received by the mocks provided for a Test.
+// It is safe for concurrent use; a nil Journal records nothing.
+type Journal struct {
+	mu      sync.Mutex
+	entries []JournalEntry
+}
+
+// JournalEntry is one request received by a mock.
+type JournalEntry struct {
+	Mock   string // Name of the mock, empty for requests no mock handled.
+	Time   time.Time
+	Method string
+	URL    string
+	Header http.Header
+	Body   string
+}
+
+// Record appends e to j.
+func (j *Journal) Record(e JournalEntry) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	j.entries = append(j.entries, e)
+	j.mu.Unlock()
+}
+
+// Entries returns the requests received by the named mock in the order of
+// arrival. An empty mock returns the requests received by all mocks.
+func (j *Journal) Entries(mock string) []JournalEntry {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entries := []JournalEntry{}
+	for _, e := range j.entries {
+		if mock == "" || e.Mock == mock {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// ----------------------------------------------------------------------------
+// MockRequest
+
+// MockRequest checks the requests received by the mocks provided for the
+// test, e.g. that the mocked backend got the proper credentials:
+//     {Check: "MockRequest", Mock: "Payment", Header: {Authorization: {Prefix: "Bearer "}}}
+// All requests received by Mock must fulfill the given conditions.
+type MockRequest struct {
+	// Mock is the name of the mock. The empty string selects all mocks.
+	Mock string `json:",omitempty"`
+
+	// Count is the number of requests Mock must have received. The
+	// zero value requires at least one request, a negative value none.
+	Count int `json:",omitempty"`
+
+	// Method is the expected request method.
+	Method string `json:",omitempty"`
+
+	// Header maps header names to the condition the first header value
+	// must fulfill. A missing header is treated as the empty string.
+	Header map[string]Condition `json:",omitempty"`
+
+	// Body is the condition the request body must fulfill.
+	Body Condition `json:",omitempty"`
+}
+
+// Prepare implements Check's Prepare method.
+func (m *MockRequest) Prepare(*Test) error {
+	for h, c := range m.Header {
+		if err := c.Compile(); err != nil {
+			return fmt.Errorf("header %s: %s", h, err)
+		}
+		m.Header[h] = c
+	}
+	return m.Body.Compile()
+}
+
+var _ Preparable = &MockRequest{}
+
+// Execute implements Check's Execute method.
+func (m MockRequest) Execute(t *Test) error {
+	entries := t.Journal.Entries(m.Mock)
+	switch {
+	case m.Count < 0 && len(entries) > 0:
+		return fmt.Errorf("mock %q received %d requests, want none", m.Mock, len(entries))
+	case m.Count == 0 && len(entries) == 0:
+		return fmt.Errorf("mock %q received no request", m.Mock)
+	case m.Count > 0 && len(entries) != m.Count:
+		return fmt.Errorf("mock %q received %d requests, want %d",
+			m.Mock, len(entries), m.Count)
+	}
+
+	for i, e := range entries {
+		if m.Method != "" && e.Method != m.Method {
+			return fmt.Errorf("request %d to %q: got method %s, want %s",
+				i+1, m.Mock, e.Method, m.Method)
+		}
+		for h, c := range m.Header {
+			if err := c.Fulfilled(e.Header.Get(h)); err != nil {
+				return fmt.Errorf("request %d to %q: header %s %s", i+1, m.Mock, h, err)
+			}
+		}
+		if err := m.Body.Fulfilled(e.Body); err != nil {
+			return fmt.Errorf("request %d to %q: body %s", i+1, m.Mock, err)
+		}
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+// MockExtractor
+
+// MockExtractor extracts data from the latest request received by a mock
+// provided for the test.
+type MockExtractor struct {
+	// Mock is the name of the mock.
+	Mock string
+
+	// Header is the name of the header to extract. If empty the request
+	// body is used.
+	Header string `json:",omitempty"`
+
+	// Regexp, if non-empty, is applied to the header value or body and
+	// the match is extracted.
+	Regexp string `json:",omitempty"`
+
+	// SubMatch selects which submatch (capturing group) of Regexp shall
+	// be returned. A 0 value indicates the whole match.
+	Submatch int `json:",omitempty"`
+}
+
+// Extract implements Extractor's Extract method.
+func (e MockExtractor) Extract(t *Test) (string, error) {
+	entries := t.Journal.Entries(e.Mock)
+	if len(entries) == 0 {
+		return "", fmt.Errorf("mock %q received no request", e.Mock)
+	}
+	last := entries[len(entries)-1]
+	s := last.Body
+	if e.Header != "" {
+		values, ok := last.Header[http.CanonicalHeaderKey(e.Header)]
+		if !ok || len(values) == 0 {
+			return "", fmt.Errorf("mock %q: header %s not received", e.Mock, e.Header)
+		}
+		s = values[0]
+	}
+	if e.Regexp == "" {
+		return s, nil
+	}
+
+	re, err := compileRegexp(e.Regexp)
+	if err != nil {
+		return "", err
+	}
+	if e.Submatch < 0 {
+		return "", errors.New("MockExtractor.Submatch < 0")
+	}
+	submatches := re.FindStringSubmatch(s)
+	if len(submatches) > e.Submatch {
+		return submatches[e.Submatch], nil
+	}
+	if len(submatches) == 0 {
+		return "", fmt.Errorf("no match found in %q", s)
+	}
+	return "", fmt.Errorf("got only %d submatches in %q", len(submatches)-1, submatches[0])
+}
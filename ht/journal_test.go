@@ -0,0 +1,84 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"net/http"
+	"testing"
+)
+
+func journalTest() *Test {
+	journal := &Journal{}
+	journal.Record(JournalEntry{
+		Mock:   "Auth",
+		Method: "POST",
+		Header: http.Header{"Authorization": {"Bearer abc123"}},
+		Body:   "user=joe",
+	})
+	journal.Record(JournalEntry{
+		Mock:   "Auth",
+		Method: "POST",
+		Header: http.Header{"Authorization": {"Bearer xyz789"}},
+		Body:   "user=ann",
+	})
+	return &Test{Journal: journal}
+}
+
+func TestMockRequest(t *testing.T) {
+	for i, tc := range []struct {
+		check MockRequest
+		ok    bool
+	}{
+		{MockRequest{Mock: "Auth"}, true},
+		{MockRequest{Mock: "Auth", Count: 2, Method: "POST"}, true},
+		{MockRequest{Mock: "Auth", Count: 1}, false},
+		{MockRequest{Mock: "Auth", Count: -1}, false},
+		{MockRequest{Mock: "Other"}, false},
+		{MockRequest{Mock: "Other", Count: -1}, true},
+		{MockRequest{Count: 2}, true},
+		{MockRequest{Mock: "Auth", Method: "GET"}, false},
+		{MockRequest{Mock: "Auth",
+			Header: map[string]Condition{"Authorization": {Prefix: "Bearer "}}}, true},
+		{MockRequest{Mock: "Auth",
+			Header: map[string]Condition{"Authorization": {Equals: "Bearer abc123"}}}, false},
+		{MockRequest{Mock: "Auth", Body: Condition{Regexp: "^user=[a-z]+$"}}, true},
+		{MockRequest{Mock: "Auth", Body: Condition{Contains: "joe"}}, false},
+	} {
+		test := journalTest()
+		check := tc.check
+		if err := check.Prepare(test); err != nil {
+			t.Errorf("%d: unexpected error %s", i, err)
+			continue
+		}
+		err := check.Execute(test)
+		if tc.ok && err != nil {
+			t.Errorf("%d: unexpected error %s", i, err)
+		} else if !tc.ok && err == nil {
+			t.Errorf("%d: missing error", i)
+		}
+	}
+}
+
+func TestMockExtractor(t *testing.T) {
+	test := journalTest()
+	for i, tc := range []struct {
+		ex   MockExtractor
+		want string
+	}{
+		{MockExtractor{Mock: "Auth"}, "user=ann"},
+		{MockExtractor{Mock: "Auth", Regexp: "user=(.*)", Submatch: 1}, "ann"},
+		{MockExtractor{Mock: "Auth", Header: "authorization", Regexp: "[0-9]+"}, "789"},
+		{MockExtractor{Mock: "Auth", Header: "X-Missing"}, "error"},
+		{MockExtractor{Mock: "Other"}, "error"},
+	} {
+		got, err := tc.ex.Extract(test)
+		if err != nil {
+			got = "error"
+		}
+		if got != tc.want {
+			t.Errorf("%d: got %q, want %q", i, got, tc.want)
+		}
+	}
+}
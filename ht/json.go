@@ -172,7 +172,7 @@ func (c *JSON) Prepare(t *Test) error {
 		return err
 	}
 	if c.Schema != "" {
-		err = hjson.Unmarshal([]byte(c.Schema), &c.schema)
+		c.schema, err = parseSchema(c.Schema)
 		if err != nil {
 			return err
 		}
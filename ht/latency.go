@@ -8,6 +8,7 @@ package ht
 
 import (
 	"bufio"
+	"context"
 	"encoding/csv"
 	"fmt"
 	"io"
@@ -104,7 +105,7 @@ func (L *Latency) Execute(t *Test) error {
 		return err
 	}
 	// Warump phase. Used to warmup the server side (not our code here).
-	averageRT := L.warmup(tests)
+	averageRT := L.warmup(t.context(), tests)
 	offset := averageRT / time.Duration(L.Concurrent)
 
 	conc := L.Concurrent
@@ -129,7 +130,7 @@ func (L *Latency) Execute(t *Test) error {
 		wg.Add(1)
 		go func(ex *Test, id int) {
 			for running := true; running; {
-				ex.Run()
+				ex.RunContext(t.context())
 				lr := latencyResult{
 					status:   ex.Result.Status,
 					started:  ex.Result.Started,
@@ -254,7 +255,7 @@ func (L *Latency) produceTestSet(t *Test) ([]*Test, error) {
 }
 
 // warump the server by running tests. Returns the average response time.
-func (L *Latency) warmup(tests []*Test) time.Duration {
+func (L *Latency) warmup(ctx context.Context, tests []*Test) time.Duration {
 	wg := &sync.WaitGroup{}
 	started := time.Now()
 	prewarmed := 0
@@ -263,7 +264,7 @@ func (L *Latency) warmup(tests []*Test) time.Duration {
 			prewarmed++
 			wg.Add(1)
 			go func(ex *Test) {
-				ex.Run()
+				ex.RunContext(ctx)
 				wg.Done()
 			}(t)
 		}
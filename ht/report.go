@@ -9,6 +9,7 @@ import (
 	"io"
 	"strings"
 	"text/template"
+	"time"
 )
 
 // ----------------------------------------------------------------------------
@@ -54,11 +55,21 @@ func (s Status) MarshalText() ([]byte, error) {
 	return []byte(s.String()), nil
 }
 
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *Status) UnmarshalText(text []byte) error {
+	status := StatusFromString(string(text))
+	if status < 0 || len(text) == 0 {
+		return fmt.Errorf("no such status %q", text)
+	}
+	*s = status
+	return nil
+}
+
 // ----------------------------------------------------------------------------
 // Templates to output
 
 // DefaultCheckTemplate is used by DefaultTestTemplate to print the checks.
-var DefaultCheckTemplate = `{{define "CHECK"}}{{printf "%-7s %-15s %s" .Status .Name .JSON}}` +
+var DefaultCheckTemplate = `{{define "CHECK"}}{{printf "%-7s %-15s %8s %s" .Status .Name (niceduration .Duration) .JSON}}` +
 	`{{if eq .Status 3 5}}{{range .Error}}
                 {{.Error}}{{end}}{{end}}{{end}}`
 
@@ -97,6 +108,9 @@ func init() {
 	fm["Underline"] = Underline
 	fm["Box"] = Box
 	fm["ToUpper"] = strings.ToUpper
+	fm["niceduration"] = func(d time.Duration) time.Duration {
+		return d.Round(10 * time.Microsecond)
+	}
 
 	ShortTestTmpl = template.New("SHORTTEST")
 	ShortTestTmpl.Funcs(fm)
@@ -195,7 +195,7 @@ func (r Resilience) Execute(t *Test) error {
 	}
 
 	t.infof("Start of resilience suite")
-	suite.ExecuteConcurrent(1, nil) // TODO: why not higher concurrency ??
+	suite.ExecuteConcurrentContext(t.context(), 1, nil) // TODO: why not higher concurrency ??
 	t.infof("End of resilience suite")
 	if suite.Status != Pass {
 		return r.collectErrors(t, suite)
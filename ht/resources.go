@@ -0,0 +1,360 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// resources.go contains the browser-like loading of page resources.
+
+package ht
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/vdobler/ht/errorlist"
+	"golang.org/x/net/html"
+)
+
+func init() {
+	RegisterCheck(Assets{})
+}
+
+// DefaultResourceConcurrency is the number of resources loaded concurrently
+// if ResourceLoading.Concurrency is zero. It resembles the number of
+// connections per host used by browsers.
+var DefaultResourceConcurrency = 6
+
+// DefaultMaxResources is the maximum number of resources loaded if
+// ResourceLoading.MaxResources is zero.
+var DefaultMaxResources = 100
+
+// ResourceLoading makes a test load the resources referenced by a HTML page
+// like a browser does: After the page has been received, all its
+// stylesheets, scripts, images etc. are fetched concurrently (reusing the
+// connections and the cookies of the test). The results are recorded in
+// Response.Resources and can be checked with the Assets check.
+//
+// The following Types of resources are available:
+//     stylesheet  <link rel="stylesheet" href="...">
+//     script      <script src="...">
+//     image       <img src="...">, <link rel="icon" href="...">
+//     media       <video src="...">, <audio src="...">, <source src="...">
+//     iframe      <iframe src="...">
+// Resources are loaded only for HTML pages.
+type ResourceLoading struct {
+	// Types of resources to load. An empty Types loads stylesheets,
+	// scripts and images.
+	Types []string `json:",omitempty"`
+
+	// Concurrency is the number of resources loaded concurrently.
+	// Zero means DefaultResourceConcurrency.
+	Concurrency int `json:",omitempty"`
+
+	// MaxResources limits the number of loaded resources. Zero means
+	// DefaultMaxResources.
+	MaxResources int `json:",omitempty"`
+
+	// Timeout for loading one resource. Zero means the timeout of the
+	// request.
+	Timeout time.Duration `json:",omitempty"`
+
+	// Ignore resources whose URL fulfills one of these conditions.
+	Ignore []Condition `json:",omitempty"`
+}
+
+// Resource is a loaded resource of a HTML page.
+type Resource struct {
+	URL         string
+	Type        string
+	StatusCode  int           `json:",omitempty"`
+	ContentType string        `json:",omitempty"`
+	Size        int64         `json:",omitempty"`
+	Duration    time.Duration `json:",omitempty"`
+	Error       string        `json:",omitempty"`
+}
+
+// resourceSelectors maps the types of resources to the selectors and
+// attributes of the referencing elements.
+var resourceSelectors = map[string][]struct {
+	sel  cascadia.Selector
+	attr string
+}{
+	"stylesheet": {{cascadia.MustCompile(`link[rel~="stylesheet"]`), "href"}},
+	"script":     {{cascadia.MustCompile("script[src]"), "src"}},
+	"image": {
+		{cascadia.MustCompile("img[src]"), "src"},
+		{cascadia.MustCompile(`link[rel~="icon"]`), "href"},
+	},
+	"media":  {{cascadia.MustCompile("video[src], audio[src], source[src]"), "src"}},
+	"iframe": {{cascadia.MustCompile("iframe[src]"), "src"}},
+}
+
+var defaultResourceTypes = []string{"stylesheet", "script", "image"}
+
+// validate checks the configuration of rl.
+func (rl *ResourceLoading) validate() error {
+	for _, typ := range rl.Types {
+		if _, ok := resourceSelectors[typ]; !ok {
+			return fmt.Errorf("unknown resource type %q", typ)
+		}
+	}
+	for i := range rl.Ignore {
+		if err := rl.Ignore[i].Compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collect the resources referenced by the HTML document in body which was
+// loaded from base.
+func (rl *ResourceLoading) collect(body string, base *url.URL) ([]Resource, error) {
+	doc, err := html.Parse(strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if b := cascadia.MustCompile("base[href]").MatchFirst(doc); b != nil {
+		if u, err := base.Parse(attrValue(b, "href")); err == nil {
+			base = u
+		}
+	}
+
+	types := rl.Types
+	if len(types) == 0 {
+		types = defaultResourceTypes
+	}
+	max := rl.MaxResources
+	if max <= 0 {
+		max = DefaultMaxResources
+	}
+	seen := make(map[string]bool)
+	resources := []Resource{}
+	for _, typ := range types {
+		for _, s := range resourceSelectors[typ] {
+		nextRef:
+			for _, node := range s.sel.MatchAll(doc) {
+				ref := strings.TrimSpace(attrValue(node, s.attr))
+				if ref == "" || strings.HasPrefix(ref, "data:") {
+					continue
+				}
+				u, err := base.Parse(ref)
+				if err != nil {
+					continue
+				}
+				u.Fragment = ""
+				r := u.String()
+				if seen[r] {
+					continue
+				}
+				for _, cond := range rl.Ignore {
+					if cond.Fulfilled(r) == nil {
+						continue nextRef
+					}
+				}
+				seen[r] = true
+				resources = append(resources, Resource{URL: r, Type: typ})
+				if len(resources) == max {
+					return resources, nil
+				}
+			}
+		}
+	}
+	return resources, nil
+}
+
+// attrValue returns the value of the attribute key of node.
+func attrValue(node *html.Node, key string) string {
+	for _, a := range node.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// loadResources loads the resources of the HTML page received by t
+// according to t.LoadResources.
+func (t *Test) loadResources() {
+	rl := t.LoadResources
+	resp := t.Response.Response
+	if rl == nil || resp == nil || t.Response.BodyErr != nil ||
+		!strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		return
+	}
+	base := t.Request.Request.URL
+	if resp.Request != nil && resp.Request.URL != nil {
+		base = resp.Request.URL
+	}
+	resources, err := rl.collect(t.Response.BodyStr, base)
+	if err != nil {
+		t.errorf("Cannot collect resources: %s", err)
+		return
+	}
+
+	timeout := rl.Timeout
+	if timeout <= 0 {
+		timeout = t.Request.Timeout
+	}
+	client := &http.Client{
+		Transport: t.client.Transport,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return hostAllowed(t.AllowedHosts, req.URL)
+		},
+	}
+	if t.Jar != nil {
+		client.Jar = t.Jar
+	}
+	conc := rl.Concurrency
+	if conc <= 0 {
+		conc = DefaultResourceConcurrency
+	}
+
+	t.debugf("Loading %d resources", len(resources))
+	sem := make(chan bool, conc)
+	wg := sync.WaitGroup{}
+	for i := range resources {
+		wg.Add(1)
+		sem <- true
+		go func(r *Resource) {
+			defer func() { <-sem; wg.Done() }()
+			t.loadResource(client, r, resp.Request)
+		}(&resources[i])
+	}
+	wg.Wait()
+	t.Response.Resources = resources
+}
+
+// loadResource loads r with client. The request carries the Referer and
+// the User-Agent of the page request.
+func (t *Test) loadResource(client *http.Client, r *Resource, page *http.Request) {
+	start := time.Now()
+	defer func() {
+		r.Duration = time.Since(start)
+		if r.Error != "" {
+			t.debugf("Resource %s: %s", r.URL, r.Error)
+		} else {
+			t.debugf("Resource %s: %d %d bytes in %s", r.URL, r.StatusCode, r.Size, r.Duration)
+		}
+	}()
+
+	req, err := http.NewRequest(http.MethodGet, r.URL, nil)
+	if err != nil {
+		r.Error = err.Error()
+		return
+	}
+	if err := hostAllowed(t.AllowedHosts, req.URL); err != nil {
+		r.Error = err.Error()
+		return
+	}
+	req = req.WithContext(t.context())
+	req.Header.Set("User-Agent", t.Request.Request.Header.Get("User-Agent"))
+	if page != nil && page.URL != nil {
+		req.Header.Set("Referer", page.URL.String())
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		r.Error = err.Error()
+		return
+	}
+	defer resp.Body.Close()
+	r.StatusCode = resp.StatusCode
+	r.ContentType = resp.Header.Get("Content-Type")
+	r.Size, err = io.Copy(ioutil.Discard, resp.Body)
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Assets
+
+// Assets checks the resources of a HTML page loaded like a browser does via
+// the LoadResources field of the test. All resources must be loaded without
+// error and with a status code below 400.
+type Assets struct {
+	// AllowBroken turns off the check that all resources were loaded
+	// successfully.
+	AllowBroken bool `json:",omitempty"`
+
+	// MaxWeight is the maximal size of the page and all its resources
+	// in bytes. Zero means unlimited.
+	MaxWeight int64 `json:",omitempty"`
+
+	// MaxCount is the maximal number of resources. Zero means unlimited.
+	MaxCount int `json:",omitempty"`
+
+	// MaxTime is the maximal time to load the slowest resource.
+	// Zero means unlimited.
+	MaxTime time.Duration `json:",omitempty"`
+
+	// SameOrigin requires that all resources are loaded from the
+	// origin (scheme, host and port) of the page.
+	SameOrigin bool `json:",omitempty"`
+}
+
+// Prepare implements Check's Prepare method.
+func (a Assets) Prepare(t *Test) error {
+	if t.LoadResources == nil {
+		return errors.New("Assets needs LoadResources in the test")
+	}
+	return nil
+}
+
+var _ Preparable = Assets{}
+
+// Execute implements Check's Execute method.
+func (a Assets) Execute(t *Test) error {
+	if t.Response.BodyErr != nil {
+		return ErrBadBody
+	}
+	var origin *url.URL
+	if resp := t.Response.Response; resp != nil && resp.Request != nil {
+		origin = resp.Request.URL
+	}
+
+	el := errorlist.List{}
+	weight := t.Response.BodySize
+	for _, r := range t.Response.Resources {
+		weight += r.Size
+		if !a.AllowBroken {
+			if r.Error != "" {
+				el = append(el, fmt.Errorf("%s %s: %s", r.Type, r.URL, r.Error))
+			} else if r.StatusCode >= 400 {
+				el = append(el, fmt.Errorf("%s %s: status %d", r.Type, r.URL, r.StatusCode))
+			}
+		}
+		if a.MaxTime > 0 && r.Duration > a.MaxTime {
+			el = append(el, fmt.Errorf("%s %s: took %s (allowed %s)",
+				r.Type, r.URL, r.Duration, a.MaxTime))
+		}
+		if a.SameOrigin && origin != nil {
+			if u, err := url.Parse(r.URL); err == nil &&
+				(u.Scheme != origin.Scheme || u.Host != origin.Host) {
+				el = append(el, fmt.Errorf("%s %s: not same origin", r.Type, r.URL))
+			}
+		}
+	}
+	if a.MaxCount > 0 && len(t.Response.Resources) > a.MaxCount {
+		el = append(el, fmt.Errorf("got %d resources, allowed %d",
+			len(t.Response.Resources), a.MaxCount))
+	}
+	if a.MaxWeight > 0 && weight > a.MaxWeight {
+		el = append(el, fmt.Errorf("page weight %d bytes exceeds %d bytes",
+			weight, a.MaxWeight))
+	}
+	if len(el) > 0 {
+		return el
+	}
+	return nil
+}
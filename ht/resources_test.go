@@ -0,0 +1,136 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/vdobler/ht/errorlist"
+)
+
+const resourcePage = `<!DOCTYPE html>
+<html><head>
+  <link rel="stylesheet" href="/css/main.css">
+  <link rel="icon" href="/favicon.ico">
+  <script src="/js/app.js"></script>
+  <script>var inline = true;</script>
+</head><body>
+  <img src="/img/logo.png">
+  <img src="/img/logo.png#again">
+  <img src="data:image/gif;base64,R0lGODlhAQABAAAAACw=">
+  <img src="/img/missing.png">
+  <img src="http://cdn.example.org/pixel.gif">
+  <video src="/media/clip.mp4"></video>
+</body></html>`
+
+func TestLoadResources(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(resourcePage))
+		case "/img/missing.png":
+			http.NotFound(w, r)
+		default:
+			if r.Header.Get("Referer") == "" {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+			w.Write([]byte(strings.Repeat("x", 100)))
+		}
+	}))
+	defer ts.Close()
+
+	test := Test{
+		Request: Request{URL: ts.URL + "/"},
+		LoadResources: &ResourceLoading{
+			Ignore: []Condition{{Contains: "cdn.example.org"}},
+		},
+		Checks: CheckList{
+			StatusCode{Expect: 200},
+			Assets{MaxCount: 3, MaxWeight: 400},
+		},
+	}
+	test.Run()
+	if test.Result.Status != Fail {
+		t.Fatalf("Got status %s: %v", test.Result.Status, test.Result.Error)
+	}
+
+	got := []string{}
+	for _, r := range test.Response.Resources {
+		got = append(got, r.Type+" "+strings.TrimPrefix(r.URL, ts.URL))
+		if r.Duration <= 0 {
+			t.Errorf("Missing duration for %s", r.URL)
+		}
+	}
+	sort.Strings(got)
+	want := "image /favicon.ico, image /img/logo.png, image /img/missing.png, " +
+		"script /js/app.js, stylesheet /css/main.css"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("Got resources %s", strings.Join(got, ", "))
+	}
+
+	details := errorlist.Details(test.Result.Error)
+	if len(details) != 3 {
+		t.Fatalf("Got details %v", details)
+	}
+	msg := test.Result.Error.Error()
+	for _, w := range []string{
+		"image " + ts.URL + "/img/missing.png: status 404",
+		"got 5 resources, allowed 3",
+		"exceeds 400 bytes",
+	} {
+		if !strings.Contains(msg, w) {
+			t.Errorf("Missing %q in %s", w, msg)
+		}
+	}
+}
+
+func TestAssets(t *testing.T) {
+	page := &http.Response{Request: &http.Request{}}
+	page.Request.URL, _ = page.Request.URL.Parse("https://www.example.org/index.html")
+	resources := []Resource{
+		{URL: "https://www.example.org/a.css", Type: "stylesheet", StatusCode: 200, Size: 100},
+		{URL: "https://cdn.example.org/b.js", Type: "script", StatusCode: 200, Size: 200},
+		{URL: "https://www.example.org/c.png", Type: "image", Error: "timeout"},
+	}
+	for i, tc := range []struct {
+		check Assets
+		err   bool
+	}{
+		{Assets{AllowBroken: true}, false},
+		{Assets{}, true},
+		{Assets{AllowBroken: true, MaxWeight: 350}, false},
+		{Assets{AllowBroken: true, MaxWeight: 300}, true},
+		{Assets{AllowBroken: true, SameOrigin: true}, true},
+		{Assets{AllowBroken: true, MaxCount: 3}, false},
+		{Assets{AllowBroken: true, MaxCount: 2}, true},
+	} {
+		test := &Test{
+			LoadResources: &ResourceLoading{},
+			Response: Response{
+				Response:  page,
+				BodySize:  50,
+				Resources: resources,
+			},
+		}
+		if err := tc.check.Prepare(test); err != nil {
+			t.Fatalf("%d: unexpected error %s", i, err)
+		}
+		err := tc.check.Execute(test)
+		if tc.err && err == nil {
+			t.Errorf("%d: missing error", i)
+		} else if !tc.err && err != nil {
+			t.Errorf("%d: unexpected error %s", i, err)
+		}
+	}
+
+	if err := (Assets{}).Prepare(&Test{}); err == nil {
+		t.Errorf("Missing error for test without LoadResources")
+	}
+}
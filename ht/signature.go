@@ -0,0 +1,248 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// signature.go contains the HMAC signing of requests.
+
+package ht
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signature describes how a request is signed with a HMAC, e.g. for APIs
+// authenticated by a custom X-Signature header or by HTTP Signatures.
+//
+// The string to sign and the value of the signature header are templates
+// with placeholders in single braces:
+//     {method}              the request method, e.g. POST
+//     {request-target}      lowercase method and path with query, e.g.
+//                           "post /api/items?limit=5"
+//     {host}                the host (and port) of the request
+//     {path}                the path of the request URL
+//     {query}               the raw query of the request URL
+//     {uri}                 path and query of the request URL
+//     {date}                the Date header; it is set to the current
+//                           time unless the Header of the Request
+//                           contains a Date
+//     {timestamp}           the current time as Unix seconds
+//     {body}                the request body
+//     {body-sha256}         the hex encoded SHA-256 hash of the body
+//     {body-sha256-base64}  the base64 encoded SHA-256 hash of the body
+//     {body-md5}            the hex encoded MD5 hash of the body
+//     {header:<Name>}       the value of the request header Name
+//     {signature}           the signature (only in Format)
+// A HTTP Signature (draft-cavage-http-signatures) can be produced by
+//     Signature: {
+//         Key: "{{SECRET}}"
+//         Digest: true
+//         StringToSign: "(request-target): {request-target}\nhost: {host}\ndate: {date}\ndigest: {header:Digest}"
+//         Header: "Authorization"
+//         Format: "Signature keyId=\"{{KEY_ID}}\",algorithm=\"hmac-sha256\",headers=\"(request-target) host date digest\",signature=\"{signature}\""
+//     }
+//
+// The signature is computed for each try after the body has been
+// finalised and the PreRequest hook has been called.
+type Signature struct {
+	// Algorithm is the HMAC algorithm: "hmac-sha1", "hmac-sha256"
+	// or "hmac-sha512". The empty string means "hmac-sha256".
+	Algorithm string `json:",omitempty"`
+
+	// Key is the secret key. It typically references a variable like
+	// "{{API_SECRET}}". Keys of the form "base64:<data>" are base64
+	// decoded.
+	Key string
+
+	// StringToSign is the template of the string to sign. The empty
+	// string means "{method}\n{uri}\n{date}\n{body-sha256}".
+	StringToSign string `json:",omitempty"`
+
+	// Header is the request header which receives the signature. The
+	// empty string means "X-Signature".
+	Header string `json:",omitempty"`
+
+	// Format is the template of the header value. The empty string
+	// means "{signature}".
+	Format string `json:",omitempty"`
+
+	// Encoding of the signature: "base64", "base64url" or "hex".
+	// The empty string means "base64".
+	Encoding string `json:",omitempty"`
+
+	// Digest sets the Digest header of the request to the base64 encoded
+	// SHA-256 hash of the body, e.g. to be signed via {header:Digest}.
+	Digest bool `json:",omitempty"`
+}
+
+// DefaultStringToSign is the default StringToSign of a Signature.
+const DefaultStringToSign = "{method}\n{uri}\n{date}\n{body-sha256}"
+
+var signaturePlaceholderRe = regexp.MustCompile(`\{([a-z0-9-]+(:[^{}]+)?)\}`)
+
+// validate checks the configuration of s.
+func (s *Signature) validate() error {
+	if s.Key == "" {
+		return errors.New("missing Key")
+	}
+	if _, err := s.key(); err != nil {
+		return err
+	}
+	if _, err := s.hash(); err != nil {
+		return err
+	}
+	switch s.Encoding {
+	case "", "base64", "base64url", "hex":
+	default:
+		return fmt.Errorf("unknown Encoding %q", s.Encoding)
+	}
+	for _, tmpl := range []string{s.StringToSign, s.Format} {
+		for _, m := range signaturePlaceholderRe.FindAllStringSubmatch(tmpl, -1) {
+			if _, ok := signaturePlaceholders[m[1]]; !ok && !strings.HasPrefix(m[1], "header:") {
+				return fmt.Errorf("unknown placeholder %s", m[0])
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Signature) key() ([]byte, error) {
+	if strings.HasPrefix(s.Key, "base64:") {
+		key, err := decodeBase64(s.Key[len("base64:"):])
+		if err != nil {
+			return nil, fmt.Errorf("bad Key: %s", err)
+		}
+		return key, nil
+	}
+	return []byte(s.Key), nil
+}
+
+func (s *Signature) hash() (func() hash.Hash, error) {
+	switch s.Algorithm {
+	case "hmac-sha1":
+		return sha1.New, nil
+	case "", "hmac-sha256":
+		return sha256.New, nil
+	case "hmac-sha512":
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("unknown Algorithm %q", s.Algorithm)
+}
+
+// signatureData is the data available to the placeholders of a Signature.
+type signatureData struct {
+	req       *http.Request
+	body      string
+	now       time.Time
+	signature string
+}
+
+var signaturePlaceholders = map[string]func(d signatureData) string{
+	"method": func(d signatureData) string { return d.req.Method },
+	"request-target": func(d signatureData) string {
+		return strings.ToLower(d.req.Method) + " " + d.req.URL.RequestURI()
+	},
+	"host":  func(d signatureData) string { return d.req.Host },
+	"path":  func(d signatureData) string { return d.req.URL.EscapedPath() },
+	"query": func(d signatureData) string { return d.req.URL.RawQuery },
+	"uri":   func(d signatureData) string { return d.req.URL.RequestURI() },
+	"date":  func(d signatureData) string { return d.req.Header.Get("Date") },
+	"timestamp": func(d signatureData) string {
+		return strconv.FormatInt(d.now.Unix(), 10)
+	},
+	"body": func(d signatureData) string { return d.body },
+	"body-sha256": func(d signatureData) string {
+		sum := sha256.Sum256([]byte(d.body))
+		return hex.EncodeToString(sum[:])
+	},
+	"body-sha256-base64": func(d signatureData) string {
+		sum := sha256.Sum256([]byte(d.body))
+		return base64.StdEncoding.EncodeToString(sum[:])
+	},
+	"body-md5": func(d signatureData) string {
+		sum := md5.Sum([]byte(d.body))
+		return hex.EncodeToString(sum[:])
+	},
+	"signature": func(d signatureData) string { return d.signature },
+}
+
+// expand replaces the placeholders in tmpl.
+func (d signatureData) expand(tmpl string) string {
+	return signaturePlaceholderRe.ReplaceAllStringFunc(tmpl, func(m string) string {
+		name := m[1 : len(m)-1]
+		if strings.HasPrefix(name, "header:") {
+			return d.req.Header.Get(name[len("header:"):])
+		}
+		if f, ok := signaturePlaceholders[name]; ok {
+			return f(d)
+		}
+		return m
+	})
+}
+
+// signRequest adds the signature described by t.Request.Signature to the
+// HTTP request of t.
+func (t *Test) signRequest() error {
+	s := t.Request.Signature
+	req := t.Request.Request
+	if s == nil || req == nil || (req.URL.Scheme != "http" && req.URL.Scheme != "https") {
+		return nil
+	}
+	key, err := s.key()
+	if err != nil {
+		return err
+	}
+	h, err := s.hash()
+	if err != nil {
+		return err
+	}
+
+	d := signatureData{req: req, body: t.Request.SentBody, now: time.Now()}
+	stringToSign, format := s.StringToSign, s.Format
+	if stringToSign == "" {
+		stringToSign = DefaultStringToSign
+	}
+	if format == "" {
+		format = "{signature}"
+	}
+	if t.Request.Header.Get("Date") == "" &&
+		(strings.Contains(stringToSign, "{date}") || strings.Contains(format, "{date}")) {
+		req.Header.Set("Date", d.now.UTC().Format(http.TimeFormat))
+	}
+	if s.Digest {
+		req.Header.Set("Digest", "SHA-256="+signaturePlaceholders["body-sha256-base64"](d))
+	}
+
+	mac := hmac.New(h, key)
+	mac.Write([]byte(d.expand(stringToSign)))
+	sum := mac.Sum(nil)
+	switch s.Encoding {
+	case "hex":
+		d.signature = hex.EncodeToString(sum)
+	case "base64url":
+		d.signature = base64.RawURLEncoding.EncodeToString(sum)
+	default:
+		d.signature = base64.StdEncoding.EncodeToString(sum)
+	}
+
+	header := s.Header
+	if header == "" {
+		header = "X-Signature"
+	}
+	req.Header.Set(header, d.expand(format))
+	t.debugf("Signed request in header %s", header)
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vdobler/ht/errorlist"
+)
+
+func TestSignature(t *testing.T) {
+	var got *http.Request
+	var gotBody string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		got, gotBody = r, string(body)
+	}))
+	defer ts.Close()
+
+	hmac256 := func(key, msg string) []byte {
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(msg))
+		return mac.Sum(nil)
+	}
+
+	test := Test{
+		Request: Request{
+			Method: "POST",
+			URL:    ts.URL + "/api/items?limit=5",
+			Body:   `{"name": "foo"}`,
+			Signature: &Signature{
+				Key: "secret",
+			},
+		},
+		Checks: CheckList{StatusCode{Expect: 200}},
+	}
+	test.Run()
+	if test.Result.Status != Pass {
+		t.Fatalf("Got status %s: %v", test.Result.Status, test.Result.Error)
+	}
+	bodyHash := sha256.Sum256([]byte(gotBody))
+	toSign := "POST\n/api/items?limit=5\n" + got.Header.Get("Date") + "\n" + hex.EncodeToString(bodyHash[:])
+	if want := base64.StdEncoding.EncodeToString(hmac256("secret", toSign)); got.Header.Get("X-Signature") != want {
+		t.Errorf("Got X-Signature %q, want %q", got.Header.Get("X-Signature"), want)
+	}
+	if got.Header.Get("Date") == "" {
+		t.Errorf("Missing Date header")
+	}
+
+	// HTTP Signatures with a Digest header.
+	test.Request.Signature = &Signature{
+		Key:          "secret",
+		Digest:       true,
+		StringToSign: "(request-target): {request-target}\nhost: {host}\ndigest: {header:Digest}",
+		Header:       "Authorization",
+		Format:       `Signature keyId="k1",algorithm="hmac-sha256",headers="(request-target) host digest",signature="{signature}"`,
+	}
+	test.Run()
+	if test.Result.Status != Pass {
+		t.Fatalf("Got status %s: %v", test.Result.Status, test.Result.Error)
+	}
+	digest := "SHA-256=" + base64.StdEncoding.EncodeToString(bodyHash[:])
+	if got.Header.Get("Digest") != digest {
+		t.Errorf("Got Digest %q, want %q", got.Header.Get("Digest"), digest)
+	}
+	toSign = "(request-target): post /api/items?limit=5\nhost: " + got.Host + "\ndigest: " + digest
+	want := `Signature keyId="k1",algorithm="hmac-sha256",headers="(request-target) host digest",signature="` +
+		base64.StdEncoding.EncodeToString(hmac256("secret", toSign)) + `"`
+	if auth := got.Header.Get("Authorization"); auth != want {
+		t.Errorf("Got Authorization\n%s\nwant\n%s", auth, want)
+	}
+
+	// Custom header with hex encoded HMAC-SHA512 and a binary key.
+	test.Request.Signature = &Signature{
+		Algorithm:    "hmac-sha512",
+		Key:          "base64:" + base64.StdEncoding.EncodeToString([]byte("\x00\x01\x02")),
+		StringToSign: "{method}:{path}:{query}:{header:X-Client}",
+		Encoding:     "hex",
+		Header:       "X-Api-Signature",
+	}
+	test.Request.Header = http.Header{"X-Client": {"ht"}}
+	test.Run()
+	if test.Result.Status != Pass {
+		t.Fatalf("Got status %s: %v", test.Result.Status, test.Result.Error)
+	}
+	mac := hmac.New(sha512.New, []byte("\x00\x01\x02"))
+	mac.Write([]byte("POST:/api/items:limit=5:ht"))
+	if want := hex.EncodeToString(mac.Sum(nil)); got.Header.Get("X-Api-Signature") != want {
+		t.Errorf("Got X-Api-Signature %q, want %q", got.Header.Get("X-Api-Signature"), want)
+	}
+}
+
+func TestSignatureValidation(t *testing.T) {
+	for i, tc := range []struct {
+		sig Signature
+		err string
+	}{
+		{Signature{}, "missing Key"},
+		{Signature{Key: "k", Algorithm: "hmac-md4"}, `unknown Algorithm "hmac-md4"`},
+		{Signature{Key: "k", Encoding: "base32"}, `unknown Encoding "base32"`},
+		{Signature{Key: "k", StringToSign: "{method} {verb}"}, "unknown placeholder {verb}"},
+		{Signature{Key: "base64:!!"}, "bad Key"},
+	} {
+		sig := tc.sig
+		test := Test{
+			Request: Request{URL: "http://www.example.org", Signature: &sig},
+		}
+		err := test.Run()
+		if test.Result.Status != Bogus || err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%d: got %s %v, want %s", i, test.Result.Status, err, tc.err)
+		}
+	}
+}
+
+func TestSignatureFailure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	test := Test{
+		Request: Request{URL: ts.URL, Signature: &Signature{Key: "k"}},
+		Hooks: &Hooks{PreRequestFunc: func(t *Test) error {
+			t.Request.Signature.Key = "base64:!!"
+			return nil
+		}},
+	}
+	test.Run()
+	details := errorlist.Details(test.Result.Error)
+	if test.Result.Status != Bogus || len(details) != 1 ||
+		details[0].Code != "request.signature" || details[0].Category != errorlist.Bogus {
+		t.Errorf("Got %s %+v", test.Result.Status, details)
+	}
+}
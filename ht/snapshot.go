@@ -0,0 +1,306 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// snapshot.go contains the comparison of responses against recorded baselines.
+
+package ht
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+func init() {
+	RegisterCheck(&Snapshot{})
+}
+
+// UpdateSnapshots makes the Snapshot check (re)write its baseline files
+// instead of comparing the response against them.
+var UpdateSnapshots = false
+
+// MaskedValue replaces the values of masked JSON elements in snapshots.
+const MaskedValue = "<masked>"
+
+// Snapshot compares the response against a baseline recorded in a file.
+// If the baseline file does not exist (or if UpdateSnapshots is set) the
+// snapshot of the actual response is recorded as the new baseline and
+// the check passes. Otherwise the check fails with a line diff between
+// the baseline and the actual snapshot.
+//
+// A snapshot of a HTML page consists of the DOM regions selected by Select
+// and/or the list of links and assets on the page. A snapshot of a JSON
+// document is the indented JSON where the volatile elements listed in Mask
+// are replaced by "<masked>". Other responses are snapshot as is.
+type Snapshot struct {
+	// Baseline is the file path of the baseline, typically relative to the
+	// test like in "{{TEST_DIR}}/snapshots/homepage.snap".
+	Baseline string
+
+	// Select is a list of CSS selectors of the DOM regions of a HTML
+	// page to record.
+	Select []string `json:",omitempty"`
+
+	// Links records the (unresolved) targets of all links, images,
+	// scripts, stylesheets etc. of a HTML page.
+	Links bool `json:",omitempty"`
+
+	// Mask is the list of volatile elements of a JSON document like
+	// timestamps or IDs whose values are masked. The elements are given
+	// in the notation of the JSON check, a "*" matches all array
+	// elements or object members, e.g. "items.*.id".
+	Mask []string `json:",omitempty"`
+
+	// Sep is the separator in Mask. A zero value is equivalent to ".".
+	Sep string `json:",omitempty"`
+
+	sel []cascadia.Selector
+}
+
+// Prepare implements Check's Prepare method.
+func (s *Snapshot) Prepare(*Test) error {
+	if s.Baseline == "" {
+		return errors.New("missing Baseline")
+	}
+	if len(s.Mask) > 0 && (len(s.Select) > 0 || s.Links) {
+		return errors.New("Mask cannot be combined with Select or Links")
+	}
+	s.sel = nil
+	for _, sel := range s.Select {
+		cs, err := cascadia.Compile(sel)
+		if err != nil {
+			return fmt.Errorf("bad selector %q: %s", sel, err)
+		}
+		s.sel = append(s.sel, cs)
+	}
+	return nil
+}
+
+var _ Preparable = &Snapshot{}
+
+// Execute implements Check's Execute method.
+func (s *Snapshot) Execute(t *Test) error {
+	if t.Response.BodyErr != nil {
+		return ErrBadBody
+	}
+	actual, err := s.snapshot(t)
+	if err != nil {
+		return CantCheck{err}
+	}
+
+	baseline, err := ioutil.ReadFile(s.Baseline)
+	if err == nil && !UpdateSnapshots {
+		if diff := lineDiff(string(baseline), actual); diff != "" {
+			return fmt.Errorf("snapshot differs from baseline %s (-baseline +actual):\n%s",
+				s.Baseline, diff)
+		}
+		return nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.Baseline), 0766); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(s.Baseline, []byte(actual), 0666); err != nil {
+		return err
+	}
+	t.infof("Recorded snapshot baseline %s", s.Baseline)
+	return nil
+}
+
+// snapshot produces the snapshot of the response of t.
+func (s *Snapshot) snapshot(t *Test) (string, error) {
+	if len(s.sel) > 0 || s.Links {
+		return s.htmlSnapshot(t.Response.BodyStr)
+	}
+	if len(s.Mask) > 0 || strings.Contains(t.Response.contentType(), "json") {
+		return s.jsonSnapshot(t.Response.BodyStr)
+	}
+	return t.Response.BodyStr, nil
+}
+
+// htmlSnapshot renders the selected regions and the links of body.
+func (s *Snapshot) htmlSnapshot(body string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	for i, sel := range s.sel {
+		fmt.Fprintf(buf, "=== %s\n", s.Select[i])
+		for _, node := range sel.MatchAll(doc) {
+			if err := html.Render(buf, node); err != nil {
+				return "", err
+			}
+			buf.WriteString("\n")
+		}
+	}
+	if s.Links {
+		buf.WriteString("=== Links\n")
+		tags := []string{"a", "link", "img", "script", "video", "audio", "source", "iframe"}
+		selector := cascadia.MustCompile(strings.Join(tags, ", "))
+		for _, node := range selector.MatchAll(doc) {
+			ref := attrValue(node, linkURLattr[node.Data].attr)
+			if ref = strings.TrimSpace(ref); ref != "" {
+				fmt.Fprintf(buf, "%s %s\n", node.Data, ref)
+			}
+		}
+	}
+	return buf.String(), nil
+}
+
+// jsonSnapshot returns body as indented JSON with the Mask elements masked.
+func (s *Snapshot) jsonSnapshot(body string) (string, error) {
+	var v interface{}
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+	sep := s.Sep
+	if sep == "" {
+		sep = "."
+	}
+	for _, mask := range s.Mask {
+		v = maskJSON(v, strings.Split(mask, sep))
+	}
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// maskJSON replaces the elements of v selected by path with MaskedValue.
+func maskJSON(v interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return MaskedValue
+	}
+	elem, rest := path[0], path[1:]
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for key, val := range x {
+			if elem == "*" || elem == key {
+				x[key] = maskJSON(val, rest)
+			}
+		}
+	case []interface{}:
+		for i, val := range x {
+			if elem == "*" || elem == fmt.Sprint(i) {
+				x[i] = maskJSON(val, rest)
+			}
+		}
+	}
+	return v
+}
+
+// lineDiff returns a unified-style diff of the lines of a and b with
+// two lines of context or the empty string if a equals b.
+func lineDiff(a, b string) string {
+	if a == b {
+		return ""
+	}
+	al := strings.Split(strings.TrimSuffix(a, "\n"), "\n")
+	bl := strings.Split(strings.TrimSuffix(b, "\n"), "\n")
+
+	// Too large for the quadratic LCS: Report the first differing line.
+	if len(al)*len(bl) > 4000000 {
+		i := 0
+		for i < len(al) && i < len(bl) && al[i] == bl[i] {
+			i++
+		}
+		diff := fmt.Sprintf("first difference in line %d\n", i+1)
+		if i < len(al) {
+			diff += "-" + al[i] + "\n"
+		}
+		if i < len(bl) {
+			diff += "+" + bl[i] + "\n"
+		}
+		return diff
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of
+	// al[i:] and bl[j:].
+	lcs := make([][]int, len(al)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bl)+1)
+	}
+	for i := len(al) - 1; i >= 0; i-- {
+		for j := len(bl) - 1; j >= 0; j-- {
+			if al[i] == bl[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type line struct {
+		op   byte
+		text string
+	}
+	lines := []line{}
+	i, j := 0, 0
+	for i < len(al) || j < len(bl) {
+		switch {
+		case i < len(al) && j < len(bl) && al[i] == bl[j]:
+			lines = append(lines, line{' ', al[i]})
+			i++
+			j++
+		case j < len(bl) && (i == len(al) || lcs[i][j+1] > lcs[i+1][j]):
+			lines = append(lines, line{'+', bl[j]})
+			j++
+		default:
+			lines = append(lines, line{'-', al[i]})
+			i++
+		}
+	}
+
+	const context = 2
+	show := make([]bool, len(lines))
+	for k, l := range lines {
+		if l.op == ' ' {
+			continue
+		}
+		for m := k - context; m <= k+context; m++ {
+			if m >= 0 && m < len(lines) {
+				show[m] = true
+			}
+		}
+	}
+	buf := &bytes.Buffer{}
+	for k, l := range lines {
+		if !show[k] {
+			if k > 0 && show[k-1] {
+				buf.WriteString("...\n")
+			}
+			continue
+		}
+		if k > 0 && !show[k-1] && buf.Len() == 0 {
+			buf.WriteString("...\n")
+		}
+		buf.WriteByte(l.op)
+		buf.WriteString(l.text)
+		buf.WriteByte('\n')
+	}
+	if buf.Len() == 0 {
+		return "differs in trailing newline\n"
+	}
+	return buf.String()
+}
@@ -0,0 +1,113 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	page := `<html><body><div id="nav"><a href="/home">Home</a></div>
+<p>Generated at 12:34</p><img src="/logo.png"></body></html>`
+	jsonBody := `{"id": 123, "items": [{"id": 1, "name": "a"}, {"id": 2, "name": "b"}], "total": 2.50}`
+	html := func(body string) Response {
+		return Response{Response: &http.Response{Header: http.Header{
+			"Content-Type": {"text/html"}}}, BodyStr: body}
+	}
+	jsn := func(body string) Response {
+		return Response{Response: &http.Response{Header: http.Header{
+			"Content-Type": {"application/json"}}}, BodyStr: body}
+	}
+	htmlFile := filepath.Join(dir, "sub", "page.snap")
+	jsonFile := filepath.Join(dir, "api.snap")
+
+	for i, tc := range []TC{
+		// First runs record the baselines.
+		{html(page), &Snapshot{Baseline: htmlFile, Select: []string{"#nav"}, Links: true}, nil},
+		{jsn(jsonBody), &Snapshot{Baseline: jsonFile, Mask: []string{"id", "items.*.id"}}, nil},
+
+		// Volatile parts of the page and masked JSON elements do not matter.
+		{html(strings.Replace(page, "12:34", "23:45", 1)),
+			&Snapshot{Baseline: htmlFile, Select: []string{"#nav"}, Links: true}, nil},
+		{jsn(`{"id": 999, "items": [{"id": 7, "name": "a"}, {"id": 8, "name": "b"}], "total": 2.50}`),
+			&Snapshot{Baseline: jsonFile, Mask: []string{"id", "items.*.id"}}, nil},
+
+		// Changes in the snapshot fail.
+		{html(strings.Replace(page, "/logo.png", "/logo2.png", 1)),
+			&Snapshot{Baseline: htmlFile, Select: []string{"#nav"}, Links: true}, errCheck},
+		{html(strings.Replace(page, "Home", "Start", 1)),
+			&Snapshot{Baseline: htmlFile, Select: []string{"#nav"}, Links: true}, errCheck},
+		{jsn(`{"id": 123, "items": [{"id": 1, "name": "a"}], "total": 2.50}`),
+			&Snapshot{Baseline: jsonFile, Mask: []string{"id", "items.*.id"}}, errCheck},
+		{jsn(`{"id": 123, "items": [{"id": 1, "name": "a"}, {"id": 2, "name": "b"}], "total": 2.5}`),
+			&Snapshot{Baseline: jsonFile, Mask: []string{"id", "items.*.id"}}, errCheck},
+
+		// Malformed JSON cannot be snapshot.
+		{jsn(`{"id": `), &Snapshot{Baseline: jsonFile}, errCheck},
+	} {
+		runTest(t, i, tc)
+	}
+
+	baseline, err := ioutil.ReadFile(jsonFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(baseline), `"id": "<masked>"`) ||
+		!strings.Contains(string(baseline), `"total": 2.50`) {
+		t.Errorf("Bad baseline:\n%s", baseline)
+	}
+
+	// Updating the snapshots records a new baseline.
+	UpdateSnapshots = true
+	changed := &Snapshot{Baseline: htmlFile, Links: true}
+	runTest(t, 100, TC{html(strings.Replace(page, "/logo.png", "/logo2.png", 1)), changed, nil})
+	UpdateSnapshots = false
+	runTest(t, 101, TC{html(strings.Replace(page, "/logo.png", "/logo2.png", 1)), changed, nil})
+	baseline, err = ioutil.ReadFile(htmlFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "=== Links\na /home\nimg /logo2.png\n"; string(baseline) != want {
+		t.Errorf("Got baseline %q, want %q", baseline, want)
+	}
+}
+
+func TestSnapshotDiff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "text.snap")
+	err = ioutil.WriteFile(file, []byte("a\nb\nc\nd\ne\nf\ng\nh\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test := &Test{Response: Response{BodyStr: "a\nb\nc\nd\nE\nf\ng\nh\n"}}
+	check := &Snapshot{Baseline: file}
+	if err := check.Prepare(test); err != nil {
+		t.Fatal(err)
+	}
+	err = check.Execute(test)
+	if err == nil {
+		t.Fatal("Missing error")
+	}
+	want := "(-baseline +actual):\n...\n c\n d\n-e\n+E\n f\n g\n...\n"
+	if !strings.HasSuffix(err.Error(), want) {
+		t.Errorf("Got %s\nwant suffix %s", err, want)
+	}
+}
@@ -18,10 +18,86 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// ----------------------------------------------------------------------------
+// Status mapping of pseudo-requests
+
+// validateStatusMapping checks the outcomes and codes of a StatusMapping.
+func validateStatusMapping(mapping map[string]int) error {
+	for outcome, code := range mapping {
+		if code < 100 || code > 999 {
+			return fmt.Errorf("status code %d for %q out of range", code, outcome)
+		}
+		kind, arg := outcome, ""
+		if i := strings.Index(outcome, ":"); i != -1 {
+			kind, arg = outcome[:i], outcome[i+1:]
+		}
+		ok := false
+		switch kind {
+		case "ok", "timeout", "error", "notexist", "permission", "exist":
+			ok = arg == "" && kind == outcome
+		case "exit":
+			_, err := strconv.Atoi(arg)
+			ok = arg == "*" || err == nil
+		case "sqlstate":
+			ok = len(arg) == 2 || len(arg) == 5
+		}
+		if !ok {
+			return fmt.Errorf("unknown outcome %q", outcome)
+		}
+	}
+	return nil
+}
+
+// mappedStatus returns the status code the first of outcomes is mapped to
+// in the StatusMapping of t.
+func (t *Test) mappedStatus(outcomes ...string) (int, bool) {
+	for _, outcome := range outcomes {
+		if code, ok := t.Request.StatusMapping[outcome]; ok {
+			return code, true
+		}
+	}
+	return 0, false
+}
+
+// setPseudoStatus sets the status of the faked response of a pseudo-request
+// to the one of the outcomes mapped or to status like "404 Not Found".
+func (t *Test) setPseudoStatus(status string, outcomes ...string) {
+	if code, ok := t.mappedStatus(outcomes...); ok {
+		status = fmt.Sprintf("%d %s", code, http.StatusText(code))
+	}
+	t.Response.Response.Status = strings.TrimSpace(status)
+	t.Response.Response.StatusCode, _ = strconv.Atoi(status[:3])
+}
+
+// fsOutcomes returns the outcomes of a failed file operation.
+func fsOutcomes(err error) []string {
+	switch {
+	case os.IsNotExist(err):
+		return []string{"notexist", "error"}
+	case os.IsPermission(err):
+		return []string{"permission", "error"}
+	case os.IsExist(err):
+		return []string{"exist", "error"}
+	}
+	return []string{"error"}
+}
+
+// sqlOutcomes returns the outcomes of a failed SQL statement. The SQLSTATE
+// is available for drivers whose errors provide a SQLState method.
+func sqlOutcomes(err error) []string {
+	if e, ok := err.(interface{ SQLState() string }); ok {
+		if state := e.SQLState(); len(state) == 5 {
+			return []string{"sqlstate:" + state, "sqlstate:" + state[:2], "error"}
+		}
+	}
+	return []string{"error"}
+}
+
 // ----------------------------------------------------------------------------
 // file:// pseudo-request
 
@@ -104,12 +180,12 @@ func (t *Test) executeFileGET(u *url.URL) {
 	filename := localFilename(u.Path)
 	file, err := os.Open(filename)
 	if err != nil {
-		t.Response.Response.Status = "404 Not Found"
-		t.Response.Response.StatusCode = 404
+		t.setPseudoStatus("404 Not Found", fsOutcomes(err)...)
 		t.Response.BodyStr = err.Error()
 		return
 	}
 	defer file.Close()
+	t.setPseudoStatus("200 OK", "ok")
 	body, err := ioutil.ReadAll(file)
 	t.Response.BodyStr = string(body)
 	t.Response.BodyErr = err
@@ -121,13 +197,11 @@ func (t *Test) executeFilePUT(u *url.URL) {
 	filename := localFilename(u.Path)
 	err := ioutil.WriteFile(filename, []byte(t.Request.Body), 0666)
 	if err != nil {
-		t.Response.Response.Status = "403 Forbidden"
-		t.Response.Response.StatusCode = 403
+		t.setPseudoStatus("403 Forbidden", fsOutcomes(err)...)
 		t.Response.BodyStr = err.Error()
 		return
 	}
-	t.Response.Response.Status = "200 OK"
-	t.Response.Response.StatusCode = 200
+	t.setPseudoStatus("200 OK", "ok")
 	t.Response.BodyStr = fmt.Sprintf("Successfully wrote %s", u)
 	t.Response.BodyErr = nil
 }
@@ -139,21 +213,18 @@ func (t *Test) executeFileDELETE(u *url.URL) {
 	filename := localFilename(u.Path)
 	_, err := os.Stat(filename)
 	if err != nil {
-		t.Response.Response.Status = "404 Not Found"
-		t.Response.Response.StatusCode = 404
+		t.setPseudoStatus("404 Not Found", fsOutcomes(err)...)
 		t.Response.BodyStr = err.Error()
 		return
 	}
 
 	err = os.Remove(filename)
 	if err != nil {
-		t.Response.Response.Status = "403 Forbidden"
-		t.Response.Response.StatusCode = 403
+		t.setPseudoStatus("403 Forbidden", fsOutcomes(err)...)
 		t.Response.BodyStr = err.Error()
 		return
 	}
-	t.Response.Response.Status = "200 OK"
-	t.Response.Response.StatusCode = 200
+	t.setPseudoStatus("200 OK", "ok")
 	t.Response.BodyStr = fmt.Sprintf("Successfully deleted %s", u)
 	t.Response.BodyErr = nil
 
@@ -196,9 +267,11 @@ func (t *Test) executeBash() error {
 		return cerr
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), t.Request.Timeout)
+	ctx, cancel := context.WithTimeout(t.context(), t.Request.Timeout)
 	defer cancel()
 	cmd := exec.CommandContext(ctx, "bash", name)
+	// Do not wait for the output of orphaned children of the killed script.
+	cmd.WaitDelay = 100 * time.Millisecond
 	cmd.Dir = workDir
 	for k, v := range t.Request.Params {
 		if strings.Contains(k, "=") {
@@ -236,14 +309,19 @@ func (t *Test) executeBash() error {
 	err = cmd.Wait()
 	t.Response.BodyStr = b.String()
 
-	if ctx.Err() == context.DeadlineExceeded {
-		t.Response.Response.StatusCode = http.StatusRequestTimeout
-		t.Response.Response.Status = "408 Timeout" // TODO check!
+	if cerr := t.context().Err(); cerr != nil {
+		// The test itself got canceled, not just the script timed out.
+		return cerr
+	} else if ctx.Err() == context.DeadlineExceeded {
+		t.setPseudoStatus("408 Timeout", "timeout") // TODO check!
 	} else if err != nil {
 		// With catching errors to start the script early this code
 		// path should become less likely.
-		t.Response.Response.Status = "500 Internal Server Error"
-		t.Response.Response.StatusCode = 500
+		outcomes := []string{"exit:*"}
+		if ee, ok := err.(*exec.ExitError); ok && ee.ExitCode() > 0 {
+			outcomes = []string{"exit:" + strconv.Itoa(ee.ExitCode()), "exit:*"}
+		}
+		t.setPseudoStatus("500 Internal Server Error", outcomes...)
 		emsg := err.Error()
 		t.Response.Response.Header.Set("Exit-Status", emsg)
 		// Append error message to body too: Most likely we do not
@@ -255,6 +333,7 @@ func (t *Test) executeBash() error {
 		}
 		t.Response.BodyStr += emsg
 	} else {
+		t.setPseudoStatus("200 OK", "exit:0", "ok")
 		t.Response.Response.Header.Set("Exit-Status", "exit status 0")
 	}
 
@@ -318,20 +397,26 @@ func (t *Test) executeSQL() error {
 	switch t.Request.Method {
 	case http.MethodGet:
 		accept := t.Request.Header.Get("Accept")
-		t.Response.BodyStr, ct, err = sqlQuery(db, t.Request.Body, accept)
-		if err != nil {
-			return err
-		}
+		t.Response.BodyStr, ct, err = sqlQuery(t.context(), db, t.Request.Body, accept)
 	case http.MethodPost:
-		t.Response.BodyStr, err = sqlExecute(db, t.Request.Body)
-		if err != nil {
-			return err
-		}
+		t.Response.BodyStr, err = sqlExecute(t.context(), db, t.Request.Body)
 	default:
 		return bogusSQLQuery(
 			fmt.Sprintf("ht: illegal method %s for sql:// pseudo query",
 				t.Request.Method))
 	}
+	if err != nil {
+		// Failing statements are errors unless mapped to a status.
+		outcomes := sqlOutcomes(err)
+		if _, ok := t.mappedStatus(outcomes...); !ok || t.context().Err() != nil {
+			return err
+		}
+		t.setPseudoStatus("", outcomes...)
+		t.Response.BodyStr = err.Error()
+		ct = "text/plain; charset=utf-8"
+	} else {
+		t.setPseudoStatus("200 OK", "ok")
+	}
 	t.Response.Response.Header.Set("Content-Type", ct)
 
 	return nil
@@ -345,8 +430,8 @@ func (t *Test) executeSQL() error {
 //            "Error": "something went wrong"
 //        }
 //    }
-func sqlExecute(db *sql.DB, query string) (string, error) {
-	result, err := db.Exec(query)
+func sqlExecute(ctx context.Context, db *sql.DB, query string) (string, error) {
+	result, err := db.ExecContext(ctx, query)
 	if err != nil {
 		return "", err
 	}
@@ -388,8 +473,8 @@ func sqlExecute(db *sql.DB, query string) (string, error) {
 //    application/json (default)
 //    text/plain
 //    text/csv
-func sqlQuery(db *sql.DB, query string, accept string) (body string, contentType string, err error) {
-	rows, err := db.Query(query)
+func sqlQuery(ctx context.Context, db *sql.DB, query string, accept string) (body string, contentType string, err error) {
+	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
 		return "", "", err
 	}
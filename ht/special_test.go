@@ -5,6 +5,7 @@
 package ht
 
 import (
+	"context"
 	"database/sql"
 	"flag"
 	"fmt"
@@ -192,6 +193,8 @@ func TestBashPseudorequest(t *testing.T) {
 	t.Run("Exit2", testBashNonzeroExit)
 	t.Run("Timeout", testBashTimeout)
 	t.Run("Error", testBashError)
+	t.Run("Canceled", testBashCanceled)
+	t.Run("StatusMapping", testBashStatusMapping)
 }
 
 func testBashOkay(t *testing.T) {
@@ -304,6 +307,103 @@ func testBashError(t *testing.T) {
 	}
 }
 
+func testBashCanceled(t *testing.T) {
+	test := &Test{
+		Name: "A canceled script.",
+		Request: Request{
+			URL:  "bash://localhost/tmp",
+			Body: `echo "Go"; sleep 5; echo "Done"`,
+		},
+		Checks: CheckList{
+			&StatusCode{Expect: 200},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := test.RunContext(ctx); err != nil {
+		t.Fatalf("Unexpected error %s <%T>", err, err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("Took too long: %s", d)
+	}
+	if test.Result.Status != Error {
+		t.Errorf("Got %s, want Error", test.Result.Status)
+	}
+}
+
+func testBashStatusMapping(t *testing.T) {
+	for _, tc := range []struct {
+		body string
+		want string
+	}{
+		{`echo Hello`, "204 No Content"},
+		{`exit 2`, "422 Unprocessable Entity"},
+		{`exit 3`, "503 Service Unavailable"},
+		{`sleep 2`, "504 Gateway Timeout"},
+	} {
+		test := &Test{
+			Name: "Mapped bash script.",
+			Request: Request{
+				URL:     "bash://localhost/tmp",
+				Body:    tc.body,
+				Timeout: 500 * time.Millisecond,
+				StatusMapping: map[string]int{
+					"ok": 204, "exit:2": 422, "exit:*": 503, "timeout": 504,
+				},
+			},
+		}
+		if err := test.Run(); err != nil {
+			t.Fatalf("Unexpected error %s <%T>", err, err)
+		}
+		if got := test.Response.Response.Status; got != tc.want {
+			t.Errorf("%q: got status %q, want %q", tc.body, got, tc.want)
+		}
+	}
+}
+
+func TestPseudoStatusMapping(t *testing.T) {
+	test := &Test{
+		Name: "Mapped file:// GET",
+		Request: Request{
+			URL:           "file://" + filepath.ToSlash(filepath.Join(os.TempDir(), "ht-nonexisting-file")),
+			StatusMapping: map[string]int{"notexist": 410},
+		},
+		Checks: CheckList{StatusCode{Expect: 410}},
+	}
+	if err := test.Run(); err != nil {
+		t.Fatalf("Unexpected error %s <%T>", err, err)
+	}
+	if test.Result.Status != Pass {
+		t.Errorf("Got %s, want Pass: %v", test.Result.Status, test.Result.Error)
+	}
+
+	test = &Test{
+		Name: "Bogus mapping",
+		Request: Request{
+			URL:           "bash://localhost/tmp",
+			Body:          "true",
+			StatusMapping: map[string]int{"exit:x": 500},
+		},
+	}
+	test.Run()
+	if test.Result.Status != Bogus ||
+		test.Result.Error.Error() != `bad StatusMapping: unknown outcome "exit:x"` {
+		t.Errorf("Got %s: %v", test.Result.Status, test.Result.Error)
+	}
+
+	got := sqlOutcomes(sqlStateError("23505"))
+	if strings.Join(got, " ") != "sqlstate:23505 sqlstate:23 error" {
+		t.Errorf("Got SQL outcomes %v", got)
+	}
+}
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "sql error " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
 // ----------------------------------------------------------------------------
 // sql:// pseudo request
 
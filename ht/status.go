@@ -6,10 +6,15 @@
 
 package ht
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 func init() {
 	RegisterCheck(StatusCode{})
+	RegisterCheck(&StatusClass{})
 	RegisterCheck(NoServerError{})
 }
 
@@ -42,6 +47,50 @@ func (c StatusCode) Execute(t *Test) error {
 	return nil
 }
 
+// ----------------------------------------------------------------------------
+// StatusClass
+
+// StatusClass checks the HTTP statuscode against a list of status codes
+// and classes of status codes.
+type StatusClass struct {
+	// Expect is a comma separated list of status codes and classes of
+	// status codes like "2xx" or "2xx, 304" one of which must match.
+	Expect string
+
+	classes []string
+}
+
+// Execute implements Check's Execute method.
+func (c *StatusClass) Execute(t *Test) error {
+	got := strconv.Itoa(t.Response.Response.StatusCode)
+	for _, class := range c.classes {
+		if len(got) == 3 && (class == got || (class[1:] == "xx" && class[0] == got[0])) {
+			return nil
+		}
+	}
+	return fmt.Errorf("Got %s, want %s", got, strings.Join(c.classes, " or "))
+}
+
+// Prepare implements Check's Prepare method.
+func (c *StatusClass) Prepare(*Test) error {
+	c.classes = c.classes[:0]
+	for _, class := range strings.Split(c.Expect, ",") {
+		class = strings.ToLower(strings.TrimSpace(class))
+		ok := len(class) == 3 && class[0] >= '1' && class[0] <= '9'
+		if ok && class[1:] != "xx" {
+			_, err := strconv.Atoi(class)
+			ok = err == nil
+		}
+		if !ok {
+			return fmt.Errorf("bad status class %q", class)
+		}
+		c.classes = append(c.classes, class)
+	}
+	return nil
+}
+
+var _ Preparable = &StatusClass{}
+
 // ----------------------------------------------------------------------------
 // NoServerError
 
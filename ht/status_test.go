@@ -77,3 +77,34 @@ func TestNoServerError(t *testing.T) {
 		}
 	}
 }
+
+func TestStatusClass(t *testing.T) {
+	status := func(code int) Response {
+		return Response{Response: &http.Response{StatusCode: code}}
+	}
+	for i, tc := range []TC{
+		{status(200), &StatusClass{Expect: "2xx"}, nil},
+		{status(204), &StatusClass{Expect: "2XX"}, nil},
+		{status(304), &StatusClass{Expect: "2xx, 304"}, nil},
+		{status(301), &StatusClass{Expect: "2xx, 304"}, errCheck},
+		{status(404), &StatusClass{Expect: "4xx,5xx"}, nil},
+		{status(302), &StatusClass{Expect: "3xx"}, nil},
+		{status(500), &StatusClass{Expect: "3xx"}, errCheck},
+		{status(200), &StatusClass{Expect: "2x"}, errDuringPrepare},
+		{status(200), &StatusClass{Expect: "abc"}, errDuringPrepare},
+		{status(200), &StatusClass{Expect: "0xx"}, errDuringPrepare},
+		{status(200), &StatusClass{}, errDuringPrepare},
+	} {
+		runTest(t, i, tc)
+	}
+
+	check := &StatusClass{Expect: "2xx, 304"}
+	test := &Test{Response: status(404)}
+	if err := check.Prepare(test); err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	err := check.Execute(test)
+	if err == nil || err.Error() != "Got 404, want 2xx or 304" {
+		t.Errorf("Got error %v", err)
+	}
+}
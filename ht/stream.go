@@ -0,0 +1,240 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// stream.go contains the streaming of huge response bodies.
+
+package ht
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"unicode/utf8"
+)
+
+// ErrBodyTruncated is the Response.BodyErr of a body which exceeded
+// Request.MaxBodySize and was truncated.
+var ErrBodyTruncated = errors.New("body truncated")
+
+// BodyStreamer is implemented by checks which can check the response body
+// as a stream: If the body exceeds Request.MaxBodySize the whole body is
+// written to the BodyStream of the check while it is read and the check's
+// outcome is the Result of the stream.
+type BodyStreamer interface {
+	Check
+
+	// StreamBody returns the stream the body is written to or nil if
+	// this check cannot be performed on a stream.
+	StreamBody(t *Test) BodyStream
+}
+
+// BodyStream consumes a response body. Its Write method must not fail.
+type BodyStream interface {
+	io.Writer
+
+	// Result returns the outcome of the check once the whole body
+	// has been written.
+	Result() error
+}
+
+// readBody reads the response body from r into t.Response. Bodies
+// exceeding t.Request.MaxBodySize are truncated and streamed.
+func (t *Test) readBody(r io.Reader) {
+	t.streams = nil
+	max := t.Request.MaxBodySize
+	if max <= 0 {
+		bb, err := ioutil.ReadAll(r)
+		t.Response.BodyStr = string(bb)
+		t.Response.BodyErr = err
+		t.Response.BodySize = int64(len(bb))
+		return
+	}
+
+	writers := []io.Writer{}
+	t.streams = make(map[int]BodyStream)
+	for i, ck := range t.Checks {
+		if bs, ok := ck.(BodyStreamer); ok {
+			if stream := bs.StreamBody(t); stream != nil {
+				t.streams[i] = stream
+				writers = append(writers, stream)
+			}
+		}
+	}
+	hash := sha256.New()
+	captured := &capture{max: max}
+	writers = append(writers, hash, captured)
+
+	n, err := io.Copy(io.MultiWriter(writers...), r)
+	t.Response.BodyStr = string(captured.buf)
+	t.Response.BodySize = n
+	t.Response.BodyErr = err
+	if err == nil && n > max {
+		t.Response.BodyErr = ErrBodyTruncated
+		t.Response.BodySHA256 = fmt.Sprintf("%x", hash.Sum(nil))
+		t.debugf("Truncated body of %d bytes to %d bytes", n, max)
+	}
+}
+
+// capture keeps the first max bytes written to it.
+type capture struct {
+	max int64
+	buf []byte
+}
+
+func (c *capture) Write(p []byte) (int, error) {
+	if room := c.max - int64(len(c.buf)); room > 0 {
+		if int64(len(p)) < room {
+			room = int64(len(p))
+		}
+		c.buf = append(c.buf, p[:room]...)
+	}
+	return len(p), nil
+}
+
+// ----------------------------------------------------------------------------
+// Streams of the streamable checks.
+
+// StreamBody implements BodyStreamer.
+func (c UTF8Encoded) StreamBody(*Test) BodyStream {
+	return &utf8Stream{}
+}
+
+// utf8Stream validates the UTF-8 encoding of a stream. Runes split
+// between two writes are kept in pending.
+type utf8Stream struct {
+	pending []byte
+	char    int
+	err     error
+}
+
+func (s *utf8Stream) Write(p []byte) (int, error) {
+	if s.err != nil {
+		return len(p), nil
+	}
+	data := append(s.pending, p...)
+	s.pending = nil
+	for len(data) > 0 {
+		if !utf8.FullRune(data) {
+			s.pending = append([]byte(nil), data...)
+			break
+		}
+		r, size := utf8.DecodeRune(data)
+		s.char++
+		if r == utf8.RuneError {
+			s.err = fmt.Errorf("Invalid UTF-8 after character %d.", s.char)
+			break
+		}
+		if r == '\ufeff' {
+			s.err = fmt.Errorf("Unicode BOM at character %d.", s.char)
+			break
+		}
+		data = data[size:]
+	}
+	return len(p), nil
+}
+
+func (s *utf8Stream) Result() error {
+	if s.err == nil && len(s.pending) > 0 {
+		return fmt.Errorf("Invalid UTF-8 after character %d.", s.char+1)
+	}
+	return s.err
+}
+
+// StreamBody implements BodyStreamer.
+func (i Identity) StreamBody(*Test) BodyStream {
+	return &identityStream{id: i, sha1: sha1.New(), sha256: sha256.New()}
+}
+
+type identityStream struct {
+	id     Identity
+	sha1   hash.Hash
+	sha256 hash.Hash
+}
+
+func (s *identityStream) Write(p []byte) (int, error) {
+	s.sha1.Write(p)
+	s.sha256.Write(p)
+	return len(p), nil
+}
+
+func (s *identityStream) Result() error {
+	return s.id.compare(s.sha1.Sum(nil), s.sha256.Sum(nil))
+}
+
+// StreamBody implements BodyStreamer. Only conditions which consist of
+// Contains (with any Count), Min and Max can be streamed.
+func (b Body) StreamBody(*Test) BodyStream {
+	c := Condition(b)
+	streamable := Condition{Contains: c.Contains, Count: c.Count, Min: c.Min, Max: c.Max}
+	if !reflect.DeepEqual(c, streamable) {
+		return nil
+	}
+	return &bodyStream{cond: c, needle: []byte(c.Contains)}
+}
+
+// bodyStream counts the occurrences of needle in a window sliding over
+// the stream: The window keeps the last len(needle)-1 bytes not already
+// part of an occurrence.
+type bodyStream struct {
+	cond   Condition
+	needle []byte
+	window []byte
+	found  int
+	size   int64
+}
+
+func (s *bodyStream) Write(p []byte) (int, error) {
+	s.size += int64(len(p))
+	if len(s.needle) == 0 {
+		return len(p), nil
+	}
+	data := append(s.window, p...)
+	end := 0
+	for {
+		i := bytes.Index(data[end:], s.needle)
+		if i == -1 {
+			break
+		}
+		s.found++
+		end += i + len(s.needle)
+	}
+	if keep := len(data) - (len(s.needle) - 1); keep > end {
+		end = keep
+	}
+	s.window = append(s.window[:0:0], data[end:]...)
+	return len(p), nil
+}
+
+func (s *bodyStream) Result() error {
+	c := s.cond
+	if c.Contains != "" {
+		if c.Count == 0 && s.found == 0 {
+			return fmt.Errorf("Cannot find %q", c.Contains)
+		} else if c.Count < 0 && s.found > 0 {
+			return fmt.Errorf("Found forbidden %q", c.Contains)
+		} else if c.Count > 0 && s.found != c.Count {
+			return fmt.Errorf("Found %d occurrences of %q, want %d",
+				s.found, c.Contains, c.Count)
+		}
+	}
+	if c.Min > 0 && s.size < int64(c.Min) {
+		return fmt.Errorf("Too short, was %d", s.size)
+	}
+	if c.Max > 0 && s.size > int64(c.Max) {
+		return fmt.Errorf("Too long, was %d", s.size)
+	}
+	return nil
+}
+
+var (
+	_ BodyStreamer = UTF8Encoded{}
+	_ BodyStreamer = Identity{}
+	_ BodyStreamer = Body{}
+)
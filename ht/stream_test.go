@@ -0,0 +1,106 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStreamedBody(t *testing.T) {
+	body := strings.Repeat("Grüße, ", 100000) + "needle"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	sha := fmt.Sprintf("%x", sha256.Sum256([]byte(body)))
+	test := Test{
+		Request: Request{URL: ts.URL, MaxBodySize: 1000},
+		Checks: CheckList{
+			&Body{Contains: "needle", Count: 1},
+			&Body{Contains: "Grüße", Count: 100000},
+			&Body{Max: 1000},
+			&Body{Regexp: "needle$"},
+			UTF8Encoded{},
+			Identity{SHA256: sha},
+		},
+	}
+	test.Run()
+
+	if test.Response.BodyErr != ErrBodyTruncated || len(test.Response.BodyStr) != 1000 ||
+		test.Response.BodySize != int64(len(body)) || test.Response.BodySHA256 != sha {
+		t.Fatalf("Got %v %d %d", test.Response.BodyErr, len(test.Response.BodyStr),
+			test.Response.BodySize)
+	}
+	want := []Status{Pass, Pass, Fail, Fail, Pass, Pass}
+	for i, cr := range test.Result.CheckResults {
+		if cr.Status != want[i] {
+			t.Errorf("Check %d %s: got %s (%v), want %s", i, cr.Name, cr.Status,
+				cr.Error, want[i])
+		}
+	}
+	if got := test.Result.CheckResults[3].Error.Error(); got != ErrBadBody.Error() {
+		t.Errorf("Got %s", got)
+	}
+}
+
+func TestBodyStream(t *testing.T) {
+	for i, tc := range []struct {
+		body string
+		cond Condition
+		want string
+	}{
+		{"abcabcab", Condition{Contains: "abc", Count: 2}, ""},
+		{"aaaa", Condition{Contains: "aa", Count: 2}, ""},
+		{"xyz", Condition{Contains: "abc"}, `Cannot find "abc"`},
+		{"xabcx", Condition{Contains: "abc", Count: -1}, `Found forbidden "abc"`},
+		{"abcdef", Condition{Min: 10}, "Too short, was 6"},
+	} {
+		// Feed the body byte by byte to stress the window.
+		stream := Body(tc.cond).StreamBody(nil)
+		for j := 0; j < len(tc.body); j++ {
+			stream.Write([]byte{tc.body[j]})
+		}
+		got := ""
+		if err := stream.Result(); err != nil {
+			got = err.Error()
+		}
+		if got != tc.want {
+			t.Errorf("%d. Got %q, want %q", i, got, tc.want)
+		}
+	}
+
+	if (Body{Prefix: "a"}).StreamBody(nil) != nil {
+		t.Errorf("Prefix condition must not be streamable")
+	}
+}
+
+func TestUTF8Stream(t *testing.T) {
+	for i, tc := range []struct {
+		body, want string
+	}{
+		{"Grüße", ""},
+		{"Gr\xffße", "Invalid UTF-8 after character 3."},
+		{"a\ufeffb", "Unicode BOM at character 2."},
+		{"ab\xc3", "Invalid UTF-8 after character 3."},
+	} {
+		stream := UTF8Encoded{}.StreamBody(nil)
+		for j := 0; j < len(tc.body); j++ {
+			stream.Write([]byte{tc.body[j]})
+		}
+		got := ""
+		if err := stream.Result(); err != nil {
+			got = err.Error()
+		}
+		if got != tc.want {
+			t.Errorf("%d. Got %q, want %q", i, got, tc.want)
+		}
+	}
+}
@@ -5,6 +5,7 @@
 package ht
 
 import (
+	"context"
 	"sync"
 
 	"github.com/vdobler/ht/cookiejar"
@@ -24,6 +25,12 @@ type Collection struct {
 // ExecuteConcurrent executes tests concurrently.
 // But at most maxConcurrent tests of s are executed concurrently.
 func (s *Collection) ExecuteConcurrent(maxConcurrent int, jar *cookiejar.Jar) error {
+	return s.ExecuteConcurrentContext(context.Background(), maxConcurrent, jar)
+}
+
+// ExecuteConcurrentContext works like ExecuteConcurrent but the tests are
+// run with ctx, see Test.RunContext.
+func (s *Collection) ExecuteConcurrentContext(ctx context.Context, maxConcurrent int, jar *cookiejar.Jar) error {
 	s.Status = NotRun
 	s.Error = nil
 	if maxConcurrent > len(s.Tests) {
@@ -37,7 +44,7 @@ func (s *Collection) ExecuteConcurrent(maxConcurrent int, jar *cookiejar.Jar) er
 		go func() {
 			defer wg.Done()
 			for test := range c {
-				test.Run()
+				test.RunContext(ctx)
 			}
 		}()
 	}
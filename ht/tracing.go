@@ -0,0 +1,67 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"time"
+
+	"github.com/vdobler/ht/scope"
+	"github.com/vdobler/ht/tracing"
+)
+
+// startRequestSpan starts the span of the request about to be sent and
+// propagates it in the traceparent header unless t is not traced or the
+// header was set explicitly in the test. Each try gets its own span.
+func (t *Test) startRequestSpan() *tracing.Span {
+	if t.Span == nil {
+		return nil
+	}
+	req := t.Request.Request
+	span := t.Span.Child("HTTP "+req.Method, tracing.Client)
+	span.Set("http.method", req.Method)
+	span.Set("http.url", scope.Mask(req.URL.String()))
+	span.Set("ht.try", t.Result.Tries)
+	if t.Request.Header.Get("traceparent") == "" {
+		req.Header.Set("traceparent", span.Traceparent())
+	}
+	return span
+}
+
+// finishRequestSpan finishes span after the request returned err.
+func (t *Test) finishRequestSpan(span *tracing.Span, err error) {
+	if span == nil {
+		return
+	}
+	if resp := t.Response.Response; resp != nil {
+		span.Set("http.status_code", resp.StatusCode)
+	}
+	if err == nil {
+		err = t.Response.BodyErr
+	}
+	if err != nil {
+		span.Fail(err.Error())
+	}
+	span.Finish()
+}
+
+// traceChecks records a span for each check executed since start.
+func (t *Test) traceChecks(start time.Time) {
+	if t.Span == nil {
+		return
+	}
+	for _, cr := range t.Result.CheckResults {
+		if cr.Status == NotRun || cr.Status == Skipped {
+			continue
+		}
+		span := t.Span.ChildAt("Check "+cr.Name, tracing.Internal, start)
+		span.Set("ht.check", scope.Mask(cr.JSON))
+		span.Set("ht.status", cr.Status.String())
+		if err := cr.Error.AsError(); err != nil {
+			span.Fail(err.Error())
+		}
+		start = start.Add(cr.Duration)
+		span.FinishAt(start)
+	}
+}
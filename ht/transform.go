@@ -0,0 +1,320 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// transform.go contains the transformation of response bodies.
+
+package ht
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"strings"
+	"unicode/utf8"
+)
+
+// Transformation is one step in the transformation of the response body
+// before the checks and extractors see it, e.g. to decrypt an encrypted
+// envelope. The following Kinds of transformation are available:
+//     base64   decode standard or URL base64 (padding optional)
+//     gunzip   decompress gzip data
+//     latin1   convert ISO-8859-1 to UTF-8
+//     charset  convert the charset declared in the Content-Type header
+//              or a HTML meta tag to UTF-8, see Response.Charset (bodies
+//              of tests without Transform are converted automatically)
+//     aes-gcm  decrypt AES-GCM: a 12 byte nonce followed by the
+//              ciphertext and the tag
+//     jwe      decrypt a JWE in compact serialization with alg "dir",
+//              "A128KW", "A192KW" or "A256KW" and enc "A128GCM",
+//              "A192GCM", "A256GCM", "A128CBC-HS256", "A192CBC-HS384"
+//              or "A256CBC-HS512"
+// The transformations of a test are applied in order, e.g. a base64
+// encoded, gzipped payload is decoded by
+//     Transform: [ {Kind: "base64"}, {Kind: "gunzip"} ]
+type Transformation struct {
+	// Kind of transformation.
+	Kind string
+
+	// Key is the base64 encoded key for aes-gcm and jwe. It typically
+	// references a variable like "{{API_KEY}}".
+	Key string `json:",omitempty"`
+
+	// Element selects the string value of this element of a JSON body
+	// (in the syntax of the JSON check with a "." as separator) as the
+	// input to the transformation. An empty Element uses the whole body.
+	Element string `json:",omitempty"`
+}
+
+// transformBody applies the Transform of t to the response body.
+func (t *Test) transformBody() error {
+	if len(t.Transform) == 0 {
+		return nil
+	}
+	if t.Response.BodyErr != nil {
+		return fmt.Errorf("cannot transform body: %s", t.Response.BodyErr)
+	}
+	body := []byte(t.Response.BodyStr)
+	for i, tr := range t.Transform {
+		var err error
+		body, err = tr.apply(t, body)
+		if err != nil {
+			return fmt.Errorf("Transform[%d] %s: %s", i, tr.Kind, err)
+		}
+		t.debugf("Transformed body with %s to %d bytes", tr.Kind, len(body))
+	}
+	t.Response.BodyStr = string(body)
+	t.Response.BodySize = int64(len(body))
+	return nil
+}
+
+// apply transforms data received by t.
+func (tr Transformation) apply(t *Test, data []byte) ([]byte, error) {
+	if tr.Element != "" {
+		raw, err := findJSONelement(data, tr.Element, ".")
+		if err != nil {
+			return nil, err
+		}
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, fmt.Errorf("element %s is not a string", tr.Element)
+		}
+		data = []byte(s)
+	}
+
+	switch tr.Kind {
+	case "base64":
+		return decodeBase64(string(data))
+	case "gunzip":
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	case "latin1":
+		buf := make([]byte, 0, len(data)+len(data)/8)
+		for _, b := range data {
+			buf = utf8.AppendRune(buf, rune(b))
+		}
+		return buf, nil
+	case "charset":
+		return t.decodeCharset(data)
+	case "aes-gcm":
+		key, err := decodeBase64(tr.Key)
+		if err != nil {
+			return nil, fmt.Errorf("bad Key: %s", err)
+		}
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) < aead.NonceSize()+aead.Overhead() {
+			return nil, errors.New("ciphertext too short")
+		}
+		nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+		return aead.Open(nil, nonce, ciphertext, nil)
+	case "jwe":
+		key, err := decodeBase64(tr.Key)
+		if err != nil {
+			return nil, fmt.Errorf("bad Key: %s", err)
+		}
+		return decryptJWE(strings.TrimSpace(string(data)), key)
+	}
+	return nil, fmt.Errorf("unknown kind %q", tr.Kind)
+}
+
+// decodeBase64 decodes s in standard or URL encoding with optional padding.
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, s)
+	s = strings.TrimRight(s, "=")
+	if strings.ContainsAny(s, "-_") {
+		return base64.RawURLEncoding.DecodeString(s)
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ----------------------------------------------------------------------------
+// JWE
+
+// decryptJWE decrypts the compact serialization of a JWE with the
+// symmetric key (RFC 7516, RFC 7518).
+func decryptJWE(compact string, key []byte) ([]byte, error) {
+	parts := strings.Split(compact, ".")
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("got %d instead of 5 parts", len(parts))
+	}
+	raw := make([][]byte, 5)
+	for i, p := range parts {
+		var err error
+		raw[i], err = base64.RawURLEncoding.DecodeString(p)
+		if err != nil {
+			return nil, fmt.Errorf("part %d: %s", i+1, err)
+		}
+	}
+	header := struct {
+		Alg, Enc, Zip string
+	}{}
+	if err := json.Unmarshal(raw[0], &header); err != nil {
+		return nil, fmt.Errorf("bad header: %s", err)
+	}
+
+	keyLen, ok := map[string]int{
+		"A128GCM": 16, "A192GCM": 24, "A256GCM": 32,
+		"A128CBC-HS256": 32, "A192CBC-HS384": 48, "A256CBC-HS512": 64,
+	}[header.Enc]
+	if !ok {
+		return nil, fmt.Errorf("unsupported enc %q", header.Enc)
+	}
+
+	var cek []byte
+	switch header.Alg {
+	case "dir":
+		cek = key
+	case "A128KW", "A192KW", "A256KW":
+		var err error
+		cek, err = aesKeyUnwrap(key, raw[1])
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported alg %q", header.Alg)
+	}
+	if len(cek) != keyLen {
+		return nil, fmt.Errorf("got %d byte key, %s needs %d", len(cek), header.Enc, keyLen)
+	}
+
+	aad := []byte(parts[0])
+	iv, ciphertext, tag := raw[2], raw[3], raw[4]
+	var plaintext []byte
+	if strings.HasSuffix(header.Enc, "GCM") {
+		block, err := aes.NewCipher(cek)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCMWithNonceSize(block, len(iv))
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err = aead.Open(nil, iv, append(ciphertext, tag...), aad)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		plaintext, err = decryptCBCHMAC(cek, iv, ciphertext, tag, aad)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if header.Zip == "DEF" {
+		return inflate(plaintext)
+	}
+	return plaintext, nil
+}
+
+// inflate decompresses the raw DEFLATE data.
+func inflate(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// decryptCBCHMAC implements AES_CBC_HMAC_SHA2 of RFC 7518 section 5.2.
+func decryptCBCHMAC(cek, iv, ciphertext, tag, aad []byte) ([]byte, error) {
+	n := len(cek) / 2
+	macKey, encKey := cek[:n], cek[n:]
+	var h func() hash.Hash
+	switch n {
+	case 16:
+		h = sha256.New
+	case 24:
+		h = sha512.New384
+	default:
+		h = sha512.New
+	}
+	mac := hmac.New(h, macKey)
+	mac.Write(aad)
+	mac.Write(iv)
+	mac.Write(ciphertext)
+	al := make([]byte, 8)
+	binary.BigEndian.PutUint64(al, uint64(len(aad))*8)
+	mac.Write(al)
+	if !hmac.Equal(mac.Sum(nil)[:n], tag) {
+		return nil, errors.New("message authentication failed")
+	}
+
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != block.BlockSize() || len(ciphertext)%block.BlockSize() != 0 || len(ciphertext) == 0 {
+		return nil, errors.New("bad ciphertext length")
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+	pad := int(plaintext[len(plaintext)-1])
+	if pad == 0 || pad > block.BlockSize() {
+		return nil, errors.New("bad padding")
+	}
+	return plaintext[:len(plaintext)-pad], nil
+}
+
+// aesKeyUnwrap implements the AES key unwrap of RFC 3394.
+func aesKeyUnwrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped)%8 != 0 || len(wrapped) < 24 {
+		return nil, errors.New("bad length of wrapped key")
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	n := len(wrapped)/8 - 1
+	a := make([]byte, 8)
+	copy(a, wrapped[:8])
+	r := make([]byte, len(wrapped)-8)
+	copy(r, wrapped[8:])
+	buf := make([]byte, 16)
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(buf[:8], binary.BigEndian.Uint64(a)^t)
+			copy(buf[8:], r[(i-1)*8:i*8])
+			block.Decrypt(buf, buf)
+			copy(a, buf[:8])
+			copy(r[(i-1)*8:], buf[8:])
+		}
+	}
+	iv := []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+	if subtle.ConstantTimeCompare(a, iv) != 1 {
+		return nil, errors.New("key unwrap failed")
+	}
+	return r, nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vdobler/ht/errorlist"
+)
+
+// Example of RFC 7516 appendix A.3: A128KW and A128CBC-HS256.
+const (
+	rfcJWEKey = "GawgguFyGrWKav7AX4VKUg"
+	rfcJWE    = "eyJhbGciOiJBMTI4S1ciLCJlbmMiOiJBMTI4Q0JDLUhTMjU2In0." +
+		"6KB707dM9YTIgHtLvtgWQ8mKwboJW3of9locizkDTHzBC2IlrT1oOQ." +
+		"AxY8DCtDaGlsbGljb3RoZQ." +
+		"KDlTtXchhZTGufMYmOYGS4HffxPSUrfmqCHXaI9wOGY." +
+		"U0m_YmjN04DJvceFICbCVQ"
+)
+
+func gzipped(s string) string {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	w.Write([]byte(s))
+	w.Close()
+	return buf.String()
+}
+
+// sealGCM encrypts plaintext with key in the aes-gcm format.
+func sealGCM(key []byte, plaintext string) string {
+	aead, err := newGCM(key)
+	if err != nil {
+		panic(err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	return string(aead.Seal(nonce, nonce, []byte(plaintext), nil))
+}
+
+// dirJWE encrypts plaintext with key as a JWE with alg "dir" and
+// enc "A256GCM".
+func dirJWE(key []byte, plaintext string) string {
+	b64 := base64.RawURLEncoding.EncodeToString
+	header := b64([]byte(`{"alg":"dir","enc":"A256GCM"}`))
+	aead, err := newGCM(key)
+	if err != nil {
+		panic(err)
+	}
+	iv := make([]byte, aead.NonceSize())
+	rand.Read(iv)
+	sealed := aead.Seal(nil, iv, []byte(plaintext), []byte(header))
+	n := len(sealed) - aead.Overhead()
+	return header + ".." + b64(iv) + "." + b64(sealed[:n]) + "." + b64(sealed[n:])
+}
+
+func TestTransformation(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+	b64key := base64.StdEncoding.EncodeToString(key)
+	otherKey := base64.StdEncoding.EncodeToString(make([]byte, 32))
+
+	for i, tc := range []struct {
+		body      string
+		transform []Transformation
+		want      string
+		err       string
+	}{
+		{"SGVsbG8gV29ybGQ=", []Transformation{{Kind: "base64"}}, "Hello World", ""},
+		{"SGVsbG8_", []Transformation{{Kind: "base64"}}, "Hello?", ""},
+		{base64.StdEncoding.EncodeToString([]byte(gzipped(gzipped("double")))),
+			[]Transformation{{Kind: "base64"}, {Kind: "gunzip"}, {Kind: "gunzip"}},
+			"double", ""},
+		{"Gr\xfc\xdfe", []Transformation{{Kind: "latin1"}}, "Grüße", ""},
+		{sealGCM(key, "secret"), []Transformation{{Kind: "aes-gcm", Key: b64key}}, "secret", ""},
+		{sealGCM(key, "secret"), []Transformation{{Kind: "aes-gcm", Key: otherKey}}, "",
+			"message authentication failed"},
+		{dirJWE(key, `{"price": 12}`), []Transformation{{Kind: "jwe", Key: b64key}}, `{"price": 12}`, ""},
+		{`{"data": "` + dirJWE(key, "inner") + `"}`,
+			[]Transformation{{Kind: "jwe", Key: b64key, Element: "data"}}, "inner", ""},
+		{rfcJWE, []Transformation{{Kind: "jwe", Key: rfcJWEKey}}, "Live long and prosper.", ""},
+		{rfcJWE[:len(rfcJWE)-2] + "AA", []Transformation{{Kind: "jwe", Key: rfcJWEKey}}, "",
+			"message authentication failed"},
+		{`{"data": 12}`, []Transformation{{Kind: "base64", Element: "data"}}, "",
+			"element data is not a string"},
+		{"abc", []Transformation{{Kind: "rot13"}}, "", `Transform[0] rot13: unknown kind "rot13"`},
+	} {
+		test := &Test{
+			Response:  Response{BodyStr: tc.body},
+			Transform: tc.transform,
+		}
+		err := test.transformBody()
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%d: got error %v, want %s", i, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %s", i, err)
+		} else if test.Response.BodyStr != tc.want {
+			t.Errorf("%d: got %q, want %q", i, test.Response.BodyStr, tc.want)
+		}
+	}
+}
+
+func TestTransformBeforeChecks(t *testing.T) {
+	key := make([]byte, 16)
+	rand.Read(key)
+	b64key := base64.StdEncoding.EncodeToString(key)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(base64.StdEncoding.EncodeToString(
+			[]byte(sealGCM(key, `{"status": "ok"}`)))))
+	}))
+	defer ts.Close()
+
+	test := Test{
+		Request:   Request{URL: ts.URL + "/"},
+		Transform: []Transformation{{Kind: "base64"}, {Kind: "aes-gcm", Key: b64key}},
+		Checks: CheckList{
+			StatusCode{Expect: 200},
+			&JSON{Element: "status", Condition: Condition{Equals: `"ok"`}},
+		},
+	}
+	test.Run()
+	if test.Result.Status != Pass {
+		t.Errorf("Got status %s: %v", test.Result.Status, test.Result.Error)
+	}
+
+	test.Transform = test.Transform[1:]
+	test.Run()
+	details := errorlist.Details(test.Result.Error)
+	if test.Result.Status != Error || len(details) != 1 ||
+		details[0].Code != "response.transform" || details[0].Category != errorlist.Check {
+		t.Errorf("Got status %s: %v", test.Result.Status, details)
+	}
+}
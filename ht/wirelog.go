@@ -0,0 +1,195 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// wirelog.go contains the dumping of requests and responses to files.
+
+package ht
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/vdobler/ht/scope"
+)
+
+// RedactedHeaders are the headers whose values are never dumped to wire
+// logs or printed in verbose logging.
+var RedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// RedactedValue replaces the values of redacted headers.
+const RedactedValue = "<redacted>"
+
+// DefaultWireLogMaxBody is the default number of bytes of a body dumped
+// to a wire log.
+const DefaultWireLogMaxBody = 16 * 1024
+
+// WireLog dumps the request and the response of each try of a test as
+// sent and received over the wire to a file, one file per execution of a
+// test. The values of the headers in RedactedHeaders and in Redact are replaced by
+// "<redacted>" and all secret values (see scope.MarkSecret) are masked.
+type WireLog struct {
+	// Dir is the directory the files are written to. The files are
+	// named after a running number unique in Dir, the sequence number
+	// and the name of the test, e.g. "0007_Main-03_Login_as_admin.wire".
+	// The running number restarts with each run (see ResetWireLogs).
+	Dir string
+
+	// MaxBody limits the number of bytes dumped of each request and
+	// response body. Zero means DefaultWireLogMaxBody, a negative value
+	// dumps the full bodies.
+	MaxBody int `json:",omitempty"`
+
+	// MaxFiles limits the number of files written to Dir during one
+	// run, e.g. during a load test. Zero means no limit.
+	MaxFiles int `json:",omitempty"`
+
+	// Redact lists additional headers to redact like "X-Api-Key".
+	Redact []string `json:",omitempty"`
+}
+
+// wireLogFiles counts the files written per directory in the current run.
+var wireLogFiles = struct {
+	sync.Mutex
+	n map[string]int
+}{n: make(map[string]int)}
+
+// ResetWireLogs restarts counting the wire log files written per directory.
+// It should be called at the start of each run so that MaxFiles limits
+// the files of this run and the running numbers in the file names
+// start again at 1.
+func ResetWireLogs() {
+	wireLogFiles.Lock()
+	wireLogFiles.n = make(map[string]int)
+	wireLogFiles.Unlock()
+}
+
+// allowFile reports whether another file may be written to w.Dir and
+// returns its running number.
+func (w *WireLog) allowFile() (int, bool) {
+	wireLogFiles.Lock()
+	defer wireLogFiles.Unlock()
+	if w.MaxFiles > 0 && wireLogFiles.n[w.Dir] >= w.MaxFiles {
+		return 0, false
+	}
+	wireLogFiles.n[w.Dir]++
+	return wireLogFiles.n[w.Dir], true
+}
+
+// maxBody returns the number of body bytes to dump; negative means all.
+func (w *WireLog) maxBody() int {
+	if w == nil {
+		return -1
+	}
+	if w.MaxBody == 0 {
+		return DefaultWireLogMaxBody
+	}
+	return w.MaxBody
+}
+
+// redact returns a copy of h with the values of the redacted headers
+// replaced by RedactedValue.
+func (w *WireLog) redact(h http.Header) http.Header {
+	redacted := make(http.Header, len(h))
+	for name, values := range h {
+		redacted[name] = append([]string(nil), values...)
+	}
+	names := RedactedHeaders
+	if w != nil {
+		names = append(names[:len(names):len(names)], w.Redact...)
+	}
+	for _, name := range names {
+		name = http.CanonicalHeaderKey(name)
+		for i := range redacted[name] {
+			redacted[name][i] = RedactedValue
+		}
+	}
+	return redacted
+}
+
+// dumpRequest returns the redacted wire representation of req with the
+// given body.
+func (w *WireLog) dumpRequest(req *http.Request, body string) string {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "%s %s HTTP/1.1\r\n", req.Method, req.URL.RequestURI())
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	fmt.Fprintf(buf, "Host: %s\r\n", host)
+	w.redact(req.Header).Write(buf)
+	buf.WriteString("\r\n")
+	buf.WriteString(capBody(body, w.maxBody()))
+	return scope.Mask(buf.String())
+}
+
+// dumpResponse returns the redacted wire representation of resp with the
+// given body.
+func (w *WireLog) dumpResponse(resp *http.Response, body string) string {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "%s %s\r\n", resp.Proto, resp.Status)
+	w.redact(resp.Header).Write(buf)
+	buf.WriteString("\r\n")
+	buf.WriteString(capBody(body, w.maxBody()))
+	return scope.Mask(buf.String())
+}
+
+// capBody returns the first max bytes of body. Negative max means no limit.
+func capBody(body string, max int) string {
+	if max < 0 || len(body) <= max {
+		return body
+	}
+	return fmt.Sprintf("%s\n... [%d more bytes]\n", body[:max], len(body)-max)
+}
+
+var wireLogNameRe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// filename of the n'th wire log in w.Dir which is the one of t. The
+// running number n keeps the names unique even for tests without a
+// sequence number (like in load tests) or with the same name.
+func (w *WireLog) filename(t *Test, n int) string {
+	name := wireLogNameRe.ReplaceAllString(t.Name, "_")
+	if seqno := t.GetStringMetadata("SeqNo"); seqno != "" {
+		name = wireLogNameRe.ReplaceAllString(seqno, "_") + "_" + name
+	}
+	return filepath.Join(w.Dir, fmt.Sprintf("%04d_%s.wire", n, name))
+}
+
+// writeWireLog appends the dump of the current try of t to its wire log.
+// The first try starts a new file which no other execution writes to.
+func (t *Test) writeWireLog(dump string) {
+	w := t.WireLog
+	if w == nil || w.Dir == "" {
+		return
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	filename := t.GetStringMetadata("WireLog")
+	if t.Result.Tries <= 1 || filename == "" {
+		n, ok := w.allowFile()
+		if !ok {
+			t.debugf("Wire log limit of %d files reached", w.MaxFiles)
+			return
+		}
+		filename = w.filename(t, n)
+		t.SetMetadata("WireLog", filename)
+		flags |= os.O_TRUNC
+	}
+
+	if err := os.MkdirAll(w.Dir, 0766); err != nil {
+		t.errorf("Cannot write wire log: %s", err)
+		return
+	}
+	file, err := os.OpenFile(filename, flags, 0666)
+	if err != nil {
+		t.errorf("Cannot write wire log: %s", err)
+		return
+	}
+	defer file.Close()
+	fmt.Fprintf(file, "=== Try %d: %s\n%s\n", t.Result.Tries, t.Name, dump)
+	t.debugf("Dumped request and response to %s", filename)
+}
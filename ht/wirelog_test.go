@@ -0,0 +1,136 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ht
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vdobler/ht/scope"
+)
+
+func TestWireLog(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "cookie-value-123"})
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		w.Write([]byte("0123456789abcdefghijklmnopqrstuvwxyz"))
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "wirelog-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ResetWireLogs()
+	scope.MarkSecret("topsecret-password")
+	logbuf := &bytes.Buffer{}
+	test := &Test{
+		Name: "Login as admin",
+		Request: Request{
+			Method: "POST",
+			URL:    ts.URL + "/login",
+			Header: http.Header{
+				"Authorization": {"Bearer bearer-token-456"},
+				"X-Api-Key":     {"api-key-789"},
+			},
+			Params: map[string][]string{"password": {"topsecret-password"}},
+		},
+		Checks:    CheckList{StatusCode{Expect: 200}},
+		Execution: Execution{Tries: 2, Verbosity: 4},
+		WireLog:   &WireLog{Dir: dir, MaxBody: 20, Redact: []string{"x-api-key"}},
+		Log:       log.New(logbuf, "", 0),
+	}
+	test.SetMetadata("SeqNo", "Main-01")
+	test.Run()
+	if test.Result.Status != Pass {
+		t.Fatalf("Unexpected status %s: %s", test.Result.Status, test.Result.Error)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "0001_Main-01_Login_as_admin.wire"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wire := string(data)
+	for _, want := range []string{
+		"=== Try 1: Login as admin\nPOST /login?password=*** HTTP/1.1\r\n",
+		"=== Try 2: Login as admin\n",
+		"Authorization: <redacted>\r\n",
+		"X-Api-Key: <redacted>\r\n",
+		"Set-Cookie: <redacted>\r\n",
+		"password=***",
+		"HTTP/1.1 503 Service Unavailable\r\n",
+		"0123456789abcdefghij\n... [16 more bytes]\n",
+	} {
+		if !strings.Contains(wire, want) {
+			t.Errorf("Missing %q in wire log\n%s", want, wire)
+		}
+	}
+	logged := logbuf.String()
+	if !strings.Contains(logged, "Full Response") || !strings.Contains(logged, "<redacted>") {
+		t.Errorf("Missing full response in log\n%s", logged)
+	}
+	for _, secret := range []string{"bearer-token-456", "api-key-789", "cookie-value-123", "topsecret-password"} {
+		if strings.Contains(wire, secret) {
+			t.Errorf("Wire log leaks %q", secret)
+		}
+		if secret != "topsecret-password" && strings.Contains(logged, secret) {
+			t.Errorf("Log leaks %q", secret) // secrets are masked by the log writer
+		}
+	}
+
+	// MaxFiles throttles the number of files written.
+	limited := &WireLog{Dir: filepath.Join(dir, "limited"), MaxFiles: 2}
+	for _, name := range []string{"One", "Two", "Three"} {
+		test := &Test{Name: name, Request: Request{URL: ts.URL}, WireLog: limited}
+		test.Run()
+	}
+	files, _ := filepath.Glob(filepath.Join(limited.Dir, "*.wire"))
+	if len(files) != 2 {
+		t.Errorf("Got wire logs %v", files)
+	}
+
+	// Errors contain the request URL and are masked too.
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	failing := &Test{
+		Name:    "Unreachable",
+		Request: Request{URL: closed.URL + "/?password=topsecret-password"},
+		WireLog: &WireLog{Dir: dir},
+	}
+	failing.Run()
+	data, err = ioutil.ReadFile(failing.GetStringMetadata("WireLog"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wire := string(data); !strings.Contains(wire, "Error: ") ||
+		strings.Contains(wire, "topsecret-password") {
+		t.Errorf("Bad wire log of failed request\n%s", wire)
+	}
+
+	// Same named tests without sequence number get their own files and
+	// a new run may write MaxFiles files again.
+	ResetWireLogs()
+	for i := 0; i < 2; i++ {
+		test := &Test{Name: "One", Request: Request{URL: ts.URL}, WireLog: limited}
+		test.Run()
+	}
+	files, _ = filepath.Glob(filepath.Join(limited.Dir, "*_One.wire"))
+	if len(files) != 2 || files[1] != filepath.Join(limited.Dir, "0002_One.wire") {
+		t.Errorf("Got wire logs %v", files)
+	}
+}
@@ -52,7 +52,7 @@ func (x *XML) Execute(t *Test) error {
 
 // Prepare implements Check's Prepare method.
 func (x *XML) Prepare(*Test) error {
-	p, err := xmlpath.Compile(x.Path)
+	p, err := compileXPath(x.Path)
 	if err != nil {
 		return err
 	}
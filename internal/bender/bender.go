@@ -18,6 +18,7 @@ limitations under the License.
 package bender
 
 import (
+	"context"
 	"sync"
 	"time"
 
@@ -45,8 +46,9 @@ type Event struct {
 }
 
 type Test struct {
-	Test *ht.Test
-	Done chan bool
+	Test    *ht.Test
+	Done    chan bool
+	Context context.Context // nil means context.Background
 }
 
 /******
@@ -116,7 +118,11 @@ func LoadTestThroughput(intervals IntervalGenerator, requests chan Test, recorde
 			go func(test Test, overage int64) {
 				defer wg.Done()
 				reqStart := time.Now().UnixNano()
-				test.Test.Run()
+				if test.Context != nil {
+					test.Test.RunContext(test.Context)
+				} else {
+					test.Test.Run()
+				}
 				test.Done <- true
 				recorder <- Event{
 					Typ:     EndRequestEvent,
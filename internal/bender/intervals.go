@@ -55,6 +55,23 @@ func RampedExponentialIntervalGenerator(rate float64, ramp time.Duration) Interv
 	}
 }
 
+// ScheduledExponentialIntervalGenerator creates an IntervalGenerator which
+// outputs exponentially distributed intervals whose average rate changes
+// over time: The average rate is rate(elapsed) with elapsed the time
+// since start. The rate function must return positive values.
+// The exponentially distributed random numbers (with rate 1) are drawn from
+// expFloat64 which defaults to rand.ExpFloat64 if nil.
+func ScheduledExponentialIntervalGenerator(start time.Time, rate func(elapsed time.Duration) float64, expFloat64 func() float64) IntervalGenerator {
+	if expFloat64 == nil {
+		expFloat64 = rand.ExpFloat64
+	}
+	t0 := start.UnixNano()
+	return func(now int64) int64 {
+		r := rate(time.Duration(now-t0)) / float64(time.Second)
+		return int64(expFloat64() / r)
+	}
+}
+
 // UniformIntervalGenerator creates and IntervalGenerator that outputs 1/rate every time it is
 // called. Boring, right?
 func UniformIntervalGenerator(rate float64) IntervalGenerator {
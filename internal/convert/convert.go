@@ -0,0 +1,130 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package convert provides the suites and tests generated by the
+// converters of foreign formats like Postman collections, OpenAPI
+// specifications or curl commands.
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/sanitize"
+)
+
+// File is a generated suite or test file.
+type File struct {
+	Name string // Name relative to the output directory.
+	Data []byte
+}
+
+// Test is a reduced version of ht.Test suitable to serialization to JSON.
+// Checks and DataExtraction are kept as soups in the form they are read
+// back by package populate.
+type Test struct {
+	Name           string
+	Description    string `json:",omitempty"`
+	Request        ht.Request
+	Checks         []Soup          `json:",omitempty"`
+	DataExtraction map[string]Soup `json:",omitempty"`
+}
+
+// Soup is a check or extractor in the generated test.
+type Soup map[string]interface{}
+
+// AddCheck adds the check name with the given field/value pairs to test.
+func (test *Test) AddCheck(name string, fieldValues ...interface{}) {
+	check := Soup{"Check": name}
+	for i := 0; i+1 < len(fieldValues); i += 2 {
+		check[fieldValues[i].(string)] = fieldValues[i+1]
+	}
+	test.Checks = append(test.Checks, check)
+}
+
+// Suite is a reduced version of suite.RawSuite suitable to serialization
+// to JSON.
+type Suite struct {
+	Name        string
+	Description string `json:",omitempty"`
+	Main        []struct {
+		File string
+	}
+	Variables map[string]string `json:",omitempty"`
+}
+
+// Builder collects the suite, its tests and the warnings of a conversion.
+type Builder struct {
+	Suite    Suite
+	Warnings []string
+
+	// Unnamed is the file name used for tests whose name yields no
+	// usable file name.
+	Unnamed string
+
+	files []File
+	names map[string]bool
+}
+
+// Warnf records a warning.
+func (b *Builder) Warnf(format string, args ...interface{}) {
+	b.Warnings = append(b.Warnings, fmt.Sprintf(format, args...))
+}
+
+// AddTest adds test to the Main tests of the suite. The test is stored in
+// directory dir in a file named after test.
+func (b *Builder) AddTest(dir string, test *Test) error {
+	data, err := Marshal(test)
+	if err != nil {
+		return err
+	}
+	filename := b.filename(dir, test.Name)
+	b.files = append(b.files, File{Name: filename, Data: data})
+	b.Suite.Main = append(b.Suite.Main, struct{ File string }{filename})
+	return nil
+}
+
+// Files returns the suite (named after the suite) followed by the tests.
+func (b *Builder) Files() ([]File, error) {
+	data, err := Marshal(b.Suite)
+	if err != nil {
+		return nil, err
+	}
+	suiteFile := File{Name: sanitize.Filename(b.Suite.Name) + ".suite", Data: data}
+	return append([]File{suiteFile}, b.files...), nil
+}
+
+// filename returns a unique filename for the test name in dir.
+func (b *Builder) filename(dir, name string) string {
+	if b.names == nil {
+		b.names = make(map[string]bool)
+	}
+	base := strings.Trim(sanitize.Filename(name), "_")
+	if base == "" {
+		base = b.Unnamed
+	}
+	filename := path.Join(dir, base+".ht")
+	for i := 2; b.names[filename]; i++ {
+		filename = path.Join(dir, fmt.Sprintf("%s_%d.ht", base, i))
+	}
+	b.names[filename] = true
+	return filename
+}
+
+// Marshal v to indented JSON (which is valid HJSON) without escaping
+// characters like & in URLs.
+func Marshal(v interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
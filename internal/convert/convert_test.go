@@ -0,0 +1,39 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package convert
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	b := &Builder{Suite: Suite{Name: "My API"}, Unnamed: "request"}
+	for _, name := range []string{"Get Pet", "Get Pet", "???", "/pets/"} {
+		test := &Test{Name: name}
+		test.AddCheck("StatusCode", "Expect", 200)
+		if err := b.AddTest("pets", test); err != nil {
+			t.Fatalf("Unexpected error %s", err)
+		}
+	}
+	files, err := b.Files()
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	names := []string{}
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	want := "My_API.suite pets/Get_Pet.ht pets/Get_Pet_2.ht pets/request.ht pets/pets.ht"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("Got files %s, want %s", got, want)
+	}
+	if data := string(files[1].Data); !strings.Contains(data, `"Check": "StatusCode"`) {
+		t.Errorf("Got test\n%s", data)
+	}
+	if data := string(files[0].Data); !strings.Contains(data, `"File": "pets/Get_Pet_2.ht"`) {
+		t.Errorf("Got suite\n%s", data)
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package hdr provides a simple High Dynamic Range histogram.
+//
+// Values are recorded with a fixed number of significant decimal digits
+// over an unbounded range of positive values: Values below 2^k are counted
+// exactly while larger values are counted in buckets whose width doubles
+// for each power of two. Memory use grows logarithmically with the largest
+// recorded value and quantiles are accurate to the configured number of
+// significant digits.
+package hdr
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Histogram is a High Dynamic Range histogram of non-negative values.
+type Histogram struct {
+	k      uint    // number of bits for the linear sub-buckets
+	counts []int64 // counts per bucket, grows as needed
+
+	total    int64
+	sum      float64
+	min, max int64
+}
+
+// New returns an empty histogram recording values with the given number of
+// significant decimal digits which must be between 1 and 5.
+func New(digits int) *Histogram {
+	if digits < 1 {
+		digits = 1
+	} else if digits > 5 {
+		digits = 5
+	}
+	largest := 2 * math.Pow10(digits)
+	k := uint(math.Ceil(math.Log2(largest)))
+	return &Histogram{
+		k:   k,
+		min: math.MaxInt64,
+	}
+}
+
+// index of the bucket v is counted in.
+func (h *Histogram) index(v int64) int {
+	n := int64(1) << h.k
+	if v < n {
+		return int(v)
+	}
+	shift := uint(bits.Len64(uint64(v))) - h.k
+	half := n / 2
+	return int(n + int64(shift-1)*half + (v >> shift) - half)
+}
+
+// lowest returns the smallest value counted in the bucket with index i.
+func (h *Histogram) lowest(i int) int64 {
+	n := int64(1) << h.k
+	if int64(i) < n {
+		return int64(i)
+	}
+	half := n / 2
+	j := int64(i) - n
+	shift := uint(j/half) + 1
+	return (half + j%half) << shift
+}
+
+// highest returns the largest value counted in the bucket with index i.
+func (h *Histogram) highest(i int) int64 {
+	return h.lowest(i+1) - 1
+}
+
+// Record the value v. Negative values are recorded as 0.
+func (h *Histogram) Record(v int64) {
+	if v < 0 {
+		v = 0
+	}
+	i := h.index(v)
+	if i >= len(h.counts) {
+		counts := make([]int64, i+1, 2*(i+1))
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+	h.counts[i]++
+	h.total++
+	h.sum += float64(v)
+	if v < h.min {
+		h.min = v
+	}
+	if v > h.max {
+		h.max = v
+	}
+}
+
+// Merge adds all values recorded in o to h. Both histograms must use the
+// same number of significant digits.
+func (h *Histogram) Merge(o *Histogram) {
+	if o.total == 0 {
+		return
+	}
+	if len(o.counts) > len(h.counts) {
+		counts := make([]int64, len(o.counts))
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+	for i, c := range o.counts {
+		h.counts[i] += c
+	}
+	h.total += o.total
+	h.sum += o.sum
+	if o.min < h.min {
+		h.min = o.min
+	}
+	if o.max > h.max {
+		h.max = o.max
+	}
+}
+
+// Count returns the number of recorded values.
+func (h *Histogram) Count() int64 { return h.total }
+
+// Min returns the smallest recorded value or 0 for an empty histogram.
+func (h *Histogram) Min() int64 {
+	if h.total == 0 {
+		return 0
+	}
+	return h.min
+}
+
+// Max returns the largest recorded value.
+func (h *Histogram) Max() int64 { return h.max }
+
+// Mean returns the arithmetic mean of the recorded values.
+func (h *Histogram) Mean() float64 {
+	if h.total == 0 {
+		return 0
+	}
+	return h.sum / float64(h.total)
+}
+
+// ValueAtPercentile returns the value below or equal to which p percent
+// of all recorded values lie; p is clipped to the range [0,100].
+func (h *Histogram) ValueAtPercentile(p float64) int64 {
+	if h.total == 0 {
+		return 0
+	}
+	if p <= 0 {
+		return h.Min()
+	} else if p >= 100 {
+		return h.max
+	}
+	target := int64(math.Ceil(p / 100 * float64(h.total)))
+	cum := int64(0)
+	for i, c := range h.counts {
+		cum += c
+		if cum >= target {
+			v := h.highest(i)
+			if v > h.max {
+				v = h.max
+			}
+			if v < h.min {
+				v = h.min
+			}
+			return v
+		}
+	}
+	return h.max
+}
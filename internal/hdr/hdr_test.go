@@ -0,0 +1,67 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hdr
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestIndexRoundtrip(t *testing.T) {
+	h := New(3)
+	for _, v := range []int64{0, 1, 2047, 2048, 2049, 4095, 4096, 123456789, 1 << 40} {
+		i := h.index(v)
+		if lo, hi := h.lowest(i), h.highest(i); v < lo || v > hi {
+			t.Errorf("value %d in bucket %d with range [%d,%d]", v, i, lo, hi)
+		}
+	}
+	for i := 0; i < 10000; i++ {
+		if got := h.index(h.lowest(i)); got != i {
+			t.Fatalf("index(lowest(%d))=%d", i, got)
+		}
+	}
+}
+
+func TestPercentiles(t *testing.T) {
+	h := New(3)
+	values := make([]int64, 100000)
+	for i := range values {
+		values[i] = int64(rand.ExpFloat64() * 50000)
+		h.Record(values[i])
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	if h.Count() != int64(len(values)) {
+		t.Errorf("Count()=%d", h.Count())
+	}
+	if h.Min() != values[0] || h.Max() != values[len(values)-1] {
+		t.Errorf("Min()=%d Max()=%d, want %d %d",
+			h.Min(), h.Max(), values[0], values[len(values)-1])
+	}
+	for _, p := range []float64{50, 90, 99, 99.9} {
+		want := values[int(math.Ceil(p/100*float64(len(values))))-1]
+		got := h.ValueAtPercentile(p)
+		if math.Abs(float64(got-want)) > 0.001*float64(want)+1 {
+			t.Errorf("p%g: got %d, want %d", p, got, want)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a, b := New(2), New(2)
+	for i := int64(1); i <= 100; i++ {
+		a.Record(i)
+		b.Record(1000 * i)
+	}
+	a.Merge(b)
+	if a.Count() != 200 || a.Min() != 1 || a.Max() != 100000 {
+		t.Errorf("Count=%d Min=%d Max=%d", a.Count(), a.Min(), a.Max())
+	}
+	if got := a.ValueAtPercentile(50); got != 100 {
+		t.Errorf("Median=%d, want 100", got)
+	}
+}
@@ -0,0 +1,562 @@
+package hjson
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Kind is the kind of a Node in a Document.
+type Kind int
+
+// The different kinds of nodes.
+const (
+	Scalar Kind = iota // string, number, boolean or null
+	Object
+	Array
+)
+
+// Document is a parsed Hjson text which keeps comments, whitespace and the
+// order of keys. Marshaling an unmodified Document yields the original text;
+// programmatic edits through the methods of Node change only the edited
+// parts of the text.
+type Document struct {
+	Root *Node
+
+	pre  string // whitespace and comments before Root
+	post string // whitespace and comments after Root
+}
+
+// Node is a value in a Document.
+type Node struct {
+	Kind Kind
+
+	value  interface{} // value of a Scalar
+	raw    string      // source text of a Scalar
+	open   string      // opening brace or bracket; empty for a root object without braces
+	close  string      // whitespace and comments before and the closing brace or bracket
+	indent string      // indentation of the line the node starts on
+	items  []*item     // members of an Object or elements of an Array
+}
+
+// item is a member of an object or an element of an array.
+type item struct {
+	pre    string // whitespace and comments before the key (or the value for arrays)
+	key    string // the key
+	rawKey string // source text of the key
+	mid    string // the text between key and value including the ':'
+	value  *Node
+	post   string // comma and comments up to the end of the line after the value
+	indent string // indentation of the line the item starts on
+}
+
+// ParseDocument parses the Hjson text data into a Document.
+func ParseDocument(data []byte) (*Document, error) {
+	p := &hjsonParser{data, 0, ' '}
+	p.next()
+	p.white()
+	start := p.pos()
+	doc := &Document{pre: string(data[:start])}
+
+	var root *Node
+	var end int
+	var err error
+	switch p.ch {
+	case '{':
+		root, end, err = p.docObject(false)
+	case '[':
+		root, end, err = p.docArray()
+	default:
+		// Root object without braces or a single value.
+		root, end, err = p.docObject(true)
+		if err != nil {
+			p.at = start
+			p.next()
+			if root2, end2, err2 := p.docValue(); err2 == nil && p.trailingOnly() {
+				root, end, err = root2, end2, nil
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !p.trailingOnly() {
+		return nil, p.errAt("Syntax error, found trailing characters")
+	}
+	doc.Root = root
+	doc.post = string(data[end:])
+	return doc, nil
+}
+
+// Bytes returns the Hjson text of d.
+func (d *Document) Bytes() []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString(d.pre)
+	d.Root.write(buf)
+	buf.WriteString(d.post)
+	return buf.Bytes()
+}
+
+// Interface returns the value of the whole document like Unmarshal would.
+func (d *Document) Interface() interface{} {
+	return d.Root.Interface()
+}
+
+// Position returns the line and column (both starting at 1) of the element
+// reached from the root by following path which consists of keys (strings)
+// into objects and indices (ints) into arrays. The position of an object
+// member is the position of its key. If path leads to a nonexisting element
+// the position of the deepest existing element on path is returned.
+func (d *Document) Position(path ...interface{}) (line, col int) {
+	buf := &bytes.Buffer{}
+	offset := len(d.pre)
+	n := d.Root
+	for k, p := range path {
+		i := -1
+		switch p := p.(type) {
+		case string:
+			i = n.lookup(p)
+		case int:
+			if n.Kind == Array && p >= 0 && p < len(n.items) {
+				i = p
+			}
+		}
+		if i < 0 {
+			break
+		}
+		offset += len(n.open)
+		for _, it := range n.items[:i] {
+			buf.Reset()
+			it.value.write(buf)
+			offset += len(it.pre) + len(it.rawKey) + len(it.mid) + buf.Len() + len(it.post)
+		}
+		it := n.items[i]
+		offset += len(it.pre)
+		if k == len(path)-1 {
+			break // report the key
+		}
+		offset += len(it.rawKey) + len(it.mid)
+		n = it.value
+	}
+
+	data := d.Bytes()
+	if offset > len(data) {
+		offset = len(data)
+	}
+	line = 1 + bytes.Count(data[:offset], []byte("\n"))
+	col = offset - bytes.LastIndexByte(data[:offset], '\n')
+	return line, col
+}
+
+// ----------------------------------------------------------------------------
+// Parsing
+
+// pos returns the index of the current character.
+func (p *hjsonParser) pos() int {
+	if p.ch == 0 {
+		return len(p.data)
+	}
+	return p.at - 1
+}
+
+// trailingOnly skips whitespace and comments and reports whether the end of
+// input was reached.
+func (p *hjsonParser) trailingOnly() bool {
+	p.white()
+	return p.ch == 0
+}
+
+// docValue parses a value starting at the current (non-white) character.
+// It returns the node and the index after its source text.
+func (p *hjsonParser) docValue() (*Node, int, error) {
+	start := p.pos()
+	switch p.ch {
+	case '{':
+		return p.docObject(false)
+	case '[':
+		return p.docArray()
+	}
+
+	var value interface{}
+	var err error
+	if p.ch == '"' {
+		value, err = p.readString()
+	} else {
+		value, err = p.readTfnns()
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	raw := strings.TrimRight(string(p.data[start:p.pos()]), " \t\r\n")
+	n := &Node{
+		Kind:   Scalar,
+		value:  value,
+		raw:    raw,
+		indent: lineIndent(p.data, start),
+	}
+	return n, start + len(raw), nil
+}
+
+func (p *hjsonParser) docObject(withoutBraces bool) (*Node, int, error) {
+	n := &Node{Kind: Object, indent: lineIndent(p.data, p.pos())}
+	if !withoutBraces {
+		n.open = "{"
+		p.next()
+	}
+
+	cur := p.pos() // start of the not yet consumed whitespace and comments
+	for {
+		p.white()
+		if p.ch == 0 {
+			if withoutBraces {
+				n.close = string(p.data[cur:p.pos()])
+				return n, p.pos(), nil
+			}
+			return nil, 0, p.errAt("End of input while parsing an object (did you forget a closing '}'?)")
+		}
+		if p.ch == '}' && !withoutBraces {
+			n.close = string(p.data[cur : p.pos()+1])
+			p.next()
+			return n, p.pos(), nil
+		}
+
+		keyStart := p.pos()
+		key, err := p.readKeyname()
+		if err != nil {
+			return nil, 0, err
+		}
+		rawKey := strings.TrimRight(string(p.data[keyStart:p.pos()]), " \t\r\n")
+		p.white()
+		if p.ch != ':' {
+			return nil, 0, p.errAt("Expected ':' instead of '" + string(p.ch) + "'")
+		}
+		p.next()
+		p.white()
+		valStart := p.pos()
+		value, valEnd, err := p.docValue()
+		if err != nil {
+			return nil, 0, err
+		}
+		it := &item{
+			pre:    string(p.data[cur:keyStart]),
+			key:    key,
+			rawKey: rawKey,
+			mid:    string(p.data[keyStart+len(rawKey) : valStart]),
+			value:  value,
+			indent: lineIndent(p.data, keyStart),
+		}
+		cur = p.afterValue(valEnd)
+		it.post = string(p.data[valEnd:cur])
+		n.items = append(n.items, it)
+	}
+}
+
+func (p *hjsonParser) docArray() (*Node, int, error) {
+	n := &Node{Kind: Array, indent: lineIndent(p.data, p.pos()), open: "["}
+	p.next()
+
+	cur := p.pos()
+	for {
+		p.white()
+		if p.ch == 0 {
+			return nil, 0, p.errAt("End of input while parsing an array (did you forget a closing ']'?)")
+		}
+		if p.ch == ']' {
+			n.close = string(p.data[cur : p.pos()+1])
+			p.next()
+			return n, p.pos(), nil
+		}
+
+		valStart := p.pos()
+		value, valEnd, err := p.docValue()
+		if err != nil {
+			return nil, 0, err
+		}
+		it := &item{
+			pre:    string(p.data[cur:valStart]),
+			value:  value,
+			indent: lineIndent(p.data, valStart),
+		}
+		cur = p.afterValue(valEnd)
+		it.post = string(p.data[valEnd:cur])
+		n.items = append(n.items, it)
+	}
+}
+
+// afterValue consumes whitespace, comments and an optional comma after a
+// value ending at valEnd. It returns the end of the text belonging to the
+// value: Everything up to and including the end of the line the value
+// ends on. The rest belongs to the next item or to the closing bracket.
+func (p *hjsonParser) afterValue(valEnd int) int {
+	p.white()
+	if p.ch == ',' {
+		p.next()
+		p.white()
+	}
+	return valEnd + lineEnd(string(p.data[valEnd:p.pos()]))
+}
+
+// lineEnd returns the index after the first newline in the whitespace and
+// comments s which is not part of a block comment or len(s) if there is
+// no such newline.
+func lineEnd(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\n':
+			return i + 1
+		case s[i] == '#' || strings.HasPrefix(s[i:], "//"):
+			if j := strings.IndexByte(s[i:], '\n'); j >= 0 {
+				return i + j + 1
+			}
+			return len(s)
+		case strings.HasPrefix(s[i:], "/*"):
+			j := strings.Index(s[i+2:], "*/")
+			if j < 0 {
+				return len(s)
+			}
+			i += j + 3
+		}
+	}
+	return len(s)
+}
+
+// lineIndent returns the leading whitespace of the line containing data[pos].
+func lineIndent(data []byte, pos int) string {
+	start := bytes.LastIndexByte(data[:pos], '\n') + 1
+	end := start
+	for end < len(data) && (data[end] == ' ' || data[end] == '\t') {
+		end++
+	}
+	return string(data[start:end])
+}
+
+// ----------------------------------------------------------------------------
+// Output
+
+func (n *Node) write(buf *bytes.Buffer) {
+	if n.Kind == Scalar {
+		buf.WriteString(n.raw)
+		return
+	}
+	buf.WriteString(n.open)
+	for _, it := range n.items {
+		buf.WriteString(it.pre)
+		buf.WriteString(it.rawKey)
+		buf.WriteString(it.mid)
+		it.value.write(buf)
+		buf.WriteString(it.post)
+	}
+	buf.WriteString(n.close)
+}
+
+// Interface returns the value of n like Unmarshal would: Objects are
+// returned as map[string]interface{} and arrays as []interface{}.
+func (n *Node) Interface() interface{} {
+	switch n.Kind {
+	case Object:
+		m := make(map[string]interface{}, len(n.items))
+		for _, it := range n.items {
+			m[it.key] = it.value.Interface()
+		}
+		return m
+	case Array:
+		a := make([]interface{}, 0, len(n.items))
+		for _, it := range n.items {
+			a = append(a, it.value.Interface())
+		}
+		return a
+	}
+	return n.value
+}
+
+// ----------------------------------------------------------------------------
+// Objects
+
+// Keys returns the keys of the Object n in source order.
+func (n *Node) Keys() []string {
+	keys := []string{}
+	if n.Kind != Object {
+		return keys
+	}
+	for _, it := range n.items {
+		keys = append(keys, it.key)
+	}
+	return keys
+}
+
+// Get returns the value stored under key in the Object n or nil if there
+// is no such key. For duplicate keys the last one wins like in Unmarshal.
+func (n *Node) Get(key string) *Node {
+	if i := n.lookup(key); i >= 0 {
+		return n.items[i].value
+	}
+	return nil
+}
+
+// Set stores v under key in the Object n. An existing value is replaced
+// keeping the comments around it, a new key is appended as last member.
+func (n *Node) Set(key string, v interface{}) error {
+	if n.Kind != Object {
+		return fmt.Errorf("hjson: cannot set key %q on non-object", key)
+	}
+	if i := n.lookup(key); i >= 0 {
+		return n.replace(i, v)
+	}
+	it := &item{key: key, rawKey: (&hjsonEncoder{}).quoteName(key), mid: ": "}
+	return n.add(it, v)
+}
+
+// Delete removes key (including its comments) from the Object n and
+// reports whether key was present.
+func (n *Node) Delete(key string) bool {
+	found := false
+	for i := n.lookup(key); i >= 0; i = n.lookup(key) {
+		n.items = append(n.items[:i], n.items[i+1:]...)
+		found = true
+	}
+	return found
+}
+
+func (n *Node) lookup(key string) int {
+	if n.Kind != Object {
+		return -1
+	}
+	for i := len(n.items) - 1; i >= 0; i-- {
+		if n.items[i].key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// ----------------------------------------------------------------------------
+// Arrays
+
+// Len returns the number of elements of the Array n or the number of
+// members of the Object n.
+func (n *Node) Len() int {
+	return len(n.items)
+}
+
+// Index returns the i'th element of the Array n.
+func (n *Node) Index(i int) *Node {
+	return n.items[i].value
+}
+
+// SetIndex replaces the i'th element of the Array n with v.
+func (n *Node) SetIndex(i int, v interface{}) error {
+	if n.Kind != Array {
+		return fmt.Errorf("hjson: cannot index non-array")
+	}
+	if i < 0 || i >= len(n.items) {
+		return fmt.Errorf("hjson: index %d out of range", i)
+	}
+	return n.replace(i, v)
+}
+
+// Append adds v as the last element to the Array n.
+func (n *Node) Append(v interface{}) error {
+	if n.Kind != Array {
+		return fmt.Errorf("hjson: cannot append to non-array")
+	}
+	return n.add(&item{}, v)
+}
+
+// Remove deletes the i'th element (including its comments) from the Array n.
+func (n *Node) Remove(i int) {
+	n.items = append(n.items[:i], n.items[i+1:]...)
+}
+
+// ----------------------------------------------------------------------------
+// Editing
+
+// replace the value of the i'th item of n with v.
+func (n *Node) replace(i int, v interface{}) error {
+	node, err := newNode(v, n.items[i].indent, !n.endsLine(i))
+	if err != nil {
+		return err
+	}
+	n.items[i].value = node
+	return nil
+}
+
+// add it with value v as the last item of n.
+func (n *Node) add(it *item, v interface{}) error {
+	inline := false
+	if len(n.items) == 0 {
+		it.indent = n.indent + DefaultOptions().IndentBy
+		if n.open == "" {
+			it.indent = n.indent
+		}
+		it.pre, it.post = "\n"+it.indent, "\n"
+		if n.open != "" && strings.TrimSpace(n.close) == n.close[len(n.close)-1:] {
+			n.close = n.indent + n.close[len(n.close)-1:]
+		}
+	} else {
+		last := n.items[len(n.items)-1]
+		it.indent = last.indent
+		if strings.HasSuffix(last.post, "\n") {
+			it.pre, it.post = it.indent, "\n"
+		} else {
+			// Single line object or array.
+			if !strings.Contains(last.post, ",") {
+				last.post += ","
+			}
+			if !strings.HasSuffix(last.post, " ") {
+				last.post += " "
+			}
+			inline = !n.endsLine(len(n.items) - 1)
+		}
+	}
+
+	node, err := newNode(v, it.indent, inline)
+	if err != nil {
+		return err
+	}
+	it.value = node
+	n.items = append(n.items, it)
+	return nil
+}
+
+// endsLine reports whether the value of the i'th item of n is the last
+// thing on its line (comments excluded) or the last thing at all.
+// Quoteless strings may be used only in this case.
+func (n *Node) endsLine(i int) bool {
+	rest := n.items[i].post
+	if i+1 < len(n.items) {
+		rest += n.items[i+1].pre
+	} else {
+		rest += n.close
+	}
+	rest = strings.TrimLeft(rest, " \t\r")
+	return rest == "" || rest[0] == '\n'
+}
+
+// newNode encodes v as a Node indented by indent.
+func newNode(v interface{}, indent string, inline bool) (*Node, error) {
+	opts := DefaultOptions()
+	opts.QuoteAlways = inline
+	data, err := MarshalWithOptions(v, opts)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(data), "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = indent + lines[i]
+		}
+	}
+	data = []byte(strings.Join(lines, "\n"))
+
+	p := &hjsonParser{data, 0, ' '}
+	p.next()
+	node, _, err := p.docValue()
+	if err != nil {
+		return nil, err
+	}
+	if !p.trailingOnly() {
+		return nil, fmt.Errorf("hjson: cannot encode %v", v)
+	}
+	node.indent = indent
+	return node, nil
+}
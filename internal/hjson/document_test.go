@@ -0,0 +1,152 @@
+package hjson
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDocumentRoundTrip(t *testing.T) {
+	files := strings.Split(string(getContent("assets/testlist.txt")), "\n")
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		data := getContent("assets/" + file)
+		var want interface{}
+		wantErr := Unmarshal(data, &want)
+
+		doc, err := ParseDocument(data)
+		if (err != nil) != (wantErr != nil) {
+			t.Errorf("%s: got error %v, Unmarshal error %v", file, err, wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got := string(doc.Bytes()); got != string(data) {
+			t.Errorf("%s: round trip changed text:\n%s", file, got)
+		}
+		if got := doc.Interface(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %#v\nwant %#v", file, got, want)
+		}
+	}
+}
+
+const documentTest = `# A test
+{
+    Name: "Test"   # the name
+    // The request
+    Request: {
+        URL: "http://example.org"
+        Header: {"Accept": "text/html"}
+    }
+    Checks: [
+        {Check: "StatusCode", Expect: 200}  # must be okay
+        /* obsolete */
+        {Check: "Body", Contains: "foo"}
+    ]
+    Tags: ["a", "b"]
+}
+`
+
+func TestDocumentEdit(t *testing.T) {
+	doc, err := ParseDocument([]byte(documentTest))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	root := doc.Root
+	if got := root.Keys(); !reflect.DeepEqual(got, []string{"Name", "Request", "Checks", "Tags"}) {
+		t.Errorf("Got keys %v", got)
+	}
+
+	mustOK := func(err error) {
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	mustOK(root.Set("Name", "Other Test"))
+	request := root.Get("Request")
+	mustOK(request.Get("Header").Set("Accept", "application/json"))
+	mustOK(request.Set("Method", "POST"))
+	checks := root.Get("Checks")
+	checks.Remove(1)
+	mustOK(checks.Append(map[string]interface{}{"Check": "ContentType", "Is": "json"}))
+	mustOK(root.Get("Tags").Append("c d"))
+	if !root.Delete("Tags") || root.Delete("Tags") {
+		t.Errorf("Bad Delete")
+	}
+	mustOK(root.Set("Description", "multi\nline"))
+
+	want := `# A test
+{
+    Name: "Other Test"   # the name
+    // The request
+    Request: {
+        URL: "http://example.org"
+        Header: {"Accept": "application/json"}
+        Method: POST
+    }
+    Checks: [
+        {Check: "StatusCode", Expect: 200}  # must be okay
+        {
+            Check: ContentType
+            Is: json
+        }
+    ]
+    Description: "multi\nline"
+}
+`
+	got := string(doc.Bytes())
+	if got != want {
+		t.Errorf("Got\n%s\nwant\n%s", got, want)
+	}
+
+	var reparsed interface{}
+	if err := Unmarshal([]byte(got), &reparsed); err != nil {
+		t.Fatalf("Cannot unmarshal edited document: %s", err)
+	}
+	if !reflect.DeepEqual(reparsed, doc.Interface()) {
+		t.Errorf("Got %#v\nwant %#v", reparsed, doc.Interface())
+	}
+}
+
+func TestDocumentPosition(t *testing.T) {
+	src := `{
+    // A comment
+    Name: "Test"
+    Request: {
+        URL: "http://example.org"
+    }
+    Checks: [
+        {Check: "StatusCode", Expect: 200}
+        {
+            Check: "Body"
+            Contains: "foo"
+        }
+    ]
+}`
+	doc, err := ParseDocument([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, tc := range []struct {
+		path      []interface{}
+		line, col int
+	}{
+		{nil, 1, 1},
+		{[]interface{}{"Name"}, 3, 5},
+		{[]interface{}{"Request", "URL"}, 5, 9},
+		{[]interface{}{"Request", "Method"}, 4, 14},
+		{[]interface{}{"Checks", 0, "Expect"}, 8, 31},
+		{[]interface{}{"Checks", 1}, 9, 9},
+		{[]interface{}{"Checks", 1, "Contains"}, 11, 13},
+		{[]interface{}{"Checks", 7}, 7, 13},
+	} {
+		line, col := doc.Position(tc.path...)
+		if line != tc.line || col != tc.col {
+			t.Errorf("%d. %v: got %d,%d, want %d,%d", i, tc.path,
+				line, col, tc.line, tc.col)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package hjson
+
+import (
+	"bytes"
+	"strings"
+)
+
+// formatWidth is the maximal line length up to which objects and arrays
+// are formatted on a single line.
+const formatWidth = 80
+
+// Format returns the Hjson text of d in canonical layout: Members and
+// elements are indented by four spaces and written one per line without
+// commas. Objects and arrays without comments which contain only scalars
+// or objects and arrays of scalars are written on one line if they fit. Comments are kept, runs of blank
+// lines are collapsed into one. The key order is not changed, use
+// Node.Reorder for this.
+func (d *Document) Format() []byte {
+	buf := &bytes.Buffer{}
+	pre := comments(d.pre)
+	if len(pre) > 0 && pre[0] == "" {
+		pre = pre[1:]
+	}
+	writeComments(buf, "", pre)
+	if d.Root.Kind == Object && d.Root.open == "" {
+		d.Root.formatItems(buf, "")
+	} else {
+		d.Root.format(buf, "", formatWidth) // never put on one line
+		post := d.post
+		if i := strings.IndexByte(post, '\n'); i >= 0 {
+			post = post[:i]
+		}
+		for _, c := range comments(post) {
+			if c != "" {
+				buf.WriteString("  " + c)
+			}
+		}
+		buf.WriteByte('\n')
+	}
+	if i := strings.IndexByte(d.post, '\n'); i >= 0 || d.Root.open == "" {
+		post := comments(d.post[i+1:])
+		for len(post) > 0 && post[len(post)-1] == "" {
+			post = post[:len(post)-1]
+		}
+		writeComments(buf, "", post)
+	}
+	return buf.Bytes()
+}
+
+// comments extracts the comments from the whitespace and comment text s.
+// Blank lines between comments and a blank line before the end of s are
+// reported as one empty string. As s is expected to start after the
+// newline of the previous line a newline at the start of s is a blank
+// line too.
+func comments(s string) []string {
+	result := []string{}
+	newlines := 0
+	blank := func() {
+		threshold := 2
+		if len(result) == 0 {
+			threshold = 1
+		}
+		if newlines >= threshold && (len(result) == 0 || result[len(result)-1] != "") {
+			result = append(result, "")
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		var c string
+		switch {
+		case s[i] == '\n':
+			newlines++
+			continue
+		case s[i] == '#' || strings.HasPrefix(s[i:], "//"):
+			j := strings.IndexByte(s[i:], '\n')
+			if j < 0 {
+				j = len(s) - i
+			}
+			c = strings.TrimRight(s[i:i+j], " \t\r")
+			i += j - 1
+		case strings.HasPrefix(s[i:], "/*"):
+			j := strings.Index(s[i+2:], "*/")
+			if j < 0 {
+				j = len(s) - i - 4
+			}
+			c = s[i : i+j+4]
+			i += j + 3
+		default:
+			continue
+		}
+		blank()
+		result = append(result, c)
+		newlines = 0
+	}
+	blank()
+	return result
+}
+
+// writeComments writes the comments cs on their own lines indented by
+// indent.
+func writeComments(buf *bytes.Buffer, indent string, cs []string) {
+	for _, c := range cs {
+		if c == "" {
+			buf.WriteByte('\n')
+			continue
+		}
+		buf.WriteString(indent + c + "\n")
+	}
+}
+
+// hasComments reports whether n or any of its descendants contain comments.
+func (n *Node) hasComments() bool {
+	if n.Kind == Scalar {
+		return false
+	}
+	if strings.ContainsAny(n.close, "#/") {
+		return true
+	}
+	for _, it := range n.items {
+		if strings.ContainsAny(it.pre+it.mid+it.post, "#/") || it.value.hasComments() {
+			return true
+		}
+	}
+	return false
+}
+
+// inline returns the single line form of n or "" if n cannot be
+// written on a single line. Objects and arrays may be nested up to the
+// given depth.
+func (n *Node) inline(depth int) string {
+	if n.Kind == Scalar {
+		if strings.Contains(n.raw, "\n") {
+			return ""
+		}
+		if s, ok := n.value.(string); ok && n.raw != "" && n.raw[0] != '"' && n.raw[0] != '\'' {
+			// Quoteless strings extend to the end of the line.
+			quoted, err := newNode(s, "", true)
+			if err != nil {
+				return ""
+			}
+			return quoted.raw
+		}
+		return n.raw
+	}
+	if depth == 0 || n.hasComments() {
+		return ""
+	}
+	parts := make([]string, len(n.items))
+	for i, it := range n.items {
+		v := it.value.inline(depth - 1)
+		if v == "" {
+			return ""
+		}
+		if n.Kind == Object {
+			v = it.rawKey + ": " + v
+		}
+		parts[i] = v
+	}
+	if n.Kind == Object {
+		return "{" + strings.Join(parts, ", ") + "}"
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// format writes n indented by indent to buf. The value starts at column
+// col of the current line.
+func (n *Node) format(buf *bytes.Buffer, indent string, col int) {
+	if n.Kind == Scalar {
+		if strings.Contains(n.raw, "\n") {
+			if node, err := newNode(n.value, indent, false); err == nil {
+				buf.WriteString(node.raw)
+				return
+			}
+		}
+		buf.WriteString(n.raw)
+		return
+	}
+	if s := n.inline(2); s != "" && col+len(s) <= formatWidth {
+		buf.WriteString(s)
+		return
+	}
+	open, close := "{", "}"
+	if n.Kind == Array {
+		open, close = "[", "]"
+	}
+	buf.WriteString(open + "\n")
+	n.formatItems(buf, indent+DefaultOptions().IndentBy)
+	buf.WriteString(indent + close)
+}
+
+// formatItems writes the members or elements of n, each indented by
+// indent on its own line, followed by the comments before the closing
+// bracket.
+func (n *Node) formatItems(buf *bytes.Buffer, indent string) {
+	for i, it := range n.items {
+		pre := comments(it.pre)
+		if i == 0 && len(pre) > 0 && pre[0] == "" {
+			pre = pre[1:]
+		}
+		if mid := comments(it.mid); len(mid) > 0 && mid[0] != "" {
+			pre = append(pre, mid...)
+		}
+		writeComments(buf, indent, pre)
+
+		buf.WriteString(indent)
+		col := len(indent)
+		if n.Kind == Object {
+			buf.WriteString(it.rawKey + ": ")
+			col += len(it.rawKey) + 2
+		}
+		it.value.format(buf, indent, col)
+		for _, c := range comments(it.post) {
+			if c != "" {
+				buf.WriteString("  " + c)
+			}
+		}
+		buf.WriteByte('\n')
+	}
+
+	close := n.close
+	if n.open != "" && len(close) > 0 {
+		close = close[:len(close)-1]
+	}
+	cs := comments(close)
+	if len(cs) > 0 && cs[0] == "" && len(n.items) == 0 {
+		cs = cs[1:]
+	}
+	for len(cs) > 0 && cs[len(cs)-1] == "" {
+		cs = cs[:len(cs)-1]
+	}
+	writeComments(buf, indent, cs)
+}
+
+// Reorder moves the members of the Object n whose keys are listed in
+// keys to the front in the given order. The other members keep their
+// relative order after them.
+func (n *Node) Reorder(keys []string) {
+	if n.Kind != Object {
+		return
+	}
+	rank := make(map[string]int, len(keys))
+	for i, k := range keys {
+		if _, ok := rank[k]; !ok {
+			rank[k] = i
+		}
+	}
+	ordered := make([]*item, 0, len(n.items))
+	for _, k := range keys {
+		if rank[k] < 0 {
+			continue
+		}
+		for _, it := range n.items {
+			if it.key == k {
+				ordered = append(ordered, it)
+			}
+		}
+		rank[k] = -1
+	}
+	for _, it := range n.items {
+		if _, ok := rank[it.key]; !ok {
+			ordered = append(ordered, it)
+		}
+	}
+	n.items = ordered
+}
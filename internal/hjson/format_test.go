@@ -0,0 +1,94 @@
+package hjson
+
+import (
+	"reflect"
+	"testing"
+)
+
+const formatTest = `# A test
+
+
+{
+  Name: "Test"   # the name
+  Description: "Some
+  text", Tags: [ "a",
+     "b" ]
+
+
+  // The request
+  Request: { URL: "http://example.org", Header: {
+     "Accept": "text/html"} }
+  Checks: [
+        {Check: "StatusCode", Expect: 200}  # must be okay
+        /* obsolete */
+        {Check: "Body"
+        Contains: "foo"}
+  // no more checks
+  ]
+}
+`
+
+const formatWant = `# A test
+
+{
+    Name: "Test"  # the name
+    Description: "Some\n  text"
+    Tags: ["a", "b"]
+
+    // The request
+    Request: {URL: "http://example.org", Header: {"Accept": "text/html"}}
+    Checks: [
+        {Check: "StatusCode", Expect: 200}  # must be okay
+        /* obsolete */
+        {Check: "Body", Contains: "foo"}
+        // no more checks
+    ]
+}
+`
+
+func TestDocumentFormat(t *testing.T) {
+	doc, err := ParseDocument([]byte(formatTest))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	want := doc.Interface()
+	got := string(doc.Format())
+	if got != formatWant {
+		t.Fatalf("Got\n%s", got)
+	}
+
+	doc, err = ParseDocument([]byte(got))
+	if err != nil {
+		t.Fatalf("Cannot parse formatted text: %s", err)
+	}
+	if !reflect.DeepEqual(doc.Interface(), want) {
+		t.Errorf("Formatting changed value to %#v", doc.Interface())
+	}
+	if again := string(doc.Format()); again != got {
+		t.Errorf("Formatting is not idempotent:\n%s", again)
+	}
+}
+
+func TestDocumentFormatBraceless(t *testing.T) {
+	doc, err := ParseDocument([]byte("// top\nb: 1\n\n\na: [1,2]\n# end\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if got := string(doc.Format()); got != "// top\nb: 1\n\na: [1, 2]\n# end\n" {
+		t.Errorf("Got\n%s", got)
+	}
+}
+
+func TestReorder(t *testing.T) {
+	doc, err := ParseDocument([]byte("{c: 1, b: 2, x: 3, a: 4}"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	doc.Root.Reorder([]string{"a", "b", "z", "a"})
+	if got := doc.Root.Keys(); !reflect.DeepEqual(got, []string{"a", "b", "c", "x"}) {
+		t.Errorf("Got %v", got)
+	}
+	if got := string(doc.Format()); got != "{\n    a: 4\n    b: 2\n    c: 1\n    x: 3\n}\n" {
+		t.Errorf("Got %s", got)
+	}
+}
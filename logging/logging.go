@@ -0,0 +1,203 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package logging provides a small leveled and structured logging interface.
+//
+// Tests and suites log records consisting of a level, a message and fields
+// like the name of the suite and the test. The records can be written as
+// plain text or as JSON lines (e.g. for ingestion by a CI system) or handed
+// to any other logging library like zap or log/slog via a LoggerFunc:
+//     levels := []slog.Level{slog.LevelError, slog.LevelInfo, slog.LevelDebug, slog.LevelDebug - 4}
+//     logger := logging.LoggerFunc(func(level logging.Level, msg string, fields ...logging.Field) {
+//         args := make([]interface{}, 0, 2*len(fields))
+//         for _, f := range fields {
+//             args = append(args, f.Key, f.Value)
+//         }
+//         slog.Log(context.Background(), levels[level], msg, args...)
+//     })
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log record. Higher levels are more verbose and
+// correspond to the Verbosity of a test needed to log them.
+type Level int
+
+// The levels of log records.
+const (
+	Error Level = iota // Logged at Verbosity 0 and above.
+	Info               // Logged at Verbosity 1 and above.
+	Debug              // Logged at Verbosity 2 and above.
+	Trace              // Logged at Verbosity 3 and above.
+)
+
+var levelNames = []string{"ERROR", "INFO", "DEBUG", "TRACE"}
+
+func (l Level) String() string {
+	if l < 0 || int(l) >= len(levelNames) {
+		return fmt.Sprintf("LEVEL%d", int(l))
+	}
+	return levelNames[l]
+}
+
+// The keys of the fields used by package ht and suite.
+const (
+	SuiteKey = "suite" // SuiteKey is the name of the suite.
+	TestKey  = "test"  // TestKey is the name of the test.
+	SeqNoKey = "seqno" // SeqNoKey is the sequence number like "Main-03".
+)
+
+// Field is a key-value pair attached to a log record.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F is a shorthand to construct a Field.
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Logger logs structured records. Implementations must be safe for
+// concurrent use.
+type Logger interface {
+	Log(level Level, msg string, fields ...Field)
+}
+
+// LoggerFunc is an adapter to allow the use of an ordinary function as
+// a Logger.
+type LoggerFunc func(level Level, msg string, fields ...Field)
+
+// Log implements Logger.
+func (f LoggerFunc) Log(level Level, msg string, fields ...Field) {
+	f(level, msg, fields...)
+}
+
+// With returns a Logger which adds fields to each record logged to l.
+// With returns nil if l is nil.
+func With(l Logger, fields ...Field) Logger {
+	if l == nil {
+		return nil
+	}
+	if len(fields) == 0 {
+		return l
+	}
+	if w, ok := l.(withLogger); ok {
+		return withLogger{
+			logger: w.logger,
+			fields: append(append([]Field(nil), w.fields...), fields...),
+		}
+	}
+	return withLogger{logger: l, fields: fields}
+}
+
+type withLogger struct {
+	logger Logger
+	fields []Field
+}
+
+func (w withLogger) Log(level Level, msg string, fields ...Field) {
+	all := make([]Field, 0, len(w.fields)+len(fields))
+	all = append(all, w.fields...)
+	all = append(all, fields...)
+	w.logger.Log(level, msg, all...)
+}
+
+// Printer adapts l to the Printf method used by the older logging
+// interfaces in this module: Each Printf call is logged at the given level.
+func Printer(l Logger, level Level, fields ...Field) interface {
+	Printf(format string, a ...interface{})
+} {
+	return printer{logger: l, level: level, fields: fields}
+}
+
+type printer struct {
+	logger Logger
+	level  Level
+	fields []Field
+}
+
+func (p printer) Printf(format string, a ...interface{}) {
+	msg := strings.TrimSuffix(fmt.Sprintf(format, a...), "\n")
+	p.logger.Log(p.level, msg, p.fields...)
+}
+
+// ----------------------------------------------------------------------------
+// Text and JSON output
+
+// NewText returns a Logger writing one line per record to w in the form
+//     INFO  Running suite="Login" test="Login page" seqno="Main-01"
+func NewText(w io.Writer) Logger {
+	return &writerLogger{w: w, format: formatText}
+}
+
+// NewJSON returns a Logger writing one JSON object per line to w like
+//     {"time":"2017-07-01T10:11:12.1234Z","level":"INFO","msg":"Running","suite":"Login"}
+// Fields named time, level or msg are dropped.
+func NewJSON(w io.Writer) Logger {
+	return &writerLogger{w: w, format: formatJSON}
+}
+
+// writerLogger writes each formatted record in a single write to w.
+type writerLogger struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format func(buf *bytes.Buffer, level Level, msg string, fields []Field)
+}
+
+func (l *writerLogger) Log(level Level, msg string, fields ...Field) {
+	buf := &bytes.Buffer{}
+	l.format(buf, level, msg, fields)
+	l.mu.Lock()
+	l.w.Write(buf.Bytes())
+	l.mu.Unlock()
+}
+
+func formatText(buf *bytes.Buffer, level Level, msg string, fields []Field) {
+	fmt.Fprintf(buf, "%-5s %s", level, msg)
+	for _, f := range fields {
+		fmt.Fprintf(buf, " %s=%q", f.Key, fmt.Sprint(f.Value))
+	}
+	buf.WriteByte('\n')
+}
+
+func formatJSON(buf *bytes.Buffer, level Level, msg string, fields []Field) {
+	buf.WriteString(`{"time":`)
+	writeJSON(buf, time.Now().UTC().Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJSON(buf, level.String())
+	buf.WriteString(`,"msg":`)
+	writeJSON(buf, msg)
+	for _, f := range fields {
+		if f.Key == "time" || f.Key == "level" || f.Key == "msg" {
+			continue
+		}
+		buf.WriteByte(',')
+		writeJSON(buf, f.Key)
+		buf.WriteByte(':')
+		writeJSON(buf, f.Value)
+	}
+	buf.WriteString("}\n")
+}
+
+// writeJSON writes v as JSON to buf. Values which cannot be marshaled are
+// written as their fmt representation.
+func writeJSON(buf *bytes.Buffer, v interface{}) {
+	if err, ok := v.(error); ok {
+		v = err.Error()
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	buf.Write(data)
+}
@@ -0,0 +1,79 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := With(NewText(buf), F(SuiteKey, "Login"))
+	logger = With(logger, F(TestKey, "Login page"))
+	logger.Log(Info, "Running", F(SeqNoKey, "Main-01"))
+	logger.Log(Error, "Boom", F("code", 42))
+
+	want := `INFO  Running suite="Login" test="Login page" seqno="Main-01"
+ERROR Boom suite="Login" test="Login page" code="42"
+`
+	if got := buf.String(); got != want {
+		t.Errorf("Got\n%s\nWant\n%s", got, want)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := With(NewJSON(buf), F(SuiteKey, "Login"))
+	logger.Log(Debug, "Check failed", F("err", errors.New("bad status")),
+		F("n", 3), F("msg", "dropped"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Got %d lines: %q", len(lines), buf.String())
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Unexpected error %s in %q", err, lines[0])
+	}
+	for key, want := range map[string]interface{}{
+		"level": "DEBUG",
+		"msg":   "Check failed",
+		"suite": "Login",
+		"err":   "bad status",
+		"n":     3.0,
+	} {
+		if got := record[key]; got != want {
+			t.Errorf("%s: got %v, want %v", key, got, want)
+		}
+	}
+	if _, ok := record["time"]; !ok {
+		t.Errorf("Missing time in %q", lines[0])
+	}
+}
+
+func TestLoggerFunc(t *testing.T) {
+	var got []string
+	logger := With(LoggerFunc(func(level Level, msg string, fields ...Field) {
+		got = append(got, level.String(), msg)
+		for _, f := range fields {
+			got = append(got, f.Key)
+		}
+	}), F(TestKey, "x"))
+	logger.Log(Trace, "wire", F("size", 12))
+	if s := strings.Join(got, " "); s != "TRACE wire test size" {
+		t.Errorf("Got %q", s)
+	}
+	Printer(logger, Error).Printf("mock %s called\n", "M")
+	if s := strings.Join(got[4:], " "); s != "ERROR mock M called test" {
+		t.Errorf("Got %q", s)
+	}
+	if With(nil, F("a", 1)) != nil {
+		t.Errorf("With(nil) not nil")
+	}
+}
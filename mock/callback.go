@@ -0,0 +1,124 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mock
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/scope"
+)
+
+// ----------------------------------------------------------------------------
+// Callbacks
+
+// Callback is an outgoing HTTP request made by a mock after it has sent
+// its response. Callbacks simulate asynchronous notifications (webhooks)
+// of the mocked service, e.g. a payment provider confirming a payment:
+//     {
+//         Delay: "2s"
+//         Method: "POST"
+//         URL: "http://localhost:8080/payment/{{id}}/notify"
+//         Body: "{\"id\": \"{{id}}\", \"status\": \"paid\"}"
+//         Checks: [ {Check: "StatusCode", Expect: 200} ]
+//     }
+// URL, header values and Body undergo the same variable replacement
+// (or template execution) as the response of the mock.
+type Callback struct {
+	// Delay after sending the response before the callback is made.
+	Delay time.Duration
+
+	// Method of the callback request. Defaults to POST.
+	Method string
+
+	// URL to call.
+	URL string
+
+	// Header of the callback request.
+	Header http.Header
+
+	// Body of the callback request. The @file syntax is supported.
+	Body string
+
+	// Checks are applied to the response to the callback request.
+	Checks ht.CheckList
+}
+
+// callback prepares the callbacks of m and fires them after their delay.
+// The executed callbacks are reported to the Monitor of m.
+func (m *Mock) callback(replace func(string) (string, error), vars scope.Variables) {
+	ctx := m.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	for i, cb := range m.Callbacks {
+		test, err := cb.test(replace, vars)
+		test.Name = fmt.Sprintf("Callback %d of %s", i+1, m.Name)
+		if m.Monitor != nil {
+			m.pending.Add(1)
+		}
+		go func(delay time.Duration) {
+			if err != nil {
+				test.Result.Status, test.Result.Error = ht.Bogus, err
+			} else {
+				timer := time.NewTimer(delay)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+				}
+				test.RunContext(ctx)
+			}
+			if m.Log != nil {
+				m.Log.Printf("Mock %s: %s: %s %s: %s",
+					m.Name, test.Name, test.Request.Method, test.Request.URL,
+					test.Result.Status)
+			}
+			if m.Monitor == nil {
+				return
+			}
+			test.SetMetadata("MockID", fmt.Sprintf("%p", m))
+			test.SetMetadata("Filename", vars["MOCK_NAME"])
+			m.Monitor <- test
+			m.pending.Done()
+		}(cb.Delay)
+	}
+}
+
+// test constructs the ht.Test executing cb.
+func (cb Callback) test(replace func(string) (string, error), vars scope.Variables) (*ht.Test, error) {
+	test := &ht.Test{
+		Description: "Autogenerated callback of a mock.",
+		Request: ht.Request{
+			Method: cb.Method,
+			Header: make(http.Header, len(cb.Header)),
+		},
+		Checks:    cb.Checks,
+		Variables: vars,
+	}
+	if test.Request.Method == "" {
+		test.Request.Method = http.MethodPost
+	}
+	var err error
+	if test.Request.URL, err = replace(cb.URL); err != nil {
+		return test, fmt.Errorf("mock: cannot construct callback URL: %s", err)
+	}
+	for key, vals := range cb.Header {
+		for _, v := range vals {
+			hv, err := replace(v)
+			if err != nil {
+				return test, fmt.Errorf("mock: cannot construct callback header %s: %s", key, err)
+			}
+			test.Request.Header.Add(key, hv)
+		}
+	}
+	if test.Request.Body, err = replace(cb.Body); err != nil {
+		return test, fmt.Errorf("mock: cannot construct callback body: %s", err)
+	}
+	return test, nil
+}
@@ -0,0 +1,439 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mock
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// ----------------------------------------------------------------------------
+// gRPC
+
+// GRPC turns a mock into a unary gRPC method. The URL of such a mock has
+// the path "/package.Service/Method" and Method POST. The response body
+// is the JSON form of the response message which is encoded as protobuf
+// according to the Response descriptor. The fields of the request message
+// described by Request are available as variables named like the field,
+// fields of nested messages as "outer.inner" and elements of repeated
+// fields as "name[0]", "name[1]", ... E.g. for a helloworld.Greeter:
+//     URL: "http://localhost:50051/helloworld.Greeter/SayHello"
+//     Method: "POST"
+//     GRPC: {
+//         Request:  { "name": {Number: 1, Type: "string"} }
+//         Response: { "message": {Number: 1, Type: "string"} }
+//     }
+//     Response: { Body: "{\"message\": \"Hello {{name}}\"}" }
+// Mocks with GRPC are served via HTTP/2, also on plain HTTP (h2c).
+type GRPC struct {
+	// Request describes the fields of the request message. Fields not
+	// described are ignored.
+	Request Message
+
+	// Response describes the fields of the response message.
+	Response Message
+
+	// Status is the gRPC status code sent. The default 0 is OK.
+	// No response message is sent for a non-zero Status.
+	Status int
+
+	// Message is sent as grpc-message with a non-zero Status.
+	Message string
+}
+
+// Message describes the fields of a protobuf message indexed by the
+// JSON name of the field.
+type Message map[string]Field
+
+// Field describes one field of a protobuf message.
+type Field struct {
+	// Number is the field number.
+	Number int
+
+	// Type of the field: One of double, float, int32, int64, uint32,
+	// uint64, sint32, sint64, fixed32, fixed64, sfixed32, sfixed64,
+	// bool, string, bytes, enum or message. Bytes are base64 encoded in
+	// JSON, enums are given by their number.
+	Type string
+
+	// Repeated fields are given as JSON arrays.
+	Repeated bool
+
+	// Message describes the fields of Type message.
+	Message Message
+}
+
+// frame encodes the JSON body as a length prefixed gRPC message.
+func (g *GRPC) frame(body string) (string, error) {
+	if g.Status != 0 {
+		return "", nil
+	}
+	dec := json.NewDecoder(bytes.NewBufferString(body))
+	dec.UseNumber()
+	var v map[string]interface{}
+	if err := dec.Decode(&v); err != nil {
+		return "", fmt.Errorf("response body is not a JSON object: %s", err)
+	}
+	msg, err := g.Response.encode(v)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(buf[1:], uint32(len(msg))) // buf[0]==0: uncompressed
+	return string(append(buf, msg...)), nil
+}
+
+// variables decodes the length prefixed gRPC request message in body
+// into variables as described in GRPC.
+func (g *GRPC) variables(body []byte) (map[string]string, error) {
+	if len(body) < 5 {
+		return nil, fmt.Errorf("gRPC request too short")
+	}
+	if body[0] != 0 {
+		return nil, fmt.Errorf("compressed gRPC requests are not supported")
+	}
+	n := binary.BigEndian.Uint32(body[1:5])
+	if uint64(n) > uint64(len(body)-5) {
+		return nil, fmt.Errorf("truncated gRPC request")
+	}
+	vars := make(map[string]string)
+	err := g.Request.decode(body[5:5+n], "", vars)
+	if err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// trailer sends the gRPC status as HTTP trailer.
+func (g *GRPC) trailer(w http.ResponseWriter) {
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(g.Status))
+	if g.Status != 0 && g.Message != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", url.PathEscape(g.Message))
+	}
+}
+
+// encode v in protobuf wire format.
+func (m Message) encode(v map[string]interface{}) ([]byte, error) {
+	names := make([]string, 0, len(v))
+	for name := range v {
+		if _, ok := m[name]; !ok {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return m[names[i]].Number < m[names[j]].Number
+	})
+
+	buf := []byte{}
+	for _, name := range names {
+		field, val := m[name], v[name]
+		if val == nil {
+			continue
+		}
+		vals := []interface{}{val}
+		if field.Repeated {
+			var ok bool
+			if vals, ok = val.([]interface{}); !ok {
+				return nil, fmt.Errorf("field %q: repeated field needs array", name)
+			}
+		}
+		for _, val := range vals {
+			var err error
+			buf, err = field.append(buf, val)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %s", name, err)
+			}
+		}
+	}
+	return buf, nil
+}
+
+// Protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// append the encoding of a single value v of f to buf.
+func (f Field) append(buf []byte, v interface{}) ([]byte, error) {
+	key := func(wire int) []byte {
+		return binary.AppendUvarint(buf, uint64(f.Number)<<3|uint64(wire))
+	}
+
+	switch f.Type {
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("need string, got %T", v)
+		}
+		return appendBytes(key(wireBytes), []byte(s)), nil
+	case "bytes":
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("need base64 string, got %T", v)
+		}
+		data, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		return appendBytes(key(wireBytes), data), nil
+	case "message":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("need object, got %T", v)
+		}
+		data, err := f.Message.encode(obj)
+		if err != nil {
+			return nil, err
+		}
+		return appendBytes(key(wireBytes), data), nil
+	case "bool":
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("need bool, got %T", v)
+		}
+		u := uint64(0)
+		if b {
+			u = 1
+		}
+		return binary.AppendUvarint(key(wireVarint), u), nil
+	case "double", "float":
+		x, err := number(v)
+		if err != nil {
+			return nil, err
+		}
+		if f.Type == "float" {
+			return binary.LittleEndian.AppendUint32(key(wireFixed32),
+				math.Float32bits(float32(x))), nil
+		}
+		return binary.LittleEndian.AppendUint64(key(wireFixed64),
+			math.Float64bits(x)), nil
+	}
+
+	// Integer types.
+	s := fmt.Sprint(v) // json.Number or string (int64 in proto3 JSON)
+	switch f.Type {
+	case "uint32", "uint64", "fixed32", "fixed64":
+		u, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		switch f.Type {
+		case "fixed32":
+			return binary.LittleEndian.AppendUint32(key(wireFixed32), uint32(u)), nil
+		case "fixed64":
+			return binary.LittleEndian.AppendUint64(key(wireFixed64), u), nil
+		}
+		return binary.AppendUvarint(key(wireVarint), u), nil
+	case "int32", "int64", "enum", "sint32", "sint64", "sfixed32", "sfixed64":
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		switch f.Type {
+		case "sint32", "sint64":
+			return binary.AppendUvarint(key(wireVarint), uint64(i<<1)^uint64(i>>63)), nil
+		case "sfixed32":
+			return binary.LittleEndian.AppendUint32(key(wireFixed32), uint32(i)), nil
+		case "sfixed64":
+			return binary.LittleEndian.AppendUint64(key(wireFixed64), uint64(i)), nil
+		}
+		return binary.AppendUvarint(key(wireVarint), uint64(i)), nil
+	}
+	return nil, fmt.Errorf("unknown type %q", f.Type)
+}
+
+// decode the protobuf wire format data of m into vars, prefixing the
+// variable names with prefix. Repeated fields are numbered in order.
+func (m Message) decode(data []byte, prefix string, vars map[string]string) error {
+	byNumber := make(map[uint64]string, len(m))
+	for name, field := range m {
+		byNumber[uint64(field.Number)] = name
+	}
+	count := make(map[string]int)
+	set := func(name string, field Field, value string) {
+		if field.Repeated {
+			vars[fmt.Sprintf("%s%s[%d]", prefix, name, count[name])] = value
+			count[name]++
+			return
+		}
+		vars[prefix+name] = value
+	}
+
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return fmt.Errorf("malformed field key")
+		}
+		data = data[n:]
+		wire := int(key & 7)
+		var raw []byte // fixed size or length delimited value
+		var u uint64   // varint value
+		switch wire {
+		case wireVarint:
+			u, n = binary.Uvarint(data)
+			if n <= 0 {
+				return fmt.Errorf("malformed varint")
+			}
+		case wireFixed64, wireFixed32:
+			n = 8
+			if wire == wireFixed32 {
+				n = 4
+			}
+			if len(data) < n {
+				return fmt.Errorf("truncated fixed value")
+			}
+			raw = data[:n]
+		case wireBytes:
+			l, k := binary.Uvarint(data)
+			if k <= 0 || l > uint64(len(data)-k) {
+				return fmt.Errorf("malformed length")
+			}
+			raw, n = data[k:k+int(l)], k+int(l)
+		default:
+			return fmt.Errorf("unsupported wire type %d", wire)
+		}
+		data = data[n:]
+
+		name, ok := byNumber[key>>3]
+		if !ok {
+			continue // unknown fields are ignored
+		}
+		field := m[name]
+		switch {
+		case field.Type == "message":
+			if wire != wireBytes {
+				return fmt.Errorf("field %q: bad wire type %d", name, wire)
+			}
+			p := name + "."
+			if field.Repeated {
+				p = fmt.Sprintf("%s[%d].", name, count[name])
+				count[name]++
+			}
+			if err := field.Message.decode(raw, prefix+p, vars); err != nil {
+				return fmt.Errorf("field %q: %s", name, err)
+			}
+		case wire == wireBytes && field.Type != "string" && field.Type != "bytes":
+			// Packed repeated scalars.
+			for len(raw) > 0 {
+				var value string
+				var err error
+				value, raw, err = field.scalar(raw)
+				if err != nil {
+					return fmt.Errorf("field %q: %s", name, err)
+				}
+				set(name, field, value)
+			}
+		default:
+			value, err := field.value(wire, u, raw)
+			if err != nil {
+				return fmt.Errorf("field %q: %s", name, err)
+			}
+			set(name, field, value)
+		}
+	}
+	return nil
+}
+
+// scalar decodes the first element of a packed repeated field of f from
+// data and returns it with the remaining data.
+func (f Field) scalar(data []byte) (string, []byte, error) {
+	switch f.Type {
+	case "fixed32", "sfixed32", "float":
+		if len(data) < 4 {
+			return "", nil, fmt.Errorf("truncated packed value")
+		}
+		value, err := f.value(wireFixed32, 0, data[:4])
+		return value, data[4:], err
+	case "fixed64", "sfixed64", "double":
+		if len(data) < 8 {
+			return "", nil, fmt.Errorf("truncated packed value")
+		}
+		value, err := f.value(wireFixed64, 0, data[:8])
+		return value, data[8:], err
+	}
+	u, n := binary.Uvarint(data)
+	if n <= 0 {
+		return "", nil, fmt.Errorf("malformed packed varint")
+	}
+	value, err := f.value(wireVarint, u, nil)
+	return value, data[n:], err
+}
+
+// value formats a single value of f read with the given wire type as
+// varint u or fixed size or length delimited raw bytes.
+func (f Field) value(wire int, u uint64, raw []byte) (string, error) {
+	want := wireVarint
+	switch f.Type {
+	case "string", "bytes":
+		want = wireBytes
+	case "fixed32", "sfixed32", "float":
+		want = wireFixed32
+	case "fixed64", "sfixed64", "double":
+		want = wireFixed64
+	}
+	if wire != want {
+		return "", fmt.Errorf("bad wire type %d for %s", wire, f.Type)
+	}
+
+	switch f.Type {
+	case "string":
+		return string(raw), nil
+	case "bytes":
+		return base64.StdEncoding.EncodeToString(raw), nil
+	case "bool":
+		return strconv.FormatBool(u != 0), nil
+	case "int32", "enum":
+		return strconv.FormatInt(int64(int32(u)), 10), nil
+	case "int64":
+		return strconv.FormatInt(int64(u), 10), nil
+	case "uint32", "uint64":
+		return strconv.FormatUint(u, 10), nil
+	case "sint32", "sint64":
+		return strconv.FormatInt(int64(u>>1)^-int64(u&1), 10), nil
+	case "fixed32":
+		return strconv.FormatUint(uint64(binary.LittleEndian.Uint32(raw)), 10), nil
+	case "sfixed32":
+		return strconv.FormatInt(int64(int32(binary.LittleEndian.Uint32(raw))), 10), nil
+	case "fixed64":
+		return strconv.FormatUint(binary.LittleEndian.Uint64(raw), 10), nil
+	case "sfixed64":
+		return strconv.FormatInt(int64(binary.LittleEndian.Uint64(raw)), 10), nil
+	case "float":
+		x := math.Float32frombits(binary.LittleEndian.Uint32(raw))
+		return strconv.FormatFloat(float64(x), 'g', -1, 32), nil
+	case "double":
+		x := math.Float64frombits(binary.LittleEndian.Uint64(raw))
+		return strconv.FormatFloat(x, 'g', -1, 64), nil
+	}
+	return "", fmt.Errorf("unknown type %q", f.Type)
+}
+
+func appendBytes(buf, data []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
+
+// number converts a JSON number (or numeric string) to float64.
+func number(v interface{}) (float64, error) {
+	switch x := v.(type) {
+	case json.Number:
+		return x.Float64()
+	case string:
+		return strconv.ParseFloat(x, 64)
+	}
+	return 0, fmt.Errorf("need number, got %T", v)
+}
@@ -0,0 +1,136 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vdobler/ht/ht"
+)
+
+// ----------------------------------------------------------------------------
+// Request matching
+
+// Match contains conditions the incoming request must fulfill for a mock
+// to apply in addition to Method and URL. All given conditions must be
+// fulfilled. Overlapping mocks (same Method and URL) are tried in order of
+// decreasing Priority.
+type Match struct {
+	// Header maps header names to the condition the header value
+	// must fulfill. A missing header is treated as the empty string.
+	Header map[string]ht.Condition
+
+	// Query maps query parameter names to the condition the parameter
+	// value must fulfill.
+	Query map[string]ht.Condition
+
+	// Body is the condition the request body must fulfill.
+	Body ht.Condition
+
+	// JSON maps elements of a JSON request body to the condition the
+	// element must fulfill. Elements are given like "user.roles.0";
+	// string elements are matched unquoted, all other elements are
+	// matched as their JSON encoding.
+	JSON map[string]ht.Condition
+}
+
+// empty reports whether m contains no conditions.
+func (m Match) empty() bool {
+	return len(m.Header) == 0 && len(m.Query) == 0 && m.Body.IsZero() &&
+		len(m.JSON) == 0
+}
+
+// compile all conditions in m.
+func (m *Match) compile() error {
+	for _, conds := range []map[string]ht.Condition{m.Header, m.Query, m.JSON} {
+		for k, c := range conds {
+			if err := c.Compile(); err != nil {
+				return fmt.Errorf("condition on %s: %s", k, err)
+			}
+			conds[k] = c
+		}
+	}
+	return m.Body.Compile()
+}
+
+// matches reports whether r fulfills all conditions of m. The body of r
+// is restored after reading.
+func (m Match) matches(r *http.Request) bool {
+	for h, c := range m.Header {
+		if c.Fulfilled(r.Header.Get(h)) != nil {
+			return false
+		}
+	}
+	query := r.URL.Query()
+	for q, c := range m.Query {
+		if c.Fulfilled(query.Get(q)) != nil {
+			return false
+		}
+	}
+	if m.Body.IsZero() && len(m.JSON) == 0 {
+		return true
+	}
+
+	var body []byte
+	if r.Body != nil {
+		body, _ = ioutil.ReadAll(r.Body)
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	if m.Body.FulfilledBytes(body) != nil {
+		return false
+	}
+	if len(m.JSON) == 0 {
+		return true
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return false
+	}
+	for elem, c := range m.JSON {
+		s, ok := jsonElement(v, elem)
+		if !ok || c.Fulfilled(s) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonElement returns the element of v selected by the dot separated path.
+func jsonElement(v interface{}, path string) (string, bool) {
+	for _, elem := range strings.Split(path, ".") {
+		if elem == "" {
+			continue
+		}
+		switch x := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = x[elem]; !ok {
+				return "", false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(elem)
+			if err != nil || i < 0 || i >= len(x) {
+				return "", false
+			}
+			v = x[i]
+		default:
+			return "", false
+		}
+	}
+	if s, ok := v.(string); ok {
+		return s, true
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+	return string(buf), true
+}
@@ -40,12 +40,15 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
-	"strings"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/vdobler/ht/ht"
 	"github.com/vdobler/ht/scope"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 // Log is the interface used for logging.
@@ -106,6 +109,34 @@ type Mock struct {
 	// Response to send for this mock.
 	Response Response
 
+	// Responses, if non-empty, is a sequence of responses sent instead
+	// of Response: The first call gets the first response, the second
+	// call the second and so on. The last response is repeated once
+	// the sequence is exhausted.
+	Responses []Response
+
+	// Match contains additional conditions on the request (header,
+	// query parameters and body) which must be fulfilled for this mock
+	// to apply.
+	Match Match
+
+	// Priority determines the order in which mocks with the same Method
+	// and URL are considered: Higher priorities first.
+	Priority int
+
+	// Template turns the response body and header values into Go
+	// text/template templates executed with the incoming request as
+	// RequestData instead of the plain {{VARIABLE}} replacement.
+	Template bool
+
+	// GRPC, if non-nil, serves this mock as a unary gRPC method: The
+	// response body is the JSON form of the response message.
+	GRPC *GRPC
+
+	// WebSocket, if non-nil, serves this mock as a WebSocket endpoint
+	// with a scripted exchange of messages. Response is not used.
+	WebSocket *WebSocket
+
 	// Variables contains the default variables/values for this mock.
 	Variables scope.Variables
 
@@ -114,6 +145,22 @@ type Mock struct {
 	// response.
 	Map []Mapping
 
+	// Scenario is the name of the state machine this mock takes part in.
+	// All mocks served together share the state of their scenarios.
+	// Each scenario starts in state StateStarted.
+	Scenario string
+
+	// RequiredState, if non-empty, is the state the Scenario must be in
+	// for this mock to be applicable.
+	RequiredState string
+
+	// NewState, if non-empty, is the state the Scenario transitions to
+	// after this mock was served.
+	NewState string
+
+	// Callbacks are made after the response has been sent.
+	Callbacks []Callback
+
 	// Monitor is used to report invocations if this mock.
 	// The incomming request and the outgoing mocked response are encoded
 	// in a ht.Test. The optional results of the Checks are stored in the
@@ -121,10 +168,73 @@ type Mock struct {
 	// This is nonsensical but is the fastet way to get mocking up running.
 	Monitor chan *ht.Test
 
+	// Journal, if non-nil, records all requests received by this mock.
+	Journal *ht.Journal
+
 	// Log to report infos to.
 	Log Log
 
-	tls bool // served via https
+	tls    bool    // served via https
+	states *states // shared scenario states
+
+	mu      sync.Mutex
+	calls   int            // number of calls so far
+	pending sync.WaitGroup // callbacks not yet reported
+
+	ctx context.Context // cancels pending callbacks, see ProvideContext
+}
+
+// response returns the response for the current call to m.
+func (m *Mock) response() Response {
+	if len(m.Responses) == 0 {
+		return m.Response
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.calls
+	if i >= len(m.Responses) {
+		i = len(m.Responses) - 1
+	}
+	m.calls++
+	return m.Responses[i]
+}
+
+// StateStarted is the initial state of all scenarios.
+const StateStarted = "Started"
+
+// states keeps the current state of scenarios.
+type states struct {
+	mu      sync.Mutex
+	current map[string]string
+}
+
+func newStates() *states {
+	return &states{current: make(map[string]string)}
+}
+
+// get returns the current state of scenario.
+func (s *states) get(scenario string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if state, ok := s.current[scenario]; ok {
+		return state
+	}
+	return StateStarted
+}
+
+// set the state of scenario.
+func (s *states) set(scenario, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current[scenario] = state
+}
+
+// applicable reports whether m's RequiredState is fulfilled.
+func (m *Mock) applicable() bool {
+	if m.Scenario == "" || m.RequiredState == "" || m.states == nil {
+		return true
+	}
+	return m.states.get(m.Scenario) == m.RequiredState
 }
 
 // Response to send as mocked answer.
@@ -232,6 +342,14 @@ func (m *Mock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Consume request body and set up a "reversed" fake Test to run
 	// Checks against the request and extract variables from the request.
 	body, bodyerr := ioutil.ReadAll(r.Body)
+	m.Journal.Record(ht.JournalEntry{
+		Mock:   m.Name,
+		Time:   started,
+		Method: r.Method,
+		URL:    r.URL.String(),
+		Header: r.Header,
+		Body:   string(body),
+	})
 	// Restore r.Body for form parsing.
 	r.Body = ioutil.NopCloser(bytes.NewReader(body))
 	// Rewrite Cookie headers to Set-Cookie headers to allow standard ht
@@ -273,11 +391,29 @@ func (m *Mock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	extractions := faketest.Extract()
 
 	repl, scope := m.replacer(r, extractions)
+	resp := m.response()
+	status := resp.StatusCode
+	if status == 0 {
+		status = http.StatusOK // 200 is the default
+	}
 
-	// Body handling: Default variable replacement happens first, then
-	// @file and @vfile syntax is handled. This allows to read different
-	// files based on the variables extracted from the request.
-	preBody := repl.Replace(m.Response.Body)
+	// Body handling: Default variable replacement (or template execution)
+	// happens first, then @file and @vfile syntax is handled. This allows
+	// to read different files based on the variables extracted from the
+	// request.
+	replace := func(s string) (string, error) { return repl.Replace(s), nil }
+	if m.Template {
+		data := newRequestData(r, body, scope)
+		replace = func(s string) (string, error) { return execute(m.Name, s, data) }
+	}
+	preBody, err := replace(resp.Body)
+	if err != nil {
+		http.Error(w,
+			fmt.Sprintf("mock: cannot execute response template for mock %q: %s",
+				m.Name, err),
+			http.StatusInternalServerError)
+		return
+	}
 	sentBody, _, err := ht.FileData(preBody, scope)
 	if err != nil {
 		http.Error(w,
@@ -286,29 +422,74 @@ func (m *Mock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			http.StatusInternalServerError)
 		return
 	}
+	if m.GRPC != nil {
+		sentBody, err = m.GRPC.frame(sentBody)
+		if err != nil {
+			http.Error(w,
+				fmt.Sprintf("mock: cannot encode gRPC response for mock %q: %s",
+					m.Name, err),
+				http.StatusInternalServerError)
+			return
+		}
+		status = http.StatusOK
+		header := http.Header{"Content-Type": {"application/grpc"}}
+		for k, v := range resp.Header {
+			header[k] = v
+		}
+		resp.Header = header
+	}
 
 	// Write response to intermediate recorder for reuse in reporting.
 	recw := httptest.NewRecorder()
-	for key, vals := range m.Response.Header {
+	for key, vals := range resp.Header {
 		for _, v := range vals {
-			recw.Header().Add(key, repl.Replace(v))
+			hv, err := replace(v)
+			if err != nil {
+				http.Error(w,
+					fmt.Sprintf("mock: cannot execute header template for mock %q: %s",
+						m.Name, err),
+					http.StatusInternalServerError)
+				return
+			}
+			recw.Header().Add(key, hv)
 		}
 	}
-	recw.WriteHeader(m.Response.StatusCode)
+	recw.WriteHeader(status)
 
 	io.WriteString(recw, sentBody)
 	response := recw.Result()
 
-	// Send actual response.
-	for h, vs := range response.Header {
-		w.Header()[h] = vs
+	// Transition state before sending the response so that
+	// subsequent requests see the new state.
+	if m.Scenario != "" && m.NewState != "" && m.states != nil {
+		m.states.set(m.Scenario, m.NewState)
+		if m.Log != nil {
+			m.Log.Printf("Mock %s: scenario %q now in state %q",
+				m.Name, m.Scenario, m.NewState)
+		}
 	}
-	status := m.Response.StatusCode
-	if status == 0 {
-		status = http.StatusOK // 200 is the default
+
+	if m.WebSocket != nil {
+		// Upgrade connection and work through the script.
+		transcript, err := m.WebSocket.serve(w, r, replace)
+		response.StatusCode, response.Status = http.StatusSwitchingProtocols, "101 Switching Protocols"
+		sentBody = transcript
+		if err != nil {
+			reportStatus, reportError = ht.Fail, err
+		}
+	} else {
+		// Send actual response.
+		for h, vs := range response.Header {
+			w.Header()[h] = vs
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, sentBody)
+		if m.GRPC != nil {
+			m.GRPC.trailer(w)
+		}
 	}
-	w.WriteHeader(status)
-	io.WriteString(w, sentBody)
+
+	m.callback(replace, scope)
 
 	if m.Monitor == nil {
 		return
@@ -360,7 +541,7 @@ func (m *Mock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 // Construct a replacer for the response from the mux variables and
 // the extractions with extractions overwriting mux variables.
-func (m *Mock) replacer(r *http.Request, extractions scope.Variables) (*strings.Replacer, scope.Variables) {
+func (m *Mock) replacer(r *http.Request, extractions scope.Variables) (*scope.Replacer, scope.Variables) {
 	vars := scope.New(scope.Variables(mux.Vars(r)), m.Variables, false)
 
 	if m.ParseForm {
@@ -381,6 +562,18 @@ func (m *Mock) replacer(r *http.Request, extractions scope.Variables) (*strings.
 		}
 	}
 
+	if m.GRPC != nil && len(m.GRPC.Request) > 0 {
+		body, _ := ioutil.ReadAll(r.Body)
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		fields, err := m.GRPC.variables(body)
+		if err != nil && m.Log != nil {
+			m.Log.Printf("Mock %s cannot decode gRPC request: %s", m.Name, err)
+		}
+		for name, value := range fields {
+			vars[name] = value
+		}
+	}
+
 	vars = scope.New(extractions, vars, true)
 
 	// Work through manual variable setting.
@@ -488,10 +681,20 @@ func Serve(mocks []*Mock, notfound http.Handler, log Log, certFile, keyFile stri
 // groupMocks groups the mocks by their port number.
 func groupMocks(mocks []*Mock) (map[string][]*Mock, error) {
 	group := make(map[string][]*Mock)
+	st := newStates()
 	for _, m := range mocks {
 		if m.Disable {
 			continue
 		}
+		m.states = st
+		if err := m.Match.compile(); err != nil {
+			return nil, fmt.Errorf("mock %q: %s", m.Name, err)
+		}
+		if m.WebSocket != nil {
+			if err := m.WebSocket.compile(); err != nil {
+				return nil, fmt.Errorf("mock %q: %s", m.Name, err)
+			}
+		}
 		u, err := url.Parse(m.URL)
 		if err != nil {
 			return nil, err
@@ -520,20 +723,44 @@ func groupMocks(mocks []*Mock) (map[string][]*Mock, error) {
 
 func createServer(port string, mocks []*Mock, notfound http.Handler, log Log) *http.Server {
 	r := mux.NewRouter()
+	mocks = append([]*Mock(nil), mocks...)
+	sort.SliceStable(mocks, func(i, j int) bool {
+		return mocks[i].Priority > mocks[j].Priority
+	})
 	for _, m := range mocks {
 		u, _ := url.Parse(m.URL) // Cannot fail: validated during splitMocks.
 		if m.Method == "" {
 			m.Method = http.MethodGet
 		}
-		r.Handle(u.Path, m).Methods(m.Method)
+		route := r.Handle(u.Path, m).Methods(m.Method)
+		if m.Scenario != "" && m.RequiredState != "" {
+			mock := m
+			route.MatcherFunc(func(*http.Request, *mux.RouteMatch) bool {
+				return mock.applicable()
+			})
+		}
+		if !m.Match.empty() {
+			match := m.Match
+			route.MatcherFunc(func(req *http.Request, _ *mux.RouteMatch) bool {
+				return match.matches(req)
+			})
+		}
 		if log != nil {
 			log.Printf("Will handle %s %s", m.Method, m.URL)
 		}
 	}
 	r.NotFoundHandler = notfound
+	var handler http.Handler = r
+	for _, m := range mocks {
+		if m.GRPC != nil {
+			// gRPC needs HTTP/2, also without TLS.
+			handler = h2c.NewHandler(r, &http2.Server{})
+			break
+		}
+	}
 	return &http.Server{
 		Addr:    ":" + port,
-		Handler: r,
+		Handler: handler,
 	}
 }
 
@@ -567,6 +794,13 @@ type Control struct {
 	monitor        chan *ht.Test
 	monitoringDone chan bool
 	results        *[]*ht.Test
+	journal        *ht.Journal
+}
+
+// Journal returns the journal of all requests received by the provided
+// mocks (including stray requests).
+func (c Control) Journal() *ht.Journal {
+	return c.journal
 }
 
 // Provide starts the given mocks, it returns a control handle which
@@ -576,7 +810,15 @@ type Control struct {
 // The returned control handle must be Analyze'd to stop data collection
 // and prevent goroutine leaks.
 func Provide(mocks []*Mock, logger Log) (Control, error) {
+	return ProvideContext(context.Background(), mocks, logger)
+}
+
+// ProvideContext works like Provide but callbacks of the mocks which are
+// still waiting for their delay or are in flight are canceled once ctx is
+// done.
+func ProvideContext(ctx context.Context, mocks []*Mock, logger Log) (Control, error) {
 	monitor := make(chan *ht.Test)
+	journal := &ht.Journal{}
 	enabled := []*Mock{}
 
 	for _, m := range mocks {
@@ -584,6 +826,8 @@ func Provide(mocks []*Mock, logger Log) (Control, error) {
 			continue // Don't start disabled mocks.
 		}
 		m.Monitor = monitor
+		m.Journal = journal
+		m.ctx = ctx
 		enabled = append(enabled, m)
 	}
 	if len(enabled) == 0 {
@@ -594,6 +838,13 @@ func Provide(mocks []*Mock, logger Log) (Control, error) {
 	notFoundHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		body, _ := ioutil.ReadAll(r.Body)
 		u := r.URL.String()
+		journal.Record(ht.JournalEntry{
+			Time:   time.Now(),
+			Method: r.Method,
+			URL:    u,
+			Header: r.Header,
+			Body:   string(body),
+		})
 		report := &ht.Test{
 			Name: "Not Found " + u,
 			Result: ht.Result{
@@ -624,6 +875,7 @@ func Provide(mocks []*Mock, logger Log) (Control, error) {
 		monitor:        monitor,
 		monitoringDone: monitoringDone,
 		results:        &results,
+		journal:        journal,
 	}
 
 	// Start goroutine which collects results from the called mocks.
@@ -653,6 +905,9 @@ func Analyse(ctrl Control) []*ht.Test {
 	// Stop serving mocks and data collection.
 	ctrl.stopMocks <- true
 	<-ctrl.stopMocks
+	for _, m := range ctrl.mocks {
+		m.pending.Wait() // outstanding callbacks report to monitor
+	}
 	close(ctrl.monitor)
 	<-ctrl.monitoringDone
 
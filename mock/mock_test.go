@@ -6,12 +6,15 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/vdobler/ht/ht"
 	"github.com/vdobler/ht/scope"
+	"golang.org/x/net/websocket"
 )
 
 type mapTest struct {
@@ -267,3 +270,301 @@ func TestServe(t *testing.T) {
 	stop <- true
 	<-stop
 }
+
+func TestStatefulMocks(t *testing.T) {
+	mocks := []*Mock{
+		{
+			Name: "Missing", Method: "GET", URL: "http://localhost:8880/user",
+			Scenario: "user", RequiredState: StateStarted,
+			Response: Response{StatusCode: 404},
+		},
+		{
+			Name: "Create", Method: "POST", URL: "http://localhost:8880/user",
+			Scenario: "user", NewState: "created",
+			Response: Response{StatusCode: 201},
+		},
+		{
+			Name: "Present", Method: "GET", URL: "http://localhost:8880/user",
+			Scenario: "user", RequiredState: "created",
+			Response: Response{StatusCode: 200, Body: "Joe"},
+		},
+	}
+	group, err := groupMocks(mocks)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	handler := createServer("8880", group["8880"], nil, nil).Handler
+
+	for i, tc := range []struct {
+		method string
+		want   int
+	}{
+		{"GET", 404},
+		{"GET", 404},
+		{"POST", 201},
+		{"GET", 200},
+		{"GET", 200},
+	} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(tc.method, "http://localhost:8880/user", nil))
+		if rr.Code != tc.want {
+			t.Errorf("%d. %s: got %d, want %d", i, tc.method, rr.Code, tc.want)
+		}
+	}
+}
+
+func TestSequencedAndConditionalMocks(t *testing.T) {
+	mocks := []*Mock{
+		{
+			Name: "Flaky", Method: "GET", URL: "http://localhost:8880/flaky",
+			Responses: []Response{{StatusCode: 500}, {StatusCode: 503}, {StatusCode: 200}},
+		},
+		{
+			Name: "Fallback", Method: "POST", URL: "http://localhost:8880/order",
+			Response: Response{StatusCode: 400},
+		},
+		{
+			Name: "Premium", Method: "POST", URL: "http://localhost:8880/order",
+			Priority: 10,
+			Match: Match{
+				Header: map[string]ht.Condition{"X-Tenant": {Equals: "acme"}},
+				Query:  map[string]ht.Condition{"express": {Equals: "1"}},
+				JSON:   map[string]ht.Condition{"items.0.qty": {Regexp: "^[1-9]$"}},
+			},
+			Response: Response{StatusCode: 201},
+		},
+	}
+	group, err := groupMocks(mocks)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	handler := createServer("8880", group["8880"], nil, nil).Handler
+
+	for i, want := range []int{500, 503, 200, 200} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "http://localhost:8880/flaky", nil))
+		if rr.Code != want {
+			t.Errorf("%d. call: got %d, want %d", i+1, rr.Code, want)
+		}
+	}
+
+	for i, tc := range []struct {
+		query, tenant, body string
+		want                int
+	}{
+		{"?express=1", "acme", `{"items": [{"qty": 3}]}`, 201},
+		{"?express=1", "acme", `{"items": [{"qty": 12}]}`, 400},
+		{"?express=1", "other", `{"items": [{"qty": 3}]}`, 400},
+		{"", "acme", `{"items": [{"qty": 3}]}`, 400},
+		{"?express=1", "acme", `not json`, 400},
+	} {
+		req := httptest.NewRequest("POST", "http://localhost:8880/order"+tc.query,
+			strings.NewReader(tc.body))
+		req.Header.Set("X-Tenant", tc.tenant)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tc.want {
+			t.Errorf("%d. got %d, want %d", i, rr.Code, tc.want)
+		}
+	}
+}
+
+func TestTemplateMock(t *testing.T) {
+	m := &Mock{
+		Name:     "User",
+		Method:   "POST",
+		URL:      "http://localhost:8880/users/{id}",
+		Template: true,
+		Variables: scope.Variables{
+			"greeting": "Hello",
+		},
+		Response: Response{
+			Header: http.Header{"X-User": []string{"{{index .Segments 1}}"}},
+			Body:   `{{.Vars.greeting}} {{.JSON.name | upper}} #{{.Vars.id}} ({{.Query.lang}}, {{.Header.Accept}}) {{json .JSON.roles}}`,
+		},
+	}
+	group, err := groupMocks([]*Mock{m})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	handler := createServer("8880", group["8880"], nil, nil).Handler
+
+	req := httptest.NewRequest("POST", "http://localhost:8880/users/123?lang=de",
+		strings.NewReader(`{"name": "joe", "roles": ["a", "b"]}`))
+	req.Header.Set("Accept", "text/plain")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if want := `Hello JOE #123 (de, text/plain) ["a","b"]`; rr.Body.String() != want {
+		t.Errorf("Got body %q, want %q", rr.Body.String(), want)
+	}
+	if got := rr.Header().Get("X-User"); got != "123" {
+		t.Errorf("Got X-User %q", got)
+	}
+
+	m.Response.Body = "{{.Broken"
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "http://localhost:8880/users/1", nil))
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Got %d for broken template", rr.Code)
+	}
+}
+
+func TestCallbackMock(t *testing.T) {
+	received := make(chan string, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received <- r.Method + " " + r.URL.Path + " " + r.Header.Get("X-Order") + " " + string(body)
+	}))
+	defer webhook.Close()
+
+	monitor := make(chan *ht.Test, 10)
+	m := &Mock{
+		Name:   "Order",
+		Method: "POST",
+		URL:    "http://localhost:8880/order/{id}",
+		Callbacks: []Callback{{
+			Delay:  10 * time.Millisecond,
+			URL:    webhook.URL + "/notify/{{id}}",
+			Header: http.Header{"X-Order": []string{"{{id}}"}},
+			Body:   "order {{id}} shipped",
+			Checks: ht.CheckList{ht.StatusCode{Expect: 200}},
+		}},
+		Monitor: monitor,
+	}
+	group, err := groupMocks([]*Mock{m})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	handler := createServer("8880", group["8880"], nil, nil).Handler
+	handler.ServeHTTP(httptest.NewRecorder(),
+		httptest.NewRequest("POST", "http://localhost:8880/order/42", nil))
+
+	if got, want := <-received, "POST /notify/42 42 order 42 shipped"; got != want {
+		t.Errorf("Got callback %q, want %q", got, want)
+	}
+	m.pending.Wait()
+	close(monitor)
+	n := 0
+	for report := range monitor {
+		n++
+		if strings.HasPrefix(report.Name, "Callback") && report.Result.Status != ht.Pass {
+			t.Errorf("%s: got status %s", report.Name, report.Result.Status)
+		}
+	}
+	if n != 2 {
+		t.Errorf("Got %d reports, want 2", n)
+	}
+}
+
+func TestGRPCMock(t *testing.T) {
+	m := &Mock{
+		Name:   "Greeter",
+		Method: "POST",
+		URL:    "http://localhost:8880/helloworld.Greeter/SayHello",
+		GRPC: &GRPC{
+			Response: Message{
+				"message": {Number: 1, Type: "string"},
+				"id":      {Number: 2, Type: "int32"},
+				"tags":    {Number: 3, Type: "sint32", Repeated: true},
+				"inner": {Number: 4, Type: "message", Message: Message{
+					"ok": {Number: 1, Type: "bool"},
+				}},
+			},
+		},
+		Response: Response{
+			Body: `{"id": 150, "message": "Hi", "tags": [-1, 1], "inner": {"ok": true}}`,
+		},
+	}
+	group, err := groupMocks([]*Mock{m})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	handler := createServer("8880", group["8880"], nil, nil).Handler
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST",
+		"http://localhost:8880/helloworld.Greeter/SayHello", nil))
+
+	want := "\x00\x00\x00\x00\x0f" + // uncompressed, length 15
+		"\x0a\x02Hi" + "\x10\x96\x01" + "\x18\x01\x18\x02" + "\x22\x02\x08\x01"
+	if got := rr.Body.String(); got != want {
+		t.Errorf("Got body %q, want %q", got, want)
+	}
+	resp := rr.Result()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/grpc" {
+		t.Errorf("Got Content-Type %q", ct)
+	}
+	if gs := resp.Trailer.Get("Grpc-Status"); gs != "0" {
+		t.Errorf("Got Grpc-Status %q", gs)
+	}
+
+	// Fields of the request are available as variables.
+	m.GRPC.Request = Message{
+		"name":  {Number: 1, Type: "string"},
+		"ids":   {Number: 2, Type: "sint32", Repeated: true},
+		"inner": {Number: 3, Type: "message", Message: Message{"n": {Number: 1, Type: "fixed32"}}},
+	}
+	m.Response.Body = `{"message": "Hi {{name}} {{ids[0]}},{{ids[1]}} {{inner.n}}"}`
+	request := "\x0a\x03Bob" + "\x12\x02\x01\x04" + "\x1a\x05\x0d\x07\x00\x00\x00" + "\x28\x01"
+	request = "\x00\x00\x00\x00" + string(rune(len(request))) + request
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST",
+		"http://localhost:8880/helloworld.Greeter/SayHello", strings.NewReader(request)))
+	if got, want := rr.Body.String(), "\x00\x00\x00\x00\x0f\x0a\x0dHi Bob -1,2 7"; got != want {
+		t.Errorf("Got body %q, want %q", got, want)
+	}
+
+	m.Response.Body = `{"unknown": 1}`
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST",
+		"http://localhost:8880/helloworld.Greeter/SayHello", nil))
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Got %d for unknown field", rr.Code)
+	}
+}
+
+func TestWebSocketMock(t *testing.T) {
+	monitor := make(chan *ht.Test, 1)
+	m := &Mock{
+		Name:      "Ticker",
+		URL:       "http://localhost:8880/ws/{user}",
+		Variables: scope.Variables{"greeting": "Hello"},
+		WebSocket: &WebSocket{
+			Script: []Frame{
+				{Receive: ht.Condition{Contains: "subscribe"}},
+				{Send: "{{greeting}} {{user}}"},
+				{Send: "tick", Receive: ht.Condition{Equals: "bye"}},
+			},
+		},
+		Monitor: monitor,
+	}
+	group, err := groupMocks([]*Mock{m})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	server := httptest.NewServer(createServer("8880", group["8880"], nil, nil).Handler)
+	defer server.Close()
+
+	conn, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws/joe",
+		"", server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer conn.Close()
+	websocket.Message.Send(conn, "subscribe please")
+	for _, want := range []string{"Hello joe", "tick"} {
+		var got string
+		if err := websocket.Message.Receive(conn, &got); err != nil || got != want {
+			t.Errorf("Got %q, %v; want %q", got, err, want)
+		}
+	}
+	websocket.Message.Send(conn, "goodbye")
+
+	report := <-monitor
+	if report.Result.Status != ht.Fail ||
+		!strings.Contains(report.Result.Error.Error(), "step 3") {
+		t.Errorf("Got %s %v", report.Result.Status, report.Result.Error)
+	}
+	if want := "< subscribe please\n> Hello joe\n> tick\n< goodbye\n"; report.Response.BodyStr != want {
+		t.Errorf("Got transcript %q, want %q", report.Response.BodyStr, want)
+	}
+}
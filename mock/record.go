@@ -0,0 +1,228 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/vdobler/ht/ht"
+)
+
+// ----------------------------------------------------------------------------
+// Recording mocks
+
+// Recorder is a reverse proxy which forwards all requests to a real backend
+// and records each request/response pair as a Mock. The recorded mocks
+// replay the backend's responses: Their URL is the URL under which the
+// Recorder was reached, so serving the recorded mocks on the same address
+// as the Recorder replaces the backend transparently. Requests with a query
+// string are recorded with a Match on the (first) value of each non-empty
+// query parameter as mocks are routed on the path only.
+type Recorder struct {
+	// Target is the backend to which requests are forwarded.
+	Target *url.URL
+
+	// Dir, if non-empty, is the directory to which each recorded mock
+	// is written immediately.
+	Dir string
+
+	// Log to report infos to.
+	Log Log
+
+	proxy *httputil.ReverseProxy
+	mu    sync.Mutex
+	mocks []*Mock
+}
+
+// NewRecorder returns a Recorder forwarding requests to target.
+func NewRecorder(target string) (*Recorder, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("mock: cannot record %q: not a http(s) URL", target)
+	}
+	rec := &Recorder{Target: u}
+	proxy := httputil.NewSingleHostReverseProxy(u)
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+		r.Host = u.Host
+		// Let the transport handle compression so that the
+		// recorded bodies are uncompressed.
+		r.Header.Del("Accept-Encoding")
+	}
+	rec.proxy = proxy
+	return rec, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (rec *Recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	mockURL := (&url.URL{Scheme: "http", Host: r.Host, Path: r.URL.Path,
+		RawQuery: r.URL.RawQuery}).String()
+	if r.TLS != nil {
+		mockURL = "https" + strings.TrimPrefix(mockURL, "http")
+	}
+	var match Match
+	for name, values := range r.URL.Query() {
+		if values[0] == "" {
+			continue
+		}
+		if match.Query == nil {
+			match.Query = make(map[string]ht.Condition)
+		}
+		match.Query[name] = ht.Condition{Equals: values[0]}
+	}
+	if rec.Log != nil {
+		rec.Log.Printf("Recording %s %s", r.Method, r.URL)
+	}
+
+	rr := httptest.NewRecorder()
+	rec.proxy.ServeHTTP(rr, r)
+	body := rr.Body.Bytes()
+
+	for h, vs := range rr.Header() {
+		w.Header()[h] = vs
+	}
+	w.WriteHeader(rr.Code)
+	w.Write(body)
+
+	header := http.Header{}
+	for h, vs := range rr.Header() {
+		switch h {
+		case "Content-Length", "Content-Encoding", "Transfer-Encoding",
+			"Connection", "Date":
+			continue
+		}
+		header[h] = vs
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	no := len(rec.mocks) + 1
+	m := &Mock{
+		Name: fmt.Sprintf("%d. %s %s", no, r.Method, r.URL.Path),
+		Description: fmt.Sprintf("Recorded %s %s at %s",
+			r.Method, rec.Target.ResolveReference(&url.URL{Path: r.URL.Path, RawQuery: r.URL.RawQuery}),
+			time.Now().Format(time.RFC3339)),
+		Method: r.Method,
+		URL:    mockURL,
+		Match:  match,
+		Response: Response{
+			StatusCode: rr.Code,
+			Header:     header,
+			Body:       string(body),
+		},
+	}
+	rec.mocks = append(rec.mocks, m)
+
+	if rec.Dir == "" {
+		return
+	}
+	if err := writeMock(m, rec.Dir, no, utf8.Valid(body)); err != nil && rec.Log != nil {
+		rec.Log.Printf("Cannot write mock %q: %s", m.Name, err)
+	}
+}
+
+// Mocks returns the mocks recorded so far.
+func (rec *Recorder) Mocks() []*Mock {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	mocks := make([]*Mock, len(rec.mocks))
+	copy(mocks, rec.mocks)
+	return mocks
+}
+
+// WriteMocks writes the mocks as individual mock files to dir. The files
+// are named like "003-get-api-users.mock". Response bodies which are not
+// valid UTF-8 are written to a separate file and referenced via "@file:".
+func WriteMocks(mocks []*Mock, dir string) error {
+	for i, m := range mocks {
+		err := writeMock(m, dir, i+1, utf8.ValidString(m.Response.Body))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordedMock is the serializable subset of a recorded Mock.
+type recordedMock struct {
+	Name        string
+	Description string
+	Method      string
+	URL         string
+	Response    Response
+}
+
+func writeMock(m *Mock, dir string, no int, textBody bool) error {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	path := m.URL
+	if u, err := url.Parse(m.URL); err == nil {
+		path = u.Path
+	}
+	basename := fmt.Sprintf("%03d-%s", no, mockFilename(m.Method+" "+path))
+	rm := recordedMock{
+		Name:        m.Name,
+		Description: m.Description,
+		Method:      m.Method,
+		URL:         m.URL,
+		Response:    m.Response,
+	}
+	if !textBody {
+		bodyfile := filepath.Join(dir, basename+".body")
+		err := ioutil.WriteFile(bodyfile, []byte(m.Response.Body), 0666)
+		if err != nil {
+			return err
+		}
+		rm.Response.Body = "@file:" + filepath.ToSlash(bodyfile)
+	}
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(rm); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, basename+".mock"), buf.Bytes(), 0666)
+}
+
+// mockFilename turns s into a lowercase filename like "get-api-users".
+func mockFilename(s string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return '-'
+	}, s)
+	for strings.Contains(name, "--") {
+		name = strings.Replace(name, "--", "-", -1)
+	}
+	name = strings.Trim(name, "-")
+	if len(name) > 60 {
+		name = name[:60]
+	}
+	return name
+}
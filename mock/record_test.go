@@ -0,0 +1,108 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Backend", "real")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"path": %q, "q": %q}`, r.URL.Path, r.URL.Query().Get("q"))
+	}))
+	defer backend.Close()
+
+	dir, err := ioutil.TempDir("", "mockrecorder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rec, err := NewRecorder(backend.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	rec.Dir = dir
+	proxy := httptest.NewServer(rec)
+	defer proxy.Close()
+
+	resp, err := http.Post(proxy.URL+"/api/users?q=x", "text/plain", strings.NewReader("body"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || string(body) != `{"path": "/api/users", "q": "x"}` {
+		t.Fatalf("Proxy returned %d %s", resp.StatusCode, body)
+	}
+
+	mocks := rec.Mocks()
+	if len(mocks) != 1 {
+		t.Fatalf("Got %d mocks, want 1", len(mocks))
+	}
+	m := mocks[0]
+	if m.Method != "POST" || m.URL != proxy.URL+"/api/users?q=x" ||
+		m.Match.Query["q"].Equals != "x" ||
+		m.Response.StatusCode != http.StatusCreated ||
+		m.Response.Header.Get("X-Backend") != "real" ||
+		m.Response.Body != string(body) {
+		t.Errorf("Got %+v", m)
+	}
+
+	// The written mock file replays the response.
+	data, err := ioutil.ReadFile(filepath.Join(dir, "001-post-api-users.mock"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	replay := &Mock{}
+	if err := json.Unmarshal(data, replay); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	rr := httptest.NewRecorder()
+	replay.ServeHTTP(rr, httptest.NewRequest("POST", "/api/users", nil))
+	if rr.Code != http.StatusCreated || rr.Body.String() != string(body) {
+		t.Errorf("Replay gave %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestWriteMocksBinaryBody(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mockrecorder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := &Mock{
+		Name:     "Image",
+		Method:   "GET",
+		URL:      "http://localhost:8880/img/Logo.png",
+		Response: Response{Body: "\x89PNG\xff\xfe"},
+	}
+	if err := WriteMocks([]*Mock{m}, dir); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "001-get-img-logo-png.mock"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !strings.Contains(string(data), `"@file:`) {
+		t.Errorf("Missing @file: reference in\n%s", data)
+	}
+	body, err := ioutil.ReadFile(filepath.Join(dir, "001-get-img-logo-png.body"))
+	if err != nil || string(body) != m.Response.Body {
+		t.Errorf("Got %q, %v", body, err)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/vdobler/ht/scope"
+)
+
+// ----------------------------------------------------------------------------
+// Response templates
+
+// RequestData is the data available to response templates of a mock with
+// Template set. A mock for "/users/{id}" can echo the requested id in its
+// response body like this:
+//     {"id": "{{.Vars.id}}", "name": "{{.JSON.name}}", "lang": "{{.Query.lang}}"}
+type RequestData struct {
+	Method   string            // Method of the request.
+	Path     string            // Path of the request.
+	Segments []string          // Path split at "/" without empty segments.
+	Query    map[string]string // First value of each query parameter.
+	Header   map[string]string // First value of each header (canonical name).
+	Body     string            // Body of the request.
+	JSON     interface{}       // Body decoded as JSON (nil if no JSON).
+	Vars     scope.Variables   // Variables of the mock including path variables.
+}
+
+func newRequestData(r *http.Request, body []byte, vars scope.Variables) *RequestData {
+	data := &RequestData{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  make(map[string]string),
+		Header: make(map[string]string, len(r.Header)),
+		Body:   string(body),
+		Vars:   vars,
+	}
+	for _, seg := range strings.Split(r.URL.Path, "/") {
+		if seg != "" {
+			data.Segments = append(data.Segments, seg)
+		}
+	}
+	for k, v := range r.URL.Query() {
+		data.Query[k] = v[0]
+	}
+	for k, v := range r.Header {
+		data.Header[k] = v[0]
+	}
+	if err := json.Unmarshal(body, &data.JSON); err != nil {
+		data.JSON = nil
+	}
+	return data
+}
+
+// templateFuncs are available in response templates in addition to the
+// builtin functions of text/template.
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		buf, err := json.Marshal(v)
+		return string(buf), err
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// execute the template text with data.
+func execute(name, text string, data *RequestData) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
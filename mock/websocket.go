@@ -0,0 +1,110 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mock
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/vdobler/ht/ht"
+	"golang.org/x/net/websocket"
+)
+
+// ----------------------------------------------------------------------------
+// WebSockets
+
+// WebSocket turns a mock into a WebSocket endpoint which works through
+// a scripted exchange of text messages once the client connected:
+//     WebSocket: {
+//         Script: [
+//             {Receive: {Contains: "subscribe"}}
+//             {Send: "{\"event\": \"welcome\", \"user\": \"{{user}}\"}"}
+//             {Delay: "1s", Send: "{\"event\": \"tick\"}"}
+//         ]
+//     }
+// The connection is closed after the last step. The transcript of the
+// exchange is reported as the response body of the mock and a message
+// not fulfilling its Receive condition fails the mock invocation.
+type WebSocket struct {
+	// Script is the sequence of steps to work through.
+	Script []Frame
+}
+
+// Frame is one step in a WebSocket script.
+type Frame struct {
+	// Delay before this step.
+	Delay time.Duration
+
+	// Send is a text message sent to the client. Variables are replaced
+	// like in the response body of the mock.
+	Send string
+
+	// Receive, if non-zero, waits for the next message from the client
+	// which must fulfill this condition.
+	Receive ht.Condition
+
+	// Timeout to wait for a message to receive. Defaults to 10 seconds.
+	Timeout time.Duration
+}
+
+// compile all conditions in ws.
+func (ws *WebSocket) compile() error {
+	for i := range ws.Script {
+		if err := ws.Script[i].Receive.Compile(); err != nil {
+			return fmt.Errorf("websocket script step %d: %s", i+1, err)
+		}
+	}
+	return nil
+}
+
+// serve upgrades the connection and works through the script. The
+// transcript of the exchange is returned.
+func (ws *WebSocket) serve(w http.ResponseWriter, r *http.Request, replace func(string) (string, error)) (string, error) {
+	transcript := &bytes.Buffer{}
+	var err error
+	server := websocket.Server{
+		Handler: func(conn *websocket.Conn) {
+			err = ws.run(conn, replace, transcript)
+		},
+	}
+	server.ServeHTTP(w, r)
+	return transcript.String(), err
+}
+
+// run the script on conn.
+func (ws *WebSocket) run(conn *websocket.Conn, replace func(string) (string, error), transcript *bytes.Buffer) error {
+	for i, frame := range ws.Script {
+		time.Sleep(frame.Delay)
+		if frame.Send != "" {
+			msg, err := replace(frame.Send)
+			if err != nil {
+				return fmt.Errorf("step %d: %s", i+1, err)
+			}
+			if err := websocket.Message.Send(conn, msg); err != nil {
+				return fmt.Errorf("step %d: %s", i+1, err)
+			}
+			fmt.Fprintf(transcript, "> %s\n", msg)
+		}
+		if frame.Receive.IsZero() {
+			continue
+		}
+		timeout := frame.Timeout
+		if timeout == 0 {
+			timeout = 10 * time.Second
+		}
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		var msg string
+		if err := websocket.Message.Receive(conn, &msg); err != nil {
+			return fmt.Errorf("step %d: %s", i+1, err)
+		}
+		fmt.Fprintf(transcript, "< %s\n", msg)
+		if err := frame.Receive.Fulfilled(msg); err != nil {
+			return fmt.Errorf("step %d: received message %s", i+1, err)
+		}
+	}
+	return nil
+}
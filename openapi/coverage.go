@@ -0,0 +1,285 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package openapi
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vdobler/ht/ht"
+)
+
+// ----------------------------------------------------------------------------
+// Coverage
+
+// Coverage records which operations of a specification and which of their
+// documented responses were exercised by executed tests.
+type Coverage struct {
+	Title      string
+	Operations []*OperationCoverage
+
+	// Unmatched lists the requests (method and URL) which do not belong
+	// to any operation of the specification.
+	Unmatched []string `json:",omitempty"`
+
+	basePath  string
+	unmatched map[string]bool
+}
+
+// OperationCoverage is the coverage of one operation.
+type OperationCoverage struct {
+	Method      string
+	Path        string
+	OperationID string `json:",omitempty"`
+
+	// Calls is the number of requests made to this operation.
+	Calls int
+
+	// Responses maps the documented response codes (like "200", "4xx"
+	// or "default") to the number of responses received.
+	Responses map[string]int
+
+	// Undocumented maps status codes which are not documented to the
+	// number of responses received.
+	Undocumented map[int]int `json:",omitempty"`
+
+	re       *regexp.Regexp
+	literals int // number of literal path segments
+}
+
+// NewCoverage returns an empty coverage of the operations in spec.
+func NewCoverage(spec *Spec) *Coverage {
+	c := &Coverage{Title: spec.Info.Title, unmatched: make(map[string]bool)}
+	if u, err := url.Parse(baseURL(spec)); err == nil {
+		c.basePath = strings.TrimSuffix(u.Path, "/")
+	}
+
+	paths := make([]string, 0, len(spec.Paths))
+	for p := range spec.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		item := spec.Paths[p]
+		if item == nil {
+			continue
+		}
+		ops := item.operations()
+		for _, method := range []string{"GET", "HEAD", "OPTIONS", "POST", "PUT", "PATCH", "DELETE"} {
+			op, ok := ops[method]
+			if !ok {
+				continue
+			}
+			oc := &OperationCoverage{
+				Method:       method,
+				Path:         p,
+				OperationID:  op.OperationID,
+				Responses:    make(map[string]int),
+				Undocumented: make(map[int]int),
+			}
+			for code := range op.Responses {
+				oc.Responses[strings.ToLower(code)] = 0
+			}
+			oc.re, oc.literals = pathPattern(p)
+			c.Operations = append(c.Operations, oc)
+		}
+	}
+	return c
+}
+
+// pathPattern returns a regular expression matching the concrete paths
+// of the path template p (like /pets/{petId}) and the number of literal
+// segments in p.
+func pathPattern(p string) (*regexp.Regexp, int) {
+	segments := strings.Split(strings.Trim(p, "/"), "/")
+	literals := 0
+	for i, seg := range segments {
+		if pathParamRe.MatchString(seg) {
+			parts := pathParamRe.Split(seg, -1)
+			for j := range parts {
+				parts[j] = regexp.QuoteMeta(parts[j])
+			}
+			segments[i] = strings.Join(parts, "[^/]+")
+		} else {
+			segments[i] = regexp.QuoteMeta(seg)
+			literals++
+		}
+	}
+	return regexp.MustCompile("^/" + strings.Join(segments, "/") + "/?$"), literals
+}
+
+// Record a request with the given method to u which was answered with
+// status. Requests to paths below the base path of the specification are
+// matched against the path templates relative to the base path, other
+// requests against the path templates as suffix of the path.
+func (c *Coverage) Record(method string, u *url.URL, status int) {
+	path := u.Path
+	if c.basePath != "" && strings.HasPrefix(path, c.basePath+"/") {
+		path = path[len(c.basePath):]
+	}
+	oc := c.match(method, path)
+	if oc == nil {
+		// Maybe the API was served under a different base path.
+		segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+		for i := 1; i < len(segments) && oc == nil; i++ {
+			oc = c.match(method, "/"+strings.Join(segments[i:], "/"))
+		}
+	}
+	if oc == nil {
+		if req := method + " " + u.String(); !c.unmatched[req] {
+			c.unmatched[req] = true
+			c.Unmatched = append(c.Unmatched, req)
+		}
+		return
+	}
+
+	oc.Calls++
+	code := strconv.Itoa(status)
+	class := code[:1] + "xx"
+	switch {
+	case hasKey(oc.Responses, code):
+		oc.Responses[code]++
+	case hasKey(oc.Responses, class):
+		oc.Responses[class]++
+	case hasKey(oc.Responses, "default"):
+		oc.Responses["default"]++
+	default:
+		oc.Undocumented[status]++
+	}
+}
+
+// match returns the operation for method and path with the most literal
+// path segments.
+func (c *Coverage) match(method, path string) *OperationCoverage {
+	var best *OperationCoverage
+	for _, oc := range c.Operations {
+		if oc.Method != method || !oc.re.MatchString(path) {
+			continue
+		}
+		if best == nil || oc.literals > best.literals {
+			best = oc
+		}
+	}
+	return best
+}
+
+func hasKey(m map[string]int, key string) bool {
+	_, ok := m[key]
+	return ok
+}
+
+// RecordTests records the requests made by the given tests. Tests which
+// did not receive a HTTP response are ignored.
+func (c *Coverage) RecordTests(tests []*ht.Test) {
+	for _, t := range tests {
+		if t.Request.Request == nil || t.Response.Response == nil {
+			continue
+		}
+		u := t.Request.Request.URL
+		if u.Scheme != "http" && u.Scheme != "https" {
+			continue
+		}
+		c.Record(t.Request.Request.Method, u, t.Response.Response.StatusCode)
+	}
+}
+
+// OperationsCovered returns the number of called and the total number of
+// operations.
+func (c *Coverage) OperationsCovered() (covered, total int) {
+	for _, oc := range c.Operations {
+		if oc.Calls > 0 {
+			covered++
+		}
+	}
+	return covered, len(c.Operations)
+}
+
+// ResponsesCovered returns the number of received and the total number of
+// documented responses.
+func (c *Coverage) ResponsesCovered() (covered, total int) {
+	for _, oc := range c.Operations {
+		for _, n := range oc.Responses {
+			if n > 0 {
+				covered++
+			}
+			total++
+		}
+	}
+	return covered, total
+}
+
+// Percent returns the percentage of covered operations.
+func (c *Coverage) Percent() float64 {
+	covered, total := c.OperationsCovered()
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(covered) / float64(total)
+}
+
+// Summary returns a one line summary of c.
+func (c *Coverage) Summary() string {
+	ops, totalOps := c.OperationsCovered()
+	resp, totalResp := c.ResponsesCovered()
+	return fmt.Sprintf("%d of %d operations (%.1f%%), %d of %d documented responses",
+		ops, totalOps, c.Percent(), resp, totalResp)
+}
+
+// WriteReport writes a plain text report of c to w: Each operation is
+// listed with its documented responses, covered items are marked with
+// "[x]", uncovered ones with "[ ]".
+func (c *Coverage) WriteReport(w io.Writer) error {
+	title := c.Title
+	if title == "" {
+		title = "OpenAPI"
+	}
+	fmt.Fprintf(w, "Coverage of %s: %s\n\n", title, c.Summary())
+
+	mark := func(n int) string {
+		if n > 0 {
+			return "[x]"
+		}
+		return "[ ]"
+	}
+	for _, oc := range c.Operations {
+		fmt.Fprintf(w, "%s %-7s %s", mark(oc.Calls), oc.Method, oc.Path)
+		if oc.OperationID != "" {
+			fmt.Fprintf(w, "  (%s)", oc.OperationID)
+		}
+		fmt.Fprintf(w, "  %d calls\n", oc.Calls)
+
+		codes := make([]string, 0, len(oc.Responses))
+		for code := range oc.Responses {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		for _, code := range codes {
+			fmt.Fprintf(w, "        %s %s\n", mark(oc.Responses[code]), code)
+		}
+		undoc := make([]int, 0, len(oc.Undocumented))
+		for status := range oc.Undocumented {
+			undoc = append(undoc, status)
+		}
+		sort.Ints(undoc)
+		for _, status := range undoc {
+			fmt.Fprintf(w, "        !!! %d undocumented, received %d times\n",
+				status, oc.Undocumented[status])
+		}
+	}
+
+	if len(c.Unmatched) > 0 {
+		fmt.Fprintf(w, "\nRequests not described by the specification:\n")
+		for _, req := range c.Unmatched {
+			fmt.Fprintf(w, "    %s\n", req)
+		}
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
@@ -0,0 +1,99 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package openapi
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/vdobler/ht/ht"
+)
+
+func TestCoverage(t *testing.T) {
+	file, err := os.Open("testdata/petstore.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	spec, err := Read(file)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	cov := NewCoverage(spec)
+	for _, req := range []struct {
+		method, url string
+		status      int
+	}{
+		{"GET", "http://localhost:8080/v1/pets?limit=10", 200},
+		{"GET", "http://localhost:8080/v1/pets/", 200},
+		{"POST", "https://staging.example.org/api/v2/pets", 400}, // other base path
+		{"GET", "http://localhost:8080/v1/pets/42", 404},
+		{"PUT", "http://localhost:8080/v1/pets/42", 200},
+		{"GET", "http://localhost:8080/v1/store", 200},
+		{"GET", "http://localhost:8080/v1/store", 200},
+	} {
+		u, _ := url.Parse(req.url)
+		cov.Record(req.method, u, req.status)
+	}
+	u, _ := url.Parse("http://localhost:8080/v1/pets/mine")
+	cov.RecordTests([]*ht.Test{
+		{
+			Request:  ht.Request{Request: &http.Request{Method: "GET", URL: u}},
+			Response: ht.Response{Response: &http.Response{StatusCode: 200}},
+		},
+		{
+			Request: ht.Request{Request: &http.Request{Method: "DELETE", URL: u}},
+		},
+	})
+
+	if covered, total := cov.OperationsCovered(); covered != 3 || total != 4 {
+		t.Errorf("Got %d of %d operations", covered, total)
+	}
+	if covered, total := cov.ResponsesCovered(); covered != 3 || total != 5 {
+		t.Errorf("Got %d of %d responses", covered, total)
+	}
+	if cov.Percent() != 75 {
+		t.Errorf("Got %.1f%%", cov.Percent())
+	}
+
+	list, create, show := cov.Operations[0], cov.Operations[1], cov.Operations[2]
+	if list.OperationID != "listPets" || list.Calls != 2 || list.Responses["200"] != 2 {
+		t.Errorf("Bad listPets coverage %+v", list)
+	}
+	if create.OperationID != "createPet" || create.Responses["default"] != 1 ||
+		create.Responses["201"] != 0 {
+		t.Errorf("Bad createPet coverage %+v", create)
+	}
+	if show.OperationID != "showPet" || show.Calls != 2 || show.Responses["200"] != 1 ||
+		show.Undocumented[404] != 1 {
+		t.Errorf("Bad showPet coverage %+v", show)
+	}
+	if got := strings.Join(cov.Unmatched, ", "); got !=
+		"PUT http://localhost:8080/v1/pets/42, GET http://localhost:8080/v1/store" {
+		t.Errorf("Got unmatched %s", got)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := cov.WriteReport(buf); err != nil {
+		t.Fatal(err)
+	}
+	report := buf.String()
+	for _, want := range []string{
+		"Coverage of Pet Store: 3 of 4 operations (75.0%), 3 of 5 documented responses\n",
+		"[x] POST    /pets  (createPet)  1 calls\n        [ ] 201\n        [x] default\n",
+		"[ ] DELETE  /pets/{petId}  0 calls\n        [ ] 204\n",
+		"!!! 404 undocumented, received 1 times\n",
+		"    GET http://localhost:8080/v1/store\n",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("Missing %q in report\n%s", want, report)
+		}
+	}
+}
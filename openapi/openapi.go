@@ -0,0 +1,851 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package openapi generates skeleton ht suites from OpenAPI 3 and
+// Swagger 2 specifications.
+//
+// Each operation of the specification is turned into one test. The
+// request is filled with example values for the required parameters and
+// an example request body; the checks test the documented success status
+// code, the content type of the response and the structure of JSON
+// responses as described by the response schema.
+// Specifications can be given in JSON or in YAML.
+//
+// Coverage reports which operations and documented responses of a
+// specification were exercised by executed tests.
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/internal/convert"
+	"github.com/vdobler/ht/sanitize"
+)
+
+// ----------------------------------------------------------------------------
+// Specification
+
+// Spec is an OpenAPI 3 or Swagger 2 specification.
+type Spec struct {
+	OpenAPI string `json:"openapi"`
+	Swagger string
+	Info    struct {
+		Title       string
+		Description string
+		Version     string
+	}
+
+	// OpenAPI 3
+	Servers    []struct{ URL string }
+	Components struct {
+		Schemas       map[string]*Schema
+		Parameters    map[string]*Parameter
+		RequestBodies map[string]*RequestBody `json:"requestBodies"`
+		Responses     map[string]*Response
+	}
+
+	// Swagger 2
+	Host        string
+	BasePath    string `json:"basePath"`
+	Schemes     []string
+	Consumes    []string
+	Produces    []string
+	Definitions map[string]*Schema
+	Parameters  map[string]*Parameter
+	Responses   map[string]*Response
+
+	Paths map[string]*PathItem
+}
+
+// PathItem describes the operations on one path.
+type PathItem struct {
+	Parameters []*Parameter
+	Get        *Operation
+	Put        *Operation
+	Post       *Operation
+	Delete     *Operation
+	Options    *Operation
+	Head       *Operation
+	Patch      *Operation
+}
+
+// operations returns the operations of pi keyed by their method.
+func (pi *PathItem) operations() map[string]*Operation {
+	ops := map[string]*Operation{}
+	for method, op := range map[string]*Operation{
+		"GET": pi.Get, "PUT": pi.Put, "POST": pi.Post, "DELETE": pi.Delete,
+		"OPTIONS": pi.Options, "HEAD": pi.Head, "PATCH": pi.Patch,
+	} {
+		if op != nil {
+			ops[method] = op
+		}
+	}
+	return ops
+}
+
+// Operation is a single API operation on a path.
+type Operation struct {
+	OperationID string `json:"operationId"`
+	Summary     string
+	Description string
+	Tags        []string
+	Parameters  []*Parameter
+	RequestBody *RequestBody `json:"requestBody"`
+	Responses   map[string]*Response
+	Consumes    []string
+	Produces    []string
+	Deprecated  bool
+}
+
+// Parameter of an operation. Swagger 2 describes the type of non-body
+// parameters inline.
+type Parameter struct {
+	Ref      string `json:"$ref"`
+	Name     string
+	In       string // path, query, header, cookie, body or formData
+	Required bool
+	Schema   *Schema
+	Example  interface{}
+
+	Type    SchemaType
+	Format  string
+	Items   *Schema
+	Default interface{}
+	Enum    []interface{}
+}
+
+// RequestBody of an OpenAPI 3 operation.
+type RequestBody struct {
+	Ref      string `json:"$ref"`
+	Required bool
+	Content  map[string]*MediaType
+}
+
+// MediaType describes the content of a request or response body.
+type MediaType struct {
+	Schema   *Schema
+	Example  interface{}
+	Examples map[string]struct{ Value interface{} }
+}
+
+// Response of an operation. Swagger 2 describes the body directly by
+// Schema and Examples (keyed by the mime type).
+type Response struct {
+	Ref         string `json:"$ref"`
+	Description string
+	Content     map[string]*MediaType
+
+	Schema   *Schema
+	Examples map[string]interface{}
+}
+
+// Schema is a JSON Schema as used by OpenAPI.
+type Schema struct {
+	Ref        string `json:"$ref"`
+	Type       SchemaType
+	Format     string
+	Properties map[string]*Schema
+	Required   []string
+	Items      *Schema
+	AllOf      []*Schema `json:"allOf"`
+	OneOf      []*Schema `json:"oneOf"`
+	AnyOf      []*Schema `json:"anyOf"`
+	Nullable   bool
+	Example    interface{}
+	Default    interface{}
+	Enum       []interface{}
+}
+
+// SchemaType is the type of a schema. OpenAPI 3.1 allows a list of types
+// of which the first one different from "null" is used.
+type SchemaType string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (st *SchemaType) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		*st = SchemaType(s)
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*st = ""
+	for _, s := range list {
+		if s != "null" {
+			*st = SchemaType(s)
+			break
+		}
+	}
+	return nil
+}
+
+// Read an OpenAPI or Swagger specification in JSON or YAML format from r.
+func Read(r io.Reader) (*Spec, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] != '{' {
+		v, err := parseYAML(data)
+		if err != nil {
+			return nil, fmt.Errorf("not an OpenAPI specification: %s", err)
+		}
+		data, err = json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	spec := &Spec{}
+	if err := json.Unmarshal(data, spec); err != nil {
+		return nil, fmt.Errorf("not an OpenAPI specification: %s", err)
+	}
+	switch {
+	case strings.HasPrefix(spec.OpenAPI, "3."):
+	case strings.HasPrefix(spec.Swagger, "2."):
+	case spec.OpenAPI == "" && spec.Swagger == "":
+		return nil, fmt.Errorf("not an OpenAPI specification: missing openapi or swagger version")
+	default:
+		return nil, fmt.Errorf("unsupported specification version %s%s",
+			spec.OpenAPI, spec.Swagger)
+	}
+	return spec, nil
+}
+
+// ----------------------------------------------------------------------------
+// Generation
+
+// File, Test, Soup and Suite are the generated files and the reduced
+// versions of tests, checks and suites they contain.
+type (
+	File  = convert.File
+	Test  = convert.Test
+	Soup  = convert.Soup
+	Suite = convert.Suite
+)
+
+// BaseURLVariable is the name of the suite variable holding the base URL
+// of the API which is prepended to the paths of all requests.
+const BaseURLVariable = "BASE_URL"
+
+type generator struct {
+	*convert.Builder
+	spec *Spec
+}
+
+// Generate creates a suite (the first of the returned files) and one test
+// per operation in spec. Tests are placed in subdirectories named after
+// the first tag of the operation. Parts of spec which cannot be handled
+// are reported in warnings.
+func Generate(spec *Spec) (files []File, warnings []string, err error) {
+	name := spec.Info.Title
+	if name == "" {
+		name = "OpenAPI"
+	}
+	g := &generator{
+		Builder: &convert.Builder{
+			Suite: Suite{
+				Name:        name,
+				Description: strings.TrimSpace(spec.Info.Description),
+				Variables:   map[string]string{BaseURLVariable: baseURL(spec)},
+			},
+			Unnamed: "operation",
+		},
+		spec: spec,
+	}
+
+	paths := make([]string, 0, len(spec.Paths))
+	for p := range spec.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		item := spec.Paths[p]
+		if item == nil {
+			continue
+		}
+		ops := item.operations()
+		for _, method := range []string{"GET", "HEAD", "OPTIONS", "POST", "PUT", "PATCH", "DELETE"} {
+			op, ok := ops[method]
+			if !ok {
+				continue
+			}
+			g.operation(p, method, item, op)
+		}
+	}
+	if len(g.Suite.Main) == 0 {
+		return nil, g.Warnings, fmt.Errorf("specification %q contains no operations", name)
+	}
+
+	files, err = g.Files()
+	return files, g.Warnings, err
+}
+
+// baseURL returns the URL of the first server of spec.
+func baseURL(spec *Spec) string {
+	if len(spec.Servers) > 0 {
+		u := strings.TrimSuffix(spec.Servers[0].URL, "/")
+		return serverVarRe.ReplaceAllString(u, "$1")
+	}
+	if spec.Swagger != "" {
+		scheme := "http"
+		if len(spec.Schemes) > 0 {
+			scheme = spec.Schemes[0]
+		}
+		host := spec.Host
+		if host == "" {
+			host = "localhost"
+		}
+		return scheme + "://" + host + strings.TrimSuffix(spec.BasePath, "/")
+	}
+	return "http://localhost"
+}
+
+var (
+	serverVarRe = regexp.MustCompile(`\{([^}]*)\}`)
+	pathParamRe = regexp.MustCompile(`\{([^}]+)\}`)
+)
+
+func (g *generator) operation(urlPath, method string, item *PathItem, op *Operation) {
+	name := op.OperationID
+	if name == "" {
+		name = method + " " + urlPath
+	}
+	where := name
+	description := op.Summary
+	if op.Description != "" {
+		if description != "" {
+			description += "\n\n"
+		}
+		description += strings.TrimSpace(op.Description)
+	}
+	if op.Deprecated {
+		g.Warnf("%s: operation is deprecated", where)
+	}
+
+	test := &Test{
+		Name:        name,
+		Description: description,
+		Request: ht.Request{
+			Method: method,
+			URL: "{{" + BaseURLVariable + "}}" +
+				pathParamRe.ReplaceAllString(urlPath, "{{$1}}"),
+		},
+	}
+
+	// Operation parameters override path item parameters.
+	params := map[string]*Parameter{}
+	order := []string{}
+	for _, list := range [][]*Parameter{item.Parameters, op.Parameters} {
+		for _, p := range list {
+			p = g.resolveParameter(p, where)
+			if p == nil {
+				continue
+			}
+			key := p.In + ":" + p.Name
+			if _, ok := params[key]; !ok {
+				order = append(order, key)
+			}
+			params[key] = p
+		}
+	}
+	for _, key := range order {
+		g.parameter(test, op, params[key], where)
+	}
+	g.requestBody(test, op, where)
+	g.checks(test, op, where)
+
+	dir := ""
+	if len(op.Tags) > 0 {
+		dir = sanitize.Filename(op.Tags[0])
+	}
+	if err := g.AddTest(dir, test); err != nil {
+		g.Warnf("%s: skipped: %s", where, err)
+	}
+}
+
+// parameter adds the parameter p to the request of test.
+func (g *generator) parameter(test *Test, op *Operation, p *Parameter, where string) {
+	if p.In == "body" {
+		if p.Schema != nil {
+			mt := &MediaType{Schema: p.Schema, Example: p.Example}
+			g.setBody(test, g.consumes(op), mt, where)
+		}
+		return
+	}
+	if !p.Required && p.In != "path" {
+		return
+	}
+
+	value := p.Example
+	if value == nil {
+		schema := p.Schema
+		if schema == nil {
+			schema = &Schema{Type: p.Type, Format: p.Format, Items: p.Items,
+				Default: p.Default, Enum: p.Enum}
+		}
+		value = g.example(schema, 0)
+	}
+	s := scalarString(value)
+
+	switch p.In {
+	case "path":
+		if _, ok := g.Suite.Variables[p.Name]; !ok {
+			g.Suite.Variables[p.Name] = s
+		}
+	case "query":
+		if test.Request.Params == nil {
+			test.Request.Params = url.Values{}
+		}
+		test.Request.Params.Add(p.Name, s)
+	case "formData":
+		if test.Request.Params == nil {
+			test.Request.Params = url.Values{}
+		}
+		test.Request.Params.Add(p.Name, s)
+		test.Request.ParamsAs = "body"
+	case "header":
+		if test.Request.Header == nil {
+			test.Request.Header = make(map[string][]string)
+		}
+		test.Request.Header.Add(p.Name, s)
+	case "cookie":
+		test.Request.Cookies = append(test.Request.Cookies,
+			ht.Cookie{Name: p.Name, Value: s})
+	default:
+		g.Warnf("%s: parameter %s in %q not supported", where, p.Name, p.In)
+	}
+}
+
+// scalarString formats the example value v as a string usable in a URL
+// or header.
+func scalarString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, e := range v {
+			parts[i] = scalarString(e)
+		}
+		return strings.Join(parts, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+// consumes returns the mime types accepted by a Swagger 2 operation.
+func (g *generator) consumes(op *Operation) []string {
+	if len(op.Consumes) > 0 {
+		return op.Consumes
+	}
+	if len(g.spec.Consumes) > 0 {
+		return g.spec.Consumes
+	}
+	return []string{"application/json"}
+}
+
+// produces returns the mime types produced by a Swagger 2 operation.
+func (g *generator) produces(op *Operation) []string {
+	if len(op.Produces) > 0 {
+		return op.Produces
+	}
+	if len(g.spec.Produces) > 0 {
+		return g.spec.Produces
+	}
+	return []string{"application/json"}
+}
+
+// requestBody sets the body of an OpenAPI 3 operation.
+func (g *generator) requestBody(test *Test, op *Operation, where string) {
+	if op.RequestBody == nil {
+		return
+	}
+	rb := op.RequestBody
+	if rb.Ref != "" {
+		if rb = g.spec.Components.RequestBodies[refName(rb.Ref)]; rb == nil {
+			g.Warnf("%s: cannot resolve request body %s", where, op.RequestBody.Ref)
+			return
+		}
+	}
+	ct := preferredMediaType(rb.Content)
+	if ct == "" {
+		return
+	}
+	g.setBody(test, []string{ct}, rb.Content[ct], where)
+}
+
+// setBody sets the body of the request in test to an example of mt
+// formatted as the first of the given content types.
+func (g *generator) setBody(test *Test, contentTypes []string, mt *MediaType, where string) {
+	ct := contentTypes[0]
+	for _, c := range contentTypes {
+		if isJSON(c) {
+			ct = c
+			break
+		}
+	}
+	value := exampleOf(mt)
+	if value == nil {
+		value = g.example(mt.Schema, 0)
+	}
+	if test.Request.Header == nil {
+		test.Request.Header = make(map[string][]string)
+	}
+
+	switch {
+	case isJSON(ct):
+		buf := &bytes.Buffer{}
+		enc := json.NewEncoder(buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "    ")
+		if err := enc.Encode(value); err != nil {
+			g.Warnf("%s: cannot encode example body: %s", where, err)
+			return
+		}
+		test.Request.Body = strings.TrimSpace(buf.String())
+		test.Request.Header.Set("Content-Type", ct)
+	case ct == "application/x-www-form-urlencoded" || ct == "multipart/form-data":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			g.Warnf("%s: cannot generate %s body from non-object example", where, ct)
+			return
+		}
+		if test.Request.Params == nil {
+			test.Request.Params = url.Values{}
+		}
+		for k, v := range obj {
+			test.Request.Params.Set(k, scalarString(v))
+		}
+		test.Request.ParamsAs = "body"
+		if ct == "multipart/form-data" {
+			test.Request.ParamsAs = "multipart"
+		}
+	default:
+		s, ok := value.(string)
+		if !ok {
+			g.Warnf("%s: no example for %s body", where, ct)
+			return
+		}
+		test.Request.Body = s
+		test.Request.Header.Set("Content-Type", ct)
+	}
+}
+
+// exampleOf returns the explicit example of mt or nil.
+func exampleOf(mt *MediaType) interface{} {
+	if mt == nil {
+		return nil
+	}
+	if mt.Example != nil {
+		return mt.Example
+	}
+	names := make([]string, 0, len(mt.Examples))
+	for name := range mt.Examples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if v := mt.Examples[name].Value; v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+// checks adds the status code, content type and JSON structure checks
+// derived from the documented success response of op.
+func (g *generator) checks(test *Test, op *Operation, where string) {
+	code, resp := successResponse(op.Responses)
+	if resp == nil {
+		g.Warnf("%s: no documented success response", where)
+		return
+	}
+	if resp.Ref != "" {
+		ref := resp.Ref
+		if resp = g.spec.Components.Responses[refName(ref)]; resp == nil {
+			resp = g.spec.Responses[refName(ref)]
+		}
+		if resp == nil {
+			g.Warnf("%s: cannot resolve response %s", where, ref)
+			return
+		}
+	}
+	test.AddCheck("StatusCode", "Expect", code)
+
+	var ct string
+	var schema *Schema
+	if g.spec.Swagger != "" {
+		if resp.Schema == nil {
+			return
+		}
+		ct = g.produces(op)[0]
+		for _, c := range g.produces(op) {
+			if isJSON(c) {
+				ct = c
+				break
+			}
+		}
+		schema = resp.Schema
+	} else {
+		ct = preferredMediaType(resp.Content)
+		if ct == "" {
+			return
+		}
+		schema = resp.Content[ct].Schema
+	}
+	if ct == "*/*" {
+		return
+	}
+	test.AddCheck("ContentType", "Is", ct)
+
+	if !isJSON(ct) || schema == nil {
+		return
+	}
+	skeleton := g.skeleton(schema, 0)
+	if skeleton == nil {
+		test.AddCheck("JSON")
+		return
+	}
+	s, err := json.Marshal(skeleton)
+	if err != nil {
+		g.Warnf("%s: cannot encode response schema: %s", where, err)
+		return
+	}
+	test.AddCheck("JSON", "Element", ".", "Schema", string(s))
+}
+
+// successResponse returns the lowest 2xx response in responses.
+func successResponse(responses map[string]*Response) (int, *Response) {
+	codes := []string{}
+	for code := range responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if strings.HasPrefix(code, "2") {
+			n, err := strconv.Atoi(code)
+			if err != nil {
+				n = 200 // e.g. "2XX"
+			}
+			return n, responses[code]
+		}
+	}
+	if r, ok := responses["default"]; ok {
+		return 200, r
+	}
+	return 0, nil
+}
+
+// preferredMediaType returns the JSON media type in content or the first
+// one (in lexical order) if there is no JSON media type.
+func preferredMediaType(content map[string]*MediaType) string {
+	types := []string{}
+	for ct := range content {
+		if content[ct] == nil {
+			continue
+		}
+		if isJSON(ct) {
+			return ct
+		}
+		types = append(types, ct)
+	}
+	if len(types) == 0 {
+		return ""
+	}
+	sort.Strings(types)
+	return types[0]
+}
+
+func isJSON(ct string) bool {
+	return ct == "application/json" || strings.HasSuffix(ct, "+json") ||
+		strings.HasPrefix(ct, "application/json;")
+}
+
+// ----------------------------------------------------------------------------
+// References and Schemas
+
+// refName extracts the name from references like #/components/schemas/Pet.
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+func (g *generator) resolveParameter(p *Parameter, where string) *Parameter {
+	if p == nil || p.Ref == "" {
+		return p
+	}
+	name := refName(p.Ref)
+	if r := g.spec.Components.Parameters[name]; r != nil {
+		return r
+	}
+	if r := g.spec.Parameters[name]; r != nil {
+		return r
+	}
+	g.Warnf("%s: cannot resolve parameter %s", where, p.Ref)
+	return nil
+}
+
+// maxDepth limits the expansion of recursive schemas.
+const maxDepth = 8
+
+// resolve follows the $ref of s (if any).
+func (g *generator) resolve(s *Schema) *Schema {
+	for i := 0; s != nil && s.Ref != "" && i < maxDepth; i++ {
+		name := refName(s.Ref)
+		r := g.spec.Components.Schemas[name]
+		if r == nil {
+			r = g.spec.Definitions[name]
+		}
+		if r == nil {
+			g.Warnf("cannot resolve schema %s", s.Ref)
+			return nil
+		}
+		s = r
+	}
+	return s
+}
+
+// merged combines the properties of all allOf subschemas of s.
+func (g *generator) merged(s *Schema) *Schema {
+	if len(s.AllOf) == 0 {
+		return s
+	}
+	m := *s
+	m.AllOf = nil
+	m.Properties = map[string]*Schema{}
+	for name, p := range s.Properties {
+		m.Properties[name] = p
+	}
+	m.Required = append([]string(nil), s.Required...)
+	for _, sub := range s.AllOf {
+		sub = g.resolve(sub)
+		if sub == nil {
+			continue
+		}
+		sub = g.merged(sub)
+		if m.Type == "" {
+			m.Type = sub.Type
+		}
+		for name, p := range sub.Properties {
+			m.Properties[name] = p
+		}
+		m.Required = append(m.Required, sub.Required...)
+	}
+	if m.Type == "" && len(m.Properties) > 0 {
+		m.Type = "object"
+	}
+	return &m
+}
+
+// example generates an example value conforming to s.
+func (g *generator) example(s *Schema, depth int) interface{} {
+	s = g.resolve(s)
+	if s == nil || depth > maxDepth {
+		return nil
+	}
+	s = g.merged(s)
+	switch {
+	case s.Example != nil:
+		return s.Example
+	case s.Default != nil:
+		return s.Default
+	case len(s.Enum) > 0:
+		return s.Enum[0]
+	case len(s.OneOf) > 0:
+		return g.example(s.OneOf[0], depth+1)
+	case len(s.AnyOf) > 0:
+		return g.example(s.AnyOf[0], depth+1)
+	}
+
+	switch s.Type {
+	case "string":
+		switch s.Format {
+		case "date-time":
+			return "2017-01-01T12:00:00Z"
+		case "date":
+			return "2017-01-01"
+		case "email":
+			return "user@example.org"
+		case "uuid":
+			return "3fa85f64-5717-4562-b3fc-2c963f66afa6"
+		case "uri", "url":
+			return "http://example.org"
+		}
+		return "string"
+	case "integer":
+		return float64(1)
+	case "number":
+		return 1.5
+	case "boolean":
+		return true
+	case "array":
+		return []interface{}{g.example(s.Items, depth+1)}
+	case "object", "":
+		if len(s.Properties) == 0 {
+			if s.Type == "" {
+				return nil
+			}
+			return map[string]interface{}{}
+		}
+		obj := map[string]interface{}{}
+		for name, p := range s.Properties {
+			obj[name] = g.example(p, depth+1)
+		}
+		return obj
+	}
+	return nil
+}
+
+// skeleton generates the structural schema used by the JSON check from s:
+// Required properties are kept, strings become "", integers 0, numbers 0.0,
+// booleans false and arrays an empty array. Parts which cannot be described
+// become null which matches anything.
+func (g *generator) skeleton(s *Schema, depth int) interface{} {
+	s = g.resolve(s)
+	if s == nil || depth > maxDepth || s.Nullable {
+		return nil
+	}
+	s = g.merged(s)
+	switch s.Type {
+	case "string":
+		return ""
+	case "integer":
+		return 0
+	case "number":
+		return json.Number("0.0")
+	case "boolean":
+		return false
+	case "array":
+		return []interface{}{}
+	case "object", "":
+		if s.Type == "" && len(s.Properties) == 0 {
+			return nil
+		}
+		obj := map[string]interface{}{}
+		for _, name := range s.Required {
+			if p, ok := s.Properties[name]; ok {
+				obj[name] = g.skeleton(p, depth+1)
+			} else {
+				obj[name] = nil
+			}
+		}
+		return obj
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package openapi
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/suite"
+)
+
+func TestParseYAML(t *testing.T) {
+	got, err := parseYAML([]byte(`
+a: 1    # comment
+b: "x # y"
+c:
+- 1.5
+- {d: [true, ~], 'e f': "g"}
+- h: |
+    line 1
+    line 2
+  i: null
+j: >-
+  folded
+  text
+k: a plain
+  multi line scalar
+l: [
+  p, q,
+  r ]
+`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	want := map[string]interface{}{
+		"a": 1.0,
+		"b": "x # y",
+		"c": []interface{}{
+			1.5,
+			map[string]interface{}{
+				"d":   []interface{}{true, nil},
+				"e f": "g",
+			},
+			map[string]interface{}{"h": "line 1\nline 2\n", "i": nil},
+		},
+		"j": "folded text",
+		"k": "a plain multi line scalar",
+		"l": []interface{}{"p", "q", "r"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %#v", got)
+	}
+
+	_, err = parseYAML([]byte("a: 1\na: 2\n"))
+	if err == nil || !strings.Contains(err.Error(), `key "a" already set`) {
+		t.Errorf("Got %v", err)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	file, err := os.Open("testdata/petstore.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	spec, err := Read(file)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	files, warnings, err := Generate(spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("Got warnings %q", warnings)
+	}
+
+	names := []string{}
+	fs := suite.FileSystem{}
+	for _, f := range files {
+		names = append(names, f.Name)
+		fs[f.Name] = &suite.File{Name: f.Name, Data: string(f.Data)}
+	}
+	if got := strings.Join(names, " "); got != "Pet_Store.suite pets/listPets.ht pets/createPet.ht showPet.ht DELETE_pets_petId.ht" {
+		t.Fatalf("Got files %s", got)
+	}
+
+	rs, err := suite.LoadRawSuite("Pet_Store.suite", fs)
+	if err != nil {
+		t.Fatalf("Cannot load generated suite: %s", err)
+	}
+	if got := rs.Variables["BASE_URL"]; got != "http://localhost:8080/v1" {
+		t.Errorf("Got BASE_URL %q", got)
+	}
+	tests := make([]*ht.Test, len(rs.RawTests()))
+	for i, rt := range rs.RawTests() {
+		tests[i], err = rt.ToTest(rs.Variables)
+		if err != nil {
+			t.Fatalf("Cannot convert %s: %s", rt, err)
+		}
+	}
+
+	list := tests[0]
+	if list.Request.URL != "http://localhost:8080/v1/pets" ||
+		list.Request.Params.Get("limit") != "10" || len(list.Request.Params) != 1 {
+		t.Errorf("Bad list request %+v", list.Request)
+	}
+	if len(list.Checks) != 3 {
+		t.Fatalf("Bad list checks %#v", list.Checks)
+	}
+	if c := list.Checks[1].(*ht.ContentType); c.Is != "application/json" {
+		t.Errorf("Bad ContentType check %#v", c)
+	}
+	if c := list.Checks[2].(*ht.JSON); c.Schema != `[]` {
+		t.Errorf("Bad JSON check %#v", c)
+	}
+
+	create := tests[1]
+	if create.Request.Method != "POST" || create.Request.Body != "{\n    \"name\": \"Rex\",\n    \"tag\": \"string\"\n}" ||
+		create.Request.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Bad create request %+v", create.Request)
+	}
+	if c := create.Checks[0].(*ht.StatusCode); c.Expect != 201 {
+		t.Errorf("Bad StatusCode check %#v", c)
+	}
+	if c := create.Checks[2].(*ht.JSON); c.Schema != `{"id":0,"name":"","weight":0.0}` {
+		t.Errorf("Bad JSON check %#v", c)
+	}
+
+	show := tests[2]
+	if show.Request.URL != "http://localhost:8080/v1/pets/42" ||
+		show.Request.Header.Get("X-Request-ID") != "3fa85f64-5717-4562-b3fc-2c963f66afa6" {
+		t.Errorf("Bad show request %+v", show.Request)
+	}
+
+	del := tests[3]
+	if del.Request.Method != "DELETE" || len(del.Checks) != 1 ||
+		del.Checks[0].(*ht.StatusCode).Expect != 204 {
+		t.Errorf("Bad delete test %+v %#v", del.Request, del.Checks)
+	}
+}
+
+func TestGenerateSwagger(t *testing.T) {
+	spec, err := Read(strings.NewReader(`{
+    "swagger": "2.0",
+    "info": {"title": "Users"},
+    "host": "api.example.org",
+    "basePath": "/api",
+    "schemes": ["https"],
+    "consumes": ["application/x-www-form-urlencoded"],
+    "paths": {
+        "/users": {
+            "post": {
+                "parameters": [
+                    {"name": "name", "in": "formData", "type": "string", "required": true},
+                    {"name": "age", "in": "formData", "type": "integer", "default": 30, "required": true}
+                ],
+                "responses": {
+                    "200": {"description": "ok", "schema": {"$ref": "#/definitions/User"}}
+                }
+            }
+        }
+    },
+    "definitions": {
+        "User": {"type": "object", "required": ["name"], "properties": {"name": {"type": "string"}}}
+    }
+}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	files, _, err := Generate(spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	data := string(files[0].Data)
+	if !strings.Contains(data, `"BASE_URL": "https://api.example.org/api"`) {
+		t.Errorf("Bad suite %s", data)
+	}
+	data = string(files[1].Data)
+	for _, want := range []string{`"age": [`, `"30"`, `"ParamsAs": "body"`,
+		`"Schema": "{\"name\":\"\"}"`} {
+		if !strings.Contains(data, want) {
+			t.Errorf("Missing %s in %s", want, data)
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	_, err := Read(strings.NewReader(`{"swagger": "1.2"}`))
+	if err == nil || err.Error() != "unsupported specification version 1.2" {
+		t.Errorf("Got %v", err)
+	}
+	_, err = Read(strings.NewReader("info:\n  title: No version\n"))
+	if err == nil || !strings.Contains(err.Error(), "missing openapi or swagger version") {
+		t.Errorf("Got %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package openapi
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+)
+
+// parseYAML parses a YAML document. Duplicate keys are an error.
+//
+// Mappings are returned as map[string]interface{}, sequences as
+// []interface{} and numbers as float64, just like encoding/json does.
+func parseYAML(data []byte) (interface{}, error) {
+	var v interface{}
+	if err := yaml.UnmarshalStrict(data, &v); err != nil {
+		return nil, err
+	}
+	return jsonify(v)
+}
+
+// jsonify converts the values produced by package yaml to the ones
+// produced by encoding/json.
+func jsonify(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			var err error
+			m[fmt.Sprint(key)], err = jsonify(val)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, val := range v {
+			var err error
+			s[i], err = jsonify(val)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case float64, string, bool, nil:
+		return v, nil
+	}
+	return nil, fmt.Errorf("yaml: unsupported value %v (%T)", v, v)
+}
@@ -0,0 +1,177 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package populate
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/vdobler/ht/errorlist"
+)
+
+// ----------------------------------------------------------------------------
+// Errors
+
+// Error is a problem populating a certain element of the soup.
+type Error struct {
+	// Path to the element in the soup: Object keys are strings, array
+	// indices are ints.
+	Path []interface{}
+
+	Err error
+}
+
+func (e Error) Error() string { return e.Err.Error() }
+
+// Within returns err with prefix prepended to the Path of all Errors in
+// err; other errors are turned into Errors located at prefix. Populators
+// which populate parts of their src on their own can use Within to report
+// the location of problems relative to their src.
+func Within(err error, prefix ...interface{}) error {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case errorlist.List:
+		list := make(errorlist.List, len(e))
+		for i, err := range e {
+			list[i] = Within(err, prefix...)
+		}
+		return list
+	case Error:
+		return Error{Path: join(prefix, e.Path...), Err: e.Err}
+	}
+	return Error{Path: join(prefix), Err: err}
+}
+
+// join returns a new slice containing a followed by b.
+func join(a []interface{}, b ...interface{}) []interface{} {
+	c := make([]interface{}, 0, len(a)+len(b))
+	c = append(c, a...)
+	return append(c, b...)
+}
+
+// path is the name of the element being populated as used in error
+// messages together with its location in the soup.
+type path struct {
+	name string
+	keys []interface{}
+}
+
+func (p path) String() string { return p.name }
+
+func (p path) field(name string) path {
+	return path{name: p.name + "." + name, keys: join(p.keys, name)}
+}
+
+func (p path) index(i int) path {
+	return path{name: fmt.Sprintf("%s[%d]", p.name, i), keys: join(p.keys, i)}
+}
+
+func (p path) key(k interface{}) path {
+	return path{name: fmt.Sprintf("%s[%v]", p.name, k), keys: join(p.keys, k)}
+}
+
+// errorf formats an Error located at p.
+func (p path) errorf(format string, args ...interface{}) error {
+	return Error{Path: p.keys, Err: fmt.Errorf(format, args...)}
+}
+
+// wrap locates err at p.
+func (p path) wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	return Error{Path: p.keys, Err: err}
+}
+
+// ----------------------------------------------------------------------------
+// Suggestions for misspelled keys
+
+// fieldNames returns the keys which can be used to populate the struct
+// type typ, including promoted fields.
+func fieldNames(typ reflect.Type) []string {
+	names := []string{}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Anonymous {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				names = append(names, fieldNames(ft)...)
+			}
+		}
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+		names = append(names, field.Name)
+		if tag := field.Tag.Get("populate"); tag != "" {
+			if name := strings.Split(tag, ",")[0]; name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// suggest returns a " (did you mean ...?)" hint listing the names which
+// are similar to key or the empty string if none is.
+func suggest(key string, names []string) string {
+	KEY := strings.ToUpper(key)
+	seen := map[string]bool{}
+	suggestions := []string{}
+	for _, name := range names {
+		if seen[name] || editDistance(strings.ToUpper(name), KEY) > 2 {
+			continue
+		}
+		seen[name] = true
+		suggestions = append(suggestions, name)
+	}
+	if len(suggestions) == 0 {
+		return ""
+	}
+	sort.Strings(suggestions)
+	return fmt.Sprintf(" (did you mean %s?)", strings.Join(suggestions, ", "))
+}
+
+// editDistance is the Damerau-Levenshtein distance (with adjacent
+// transpositions only) of a and b.
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	d := make([][]int, len(s)+1)
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			d[i][j] = min3(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d[i][j] = min3(d[i][j], d[i-2][j-2]+cost, d[i][j])
+			}
+		}
+	}
+	return d[len(s)][len(t)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
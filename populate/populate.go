@@ -21,55 +21,109 @@
 //   - time.Durations can be populated from ints or floats (containing
 //     the duration in nanoseconds) or from strings like "2.5s" or "45ms"
 //     i.e. strings parsable by time.ParseDuration.
+//   - time.Time can be populated from strings in one of the TimeLayouts.
+//   - url.URL and types implementing encoding.TextUnmarshaler (e.g.
+//     net.IP) can be populated from strings.
+//   - Fields promoted from embedded structs can be populated by their
+//     promoted name; nil pointers to embedded structs are allocated
+//     as needed.
+//
+// The key used to populate a struct field can be controlled with a
+// struct tag:
+//     type T struct {
+//         Timeout time.Duration `populate:"timeout,alt=Wait,alt=Delay"`
+//     }
+// Here the field Timeout is populated from the key "timeout". The field
+// name "Timeout" still works and the deprecated old keys "Wait" and "Delay"
+// are accepted too, but their use is reported via Warn.
+//
+// Problems are reported as Errors which contain the path to the offending
+// element in the soup so that callers can map them back to a position in
+// the source. Unknown keys come with suggestions of similar field names.
 //
 package populate
 
 import (
+	"encoding"
 	"fmt"
+	"log"
+	"net/url"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/vdobler/ht/errorlist"
 )
 
+// Warn is used to report the use of deprecated keys during population.
+var Warn = log.Printf
+
+// TimeLayouts are the layouts tried in order when populating a time.Time
+// from a string.
+var TimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
 // Populator is the interface a type can implement to provide a custom
 // deserialisation.
 type Populator interface {
 	Populate(src interface{}) error
 }
 
-// Strict populates dst from src failing if elements in src cannot be mapped
-// to dst.
-func Strict(dst, src interface{}) error {
+// OptionsPopulator is implemented by Populators which populate nested
+// values and honour the Options of the surrounding population.
+type OptionsPopulator interface {
+	PopulateWith(src interface{}, opts Options) error
+}
+
+// Options control how a value is populated.
+type Options struct {
+	// Lax ignores elements in src which cannot be mapped to dst.
+	Lax bool
+
+	// FoldCase allows keys to match struct fields (and the keys given in
+	// populate struct tags) case-insensitively like encoding/json does.
+	// An exact match is preferred.
+	FoldCase bool
+}
+
+// Populate populates dst from src according to opts. All problems found
+// are reported in the returned errorlist.List.
+func (opts Options) Populate(dst, src interface{}) error {
 	dv, sv := reflect.ValueOf(dst), reflect.ValueOf(src)
 	if dv.Kind() != reflect.Ptr || dv.IsNil() {
-		panic("populate: not a pointer or nil")
+		return fmt.Errorf("Not a pointer or nil")
 	}
 	x := reflect.New(dv.Type()).Elem()
-	err := recFillWith(x, sv, x.Type().Elem().Name(), true)
+	err := recFillWith(x, sv, path{name: x.Type().Elem().Name()}, opts)
 	if err != nil {
-		return err
+		return errorlist.List{}.Append(err)
 	}
 	dv.Elem().Set(x.Elem())
 	return nil
 }
 
+// Strict populates dst from src failing if elements in src cannot be mapped
+// to dst. All problems found are reported in the returned errorlist.List.
+func Strict(dst, src interface{}) error {
+	return Options{}.Populate(dst, src)
+}
+
 // Lax populates dst from src. Src may contain elements which cannot
-// be mapped to dst in which case they are ignored silently.
+// be mapped to dst in which case they are ignored silently. All other
+// problems are reported in the returned errorlist.List.
 func Lax(dst, src interface{}) error {
-	dv, sv := reflect.ValueOf(dst), reflect.ValueOf(src)
-	if dv.Kind() != reflect.Ptr || dv.IsNil() {
-		return fmt.Errorf("Not a pointer or nil")
-	}
-	x := reflect.New(dv.Type()).Elem()
-	err := recFillWith(x, sv, x.Type().Elem().Name(), false)
-	if err != nil {
-		return err
-	}
-	dv.Elem().Set(x.Elem())
-	return nil
+	return Options{Lax: true}.Populate(dst, src)
 }
 
-func setFloat(dst, src reflect.Value, elem string) error {
+func setFloat(dst, src reflect.Value, elem path) error {
 	f := 0.0
 
 	switch src.Kind() {
@@ -99,7 +153,7 @@ func setFloat(dst, src reflect.Value, elem string) error {
 	return nil
 }
 
-func setBool(dst, src reflect.Value, elem string) error {
+func setBool(dst, src reflect.Value, elem path) error {
 	b := false
 
 	switch src.Kind() {
@@ -135,7 +189,7 @@ func setBool(dst, src reflect.Value, elem string) error {
 	return nil
 }
 
-func setInt(dst, src reflect.Value, elem string) error {
+func setInt(dst, src reflect.Value, elem path) error {
 	i := int64(0)
 
 	switch src.Kind() {
@@ -169,7 +223,7 @@ func setInt(dst, src reflect.Value, elem string) error {
 	return nil
 }
 
-func setDuration(dst, src reflect.Value, elem string) error {
+func setDuration(dst, src reflect.Value, elem path) error {
 	switch src.Kind() {
 	case reflect.Int64:
 		if isDuration(src) {
@@ -195,11 +249,22 @@ func setDuration(dst, src reflect.Value, elem string) error {
 		elem, src.Interface(), src.Kind())
 }
 
-func setUint(dst, src reflect.Value, elem string) error {
+func setTime(dst, src reflect.Value, elem path) error {
+	s := strings.TrimSpace(src.String())
+	for _, layout := range TimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			dst.Set(reflect.ValueOf(t))
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot set %s <Time> to %q", elem, src.String())
+}
+
+func setUint(dst, src reflect.Value, elem path) error {
 	panic("not implemented")
 }
 
-func setSlice(dst, src reflect.Value, elem string, strict bool) error {
+func setSlice(dst, src reflect.Value, elem path, opts Options) error {
 	if !src.IsValid() {
 		// Src is a zero Value slice.
 		dst.Set(reflect.Zero(dst.Type()))
@@ -209,22 +274,20 @@ func setSlice(dst, src reflect.Value, elem string, strict bool) error {
 	if src.Kind() == reflect.Slice {
 		n := src.Len()
 		dst.Set(reflect.MakeSlice(dst.Type(), n, n))
+		errs := errorlist.List{}
 		for i := 0; i < n; i++ {
-			err := recFillWith(dst.Index(i), src.Index(i),
-				fmt.Sprintf("%s[%d]", elem, i), strict)
-			if err != nil {
-				return err
-			}
+			err := recFillWith(dst.Index(i), src.Index(i), elem.index(i), opts)
+			errs = errs.Append(err)
 		}
-		return nil
+		return errs.AsError()
 	}
 
 	// Autogenerated single element slice.
 	dst.Set(reflect.MakeSlice(dst.Type(), 1, 1))
-	return recFillWith(dst.Index(0), src, fmt.Sprintf("%s[%d]", elem, 0), strict)
+	return recFillWith(dst.Index(0), src, elem.index(0), opts)
 }
 
-func setMap(dst, src reflect.Value, elem string, strict bool) error {
+func setMap(dst, src reflect.Value, elem path, opts Options) error {
 	if !src.IsValid() {
 		// Src is a zero Value of a map.
 		dst.Set(reflect.Zero(dst.Type()))
@@ -234,76 +297,182 @@ func setMap(dst, src reflect.Value, elem string, strict bool) error {
 	switch src.Kind() {
 	case reflect.Map:
 		dst.Set(reflect.MakeMap(dst.Type()))
-		for _, key := range src.MapKeys() {
+		errs := errorlist.List{}
+		for _, key := range sortedKeys(src) {
 			srcValue := src.MapIndex(key)
 			dstValue := reflect.New(dst.Type().Elem()).Elem()
-			err := recFillWith(dstValue, srcValue,
-				fmt.Sprintf("%s[%v]", elem, key.Interface()), strict)
+			err := recFillWith(dstValue, srcValue, elem.key(key.Interface()), opts)
 			if err != nil {
-				return err
+				errs = errs.Append(err)
+				continue
 			}
 			// TODO: generate and fill destination key from source key as their types may differ.
 			dst.SetMapIndex(key, dstValue)
 		}
-		return nil
+		return errs.AsError()
 	}
 
 	mt := dst.Type()
-	return fmt.Errorf("cannot set %s <map[%s]%s> to %v <%s>",
+	return elem.errorf("cannot set %s <map[%s]%s> to %v <%s>",
 		elem, mt.Key().Kind(), mt.Elem().Kind(), src.Interface(), src.Kind())
 }
 
-func setStruct(dst, src reflect.Value, elem string, strict bool) error {
+func setStruct(dst, src reflect.Value, elem path, opts Options) error {
 	switch src.Kind() {
 	case reflect.Map:
-		for _, key := range src.MapKeys() {
+		errs := errorlist.List{}
+		for _, key := range sortedKeys(src) {
 			if key.Kind() != reflect.String {
-				return fmt.Errorf("cannot set %s to map with %s keys",
+				return elem.errorf("cannot set %s to map with %s keys",
 					elem, key.Kind())
 			}
 			name := key.String()
 			srcValue := src.MapIndex(key)
-			//field := dst.Type().FieldByName(name)
-			field := dst.FieldByName(name)
-			if !field.IsValid() {
-				if name == "comment" || !strict {
+			index, deprecated := lookupField(dst.Type(), name, opts.FoldCase)
+			if index == nil {
+				if name == "comment" || opts.Lax {
 					continue
 				}
-				return fmt.Errorf("unknown field %s in %s",
-					name, elem) // TODO: error is unclear
+				errs = errs.Append(elem.field(name).errorf(
+					"unknown field %s in %s%s", name, elem,
+					suggest(name, fieldNames(dst.Type()))))
+				continue
+			}
+			if deprecated != "" {
+				Warn("populate: %s.%s is deprecated, use %s instead",
+					elem, name, deprecated)
 			}
-			err := recFillWith(field, srcValue,
-				fmt.Sprintf("%s.%s", elem, name), strict)
+			field, err := fieldByIndex(dst, index, elem)
 			if err != nil {
-				return err
+				errs = errs.Append(err)
+				continue
 			}
+			err = recFillWith(field, srcValue, elem.field(name), opts)
+			errs = errs.Append(err)
 		}
-		return nil
+		return errs.AsError()
 	}
 
-	return fmt.Errorf("cannot set %s <%s> to %v <%s>",
+	return elem.errorf("cannot set %s <%s> to %v <%s>",
 		elem, dst.Kind(), src.Interface(), src.Kind())
 }
 
-func recFillWith(dst, src reflect.Value, elem string, strict bool) error {
+// sortedKeys returns the keys of the map m in a stable order so that
+// errors are reported in a reproducible sequence.
+func sortedKeys(m reflect.Value) []reflect.Value {
+	keys := m.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+	return keys
+}
+
+// lookupField returns the index of the field of the struct type typ which
+// is populated from key. Keys given by the populate struct tag take
+// precedence over the plain field names. Fields promoted from embedded
+// structs are found too. If key is a deprecated alternative name the
+// preferred key is returned as well.
+func lookupField(typ reflect.Type, key string, fold bool) ([]int, string) {
+	same := func(s string) bool { return s == key }
+	if index, preferred, ok := taggedField(typ, same); ok {
+		return index, preferred
+	}
+	if field, ok := typ.FieldByName(key); ok || !fold {
+		return field.Index, ""
+	}
+
+	similar := func(s string) bool { return strings.EqualFold(s, key) }
+	if index, preferred, ok := taggedField(typ, similar); ok {
+		return index, preferred
+	}
+	field, _ := typ.FieldByNameFunc(similar)
+	return field.Index, ""
+}
+
+// taggedField looks up the key matched by match in the populate tags of
+// typ and its embedded structs.
+func taggedField(typ reflect.Type, match func(string) bool) ([]int, string, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if ft := field.Type; field.Anonymous {
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if index, preferred, ok := taggedField(ft, match); ok {
+					return append([]int{i}, index...), preferred, true
+				}
+				continue
+			}
+		}
+		tag := field.Tag.Get("populate")
+		if tag == "" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		name := parts[0]
+		if name == "" {
+			name = field.Name
+		}
+		if match(name) {
+			return []int{i}, "", true
+		}
+		for _, part := range parts[1:] {
+			if strings.HasPrefix(part, "alt=") && match(part[len("alt="):]) {
+				return []int{i}, name, true
+			}
+		}
+	}
+	return nil, "", false
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex but allocates nil
+// pointers to embedded structs on the way to the promoted field.
+func fieldByIndex(v reflect.Value, index []int, elem path) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, elem.errorf(
+						"cannot set embedded pointer to unexported struct %s in %s",
+						v.Type().Elem().Name(), elem)
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+func recFillWith(dst, src reflect.Value, elem path, opts Options) error {
 	// fmt.Println("recFillWith", elem)
 	if src.Kind() == reflect.Interface {
 		src = src.Elem()
 		// fmt.Printf("Unwrapped interface src to %s\n", src.Kind())
-		return recFillWith(dst, src, elem, strict)
+		return recFillWith(dst, src, elem, opts)
 	}
 
 	if !dst.CanSet() {
 		// This should not happen, or?
-		return fmt.Errorf("cannot set element %s (%v)", elem, dst)
+		return elem.errorf("cannot set element %s (%v)", elem, dst)
 	}
 
 	if dst.Kind() != reflect.Ptr && dst.Type().Name() != "" && dst.CanAddr() {
 		dstAddr := dst.Addr()
+		if p, ok := dstAddr.Interface().(OptionsPopulator); ok {
+			err := p.PopulateWith(src.Interface(), opts)
+			if err != nil {
+				return Within(err, elem.keys...)
+			}
+			dst.Set(dstAddr.Elem())
+			return nil
+		}
 		if p, ok := dstAddr.Interface().(Populator); ok {
 			err := p.Populate(src.Interface())
 			if err != nil {
-				return err
+				return Within(err, elem.keys...)
 			}
 			dst.Set(dstAddr.Elem())
 			return nil
@@ -325,40 +494,69 @@ func recFillWith(dst, src reflect.Value, elem string, strict bool) error {
 
 	// fmt.Printf("recFillWith %s (%s) with %s \n", elem, dst.Kind(), src.Kind())
 
+	if src.Kind() == reflect.String && dst.CanAddr() {
+		if isTimeType(dst.Type()) {
+			return elem.wrap(setTime(dst, src, elem))
+		}
+		if isURLType(dst.Type()) {
+			u, err := url.Parse(src.String())
+			if err != nil {
+				return elem.errorf("cannot set %s <URL> to %q: %s",
+					elem, src.String(), err)
+			}
+			dst.Set(reflect.ValueOf(*u))
+			return nil
+		}
+		if u, ok := dst.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			err := u.UnmarshalText([]byte(src.String()))
+			if err != nil {
+				return elem.errorf("cannot set %s <%s> to %q: %s",
+					elem, dst.Type(), src.String(), err)
+			}
+			return nil
+		}
+	}
+
 	switch dst.Kind() {
 	case reflect.Bool:
-		return setBool(dst, src, elem)
+		return elem.wrap(setBool(dst, src, elem))
 	case reflect.Int64:
 		if isDuration(dst) {
-			return setDuration(dst, src, elem)
+			return elem.wrap(setDuration(dst, src, elem))
 		}
 		fallthrough
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
-		return setInt(dst, src, elem)
+		return elem.wrap(setInt(dst, src, elem))
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return setUint(dst, src, elem)
+		return elem.wrap(setUint(dst, src, elem))
 	case reflect.Float64, reflect.Float32:
-		return setFloat(dst, src, elem)
+		return elem.wrap(setFloat(dst, src, elem))
 	case reflect.String:
 		dst.SetString(fmt.Sprintf("%v", src.Interface()))
 		return nil
 	case reflect.Slice:
-		return setSlice(dst, src, elem, strict)
+		return setSlice(dst, src, elem, opts)
 	case reflect.Map:
-		return setMap(dst, src, elem, strict)
+		return setMap(dst, src, elem, opts)
 	case reflect.Struct:
-		return setStruct(dst, src, elem, strict)
+		return setStruct(dst, src, elem, opts)
 	case reflect.Interface:
 		dst.Set(src)
 	default:
-		return fmt.Errorf("cannot set %s <%s> to <%s>", elem, dst.Kind(), src.Kind())
+		return elem.errorf("cannot set %s <%s> to <%s>", elem, dst.Kind(), src.Kind())
 	}
 
 	return nil
 }
 
 func isDuration(v reflect.Value) bool {
-	t := v.Type()
-	return (t.PkgPath() == "time" && t.Name() == "Duration") ||
-		(t.PkgPath() == "github.com/vdobler/ht/ht" && t.Name() == "Duration")
+	return isDurationType(v.Type())
+}
+
+func isTimeType(t reflect.Type) bool {
+	return t.PkgPath() == "time" && t.Name() == "Time"
+}
+
+func isURLType(t reflect.Type) bool {
+	return t.PkgPath() == "net/url" && t.Name() == "URL"
 }
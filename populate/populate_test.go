@@ -7,11 +7,14 @@ package populate
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/vdobler/ht/errorlist"
 	"github.com/vdobler/ht/internal/hjson"
 )
 
@@ -304,3 +307,307 @@ func TestNullHandling(t *testing.T) {
 		t.Errorf("got/want\n%s\n%s", got, want)
 	}
 }
+
+func TestStructTags(t *testing.T) {
+	type Tagged struct {
+		URL     string        `populate:"url"`
+		Timeout time.Duration `populate:"Timeout,alt=Wait,alt=Delay"`
+		Plain   int
+	}
+	type Wrapper struct {
+		Tagged
+		Name string `populate:"name"`
+	}
+
+	var warnings []string
+	defer func(w func(string, ...interface{})) { Warn = w }(Warn)
+	Warn = func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	data := `{
+    url: "http://example.org"
+    Wait: "2s"
+    Plain: 3
+    name: "foo"
+}`
+	var raw interface{}
+	if err := hjson.Unmarshal([]byte(data), &raw); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+
+	v := Wrapper{}
+	if err := Strict(&v, raw); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if v.URL != "http://example.org" || v.Timeout != 2*time.Second ||
+		v.Plain != 3 || v.Name != "foo" {
+		t.Errorf("Got %+v", v)
+	}
+	if len(warnings) != 1 ||
+		warnings[0] != "populate: Wrapper.Wait is deprecated, use Timeout instead" {
+		t.Errorf("Got warnings %q", warnings)
+	}
+
+	// Field names still work.
+	warnings = nil
+	err := Strict(&v, map[string]interface{}{"URL": "x", "Timeout": "1s"})
+	if err != nil || v.URL != "x" || v.Timeout != time.Second || len(warnings) != 0 {
+		t.Errorf("Got %+v, %v, %q", v, err, warnings)
+	}
+
+	if err := Strict(&v, map[string]interface{}{"Sleep": "1s"}); err == nil {
+		t.Errorf("Missing error for unknown key")
+	}
+}
+
+func TestAllErrors(t *testing.T) {
+	data := `{
+    Int: "many"
+    Slice: [ "a", "b" ]
+    Int32s: [ 1, "two", 3, "four" ]
+    S: {
+        A: "x"
+        XXX: "unknown"
+    }
+    Duration: "long"
+}`
+	var raw interface{}
+	if err := hjson.Unmarshal([]byte(data), &raw); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+
+	v := T{}
+	err := Strict(&v, raw)
+	el, ok := err.(errorlist.List)
+	if !ok {
+		t.Fatalf("Got %T %v, want errorlist.List", err, err)
+	}
+	want := []string{
+		`cannot set T.Duration <Duration> to "long"`,
+		`cannot set T.Int <int> to "many"`,
+		`cannot set T.Int32s[1] <int32> to "two"`,
+		`cannot set T.Int32s[3] <int32> to "four"`,
+		`cannot set T.S.A <int> to "x"`,
+		`unknown field XXX in T.S`,
+	}
+	got := el.AsStrings()
+	if len(got) != len(want) {
+		t.Fatalf("Got %d errors, want %d:\n%s", len(got), len(want),
+			strings.Join(got, "\n"))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	// Lax ignores the unknown field but reports the rest.
+	err = Lax(&v, raw)
+	if el, ok := err.(errorlist.List); !ok || len(el) != len(want)-1 {
+		t.Errorf("Got %v", err)
+	}
+}
+
+func TestFoldCase(t *testing.T) {
+	type Request struct {
+		URL             string
+		FollowRedirects bool
+		Timeout         time.Duration `populate:"Timeout,alt=Wait"`
+		Embedded
+	}
+	data := `{
+    url: "http://example.org"
+    followRedirects: true
+    wait: "3s"
+    a: 7
+}`
+	var raw interface{}
+	if err := hjson.Unmarshal([]byte(data), &raw); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+
+	defer func(w func(string, ...interface{})) { Warn = w }(Warn)
+	Warn = func(string, ...interface{}) {}
+
+	v := Request{}
+	if err := Strict(&v, raw); err == nil {
+		t.Errorf("Missing error in case sensitive mode")
+	}
+
+	err := Options{FoldCase: true}.Populate(&v, raw)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := Strict(v, raw); err == nil {
+		t.Errorf("Missing error for non-pointer")
+	}
+	if v.URL != "http://example.org" || !v.FollowRedirects ||
+		v.Timeout != 3*time.Second || v.A != 7 {
+		t.Errorf("Got %+v", v)
+	}
+}
+
+type Options2 struct {
+	Verbose bool
+	Retries int `populate:"retries"`
+}
+
+type options3 struct {
+	Level int
+}
+
+type Promoted struct {
+	Name string
+	*Options2
+	options3
+	Embedded
+}
+
+func TestPromotedFields(t *testing.T) {
+	data := `{
+    Name: "foo"
+    Verbose: true
+    retries: 3
+    Level: 2
+    A: 9
+}`
+	var raw interface{}
+	if err := hjson.Unmarshal([]byte(data), &raw); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+
+	v := Promoted{}
+	if err := Strict(&v, raw); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if v.Name != "foo" || v.Options2 == nil || !v.Verbose || v.Retries != 3 ||
+		v.Level != 2 || v.A != 9 {
+		t.Errorf("Got %+v %+v", v, v.Options2)
+	}
+
+	// Untouched embedded pointers stay nil.
+	v = Promoted{}
+	if err := Strict(&v, map[string]interface{}{"Name": "bar"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if v.Options2 != nil {
+		t.Errorf("Got %+v", v.Options2)
+	}
+}
+
+// ----------------------------------------------------------------------------
+// TextUnmarshaler and time.Time
+
+type Texts struct {
+	IP   net.IP
+	IPs  []net.IP
+	Time time.Time
+	URL  *url.URL
+	Date *time.Time
+}
+
+func TestTextUnmarshaler(t *testing.T) {
+	data := `{
+    IP: "10.1.2.3"
+    IPs: [ "::1", "192.168.0.1" ]
+    Time: "2017-03-04T12:13:14+01:00"
+    URL: "https://example.org/path?q=1"
+    Date: "2017-03-04"
+}`
+	var raw interface{}
+	err := hjson.Unmarshal([]byte(data), &raw)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+
+	v := Texts{}
+	err = Strict(&v, raw)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if got := v.IP.String(); got != "10.1.2.3" {
+		t.Errorf("Got IP=%s", got)
+	}
+	if len(v.IPs) != 2 || v.IPs[0].String() != "::1" {
+		t.Errorf("Got IPs=%v", v.IPs)
+	}
+	if want := time.Date(2017, 3, 4, 12, 13, 14, 0, time.FixedZone("", 3600)); !v.Time.Equal(want) {
+		t.Errorf("Got Time=%s", v.Time)
+	}
+	if v.URL == nil || v.URL.Host != "example.org" || v.URL.RawQuery != "q=1" {
+		t.Errorf("Got URL=%v", v.URL)
+	}
+	if v.Date == nil || v.Date.Format("2006-01-02") != "2017-03-04" {
+		t.Errorf("Got Date=%v", v.Date)
+	}
+}
+
+func TestTextUnmarshalerErrors(t *testing.T) {
+	raw := map[string]interface{}{
+		"IP":   "10.1.2.300",
+		"Time": "yesterday",
+	}
+	err := Strict(&Texts{}, raw)
+	if err == nil {
+		t.Fatalf("Missing error")
+	}
+	el, ok := err.(errorlist.List)
+	if !ok || len(el) != 2 {
+		t.Fatalf("Got %#v", err)
+	}
+	if got := el[0].Error(); !strings.Contains(got, `Texts.IP <net.IP> to "10.1.2.300"`) {
+		t.Errorf("Got %s", got)
+	}
+	if got := el[1].Error(); got != `cannot set Texts.Time <Time> to "yesterday"` {
+		t.Errorf("Got %s", got)
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Error locations and suggestions
+
+func TestErrorPath(t *testing.T) {
+	raw := map[string]interface{}{
+		"Slice":  []interface{}{"a"},
+		"S":      map[string]interface{}{"B": "x"},
+		"PS":     map[string]interface{}{"CC": 1},
+		"Int32s": []interface{}{1, "two"},
+		"Dict":   map[string]interface{}{},
+	}
+	err := Strict(&T{}, raw)
+	el, ok := err.(errorlist.List)
+	if !ok || len(el) != 3 {
+		t.Fatalf("Got %v", err)
+	}
+	want := []struct {
+		path string
+		msg  string
+	}{
+		{"[Int32s 1]", `cannot set T.Int32s[1] <int32> to "two"`},
+		{"[PS CC]", `unknown field CC in T.PS (did you mean A, B, C?)`},
+		{"[S B]", `cannot set T.S.B <float64> to "x"`},
+	}
+	for i, w := range want {
+		e, ok := el[i].(Error)
+		if !ok {
+			t.Errorf("%d. got %T", i, el[i])
+			continue
+		}
+		if got := fmt.Sprint(e.Path); got != w.path {
+			t.Errorf("%d. path=%s, want %s", i, got, w.path)
+		}
+		if got := e.Error(); got != w.msg {
+			t.Errorf("%d. got %q, want %q", i, got, w.msg)
+		}
+	}
+
+	within := Within(errorlist.List{el[0], fmt.Errorf("plain")}, "X", 3)
+	paths := []string{}
+	for _, e := range within.(errorlist.List) {
+		paths = append(paths, fmt.Sprint(e.(Error).Path))
+	}
+	if got := strings.Join(paths, " "); got != "[X 3 Int32s 1] [X 3]" {
+		t.Errorf("Got %s", got)
+	}
+}
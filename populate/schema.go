@@ -0,0 +1,478 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package populate
+
+import (
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/vdobler/ht/errorlist"
+)
+
+// ----------------------------------------------------------------------------
+// JSON Schema generation
+
+// Schemer can be implemented by Populator types to describe the soup they
+// accept as a JSON Schema.
+type Schemer interface {
+	PopulateSchema(g *SchemaGenerator) map[string]interface{}
+}
+
+// SchemaGenerator generates JSON Schemas (draft-07) describing the soups
+// which can be populated into Go types. The schemas are lenient in the same
+// way population is: E.g. an int field accepts numbers, booleans and
+// strings and a slice accepts a single element too.
+// Named struct types end up in the definitions of the generated schema.
+type SchemaGenerator struct {
+	defs map[string]interface{}
+}
+
+// NewSchemaGenerator returns a new SchemaGenerator.
+func NewSchemaGenerator() *SchemaGenerator {
+	return &SchemaGenerator{defs: make(map[string]interface{})}
+}
+
+// Document returns a complete JSON Schema document for the given schema
+// (typically generated by g) including all definitions collected in g.
+func (g *SchemaGenerator) Document(schema map[string]interface{}) map[string]interface{} {
+	doc := map[string]interface{}{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+	}
+	for k, v := range schema {
+		doc[k] = v
+	}
+	if len(g.defs) > 0 {
+		doc["definitions"] = g.defs
+	}
+	return doc
+}
+
+// Schema returns the schema for the type of v. Named structs and Schemers
+// are referenced from the definitions.
+func (g *SchemaGenerator) Schema(v interface{}) map[string]interface{} {
+	return g.schema(reflect.TypeOf(v))
+}
+
+// StructSchema returns the (not referenced) object schema of the struct
+// type of v which may be extended by additional properties.
+func (g *SchemaGenerator) StructSchema(v interface{}) map[string]interface{} {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return g.structSchema(t)
+}
+
+var (
+	populatorType = reflect.TypeOf((*Populator)(nil)).Elem()
+	schemerType   = reflect.TypeOf((*Schemer)(nil)).Elem()
+
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func lenient(types ...string) map[string]interface{} {
+	list := make([]interface{}, len(types))
+	for i, t := range types {
+		list[i] = t
+	}
+	return map[string]interface{}{"type": list}
+}
+
+func (g *SchemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if isDurationType(t) {
+		return lenient("string", "number")
+	}
+	if isURLType(t) || (t.Kind() == reflect.Struct &&
+		reflect.PtrTo(t).Implements(textUnmarshalerType) &&
+		!reflect.PtrTo(t).Implements(populatorType)) {
+		return map[string]interface{}{"type": "string"}
+	}
+	if t.Name() != "" && (t.Kind() == reflect.Struct || reflect.PtrTo(t).Implements(populatorType)) {
+		return g.ref(t)
+	}
+
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return lenient("number", "boolean", "string")
+	case reflect.String:
+		return lenient("string", "number", "boolean")
+	case reflect.Slice, reflect.Array:
+		elem := g.schema(t.Elem())
+		if len(elem) == 0 {
+			return elem
+		}
+		return map[string]interface{}{"anyOf": []interface{}{
+			map[string]interface{}{"type": "array", "items": elem},
+			elem,
+			map[string]interface{}{"type": "null"},
+		}}
+	case reflect.Map:
+		return map[string]interface{}{"anyOf": []interface{}{
+			map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": g.schema(t.Elem()),
+			},
+			map[string]interface{}{"type": "null"},
+		}}
+	case reflect.Struct:
+		return g.structSchema(t)
+	}
+	return map[string]interface{}{} // anything
+}
+
+// ref returns a reference to the definition of the named type t.
+func (g *SchemaGenerator) ref(t reflect.Type) map[string]interface{} {
+	name := t.String()
+	ref := map[string]interface{}{"$ref": "#/definitions/" + name}
+	if _, ok := g.defs[name]; ok {
+		return ref
+	}
+	g.defs[name] = true // placeholder to stop recursion
+
+	var def map[string]interface{}
+	pt := reflect.PtrTo(t)
+	switch {
+	case pt.Implements(schemerType):
+		def = reflect.New(t).Interface().(Schemer).PopulateSchema(g)
+	case pt.Implements(populatorType):
+		def = map[string]interface{}{} // unknown, anything
+	default:
+		def = g.structSchema(t)
+	}
+	g.defs[name] = def
+	return ref
+}
+
+func (g *SchemaGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{
+		"comment": map[string]interface{}{},
+	}
+	g.addFields(props, t)
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+}
+
+// addFields adds the fields of the struct t to props. Fields promoted from
+// embedded structs are added unless shadowed.
+func (g *SchemaGenerator) addFields(props map[string]interface{}, t reflect.Type) {
+	embedded := []reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue // unexported
+		}
+		if field.Anonymous {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, ft)
+			}
+			if field.PkgPath != "" {
+				continue
+			}
+		}
+
+		name, alts := field.Name, []string(nil)
+		if tag := field.Tag.Get("populate"); tag != "" {
+			parts := strings.Split(tag, ",")
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, part := range parts[1:] {
+				if strings.HasPrefix(part, "alt=") {
+					alts = append(alts, part[len("alt="):])
+				}
+			}
+		}
+		s := g.schema(field.Type)
+		props[name] = s
+		if name != field.Name {
+			props[field.Name] = s
+		}
+		for _, alt := range alts {
+			props[alt] = map[string]interface{}{
+				"allOf":       []interface{}{s},
+				"description": fmt.Sprintf("deprecated, use %s", name),
+			}
+		}
+	}
+
+	for _, et := range embedded {
+		promoted := map[string]interface{}{}
+		g.addFields(promoted, et)
+		for name, s := range promoted {
+			if _, shadowed := props[name]; !shadowed {
+				props[name] = s
+			}
+		}
+	}
+}
+
+func isDurationType(t reflect.Type) bool {
+	return (t.PkgPath() == "time" && t.Name() == "Duration") ||
+		(t.PkgPath() == "github.com/vdobler/ht/ht" && t.Name() == "Duration")
+}
+
+// ----------------------------------------------------------------------------
+// Validation against a JSON Schema
+
+// Validate checks src against the JSON Schema document schema. It
+// implements the subset of JSON Schema generated by SchemaGenerator:
+// $ref (to local definitions), type, enum, const, properties,
+// additionalProperties, required, items, anyOf, allOf and if/then.
+// All violations are reported in the returned errorlist.List; elem is
+// the name of src used in the messages.
+func Validate(schema map[string]interface{}, src interface{}, elem string) error {
+	v := validator{root: schema}
+	return errorlist.List(v.validate(schema, src, elem)).AsError()
+}
+
+type validator struct {
+	root map[string]interface{}
+}
+
+func (v validator) validate(schema interface{}, src interface{}, elem string) []error {
+	s, ok := schema.(map[string]interface{})
+	if !ok {
+		if b, ok := schema.(bool); ok && !b {
+			return []error{fmt.Errorf("%s is not allowed", elem)}
+		}
+		return nil
+	}
+
+	if ref, ok := s["$ref"].(string); ok {
+		return v.validate(v.resolve(ref), src, elem)
+	}
+
+	if types, ok := s["type"]; ok && !matchesType(types, src) {
+		return []error{fmt.Errorf("%s must be %s, got %s",
+			elem, typeNames(types), jsonType(src))}
+	}
+	if c, ok := s["const"]; ok && !equalValue(c, src) {
+		return []error{fmt.Errorf("%s must be %v, got %v", elem, c, src)}
+	}
+	if enum, ok := s["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if equalValue(e, src) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return []error{fmt.Errorf("%s has illegal value %v", elem, src)}
+		}
+	}
+
+	errs := []error{}
+	if any, ok := s["anyOf"].([]interface{}); ok {
+		errs = append(errs, v.anyOf(any, src, elem)...)
+	}
+	if all, ok := s["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			errs = append(errs, v.validate(sub, src, elem)...)
+		}
+	}
+	if cond, ok := s["if"]; ok && len(v.validate(cond, src, elem)) == 0 {
+		if then, ok := s["then"]; ok {
+			errs = append(errs, v.validate(then, src, elem)...)
+		}
+	}
+
+	switch val := src.(type) {
+	case map[string]interface{}:
+		errs = append(errs, v.object(s, val, elem)...)
+	case []interface{}:
+		if items, ok := s["items"]; ok {
+			for i, item := range val {
+				errs = append(errs, v.validate(items, item,
+					fmt.Sprintf("%s[%d]", elem, i))...)
+			}
+		}
+	}
+	return errs
+}
+
+// anyOf reports the errors of the best matching alternative if src does
+// not match any of the alternatives.
+func (v validator) anyOf(alternatives []interface{}, src interface{}, elem string) []error {
+	var best []error
+	for i, alt := range alternatives {
+		errs := v.validate(alt, src, elem)
+		if len(errs) == 0 {
+			return nil
+		}
+		if i == 0 || len(errs) < len(best) {
+			best = errs
+		}
+	}
+	return best
+}
+
+func (v validator) object(s map[string]interface{}, obj map[string]interface{}, elem string) []error {
+	errs := []error{}
+	props, _ := s["properties"].(map[string]interface{})
+	if required, ok := s["required"].([]interface{}); ok {
+		for _, r := range required {
+			if name, ok := r.(string); ok {
+				if _, present := obj[name]; !present {
+					errs = append(errs, fmt.Errorf("%s misses %s", elem, name))
+				}
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	additional, hasAdditional := s["additionalProperties"]
+	for _, key := range keys {
+		sub := elem + "." + key
+		if ps, ok := props[key]; ok {
+			errs = append(errs, v.validate(ps, obj[key], sub)...)
+			continue
+		}
+		if !hasAdditional {
+			continue
+		}
+		if b, ok := additional.(bool); ok {
+			if !b {
+				errs = append(errs, unknownField(key, elem, props))
+			}
+			continue
+		}
+		errs = append(errs, v.validate(additional, obj[key],
+			fmt.Sprintf("%s[%s]", elem, key))...)
+	}
+	return errs
+}
+
+// unknownField produces an error for the unknown field key, suggesting
+// known fields with a similar name.
+func unknownField(key, elem string, props map[string]interface{}) error {
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	return fmt.Errorf("unknown field %s in %s%s", key, elem, suggest(key, names))
+}
+
+func (v validator) resolve(ref string) interface{} {
+	const prefix = "#/definitions/"
+	if !strings.HasPrefix(ref, prefix) {
+		return nil
+	}
+	defs, _ := v.root["definitions"].(map[string]interface{})
+	return defs[ref[len(prefix):]]
+}
+
+func matchesType(types interface{}, src interface{}) bool {
+	switch t := types.(type) {
+	case string:
+		return isType(t, src)
+	case []interface{}:
+		for _, tt := range t {
+			if name, ok := tt.(string); ok && isType(name, src) {
+				return true
+			}
+		}
+	case []string:
+		for _, name := range t {
+			if isType(name, src) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isType(name string, src interface{}) bool {
+	got := jsonType(src)
+	switch name {
+	case "number":
+		return got == "integer" || got == "number"
+	case "integer":
+		if got == "number" {
+			f, _ := toFloat(src)
+			return f == math.Trunc(f)
+		}
+	}
+	return got == name
+}
+
+// jsonType returns the JSON Schema type of the soup element src.
+func jsonType(src interface{}) string {
+	switch src.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return "integer"
+	case float32, float64:
+		return "number"
+	}
+	return fmt.Sprintf("%T", src)
+}
+
+func typeNames(types interface{}) string {
+	switch t := types.(type) {
+	case []interface{}:
+		names := make([]string, len(t))
+		for i, n := range t {
+			names[i] = fmt.Sprint(n)
+		}
+		return strings.Join(names, " or ")
+	case []string:
+		return strings.Join(t, " or ")
+	}
+	return fmt.Sprint(types)
+}
+
+func toFloat(x interface{}) (float64, bool) {
+	v := reflect.ValueOf(x)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// equalValue compares soup values treating all numbers alike.
+func equalValue(a, b interface{}) bool {
+	fa, oka := toFloat(a)
+	fb, okb := toFloat(b)
+	if oka && okb {
+		return fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,109 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package populate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vdobler/ht/internal/hjson"
+)
+
+type Schemed struct {
+	Name    string
+	Count   int `populate:"Count,alt=Cnt"`
+	Tags    []string
+	Inner   S
+	Options map[string]int
+}
+
+func TestSchemaGeneration(t *testing.T) {
+	g := NewSchemaGenerator()
+	doc := g.Document(g.StructSchema(Schemed{}))
+
+	if doc["$schema"] == nil {
+		t.Errorf("Missing $schema")
+	}
+	props := doc["properties"].(map[string]interface{})
+	for _, name := range []string{"Name", "Count", "Cnt", "Tags", "Inner", "Options", "comment"} {
+		if _, ok := props[name]; !ok {
+			t.Errorf("Missing property %s", name)
+		}
+	}
+	if got := props["Inner"].(map[string]interface{})["$ref"]; got != "#/definitions/populate.S" {
+		t.Errorf("Got Inner=%v", got)
+	}
+	defs := doc["definitions"].(map[string]interface{})
+	if _, ok := defs["populate.S"]; !ok {
+		t.Errorf("Missing definition of populate.S")
+	}
+}
+
+var validateTests = []struct {
+	src  string
+	want []string
+}{
+	{`{Name: "x", Count: 3, Tags: ["a", "b"], Inner: {A: 1, B: 2.5, C: "c"}}`, nil},
+	{`{Name: 123, Count: "3", Tags: "single", Options: {a: 1}}`, nil},
+	{`{Cnt: 3}`, nil},
+	{`{Nmae: "x"}`, []string{"unknown field Nmae in x (did you mean Name?)"}},
+	{`{Inner: {A: 1, X: 2}}`, []string{"unknown field X in x.Inner"}},
+	{`{Count: [1], Options: {a: "b", c: {}}}`, []string{
+		"x.Count must be",
+		"x.Options[c] must be",
+	}},
+}
+
+func TestValidate(t *testing.T) {
+	g := NewSchemaGenerator()
+	schema := g.Document(g.StructSchema(Schemed{}))
+
+	for i, tc := range validateTests {
+		var soup interface{}
+		if err := hjson.Unmarshal([]byte(tc.src), &soup); err != nil {
+			t.Fatalf("%d. %s", i, err)
+		}
+		err := Validate(schema, soup, "x")
+		if len(tc.want) == 0 {
+			if err != nil {
+				t.Errorf("%d. unexpected error %s", i, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%d. missing error", i)
+			continue
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%d. got %q, want %q", i, err, want)
+			}
+		}
+
+		// Soups which fail validation must fail strict population too.
+		if Strict(&Schemed{}, soup) == nil {
+			t.Errorf("%d. strict population succeeded", i)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	for i, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"NAME", "NMAE", 1},
+		{"REQUEST", "REQEST", 1},
+		{"MAIN", "MIAN", 1},
+		{"kitten", "sitting", 3},
+	} {
+		if got := editDistance(tc.a, tc.b); got != tc.want {
+			t.Errorf("%d. editDistance(%q,%q)=%d, want %d",
+				i, tc.a, tc.b, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,478 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package postman converts Postman collections (format v2.0 and v2.1)
+// into ht suites and tests.
+//
+// Requests with their headers, bodies and authentication, the collection
+// variables and common assertions of the test scripts are converted.
+// Postman's {{variable}} syntax is the same as ht's so variables are kept
+// as they are; dynamic variables like {{$guid}} are mapped to the
+// corresponding ht functions. Everything which cannot be converted is
+// reported as a warning.
+package postman
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/internal/convert"
+	"github.com/vdobler/ht/sanitize"
+)
+
+// ----------------------------------------------------------------------------
+// Postman Collection Format
+
+// Collection is a Postman collection.
+type Collection struct {
+	Info struct {
+		Name        string
+		Description Description
+		Schema      string
+	}
+	Item     []Item
+	Auth     *Auth
+	Event    []Event
+	Variable []Variable
+}
+
+// Item is a request or a folder (if Item is non-empty) in a collection.
+type Item struct {
+	Name        string
+	Description Description
+	Request     *Request
+	Item        []Item
+	Auth        *Auth
+	Event       []Event
+}
+
+// Request in a collection.
+type Request struct {
+	Method      string
+	URL         URL
+	Header      []KeyValue
+	Body        *Body
+	Auth        *Auth
+	Description Description
+}
+
+// UnmarshalJSON allows a request to be given as just the URL.
+func (r *Request) UnmarshalJSON(data []byte) error {
+	var raw string
+	if json.Unmarshal(data, &raw) == nil {
+		*r = Request{Method: "GET", URL: URL{Raw: raw}}
+		return nil
+	}
+	type plain Request
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*r = Request(p)
+	return nil
+}
+
+// URL of a request.
+type URL struct {
+	Raw      string
+	Query    []KeyValue
+	Variable []KeyValue
+}
+
+// UnmarshalJSON allows the URL to be given as a string.
+func (u *URL) UnmarshalJSON(data []byte) error {
+	var raw string
+	if json.Unmarshal(data, &raw) == nil {
+		*u = URL{Raw: raw}
+		return nil
+	}
+	type plain URL
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*u = URL(p)
+	return nil
+}
+
+// Description is a string or an object with the string as content.
+type Description string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Description) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		*d = Description(s)
+		return nil
+	}
+	var obj struct{ Content string }
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	*d = Description(obj.Content)
+	return nil
+}
+
+// KeyValue is a header, query parameter or form field.
+type KeyValue struct {
+	Key      string
+	Value    string
+	Type     string // "text" or "file" for form data
+	Src      interface{}
+	Disabled bool
+}
+
+// Body of a request.
+type Body struct {
+	Mode       string // raw, urlencoded, formdata, file or graphql
+	Raw        string
+	URLEncoded []KeyValue `json:"urlencoded"`
+	FormData   []KeyValue `json:"formdata"`
+	File       struct{ Src string }
+	GraphQL    struct {
+		Query     string
+		Variables string
+	}
+	Options struct {
+		Raw struct{ Language string }
+	}
+}
+
+// Auth describes the authentication of a request. In format v2.1 the
+// parameters are lists of key/value pairs, in v2.0 objects.
+type Auth struct {
+	Type   string
+	Basic  json.RawMessage
+	Bearer json.RawMessage
+	APIKey json.RawMessage `json:"apikey"`
+}
+
+// param returns the value of the parameter key in the raw parameters.
+func (a *Auth) param(raw json.RawMessage, key string) string {
+	var list []KeyValue
+	if json.Unmarshal(raw, &list) == nil {
+		for _, kv := range list {
+			if kv.Key == key {
+				return kv.Value
+			}
+		}
+		return ""
+	}
+	var obj map[string]interface{}
+	if json.Unmarshal(raw, &obj) == nil {
+		if v, ok := obj[key]; ok {
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
+
+// Event is a script attached to a collection, folder or request.
+type Event struct {
+	Listen string // "prerequest" or "test"
+	Script struct {
+		Exec ScriptLines
+	}
+}
+
+// ScriptLines of a script are given as a list of lines or as one string.
+type ScriptLines []string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (sl *ScriptLines) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		*sl = strings.Split(s, "\n")
+		return nil
+	}
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err != nil {
+		return err
+	}
+	*sl = lines
+	return nil
+}
+
+// Variable of a collection.
+type Variable struct {
+	Key      string
+	Value    interface{}
+	Disabled bool
+}
+
+// Read a Postman collection from r.
+func Read(r io.Reader) (*Collection, error) {
+	c := &Collection{}
+	if err := json.NewDecoder(r).Decode(c); err != nil {
+		return nil, fmt.Errorf("not a Postman collection: %s", err)
+	}
+	if c.Info.Schema != "" && !strings.Contains(c.Info.Schema, "v2.") {
+		return nil, fmt.Errorf("unsupported collection format %s (export as v2.1)",
+			c.Info.Schema)
+	}
+	return c, nil
+}
+
+// ----------------------------------------------------------------------------
+// Conversion
+
+// File, Test, Soup and Suite are the generated files and the reduced
+// versions of tests, checks and suites they contain.
+type (
+	File  = convert.File
+	Test  = convert.Test
+	Soup  = convert.Soup
+	Suite = convert.Suite
+)
+
+type converter struct {
+	*convert.Builder
+}
+
+// Convert translates c into a suite (the first of the returned files)
+// and one test per request. Requests in folders are placed in
+// corresponding subdirectories. Parts of c which cannot be translated
+// are reported in warnings.
+func Convert(c *Collection) (files []File, warnings []string, err error) {
+	name := c.Info.Name
+	if name == "" {
+		name = "Postman Collection"
+	}
+	cv := &converter{&convert.Builder{
+		Suite: Suite{
+			Name:        name,
+			Description: string(c.Info.Description),
+			Variables:   make(map[string]string),
+		},
+		Unnamed: "request",
+	}}
+	for _, v := range c.Variable {
+		if v.Disabled || v.Key == "" {
+			continue
+		}
+		value := ""
+		if v.Value != nil {
+			value = fmt.Sprint(v.Value)
+		}
+		cv.Suite.Variables[v.Key] = cv.substitute(value, "variable "+v.Key)
+	}
+
+	cv.items(c.Item, "", c.Auth, c.Event)
+	if len(cv.Suite.Main) == 0 {
+		return nil, cv.Warnings, fmt.Errorf("collection %q contains no requests", name)
+	}
+
+	files, err = cv.Files()
+	return files, cv.Warnings, err
+}
+
+// items converts the items (located in folder dir) which inherit the
+// given auth and events.
+func (cv *converter) items(items []Item, dir string, auth *Auth, events []Event) {
+	for _, item := range items {
+		itemAuth := auth
+		if item.Auth != nil {
+			itemAuth = item.Auth
+		}
+		itemEvents := append(events[:len(events):len(events)], item.Event...)
+		if item.Request == nil {
+			sub := path.Join(dir, sanitize.Filename(item.Name))
+			cv.items(item.Item, sub, itemAuth, itemEvents)
+			continue
+		}
+		if item.Request.Auth != nil {
+			itemAuth = item.Request.Auth
+		}
+
+		test, err := cv.test(item, itemAuth, itemEvents)
+		if err != nil {
+			cv.Warnf("%s: skipped: %s", item.Name, err)
+			continue
+		}
+		if err := cv.AddTest(dir, test); err != nil {
+			cv.Warnf("%s: skipped: %s", item.Name, err)
+		}
+	}
+}
+
+func (cv *converter) test(item Item, auth *Auth, events []Event) (*Test, error) {
+	req := item.Request
+	where := item.Name
+	if req.URL.Raw == "" {
+		return nil, fmt.Errorf("missing URL")
+	}
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = "GET"
+	}
+	description := item.Description
+	if description == "" {
+		description = req.Description
+	}
+
+	test := &Test{
+		Name:        item.Name,
+		Description: string(description),
+		Request: ht.Request{
+			Method: method,
+			URL:    cv.substitute(cv.url(req.URL), where),
+		},
+	}
+
+	for _, h := range req.Header {
+		if h.Disabled || h.Key == "" {
+			continue
+		}
+		if test.Request.Header == nil {
+			test.Request.Header = make(map[string][]string)
+		}
+		test.Request.Header[h.Key] = append(test.Request.Header[h.Key],
+			cv.substitute(h.Value, where))
+	}
+	cv.body(&test.Request, req.Body, where)
+	cv.auth(&test.Request, auth, where)
+	cv.scripts(test, events, where)
+
+	return test, nil
+}
+
+// url returns the raw URL of u with path variables like :id replaced
+// and disabled query parameters removed.
+func (cv *converter) url(u URL) string {
+	raw := u.Raw
+	for _, v := range u.Variable {
+		raw = strings.Replace(raw, "/:"+v.Key, "/"+v.Value, -1)
+	}
+	for _, q := range u.Query {
+		if !q.Disabled {
+			continue
+		}
+		param := q.Key + "=" + q.Value
+		raw = strings.Replace(raw, "&"+param, "", 1)
+		raw = strings.Replace(raw, "?"+param+"&", "?", 1)
+		raw = strings.Replace(raw, "?"+param, "", 1)
+	}
+	return raw
+}
+
+func (cv *converter) body(r *ht.Request, body *Body, where string) {
+	if body == nil {
+		return
+	}
+	switch body.Mode {
+	case "", "raw":
+		r.Body = cv.substitute(body.Raw, where)
+		if body.Options.Raw.Language == "json" && r.Header.Get("Content-Type") == "" {
+			if r.Header == nil {
+				r.Header = make(map[string][]string)
+			}
+			r.Header.Set("Content-Type", "application/json")
+		}
+	case "urlencoded", "formdata":
+		fields := body.URLEncoded
+		r.ParamsAs = "body"
+		if body.Mode == "formdata" {
+			fields = body.FormData
+			r.ParamsAs = "multipart"
+		}
+		for _, f := range fields {
+			if f.Disabled || f.Key == "" {
+				continue
+			}
+			if r.Params == nil {
+				r.Params = make(map[string][]string)
+			}
+			value := cv.substitute(f.Value, where)
+			if f.Type == "file" {
+				value = "@file:" + fmt.Sprint(f.Src)
+			}
+			r.Params[f.Key] = append(r.Params[f.Key], value)
+		}
+	case "file":
+		r.Body = "@file:" + body.File.Src
+	case "graphql":
+		payload := map[string]interface{}{"query": body.GraphQL.Query}
+		if body.GraphQL.Variables != "" {
+			payload["variables"] = json.RawMessage(body.GraphQL.Variables)
+		}
+		data, err := json.Marshal(payload)
+		if err != nil {
+			cv.Warnf("%s: bad GraphQL variables: %s", where, err)
+			return
+		}
+		r.Body = cv.substitute(string(data), where)
+		if r.Header == nil {
+			r.Header = make(map[string][]string)
+		}
+		r.Header.Set("Content-Type", "application/json")
+	default:
+		cv.Warnf("%s: unsupported body mode %q", where, body.Mode)
+	}
+}
+
+func (cv *converter) auth(r *ht.Request, auth *Auth, where string) {
+	if auth == nil {
+		return
+	}
+	setHeader := func(key, value string) {
+		if r.Header == nil {
+			r.Header = make(map[string][]string)
+		}
+		r.Header.Set(key, cv.substitute(value, where))
+	}
+	switch auth.Type {
+	case "", "noauth":
+	case "basic":
+		r.BasicAuthUser = cv.substitute(auth.param(auth.Basic, "username"), where)
+		r.BasicAuthPass = cv.substitute(auth.param(auth.Basic, "password"), where)
+	case "bearer":
+		setHeader("Authorization", "Bearer "+auth.param(auth.Bearer, "token"))
+	case "apikey":
+		key := auth.param(auth.APIKey, "key")
+		value := auth.param(auth.APIKey, "value")
+		if auth.param(auth.APIKey, "in") == "query" {
+			sep := "?"
+			if strings.Contains(r.URL, "?") {
+				sep = "&"
+			}
+			r.URL += sep + key + "=" + cv.substitute(value, where)
+		} else {
+			setHeader(key, value)
+		}
+	default:
+		cv.Warnf("%s: unsupported auth type %q", where, auth.Type)
+	}
+}
+
+// dynamicVariables maps Postman's dynamic variables to ht functions.
+var dynamicVariables = map[string]string{
+	"{{$guid}}":         "{{UUID}}",
+	"{{$randomUUID}}":   "{{UUID}}",
+	"{{$timestamp}}":    "{{NOW unix}}",
+	"{{$isoTimestamp}}": "{{NOW tz:UTC 2006-01-02T15:04:05.000Z}}",
+	"{{$randomInt}}":    "{{RANDOM int 0 1000}}",
+}
+
+// substitute replaces Postman's dynamic variables in s by the equivalent
+// ht functions.
+func (cv *converter) substitute(s, where string) string {
+	if !strings.Contains(s, "{{$") {
+		return s
+	}
+	for dyn, fn := range dynamicVariables {
+		s = strings.Replace(s, dyn, fn, -1)
+	}
+	if i := strings.Index(s, "{{$"); i >= 0 {
+		end := strings.Index(s[i:], "}}")
+		if end < 0 {
+			end = len(s) - i - 2
+		}
+		cv.Warnf("%s: unsupported dynamic variable %s", where, s[i:i+end+2])
+	}
+	return s
+}
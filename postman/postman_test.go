@@ -0,0 +1,116 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postman
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/suite"
+)
+
+func TestConvert(t *testing.T) {
+	file, err := os.Open("testdata/collection.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	collection, err := Read(file)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	files, warnings, err := Convert(collection)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	names := []string{}
+	fs := suite.FileSystem{}
+	for _, f := range files {
+		names = append(names, f.Name)
+		fs[f.Name] = &suite.File{Name: f.Name, Data: string(f.Data)}
+	}
+	if got := strings.Join(names, " "); got != "Pet_Store.suite Login.ht Pets/Get_Pet.ht Pets/Create_Pet.ht" {
+		t.Fatalf("Got files %s", got)
+	}
+	if len(warnings) != 1 || warnings[0] != "Login: 1 lines of the test script not converted" {
+		t.Errorf("Got warnings %q", warnings)
+	}
+
+	rs, err := suite.LoadRawSuite("Pet_Store.suite", fs)
+	if err != nil {
+		t.Fatalf("Cannot load generated suite: %s", err)
+	}
+	if got := rs.Variables["REQID"]; got != "{{UUID}}" {
+		t.Errorf("Got REQID %q", got)
+	}
+	tests := make([]*ht.Test, len(rs.RawTests()))
+	for i, rt := range rs.RawTests() {
+		tests[i], err = rt.ToTest(rs.Variables)
+		if err != nil {
+			t.Fatalf("Cannot convert %s: %s", rt, err)
+		}
+	}
+
+	login := tests[0]
+	if login.Request.Method != "POST" || login.Request.URL != "http://localhost:8080/login" ||
+		login.Request.ParamsAs != "body" || len(login.Request.Params) != 1 ||
+		login.Request.BasicAuthUser != "admin" || login.Request.BasicAuthPass != "{{PASS}}" ||
+		login.Request.Header.Get("Authorization") != "" {
+		t.Errorf("Bad login request %+v", login.Request)
+	}
+	if len(login.Checks) != 1 || login.Checks[0].(*ht.StatusCode).Expect != 200 {
+		t.Errorf("Bad login checks %#v", login.Checks)
+	}
+	if ex, ok := login.DataExtraction["TOKEN"].(*ht.JSONExtractor); !ok || ex.Element != "auth.token" {
+		t.Errorf("Bad login extraction %#v", login.DataExtraction)
+	}
+	if !strings.Contains(login.Description, "console.log(jsonData);") {
+		t.Errorf("Missing unconverted script in %q", login.Description)
+	}
+
+	get := tests[1]
+	if get.Request.URL != "http://localhost:8080/pets/42?expand=owner" ||
+		get.Request.Header.Get("Authorization") != "Bearer secret" ||
+		get.Request.Header.Get("X-Request-ID") == "{{$guid}}" ||
+		get.Request.Header.Get("X-Debug") != "" {
+		t.Errorf("Bad get request %+v", get.Request)
+	}
+	if len(get.Checks) != 4 {
+		t.Fatalf("Bad get checks %#v", get.Checks)
+	}
+	if c := get.Checks[2].(*ht.JSON); c.Element != "pets.0.name" || c.Equals != `"Rex"` {
+		t.Errorf("Bad JSON check %#v", c)
+	}
+	if c := get.Checks[3].(*ht.ResponseTime); c.Lower != 500*time.Millisecond {
+		t.Errorf("Bad ResponseTime check %#v", c)
+	}
+
+	create := tests[2]
+	if create.Request.Method != "POST" || create.Request.Body != `{"name": "Rex"}` ||
+		create.Request.Header.Get("Content-Type") != "application/json" ||
+		create.Request.Header.Get("Authorization") != "" {
+		t.Errorf("Bad create request %+v", create.Request)
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	_, err := Read(strings.NewReader(`{"info": {"schema": "https://schema.getpostman.com/json/collection/v1.0.0/collection.json"}}`))
+	if err == nil || !strings.Contains(err.Error(), "unsupported collection format") {
+		t.Errorf("Got %v", err)
+	}
+
+	c, err := Read(strings.NewReader(`{"info": {"name": "Empty"}, "item": [{"name": "Folder", "item": []}]}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	_, _, err = Convert(c)
+	if err == nil || err.Error() != `collection "Empty" contains no requests` {
+		t.Errorf("Got %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postman
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ----------------------------------------------------------------------------
+// Test scripts
+
+// A scriptRule converts a line of a test script matching re.
+type scriptRule struct {
+	re      *regexp.Regexp
+	convert func(test *Test, m []string)
+}
+
+const (
+	str      = `["']([^"']*)["']`                                  // quoted string
+	jsonElem = `(?:jsonData|pm\.response\.json\(\))\.([\w.\[\]]+)` // JSON element
+)
+
+var scriptRules = []scriptRule{
+	{regexp.MustCompile(`pm\.response\.to\.(?:have|be)\.status\((\d{3})\)`), statusCheck},
+	{regexp.MustCompile(`pm\.expect\(pm\.response\.code\)\.to\.(?:eql|equal|be\.equal)\((\d{3})\)`), statusCheck},
+	{regexp.MustCompile(`responseCode\.code\s*===?\s*(\d{3})`), statusCheck},
+	{regexp.MustCompile(`pm\.response\.to\.have\.header\(` + str + `\s*,\s*` + str + `\)`),
+		func(test *Test, m []string) {
+			test.AddCheck("Header", "Header", m[1], "Equals", m[2])
+		}},
+	{regexp.MustCompile(`pm\.response\.to\.have\.header\(` + str + `\)`),
+		func(test *Test, m []string) {
+			test.AddCheck("Header", "Header", m[1])
+		}},
+	{regexp.MustCompile(`pm\.expect\(pm\.response\.text\(\)\)\.to\.include\(` + str + `\)`), bodyContains},
+	{regexp.MustCompile(`responseBody\.has\(` + str + `\)`), bodyContains},
+	{regexp.MustCompile(`pm\.response\.to\.have\.body\(` + str + `\)`),
+		func(test *Test, m []string) {
+			test.AddCheck("Body", "Equals", m[1])
+		}},
+	{regexp.MustCompile(`pm\.response\.to\.(?:be\.json|have\.jsonBody\(\))`),
+		func(test *Test, m []string) {
+			test.AddCheck("ContentType", "Is", "json")
+		}},
+	{regexp.MustCompile(`pm\.expect\(pm\.response\.responseTime\)\.to\.be\.below\((\d+)\)`),
+		func(test *Test, m []string) {
+			test.AddCheck("ResponseTime", "Lower", m[1]+"ms")
+		}},
+	{regexp.MustCompile(`pm\.expect\(` + jsonElem + `\)\.to\.(?:eql|equal|be\.equal)\((.+)\);?\s*$`),
+		func(test *Test, m []string) {
+			test.AddCheck("JSON", "Element", jsonElement(m[1]),
+				"Equals", jsonLiteral(m[2]))
+		}},
+	{regexp.MustCompile(`pm\.expect\(` + jsonElem + `\)\.to\.(?:exist|be\.ok)`),
+		func(test *Test, m []string) {
+			test.AddCheck("JSON", "Element", jsonElement(m[1]))
+		}},
+	{regexp.MustCompile(`pm\.(?:environment|collectionVariables|globals|variables)\.set\(` + str + `\s*,\s*` + jsonElem + `\)`),
+		func(test *Test, m []string) {
+			if test.DataExtraction == nil {
+				test.DataExtraction = make(map[string]Soup)
+			}
+			test.DataExtraction[m[1]] = Soup{
+				"Extractor": "JSONExtractor",
+				"Element":   jsonElement(m[2]),
+			}
+		}},
+}
+
+// structuralLine matches lines of a test script which carry no meaning
+// on their own like the pm.test wrapper or the parsing of the body.
+var structuralLine = regexp.MustCompile(`^(?:` +
+	`|//.*` +
+	`|pm\.test\(.*function\s*\(\)\s*\{` +
+	`|pm\.test\(.*\(\)\s*=>\s*\{` +
+	`|\}\);?` +
+	`|(?:var|let|const)\s+jsonData\s*=\s*(?:pm\.response\.json\(\)|JSON\.parse\(responseBody\));?` +
+	`)$`)
+
+func statusCheck(test *Test, m []string) {
+	code, _ := strconv.Atoi(m[1])
+	test.AddCheck("StatusCode", "Expect", code)
+}
+
+func bodyContains(test *Test, m []string) {
+	test.AddCheck("Body", "Contains", m[1])
+}
+
+// jsonElement converts a JavaScript element access like a.b[0].c to the
+// element path a.b.0.c used by ht.
+func jsonElement(s string) string {
+	s = strings.Replace(s, "[", ".", -1)
+	return strings.Replace(s, "]", "", -1)
+}
+
+// jsonLiteral converts the JavaScript literal s to its JSON representation.
+func jsonLiteral(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return strconv.Quote(s[1 : len(s)-1])
+	}
+	return s
+}
+
+// scripts converts the test scripts in events to checks and extractions
+// of test. Lines which cannot be converted are kept in the description.
+func (cv *converter) scripts(test *Test, events []Event, where string) {
+	unconverted := []string{}
+	for _, ev := range events {
+		if ev.Listen != "test" {
+			if len(ev.Script.Exec) > 0 && ev.Listen == "prerequest" {
+				cv.Warnf("%s: pre-request scripts are not converted", where)
+			}
+			continue
+		}
+	line:
+		for _, line := range ev.Script.Exec {
+			line = strings.TrimSpace(line)
+			if structuralLine.MatchString(line) {
+				continue
+			}
+			for _, rule := range scriptRules {
+				if m := rule.re.FindStringSubmatch(line); m != nil {
+					rule.convert(test, m)
+					continue line
+				}
+			}
+			unconverted = append(unconverted, line)
+		}
+	}
+	if len(unconverted) == 0 {
+		return
+	}
+	cv.Warnf("%s: %d lines of the test script not converted", where, len(unconverted))
+	if test.Description != "" {
+		test.Description += "\n\n"
+	}
+	test.Description += "Unconverted Postman test script:\n    " +
+		strings.Join(unconverted, "\n    ")
+}
@@ -0,0 +1,153 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package recorder
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+// HTTP Archive
+
+// harNameValue is a header, cookie or parameter in a HAR file.
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// harEntry is the relevant part of one entry of a HAR 1.2 file.
+type harEntry struct {
+	StartedDateTime string `json:"startedDateTime"`
+	Request         struct {
+		Method   string         `json:"method"`
+		URL      string         `json:"url"`
+		Headers  []harNameValue `json:"headers"`
+		PostData *struct {
+			MimeType string         `json:"mimeType"`
+			Text     string         `json:"text"`
+			Params   []harNameValue `json:"params"`
+		} `json:"postData"`
+	} `json:"request"`
+	Response struct {
+		Status  int            `json:"status"`
+		Headers []harNameValue `json:"headers"`
+		Content struct {
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+			Encoding string `json:"encoding"`
+		} `json:"content"`
+	} `json:"response"`
+}
+
+// ReadHAR reads the HTTP Archive (HAR) from r and returns its entries as
+// events suitable for DumpEventsWith. Entries ignored by opts and entries
+// without a response (e.g. blocked or cancelled requests) are skipped.
+func ReadHAR(r io.Reader, opts Options) ([]Event, error) {
+	var har struct {
+		Log struct {
+			Entries []harEntry `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.NewDecoder(r).Decode(&har); err != nil {
+		return nil, err
+	}
+
+	events := []Event{}
+	for i, entry := range har.Log.Entries {
+		if entry.Response.Status == 0 {
+			continue
+		}
+		e, err := entry.event()
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %s", i+1, err)
+		}
+		if opts.ignore(e) {
+			continue
+		}
+		name := e.extractName()
+		if name == "" {
+			name = e.Request.Method + " " + e.Request.URL.Path
+		}
+		e.Name = fmt.Sprintf("Event %d: %s", len(events)+1, name)
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// event converts entry to an Event.
+func (entry harEntry) event() (Event, error) {
+	e := Event{}
+	if entry.Request.PostData != nil {
+		pd := entry.Request.PostData
+		e.RequestBody = pd.Text
+		if e.RequestBody == "" && len(pd.Params) > 0 {
+			params := url.Values{}
+			for _, p := range pd.Params {
+				params.Add(p.Name, p.Value)
+			}
+			e.RequestBody = params.Encode()
+		}
+	}
+
+	req, err := http.NewRequest(entry.Request.Method, entry.Request.URL,
+		strings.NewReader(e.RequestBody))
+	if err != nil {
+		return e, err
+	}
+	for _, h := range entry.Request.Headers {
+		// Skip HTTP/2 pseudo headers like :authority and the headers
+		// which package http sets itself.
+		if strings.HasPrefix(h.Name, ":") ||
+			http.CanonicalHeaderKey(h.Name) == "Host" ||
+			http.CanonicalHeaderKey(h.Name) == "Content-Length" {
+			continue
+		}
+		req.Header.Add(h.Name, h.Value)
+	}
+	if pd := entry.Request.PostData; pd != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", pd.MimeType)
+	}
+	e.Request = req
+
+	body := entry.Response.Content.Text
+	if entry.Response.Content.Encoding == "base64" {
+		data, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return e, fmt.Errorf("response body: %s", err)
+		}
+		body = string(data)
+	}
+	e.ResponseBody = body
+
+	rec := httptest.NewRecorder()
+	for _, h := range entry.Response.Headers {
+		// The content in the HAR is already decoded.
+		switch http.CanonicalHeaderKey(h.Name) {
+		case "Content-Encoding", "Content-Length", "Transfer-Encoding":
+			continue
+		}
+		rec.HeaderMap.Add(h.Name, h.Value)
+	}
+	if rec.HeaderMap.Get("Content-Type") == "" && entry.Response.Content.MimeType != "" {
+		rec.HeaderMap.Set("Content-Type", entry.Response.Content.MimeType)
+	}
+	rec.Code = entry.Response.Status
+	rec.Body.WriteString(body)
+	e.Response = rec
+
+	e.Timestamp, err = time.Parse(time.RFC3339Nano, entry.StartedDateTime)
+	if err != nil {
+		e.Timestamp = time.Now()
+	}
+	return e, nil
+}
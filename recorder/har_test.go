@@ -0,0 +1,89 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package recorder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/vdobler/ht/suite"
+)
+
+func TestReadHAR(t *testing.T) {
+	file, err := os.Open("testdata/session.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	events, err := ReadHAR(file, Options{IgnoredContentType: regexp.MustCompile("css")})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Got %d events, want 2", len(events))
+	}
+
+	login, cart := events[0], events[1]
+	if login.Name != "Event 1: POST /login" || cart.Name != "Event 2: Your Cart" {
+		t.Errorf("Got names %q and %q", login.Name, cart.Name)
+	}
+	if login.RequestBody != "user=alice&password=secret" {
+		t.Errorf("Got request body %q", login.RequestBody)
+	}
+	if h := login.Request.Header; h.Get(":authority") != "" || h.Get("Content-Length") != "" ||
+		h.Get("Accept") != "text/html" {
+		t.Errorf("Got request header %v", h)
+	}
+	if login.Response.Code != 302 {
+		t.Errorf("Got status %d", login.Response.Code)
+	}
+	if !strings.Contains(cart.ResponseBody, "<h1>Cart</h1>") {
+		t.Errorf("Got response body %q", cart.ResponseBody)
+	}
+	if ce := cart.Response.HeaderMap.Get("Content-Encoding"); ce != "" {
+		t.Errorf("Got Content-Encoding %q", ce)
+	}
+
+	dir, err := ioutil.TempDir("", "har")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = DumpEventsWith(events, dir, "Session", DumpOptions{
+		HostVariables: map[string]string{"shop.example.org": "SHOP"},
+		KeepCookies:   true,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	rs, err := suite.LoadRawSuite(filepath.Join(dir, "session.suite"), nil)
+	if err != nil {
+		t.Fatalf("Cannot load generated suite: %s", err)
+	}
+	if !rs.KeepCookies || rs.Variables["SHOP"] != "shop.example.org" {
+		t.Errorf("Got KeepCookies=%t Variables=%v", rs.KeepCookies, rs.Variables)
+	}
+	rawTests := rs.RawTests()
+	if len(rawTests) != 2 {
+		t.Fatalf("Got %d tests", len(rawTests))
+	}
+	test, err := rawTests[1].ToTest(rs.Variables)
+	if err != nil {
+		t.Fatalf("Cannot convert test: %s", err)
+	}
+	if test.Request.URL != "https://shop.example.org/cart" ||
+		test.Request.Params.Get("sort") != "price" {
+		t.Errorf("Got URL %q and params %v", test.Request.URL, test.Request.Params)
+	}
+	// Cookie session was set by the login response and is kept in the jar.
+	if len(test.Request.Cookies) != 1 || test.Request.Cookies[0].Name != "theme" {
+		t.Errorf("Got cookies %v", test.Request.Cookies)
+	}
+}
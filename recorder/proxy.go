@@ -0,0 +1,287 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package recorder
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vdobler/ht/sanitize"
+)
+
+// Proxy is a forward HTTP proxy which captures the request/response pairs
+// passing through it. HTTPS traffic tunneled via CONNECT is intercepted
+// with certificates issued by CA; clients must trust CA for this to work.
+type Proxy struct {
+	// CA issues the certificates for intercepted HTTPS connections.
+	// CONNECT requests are rejected if CA is nil.
+	CA *CA
+
+	// Options select the events to capture. Disarm and Rewrite are
+	// not used by Proxy.
+	Options Options
+
+	// Transport is used to forward the requests. If nil
+	// http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// Record is called for each captured event.
+	Record func(Event)
+}
+
+// hopHeaders are the hop-by-hop headers which are not forwarded.
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// ServeHTTP forwards r to its destination and records the exchange.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.intercept(w, r)
+		return
+	}
+	if !r.URL.IsAbs() {
+		http.Error(w, "ht record is a proxy, not a web server", http.StatusBadRequest)
+		return
+	}
+	p.forward(w, r)
+}
+
+// intercept handles a CONNECT request by terminating the TLS connection
+// of the client with a certificate issued by p.CA and serving the
+// tunneled requests.
+func (p *Proxy) intercept(w http.ResponseWriter, r *http.Request) {
+	if p.CA == nil {
+		http.Error(w, "HTTPS interception not enabled", http.StatusMethodNotAllowed)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "cannot hijack connection", http.StatusInternalServerError)
+		return
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, err = io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	target := r.Host
+	hostname, port, err := net.SplitHostPort(target)
+	if err != nil {
+		hostname, port = target, "443"
+	}
+	if port == "443" {
+		target = hostname
+	}
+	config := &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if hello.ServerName != "" {
+				return p.CA.Certificate(hello.ServerName)
+			}
+			return p.CA.Certificate(hostname)
+		},
+		NextProtos: []string{"http/1.1"},
+	}
+	tunnel := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.URL.Scheme = "https"
+		r.URL.Host = target
+		p.forward(w, r)
+	})
+	server := &http.Server{
+		Handler:  tunnel,
+		ErrorLog: log.New(ioutil.Discard, "", 0),
+	}
+	go server.Serve(&connListener{conn: tls.Server(conn, config)})
+}
+
+// forward sends r to its destination, copies the response to w and
+// records the exchange.
+func (p *Proxy) forward(w http.ResponseWriter, r *http.Request) {
+	requestBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	outreq := r.Clone(r.Context())
+	outreq.RequestURI = ""
+	outreq.Body = ioutil.NopCloser(bytes.NewReader(requestBody))
+	outreq.ContentLength = int64(len(requestBody))
+	for _, h := range hopHeaders {
+		outreq.Header.Del(h)
+	}
+
+	transport := p.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	timestamp := time.Now()
+	resp, err := transport.RoundTrip(outreq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	for _, h := range hopHeaders {
+		resp.Header.Del(h)
+	}
+	resp.Header.Del("Content-Length")
+
+	rr := httptest.NewRecorder()
+	for h, vv := range resp.Header {
+		w.Header()[h] = vv
+		rr.HeaderMap[h] = vv
+	}
+	w.WriteHeader(resp.StatusCode)
+	w.Write(body)
+	rr.WriteHeader(resp.StatusCode)
+	rr.Write(body)
+
+	responseBody := body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		if gz, err := gzip.NewReader(bytes.NewReader(body)); err == nil {
+			if unzipped, err := ioutil.ReadAll(gz); err == nil {
+				responseBody = unzipped
+			}
+		}
+	}
+
+	outreq.Body = nil
+	e := Event{
+		Request:      outreq,
+		Response:     rr,
+		RequestBody:  string(requestBody),
+		ResponseBody: string(responseBody),
+		Timestamp:    timestamp,
+	}
+	if p.Options.ignore(e) || p.Record == nil {
+		return
+	}
+	p.Record(e)
+}
+
+// connListener is a net.Listener which accepts just one connection.
+type connListener struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return nil, io.EOF
+	}
+	conn := l.conn
+	l.conn = nil
+	return conn, nil
+}
+
+func (l *connListener) Close() error { return nil }
+
+func (l *connListener) Addr() net.Addr { return dummyAddr{} }
+
+type dummyAddr struct{}
+
+func (dummyAddr) Network() string { return "tcp" }
+func (dummyAddr) String() string  { return "tunnel" }
+
+// ----------------------------------------------------------------------------
+// Writer
+
+// Writer writes events as tests to a directory as soon as they are
+// captured. A suite file executing all written tests in order is kept
+// up to date.
+type Writer struct {
+	Directory string      // Directory to write the tests and the suite to.
+	Suite     string      // Name of the suite.
+	Options   DumpOptions // Options used to generate the tests.
+
+	mu     sync.Mutex
+	suite  *Suite
+	jarred map[string]bool
+}
+
+// Write generates a test from e and writes it and the updated suite to
+// w.Directory. It returns the name of the test file written. Unnamed
+// events get a name like "Event 3: Title" derived from the response.
+func (w *Writer) Write(e Event) (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.suite == nil {
+		if err := os.MkdirAll(w.Directory, 0777); err != nil {
+			return "", err
+		}
+		w.suite = &Suite{
+			Name:        w.Suite,
+			Description: fmt.Sprintf("Recorded at %s", time.Now()),
+			Variables:   map[string]string{},
+			KeepCookies: w.Options.KeepCookies,
+		}
+		for host, name := range w.Options.HostVariables {
+			w.suite.Variables[name] = host
+		}
+		w.jarred = map[string]bool{}
+	}
+
+	if e.Name == "" {
+		name := e.extractName()
+		if name == "" {
+			name = e.Request.Method + " " + e.Request.URL.Path
+		}
+		e.Name = fmt.Sprintf("Event %d: %s", len(w.suite.Main)+1, name)
+	}
+	test := w.Options.test(e, w.jarred)
+	name := sanitize.Filename(e.Name) + ".ht"
+	filename := path.Join(w.Directory, name)
+	if err := writeTest(test, filename); err != nil {
+		return "", err
+	}
+	w.suite.Main = append(w.suite.Main, struct{ File string }{name})
+	err := writeSuite(*w.suite, path.Join(w.Directory, suiteFilename(w.Suite)))
+	return filename, err
+}
+
+// suiteFilename returns the name of the file the suite with the given name
+// is written to.
+func suiteFilename(suitename string) string {
+	name := strings.ToLower(strings.Replace(suitename, " ", "_", -1))
+	if !strings.HasSuffix(name, ".suite") {
+		name += ".suite"
+	}
+	return name
+}
@@ -0,0 +1,112 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package recorder
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/vdobler/ht/suite"
+)
+
+func TestProxy(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"path": %q, "ok": true}`, r.URL.Path)
+	})
+	backend := httptest.NewServer(handler)
+	defer backend.Close()
+	tlsBackend := httptest.NewTLSServer(handler)
+	defer tlsBackend.Close()
+
+	ca, err := NewCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "recorder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writer := &Writer{Directory: dir, Suite: "Recorded"}
+
+	var mu sync.Mutex
+	written := []string{}
+	proxy := httptest.NewServer(&Proxy{
+		CA:        ca,
+		Transport: tlsBackend.Client().Transport, // trusts both backends
+		Record: func(e Event) {
+			filename, err := writer.Write(e)
+			if err != nil {
+				t.Errorf("Unexpected error: %s", err)
+			}
+			mu.Lock()
+			written = append(written, filename)
+			mu.Unlock()
+		},
+	})
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyURL(proxyURL),
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		},
+	}
+
+	for _, u := range []string{backend.URL + "/plain", tlsBackend.URL + "/secure"} {
+		resp, err := client.Get(u)
+		if err != nil {
+			t.Fatalf("GET %s: %s", u, err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if !strings.Contains(string(body), `"ok": true`) {
+			t.Errorf("GET %s: got body %q", u, body)
+		}
+	}
+
+	if len(written) != 2 {
+		t.Fatalf("Got %d written tests, want 2: %v", len(written), written)
+	}
+	if want := filepath.Join(dir, "Event_1_GET_plain.ht"); written[0] != want {
+		t.Errorf("Got %q, want %q", written[0], want)
+	}
+
+	rs, err := suite.LoadRawSuite(filepath.Join(dir, "recorded.suite"), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	rawTests := rs.RawTests()
+	if len(rawTests) != 2 {
+		t.Fatalf("Got %d tests, want 2", len(rawTests))
+	}
+	for i, prefix := range []string{"http://", "https://"} {
+		test, err := rawTests[i].ToTest(rs.Variables)
+		if err != nil {
+			t.Fatalf("Cannot convert test: %s", err)
+		}
+		if !strings.HasPrefix(test.Request.URL, prefix) {
+			t.Errorf("Test %d: got URL %q", i, test.Request.URL)
+		}
+		// StatusCode, ContentType, JSON and two JSON elements.
+		if len(test.Checks) != 5 {
+			t.Errorf("Test %d: got %d checks: %v", i, len(test.Checks), test.Checks)
+		}
+	}
+}
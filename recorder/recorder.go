@@ -4,7 +4,8 @@
 // license that can be found in the LICENSE file.
 
 // Package recorder allows to capture request/response pairs via a
-// reverse proxy and generate tests for these pairs.
+// reverse proxy or a capturing (forward) proxy and generate tests for
+// these pairs.
 package recorder
 
 import (
@@ -24,8 +25,10 @@ import (
 	"os"
 	"path"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"golang.org/x/net/html"
 
@@ -365,11 +368,36 @@ type Suite struct {
 	Main        []struct {
 		File string
 	}
-	Variables map[string]string
+	Variables   map[string]string
+	KeepCookies bool `json:",omitempty"`
+}
+
+// DumpOptions control how DumpEventsWith generates tests.
+type DumpOptions struct {
+	// HostVariables maps hosts to the names of variables used instead of
+	// the host in the URLs of the generated tests. The suite sets these
+	// variables to the original host. Other hosts are kept literally.
+	HostVariables map[string]string
+
+	// KeepCookies generates a suite which keeps cookies in a cookie jar.
+	// Cookies set by earlier responses are not sent explicitly by the
+	// tests but taken from the jar.
+	KeepCookies bool
 }
 
 // DumpEvents writes events to directory, it extracts common request headers.
 func DumpEvents(events []Event, directory string, suitename string) error {
+	return DumpEventsWith(events, directory, suitename, DumpOptions{
+		HostVariables: map[string]string{remoteHost: "HOSTNAME"},
+	})
+}
+
+// DumpEventsWith writes events to directory like DumpEvents does but
+// controlled by opts.
+func DumpEventsWith(events []Event, directory string, suitename string, opts DumpOptions) error {
+	if len(events) == 0 {
+		return fmt.Errorf("no events to dump")
+	}
 	err := os.MkdirAll(directory, 0777)
 	if err != nil {
 		return err
@@ -394,65 +422,17 @@ func DumpEvents(events []Event, directory string, suitename string) error {
 	suite := Suite{
 		Name:        suitename,
 		Description: fmt.Sprintf("Generated at %s", time.Now()),
-		Variables: map[string]string{
-			"HOSTNAME": remoteHost,
-		},
+		Variables:   map[string]string{},
+		KeepCookies: opts.KeepCookies,
+	}
+	for host, name := range opts.HostVariables {
+		suite.Variables[name] = host
 	}
 
+	jarred := map[string]bool{} // names of cookies set by earlier responses
 	for _, e := range events {
-		host := e.Request.URL.Host
-		e.Request.URL.Host = "H.O.S.T.N.A.M.E"
-		cookies := []ht.Cookie{}
-		for _, c := range e.Request.Cookies() {
-			cookies = append(cookies, ht.Cookie{Name: c.Name, Value: c.Value})
-		}
-		e.Request.Header.Del("Cookie")
-
-		// Inspect body and extract parameters if appropriate.
-		queryParams := e.Request.URL.Query()
-		rawQuery := e.Request.URL.RawQuery
-		e.Request.URL.RawQuery = "" // clear to prevent reparsing when body is analyzed
-		body, bodyParams, paramsAs := scanRequestBody(&e)
-
-		var params url.Values
-		if len(queryParams) > 0 && len(bodyParams) > 0 {
-			// Parameters in URL _and_ body: Must keep both
-			e.Request.URL.RawQuery = rawQuery
-			params = bodyParams
-		} else {
-			// Just one "type" of parameters.
-			if len(queryParams) > 0 {
-				params = queryParams
-				paramsAs = ""
-			} else {
-				params = bodyParams
-			}
-		}
-		if params == nil {
-			params = make(url.Values)
-		}
-		urlString := e.Request.URL.String()
-		urlString = strings.Replace(urlString, "H.O.S.T.N.A.M.E", "{{HOSTNAME}}", 1)
-
-		dropUnnecessaryHeaders(e.Request.Header)
-
-		checks := extractChecks(e)
-
-		test := &Test{
-			Name:        e.Name,
-			Description: fmt.Sprintf("Recorded from %s on %s", host, time.Now()),
-			Mixin:       []string{commonHeadersName},
-			Request: ht.Request{
-				Method:   e.Request.Method,
-				URL:      urlString,
-				Cookies:  cookies,
-				Header:   e.Request.Header,
-				Params:   params,
-				ParamsAs: paramsAs,
-				Body:     body,
-			},
-			Checks: checks,
-		}
+		test := opts.test(e, jarred)
+		test.Mixin = []string{commonHeadersName}
 
 		name := sanitize.Filename(e.Name) + ".ht"
 		suite.Main = append(suite.Main, struct{ File string }{name})
@@ -461,16 +441,10 @@ func DumpEvents(events []Event, directory string, suitename string) error {
 		if err != nil {
 			return err
 		}
-
-		e.Request.URL.Host = host
 		log.Println("Generate test for ", e.Request.Method, e.Request.URL, " --> ", filename)
 	}
 
-	name := strings.ToLower(strings.Replace(suitename, " ", "_", -1))
-	if !strings.HasSuffix(name, ".suite") {
-		name += ".suite"
-	}
-	filename := path.Join(directory, name)
+	filename := path.Join(directory, suiteFilename(suitename))
 	err = writeSuite(suite, filename)
 	if err != nil {
 		return err
@@ -480,6 +454,81 @@ func DumpEvents(events []Event, directory string, suitename string) error {
 	return nil
 }
 
+// test converts e to a Test. Cookies whose names are in jarred are
+// omitted if opts.KeepCookies is set, jarred is updated with the cookies
+// set by the response.
+func (opts DumpOptions) test(e Event, jarred map[string]bool) *Test {
+	host := e.Request.URL.Host
+	hostVar, parameterize := opts.HostVariables[host]
+	if parameterize {
+		e.Request.URL.Host = "H.O.S.T.N.A.M.E"
+	}
+	cookies := []ht.Cookie{}
+	for _, c := range e.Request.Cookies() {
+		if opts.KeepCookies && jarred[c.Name] {
+			continue
+		}
+		cookies = append(cookies, ht.Cookie{Name: c.Name, Value: c.Value})
+	}
+	e.Request.Header.Del("Cookie")
+	if opts.KeepCookies {
+		dummy := http.Response{Header: e.Response.Header()}
+		for _, c := range dummy.Cookies() {
+			jarred[c.Name] = true
+		}
+	}
+
+	// Inspect body and extract parameters if appropriate.
+	queryParams := e.Request.URL.Query()
+	rawQuery := e.Request.URL.RawQuery
+	e.Request.URL.RawQuery = "" // clear to prevent reparsing when body is analyzed
+	body, bodyParams, paramsAs := scanRequestBody(&e)
+
+	var params url.Values
+	if len(queryParams) > 0 && len(bodyParams) > 0 {
+		// Parameters in URL _and_ body: Must keep both
+		e.Request.URL.RawQuery = rawQuery
+		params = bodyParams
+	} else {
+		// Just one "type" of parameters.
+		if len(queryParams) > 0 {
+			params = queryParams
+			paramsAs = ""
+		} else {
+			params = bodyParams
+		}
+	}
+	if params == nil {
+		params = make(url.Values)
+	}
+	urlString := e.Request.URL.String()
+	if parameterize {
+		urlString = strings.Replace(urlString, "H.O.S.T.N.A.M.E", "{{"+hostVar+"}}", 1)
+	}
+
+	dropUnnecessaryHeaders(e.Request.Header)
+
+	checks := extractChecks(e)
+
+	test := &Test{
+		Name:        e.Name,
+		Description: fmt.Sprintf("Recorded from %s on %s", host, time.Now()),
+		Request: ht.Request{
+			Method:   e.Request.Method,
+			URL:      urlString,
+			Cookies:  cookies,
+			Header:   e.Request.Header,
+			Params:   params,
+			ParamsAs: paramsAs,
+			Body:     body,
+		},
+		Checks: checks,
+	}
+	e.Request.URL.Host = host
+
+	return test
+}
+
 func scanRequestBody(e *Event) (body string, params url.Values, as string) {
 	if len(e.RequestBody) == 0 {
 		return "", nil, ""
@@ -588,6 +637,10 @@ func extractChecks(e Event) ht.CheckList {
 			list = append(list, extractImageChecks(e)...)
 		case contentTypeParts[1] == "pdf":
 			list = append(list, identityCheck(e))
+		case contentTypeParts[1] == "json" || strings.HasSuffix(contentTypeParts[1], "+json"):
+			list = append(list, extractJSONChecks(e)...)
+		case contentType == "text/plain":
+			list = append(list, extractTextChecks(e)...)
 		}
 	}
 
@@ -665,6 +718,59 @@ func extractHTMLChecks(e Event) ht.CheckList {
 	return list
 }
 
+// maxAnchors is the maximal number of elements or lines of a JSON or text
+// body checked for existence.
+const maxAnchors = 3
+
+// extractJSONChecks checks that the JSON body is wellformed and contains
+// the first few members of a top-level object. The values are not checked
+// as they are likely to change (ids, timestamps).
+func extractJSONChecks(e Event) ht.CheckList {
+	list := ht.CheckList{&ht.JSON{}}
+
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(e.ResponseBody), &obj); err != nil {
+		return list
+	}
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		if key != "" && !strings.Contains(key, ".") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		if i == maxAnchors {
+			break
+		}
+		list = append(list, &ht.JSON{Element: key})
+	}
+	return list
+}
+
+// extractTextChecks checks that the first few nonempty lines of a plain
+// text body are present.
+func extractTextChecks(e Event) ht.CheckList {
+	list := ht.CheckList{}
+	for _, line := range strings.Split(e.ResponseBody, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if len(line) > 60 {
+			line = line[:60]
+			for !utf8.ValidString(line) {
+				line = line[:len(line)-1]
+			}
+		}
+		list = append(list, &ht.Body{Contains: line})
+		if len(list) == maxAnchors {
+			break
+		}
+	}
+	return list
+}
+
 func extractImageChecks(e Event) ht.CheckList {
 	list := ht.CheckList{}
 
@@ -0,0 +1,176 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scope
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+// Credential providers
+
+// CredentialProvider obtains credentials like tokens used in Authorization
+// headers from external sources.
+type CredentialProvider interface {
+	// Credential returns the credential for the given arguments and
+	// its expiry time. A zero expiry time means unknown.
+	Credential(args []string) (value string, expires time.Time, err error)
+}
+
+// CredentialTTL is the time credentials with unknown expiry are cached.
+var CredentialTTL = 5 * time.Minute
+
+// credentialMargin is the time before their expiry at which cached
+// credentials are renewed.
+const credentialMargin = 30 * time.Second
+
+// RegisterCredentialProvider makes p callable in substitutions as the
+// function name, e.g. {{GCP_ID_TOKEN https://example.org}}. Credentials
+// are obtained lazily on first use, cached per arguments until they expire
+// and are marked as secret. As they hand out the user's credentials name
+// is added to LocalFuncs.
+func RegisterCredentialProvider(name string, p CredentialProvider) {
+	RegisterFunc(name, func(args []string) (string, error) {
+		return credentials.get(name, p, args)
+	})
+	for _, local := range LocalFuncs {
+		if local == name {
+			return
+		}
+	}
+	LocalFuncs = append(LocalFuncs, name)
+}
+
+type cachedCredential struct {
+	value   string
+	expires time.Time
+}
+
+var credentials = &credentialCache{
+	cache: make(map[string]cachedCredential),
+}
+
+type credentialCache struct {
+	sync.Mutex
+	cache map[string]cachedCredential
+}
+
+func (cc *credentialCache) get(name string, p CredentialProvider, args []string) (string, error) {
+	key := name + "\x00" + strings.Join(args, "\x00")
+	cc.Lock()
+	defer cc.Unlock()
+	if c, ok := cc.cache[key]; ok && time.Now().Add(credentialMargin).Before(c.expires) {
+		return c.value, nil
+	}
+
+	value, expires, err := p.Credential(args)
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", name, err)
+	}
+	if expires.IsZero() {
+		expires = time.Now().Add(CredentialTTL)
+	}
+	MarkSecret(value)
+	cc.cache[key] = cachedCredential{value: value, expires: expires}
+	return value, nil
+}
+
+// ForgetCredentials drops all cached credentials.
+func ForgetCredentials() {
+	credentials.Lock()
+	credentials.cache = make(map[string]cachedCredential)
+	credentials.Unlock()
+}
+
+// CommandProvider is a CredentialProvider which runs a shell command and
+// uses its output (with surrounding whitespace trimmed) as credential. The
+// arguments are passed as positional parameters $1, $2, ... to the command.
+// The expiry of credentials which are JWTs is taken from their exp claim.
+type CommandProvider struct {
+	Command string
+}
+
+// Credential implements CredentialProvider.
+func (cp CommandProvider) Credential(args []string) (string, time.Time, error) {
+	if cp.Command == "" {
+		return "", time.Time{}, fmt.Errorf("no command configured")
+	}
+	cmd := exec.Command("sh", append([]string{"-c", cp.Command, "sh"}, args...)...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("%s: %s", err,
+			strings.TrimSpace(stderr.String()))
+	}
+	value := strings.TrimSpace(string(out))
+	if value == "" {
+		return "", time.Time{}, fmt.Errorf("empty credential")
+	}
+	return value, jwtExpiry(value), nil
+}
+
+// jwtExpiry returns the expiry time of token if it is a JWT with an exp
+// claim and the zero time otherwise. The signature is not verified.
+func jwtExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if json.Unmarshal(payload, &claims) != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.Exp, 0)
+}
+
+// The commands used by the predefined credential providers:
+//     {{KRB_TOKEN <service>}}       base64 SPNEGO token for service
+//     {{GCP_ID_TOKEN <audience>}}   Google Cloud identity token
+//     {{AZURE_TOKEN <resource>}}    Azure access token
+// Kerberos has no generally available command line tool to produce SPNEGO
+// tokens, so KRB_TOKEN uses the command from the environment variable
+// HT_KRB_TOKEN_COMMAND unless KerberosTokenCommand is set.
+var (
+	KerberosTokenCommand = ""
+	GCPIDTokenCommand    = `gcloud auth print-identity-token --audiences="$1"`
+	AzureTokenCommand    = `az account get-access-token --resource "$1" --query accessToken --output tsv`
+)
+
+// commandRef is a CredentialProvider running the command *cmd which may be
+// changed after registration.
+type commandRef struct {
+	cmd *string
+	env string // fallback environment variable if *cmd is empty
+}
+
+func (cr commandRef) Credential(args []string) (string, time.Time, error) {
+	command := *cr.cmd
+	if command == "" && cr.env != "" {
+		command = os.Getenv(cr.env)
+	}
+	return CommandProvider{Command: command}.Credential(args)
+}
+
+func init() {
+	RegisterCredentialProvider("KRB_TOKEN",
+		commandRef{&KerberosTokenCommand, "HT_KRB_TOKEN_COMMAND"})
+	RegisterCredentialProvider("GCP_ID_TOKEN", commandRef{cmd: &GCPIDTokenCommand})
+	RegisterCredentialProvider("AZURE_TOKEN", commandRef{cmd: &AzureTokenCommand})
+}
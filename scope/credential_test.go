@@ -0,0 +1,81 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scope
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type countingProvider struct {
+	calls   int
+	expires time.Time
+}
+
+func (cp *countingProvider) Credential(args []string) (string, time.Time, error) {
+	cp.calls++
+	if len(args) != 1 {
+		return "", time.Time{}, fmt.Errorf("need one argument")
+	}
+	return fmt.Sprintf("token-%s-%d", args[0], cp.calls), cp.expires, nil
+}
+
+func TestCredentialProvider(t *testing.T) {
+	defer ForgetCredentials()
+	cp := &countingProvider{}
+	defer func(local []string) { LocalFuncs = local }(LocalFuncs)
+	RegisterCredentialProvider("TEST_TOKEN", cp)
+	defer delete(FuncRegistry, "TEST_TOKEN")
+	r := Variables{"aud": "api"}.Replacer()
+
+	if !UsesLocalFuncs("{{TEST_TOKEN api}}") {
+		t.Errorf("Credential provider not a local function")
+	}
+
+	if got := r.Replace("Bearer {{TEST_TOKEN .aud}}"); got != "Bearer token-api-1" {
+		t.Errorf("Got %q", got)
+	}
+	if got := r.Replace("{{TEST_TOKEN api}} {{TEST_TOKEN web}}"); got != "token-api-1 token-web-2" {
+		t.Errorf("Got %q", got)
+	}
+	if !IsSecret("token-web-2") {
+		t.Errorf("Credential not marked as secret")
+	}
+	if got := r.Replace("{{TEST_TOKEN}}"); got != "{{TEST_TOKEN}}" {
+		t.Errorf("Got %q", got)
+	}
+
+	// Expired credentials are renewed.
+	ForgetCredentials()
+	cp.expires = time.Now().Add(10 * time.Second)
+	r.Replace("{{TEST_TOKEN api}}")
+	if got := r.Replace("{{TEST_TOKEN api}}"); got != "token-api-5" {
+		t.Errorf("Got %q", got)
+	}
+}
+
+func TestCommandProvider(t *testing.T) {
+	exp := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	payload := base64.RawURLEncoding.EncodeToString(
+		[]byte(fmt.Sprintf(`{"aud":"x","exp":%d}`, exp.Unix())))
+	jwt := "eyJhbGciOiJub25lIn0." + payload + ".sig"
+
+	value, expires, err := CommandProvider{Command: `echo "` + jwt + `"`}.Credential(nil)
+	if err != nil || value != jwt || !expires.Equal(exp) {
+		t.Errorf("Got %q, %s, %v", value, expires, err)
+	}
+
+	value, expires, err = CommandProvider{Command: `echo "tok-$1"`}.Credential([]string{"svc"})
+	if err != nil || value != "tok-svc" || !expires.IsZero() {
+		t.Errorf("Got %q, %s, %v", value, expires, err)
+	}
+
+	_, _, err = CommandProvider{Command: `echo oops >&2; exit 1`}.Credential(nil)
+	if err == nil || err.Error() != "exit status 1: oops" {
+		t.Errorf("Got %v", err)
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scope
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ----------------------------------------------------------------------------
+// Arithmetic expressions
+
+// Arithmetic expressions are evaluated by the function "=", e.g.
+// {{= {{BASEPORT}} + 10}} or {{= ({{A}} + 1) * 2}}. Expressions consist of
+// integer and floating point numbers, the binary operators + - * / % and
+// parentheses. Integer operands produce integer results (with truncating
+// division), as soon as a floating point number is involved the result is
+// a float. Integer overflow is an error.
+
+// evaluate returns the result of the arithmetic expression s.
+func evaluate(s string) (string, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return "", err
+	}
+	p := &exprParser{tokens: tokens}
+	n, err := p.sum()
+	if err != nil {
+		return "", err
+	}
+	if p.pos != len(tokens) {
+		return "", fmt.Errorf("unexpected %q in expression %q", p.peek(), s)
+	}
+	return n.String(), nil
+}
+
+// number is an integer or a float.
+type number struct {
+	isInt bool
+	i     int64
+	f     float64
+}
+
+func (n number) float() float64 {
+	if n.isInt {
+		return float64(n.i)
+	}
+	return n.f
+}
+
+func (n number) String() string {
+	if n.isInt {
+		return strconv.FormatInt(n.i, 10)
+	}
+	return strconv.FormatFloat(n.f, 'f', -1, 64)
+}
+
+type token struct {
+	op  byte // one of "+-*/%()" or 0 for a number
+	num number
+}
+
+func tokenize(s string) ([]token, error) {
+	tokens := []token{}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case strings.IndexByte("+-*/%()", c) >= 0:
+			tokens = append(tokens, token{op: c})
+			i++
+		case c >= '0' && c <= '9' || c == '.':
+			j := i
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.') {
+				j++
+			}
+			lit := s[i:j]
+			if v, err := strconv.ParseInt(lit, 10, 64); err == nil {
+				tokens = append(tokens, token{num: number{isInt: true, i: v}})
+			} else if !strings.Contains(lit, ".") {
+				return nil, fmt.Errorf("integer %s overflows", lit)
+			} else if f, err := strconv.ParseFloat(lit, 64); err == nil {
+				tokens = append(tokens, token{num: number{f: f}})
+			} else {
+				return nil, fmt.Errorf("bad number %q", lit)
+			}
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected %q in expression", c)
+		}
+	}
+	return tokens, nil
+}
+
+// exprParser is a recursive descent parser for arithmetic expressions.
+type exprParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *exprParser) peek() byte {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos].op
+	}
+	return ';'
+}
+
+// sum = product { ("+" | "-") product }
+func (p *exprParser) sum() (number, error) {
+	a, err := p.product()
+	for err == nil && (p.peek() == '+' || p.peek() == '-') {
+		op := p.operator()
+		var b number
+		if b, err = p.product(); err == nil {
+			a, err = apply(op, a, b)
+		}
+	}
+	return a, err
+}
+
+// product = unary { ("*" | "/" | "%") unary }
+func (p *exprParser) product() (number, error) {
+	a, err := p.unary()
+	for err == nil && (p.peek() == '*' || p.peek() == '/' || p.peek() == '%') {
+		op := p.operator()
+		var b number
+		if b, err = p.unary(); err == nil {
+			a, err = apply(op, a, b)
+		}
+	}
+	return a, err
+}
+
+// operator consumes a binary operator.
+func (p *exprParser) operator() byte {
+	p.pos++
+	return p.tokens[p.pos-1].op
+}
+
+// unary = [ "-" ] ( number | "(" sum ")" )
+func (p *exprParser) unary() (number, error) {
+	if p.peek() == '-' {
+		p.pos++
+		n, err := p.unary()
+		if err != nil {
+			return n, err
+		}
+		if n.isInt && n.i == math.MinInt64 {
+			return n, errOverflow
+		}
+		n.i, n.f = -n.i, -n.f
+		return n, nil
+	}
+	if p.peek() == '(' {
+		p.pos++
+		n, err := p.sum()
+		if err != nil {
+			return n, err
+		}
+		if p.peek() != ')' {
+			return n, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return n, nil
+	}
+	if p.pos == len(p.tokens) {
+		return number{}, fmt.Errorf("unexpected end of expression")
+	}
+	if p.peek() != 0 {
+		return number{}, fmt.Errorf("unexpected %q", p.peek())
+	}
+	p.pos++
+	return p.tokens[p.pos-1].num, nil
+}
+
+var errOverflow = errors.New("integer overflow")
+
+func apply(op byte, a, b number) (number, error) {
+	if a.isInt && b.isInt {
+		x, y := a.i, b.i
+		var r int64
+		switch op {
+		case '+':
+			r = x + y
+			if (y > 0 && r < x) || (y < 0 && r > x) {
+				return number{}, errOverflow
+			}
+		case '-':
+			r = x - y
+			if (y > 0 && r > x) || (y < 0 && r < x) {
+				return number{}, errOverflow
+			}
+		case '*':
+			r = x * y
+			if x != 0 && (r/x != y || (x == -1 && y == math.MinInt64)) {
+				return number{}, errOverflow
+			}
+		case '/', '%':
+			if y == 0 {
+				return number{}, fmt.Errorf("division by zero")
+			}
+			if x == math.MinInt64 && y == -1 {
+				if op == '%' {
+					return number{isInt: true}, nil
+				}
+				return number{}, errOverflow
+			}
+			if op == '/' {
+				r = x / y
+			} else {
+				r = x % y
+			}
+		}
+		return number{isInt: true, i: r}, nil
+	}
+	x, y := a.float(), b.float()
+	switch op {
+	case '+':
+		return number{f: x + y}, nil
+	case '-':
+		return number{f: x - y}, nil
+	case '*':
+		return number{f: x * y}, nil
+	case '/':
+		if y == 0 {
+			return number{}, fmt.Errorf("division by zero")
+		}
+		return number{f: x / y}, nil
+	}
+	return number{}, fmt.Errorf("bad operator %q for floats", op)
+}
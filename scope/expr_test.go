@@ -0,0 +1,78 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scope
+
+import "testing"
+
+func TestEvaluate(t *testing.T) {
+	for i, tc := range []struct {
+		in   string
+		want string // empty: error
+	}{
+		{"8000 + 10", "8010"},
+		{"2 + 3 * 4", "14"},
+		{"(2 + 3) * 4", "20"},
+		{"7 / 2", "3"},
+		{"7 % 4", "3"},
+		{"7.0 / 2", "3.5"},
+		{"1.5 + 1.5", "3"},
+		{"10 - -5", "15"},
+		{"-3 * 2", "-6"},
+		{"10/20", "0"},
+		{"1 + 2-3", "0"},
+		{"8080", "8080"},
+		{"-5", "-5"},
+		{"9223372036854775806 + 1", "9223372036854775807"},
+		{"a + b", ""},
+		{"1 / 0", ""},
+		{"1 +", ""},
+		{"(1 + 2", ""},
+		{"1.5 % 2", ""},
+		{"9223372036854775807 + 1", ""},
+		{"-9223372036854775807 - 2", ""},
+		{"4611686018427387904 * 2", ""},
+		{"(-9223372036854775807 - 1) / -1", ""},
+		{"-(-9223372036854775807 - 1)", ""},
+		{"9223372036854775808", ""},
+	} {
+		got, err := evaluate(tc.in)
+		if tc.want == "" {
+			if err == nil {
+				t.Errorf("%d. %q: unexpected result %q", i, tc.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("%d. %q: got %q (%v), want %q", i, tc.in, got, err, tc.want)
+		}
+	}
+}
+
+func TestExpressionsInScope(t *testing.T) {
+	outer := Variables{"BASEPORT": "8000", "NAME": "World", "DATE": "2017-03-04"}
+	inner := Variables{
+		"PORT":     "{{= {{BASEPORT}} + 10}}",
+		"GREETING": "Hello {{NAME | upper}}!",
+		"SLUG":     "{{NAME | lower | len}}",
+		"DAY":      "{{DATE}}",
+		"RATIO":    "{{= {{BASEPORT}} / 3.2}}",
+		"PLAIN":    "{{BASEPORT}} + 10",
+		"BIG":      "{{= 9223372036854775807 * 2}}",
+	}
+	s := New(outer, inner, false)
+	for name, want := range map[string]string{
+		"PORT":     "8010",
+		"GREETING": "Hello WORLD!",
+		"SLUG":     "5",
+		"DAY":      "2017-03-04",
+		"RATIO":    "2500",
+		"PLAIN":    "8000 + 10",
+		"BIG":      "{{= 9223372036854775807 * 2}}",
+	} {
+		if got := s[name]; got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scope
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ----------------------------------------------------------------------------
+// Replacer
+
+// Replacer replaces variables and function calls in strings:
+//   - {{name}} is replaced by the value of the variable name.
+//   - {{NAME arg1 arg2 ...}} is replaced by the result of calling the
+//     function NAME from FuncRegistry with the whitespace separated
+//     arguments. Arguments may contain variables and function calls
+//     themselves, e.g. {{SHA256 {{BODY}}}}, and can reference the value
+//     of a variable v as .v like in {{BASE64 .user:.pass}}.
+// Variables take precedence over functions of the same name. Calls to
+// unknown functions and calls which fail are not replaced.
+type Replacer struct {
+	vars Variables
+}
+
+// Replace returns a copy of s with all variables and function calls
+// replaced.
+func (r *Replacer) Replace(s string) string {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+	buf := &bytes.Buffer{}
+	for i := 0; i < len(s); {
+		k := strings.Index(s[i:], "{{")
+		if k < 0 {
+			buf.WriteString(s[i:])
+			break
+		}
+		buf.WriteString(s[i : i+k])
+		i += k
+		if val, n, ok := r.expand(s[i+2:]); ok {
+			buf.WriteString(val)
+			i += 2 + n
+			continue
+		}
+		buf.WriteString("{{")
+		i += 2
+	}
+	return buf.String()
+}
+
+// expand the variable or function call at the start of s which directly
+// follows a "{{". It returns the replacement and the number of bytes
+// consumed from s including the closing "}}".
+func (r *Replacer) expand(s string) (string, int, bool) {
+	if j := strings.Index(s, "}}"); j >= 0 {
+		if val, ok := r.vars[s[:j]]; ok {
+			return val, j + 2, true
+		}
+		if inner := s[:j]; strings.Contains(inner, "|") && !strings.Contains(inner, "{{") {
+			val, ok := r.pipe(inner)
+			return val, j + 2, ok
+		}
+	}
+	return r.call(s)
+}
+
+// pipe evaluates a pipeline like "NAME | lower | BASE64": The value of the
+// first element (a variable or a function call) is passed as the last
+// argument to the function given by the next element and so on.
+func (r *Replacer) pipe(s string) (string, bool) {
+	parts := strings.Split(s, "|")
+	head := strings.TrimSpace(parts[0])
+	val, ok := r.vars[head]
+	if !ok {
+		var n int
+		val, n, ok = r.call(head + "}}")
+		if !ok || n != len(head)+2 {
+			return "", false
+		}
+	}
+	for _, part := range parts[1:] {
+		args := strings.Fields(part)
+		if len(args) == 0 {
+			return "", false
+		}
+		f, ok := FuncRegistry[args[0]]
+		if !ok {
+			return "", false
+		}
+		var err error
+		val, err = f(append(args[1:], val))
+		if err != nil {
+			return "", false
+		}
+	}
+	return val, true
+}
+
+// call evaluates the function call at the start of s.
+func (r *Replacer) call(s string) (string, int, bool) {
+	end := strings.IndexAny(s, " \t\n}")
+	if end <= 0 {
+		return "", 0, false
+	}
+	f, ok := FuncRegistry[s[:end]]
+	if !ok {
+		return "", 0, false
+	}
+
+	args := []string{}
+	if frozen, ok := r.vars[FrozenNow]; ok && s[:end] == "NOW" {
+		args = append(args, "@"+frozen) // a later anchor still wins
+	}
+	arg, inArg := &bytes.Buffer{}, false
+	flush := func() {
+		if inArg {
+			args = append(args, arg.String())
+			arg.Reset()
+			inArg = false
+		}
+	}
+	for i := end; i < len(s); {
+		switch c := s[i]; {
+		case strings.HasPrefix(s[i:], "}}"):
+			flush()
+			result, err := f(args)
+			if err != nil {
+				return "", 0, false
+			}
+			return result, i + 2, true
+		case strings.HasPrefix(s[i:], "{{"):
+			if val, n, ok := r.expand(s[i+2:]); ok {
+				arg.WriteString(val)
+				i += 2 + n
+			} else {
+				arg.WriteString("{{")
+				i += 2
+			}
+			inArg = true
+		case c == ' ' || c == '\t' || c == '\n':
+			flush()
+			i++
+		case c == '.':
+			name := identifier(s[i+1:])
+			if val, ok := r.vars[name]; ok && name != "" {
+				arg.WriteString(val)
+				i += 1 + len(name)
+			} else {
+				arg.WriteByte(c)
+				i++
+			}
+			inArg = true
+		default:
+			arg.WriteByte(c)
+			inArg = true
+			i++
+		}
+	}
+	return "", 0, false // unterminated call
+}
+
+// identifier returns the leading letters, digits and underscores of s.
+func identifier(s string) string {
+	for i, c := range s {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// ----------------------------------------------------------------------------
+// Functions
+
+// Func is a function which can be called in substitutions.
+type Func func(args []string) (string, error)
+
+// FuncRegistry contains all functions callable in substitutions indexed
+// by name. The following functions are predefined:
+//     UUID                   a random UUID (version 4)
+//     RANDOM                 a random number with 6 digits
+//     RANDOM int <min> <max> a random number from [min,max]
+//     RANDOM hex <n>         n random hex digits
+//     RANDOM digits <n>      n random decimal digits
+//     RANDOM alpha <n>       n random letters and digits
+//     BASE64 <arg>...        the base64 encoding of the arguments
+//     SHA256 <arg>...        the hex encoded SHA-256 of the arguments
+//     ENV <name>             the value of the environment variable name
+//     FILE <path>            the content of the file path
+//     NOW [@<anchor>] [tz:<zone>] [<offset>|^<unit>]... [<layout>]
+//                            the current time (or the anchor time) in zone,
+//                            shifted by offsets like +2h, +3d or -2bd
+//                            (business days) and truncated to the start of
+//                            ^day, ^week, ^month etc. formated according to
+//                            layout (default RFC1123), see nowFunc; the
+//                            variable FROZEN_NOW replaces the current time
+//     = <expression>         the value of the arithmetic expression, e.g.
+//                            {{= {{PORT}} + 1}}, see evaluate
+// Multiple arguments to BASE64 and SHA256 are joined by a single space.
+// The credential providers KRB_TOKEN, GCP_ID_TOKEN and AZURE_TOKEN are
+// registered too, see RegisterCredentialProvider.
+// The following functions are meant to be used in pipelines like
+// {{NAME | upper}} which pass the value as the last argument:
+//     upper, lower, title   change case
+//     trim                  remove leading and trailing whitespace
+//     urlquery              escape for use in an URL query
+//     len                   number of characters
+var FuncRegistry = make(map[string]Func)
+
+// LocalFuncs are the functions in FuncRegistry which read from the local
+// machine: ENV, FILE and all credential providers. Files of untrusted
+// origin must not call them, see UsesLocalFuncs.
+var LocalFuncs = []string{"ENV", "FILE"}
+
+// UsesLocalFuncs reports whether s calls one of the LocalFuncs, either
+// directly like in {{ENV HOME}} or in a pipeline like in {{NAME | FILE}}.
+// A variable shadowing such a function is reported as a call too.
+func UsesLocalFuncs(s string) bool {
+	for i := strings.Index(s, "{{"); i >= 0; i = strings.Index(s, "{{") {
+		s = s[i+2:]
+		inner := s
+		if j := strings.Index(inner, "}}"); j >= 0 {
+			inner = inner[:j]
+		}
+		if k := strings.Index(inner, "{{"); k >= 0 {
+			inner = inner[:k] // nested calls are checked on their own
+		}
+		for _, part := range strings.Split(inner, "|") {
+			fields := strings.Fields(part)
+			if len(fields) == 0 {
+				continue
+			}
+			for _, name := range LocalFuncs {
+				if fields[0] == name {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// RegisterFunc registers f under name in FuncRegistry. Registering an
+// existing name replaces the function.
+func RegisterFunc(name string, f Func) {
+	FuncRegistry[name] = f
+}
+
+func init() {
+	RegisterFunc("UUID", uuidFunc)
+	RegisterFunc("RANDOM", randomFunc)
+	RegisterFunc("BASE64", func(args []string) (string, error) {
+		return base64.StdEncoding.EncodeToString([]byte(strings.Join(args, " "))), nil
+	})
+	RegisterFunc("SHA256", func(args []string) (string, error) {
+		sum := sha256.Sum256([]byte(strings.Join(args, " ")))
+		return hex.EncodeToString(sum[:]), nil
+	})
+	RegisterFunc("ENV", func(args []string) (string, error) {
+		if len(args) != 1 {
+			return "", fmt.Errorf("ENV needs exactly one argument")
+		}
+		return os.Getenv(args[0]), nil
+	})
+	RegisterFunc("FILE", func(args []string) (string, error) {
+		if len(args) != 1 {
+			return "", fmt.Errorf("FILE needs exactly one argument")
+		}
+		data, err := ioutil.ReadFile(args[0])
+		return string(data), err
+	})
+	RegisterFunc("NOW", nowFunc)
+	RegisterFunc("=", func(args []string) (string, error) {
+		return evaluate(strings.Join(args, " "))
+	})
+
+	stringFunc := func(f func(string) string) Func {
+		return func(args []string) (string, error) {
+			return f(strings.Join(args, " ")), nil
+		}
+	}
+	RegisterFunc("upper", stringFunc(strings.ToUpper))
+	RegisterFunc("lower", stringFunc(strings.ToLower))
+	RegisterFunc("title", stringFunc(strings.Title))
+	RegisterFunc("trim", stringFunc(strings.TrimSpace))
+	RegisterFunc("urlquery", stringFunc(url.QueryEscape))
+	RegisterFunc("len", stringFunc(func(s string) string {
+		return strconv.Itoa(utf8.RuneCountInString(s))
+	}))
+}
+
+func uuidFunc(args []string) (string, error) {
+	b := make([]byte, 16)
+	randMux.Lock()
+	Random.Read(b)
+	randMux.Unlock()
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // variant RFC 4122
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// RandomNumber returns a random number with 6 digits, the value of the
+// automatic RANDOM variable.
+func RandomNumber() string {
+	return strconv.Itoa(100000 + RandomIntn(900000))
+}
+
+const (
+	hexDigits   = "0123456789abcdef"
+	decDigits   = "0123456789"
+	alphaDigits = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+func randomFunc(args []string) (string, error) {
+	if len(args) == 0 {
+		return RandomNumber(), nil
+	}
+	switch args[0] {
+	case "int":
+		if len(args) != 3 {
+			return "", fmt.Errorf("RANDOM int needs min and max")
+		}
+		min, err := strconv.Atoi(args[1])
+		if err != nil {
+			return "", err
+		}
+		max, err := strconv.Atoi(args[2])
+		if err != nil {
+			return "", err
+		}
+		if max < min {
+			return "", fmt.Errorf("RANDOM int: max %d < min %d", max, min)
+		}
+		return strconv.Itoa(min + RandomIntn(max-min+1)), nil
+	case "hex", "digits", "alpha":
+		if len(args) != 2 {
+			return "", fmt.Errorf("RANDOM %s needs a length", args[0])
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			return "", fmt.Errorf("RANDOM %s: bad length %q", args[0], args[1])
+		}
+		alphabet := map[string]string{
+			"hex": hexDigits, "digits": decDigits, "alpha": alphaDigits,
+		}[args[0]]
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = alphabet[RandomIntn(len(alphabet))]
+		}
+		return string(b), nil
+	}
+	return "", fmt.Errorf("RANDOM: unknown kind %q", args[0])
+}
@@ -0,0 +1,142 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scope
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestReplacer(t *testing.T) {
+	os.Setenv("HT_SCOPE_TEST", "from env")
+	defer os.Unsetenv("HT_SCOPE_TEST")
+	dir, err := ioutil.TempDir("", "scope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	payload := filepath.Join(dir, "payload.json")
+	ioutil.WriteFile(payload, []byte(`{"a": 1}`), 0644)
+
+	vars := Variables{
+		"user":   "alice",
+		"pass":   "secret",
+		"BODY":   "Hello World",
+		"RANDOM": "123456",
+		"FILE":   payload,
+		"a b":    "space",
+	}
+	r := vars.Replacer()
+
+	for i, tc := range []struct {
+		in, want string
+	}{
+		{"no vars", "no vars"},
+		{"{{user}}:{{pass}}", "alice:secret"},
+		{"{{a b}}", "space"},
+		{"{{RANDOM}}", "123456"},
+		{"{{BASE64 .user:.pass}}", "YWxpY2U6c2VjcmV0"},
+		{"{{BASE64 {{user}}:{{pass}}}}", "YWxpY2U6c2VjcmV0"},
+		{"{{SHA256 {{BODY}}}}", "a591a6d40bf420404a011733cfb7b190d62c65bf0bcda32b57b277d9ad9f146e"},
+		{"x{{ENV HT_SCOPE_TEST}}y", "xfrom envy"},
+		{"{{FILE {{FILE}}}}", `{"a": 1}`},
+		{"{{RANDOM int 7 7}}", "7"},
+		{"{{NOW 2006}}" + "{{NOW +0s 2006}}", ""},
+		{"{{unknown}} {{NOPE x}} {{FILE /no/such/file}}", "{{unknown}} {{NOPE x}} {{FILE /no/such/file}}"},
+		{"{{BASE64 x", "{{BASE64 x"},
+		{"{{.user}}", "{{.user}}"},
+		{"{{user | upper}}", "ALICE"},
+		{"{{ user | upper | BASE64 }}", "QUxJQ0U="},
+		{"{{ENV HT_SCOPE_TEST | title}}", "From Env"},
+		{"{{user | nope}} {{nobody | upper}}", "{{user | nope}} {{nobody | upper}}"},
+	} {
+		got := r.Replace(tc.in)
+		if tc.want == "" {
+			if !regexp.MustCompile(`^\d{8}$`).MatchString(got) {
+				t.Errorf("%d. %q: got %q", i, tc.in, got)
+			}
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%d. %q: got %q, want %q", i, tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestRandomFuncs(t *testing.T) {
+	r := Variables{}.Replacer()
+	for i, tc := range []struct {
+		in   string
+		want string
+	}{
+		{"{{UUID}}", `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+		{"{{RANDOM}}", `^[1-9]\d{5}$`},
+		{"{{RANDOM hex 16}}", `^[0-9a-f]{16}$`},
+		{"{{RANDOM digits 4}}", `^\d{4}$`},
+		{"{{RANDOM alpha 10}}", `^[A-Za-z0-9]{10}$`},
+		{"{{RANDOM int 10 20}}", `^(1\d|20)$`},
+		{"{{RANDOM foo 3}}", `^\{\{RANDOM foo 3\}\}$`},
+	} {
+		if got := r.Replace(tc.in); !regexp.MustCompile(tc.want).MatchString(got) {
+			t.Errorf("%d. %q: got %q", i, tc.in, got)
+		}
+	}
+}
+
+func TestSeed(t *testing.T) {
+	r := Variables{}.Replacer()
+	in := "{{UUID}} {{RANDOM}} {{RANDOM hex 8}} {{RANDOM alpha 12}}"
+	Seed(42)
+	first := r.Replace(in)
+	Seed(42)
+	if second := r.Replace(in); second != first {
+		t.Errorf("Same seed, different values:\n%s\n%s", first, second)
+	}
+	Seed(43)
+	if other := r.Replace(in); other == first {
+		t.Errorf("Different seed, same values: %s", other)
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	RegisterFunc("JOIN", func(args []string) (string, error) {
+		s := ""
+		for _, a := range args {
+			s += "<" + a + ">"
+		}
+		return s, nil
+	})
+	defer delete(FuncRegistry, "JOIN")
+
+	r := Variables{"v": "a b"}.Replacer()
+	if got := r.Replace("{{JOIN x  {{v}}\n.v}}"); got != "<x><a b><a b>" {
+		t.Errorf("Got %q", got)
+	}
+}
+
+func TestUsesLocalFuncs(t *testing.T) {
+	for i, tc := range []struct {
+		s    string
+		want bool
+	}{
+		{"{{ENV HOME}}", true},
+		{"x {{ FILE /etc/passwd }} y", true},
+		{"{{SHA256 {{FILE key}}}}", true},
+		{"{{NAME | lower | ENV}}", true},
+		{"{{ENVIRONMENT}} {{HOST}}", false},
+		{"ENV FILE {{UUID}} a|FILE", false},
+		{"{{BASE64 .ENV}}", false},
+		{"{{GCP_ID_TOKEN https://example.org}}", true},
+		{"{{AUD | AZURE_TOKEN}}", true},
+		{"{{KRB_TOKEN HTTP@example.org}}", true},
+	} {
+		if got := UsesLocalFuncs(tc.s); got != tc.want {
+			t.Errorf("%d. %q: got %t", i, tc.s, got)
+		}
+	}
+}
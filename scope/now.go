@@ -0,0 +1,187 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scope
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+// NOW
+
+// Now returns the current time used by the NOW function. It can be replaced
+// to produce deterministic results.
+var Now = time.Now
+
+// FrozenNow is the name of the variable which freezes the time: If it is
+// set (to a time in one of the AnchorLayouts) the NOW function uses its
+// value instead of Now() unless an explicit anchor is given.
+const FrozenNow = "FROZEN_NOW"
+
+// AnchorLayouts are the layouts tried in order to parse the anchor time
+// given to NOW as @<time>.
+var AnchorLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// nowLayouts are named layouts usable in NOW.
+var nowLayouts = map[string]string{
+	"RFC1123":  time.RFC1123,
+	"RFC1123Z": time.RFC1123Z,
+	"RFC3339":  time.RFC3339,
+	"RFC822":   time.RFC822,
+	"Kitchen":  time.Kitchen,
+}
+
+// nowFunc implements
+//     NOW [@<anchor>] [tz:<zone>] [<offset>|^<unit>]... [<layout>]
+// The time (Now or the anchor) is converted to the time zone and the
+// offsets and truncations are applied in the given order. An offset is
+// either a Go duration like +90m or a number followed by one of the
+// calendar units d (days), w (weeks), M (months), y (years) or bd
+// (business days, i.e. Monday to Friday) like -3bd. Truncations ^hour,
+// ^day, ^week (to Monday), ^month and ^year go to the start of the unit.
+// The layout is a Go time layout, one of the names in nowLayouts or
+// "unix" for seconds since epoch.
+func nowFunc(args []string) (string, error) {
+	t := Now()
+	for len(args) > 0 && args[0] != "" {
+		arg := args[0]
+		switch {
+		case arg[0] == '@':
+			anchor, err := parseAnchor(arg[1:])
+			if err != nil {
+				return "", err
+			}
+			t = anchor
+		case strings.HasPrefix(arg, "tz:"):
+			loc, err := time.LoadLocation(arg[3:])
+			if err != nil {
+				return "", fmt.Errorf("NOW: %s", err)
+			}
+			t = t.In(loc)
+		case arg[0] == '+' || arg[0] == '-':
+			var err error
+			t, err = addOffset(t, arg)
+			if err != nil {
+				return "", err
+			}
+		case arg[0] == '^':
+			var err error
+			t, err = truncate(t, arg[1:])
+			if err != nil {
+				return "", err
+			}
+		default:
+			return formatNow(t, strings.Join(args, " ")), nil
+		}
+		args = args[1:]
+	}
+	return formatNow(t, ""), nil
+}
+
+func parseAnchor(s string) (time.Time, error) {
+	t, err := ParseTime(s)
+	if err != nil {
+		return t, fmt.Errorf("NOW: %s", err)
+	}
+	return t, nil
+}
+
+// ParseTime parses s in one of the AnchorLayouts.
+func ParseTime(s string) (time.Time, error) {
+	for _, layout := range AnchorLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse anchor time %q", s)
+}
+
+func formatNow(t time.Time, layout string) string {
+	switch layout {
+	case "":
+		layout = time.RFC1123
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	default:
+		if named, ok := nowLayouts[layout]; ok {
+			layout = named
+		}
+	}
+	return t.Format(layout)
+}
+
+// addOffset adds the signed offset s to t. Calendar units are handled
+// by time.AddDate so that e.g. +1d keeps the wall clock time across
+// daylight saving time changes.
+func addOffset(t time.Time, s string) (time.Time, error) {
+	for _, unit := range []string{"bd", "d", "w", "M", "y"} {
+		if !strings.HasSuffix(s, unit) {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(s, unit))
+		if err != nil {
+			break
+		}
+		switch unit {
+		case "bd":
+			return addBusinessDays(t, n), nil
+		case "d":
+			return t.AddDate(0, 0, n), nil
+		case "w":
+			return t.AddDate(0, 0, 7*n), nil
+		case "M":
+			return t.AddDate(0, n, 0), nil
+		case "y":
+			return t.AddDate(n, 0, 0), nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return t, fmt.Errorf("NOW: bad offset %q", s)
+	}
+	return t.Add(d), nil
+}
+
+// addBusinessDays moves t by n days skipping Saturdays and Sundays.
+func addBusinessDays(t time.Time, n int) time.Time {
+	step := 1
+	if n < 0 {
+		step, n = -1, -n
+	}
+	for n > 0 {
+		t = t.AddDate(0, 0, step)
+		if wd := t.Weekday(); wd != time.Saturday && wd != time.Sunday {
+			n--
+		}
+	}
+	return t
+}
+
+// truncate t to the start of the given unit.
+func truncate(t time.Time, unit string) (time.Time, error) {
+	y, m, d := t.Date()
+	loc := t.Location()
+	switch unit {
+	case "hour":
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, loc), nil
+	case "day":
+		return time.Date(y, m, d, 0, 0, 0, 0, loc), nil
+	case "week":
+		offset := (int(t.Weekday()) + 6) % 7 // days since Monday
+		return time.Date(y, m, d-offset, 0, 0, 0, 0, loc), nil
+	case "month":
+		return time.Date(y, m, 1, 0, 0, 0, 0, loc), nil
+	case "year":
+		return time.Date(y, 1, 1, 0, 0, 0, 0, loc), nil
+	}
+	return t, fmt.Errorf("NOW: cannot truncate to %q", unit)
+}
@@ -0,0 +1,80 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scope
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNow(t *testing.T) {
+	// Friday, 2017-03-24 15:30:00 UTC
+	Now = func() time.Time { return time.Date(2017, 3, 24, 15, 30, 0, 0, time.UTC) }
+	defer func() { Now = time.Now }()
+
+	for i, tc := range []struct {
+		args string
+		want string
+	}{
+		{"", "Fri, 24 Mar 2017 15:30:00 UTC"},
+		{"2006-01-02", "2017-03-24"},
+		{"RFC3339", "2017-03-24T15:30:00Z"},
+		{"unix", "1490369400"},
+		{"+90m 15:04", "17:00"},
+		{"+1d 2006-01-02", "2017-03-25"},
+		{"-2w 2006-01-02", "2017-03-10"},
+		{"+1M 2006-01-02", "2017-04-24"},
+		{"-1y 2006-01-02", "2016-03-24"},
+		{"+1bd 2006-01-02", "2017-03-27"},
+		{"+6bd 2006-01-02", "2017-04-03"},
+		{"-5bd 2006-01-02", "2017-03-17"},
+		{"^day RFC3339", "2017-03-24T00:00:00Z"},
+		{"^week 2006-01-02", "2017-03-20"},
+		{"^month +1M -1d 2006-01-02", "2017-03-31"},
+		{"^year RFC3339", "2017-01-01T00:00:00Z"},
+		{"^hour 15:04:05", "15:00:00"},
+		{"tz:Asia/Tokyo 2006-01-02 15:04 MST", "2017-03-25 00:30 JST"},
+		{"tz:Asia/Tokyo ^day RFC3339", "2017-03-25T00:00:00+09:00"},
+		{"@2016-02-29 +1y 2006-01-02", "2017-03-01"},
+		{"@2017-01-07T10:00:00 +1bd Mon 2006-01-02", "Mon 2017-01-09"},
+		// Calendar days keep the wall clock across DST changes.
+		{"@2017-03-25T12:00:00+01:00 tz:Europe/Berlin +1d 15:04", "12:00"},
+		{"@2017-03-25T12:00:00+01:00 tz:Europe/Berlin +24h 15:04", "13:00"},
+	} {
+		got, err := nowFunc(strings.Fields(tc.args))
+		if err != nil {
+			t.Errorf("%d. NOW %s: unexpected error %s", i, tc.args, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%d. NOW %s: got %q, want %q", i, tc.args, got, tc.want)
+		}
+	}
+
+	r := Variables{"START": "2017-12-29"}.Replacer()
+	if got := r.Replace("{{NOW @.START +1bd 2006-01-02}}"); got != "2018-01-01" {
+		t.Errorf("Anchor from variable: got %q", got)
+	}
+
+	frozen := Variables{FrozenNow: "2016-07-01T08:00:00Z", "START": "2017-12-29"}.Replacer()
+	for i, tc := range []struct {
+		in, want string
+	}{
+		{"{{NOW RFC3339}}", "2016-07-01T08:00:00Z"},
+		{"{{NOW +1d 2006-01-02}}", "2016-07-02"},
+		{"{{NOW @.START 2006-01-02}}", "2017-12-29"},
+	} {
+		if got := frozen.Replace(tc.in); got != tc.want {
+			t.Errorf("%d. Frozen %s: got %q, want %q", i, tc.in, got, tc.want)
+		}
+	}
+
+	for i, args := range []string{"@tomorrow", "tz:Mars/Olympus", "+3x", "^decade"} {
+		if _, err := nowFunc(strings.Fields(args)); err == nil {
+			t.Errorf("%d. NOW %s: missing error", i, args)
+		}
+	}
+}
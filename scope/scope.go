@@ -8,22 +8,16 @@ package scope
 import (
 	"math/rand"
 	"strconv"
-	"strings"
 	"sync"
 )
 
 // Variables represents a set of (variable-name, variable-value)-pairs.
 type Variables map[string]string
 
-// Replacer to replace {{name}} pattern with the given values from vars.
-func (vars Variables) Replacer() *strings.Replacer {
-	oldnew := []string{}
-	for k, v := range vars {
-		oldnew = append(oldnew, "{{"+k+"}}") // TODO: make configurable ??
-		oldnew = append(oldnew, v)
-	}
-
-	return strings.NewReplacer(oldnew...)
+// Replacer to replace {{name}} pattern with the given values from vars
+// and function calls like {{NAME arg...}} with their result.
+func (vars Variables) Replacer() *Replacer {
+	return &Replacer{vars: vars}
 }
 
 // Copy returns a copy of vars.
@@ -42,7 +36,8 @@ func (vars Variables) Copy() Variables {
 // of default which gets overwriten from the outside.
 //
 // Variable values in the inner scope may reference values from the
-// outer scope.
+// outer scope and may call functions, e.g. "{{= {{PORT}} + 1}}" yields
+// "8081" if PORT is "8080" in the outer scope.
 //
 // If auto variables are requested the new scope will contain the COUNTER
 // and RANDOM variable.
@@ -54,7 +49,7 @@ func New(outer, inner Variables, auto bool) Variables {
 	}
 	if auto {
 		scope["COUNTER"] = strconv.Itoa(<-GetCounter)
-		scope["RANDOM"] = strconv.Itoa(100000 + RandomIntn(900000))
+		scope["RANDOM"] = RandomNumber()
 	}
 	replacer := scope.Replacer()
 
@@ -65,7 +60,8 @@ func New(outer, inner Variables, auto bool) Variables {
 			// overwrite with suite defaults.
 			continue
 		}
-		scope[name] = replacer.Replace(val)
+		val = replacer.Replace(val)
+		scope[name] = val
 	}
 
 	return scope
@@ -74,7 +70,9 @@ func New(outer, inner Variables, auto bool) Variables {
 // ----------------------------------------------------------------------------
 // Random
 
-// Random is the source for all randomness used in packe scope.
+// Random is the source for all randomness used in packe scope and for
+// the randomness of load tests in package suite. Seeding it makes a whole
+// run reproducible.
 var Random *rand.Rand
 var randMux sync.Mutex
 
@@ -82,6 +80,14 @@ func init() {
 	Random = rand.New(rand.NewSource(34)) // Seed chosen truly random by Sabine.
 }
 
+// Seed replaces Random with a new source seeded with seed. It is safe for
+// concurrent use.
+func Seed(seed int64) {
+	randMux.Lock()
+	Random = rand.New(rand.NewSource(seed))
+	randMux.Unlock()
+}
+
 // RandomIntn returns a random int in the rnage [0,n) read from Random.
 // It is safe for concurrent use.
 func RandomIntn(n int) int {
@@ -91,6 +97,33 @@ func RandomIntn(n int) int {
 	return r
 }
 
+// RandomInt63n returns a random int64 in the range [0,n) read from Random.
+// It is safe for concurrent use.
+func RandomInt63n(n int64) int64 {
+	randMux.Lock()
+	r := Random.Int63n(n)
+	randMux.Unlock()
+	return r
+}
+
+// RandomFloat64 returns a random float64 in the range [0,1) read from
+// Random. It is safe for concurrent use.
+func RandomFloat64() float64 {
+	randMux.Lock()
+	r := Random.Float64()
+	randMux.Unlock()
+	return r
+}
+
+// RandomExpFloat64 returns an exponentially distributed float64 with
+// rate 1 read from Random. It is safe for concurrent use.
+func RandomExpFloat64() float64 {
+	randMux.Lock()
+	r := Random.ExpFloat64()
+	randMux.Unlock()
+	return r
+}
+
 // ----------------------------------------------------------------------------
 // Counter
 
@@ -0,0 +1,254 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scope
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+// Secrets
+
+// SecretPrefix marks a variable value as a reference to a secret which is
+// loaded from an external source. The following forms are recognised:
+//     @secret:env:NAME
+//         the value of the environment variable NAME
+//     @secret:file:/path/to/file
+//         the content of the file with trailing whitespace trimmed
+//     @secret:vault:path/to/secret#field
+//         the field of the secret stored in HashiCorp Vault under
+//         path/to/secret; the Vault server and the token are taken from
+//         the environment variables VAULT_ADDR and VAULT_TOKEN.
+//     @secret:value:anything
+//         anything itself; useful to just mask a value given on the
+//         command line
+//     @secret:anything
+//         short form of @secret:value:anything if anything contains no ':'
+// Other sources like in "@secret:enb:NAME" are rejected.
+const SecretPrefix = "@secret:"
+
+// MaskString is the string secret values are replaced with by Mask.
+var MaskString = "***"
+
+var secrets = struct {
+	sync.Mutex
+	values   map[string]bool
+	replacer *strings.Replacer
+}{values: make(map[string]bool)}
+
+// MarkSecret registers value as a secret which will be masked by Mask.
+// It is safe for concurrent use. Empty values are not registered.
+func MarkSecret(value string) {
+	if value == "" {
+		return
+	}
+	secrets.Lock()
+	defer secrets.Unlock()
+	if secrets.values[value] {
+		return
+	}
+	secrets.values[value] = true
+
+	// Replace longer secrets first in case one secret is part of an
+	// other one. The encoded forms are masked too as the secret may be
+	// written escaped into a HTML report or a JSON or URL.
+	seen := make(map[string]bool)
+	values := make([]string, 0, len(secrets.values))
+	for v := range secrets.values {
+		for _, e := range encodings(v) {
+			if !seen[e] {
+				seen[e] = true
+				values = append(values, e)
+			}
+		}
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+	oldnew := make([]string, 0, 2*len(values))
+	for _, v := range values {
+		oldnew = append(oldnew, v, MaskString)
+	}
+	secrets.replacer = strings.NewReplacer(oldnew...)
+}
+
+// encodings returns value and the forms value takes when it is escaped
+// as HTML, JavaScript, JSON or as part of a URL.
+func encodings(value string) []string {
+	enc := []string{
+		value,
+		template.HTMLEscapeString(value),
+		// html/template additionally escapes '+' in HTML text.
+		strings.Replace(template.HTMLEscapeString(value), "+", "&#43;", -1),
+		template.JSEscapeString(value),
+		url.QueryEscape(value),
+		strings.Replace(url.QueryEscape(value), "+", "%20", -1),
+		url.PathEscape(value),
+	}
+	for _, escapeHTML := range []bool{true, false} {
+		buf := &bytes.Buffer{}
+		e := json.NewEncoder(buf)
+		e.SetEscapeHTML(escapeHTML)
+		if e.Encode(value) == nil {
+			quoted := strings.TrimSpace(buf.String())
+			enc = append(enc, quoted[1:len(quoted)-1])
+		}
+	}
+	return enc
+}
+
+// IsSecret reports whether value has been registered as a secret.
+func IsSecret(value string) bool {
+	secrets.Lock()
+	defer secrets.Unlock()
+	return secrets.values[value]
+}
+
+// Mask replaces every occurrence of a secret value in s with MaskString.
+func Mask(s string) string {
+	secrets.Lock()
+	r := secrets.replacer
+	secrets.Unlock()
+	if r == nil {
+		return s
+	}
+	return r.Replace(s)
+}
+
+// MaskedCopy returns a copy of vars with all secret values masked.
+func (vars Variables) MaskedCopy() Variables {
+	cpy := make(Variables, len(vars))
+	for n, v := range vars {
+		cpy[n] = Mask(v)
+	}
+	return cpy
+}
+
+// MaskingWriter returns a writer which masks secrets in everything
+// written to w. Masking happens per call to Write, so a secret split over
+// two calls to Write is not masked. This is fine for line based output
+// like the one produced by a log.Logger.
+func MaskingWriter(w io.Writer) io.Writer {
+	return maskingWriter{w}
+}
+
+type maskingWriter struct {
+	w io.Writer
+}
+
+func (m maskingWriter) Write(p []byte) (int, error) {
+	_, err := io.WriteString(m.w, Mask(string(p)))
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// ResolveSecrets replaces each value in vars of the form "@secret:..."
+// with the secret loaded from the external source and marks it as secret.
+func ResolveSecrets(vars Variables) error {
+	for name, value := range vars {
+		if !strings.HasPrefix(value, SecretPrefix) {
+			continue
+		}
+		secret, err := LoadSecret(value[len(SecretPrefix):])
+		if err != nil {
+			return fmt.Errorf("cannot load secret for variable %s: %s", name, err)
+		}
+		vars[name] = secret
+		MarkSecret(secret)
+	}
+	return nil
+}
+
+// LoadSecret loads the secret referenced by ref which is the part
+// following the SecretPrefix.
+func LoadSecret(ref string) (string, error) {
+	i := strings.Index(ref, ":")
+	if i == -1 {
+		return ref, nil
+	}
+	source, arg := ref[:i], ref[i+1:]
+	switch source {
+	case "value":
+		return arg, nil
+	case "env":
+		value, ok := os.LookupEnv(arg)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s not set", arg)
+		}
+		return value, nil
+	case "file":
+		data, err := ioutil.ReadFile(arg)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), " \t\r\n"), nil
+	case "vault":
+		return loadVaultSecret(arg)
+	}
+	return "", fmt.Errorf("unknown secret source %q", source)
+}
+
+// VaultClient is the http.Client used to load secrets from Vault.
+var VaultClient = &http.Client{Timeout: 10 * time.Second}
+
+// loadVaultSecret reads the field from the secret stored in Vault;
+// ref has the form path/to/secret#field. Both the KV version 1 and
+// version 2 response format are understood.
+func loadVaultSecret(ref string) (string, error) {
+	addr, token := os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN")
+	if addr == "" || token == "" {
+		return "", fmt.Errorf("VAULT_ADDR and VAULT_TOKEN must be set")
+	}
+	i := strings.LastIndex(ref, "#")
+	if i == -1 {
+		return "", fmt.Errorf("missing #field in vault reference %q", ref)
+	}
+	path, field := strings.Trim(ref[:i], "/"), ref[i+1:]
+
+	req, err := http.NewRequest("GET", strings.TrimRight(addr, "/")+"/v1/"+path, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	resp, err := VaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault responded with %s", resp.Status)
+	}
+
+	var secret struct {
+		Data map[string]interface{}
+	}
+	err = json.NewDecoder(resp.Body).Decode(&secret)
+	if err != nil {
+		return "", err
+	}
+	data := secret.Data
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		data = inner // KV version 2
+	}
+	value, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("no field %q in vault secret %s", field, path)
+	}
+	return fmt.Sprintf("%v", value), nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scope
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" || r.URL.Path != "/v1/secret/data/app" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"data": {"data": {"pass": "vault-secret"}}}`))
+	}))
+	defer vault.Close()
+	os.Setenv("VAULT_ADDR", vault.URL)
+	os.Setenv("VAULT_TOKEN", "token")
+	os.Setenv("HT_TEST_SECRET", "env-secret")
+
+	file, err := ioutil.TempFile("", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("file-secret\n")
+	file.Close()
+
+	vars := Variables{
+		"A": "@secret:env:HT_TEST_SECRET",
+		"B": "@secret:file:" + file.Name(),
+		"C": "@secret:vault:secret/data/app#pass",
+		"D": "@secret:plain-secret",
+		"E": "not a secret",
+	}
+	if err := ResolveSecrets(vars); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	want := Variables{
+		"A": "env-secret",
+		"B": "file-secret",
+		"C": "vault-secret",
+		"D": "plain-secret",
+		"E": "not a secret",
+	}
+	for n, v := range want {
+		if vars[n] != v {
+			t.Errorf("%s: got %q, want %q", n, vars[n], v)
+		}
+	}
+
+	if got := Mask("A=env-secret B=file-secret, not a secret"); got != "A=*** B=***, not a secret" {
+		t.Errorf("Got %q", got)
+	}
+	buf := &bytes.Buffer{}
+	MaskingWriter(buf).Write([]byte("vault-secret plain-secret"))
+	if got := buf.String(); got != "*** ***" {
+		t.Errorf("Got %q", got)
+	}
+
+	if err := ResolveSecrets(Variables{"X": "@secret:env:HT_TEST_UNSET_SECRET"}); err == nil {
+		t.Errorf("Missing error for unset environment variable")
+	}
+	if err := ResolveSecrets(Variables{"X": "@secret:enb:HT_TEST_SECRET"}); err == nil {
+		t.Errorf("Missing error for unknown source")
+	}
+	vars = Variables{"X": "@secret:value:with:colon"}
+	if err := ResolveSecrets(vars); err != nil || vars["X"] != "with:colon" {
+		t.Errorf("Got %q, %v", vars["X"], err)
+	}
+}
+
+func TestMaskEncoded(t *testing.T) {
+	MarkSecret(`p<a"s s&w+d`)
+	for i, tc := range []string{
+		`p<a"s s&w+d`,
+		`p&lt;a&#34;s s&amp;w+d`,     // html/template, HTML text
+		`p&lt;a&#34;s s&amp;w&#43;d`, // html/template, attribute
+		`p\u003ca\"s s\u0026w+d`,     // JSON
+		`p<a\"s s&w+d`,               // JSON without HTML escaping
+		`p\u003Ca\"s s\u0026w+d`,     // JavaScript
+		`p%3Ca%22s+s%26w%2Bd`,        // URL query
+		`p%3Ca%22s%20s&w+d`,          // URL path
+	} {
+		if got := Mask("x " + tc + " y"); got != "x *** y" {
+			t.Errorf("%d. %s: got %q", i, tc, got)
+		}
+	}
+}
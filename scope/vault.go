@@ -0,0 +1,180 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scope
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// ----------------------------------------------------------------------------
+// Encrypted variable files
+
+// VaultHeader is the first line of an encrypted variables file. The
+// remaining lines contain the base64 encoded salt, nonce and ciphertext.
+// The key is derived from the passphrase with scrypt and the plaintext
+// is encrypted with AES-256 in GCM mode.
+const VaultHeader = "$HTVAULT;1;AES256-GCM;SCRYPT"
+
+// The passphrase used to decrypt encrypted variable files is taken from
+// the first of the following sources which is set:
+//   - VaultPassphrase
+//   - the environment variable HT_VAULT_PASSPHRASE
+//   - the content of the file VaultKeyFile (trailing whitespace trimmed)
+//   - the output of the shell command VaultKeyCommand (trailing whitespace
+//     trimmed), useful to fetch the passphrase from a KMS.
+var (
+	VaultPassphrase string
+	VaultKeyFile    string
+	VaultKeyCommand string
+)
+
+// scrypt parameters and sizes used in the vault format.
+const (
+	vaultSaltLen  = 16
+	vaultKeyLen   = 32
+	vaultScryptN  = 1 << 15
+	vaultScryptR  = 8
+	vaultScryptP  = 1
+	vaultLineWrap = 64
+)
+
+// IsEncrypted reports whether data is an encrypted variables file.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(VaultHeader))
+}
+
+// Encrypt encrypts plaintext with passphrase and returns it in the vault
+// file format.
+func Encrypt(plaintext, passphrase []byte) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("empty vault passphrase")
+	}
+	salt := make([]byte, vaultSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := vaultCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	payload := append(salt, nonce...)
+	payload = aead.Seal(payload, nonce, plaintext, []byte(VaultHeader))
+
+	encoded := base64.StdEncoding.EncodeToString(payload)
+	buf := &bytes.Buffer{}
+	buf.WriteString(VaultHeader)
+	buf.WriteByte('\n')
+	for len(encoded) > vaultLineWrap {
+		buf.WriteString(encoded[:vaultLineWrap])
+		buf.WriteByte('\n')
+		encoded = encoded[vaultLineWrap:]
+	}
+	buf.WriteString(encoded)
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// Decrypt decrypts data in the vault file format with passphrase.
+func Decrypt(data, passphrase []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return nil, errors.New("missing vault header")
+	}
+	body := string(data[len(VaultHeader):])
+	body = strings.Join(strings.Fields(body), "")
+	payload, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return nil, fmt.Errorf("malformed vault data: %s", err)
+	}
+	if len(payload) < vaultSaltLen {
+		return nil, errors.New("vault data too short")
+	}
+	salt, payload := payload[:vaultSaltLen], payload[vaultSaltLen:]
+	aead, err := vaultCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) < aead.NonceSize() {
+		return nil, errors.New("vault data too short")
+	}
+	nonce, ciphertext := payload[:aead.NonceSize()], payload[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(VaultHeader))
+	if err != nil {
+		return nil, errors.New("cannot decrypt vault: wrong passphrase or corrupted data")
+	}
+	return plaintext, nil
+}
+
+// DecryptVault decrypts data with the configured vault passphrase.
+func DecryptVault(data []byte) ([]byte, error) {
+	passphrase, err := VaultKey()
+	if err != nil {
+		return nil, err
+	}
+	return Decrypt(data, passphrase)
+}
+
+// VaultKey returns the vault passphrase from the first configured source.
+func VaultKey() ([]byte, error) {
+	if VaultPassphrase != "" {
+		return []byte(VaultPassphrase), nil
+	}
+	if pass := os.Getenv("HT_VAULT_PASSPHRASE"); pass != "" {
+		return []byte(pass), nil
+	}
+	if VaultKeyFile != "" {
+		data, err := ioutil.ReadFile(VaultKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read vault key file: %s", err)
+		}
+		return trimKey(data)
+	}
+	if VaultKeyCommand != "" {
+		cmd := exec.Command("sh", "-c", VaultKeyCommand)
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("vault key command failed: %s", err)
+		}
+		return trimKey(out)
+	}
+	return nil, errors.New("no vault passphrase configured")
+}
+
+func trimKey(data []byte) ([]byte, error) {
+	key := bytes.TrimRight(data, " \t\r\n")
+	if len(key) == 0 {
+		return nil, errors.New("empty vault passphrase")
+	}
+	return key, nil
+}
+
+func vaultCipher(passphrase, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, vaultScryptN, vaultScryptR,
+		vaultScryptP, vaultKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,64 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scope
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestVaultRoundTrip(t *testing.T) {
+	plaintext := []byte(`{ USER: "admin", PASS: "s3cr3t" }` + "\n")
+	encrypted, err := Encrypt(plaintext, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(encrypted) {
+		t.Fatalf("Missing header in %q", encrypted)
+	}
+	if bytes.Contains(encrypted, []byte("s3cr3t")) {
+		t.Fatalf("Plaintext leaked into %q", encrypted)
+	}
+	for _, line := range strings.Split(string(encrypted), "\n") {
+		if len(line) > vaultLineWrap && line != VaultHeader {
+			t.Errorf("Line too long: %q", line)
+		}
+	}
+
+	decrypted, err := Decrypt(encrypted, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Got %q, want %q", decrypted, plaintext)
+	}
+
+	_, err = Decrypt(encrypted, []byte("wrong"))
+	if err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Errorf("Got error %v for wrong passphrase", err)
+	}
+
+	tampered := bytes.Replace(encrypted, []byte("\n"), []byte("\nAAAA"), 2)
+	if _, err = Decrypt(tampered, []byte("passphrase")); err == nil {
+		t.Errorf("Missing error for tampered data")
+	}
+}
+
+func TestVaultKey(t *testing.T) {
+	defer func() { VaultPassphrase, VaultKeyCommand = "", "" }()
+
+	VaultKeyCommand = "echo from-command"
+	key, err := VaultKey()
+	if err != nil || string(key) != "from-command" {
+		t.Errorf("Got %q, %v", key, err)
+	}
+
+	VaultPassphrase = "direct"
+	key, err = VaultKey()
+	if err != nil || string(key) != "direct" {
+		t.Errorf("Got %q, %v", key, err)
+	}
+}
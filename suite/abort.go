@@ -0,0 +1,110 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/internal/hdr"
+)
+
+// ----------------------------------------------------------------------------
+// Early abort
+
+// AbortCondition describes when a throughput test is aborted early: If the
+// error rate or the 99th percentile of the request duration of the requests
+// made during the last Window exceed their limit. The condition is not
+// evaluated before the throughput test has been running for Window so a
+// short burst of errors does not abort the test.
+type AbortCondition struct {
+	// ErrorRate is the maximal tolerable error rate in percent.
+	// Zero disables this limit.
+	ErrorRate float64
+
+	// P99 is the maximal tolerable 99th percentile of the request
+	// duration. Zero disables this limit.
+	P99 time.Duration
+
+	// Window over which the error rate and the 99th percentile are
+	// computed. Defaults to 30 seconds.
+	Window time.Duration
+}
+
+// minAbortSamples is the minimal number of requests during the window
+// needed to abort the throughput test.
+const minAbortSamples = 20
+
+// abortMonitor evaluates an AbortCondition during a throughput test.
+type abortMonitor struct {
+	cond    AbortCondition
+	started time.Time
+
+	mu     sync.Mutex
+	recent []sample
+}
+
+// newAbortMonitor returns a monitor for cond or nil if cond has no limits.
+func newAbortMonitor(cond AbortCondition, started time.Time) *abortMonitor {
+	if cond.ErrorRate <= 0 && cond.P99 <= 0 {
+		return nil
+	}
+	if cond.Window <= 0 {
+		cond.Window = 30 * time.Second
+	}
+	return &abortMonitor{cond: cond, started: started}
+}
+
+// record the outcome of a request finished at.
+func (am *abortMonitor) record(at time.Time, d TestData) {
+	if am == nil {
+		return
+	}
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.recent = append(am.recent, sample{at, d.ReqDuration, d.Status > ht.Pass})
+}
+
+// check returns a non-nil error describing the exceeded limit if the
+// throughput test has to be aborted.
+func (am *abortMonitor) check(now time.Time) error {
+	if am == nil || now.Sub(am.started) < am.cond.Window {
+		return nil
+	}
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	limit := now.Add(-am.cond.Window)
+	i := 0
+	for i < len(am.recent) && am.recent[i].at.Before(limit) {
+		i++
+	}
+	am.recent = am.recent[i:]
+	n := len(am.recent)
+	if n < minAbortSamples {
+		return nil
+	}
+
+	failed := 0
+	hist := hdr.New(3)
+	for _, s := range am.recent {
+		hist.Record(int64(s.duration / time.Microsecond))
+		if s.failed {
+			failed++
+		}
+	}
+	if rate := 100 * float64(failed) / float64(n); am.cond.ErrorRate > 0 && rate > am.cond.ErrorRate {
+		return fmt.Errorf("error rate %.1f%% during last %s exceeds %g%%",
+			rate, am.cond.Window, am.cond.ErrorRate)
+	}
+	p99 := time.Duration(hist.ValueAtPercentile(99)) * time.Microsecond
+	if am.cond.P99 > 0 && p99 > am.cond.P99 {
+		return fmt.Errorf("p99 %s during last %s exceeds %s",
+			p99, am.cond.Window, am.cond.P99)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vdobler/ht/ht"
+)
+
+func TestAbortMonitor(t *testing.T) {
+	if newAbortMonitor(AbortCondition{Window: time.Second}, time.Now()) != nil {
+		t.Errorf("Got monitor without limits")
+	}
+
+	start := time.Now()
+	cond := AbortCondition{ErrorRate: 10, P99: 500 * time.Millisecond, Window: 10 * time.Second}
+
+	for i, tc := range []struct {
+		status   ht.Status
+		duration time.Duration
+		okay     bool
+	}{
+		{ht.Pass, 100 * time.Millisecond, true},
+		{ht.Error, 100 * time.Millisecond, false},
+		{ht.Pass, time.Second, false},
+	} {
+		am := newAbortMonitor(cond, start)
+		// Old requests fall out of the window.
+		for k := 0; k < 50; k++ {
+			am.record(start, TestData{Status: ht.Error, ReqDuration: time.Hour})
+		}
+		for k := 0; k < 50; k++ {
+			d := TestData{Status: ht.Pass, ReqDuration: 100 * time.Millisecond}
+			if k%2 == 0 {
+				d = TestData{Status: tc.status, ReqDuration: tc.duration}
+			}
+			am.record(start.Add(15*time.Second), d)
+		}
+
+		if err := am.check(start.Add(5 * time.Second)); err != nil {
+			t.Errorf("%d. Unexpected abort before Window: %s", i, err)
+		}
+		err := am.check(start.Add(20 * time.Second))
+		if tc.okay && err != nil {
+			t.Errorf("%d. Unexpected abort: %s", i, err)
+		} else if !tc.okay && err == nil {
+			t.Errorf("%d. Missing abort", i)
+		}
+	}
+
+	// Too few requests never abort.
+	am := newAbortMonitor(cond, start)
+	for k := 0; k < minAbortSamples-1; k++ {
+		am.record(start.Add(15*time.Second), TestData{Status: ht.Error})
+	}
+	if err := am.check(start.Add(20 * time.Second)); err != nil {
+		t.Errorf("Unexpected abort: %s", err)
+	}
+}
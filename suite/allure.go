@@ -0,0 +1,197 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/scope"
+)
+
+// ----------------------------------------------------------------------------
+//   Allure
+
+// AllureReporter writes the result of a suite as Allure result files to
+// the folder allure-results in dir: One result per test with one step per
+// check, the request and the response as attachments and the suite, the
+// host and the tags of the test as labels. Run 'allure generate' on the
+// folder to produce the Allure report.
+var AllureReporter = ReporterFunc(func(dir string, s *Suite) error {
+	folder := filepath.Join(dir, "allure-results")
+	if err := os.MkdirAll(folder, 0766); err != nil {
+		return err
+	}
+	for _, test := range s.Tests {
+		if err := writeAllureResult(folder, s, test); err != nil {
+			return err
+		}
+	}
+	return nil
+})
+
+// allureResult is a test result in the Allure result format.
+type allureResult struct {
+	UUID          string             `json:"uuid"`
+	HistoryID     string             `json:"historyId"`
+	Name          string             `json:"name"`
+	FullName      string             `json:"fullName"`
+	Description   string             `json:"description,omitempty"`
+	Status        string             `json:"status"`
+	StatusDetails *allureDetails     `json:"statusDetails,omitempty"`
+	Stage         string             `json:"stage"`
+	Start         int64              `json:"start"`
+	Stop          int64              `json:"stop"`
+	Labels        []allureLabel      `json:"labels"`
+	Steps         []allureStep       `json:"steps,omitempty"`
+	Attachments   []allureAttachment `json:"attachments,omitempty"`
+}
+
+type allureDetails struct {
+	Message string `json:"message,omitempty"`
+}
+
+type allureLabel struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type allureStep struct {
+	Name          string         `json:"name"`
+	Status        string         `json:"status"`
+	StatusDetails *allureDetails `json:"statusDetails,omitempty"`
+	Stage         string         `json:"stage"`
+	Start         int64          `json:"start"`
+	Stop          int64          `json:"stop"`
+}
+
+type allureAttachment struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	Type   string `json:"type"`
+}
+
+// writeAllureResult writes the result file and the attachments of test
+// to folder.
+func writeAllureResult(folder string, s *Suite, test *ht.Test) error {
+	uuid, err := allureUUID()
+	if err != nil {
+		return err
+	}
+	seqNo := test.GetStringMetadata("SeqNo")
+	start := test.Result.Started
+	if start.IsZero() {
+		start = s.Started
+	}
+	result := allureResult{
+		UUID:          uuid,
+		HistoryID:     s.Name + "/" + seqNo,
+		Name:          test.Name,
+		FullName:      s.Name + ": " + seqNo + " " + test.Name,
+		Description:   test.Description,
+		Status:        allureStatus(test.Result.Status),
+		StatusDetails: allureStatusDetails(test.Result.Error),
+		Stage:         "finished",
+		Start:         allureTime(start),
+		Stop:          allureTime(start.Add(test.Result.Duration)),
+		Labels: []allureLabel{
+			{"suite", s.Name},
+			{"framework", "ht"},
+			{"language", "go"},
+		},
+	}
+	if test.Request.Request != nil {
+		result.Labels = append(result.Labels,
+			allureLabel{"host", test.Request.Request.URL.Host})
+	}
+	for _, tag := range test.Tags {
+		result.Labels = append(result.Labels, allureLabel{"tag", tag})
+	}
+
+	// Checks are executed after the response has been received.
+	t := start.Add(test.Response.Duration)
+	for _, cr := range test.Result.CheckResults {
+		result.Steps = append(result.Steps, allureStep{
+			Name:          cr.Name + " " + scope.Mask(cr.JSON),
+			Status:        allureStatus(cr.Status),
+			StatusDetails: allureStatusDetails(cr.Error.AsError()),
+			Stage:         "finished",
+			Start:         allureTime(t),
+			Stop:          allureTime(t.Add(cr.Duration)),
+		})
+		t = t.Add(cr.Duration)
+	}
+
+	for _, att := range []struct{ name, content string }{
+		{"Request", wireRequest(test)},
+		{"Response", wireResponse(test)},
+	} {
+		if att.content == "" {
+			continue
+		}
+		source := fmt.Sprintf("%s-%s-attachment.txt", uuid, strings.ToLower(att.name))
+		err := ioutil.WriteFile(filepath.Join(folder, source),
+			[]byte(scope.Mask(att.content)), 0666)
+		if err != nil {
+			return err
+		}
+		result.Attachments = append(result.Attachments, allureAttachment{
+			Name:   att.name,
+			Source: source,
+			Type:   "text/plain",
+		})
+	}
+
+	data, err := json.MarshalIndent(result, "", "    ")
+	if err != nil {
+		return err
+	}
+	data = []byte(scope.Mask(string(data)))
+	return ioutil.WriteFile(filepath.Join(folder, uuid+"-result.json"), data, 0666)
+}
+
+// allureStatus maps status to the Allure statuses passed, failed, broken
+// and skipped.
+func allureStatus(status ht.Status) string {
+	switch status {
+	case ht.Pass:
+		return "passed"
+	case ht.Fail:
+		return "failed"
+	case ht.Error, ht.Bogus:
+		return "broken"
+	}
+	return "skipped"
+}
+
+func allureStatusDetails(err error) *allureDetails {
+	if err == nil {
+		return nil
+	}
+	return &allureDetails{Message: strings.Join(errorStrings(err), "\n")}
+}
+
+// allureTime returns t in milliseconds since the epoch.
+func allureTime(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// allureUUID returns a random (version 4) UUID.
+func allureUUID() (string, error) {
+	u := make([]byte, 16)
+	if _, err := rand.Read(u); err != nil {
+		return "", err
+	}
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:]), nil
+}
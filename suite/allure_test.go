@@ -0,0 +1,111 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vdobler/ht/errorlist"
+	"github.com/vdobler/ht/ht"
+)
+
+func TestAllureReporter(t *testing.T) {
+	started := time.Date(2017, 5, 1, 12, 0, 0, 0, time.UTC)
+	test := &ht.Test{
+		Name: "Search",
+		Tags: []string{"smoke", "search"},
+		Request: ht.Request{
+			Request: &http.Request{
+				Method: "GET",
+				URL:    &url.URL{Scheme: "http", Host: "example.org", Path: "/search"},
+				Header: http.Header{"Accept": {"text/html"}},
+			},
+		},
+		Response: ht.Response{
+			Response: &http.Response{
+				Proto:  "HTTP/1.1",
+				Status: "200 OK",
+				Header: http.Header{"Content-Type": {"text/html"}},
+			},
+			BodyStr:  "Found 3 items",
+			Duration: 20 * time.Millisecond,
+		},
+		Result: ht.Result{
+			Status:   ht.Fail,
+			Started:  started,
+			Duration: 30 * time.Millisecond,
+			Error:    errorlist.List{fmt.Errorf("not found")},
+			CheckResults: []ht.CheckResult{
+				{Name: "StatusCode", JSON: `{"Expect":200}`, Status: ht.Pass,
+					Duration: 2 * time.Millisecond},
+				{Name: "Body", JSON: `{"Contains":"4 items"}`, Status: ht.Fail,
+					Duration: 1 * time.Millisecond,
+					Error:    errorlist.List{fmt.Errorf("not found")}},
+			},
+		},
+	}
+	test.SetMetadata("SeqNo", "Main-01")
+	s := &Suite{Name: "Shop", Tests: []*ht.Test{test}}
+
+	dir, err := ioutil.TempDir("", "allure")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := AllureReporter.Report(dir, s); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	folder := filepath.Join(dir, "allure-results")
+	results, _ := filepath.Glob(filepath.Join(folder, "*-result.json"))
+	attachments, _ := filepath.Glob(filepath.Join(folder, "*-attachment.txt"))
+	if len(results) != 1 || len(attachments) != 2 {
+		t.Fatalf("Got results %v and attachments %v", results, attachments)
+	}
+	data, err := ioutil.ReadFile(results[0])
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	got := allureResult{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unexpected error: %s\n%s", err, data)
+	}
+	if got.Status != "failed" || got.Name != "Search" || got.HistoryID != "Shop/Main-01" ||
+		got.StatusDetails == nil || got.StatusDetails.Message != "not found" ||
+		got.Stop-got.Start != 30 {
+		t.Errorf("Got %+v", got)
+	}
+	labels := []string{}
+	for _, l := range got.Labels {
+		labels = append(labels, l.Name+"="+l.Value)
+	}
+	if l := strings.Join(labels, " "); l != "suite=Shop framework=ht language=go host=example.org tag=smoke tag=search" {
+		t.Errorf("Got labels %s", l)
+	}
+	if len(got.Steps) != 2 || got.Steps[0].Status != "passed" ||
+		got.Steps[1].Status != "failed" || got.Steps[1].Name != `Body {"Contains":"4 items"}` ||
+		got.Steps[1].Start-got.Start != 22 {
+		t.Errorf("Got steps %+v", got.Steps)
+	}
+	if len(got.Attachments) != 2 || got.Attachments[1].Name != "Response" {
+		t.Fatalf("Got attachments %+v", got.Attachments)
+	}
+	resp, err := ioutil.ReadFile(filepath.Join(folder, got.Attachments[1].Source))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if want := "HTTP/1.1 200 OK\nContent-Type: text/html\n\nFound 3 items\n"; string(resp) != want {
+		t.Errorf("Got response %q", resp)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/vdobler/ht/errorlist"
+)
+
+// ----------------------------------------------------------------------------
+// Load test summaries and baselines
+
+// A LoadSummary is the machine readable summary of a throughput test. It can
+// be stored and used as the baseline for later throughput tests.
+type LoadSummary struct {
+	Started    time.Time        // Start of the first request.
+	Duration   time.Duration    // Duration from first to last request.
+	Throughput float64          // Requests per second.
+	Latencies  []LatencySummary // Latencies like returned by Latencies.
+}
+
+// Summarise the throughput test result data.
+func Summarise(data []TestData) LoadSummary {
+	summary := LoadSummary{Latencies: Latencies(data)}
+	if len(data) == 0 {
+		return summary
+	}
+	first, last := data[0].Started, data[0].Started
+	for _, d := range data {
+		if d.Started.Before(first) {
+			first = d.Started
+		}
+		if end := d.Started.Add(d.ReqDuration); end.After(last) {
+			last = end
+		}
+	}
+	summary.Started = first
+	summary.Duration = last.Sub(first)
+	if summary.Duration > 0 {
+		summary.Throughput = float64(len(data)) / summary.Duration.Seconds()
+	}
+	return summary
+}
+
+// WriteJSON writes s as JSON to w.
+func (s LoadSummary) WriteJSON(w io.Writer) error {
+	buf, err := json.MarshalIndent(s, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+// ReadLoadSummary reads a LoadSummary written by WriteJSON from filename.
+func ReadLoadSummary(filename string) (LoadSummary, error) {
+	s := LoadSummary{}
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return s, err
+	}
+	err = json.Unmarshal(buf, &s)
+	return s, err
+}
+
+// latency returns the latency summary with the given name.
+func (s LoadSummary) latency(name string) (LatencySummary, bool) {
+	for _, ls := range s.Latencies {
+		if ls.Name == name {
+			return ls, true
+		}
+	}
+	return LatencySummary{}, false
+}
+
+// Compare s to the baseline and report regressions: A latency (mean, p50,
+// p90 or p99) of a test, scenario or overall which is more than tolerance
+// percent higher than in the baseline, an error rate which is more than
+// tolerance percentage points higher and a throughput which is more than
+// tolerance percent lower.
+// Tests and scenarios not present in the baseline are not compared.
+func (s LoadSummary) Compare(baseline LoadSummary, tolerance float64) error {
+	errors := errorlist.List{}
+	factor := 1 + tolerance/100
+	if baseline.Throughput > 0 && s.Throughput < baseline.Throughput*(1-tolerance/100) {
+		errors = append(errors, fmt.Errorf("throughput dropped from %.1f to %.1f requests/second",
+			baseline.Throughput, s.Throughput))
+	}
+
+	for _, cur := range s.Latencies {
+		base, ok := baseline.latency(cur.Name)
+		if !ok {
+			continue
+		}
+		for _, m := range []struct {
+			name      string
+			base, cur time.Duration
+		}{
+			{"mean", base.Mean, cur.Mean},
+			{"p50", base.P50, cur.P50},
+			{"p90", base.P90, cur.P90},
+			{"p99", base.P99, cur.P99},
+		} {
+			if float64(m.cur) > factor*float64(m.base) {
+				errors = append(errors, fmt.Errorf("%s: %s regressed from %s to %s (%+.1f%%)",
+					cur.Name, m.name, m.base, m.cur, relChange(m.base, m.cur)))
+			}
+		}
+		if cur.ErrorRate() > base.ErrorRate()+tolerance {
+			errors = append(errors, fmt.Errorf("%s: error rate increased from %.2f%% to %.2f%%",
+				cur.Name, base.ErrorRate(), cur.ErrorRate()))
+		}
+	}
+
+	return errors.AsError()
+}
+
+// PrintComparison prints the change of the latencies of s relative to
+// the baseline to w.
+func (s LoadSummary) PrintComparison(w io.Writer, baseline LoadSummary) {
+	width := 0
+	for _, ls := range s.Latencies {
+		if len(ls.Name) > width {
+			width = len(ls.Name)
+		}
+	}
+	fmt.Fprintf(w, "%-*s  %9s %9s %9s %9s %9s\n", width, "Change to baseline",
+		"Errors", "Mean", "p50", "p90", "p99")
+	for _, cur := range s.Latencies {
+		base, ok := baseline.latency(cur.Name)
+		if !ok {
+			fmt.Fprintf(w, "%-*s  %9s\n", width, cur.Name, "new")
+			continue
+		}
+		fmt.Fprintf(w, "%-*s  %+8.2f%% %+8.1f%% %+8.1f%% %+8.1f%% %+8.1f%%\n",
+			width, cur.Name, cur.ErrorRate()-base.ErrorRate(),
+			relChange(base.Mean, cur.Mean), relChange(base.P50, cur.P50),
+			relChange(base.P90, cur.P90), relChange(base.P99, cur.P99))
+	}
+	fmt.Fprintf(w, "Throughput: %.1f requests/second (baseline %.1f)\n",
+		s.Throughput, baseline.Throughput)
+}
+
+// relChange returns the change from base to cur in percent.
+func relChange(base, cur time.Duration) float64 {
+	if base == 0 {
+		return 0
+	}
+	return 100 * float64(cur-base) / float64(base)
+}
@@ -0,0 +1,85 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadSummaryRoundtrip(t *testing.T) {
+	summary := Summarise(latencyTestData())
+	if len(summary.Latencies) != 4 {
+		t.Fatalf("Got %d latencies", len(summary.Latencies))
+	}
+
+	buf := &bytes.Buffer{}
+	if err := summary.WriteJSON(buf); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	back := LoadSummary{}
+	if err := json.Unmarshal(buf.Bytes(), &back); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if back.Latencies[0].P99 != summary.Latencies[0].P99 ||
+		back.Latencies[3].Name != "Scen: Odd" {
+		t.Errorf("Got %+v", back.Latencies)
+	}
+	if err := back.Compare(summary, 0); err != nil {
+		t.Errorf("Unexpected regression: %s", err)
+	}
+}
+
+func TestLoadSummaryCompare(t *testing.T) {
+	baseline := Summarise(latencyTestData())
+	baseline.Throughput = 100
+
+	slower := Summarise(latencyTestData())
+	slower.Throughput = 100
+	slower.Latencies[2].P99 = baseline.Latencies[2].P99 * 13 / 10
+
+	if err := slower.Compare(baseline, 50); err != nil {
+		t.Errorf("Unexpected regression: %s", err)
+	}
+	err := slower.Compare(baseline, 10)
+	if err == nil || !strings.Contains(err.Error(), "Scen: Even: p99 regressed") {
+		t.Errorf("Got %v", err)
+	}
+
+	slower.Latencies[2].P99 = baseline.Latencies[2].P99
+	slower.Throughput = 90.5 // less than 10% lower
+	if err := slower.Compare(baseline, 10); err != nil {
+		t.Errorf("Unexpected regression: %s", err)
+	}
+	slower.Throughput = 80
+	if err := slower.Compare(baseline, 10); err == nil {
+		t.Errorf("Missing throughput regression")
+	}
+
+	buf := &bytes.Buffer{}
+	slower.PrintComparison(buf, baseline)
+	if !strings.Contains(buf.String(), "Throughput: 80.0 requests/second (baseline 100.0)") {
+		t.Errorf("Got\n%s", buf.String())
+	}
+}
+
+func TestSummariseThroughput(t *testing.T) {
+	start := time.Now()
+	data := []TestData{}
+	for i := 0; i < 10; i++ {
+		data = append(data, TestData{
+			Started:     start.Add(time.Duration(i) * 100 * time.Millisecond),
+			ReqDuration: 100 * time.Millisecond,
+			ID:          TestIdentifier{Scenario: 1, Test: 1, ScenarioName: "S", TestName: "T"},
+		})
+	}
+	s := Summarise(data)
+	if s.Duration != time.Second || s.Throughput != 10 {
+		t.Errorf("Got %s %g", s.Duration, s.Throughput)
+	}
+}
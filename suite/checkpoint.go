@@ -0,0 +1,174 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/vdobler/ht/cookiejar"
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/scope"
+)
+
+// ----------------------------------------------------------------------------
+// Checkpoint
+
+// A Checkpoint persists the outcome of each executed test of a suite
+// incrementally to a file. An interrupted execution of the suite can be
+// resumed from such a file: Main tests which passed in the interrupted run
+// are not executed again but their extracted variables (and the cookies)
+// are reused. Setup and Teardown tests are always executed.
+//
+// Secret values (see scope.MarkSecret) are not written to the checkpoint
+// file: Extracted variables with a secret value are masked and cookies with
+// a secret value are dropped. Tests whose entry had to be masked are
+// executed again when resuming.
+//
+// The checkpoint file contains one JSON encoded CheckpointEntry per line.
+type Checkpoint struct {
+	// Filename of the checkpoint file.
+	Filename string
+
+	done map[int]CheckpointEntry // tests recorded in a previous run
+	last *CheckpointEntry        // the last recorded entry of previous run
+	file *os.File
+}
+
+// CheckpointEntry records the outcome of one test.
+type CheckpointEntry struct {
+	Index     int               // Index of the test in the suite, 0-based.
+	File      string            // Filename of the test.
+	Name      string            // Name of the test.
+	Status    ht.Status         // Status of the test.
+	Extracted map[string]string // Successfully extracted variables.
+	Cookies   []cookiejar.Entry // Content of the cookie jar after the test.
+	Masked    bool              // Secret values have been masked or dropped.
+}
+
+// OpenCheckpoint opens filename as a checkpoint. If resume is true the
+// entries of a previous (interrupted) execution are read in and new
+// entries are appended; otherwise the file is truncated. A missing file
+// is not an error when resuming.
+func OpenCheckpoint(filename string, resume bool) (*Checkpoint, error) {
+	cp := &Checkpoint{
+		Filename: filename,
+		done:     make(map[int]CheckpointEntry),
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if resume {
+		err := cp.load()
+		if err != nil {
+			return nil, err
+		}
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	file, err := os.OpenFile(filename, flags, 0666)
+	if err != nil {
+		return nil, err
+	}
+	cp.file = file
+	return cp, nil
+}
+
+// load reads the entries of a previous run.
+func (cp *Checkpoint) load() error {
+	file, err := os.Open(cp.Filename)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		entry := CheckpointEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// A crash might leave a truncated last line: Ignore it.
+			continue
+		}
+		cp.done[entry.Index] = entry
+		cp.last = &entry
+	}
+	return scanner.Err()
+}
+
+// Close the underlying checkpoint file.
+func (cp *Checkpoint) Close() error {
+	if cp == nil || cp.file == nil {
+		return nil
+	}
+	return cp.file.Close()
+}
+
+// passed returns the recorded entry of the index'th test if this
+// test passed during the previous run and its entry is complete.
+func (cp *Checkpoint) passed(index int, file string) (CheckpointEntry, bool) {
+	if cp == nil {
+		return CheckpointEntry{}, false
+	}
+	entry, ok := cp.done[index]
+	if !ok || entry.File != file || entry.Status != ht.Pass || entry.Masked {
+		return CheckpointEntry{}, false
+	}
+	return entry, true
+}
+
+// restoreCookies loads the cookies recorded last into jar.
+func (cp *Checkpoint) restoreCookies(jar *cookiejar.Jar) {
+	if cp == nil || cp.last == nil || jar == nil {
+		return
+	}
+	jar.LoadEntries(cp.last.Cookies)
+}
+
+// record appends the outcome of test to the checkpoint file.
+func (cp *Checkpoint) record(index int, file string, test *ht.Test, jar *cookiejar.Jar) error {
+	if cp == nil {
+		return nil
+	}
+	entry := CheckpointEntry{
+		Index:     index,
+		File:      file,
+		Name:      test.Name,
+		Status:    test.Result.Status,
+		Extracted: make(map[string]string),
+	}
+	for name, ex := range test.Result.Extractions {
+		if ex.Error == nil {
+			entry.Extracted[name] = scope.Mask(ex.Value)
+			entry.Masked = entry.Masked || entry.Extracted[name] != ex.Value
+		}
+	}
+	if jar != nil {
+		var cookies []cookiejar.Entry
+		for _, tld := range jar.ETLDsPlus1(nil) {
+			cookies = jar.Entries(tld, cookies)
+		}
+		for _, c := range cookies {
+			if scope.Mask(c.Value) != c.Value {
+				entry.Masked = true
+				continue
+			}
+			entry.Cookies = append(entry.Cookies, c)
+		}
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if _, err = cp.file.Write(data); err != nil {
+		return fmt.Errorf("cannot write checkpoint %s: %s", cp.Filename, err)
+	}
+	return cp.file.Sync()
+}
@@ -0,0 +1,344 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/internal/hjson"
+	"github.com/vdobler/ht/scope"
+)
+
+// ----------------------------------------------------------------------------
+//   Annotations
+
+// An Annotation points to the definition of a failed test.
+type Annotation struct {
+	File    string // File is the file of the test or mixin.
+	Line    int    // Line (1-based) of the failing check.
+	Title   string // Title like "Fail: Main-02 Login".
+	Message string // Message lists the errors.
+}
+
+// Annotations returns an Annotation for each failed, errored or bogus
+// test of s. The annotation points to the first failing check
+// if it can be located in the test or its mixins and to the start of the
+// test file otherwise. Inline tests point to the suite file.
+func (s *Suite) Annotations() []Annotation {
+	annotations := []Annotation{}
+	for i, test := range s.Tests {
+		if test.Result.Status <= ht.Pass {
+			continue
+		}
+		a := Annotation{
+			File: test.GetStringMetadata("Filename"),
+			Line: 1,
+			Title: fmt.Sprintf("%s: %s %s", test.Result.Status,
+				test.GetStringMetadata("SeqNo"), test.Name),
+			Message: scope.Mask(strings.Join(errorStrings(test.Result.Error), "\n")),
+		}
+		if i < len(s.tests) {
+			a.File, a.Line = s.tests[i].locateFailure(test)
+		}
+		if i := strings.Index(a.File, "_inline-"); i != -1 {
+			a.File = a.File[:i]
+		}
+		annotations = append(annotations, a)
+	}
+	return annotations
+}
+
+// locateFailure returns the file and the line in rt or its mixins where
+// the first failed check of test is defined. (Lines in inline tests do
+// not correspond to lines in the suite file and are not located.)
+func (rt *RawTest) locateFailure(test *ht.Test) (string, int) {
+	files := []*File{rt.File}
+	for _, mix := range rt.Mixins {
+		files = append(files, mix.File)
+	}
+	for i, cr := range test.Result.CheckResults {
+		if cr.Status <= ht.Pass {
+			continue
+		}
+		// The checks of test are the ones of rt followed by the
+		// ones of its mixins.
+		n := i
+		for _, f := range files {
+			doc, err := hjson.ParseDocument([]byte(f.Data))
+			if err != nil {
+				break
+			}
+			soup, _ := doc.Interface().(map[string]interface{})
+			checks, _ := soup["Checks"].([]interface{})
+			if n >= len(checks) {
+				n -= len(checks)
+				continue
+			}
+			if isInline(f) {
+				break
+			}
+			line, _ := doc.Position("Checks", n, "Check")
+			return f.Name, line
+		}
+		break
+	}
+	return rt.File.Name, 1
+}
+
+// ----------------------------------------------------------------------------
+//   CI Reporter
+
+// A CIReporter reports the outcome of suites to the commit they were
+// executed for on GitHub or GitLab: On GitHub a check run is created
+// whose annotations show up inline in pull requests; on GitLab the
+// commit status is set and the failed tests are commented on the lines
+// of their definition.
+type CIReporter struct {
+	// Provider is either "github" or "gitlab".
+	Provider string
+
+	// API is the base URL of the API like "https://api.github.com"
+	// or "https://gitlab.example.org/api/v4".
+	API string
+
+	// Project is "owner/repository" for GitHub and the ID or the
+	// path of the project for GitLab.
+	Project string
+
+	Commit string // Commit is the full SHA of the commit.
+	Token  string // Token used for authentication.
+
+	// Name of the check run or the commit status; empty means "ht".
+	Name string
+
+	// TargetURL links from the status to e.g. the CI job.
+	TargetURL string
+
+	// Root is the folder of the repository. File names of annotations
+	// are made relative to Root.
+	Root string
+
+	// MaxComments limits the number of commit comments on GitLab.
+	// Zero means 10.
+	MaxComments int
+
+	// Client used for the API calls; nil means http.DefaultClient.
+	Client *http.Client
+}
+
+// Report the outcome of suites. Problems of the run which are not tied to
+// a test (like insufficient coverage) fail the run and are added to the
+// summary.
+func (c *CIReporter) Report(suites []*Suite, problems ...string) error {
+	status := ht.NotRun
+	total, passed := 0, 0
+	annotations := []Annotation{}
+	for _, s := range suites {
+		if s.Status > status {
+			status = s.Status
+		}
+		for _, test := range s.Tests {
+			total++
+			if test.Result.Status == ht.Pass {
+				passed++
+			}
+		}
+		for _, a := range s.Annotations() {
+			a.File = c.relative(a.File)
+			annotations = append(annotations, a)
+		}
+	}
+	if len(problems) > 0 && status < ht.Fail {
+		status = ht.Fail
+	}
+	summary := fmt.Sprintf("%s: %d of %d tests passed", status, passed, total)
+	for _, p := range problems {
+		summary += "; " + p
+	}
+
+	switch c.Provider {
+	case "github":
+		return c.github(status, summary, annotations)
+	case "gitlab":
+		return c.gitlab(status, summary, annotations)
+	}
+	return fmt.Errorf("unknown CI provider %q", c.Provider)
+}
+
+func (c *CIReporter) name() string {
+	if c.Name == "" {
+		return "ht"
+	}
+	return c.Name
+}
+
+// relative returns filename relative to c.Root in slash notation.
+func (c *CIReporter) relative(filename string) string {
+	if c.Root != "" {
+		abs, err := filepath.Abs(filename)
+		if err == nil {
+			if rel, err := filepath.Rel(c.Root, abs); err == nil && !strings.HasPrefix(rel, "..") {
+				filename = rel
+			}
+		}
+	}
+	return filepath.ToSlash(filename)
+}
+
+// maxCheckRunAnnotations is the maximum number of annotations GitHub
+// accepts in one request.
+const maxCheckRunAnnotations = 50
+
+func (c *CIReporter) github(status ht.Status, summary string, annotations []Annotation) error {
+	conclusion := "success"
+	if status > ht.Pass {
+		conclusion = "failure"
+	}
+	type ghAnnotation struct {
+		Path      string `json:"path"`
+		StartLine int    `json:"start_line"`
+		EndLine   int    `json:"end_line"`
+		Level     string `json:"annotation_level"`
+		Title     string `json:"title"`
+		Message   string `json:"message"`
+	}
+	chunk := func(annotations []Annotation) []ghAnnotation {
+		gh := []ghAnnotation{}
+		for _, a := range annotations {
+			gh = append(gh, ghAnnotation{a.File, a.Line, a.Line, "failure", a.Title, a.Message})
+		}
+		return gh
+	}
+	n := len(annotations)
+	if n > maxCheckRunAnnotations {
+		n = maxCheckRunAnnotations
+	}
+	output := map[string]interface{}{
+		"title":       summary,
+		"summary":     summary,
+		"annotations": chunk(annotations[:n]),
+	}
+	run := map[string]interface{}{
+		"name":       c.name(),
+		"head_sha":   c.Commit,
+		"status":     "completed",
+		"conclusion": conclusion,
+		"output":     output,
+	}
+	if c.TargetURL != "" {
+		run["details_url"] = c.TargetURL
+	}
+	created := struct{ ID int64 }{}
+	err := c.call("POST", "/repos/"+c.Project+"/check-runs", run, &created)
+	if err != nil {
+		return err
+	}
+
+	// Further annotations must be added by updating the check run.
+	for rest := annotations[n:]; len(rest) > 0; rest = rest[n:] {
+		n = len(rest)
+		if n > maxCheckRunAnnotations {
+			n = maxCheckRunAnnotations
+		}
+		output["annotations"] = chunk(rest[:n])
+		err := c.call("PATCH", fmt.Sprintf("/repos/%s/check-runs/%d", c.Project, created.ID),
+			map[string]interface{}{"output": output}, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *CIReporter) gitlab(status ht.Status, summary string, annotations []Annotation) error {
+	project := "/projects/" + url.PathEscape(c.Project)
+	state := "success"
+	if status > ht.Pass {
+		state = "failed"
+	}
+	commitStatus := map[string]string{
+		"state":       state,
+		"name":        c.name(),
+		"description": summary,
+	}
+	if c.TargetURL != "" {
+		commitStatus["target_url"] = c.TargetURL
+	}
+	err := c.call("POST", project+"/statuses/"+c.Commit, commitStatus, nil)
+	if err != nil {
+		return err
+	}
+
+	max := c.MaxComments
+	if max == 0 {
+		max = 10
+	}
+	for i, a := range annotations {
+		if i == max {
+			break
+		}
+		comment := map[string]interface{}{
+			"note":      fmt.Sprintf("**%s**\n\n```\n%s\n```", a.Title, a.Message),
+			"path":      a.File,
+			"line":      a.Line,
+			"line_type": "new",
+		}
+		err := c.call("POST", project+"/repository/commits/"+c.Commit+"/comments", comment, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// call the API endpoint with the JSON encoded body and decode the JSON
+// response into result if non-nil.
+func (c *CIReporter) call(method, endpoint string, body, result interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.API, "/")+endpoint,
+		bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.Provider == "github" {
+		req.Header.Set("Accept", "application/vnd.github+json")
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	} else {
+		req.Header.Set("PRIVATE-TOKEN", c.Token)
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	answer, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s %s: %s %s", method, req.URL.Path, resp.Status,
+			bytes.TrimSpace(answer))
+	}
+	if result != nil {
+		return json.Unmarshal(answer, result)
+	}
+	return nil
+}
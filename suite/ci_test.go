@@ -0,0 +1,204 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vdobler/ht/ht"
+)
+
+var ciFS = `
+# ci.suite
+{
+    Name: "CI"
+    Main: [
+        {File: "good.ht"}
+        {File: "bad.ht"}
+        {Test: {Request: {URL: "{{URL}}/x"}, Checks: [{Check: "StatusCode", Expect: 404}]}}
+    ]
+}
+
+# good.ht
+{
+    Name: "Good"
+    Request: {URL: "{{URL}}/good"}
+    Checks: [ {Check: "StatusCode", Expect: 200} ]
+}
+
+# bad.ht
+{
+    Name: "Bad"
+    Mixin: ["checks.mix"]
+    Request: {URL: "{{URL}}/bad"}
+}
+
+# checks.mix
+{
+    Checks: [
+        {Check: "StatusCode", Expect: 200}
+        {
+            Check: "Body"
+            Contains: "missing"
+        }
+    ]
+}
+`
+
+func executeCISuite(t *testing.T) *Suite {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "Hello")
+	}))
+	defer ts.Close()
+	fs, err := NewFileSystem(ciFS)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	rs, err := LoadRawSuite("ci.suite", fs)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	s := rs.Execute(map[string]string{"URL": ts.URL}, nil, logger())
+	if s.Status != ht.Fail {
+		t.Fatalf("Got status %s", s.Status)
+	}
+	return s
+}
+
+func TestAnnotations(t *testing.T) {
+	s := executeCISuite(t)
+	got := s.Annotations()
+	if len(got) != 2 {
+		t.Fatalf("Got %d annotations: %v", len(got), got)
+	}
+	if a := got[0]; a.File != "checks.mix" || a.Line != 5 ||
+		a.Title != "Fail: Main-02 Bad" || !strings.Contains(a.Message, "missing") {
+		t.Errorf("Got %+v", a)
+	}
+	if a := got[1]; a.File != "ci.suite" || a.Line != 1 {
+		t.Errorf("Got %+v", a)
+	}
+}
+
+func TestLocateFailure(t *testing.T) {
+	fs, err := NewFileSystem(`
+# test.ht
+{
+    Mixin: ["more.mix"]
+    Checks: [
+        {Check: "StatusCode", Expect: 200}
+    ]
+}
+
+# more.mix
+{
+    // StatusCode again
+    Checks: [
+        {Check: "Body", Contains: "StatusCode"}
+        {Check: "StatusCode", Expect: 200}
+    ]
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt, err := LoadRawTest("test.ht", fs)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	for i, tc := range []struct {
+		failed int
+		file   string
+		line   int
+	}{
+		{0, "test.ht", 4},
+		{1, "more.mix", 4},
+		{2, "more.mix", 5},
+		{3, "test.ht", 1},
+	} {
+		test := &ht.Test{}
+		for j := 0; j < 3; j++ {
+			cr := ht.CheckResult{Name: "StatusCode", Status: ht.Pass}
+			if j == tc.failed {
+				cr.Status = ht.Fail
+			}
+			test.Result.CheckResults = append(test.Result.CheckResults, cr)
+		}
+		file, line := rt.locateFailure(test)
+		if file != tc.file || line != tc.line {
+			t.Errorf("%d. Got %s:%d, want %s:%d", i, file, line, tc.file, tc.line)
+		}
+	}
+}
+
+func TestCIReporter(t *testing.T) {
+	s := executeCISuite(t)
+
+	var calls []string
+	var bodies []map[string]interface{}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.EscapedPath()+" "+
+			r.Header.Get("Authorization")+r.Header.Get("PRIVATE-TOKEN"))
+		body, _ := ioutil.ReadAll(r.Body)
+		b := map[string]interface{}{}
+		json.Unmarshal(body, &b)
+		bodies = append(bodies, b)
+		fmt.Fprint(w, `{"id": 42}`)
+	}))
+	defer api.Close()
+
+	gh := &CIReporter{Provider: "github", API: api.URL, Project: "acme/shop",
+		Commit: "abc123", Token: "secret"}
+	if err := gh.Report([]*Suite{s}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(calls) != 1 || calls[0] != "POST /repos/acme/shop/check-runs Bearer secret" {
+		t.Fatalf("Got calls %v", calls)
+	}
+	if bodies[0]["conclusion"] != "failure" || bodies[0]["head_sha"] != "abc123" {
+		t.Errorf("Got %v", bodies[0])
+	}
+	output := bodies[0]["output"].(map[string]interface{})
+	if output["title"] != "Fail: 1 of 3 tests passed" ||
+		len(output["annotations"].([]interface{})) != 2 {
+		t.Errorf("Got output %v", output)
+	}
+
+	calls, bodies = nil, nil
+	gl := &CIReporter{Provider: "gitlab", API: api.URL + "/api/v4", Project: "acme/shop",
+		Commit: "abc123", Token: "secret", MaxComments: 1}
+	if err := gl.Report([]*Suite{s}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	want := []string{
+		"POST /api/v4/projects/acme%2Fshop/statuses/abc123 secret",
+		"POST /api/v4/projects/acme%2Fshop/repository/commits/abc123/comments secret",
+	}
+	if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("Got calls %v", calls)
+	}
+	if bodies[0]["state"] != "failed" || bodies[1]["path"] != "checks.mix" ||
+		bodies[1]["line"] != 5.0 {
+		t.Errorf("Got %v", bodies)
+	}
+
+	// Problems fail an otherwise passing run.
+	calls, bodies = nil, nil
+	passed := &Suite{Name: "Passed", Status: ht.Pass}
+	if err := gh.Report([]*Suite{passed}, "coverage too low"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	output = bodies[0]["output"].(map[string]interface{})
+	if bodies[0]["conclusion"] != "failure" ||
+		output["title"] != "Fail: 0 of 0 tests passed; coverage too low" {
+		t.Errorf("Got %v", bodies[0])
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ----------------------------------------------------------------------------
+//   Comparing runs
+
+// LoadResults reads the SuiteResults stored in filename which is either a
+// result.json file or a folder which is searched recursively for
+// result.json files like the output folder of 'ht exec'.
+func LoadResults(filename string) ([]*SuiteResult, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{filename}
+	if info.IsDir() {
+		files = files[:0]
+		err := filepath.Walk(filename, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && info.Name() == "result.json" {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no result.json found in %s", filename)
+		}
+	}
+
+	results := []*SuiteResult{}
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		result := &SuiteResult{}
+		if err := json.Unmarshal(data, result); err != nil {
+			return nil, fmt.Errorf("%s: %s", f, err)
+		}
+		if result.Version != ResultVersion {
+			return nil, fmt.Errorf("%s: unsupported result version %d",
+				f, result.Version)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// TestChange describes how one test differs between two runs.
+type TestChange struct {
+	Suite string // Suite is the name of the suite.
+	SeqNo string // SeqNo of the test like "Main-03".
+	Name  string // Name of the test.
+
+	OldStatus string `json:",omitempty"` // OldStatus is empty for added tests.
+	NewStatus string `json:",omitempty"` // NewStatus is empty for removed tests.
+
+	OldDurationMS int64
+	NewDurationMS int64
+
+	// DeltaMS is NewDurationMS - OldDurationMS and Delta the change
+	// relative to OldDurationMS, e.g. 0.5 for 50% slower.
+	DeltaMS int64
+	Delta   float64
+
+	// NewFailure is set if the test did not pass in the new run but
+	// passed in the old run or was not part of the old run.
+	NewFailure bool `json:",omitempty"`
+}
+
+// RunDiff is the structured difference between an old and a new run.
+type RunDiff struct {
+	Changed []TestChange // Changed lists the tests which changed their status.
+	Latency []TestChange // Latency lists tests whose duration changed significantly.
+	Added   []TestChange // Added lists tests not part of the old run.
+	Removed []TestChange // Removed lists tests not part of the new run.
+
+	Unchanged   int // Unchanged counts the tests with the same status.
+	NewFailures int // NewFailures counts the tests with NewFailure set.
+}
+
+// CompareResults compares the run after to the run before. Tests are
+// identified by suite name, sequence number and test name. A change in
+// duration is considered significant if it exceeds minDeltaMS milliseconds
+// and the fraction threshold of the old duration. Durations are compared
+// for tests which passed in both runs only.
+func CompareResults(before, after []*SuiteResult, threshold float64, minDeltaMS int64) *RunDiff {
+	type key struct{ suite, seqNo, name string }
+	index := func(results []*SuiteResult) (map[key]TestResult, []key) {
+		tests := map[key]TestResult{}
+		keys := []key{}
+		for _, s := range results {
+			for _, tr := range s.Tests {
+				k := key{s.Name, tr.SeqNo, tr.Name}
+				if _, ok := tests[k]; !ok {
+					keys = append(keys, k)
+				}
+				tests[k] = tr
+			}
+		}
+		return tests, keys
+	}
+	oldTests, oldKeys := index(before)
+	newTests, newKeys := index(after)
+
+	diff := &RunDiff{
+		Changed: []TestChange{},
+		Latency: []TestChange{},
+		Added:   []TestChange{},
+		Removed: []TestChange{},
+	}
+	for _, k := range newKeys {
+		nt := newTests[k]
+		change := TestChange{
+			Suite:         k.suite,
+			SeqNo:         k.seqNo,
+			Name:          k.name,
+			NewStatus:     nt.Status,
+			NewDurationMS: nt.DurationMS,
+		}
+		ot, ok := oldTests[k]
+		if !ok {
+			change.NewFailure = failedStatus(nt.Status)
+			diff.Added = append(diff.Added, change)
+		} else {
+			change.OldStatus = ot.Status
+			change.OldDurationMS = ot.DurationMS
+			change.DeltaMS = nt.DurationMS - ot.DurationMS
+			if ot.DurationMS > 0 {
+				change.Delta = float64(change.DeltaMS) / float64(ot.DurationMS)
+			}
+			if ot.Status != nt.Status {
+				change.NewFailure = ot.Status == "Pass" && failedStatus(nt.Status)
+				diff.Changed = append(diff.Changed, change)
+			} else {
+				diff.Unchanged++
+				if nt.Status == "Pass" && abs64(change.DeltaMS) > minDeltaMS &&
+					(ot.DurationMS == 0 || change.Delta > threshold || change.Delta < -threshold) {
+					diff.Latency = append(diff.Latency, change)
+				}
+			}
+		}
+		if change.NewFailure {
+			diff.NewFailures++
+		}
+	}
+	for _, k := range oldKeys {
+		if _, ok := newTests[k]; ok {
+			continue
+		}
+		ot := oldTests[k]
+		diff.Removed = append(diff.Removed, TestChange{
+			Suite:         k.suite,
+			SeqNo:         k.seqNo,
+			Name:          k.name,
+			OldStatus:     ot.Status,
+			OldDurationMS: ot.DurationMS,
+		})
+	}
+
+	// Biggest slowdowns first.
+	sort.SliceStable(diff.Latency, func(i, j int) bool {
+		return diff.Latency[i].DeltaMS > diff.Latency[j].DeltaMS
+	})
+	return diff
+}
+
+// failedStatus reports whether status is one of Fail, Error or Bogus.
+func failedStatus(status string) bool {
+	return status == "Fail" || status == "Error" || status == "Bogus"
+}
+
+func abs64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,92 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompareResults(t *testing.T) {
+	run := func(tests ...TestResult) []*SuiteResult {
+		return []*SuiteResult{{Version: ResultVersion, Name: "Shop", Tests: tests}}
+	}
+	test := func(seqNo, name, status string, ms int64) TestResult {
+		return TestResult{SeqNo: seqNo, Name: name, Status: status, DurationMS: ms}
+	}
+	before := run(
+		test("Main-01", "Home", "Pass", 100),
+		test("Main-02", "Login", "Pass", 100),
+		test("Main-03", "Search", "Fail", 100),
+		test("Main-04", "Slow", "Pass", 1000),
+		test("Main-05", "Jitter", "Pass", 10),
+		test("Main-06", "Gone", "Pass", 100),
+	)
+	after := run(
+		test("Main-01", "Home", "Pass", 100),
+		test("Main-02", "Login", "Error", 100),
+		test("Main-03", "Search", "Pass", 100),
+		test("Main-04", "Slow", "Pass", 2000),
+		test("Main-05", "Jitter", "Pass", 50),
+		test("Main-07", "New", "Fail", 100),
+	)
+
+	diff := CompareResults(before, after, 0.5, 100)
+	if diff.NewFailures != 2 || diff.Unchanged != 3 {
+		t.Errorf("Got %d new failures, %d unchanged", diff.NewFailures, diff.Unchanged)
+	}
+	if len(diff.Changed) != 2 ||
+		diff.Changed[0].Name != "Login" || !diff.Changed[0].NewFailure ||
+		diff.Changed[1].Name != "Search" || diff.Changed[1].NewFailure {
+		t.Errorf("Got changed %+v", diff.Changed)
+	}
+	if len(diff.Latency) != 1 || diff.Latency[0].Name != "Slow" ||
+		diff.Latency[0].DeltaMS != 1000 || diff.Latency[0].Delta != 1 {
+		t.Errorf("Got latency %+v", diff.Latency)
+	}
+	if len(diff.Added) != 1 || diff.Added[0].Name != "New" || !diff.Added[0].NewFailure {
+		t.Errorf("Got added %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Name != "Gone" ||
+		diff.Removed[0].OldStatus != "Pass" || diff.Removed[0].NewStatus != "" {
+		t.Errorf("Got removed %+v", diff.Removed)
+	}
+}
+
+func TestLoadResults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compare")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"Shop", "Blog"} {
+		sub := filepath.Join(dir, name)
+		if err := os.Mkdir(sub, 0777); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		s := &Suite{Name: name}
+		if err := JSONReporter.Report(sub, s); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+
+	results, err := LoadResults(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(results) != 2 || results[0].Name != "Blog" || results[1].Name != "Shop" {
+		t.Errorf("Got %+v", results)
+	}
+	results, err = LoadResults(filepath.Join(dir, "Shop", "result.json"))
+	if err != nil || len(results) != 1 {
+		t.Errorf("Got %v, %v", results, err)
+	}
+	if _, err := LoadResults(filepath.Join(dir, "Shop", "missing.json")); err == nil {
+		t.Errorf("Missing error for missing file")
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/vdobler/ht/internal/hjson"
+)
+
+// ----------------------------------------------------------------------------
+// Data pools
+
+// A DataPool is a set of records (e.g. user credentials or order ids) which
+// are handed out exclusively to the threads of a throughput test scenario:
+// Before each iteration of the Main tests a thread checks out one record,
+// the fields of the record become variables of the iteration and after the
+// iteration the record is returned to the pool. No two threads use the same
+// record at the same time.
+//
+// A thread waits until a record is available; the number of threads of a
+// scenario with a DataPool is therefore limited to the number of records.
+type DataPool struct {
+	// Name of the data pool, typically the filename.
+	Name string
+
+	records []map[string]string
+	free    chan int
+}
+
+// NewDataPool returns a data pool with the given records.
+func NewDataPool(name string, records []map[string]string) *DataPool {
+	dp := &DataPool{
+		Name:    name,
+		records: records,
+		free:    make(chan int, len(records)),
+	}
+	for i := range records {
+		dp.free <- i
+	}
+	return dp
+}
+
+// ParseDataPool parses data as a CSV file (if name ends in ".csv") or as a
+// (h)json array of objects. The first line of a CSV file contains the
+// field names.
+func ParseDataPool(name string, data string) (*DataPool, error) {
+	var records []map[string]string
+	var err error
+	if strings.HasSuffix(strings.ToLower(name), ".csv") {
+		records, err = parseCSVRecords(data)
+	} else {
+		records, err = parseJSONRecords(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("data pool %s: %s", name, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("data pool %s: no records", name)
+	}
+	return NewDataPool(name, records), nil
+}
+
+// LoadDataPool reads the data pool filename from fs. Unlike LoadFile it
+// does not require the file to be valid hjson.
+func LoadDataPool(filename string, fs FileSystem) (*DataPool, error) {
+	var data string
+	if len(fs) == 0 && isRemote(filename) {
+		buf, err := loadRemote(filename)
+		if err != nil {
+			return nil, err
+		}
+		data = string(buf)
+	} else if len(fs) == 0 {
+		filename = path.Clean(filepath.ToSlash(filename))
+		buf, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		data = string(buf)
+	} else {
+		f, err := fs.Load(filename)
+		if err != nil {
+			return nil, err
+		}
+		data = f.Data
+	}
+	return ParseDataPool(filename, data)
+}
+
+func parseCSVRecords(data string) ([]map[string]string, error) {
+	all, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(all) == 0 {
+		return nil, nil
+	}
+	header := all[0]
+	records := make([]map[string]string, 0, len(all)-1)
+	for _, line := range all[1:] {
+		record := make(map[string]string, len(header))
+		for i, field := range header {
+			record[strings.TrimSpace(field)] = line[i]
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func parseJSONRecords(data string) ([]map[string]string, error) {
+	var soup interface{}
+	if err := hjson.Unmarshal([]byte(data), &soup); err != nil {
+		return nil, err
+	}
+	raw, ok := soup.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("not an array of objects")
+	}
+	records := make([]map[string]string, len(raw))
+	for i, x := range raw {
+		r, ok := x.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("record %d is not an object", i+1)
+		}
+		records[i] = make(map[string]string, len(r))
+		for k, v := range r {
+			records[i][k] = fmt.Sprint(v)
+		}
+	}
+	return records, nil
+}
+
+// Len returns the number of records in dp.
+func (dp *DataPool) Len() int { return len(dp.records) }
+
+// Checkout a free record from dp, waiting until one is available. The
+// returned handle must be passed to Return once the record is no longer
+// used. Checkout returns false if stop was closed while waiting.
+func (dp *DataPool) Checkout(stop chan bool) (record map[string]string, handle int, ok bool) {
+	select {
+	case i := <-dp.free:
+		return dp.records[i], i, true
+	case <-stop:
+		return nil, -1, false
+	}
+}
+
+// Return the record with the given handle to dp.
+func (dp *DataPool) Return(handle int) {
+	dp.free <- handle
+}
@@ -0,0 +1,114 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseDataPool(t *testing.T) {
+	for _, tc := range []struct {
+		name, data string
+	}{
+		{"users.csv", "USER,PASS\nalice,secret1\nbob,secret2\n"},
+		{"users.json", `[{USER: "alice", PASS: "secret1"}, {USER: "bob", PASS: "secret2"}]`},
+	} {
+		dp, err := ParseDataPool(tc.name, tc.data)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", tc.name, err)
+			continue
+		}
+		if dp.Len() != 2 {
+			t.Errorf("%s: got %d records", tc.name, dp.Len())
+			continue
+		}
+		if r := dp.records[1]; r["USER"] != "bob" || r["PASS"] != "secret2" {
+			t.Errorf("%s: got %v", tc.name, r)
+		}
+	}
+
+	if _, err := ParseDataPool("empty.csv", "USER,PASS\n"); err == nil {
+		t.Errorf("Missing error for empty data pool")
+	}
+}
+
+func TestDataPoolCheckout(t *testing.T) {
+	dp := NewDataPool("test", []map[string]string{{"N": "1"}, {"N": "2"}})
+	stop := make(chan bool)
+
+	var mu sync.Mutex
+	inUse := map[string]bool{}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < 20; k++ {
+				record, h, ok := dp.Checkout(stop)
+				if !ok {
+					t.Errorf("Checkout failed")
+					return
+				}
+				mu.Lock()
+				if inUse[record["N"]] {
+					t.Errorf("Record %s checked out twice", record["N"])
+				}
+				inUse[record["N"]] = true
+				mu.Unlock()
+				time.Sleep(time.Millisecond)
+				mu.Lock()
+				inUse[record["N"]] = false
+				mu.Unlock()
+				dp.Return(h)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Exhausted pool: Checkout must return once stop is closed.
+	dp.Checkout(stop)
+	dp.Checkout(stop)
+	close(stop)
+	if _, _, ok := dp.Checkout(stop); ok {
+		t.Errorf("Checkout from exhausted pool succeeded")
+	}
+}
+
+func TestRawScenarioDataPool(t *testing.T) {
+	txt := `
+# pool.load
+{
+    Scenarios: [
+        { File: "s.suite", Percentage: 100, MaxThreads: 10, DataPool: "users.csv" }
+    ]
+}
+
+# s.suite
+{
+    Main: [ {File: "t.ht"} ]
+}
+
+# t.ht
+{
+    Request: { URL: "http://localhost/{{USER}}" }
+}
+
+# users.csv
+USER,PASS
+alice,secret1
+bob,secret2
+`
+
+	raw, err := parseRawLoadtest("pool.load", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	scenarios := raw.ToScenario(nil)
+	if dp := scenarios[0].DataPool; dp == nil || dp.Len() != 2 {
+		t.Errorf("Got data pool %v", dp)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/vdobler/ht/errorlist"
+	"github.com/vdobler/ht/populate"
+	"github.com/vdobler/ht/scope"
+)
+
+// ----------------------------------------------------------------------------
+// Variable declarations
+
+// VarTypes lists the types a variable can be declared with.
+var VarTypes = []string{"string", "int", "float", "bool", "duration", "url"}
+
+// VarDecl declares the type and constraints of a variable a suite expects.
+// It can be written as just the type like "duration" or as an object like
+// { required: true, pattern: "^https?://" }.
+type VarDecl struct {
+	// Type of the variable, one of VarTypes. Empty means "string".
+	Type string
+
+	// Required variables must be set to a non-empty value.
+	Required bool
+
+	// Pattern is a regular expression the value must match.
+	Pattern string
+
+	// Description of the variable's purpose.
+	Description string
+}
+
+// Populate implements populate.Populator: A VarDecl can be populated from
+// a string (the type) or from an object with case-insensitive keys.
+func (d *VarDecl) Populate(src interface{}) error {
+	if typ, ok := src.(string); ok {
+		*d = VarDecl{Type: typ}
+	} else {
+		type plain VarDecl
+		var p plain
+		if err := (populate.Options{FoldCase: true}).Populate(&p, src); err != nil {
+			return err
+		}
+		*d = VarDecl(p)
+	}
+	if !isVarType(d.Type) {
+		return fmt.Errorf("unknown variable type %q, use one of %v",
+			d.Type, VarTypes)
+	}
+	if _, err := regexp.Compile(d.Pattern); err != nil {
+		return fmt.Errorf("bad pattern: %s", err)
+	}
+	return nil
+}
+
+// PopulateSchema implements populate.Schemer.
+func (d *VarDecl) PopulateSchema(g *populate.SchemaGenerator) map[string]interface{} {
+	types := make([]interface{}, len(VarTypes))
+	for i, t := range VarTypes {
+		types[i] = t
+	}
+	return map[string]interface{}{
+		"anyOf": []interface{}{
+			map[string]interface{}{"type": "string", "enum": types},
+			map[string]interface{}{"type": "object"},
+		},
+	}
+}
+
+func isVarType(typ string) bool {
+	if typ == "" {
+		return true
+	}
+	for _, t := range VarTypes {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// Check reports whether value (which is set iff defined) satisfies d.
+func (d VarDecl) Check(value string, defined bool) error {
+	if !defined || value == "" {
+		if d.Required {
+			return fmt.Errorf("required but not set")
+		}
+		return nil
+	}
+
+	var err error
+	switch d.Type {
+	case "int":
+		_, err = strconv.Atoi(value)
+	case "float":
+		_, err = strconv.ParseFloat(value, 64)
+	case "bool":
+		_, err = strconv.ParseBool(value)
+	case "duration":
+		_, err = time.ParseDuration(value)
+	case "url":
+		var u *url.URL
+		u, err = url.Parse(value)
+		if err == nil && (u.Scheme == "" || u.Host == "") {
+			err = fmt.Errorf("not an absolute URL")
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("value %q is not of type %s", value, d.Type)
+	}
+
+	if d.Pattern != "" {
+		re := regexp.MustCompile(d.Pattern) // checked in Populate
+		if !re.MatchString(value) {
+			return fmt.Errorf("value %q does not match pattern %q", value, d.Pattern)
+		}
+	}
+	return nil
+}
+
+// checkDeclarations checks the variables in vars against the declarations
+// of rs. All violations are reported.
+func (rs *RawSuite) checkDeclarations(vars scope.Variables) error {
+	names := make([]string, 0, len(rs.Declarations))
+	for name := range rs.Declarations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	el := errorlist.List{}
+	for _, name := range names {
+		decl := rs.Declarations[name]
+		value, defined := vars[name]
+		if err := decl.Check(value, defined); err != nil {
+			msg := fmt.Sprintf("variable %s: %s", name, err)
+			if decl.Description != "" {
+				msg += " (" + decl.Description + ")"
+			}
+			el = append(el, errorlist.Error{
+				Code:     "variable.declaration",
+				Category: errorlist.Validation,
+				Field:    "Variables." + name,
+				Err:      fmt.Errorf("%s", msg),
+			})
+		}
+	}
+	if len(el) > 0 {
+		return el
+	}
+	return nil
+}
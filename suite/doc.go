@@ -69,4 +69,276 @@
 // in the global scope (e.g. by running cmd/ht with -D B=localhost) then these
 // values will dominate any default from the various Variables sections.
 //
+// Mixins share the test scope but parameters can be passed to a mixin
+// explicitly. These override the test scope inside this mixin only, so the
+// same mixin can be included twice with different values:
+//     {
+//         Mixin: [
+//             { File: "login.mix", Namespace: "admin", Variables: { USER: "root" } }
+//             { File: "login.mix", Namespace: "guest", Variables: { USER: "anon" } }
+//         ]
+//     }
+// The variables as seen by a mixin are available under its namespace too,
+// e.g. as {{admin.USER}} and {{guest.USER}} in the test and in all mixins.
+// The namespace defaults to the mixin's filename without extension.
+//
+// Default variables of a suite can be read from variable files (HJSON
+// objects with string values) listed in VariablesFrom; later files override
+// earlier ones and the suite's Variables override all files. A suite may
+// define named profiles:
+//     {
+//         Variables: { PORT: "8080" }
+//         VariablesFrom: [ "common.vars" ]
+//         Profiles: {
+//             staging: { VariablesFrom: [ "staging.vars" ] }
+//             prod:    { Variables: { HOST: "www.example.org" } }
+//         }
+//     }
+// The variables of the profile selected via SelectProfile (the -profile
+// flag of cmd/ht) are merged into the global scope: They override the
+// suite defaults but not the global variables from the command line.
+//
+// A suite may declare the variables it expects with their type (one of
+// string, int, float, bool, duration or url) and constraints:
+//     {
+//         Declarations: {
+//             HOST:    { Required: true, Pattern: "^https?://", Type: "url" }
+//             TIMEOUT: "duration"
+//         }
+//     }
+// A suite whose variables violate the declarations is not executed but
+// fails immediately with a Bogus status listing all violations.
+//
+// Variable files may be encrypted (see "ht vault encrypt") so that
+// credentials can be committed alongside the suites. They are decrypted
+// while loading with the passphrase from scope.VaultPassphrase, the
+// environment variable HT_VAULT_PASSPHRASE, scope.VaultKeyFile or the output
+// of scope.VaultKeyCommand; all values from an encrypted file are secrets.
+// Variables of suites, profiles, variable files, tests and load tests may
+// reference secrets like "@secret:env:PASSWORD" (see scope.SecretPrefix)
+// which are resolved while loading; files loaded from a URL or git must not
+// reference secrets.
+//
+// Variables extracted from a passing test via its DataExtraction are written
+// back into the suite scope. This can be restricted: A suite element may list
+// the variables the test may extract in its Exports and a suite may list
+// variables in ReadOnly which must not be extracted by any test:
+//     {
+//         Main: [
+//             { File: "login.ht", Exports: [ "SESSION" ] }
+//         ]
+//         ReadOnly: [ "HOST" ]
+//     }
+// A test violating these restrictions is Bogus and is not executed.
+//
+// Besides variables substitutions may call functions:
+//     {{UUID}}  {{RANDOM hex 16}}  {{BASE64 .user:.pass}}
+//     {{SHA256 {{BODY}}}}  {{ENV HOME}}  {{FILE ./payload.json}}
+//     {{NOW +24h 2006-01-02}}
+// Arguments are whitespace separated, may contain nested substitutions and
+// .name references the value of variable name. See scope.FuncRegistry for
+// the list of functions; additional ones can be registered with
+// scope.RegisterFunc.
+// A pipeline like {{NAME | lower | urlquery}} passes the value of NAME (or
+// the result of a function call) as last argument to the following
+// functions. Files loaded from an URL or git cannot call ENV, FILE or the
+// credential providers (see scope.LocalFuncs).
+//
+// NOW accepts an anchor time instead of the wall clock (e.g. from a variable
+// as in @.START), a time zone, calendar offsets and truncations which are
+// applied in order, e.g. the last business day of next month in Zurich:
+//     {{NOW tz:Europe/Zurich ^month +2M -1d +1bd -1bd 2006-01-02}}
+// Offsets are Go durations or numbers with one of the units d, w, M, y and
+// bd (business days); truncations are ^hour, ^day, ^week, ^month and ^year.
+//
+// Credentials for Authorization headers are provided by the functions
+// KRB_TOKEN, GCP_ID_TOKEN and AZURE_TOKEN, e.g.
+//     Header: { Authorization: "Bearer {{GCP_ID_TOKEN https://api.example.org}}" }
+// They are obtained on first use only, cached until they expire and masked
+// in all output. Further sources can be added with
+// scope.RegisterCredentialProvider.
+//
+// Arithmetic expressions are evaluated with the function "=", e.g.
+// PORT: "{{= {{BASEPORT}} + 10}}". Integer overflow and division by zero
+// leave the expression unreplaced.
+//
+//
+// Hermetic Mode
+//
+// A suite may restrict the hosts its tests are allowed to send HTTP requests
+// to by listing them in AllowedHosts:
+//     {
+//         AllowedHosts: [ "localhost", "*.test.example.org", "10.0.0.0/8" ]
+//         ...
+//     }
+// Requests (and followed redirects) to any other host are not sent at all,
+// the test's status is Error. This prevents a mis-substituted {{HOST}}
+// variable from hitting e.g. a production system during local runs.
+// Entries may be hostnames, wildcards of the form "*.domain", IP addresses
+// or CIDR ranges; variables are substituted in the entries.
+//
+//
+// Cookies
+//
+// A suite with KeepCookies set shares one cookie jar between all its tests.
+// The jar can be seeded from a file before the first test and saved to a
+// file after the last test, e.g. to reuse a login session in a later
+// invocation of ht or with curl (curl -b cookies.txt -c cookies.txt):
+//     {
+//         KeepCookies: true
+//         LoadCookies: "session.cookies"
+//         SaveCookies: "session.cookies"
+//     }
+// Relative filenames are relative to the suite and variables are substituted.
+// Files ending in ".json" are written as JSON, all others in the Netscape
+// cookies.txt format; both formats are recognised while loading. A missing
+// LoadCookies file is not an error.
+//
+// A CookiePolicy restricts which cookies end up in the jar: Cookies for
+// domains in Reject (or not in Allow if Allow is non-empty) are dropped and
+// if FirstParty is non-empty cookies set by hosts of other sites are
+// treated as third-party cookies and dropped too:
+//     {
+//         KeepCookies: true
+//         CookiePolicy: {
+//             FirstParty: [ "{{HOST}}" ]
+//             Reject: [ "ads.example.org" ]
+//         }
+//     }
+// The policy applies to the cookies loaded from LoadCookies too. The
+// CookieJar check asserts the state of the jar after a test.
+//
+//
+// Connections
+//
+// By default all tests share the HTTP connections of ht.Transport. A
+// ClientPool gives the tests of a suite their own connections which
+// allows to model a specific client:
+//     {
+//         ClientPool: {
+//             MaxIdleConnsPerHost: 4
+//             MaxConnsPerHost: 8
+//             DisableKeepAlives: false
+//             HTTP2: true
+//         }
+//     }
+// The idle connections are closed after the suite. The SuiteResult reports
+// the number of requests and of new and reused connections; each
+// TestResult those of its request. A server which suddenly stops reusing
+// connections shows up as a jump in new connections.
+//
+//
+// Wire Logs
+//
+// A WireLog in the suite dumps the full requests and responses of all
+// tests which do not have their own WireLog to one file per execution of
+// a test, numbered through during each run:
+//     {
+//         WireLog: {
+//             Dir: "wire"         // relative to the suite
+//             MaxBody: 4096       // bytes dumped of each body
+//             MaxFiles: 200       // per run, e.g. during load tests
+//             Redact: [ "X-Api-Key" ]
+//         }
+//     }
+// Authorization, Proxy-Authorization, Cookie and Set-Cookie headers and
+// the listed ones are redacted and secret variables are masked.
+//
+//
+// Logging
+//
+// The log messages of a suite, its tests and their mocks are printed to
+// the suite's Log. A structured logging.Logger set with RawSuite.SetLogger
+// receives them instead as leveled records with the fields suite, test
+// and seqno, e.g. to write JSON lines or to hand them to zap or log/slog.
+//
+//
+// Cancellation
+//
+// RawSuite.ExecuteWith cancels the execution once the Context of its
+// ExecOptions is done, a Timeout in the suite cancels it after the given
+// duration:
+//     {
+//         Timeout: "5m"
+//     }
+// The running test is aborted (in-flight HTTP requests, bash scripts and
+// SQL queries are canceled) with status Error and the remaining Setup and
+// Main tests are skipped. The Teardown tests are still executed if any
+// Setup or Main test was run, but they get canceled once the
+// TeardownGracePeriod has elapsed too. A canceled suite has status Error
+// with the error code "suite.canceled".
+//
+//
+// Frozen Time
+//
+// Date-sensitive requests and mock responses change from run to run. The
+// FreezeNow option of a suite fixes the time used by all {{NOW}}
+// substitutions and all SetTimestamp extractors: "start" freezes it to the
+// start of the execution of the suite, other values are a fixed time:
+//     {
+//         FreezeNow: "2017-03-24T15:30:00+01:00"
+//     }
+// The frozen time is stored in the variable FROZEN_NOW which can also be
+// set directly, e.g. with -D FROZEN_NOW=2017-03-24 to use the same instant
+// for all suites executed.
+//
+//
+// Checkpoints
+//
+// The Checkpoint in ExecOptions records the outcome of each test in a
+// file. If an execution gets interrupted it can be resumed from this file:
+// Main tests which passed already are Skipped, the variables they extracted
+// and the cookies recorded last are restored. Setup and Teardown tests are
+// executed again. Secret values are not recorded, so tests which extracted
+// a secret are executed again too.
+//
+//
+// Including Files
+//
+// All files loaded through a FileSystem may include other files. A string
+// value of the form "!include <filename>" is replaced by the content of
+// the named file. If such a string is an element of a list and the included
+// file contains a list, its elements are spliced into the including list.
+// An object containing the key "$include" is merged with the object(s) from
+// the file(s) listed there, keys of the including object take precedence:
+//     {
+//         Request: {
+//             $include: "shared/request-defaults.hjson"
+//             URL: "http://{{HOST}}/path"
+//         }
+//         Checks: [
+//             {Check: "StatusCode", Expect: 200}
+//             "!include shared/html-checks.hjson"
+//         ]
+//     }
+// Filenames are relative to the including file. Includes are resolved
+// while loading, before variable substitution, so included files may use
+// variables.
+//
+//
+// Remote Files
+//
+// Suites, tests, mixins, mocks and included files may be loaded from
+// other locations than the local file system:
+//   - http://... and https://... URLs are downloaded.
+//   - <archive>!<path> reads path from a zip or tar archive (.zip, .tar,
+//     .tar.gz or .tgz); the archive may be a local file or a URL, e.g.
+//     https://artifacts.example.org/api-suites-1.3.tgz!smoke.suite
+//   - git:<repository>@<ref>:<path> reads path at the given ref (branch,
+//     tag or commit) from a local git repository (e.g. a bare mirror).
+// Files referenced relative to such a file are loaded from the same
+// location. A location may be pinned by appending #sha256=<hex digest>:
+// The downloaded artifact (the archive for archive locations) must have
+// this digest. Artifacts are cached in memory; pinned artifacts are also
+// cached in RemoteCacheDir if set.
+//
+//
+// JSON Schema
+//
+// Schema provides JSON Schemas for tests, mixins, suites, mocks and load
+// tests which can be used by editors for autocompletion and validation.
+// If ValidateSchema is set the files are validated against their schema
+// before they are populated; all violations are reported, misspelled
+// fields with a suggestion for the correct name.
+//
 package suite
@@ -0,0 +1,68 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestElasticReporter(t *testing.T) {
+	s := executeCISuite(t)
+
+	var lines []string
+	var apiKey string
+	fail := false
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+			t.Errorf("Got %s %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		apiKey = r.Header.Get("Authorization")
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		if fail {
+			fmt.Fprint(w, `{"errors": true, "items": [{"index": {"status": 400,
+                            "error": {"type": "mapper_parsing_exception"}}}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"errors": false, "items": []}`)
+	}))
+	defer es.Close()
+
+	reporter := &ElasticReporter{URL: es.URL, APIKey: "key", RunID: "run-1",
+		Environment: "staging"}
+	if err := reporter.Report("", s); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if apiKey != "ApiKey key" {
+		t.Errorf("Got Authorization %q", apiKey)
+	}
+	if len(lines) != 6 || lines[0] != `{"index":{"_index":"ht-results"}}` {
+		t.Fatalf("Got %d lines:\n%s", len(lines), strings.Join(lines, "\n"))
+	}
+	doc := ElasticDocument{}
+	if err := json.Unmarshal([]byte(lines[3]), &doc); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if doc.Test != "Bad" || doc.Status != "Fail" || doc.SeqNo != "Main-02" ||
+		doc.RunID != "run-1" || doc.Environment != "staging" ||
+		doc.StatusCode != 200 || doc.ResponseBytes != 5 || len(doc.Checks) != 2 ||
+		!strings.HasSuffix(doc.URL, "/bad") || doc.Timestamp.IsZero() {
+		t.Errorf("Got %+v", doc)
+	}
+
+	fail, lines = true, nil
+	err := reporter.Report("", s)
+	if err == nil || !strings.Contains(err.Error(), "mapper_parsing_exception") {
+		t.Errorf("Got error %v", err)
+	}
+}
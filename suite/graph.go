@@ -0,0 +1,138 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// ----------------------------------------------------------------------------
+//   Graph
+
+// Graph writes the structure of rs as a Graphviz graph in the DOT language
+// to w: The tests in execution order grouped into setup, main and teardown,
+// the mixins and mocks used by the tests and the flow of variables from
+// the tests extracting them to the tests using them.
+func (rs *RawSuite) Graph(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "digraph suite {\n")
+	fmt.Fprintf(buf, "    label=%s;\n    labelloc=t;\n", strconv.Quote(rs.Name))
+	fmt.Fprintf(buf, "    node [shape=box, style=rounded];\n")
+
+	// Tests clustered by their part in execution order.
+	i := 0
+	for _, part := range []struct {
+		which string
+		n     int
+	}{{"Setup", len(rs.Setup)}, {"Main", len(rs.Main)}, {"Teardown", len(rs.Teardown)}} {
+		if part.n == 0 {
+			continue
+		}
+		fmt.Fprintf(buf, "    subgraph cluster_%s {\n", part.which)
+		fmt.Fprintf(buf, "        label=%q;\n", part.which)
+		for j := 0; j < part.n && i < len(rs.tests); j++ {
+			fmt.Fprintf(buf, "        t%d [label=%s];\n", i+1,
+				strconv.Quote(rs.tests[i].graphLabel(i)))
+			i++
+		}
+		fmt.Fprintf(buf, "    }\n")
+	}
+	for i := 1; i < len(rs.tests); i++ {
+		fmt.Fprintf(buf, "    t%d -> t%d [weight=10];\n", i, i+1)
+	}
+
+	// Mixins and mocks are shared nodes, identified by their file name.
+	mixins, mocks := map[string]string{}, map[string]string{}
+	for i, rt := range rs.tests {
+		for _, mix := range rt.Mixins {
+			id, ok := mixins[mix.File.Name]
+			if !ok {
+				id = fmt.Sprintf("x%d", len(mixins)+1)
+				mixins[mix.File.Name] = id
+				fmt.Fprintf(buf, "    %s [label=%s, shape=note, style=\"\"];\n",
+					id, strconv.Quote(mix.File.Name))
+			}
+			fmt.Fprintf(buf, "    t%d -> %s [style=dashed, arrowhead=none];\n", i+1, id)
+		}
+		for _, mf := range rt.mocks {
+			id, ok := mocks[mf.File.Name]
+			if !ok {
+				id = fmt.Sprintf("m%d", len(mocks)+1)
+				mocks[mf.File.Name] = id
+				fmt.Fprintf(buf, "    %s [label=%s, shape=component, style=\"\"];\n",
+					id, strconv.Quote(mf.File.Name))
+			}
+			fmt.Fprintf(buf, "    t%d -> %s [style=dotted, label=\"mock\"];\n", i+1, id)
+		}
+	}
+
+	// Variable flow from the last test extracting a variable to each
+	// later test using it.
+	extractedBy := map[string]int{}
+	for i, rt := range rs.tests {
+		used := usedVariables(rt.allData())
+		for _, value := range rt.contextVars {
+			for name := range usedVariables(value) {
+				used[name] = true
+			}
+		}
+		names := make([]string, 0, len(used))
+		for name := range used {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if j, ok := extractedBy[name]; ok {
+				fmt.Fprintf(buf, "    t%d -> t%d [label=%s, color=blue, fontcolor=blue, constraint=false];\n",
+					j+1, i+1, strconv.Quote(name))
+			}
+		}
+		for _, name := range rt.extractedVariables() {
+			extractedBy[name] = i
+		}
+	}
+
+	fmt.Fprintf(buf, "}\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// graphLabel returns the label of the i'th (0-based) test rt in a graph.
+func (rt *RawTest) graphLabel(i int) string {
+	label := fmt.Sprintf("%d. ", i+1)
+	if isInline(rt.File) {
+		label += "(inline)"
+	} else {
+		label += rt.File.Basename()
+	}
+	if name := rt.testName(); name != "" {
+		label += "\n" + name
+	}
+	return label
+}
+
+// extractedVariables returns the sorted names of the variables extracted
+// by rt or its mixins.
+func (rt *RawTest) extractedVariables() []string {
+	names := map[string]string{}
+	files := []*File{rt.File}
+	for _, mix := range rt.Mixins {
+		files = append(files, mix.File)
+	}
+	for _, f := range files {
+		x := &struct{ DataExtraction map[string]interface{} }{}
+		if f.decodeLaxTo(x) != nil {
+			continue
+		}
+		for name := range x.DataExtraction {
+			names[name] = name
+		}
+	}
+	return sortedNames(names)
+}
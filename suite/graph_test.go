@@ -0,0 +1,95 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var graphFS = `
+# graph.suite
+{
+    Name: "Graph"
+    Setup: [ {File: "login.ht"} ]
+    Main: [
+        {File: "get.ht", Mocks: ["backend.mock"]}
+        {File: "get.ht", Variables: {ID: "{{ORDER}}"}}
+    ]
+    Teardown: [ {File: "logout.ht"} ]
+}
+
+# login.ht
+{
+    Name: "Login"
+    Request: {URL: "http://localhost/login"}
+    DataExtraction: {
+        TOKEN: {Extractor: "BodyExtractor", Regexp: "[0-9]+"}
+        ORDER: {Extractor: "BodyExtractor", Regexp: "[a-z]+"}
+    }
+}
+
+# get.ht
+{
+    Mixin: ["auth.mix"]
+    Request: {URL: "http://localhost/get?id={{ID}}"}
+}
+
+# logout.ht
+{
+    Request: {URL: "http://localhost/logout"}
+}
+
+# auth.mix
+{
+    Request: {Header: {Authorization: "Bearer {{TOKEN}}"}}
+}
+
+# backend.mock
+{
+    Method: "GET"
+    URL: "http://localhost:8880/backend"
+    Response: {StatusCode: 200}
+}
+`
+
+func TestGraph(t *testing.T) {
+	fs, err := NewFileSystem(graphFS)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	rs, err := LoadRawSuite("graph.suite", fs)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := rs.Graph(buf); err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		`subgraph cluster_Setup {`,
+		`t1 [label="1. login.ht\nLogin"];`,
+		`t4 [label="4. logout.ht"];`,
+		`t3 -> t4 [weight=10];`,
+		`x1 [label="auth.mix", shape=note, style=""];`,
+		`t2 -> x1 [style=dashed, arrowhead=none];`,
+		`t3 -> x1 [style=dashed, arrowhead=none];`,
+		`m1 [label="backend.mock", shape=component, style=""];`,
+		`t2 -> m1 [style=dotted, label="mock"];`,
+		`t1 -> t2 [label="TOKEN", color=blue`,
+		`t1 -> t3 [label="ORDER", color=blue`,
+		`t1 -> t3 [label="TOKEN", color=blue`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Missing %s in\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, `label="ID"`) {
+		t.Errorf("Unexpected flow of ID in\n%s", got)
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/scope"
+)
+
+// ----------------------------------------------------------------------------
+// HAR (HTTP Archive) export
+
+// HAR produces a HTTP Archive (HAR 1.2) of the request/response traffic of
+// all executed tests in s. Such a file can be loaded into the developer
+// tools of most browsers. Request and response bodies longer than bodyLimit
+// bytes are truncated; a negative bodyLimit disables truncation.
+// Binary bodies are base64 encoded and secret values (see package scope)
+// are masked.
+//
+// Each try of a test is recorded as its own entry. Timing information is
+// limited to the overall duration of the request: It is reported as wait
+// time.
+func (s *Suite) HAR(bodyLimit int) ([]byte, error) {
+	// Local types used for JSON encoding.
+	type NameValue struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	type PostData struct {
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+		Encoding string `json:"encoding,omitempty"`
+		Comment  string `json:"comment,omitempty"`
+	}
+	type Request struct {
+		Method      string      `json:"method"`
+		URL         string      `json:"url"`
+		HTTPVersion string      `json:"httpVersion"`
+		Cookies     []NameValue `json:"cookies"`
+		Headers     []NameValue `json:"headers"`
+		QueryString []NameValue `json:"queryString"`
+		PostData    *PostData   `json:"postData,omitempty"`
+		HeadersSize int         `json:"headersSize"`
+		BodySize    int         `json:"bodySize"`
+	}
+	type Content struct {
+		Size     int    `json:"size"`
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+		Encoding string `json:"encoding,omitempty"`
+		Comment  string `json:"comment,omitempty"`
+	}
+	type Response struct {
+		Status      int         `json:"status"`
+		StatusText  string      `json:"statusText"`
+		HTTPVersion string      `json:"httpVersion"`
+		Cookies     []NameValue `json:"cookies"`
+		Headers     []NameValue `json:"headers"`
+		Content     Content     `json:"content"`
+		RedirectURL string      `json:"redirectURL"`
+		HeadersSize int         `json:"headersSize"`
+		BodySize    int         `json:"bodySize"`
+	}
+	type Timings struct {
+		Send    float64 `json:"send"`
+		Wait    float64 `json:"wait"`
+		Receive float64 `json:"receive"`
+	}
+	type Entry struct {
+		Pageref         string   `json:"pageref"`
+		StartedDateTime string   `json:"startedDateTime"`
+		Time            float64  `json:"time"`
+		Request         Request  `json:"request"`
+		Response        Response `json:"response"`
+		Cache           struct{} `json:"cache"`
+		Timings         Timings  `json:"timings"`
+		Comment         string   `json:"comment,omitempty"`
+	}
+	type Page struct {
+		StartedDateTime string   `json:"startedDateTime"`
+		ID              string   `json:"id"`
+		Title           string   `json:"title"`
+		PageTimings     struct{} `json:"pageTimings"`
+	}
+	type Creator struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	type Log struct {
+		Version string  `json:"version"`
+		Creator Creator `json:"creator"`
+		Pages   []Page  `json:"pages"`
+		Entries []Entry `json:"entries"`
+	}
+
+	headers := func(h http.Header) []NameValue {
+		nv := []NameValue{}
+		names := make([]string, 0, len(h))
+		for name := range h {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, value := range h[name] {
+				nv = append(nv, NameValue{name, scope.Mask(value)})
+			}
+		}
+		return nv
+	}
+	cookies := func(cs []*http.Cookie) []NameValue {
+		nv := []NameValue{}
+		for _, c := range cs {
+			nv = append(nv, NameValue{c.Name, scope.Mask(c.Value)})
+		}
+		return nv
+	}
+	values := func(v url.Values) []NameValue {
+		nv := []NameValue{}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, value := range v[name] {
+				nv = append(nv, NameValue{name, scope.Mask(value)})
+			}
+		}
+		return nv
+	}
+	// body returns the (masked, possibly truncated) text, the encoding
+	// and a comment for the body b.
+	body := func(b string) (string, string, string) {
+		text := utf8.ValidString(b)
+		comment := ""
+		if bodyLimit >= 0 && len(b) > bodyLimit {
+			n := bodyLimit
+			for text && n > 0 && !utf8.RuneStart(b[n]) {
+				n-- // do not cut a rune
+			}
+			comment = fmt.Sprintf("body truncated from %d to %d bytes",
+				len(b), n)
+			b = b[:n]
+		}
+		if text {
+			return scope.Mask(b), "", comment
+		}
+		return base64.StdEncoding.EncodeToString([]byte(b)), "base64", comment
+	}
+	ms := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+
+	pageID := "suite"
+	harlog := Log{
+		Version: "1.2",
+		Creator: Creator{Name: "ht", Version: "1"},
+		Pages: []Page{{
+			StartedDateTime: s.Started.Format(time.RFC3339Nano),
+			ID:              pageID,
+			Title:           s.Name,
+		}},
+		Entries: []Entry{},
+	}
+
+	addEntry := func(test *ht.Test, try ht.Try, n int) {
+		req := try.Request.Request
+		if req == nil {
+			return // not sent at all
+		}
+		comment := fmt.Sprintf("%s %s: %s", identifier(test), test.Name, try.Status)
+		if test.Result.Tries > 1 {
+			comment += fmt.Sprintf(" (try %d of %d)", n, test.Result.Tries)
+		}
+		entry := Entry{
+			Pageref:         pageID,
+			StartedDateTime: try.Started.Format(time.RFC3339Nano),
+			Request: Request{
+				Method:      req.Method,
+				URL:         scope.Mask(req.URL.String()),
+				HTTPVersion: req.Proto,
+				Cookies:     cookies(req.Cookies()),
+				Headers:     headers(req.Header),
+				QueryString: values(req.URL.Query()),
+				HeadersSize: -1,
+				BodySize:    len(try.Request.SentBody),
+			},
+			Response: Response{
+				Cookies:     []NameValue{},
+				Headers:     []NameValue{},
+				HeadersSize: -1,
+				BodySize:    -1,
+			},
+			Comment: comment,
+		}
+		if try.Request.SentBody != "" {
+			text, encoding, comment := body(try.Request.SentBody)
+			entry.Request.PostData = &PostData{
+				MimeType: req.Header.Get("Content-Type"),
+				Text:     text,
+				Encoding: encoding,
+				Comment:  comment,
+			}
+		}
+
+		if resp := try.Response.Response; resp != nil {
+			entry.Response.Status = resp.StatusCode
+			entry.Response.StatusText = http.StatusText(resp.StatusCode)
+			entry.Response.HTTPVersion = resp.Proto
+			entry.Response.Cookies = cookies(resp.Cookies())
+			entry.Response.Headers = headers(resp.Header)
+			entry.Response.RedirectURL = resp.Header.Get("Location")
+			entry.Response.BodySize = len(try.Response.BodyStr)
+			text, encoding, comment := body(try.Response.BodyStr)
+			entry.Response.Content = Content{
+				Size:     len(try.Response.BodyStr),
+				MimeType: resp.Header.Get("Content-Type"),
+				Text:     text,
+				Encoding: encoding,
+				Comment:  comment,
+			}
+			entry.Time = ms(try.Response.Duration)
+			entry.Timings.Wait = entry.Time
+		}
+
+		harlog.Entries = append(harlog.Entries, entry)
+	}
+
+	for _, test := range s.Tests {
+		for i, try := range test.Result.PrevTries {
+			addEntry(test, try, i+1)
+		}
+		started := test.Result.TryStarted
+		if started.IsZero() {
+			started = test.Result.Started
+		}
+		addEntry(test, ht.Try{
+			Started:  started,
+			Status:   test.Result.Status,
+			Error:    test.Result.Error,
+			Request:  test.Request,
+			Response: test.Response,
+		}, len(test.Result.PrevTries)+1)
+	}
+
+	return json.MarshalIndent(struct {
+		Log Log `json:"log"`
+	}{harlog}, "", "  ")
+}
@@ -0,0 +1,154 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vdobler/ht/ht"
+)
+
+func TestHAR(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, "Hello World, how are you?")
+	}))
+	defer ts.Close()
+
+	test := &ht.Test{
+		Name: "HAR test",
+		Request: ht.Request{
+			Method: "POST",
+			URL:    ts.URL + "/path?q=1",
+			Body:   "some data",
+		},
+	}
+	test.Run()
+	if test.Result.Status != ht.Pass {
+		t.Fatalf("Unexpected status %s: %v", test.Result.Status, test.Result.Error)
+	}
+	s := &Suite{Name: "HAR Suite", Tests: []*ht.Test{test, {Name: "Not run"}}}
+
+	data, err := s.HAR(11)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var har struct {
+		Log struct {
+			Version string
+			Entries []struct {
+				Request struct {
+					Method      string
+					URL         string
+					QueryString []struct{ Name, Value string }
+					PostData    struct{ Text string }
+				}
+				Response struct {
+					Status  int
+					Cookies []struct{ Name, Value string }
+					Content struct {
+						Size    int
+						Text    string
+						Comment string
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &har); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if har.Log.Version != "1.2" || len(har.Log.Entries) != 1 {
+		t.Fatalf("Bad HAR log:\n%s", data)
+	}
+	entry := har.Log.Entries[0]
+	if entry.Request.Method != "POST" ||
+		!strings.HasSuffix(entry.Request.URL, "/path?q=1") ||
+		len(entry.Request.QueryString) != 1 ||
+		entry.Request.PostData.Text != "some data" {
+		t.Errorf("Bad request %+v", entry.Request)
+	}
+	if entry.Response.Status != 200 ||
+		len(entry.Response.Cookies) != 1 || entry.Response.Cookies[0].Value != "abc" ||
+		entry.Response.Content.Size != 25 ||
+		entry.Response.Content.Text != "Hello World" ||
+		entry.Response.Content.Comment == "" {
+		t.Errorf("Bad response %+v", entry.Response)
+	}
+}
+
+func TestHARTriesAndEncoding(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "Grüße")
+	}))
+	defer ts.Close()
+
+	test := &ht.Test{
+		Name: "Retried",
+		Request: ht.Request{
+			Method: "POST",
+			URL:    ts.URL,
+			Body:   "\xff\xfe\x00binary",
+		},
+		Checks:    ht.CheckList{ht.StatusCode{Expect: 200}},
+		Execution: ht.Execution{Tries: 2},
+	}
+	test.Run()
+	if test.Result.Status != ht.Pass || test.Result.Tries != 2 {
+		t.Fatalf("Unexpected status %s after %d tries: %v", test.Result.Status,
+			test.Result.Tries, test.Result.Error)
+	}
+	s := &Suite{Name: "HAR Suite", Tests: []*ht.Test{test}}
+
+	data, err := s.HAR(3) // cuts the "ü" of "Grüße"
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	var har struct {
+		Log struct {
+			Entries []struct {
+				Request struct {
+					PostData struct{ Text, Encoding string }
+				}
+				Response struct {
+					Status  int
+					Content struct{ Text, Encoding string }
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &har); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(har.Log.Entries) != 2 {
+		t.Fatalf("Got %d entries:\n%s", len(har.Log.Entries), data)
+	}
+	for i, want := range []int{503, 200} {
+		entry := har.Log.Entries[i]
+		if entry.Response.Status != want {
+			t.Errorf("%d. entry: got status %d, want %d", i, entry.Response.Status, want)
+		}
+		if entry.Request.PostData.Encoding != "base64" ||
+			entry.Request.PostData.Text != "//4A" {
+			t.Errorf("%d. entry: bad post data %+v", i, entry.Request.PostData)
+		}
+	}
+	if got := har.Log.Entries[1].Response.Content; got.Text != "Gr" || got.Encoding != "" {
+		t.Errorf("Got content %+v", got)
+	}
+}
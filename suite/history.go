@@ -0,0 +1,277 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/vdobler/ht/ht"
+)
+
+// ----------------------------------------------------------------------------
+// History
+
+// The history of test results is stored in a file containing one JSON
+// encoded HistoryEntry per line. New runs are appended to the file which
+// keeps recording cheap and the file easy to process with other tools.
+
+// HistoryEntry records the outcome of one test in one run.
+type HistoryEntry struct {
+	Run        string    // Run identifies the run, see RunID.
+	Time       time.Time // Time the run started.
+	Suite      string    // Suite is the name of the suite.
+	SeqNo      string    // SeqNo of the test like "Main-03".
+	Test       string    // Test is the name of the test.
+	File       string    // File of the test.
+	Status     string    // Status of the test.
+	DurationMS int64     // DurationMS of the last try of the test.
+}
+
+// Key identifies the test of e across runs like "Shop: Main-03 Login".
+func (e HistoryEntry) Key() string {
+	name := e.Test
+	if name == "" {
+		name = e.File
+	}
+	return e.Suite + ": " + e.SeqNo + " " + name
+}
+
+// RunID returns a key identifying the run started at started: The start
+// time followed by a random suffix which keeps runs started in the same
+// second apart.
+func RunID(started time.Time) string {
+	var suffix [4]byte
+	rand.Read(suffix[:])
+	return fmt.Sprintf("%s-%x", started.Format("2006-01-02_15h04m05s"), suffix)
+}
+
+// AppendHistory appends the outcome of the tests of suites in the run
+// started at started to the history file filename.
+func AppendHistory(filename, run string, started time.Time, suites []*Suite) error {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	for _, s := range suites {
+		for _, test := range s.Tests {
+			err := enc.Encode(HistoryEntry{
+				Run:        run,
+				Time:       started,
+				Suite:      s.Name,
+				SeqNo:      test.GetStringMetadata("SeqNo"),
+				Test:       test.Name,
+				File:       test.GetStringMetadata("Filename"),
+				Status:     test.Result.Status.String(),
+				DurationMS: milliseconds(test.Result.Duration),
+			})
+			if err != nil {
+				file.Close()
+				return err
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// LoadHistory reads the history file filename.
+func LoadHistory(filename string) ([]HistoryEntry, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := []HistoryEntry{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		e := HistoryEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", filename, line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// ----------------------------------------------------------------------------
+// Trend analysis
+
+// TestTrend summarises the history of one test over the analysed runs.
+type TestTrend struct {
+	Key string // Key of the test, see HistoryEntry.Key.
+
+	// Statuses contains one character per analysed run, oldest
+	// first: "." for Pass, "F" Fail, "E" Error, "B" Bogus, "s" Skipped
+	// or NotRun and " " if the test was not part of the run.
+	Statuses string
+
+	Runs     int     // Runs is the number of runs the test was part of.
+	PassRate float64 // PassRate is the fraction of passed runs.
+
+	LastStatus     string
+	LastDurationMS int64
+
+	// MedianMS is the median duration of the passed runs before the
+	// last run.
+	MedianMS int64
+
+	// Flakiness is the fraction of status changes between passing and
+	// not passing in consecutive runs of the test: 0 means stable,
+	// 1 means the status changes on every run.
+	Flakiness float64
+
+	NewlyFailing bool // NewlyFailing: Failed in last, passed in previous run.
+
+	// Slowdown is the relative increase of the last duration compared
+	// to MedianMS, e.g. 0.5 for 50% slower.
+	Slowdown float64
+}
+
+// Trends is the result of analysing a history.
+type Trends struct {
+	Runs  []string  // Runs are the analysed runs, oldest first.
+	First time.Time // First and Last are the start times of the
+	Last  time.Time // first and last analysed run.
+	Tests []TestTrend
+}
+
+// AnalyseHistory analyses the last n runs (all runs if n <= 0) recorded
+// in entries. The tests are sorted by their Key.
+func AnalyseHistory(entries []HistoryEntry, n int) *Trends {
+	// Runs in order of appearance.
+	runIndex := map[string]int{}
+	trends := &Trends{}
+	for _, e := range entries {
+		if _, ok := runIndex[e.Run]; !ok {
+			runIndex[e.Run] = len(trends.Runs)
+			trends.Runs = append(trends.Runs, e.Run)
+		}
+	}
+	skip := 0
+	if n > 0 && len(trends.Runs) > n {
+		skip = len(trends.Runs) - n
+	}
+	trends.Runs = trends.Runs[skip:]
+
+	// History of each test, one entry per analysed run.
+	history := map[string][]*HistoryEntry{}
+	for i := range entries {
+		e := &entries[i]
+		r := runIndex[e.Run] - skip
+		if r < 0 {
+			continue
+		}
+		if r == 0 && trends.First.IsZero() {
+			trends.First = e.Time
+		}
+		if r == len(trends.Runs)-1 {
+			trends.Last = e.Time
+		}
+		h, ok := history[e.Key()]
+		if !ok {
+			h = make([]*HistoryEntry, len(trends.Runs))
+			history[e.Key()] = h
+		}
+		h[r] = e // later entries of the same test in the same run win
+	}
+
+	for key, h := range history {
+		trends.Tests = append(trends.Tests, analyseTest(key, h))
+	}
+	sort.Slice(trends.Tests, func(i, j int) bool {
+		return trends.Tests[i].Key < trends.Tests[j].Key
+	})
+	return trends
+}
+
+// analyseTest analyses the history h of the test key.
+func analyseTest(key string, h []*HistoryEntry) TestTrend {
+	trend := TestTrend{Key: key}
+	statuses := make([]byte, len(h))
+	passed, changes, prev := 0, 0, ""
+	durations := []int64{}
+	var last *HistoryEntry
+	for i, e := range h {
+		if e == nil {
+			statuses[i] = ' '
+			continue
+		}
+		trend.Runs++
+		statuses[i] = historyStatusChar(e.Status)
+		if e.Status == ht.Pass.String() {
+			passed++
+		}
+		if last != nil {
+			if e.Status == ht.Pass.String() && prev != ht.Pass.String() ||
+				e.Status != ht.Pass.String() && prev == ht.Pass.String() {
+				changes++
+			}
+			if last.Status == ht.Pass.String() {
+				durations = append(durations, last.DurationMS)
+			}
+		}
+		prev, last = e.Status, e
+	}
+	trend.Statuses = string(statuses)
+	if trend.Runs == 0 {
+		return trend
+	}
+	trend.PassRate = float64(passed) / float64(trend.Runs)
+	trend.LastStatus = last.Status
+	trend.LastDurationMS = last.DurationMS
+	if trend.Runs > 1 {
+		trend.Flakiness = float64(changes) / float64(trend.Runs-1)
+	}
+
+	// Newly failing and slow is judged for tests in the last run only.
+	if h[len(h)-1] == nil {
+		return trend
+	}
+	if len(h) > 1 && h[len(h)-2] != nil && h[len(h)-2].Status == ht.Pass.String() &&
+		last.Status != ht.Pass.String() && last.Status != ht.Skipped.String() &&
+		last.Status != ht.NotRun.String() {
+		trend.NewlyFailing = true
+	}
+	if len(durations) > 0 {
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		trend.MedianMS = durations[len(durations)/2]
+		if trend.MedianMS > 0 && last.Status == ht.Pass.String() {
+			trend.Slowdown = float64(last.DurationMS-trend.MedianMS) / float64(trend.MedianMS)
+		}
+	}
+	return trend
+}
+
+func historyStatusChar(status string) byte {
+	switch status {
+	case "Pass":
+		return '.'
+	case "Fail":
+		return 'F'
+	case "Error":
+		return 'E'
+	case "Bogus":
+		return 'B'
+	}
+	return 's'
+}
@@ -0,0 +1,124 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vdobler/ht/ht"
+)
+
+func TestHistoryRoundtrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "history.jsonl")
+
+	test := &ht.Test{Name: "Login"}
+	test.SetMetadata("Filename", "login.ht")
+	test.SetMetadata("SeqNo", "Setup-01")
+	test.Result.Status = ht.Fail
+	test.Result.Duration = 1500 * time.Millisecond
+	s := &Suite{Name: "Shop", Tests: []*ht.Test{test}}
+	started := time.Date(2017, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, run := range []string{"run1", "run2"} {
+		if err := AppendHistory(filename, run, started, []*Suite{s}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+
+	entries, err := LoadHistory(filename)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	want := HistoryEntry{Run: "run2", Time: started, Suite: "Shop", SeqNo: "Setup-01", Test: "Login",
+		File: "login.ht", Status: "Fail", DurationMS: 1500}
+	if len(entries) != 2 || entries[1] != want {
+		t.Errorf("Got %+v", entries)
+	}
+}
+
+func TestRunID(t *testing.T) {
+	started := time.Date(2017, 5, 1, 12, 0, 0, 0, time.UTC)
+	a, b := RunID(started), RunID(started)
+	if a == b || !strings.HasPrefix(a, "2017-05-01_12h00m00s-") ||
+		len(a) != len("2017-05-01_12h00m00s-")+8 {
+		t.Errorf("Got %q and %q", a, b)
+	}
+}
+
+func TestAnalyseHistory(t *testing.T) {
+	entries := []HistoryEntry{}
+	add := func(run int, test, status string, ms int64) {
+		entries = append(entries, HistoryEntry{
+			Run:        fmt.Sprintf("run%d", run),
+			Time:       time.Date(2017, 5, run, 12, 0, 0, 0, time.UTC),
+			Suite:      "Shop",
+			SeqNo:      "Main-01",
+			Test:       test,
+			Status:     status,
+			DurationMS: ms,
+		})
+	}
+	for run := 1; run <= 6; run++ {
+		add(run, "Stable", "Pass", 100)
+		if run%2 == 0 {
+			add(run, "Flaky", "Fail", 100)
+		} else {
+			add(run, "Flaky", "Pass", 100)
+		}
+		if run < 6 {
+			add(run, "Broken", "Pass", 100)
+			add(run, "Slow", "Pass", 100+int64(run))
+		} else {
+			add(run, "Broken", "Error", 100)
+			add(run, "Slow", "Pass", 250)
+		}
+		if run == 3 {
+			add(run, "Gone", "Pass", 10)
+		}
+	}
+
+	trends := AnalyseHistory(entries, 5)
+	if len(trends.Runs) != 5 || trends.Runs[0] != "run2" ||
+		trends.First.Day() != 2 || trends.Last.Day() != 6 {
+		t.Fatalf("Got runs %v %s %s", trends.Runs, trends.First, trends.Last)
+	}
+	got := map[string]TestTrend{}
+	for _, tt := range trends.Tests {
+		got[tt.Key] = tt
+	}
+	if len(got) != 5 {
+		t.Fatalf("Got %d tests: %v", len(got), trends.Tests)
+	}
+
+	if tt := got["Shop: Main-01 Stable"]; tt.Statuses != "....." || tt.PassRate != 1 ||
+		tt.Flakiness != 0 || tt.NewlyFailing || tt.Slowdown != 0 || tt.MedianMS != 100 {
+		t.Errorf("Stable: %+v", tt)
+	}
+	if tt := got["Shop: Main-01 Flaky"]; tt.Statuses != "F.F.F" || tt.Flakiness != 1 ||
+		!tt.NewlyFailing || tt.PassRate != 0.4 {
+		t.Errorf("Flaky: %+v", tt)
+	}
+	if tt := got["Shop: Main-01 Broken"]; tt.Statuses != "....E" || !tt.NewlyFailing ||
+		tt.LastStatus != "Error" || tt.Flakiness != 0.25 {
+		t.Errorf("Broken: %+v", tt)
+	}
+	if tt := got["Shop: Main-01 Slow"]; tt.MedianMS != 104 || tt.LastDurationMS != 250 ||
+		tt.Slowdown < 1.40 || tt.Slowdown > 1.41 {
+		t.Errorf("Slow: %+v", tt)
+	}
+	if tt := got["Shop: Main-01 Gone"]; tt.Statuses != " .   " || tt.Runs != 1 || tt.NewlyFailing {
+		t.Errorf("Gone: %+v", tt)
+	}
+}
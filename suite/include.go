@@ -0,0 +1,242 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vdobler/ht/internal/hjson"
+	"github.com/vdobler/ht/scope"
+)
+
+// ----------------------------------------------------------------------------
+//   Include directives
+
+// Include directives are resolved when loading a file through a FileSystem,
+// see the package documentation for their syntax.
+
+const (
+	includeDirective = "!include "
+	includeKey       = "$include"
+)
+
+// maxIncludeDepth limits the nesting of included files.
+const maxIncludeDepth = 20
+
+// hasIncludes reports whether f contains include directives. Files which
+// are not valid Hjson are reported to have no includes so that they are
+// left untouched and parse errors refer to the original text.
+func (f *File) hasIncludes() bool {
+	if !strings.Contains(f.Data, includeDirective) &&
+		!strings.Contains(f.Data, includeKey) {
+		return false // fast path
+	}
+	var soup interface{}
+	if err := hjson.Unmarshal([]byte(f.Data), &soup); err != nil {
+		return false
+	}
+	return containsInclude(soup)
+}
+
+// containsInclude reports whether soup contains an include directive.
+func containsInclude(soup interface{}) bool {
+	switch v := soup.(type) {
+	case string:
+		return isInclude(v)
+	case []interface{}:
+		for _, elem := range v {
+			if containsInclude(elem) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		if _, ok := v[includeKey]; ok {
+			return true
+		}
+		for _, elem := range v {
+			if containsInclude(elem) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolveIncludes returns a copy of f with all include directives resolved.
+// The chain of including files is given by including.
+func (f *File) resolveIncludes(fs FileSystem, including []string) (*File, error) {
+	for _, name := range including {
+		if name == f.Name {
+			return nil, fmt.Errorf("include cycle %s -> %s",
+				strings.Join(including, " -> "), f.Name)
+		}
+	}
+	if len(including) >= maxIncludeDepth {
+		return nil, fmt.Errorf("includes nested too deep in %s", f.Name)
+	}
+
+	var soup interface{}
+	err := hjson.Unmarshal([]byte(f.Data), &soup)
+	if err != nil {
+		return nil, fmt.Errorf("file %s is not valid hjson: %s", f.Name, err)
+	}
+	inc := includer{fs: fs, dir: f.Dirname(), including: append(including, f.Name)}
+	soup, err = inc.resolve(soup)
+	if err != nil {
+		return nil, fmt.Errorf("file %s: %s", f.Name, err)
+	}
+	data, err := hjson.Marshal(soup)
+	if err != nil {
+		return nil, err
+	}
+
+	return &File{Data: string(data), Name: f.Name}, nil
+}
+
+// includer resolves include directives in the soup read from a file in dir.
+type includer struct {
+	fs        FileSystem
+	dir       string
+	including []string
+}
+
+func (inc includer) resolve(soup interface{}) (interface{}, error) {
+	switch v := soup.(type) {
+	case string:
+		if name, ok := includeName(v); ok {
+			return inc.load(name)
+		}
+	case []interface{}:
+		list := make([]interface{}, 0, len(v))
+		for _, elem := range v {
+			resolved, err := inc.resolve(elem)
+			if err != nil {
+				return nil, err
+			}
+			if s, ok := elem.(string); ok && isInclude(s) {
+				if elems, ok := resolved.([]interface{}); ok {
+					list = append(list, elems...)
+					continue
+				}
+			}
+			list = append(list, resolved)
+		}
+		return list, nil
+	case map[string]interface{}:
+		merged := make(map[string]interface{}, len(v))
+		if names, ok := v[includeKey]; ok {
+			err := inc.merge(merged, names)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for key, elem := range v {
+			if key == includeKey {
+				continue
+			}
+			resolved, err := inc.resolve(elem)
+			if err != nil {
+				return nil, err
+			}
+			merged[key] = resolved
+		}
+		return merged, nil
+	}
+	return soup, nil
+}
+
+// merge the objects from the files given by names (a string or a list of
+// strings) into m.
+func (inc includer) merge(m map[string]interface{}, names interface{}) error {
+	list, ok := names.([]interface{})
+	if !ok {
+		list = []interface{}{names}
+	}
+	for _, n := range list {
+		name, ok := n.(string)
+		if !ok {
+			return fmt.Errorf("%s needs filenames, got %v", includeKey, n)
+		}
+		soup, err := inc.load(name)
+		if err != nil {
+			return err
+		}
+		obj, ok := soup.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("included file %s is not an object (got %T)",
+				name, soup)
+		}
+		for key, val := range obj {
+			m[key] = val
+		}
+	}
+	return nil
+}
+
+// load the included file name and return its resolved content.
+func (inc includer) load(name string) (interface{}, error) {
+	filename := joinName(inc.dir, name)
+	file, err := inc.fs.loadPlain(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot include %q: %s", name, err)
+	}
+	if file.hasIncludes() {
+		file, err = file.resolveIncludes(inc.fs, inc.including)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var soup interface{}
+	err = hjson.Unmarshal([]byte(file.Data), &soup)
+	if err != nil {
+		return nil, fmt.Errorf("included file %s is not valid hjson: %s",
+			file.Name, err)
+	}
+
+	// The merged file has the name of the including file, so secret
+	// references of untrusted files must be rejected here (calls of
+	// local functions are rejected by loadPlain).
+	if isUntrusted(file.Name) && referencesSecret(soup) {
+		return nil, fmt.Errorf("included file %s: downloaded files cannot reference secrets",
+			file.Name)
+	}
+	return soup, nil
+}
+
+// referencesSecret reports whether a string in soup is a secret reference.
+func referencesSecret(soup interface{}) bool {
+	switch v := soup.(type) {
+	case string:
+		return strings.HasPrefix(v, scope.SecretPrefix)
+	case []interface{}:
+		for _, elem := range v {
+			if referencesSecret(elem) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		for _, elem := range v {
+			if referencesSecret(elem) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isInclude(s string) bool {
+	_, ok := includeName(s)
+	return ok
+}
+
+// includeName extracts the filename from the include directive s.
+func includeName(s string) (string, bool) {
+	if !strings.HasPrefix(s, includeDirective) {
+		return "", false
+	}
+	name := strings.TrimSpace(s[len(includeDirective):])
+	return name, name != ""
+}
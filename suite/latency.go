@@ -0,0 +1,244 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/internal/hdr"
+)
+
+// ----------------------------------------------------------------------------
+// Latency histograms
+
+// LatencySummary summarises the request durations of (a part of) the
+// requests made during a throughput test. The durations are collected in
+// a HDR histogram with three significant digits.
+type LatencySummary struct {
+	Name   string // "All", the scenario name or "<scenario>: <test>"
+	Count  int    // Number of requests.
+	Errors int    // Number of requests with status Fail, Error or Bogus.
+
+	Mean, P50, P90, P99, P999, Max time.Duration
+
+	hist *hdr.Histogram // in microseconds
+}
+
+// ErrorRate returns the percentage of requests which did not pass.
+func (ls LatencySummary) ErrorRate() float64 {
+	if ls.Count == 0 {
+		return 0
+	}
+	return 100 * float64(ls.Errors) / float64(ls.Count)
+}
+
+// Percentile returns the request duration below which p percent of the
+// requests lie.
+func (ls LatencySummary) Percentile(p float64) time.Duration {
+	if ls.hist == nil {
+		return 0
+	}
+	return time.Duration(ls.hist.ValueAtPercentile(p)) * time.Microsecond
+}
+
+func (ls *LatencySummary) record(d TestData) {
+	if ls.hist == nil {
+		ls.hist = hdr.New(3)
+	}
+	ls.hist.Record(int64(d.ReqDuration / time.Microsecond))
+	ls.Count++
+	if d.Status > ht.Pass {
+		ls.Errors++
+	}
+}
+
+func (ls *LatencySummary) finish() {
+	ls.Mean = time.Duration(ls.hist.Mean()) * time.Microsecond
+	ls.P50 = ls.Percentile(50)
+	ls.P90 = ls.Percentile(90)
+	ls.P99 = ls.Percentile(99)
+	ls.P999 = ls.Percentile(99.9)
+	ls.Max = time.Duration(ls.hist.Max()) * time.Microsecond
+}
+
+// Latencies summarises the request durations in data: The first summary
+// covers all requests followed by one summary per scenario and one per test
+// of each scenario.
+func Latencies(data []TestData) []LatencySummary {
+	all := &LatencySummary{Name: "All"}
+	type key struct{ scenario, test int }
+	parts := make(map[key]*LatencySummary)
+	keys := []key{}
+	for _, d := range data {
+		all.record(d)
+		for _, k := range []key{{d.ID.Scenario, 0}, {d.ID.Scenario, d.ID.Test}} {
+			ls, ok := parts[k]
+			if !ok {
+				ls = &LatencySummary{Name: d.ID.ScenarioName}
+				if k.test != 0 {
+					ls.Name += ": " + d.ID.TestName
+				}
+				parts[k] = ls
+				keys = append(keys, k)
+			}
+			ls.record(d)
+		}
+	}
+	if all.Count == 0 {
+		return nil
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].scenario != keys[j].scenario {
+			return keys[i].scenario < keys[j].scenario
+		}
+		return keys[i].test < keys[j].test
+	})
+	all.finish()
+	summaries := []LatencySummary{*all}
+	for _, k := range keys {
+		parts[k].finish()
+		summaries = append(summaries, *parts[k])
+	}
+	return summaries
+}
+
+// PrintLatencies prints the summaries as a table to w.
+func PrintLatencies(w io.Writer, summaries []LatencySummary) {
+	width := 0
+	for _, ls := range summaries {
+		if len(ls.Name) > width {
+			width = len(ls.Name)
+		}
+	}
+	ms := func(d time.Duration) float64 { return float64(d/time.Microsecond) / 1000 }
+	fmt.Fprintf(w, "%-*s  %7s %7s %9s %9s %9s %9s %9s %9s\n", width, "Latency [ms]",
+		"Count", "Errors", "Mean", "p50", "p90", "p99", "p99.9", "Max")
+	for _, ls := range summaries {
+		fmt.Fprintf(w, "%-*s  %7d %6.2f%% %9.1f %9.1f %9.1f %9.1f %9.1f %9.1f\n",
+			width, ls.Name, ls.Count, ls.ErrorRate(), ms(ls.Mean),
+			ms(ls.P50), ms(ls.P90), ms(ls.P99), ms(ls.P999), ms(ls.Max))
+	}
+}
+
+// ----------------------------------------------------------------------------
+// SLA thresholds
+
+// An SLA is a threshold on the request latency or the error rate of a
+// throughput test. It is written like
+//     p99 < 800ms
+//     mean <= 250ms
+//     error rate < 1%
+//     Login: p99.9 < 2s
+// where the optional prefix (here "Login") restricts the SLA to the named
+// scenario or to a test (given as "<scenario>: <test>"); the SLA applies
+// to all requests otherwise. Latencies may be given as percentiles like
+// p50, p99 or p99.9 or as mean or max.
+type SLA struct {
+	Scope  string  // Name of the LatencySummary, empty for all requests.
+	Metric string  // p<percentile>, mean, max or errors.
+	Strict bool    // Strict uses < instead of <=.
+	Limit  float64 // in milliseconds or in percent for errors.
+
+	percentile float64
+	text       string
+}
+
+// ParseSLA parses s as an SLA.
+func ParseSLA(s string) (SLA, error) {
+	sla := SLA{text: strings.TrimSpace(s)}
+	expr := sla.text
+	if i := strings.LastIndex(expr, ":"); i != -1 {
+		sla.Scope = strings.TrimSpace(expr[:i])
+		expr = expr[i+1:]
+	}
+
+	var metric, limit string
+	if i := strings.Index(expr, "<="); i != -1 {
+		metric, limit = expr[:i], expr[i+2:]
+	} else if i := strings.Index(expr, "<"); i != -1 {
+		metric, limit = expr[:i], expr[i+1:]
+		sla.Strict = true
+	} else {
+		return sla, fmt.Errorf("sla %q: missing < or <=", s)
+	}
+	metric, limit = strings.ToLower(strings.TrimSpace(metric)), strings.TrimSpace(limit)
+
+	switch {
+	case metric == "error rate" || metric == "errors":
+		sla.Metric = "errors"
+		if !strings.HasSuffix(limit, "%") {
+			return sla, fmt.Errorf("sla %q: error rate must be given in percent", s)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(limit[:len(limit)-1]), 64)
+		if err != nil {
+			return sla, fmt.Errorf("sla %q: %s", s, err)
+		}
+		sla.Limit = v
+		return sla, nil
+	case metric == "mean" || metric == "max":
+		sla.Metric = metric
+	case strings.HasPrefix(metric, "p"):
+		p, err := strconv.ParseFloat(metric[1:], 64)
+		if err != nil || p <= 0 || p > 100 {
+			return sla, fmt.Errorf("sla %q: bad percentile %q", s, metric)
+		}
+		sla.Metric, sla.percentile = metric, p
+	default:
+		return sla, fmt.Errorf("sla %q: unknown metric %q", s, metric)
+	}
+
+	d, err := time.ParseDuration(limit)
+	if err != nil {
+		return sla, fmt.Errorf("sla %q: %s", s, err)
+	}
+	sla.Limit = float64(d) / float64(time.Millisecond)
+	return sla, nil
+}
+
+// String returns the SLA as given to ParseSLA.
+func (sla SLA) String() string { return sla.text }
+
+// Check if the SLA is fulfilled by the summaries.
+func (sla SLA) Check(summaries []LatencySummary) error {
+	scope := sla.Scope
+	if scope == "" {
+		scope = "All"
+	}
+	var ls *LatencySummary
+	for i := range summaries {
+		if summaries[i].Name == scope {
+			ls = &summaries[i]
+			break
+		}
+	}
+	if ls == nil {
+		return fmt.Errorf("sla %q: no requests for %q", sla.text, scope)
+	}
+
+	var actual float64
+	unit := "ms"
+	switch sla.Metric {
+	case "errors":
+		actual, unit = ls.ErrorRate(), "%"
+	case "mean":
+		actual = float64(ls.Mean) / float64(time.Millisecond)
+	case "max":
+		actual = float64(ls.Max) / float64(time.Millisecond)
+	default:
+		actual = float64(ls.Percentile(sla.percentile)) / float64(time.Millisecond)
+	}
+
+	if actual < sla.Limit || (!sla.Strict && actual == sla.Limit) {
+		return nil
+	}
+	return fmt.Errorf("sla %q violated: %s is %.2f%s", sla.text, sla.Metric, actual, unit)
+}
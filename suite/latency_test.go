@@ -0,0 +1,103 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vdobler/ht/ht"
+)
+
+func latencyTestData() []TestData {
+	data := []TestData{}
+	for i := 1; i <= 1000; i++ {
+		d := TestData{
+			Status:      ht.Pass,
+			ReqDuration: time.Duration(i) * time.Millisecond,
+			ID: TestIdentifier{
+				Scenario:     1,
+				Test:         1 + i%2,
+				ScenarioName: "Scen",
+				TestName:     []string{"Even", "Odd"}[i%2],
+			},
+		}
+		if i%100 == 0 {
+			d.Status = ht.Fail
+		}
+		data = append(data, d)
+	}
+	return data
+}
+
+func TestLatencies(t *testing.T) {
+	summaries := Latencies(latencyTestData())
+	if len(summaries) != 4 {
+		t.Fatalf("Got %d summaries, want 4", len(summaries))
+	}
+	for i, name := range []string{"All", "Scen", "Scen: Even", "Scen: Odd"} {
+		if summaries[i].Name != name {
+			t.Errorf("%d. summary: got name %q, want %q", i, summaries[i].Name, name)
+		}
+	}
+
+	all := summaries[0]
+	if all.Count != 1000 || all.Errors != 10 || all.ErrorRate() != 1 {
+		t.Errorf("Got Count=%d Errors=%d", all.Count, all.Errors)
+	}
+	for _, tc := range []struct {
+		got, want time.Duration
+	}{
+		{all.P50, 500 * time.Millisecond},
+		{all.P90, 900 * time.Millisecond},
+		{all.P99, 990 * time.Millisecond},
+		{all.P999, 999 * time.Millisecond},
+		{all.Max, 1000 * time.Millisecond},
+	} {
+		if diff := tc.got - tc.want; diff < -time.Millisecond || diff > time.Millisecond {
+			t.Errorf("Got %s, want %s", tc.got, tc.want)
+		}
+	}
+}
+
+func TestSLA(t *testing.T) {
+	summaries := Latencies(latencyTestData())
+
+	for i, tc := range []struct {
+		sla  string
+		okay bool
+	}{
+		{"p50 < 600ms", true},
+		{"p50 < 400ms", false},
+		{"p99.9 <= 1s", true},
+		{"mean < 0.6s", true},
+		{"max < 1s", false},
+		{"max <= 1s", true},
+		{"error rate < 2%", true},
+		{"errors < 1%", false},
+		{"errors <= 1%", true},
+		{"Scen: Odd: p50 < 450ms", false},
+		{"Scen: p50 < 600ms", true},
+		{"Other: p50 < 600ms", false},
+	} {
+		sla, err := ParseSLA(tc.sla)
+		if err != nil {
+			t.Errorf("%d. %q: unexpected error %s", i, tc.sla, err)
+			continue
+		}
+		err = sla.Check(summaries)
+		if tc.okay && err != nil {
+			t.Errorf("%d. %q: unexpected error %s", i, tc.sla, err)
+		} else if !tc.okay && err == nil {
+			t.Errorf("%d. %q: missing error", i, tc.sla)
+		}
+	}
+
+	for _, bad := range []string{"p50 = 1s", "p0 < 1s", "foo < 1s", "errors < 1", "p50 < 1"} {
+		if _, err := ParseSLA(bad); err == nil {
+			t.Errorf("Missing error for %q", bad)
+		}
+	}
+}
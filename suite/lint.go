@@ -0,0 +1,252 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vdobler/ht/errorlist"
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/internal/hjson"
+	"github.com/vdobler/ht/mock"
+	"github.com/vdobler/ht/populate"
+)
+
+// ----------------------------------------------------------------------------
+//   Linting
+
+// Lint reports problems in rs and the tests, mixins and mocks it uses
+// which do not prevent execution but are likely mistakes or hamper
+// maintenance:
+//   - fields not in the JSON Schema of the file, e.g. misspelled fields
+//   - use of deprecated fields
+//   - suite variables which are not used anywhere
+//   - variables passed to a test which the test does not use
+//   - tests with the same name
+// Lint returns nil if no problems are found.
+func (rs *RawSuite) Lint() errorlist.List {
+	el := errorlist.List{}
+	seen := map[string]bool{}
+	check := func(f *File, kind string) {
+		if seen[f.Name] || isInline(f) {
+			return
+		}
+		seen[f.Name] = true
+		if err := f.CheckSchema(kind); err != nil {
+			el = el.Append(err)
+		}
+		el = el.Append(f.lintDeprecated(kind))
+	}
+
+	check(rs.File, "suite")
+	names := map[string]string{}
+	for _, rt := range rs.tests {
+		check(rt.File, "test")
+		for _, mix := range rt.Mixins {
+			check(mix.File, "mixin")
+		}
+		for _, mf := range rt.mocks {
+			check(mf.File, "mock")
+		}
+
+		name := rt.testName()
+		if name == "" {
+			continue
+		}
+		if other, ok := names[name]; ok && other != rt.File.Name {
+			el = el.Append(fmt.Errorf("%s: test name %q already used by %s",
+				rt.File.Name, name, other))
+		} else if !ok {
+			names[name] = rt.File.Name
+		}
+	}
+
+	el = el.Append(rs.lintVariables())
+	if len(el) == 0 {
+		return nil
+	}
+	return el
+}
+
+// Referenced returns the names of all files used by rs: The suite file,
+// the files of its tests, their mixins and mocks and the variable files.
+func (rs *RawSuite) Referenced() []string {
+	seen := map[string]bool{rs.File.Name: true}
+	dir := rs.File.Dirname()
+	for _, vf := range rs.VariablesFrom {
+		seen[joinName(dir, vf)] = true
+	}
+	for _, p := range rs.Profiles {
+		for _, vf := range p.VariablesFrom {
+			seen[joinName(dir, vf)] = true
+		}
+	}
+	for _, rt := range rs.tests {
+		if !isInline(rt.File) {
+			seen[rt.File.Name] = true
+		}
+		for _, mix := range rt.Mixins {
+			seen[mix.File.Name] = true
+		}
+		for _, mf := range rt.mocks {
+			seen[mf.File.Name] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isInline reports whether f is the file of an inline test of a suite.
+func isInline(f *File) bool {
+	return strings.Contains(f.Name, "_inline-")
+}
+
+// testName returns the literal name of rt or its mixins.
+func (rt *RawTest) testName() string {
+	x := &struct{ Name string }{}
+	if rt.File.decodeLaxTo(x) == nil && x.Name != "" {
+		return x.Name
+	}
+	for _, mix := range rt.Mixins {
+		if mix.File.decodeLaxTo(x) == nil && x.Name != "" {
+			return x.Name
+		}
+	}
+	return ""
+}
+
+// lintDeprecated reports the deprecated fields used in f of the given
+// kind.
+func (f *File) lintDeprecated(kind string) error {
+	m, err := f.decode()
+	if err != nil {
+		return err
+	}
+	el := errorlist.List{}
+	defer func(w func(string, ...interface{})) { populate.Warn = w }(populate.Warn)
+	populate.Warn = func(format string, args ...interface{}) {
+		msg := strings.TrimPrefix(fmt.Sprintf(format, args...), "populate: ")
+		el = append(el, fmt.Errorf("%s: %s", f.Name, msg))
+	}
+
+	// Values are not substituted, so errors are expected and ignored.
+	switch kind {
+	case "suite":
+		populate.Lax(&RawSuite{}, m)
+	case "test", "mixin":
+		delete(m, "Mixin")
+		populate.Lax(&ht.Test{}, m)
+	case "mock":
+		populate.Lax(&mock.Mock{}, m)
+	}
+	if len(el) == 0 {
+		return nil
+	}
+	return el
+}
+
+// lintVariables reports the variables defined in the suite file which are
+// never used and the variables passed to a test which the test never uses.
+func (rs *RawSuite) lintVariables() error {
+	el := errorlist.List{}
+	doc, err := hjson.ParseDocument([]byte(rs.File.Data))
+	if err != nil {
+		return err
+	}
+	pos := func(path ...interface{}) string {
+		line, col := doc.Position(path...)
+		return fmt.Sprintf("%s:%d:%d", rs.File.Name, line, col)
+	}
+
+	defined := &struct {
+		Variables             map[string]string
+		Setup, Main, Teardown []RawElement
+	}{}
+	if err := rs.File.decodeLaxTo(defined); err != nil {
+		return err
+	}
+
+	// Usage of suite variables anywhere.
+	used := usedVariables(rs.File.Data)
+	for _, rt := range rs.tests {
+		for name := range usedVariables(rt.allData()) {
+			used[name] = true
+		}
+	}
+	for _, name := range sortedNames(defined.Variables) {
+		if !used[name] {
+			el = append(el, fmt.Errorf("%s: variable %s is never used",
+				pos("Variables", name), name))
+		}
+	}
+
+	// Usage of call variables in the called test.
+	i := 0
+	for _, part := range []struct {
+		which string
+		elems []RawElement
+	}{{"Setup", defined.Setup}, {"Main", defined.Main}, {"Teardown", defined.Teardown}} {
+		for j, elem := range part.elems {
+			if i >= len(rs.tests) {
+				break
+			}
+			rt := rs.tests[i]
+			i++
+			used := usedVariables(rt.allData())
+			for _, name := range sortedNames(elem.Variables) {
+				if !used[name] {
+					el = append(el, fmt.Errorf("%s: variable %s is not used by test %s",
+						pos(part.which, j, "Variables", name), name, rt.File.Name))
+				}
+			}
+		}
+	}
+
+	if len(el) == 0 {
+		return nil
+	}
+	return el
+}
+
+// allData returns the content of rt, its mixins and its mocks.
+func (rt *RawTest) allData() string {
+	data := []string{rt.File.Data}
+	for _, mix := range rt.Mixins {
+		data = append(data, mix.File.Data)
+	}
+	for _, mf := range rt.mocks {
+		data = append(data, mf.File.Data)
+	}
+	return strings.Join(data, "\n")
+}
+
+// usedVariables returns the names of the variables referenced in txt as
+// {{NAME}}, {{NAME | fmt}} or similar.
+func usedVariables(txt string) map[string]bool {
+	used := map[string]bool{}
+	for _, m := range unresolvedVarRe.FindAllString(txt, -1) {
+		name := strings.TrimSpace(m[2 : len(m)-2])
+		if i := strings.IndexAny(name, " \t|+-"); i > 0 {
+			name = name[:i]
+		}
+		used[name] = true
+	}
+	return used
+}
+
+func sortedNames(m map[string]string) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,73 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"reflect"
+	"testing"
+)
+
+var lintFS = `
+# lint.suite
+{
+    Name: "Lint"
+    Main: [
+        {File: "a.ht", Variables: {USER: "joe", UNUSED: "x"}}
+        {File: "b.ht"}
+        {File: "a.ht"}
+    ]
+    Variables: {
+        HOST: "example.org"
+        PORT: "8080"
+        PATH: "/"
+    }
+}
+
+# a.ht
+{
+    Name: "Same"
+    Mixin: ["m.mix"]
+    Request: {URL: "http://{{HOST}}{{PATH}}?u={{USER}}"}
+}
+
+# b.ht
+{
+    Name: "Same"
+    Request: {URL: "http://{{HOST}}/b"}
+    Chekcs: [ {Check: "StatusCode", Expect: 200} ]
+}
+
+# m.mix
+{
+    Description: "Mixin {{NOW | \"2006\"}}"
+}
+`
+
+func TestLint(t *testing.T) {
+	fs, err := NewFileSystem(lintFS)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	rs, err := LoadRawSuite("lint.suite", fs)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+
+	got := rs.Lint().AsStrings()
+	want := []string{
+		"file b.ht does not match schema: unknown field Chekcs in test (did you mean Checks?)",
+		`b.ht: test name "Same" already used by a.ht`,
+		"lint.suite:10:9: variable PORT is never used",
+		"lint.suite:4:49: variable UNUSED is not used by test a.ht",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %q", got)
+	}
+
+	ref := rs.Referenced()
+	if !reflect.DeepEqual(ref, []string{"a.ht", "b.ht", "lint.suite", "m.mix"}) {
+		t.Errorf("Got referenced %q", ref)
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/internal/hdr"
+)
+
+// ----------------------------------------------------------------------------
+// Live metrics
+
+// LiveMetrics collects metrics of a running throughput test per scenario.
+// They can be scraped by Prometheus (LiveMetrics is a http.Handler serving
+// the Prometheus text format) or pushed to a StatsD server to show a live
+// dashboard while the throughput test is running.
+//
+// Rates, error rates and latency percentiles are computed over the
+// requests finished during the last Window.
+type LiveMetrics struct {
+	// Window over which rates and percentiles are computed.
+	Window time.Duration
+
+	mu        sync.Mutex
+	started   time.Time
+	scenarios []*scenarioMetrics
+}
+
+type scenarioMetrics struct {
+	name     string
+	requests int64
+	errors   int64
+	threads  int
+	recent   []sample
+}
+
+type sample struct {
+	at       time.Time
+	duration time.Duration
+	failed   bool
+}
+
+// MetricsSnapshot are the live metrics of one scenario.
+type MetricsSnapshot struct {
+	Scenario      string        // Name of the scenario.
+	Requests      int64         // Total number of requests.
+	Errors        int64         // Total number of failed requests.
+	Threads       int           // Number of threads (virtual users).
+	Rate          float64       // Requests per second during Window.
+	ErrorRate     float64       // Fraction of failed requests during Window.
+	P50, P90, P99 time.Duration // Latency percentiles during Window.
+}
+
+// NewLiveMetrics returns live metrics computed over the given window.
+func NewLiveMetrics(window time.Duration) *LiveMetrics {
+	if window <= 0 {
+		window = 10 * time.Second
+	}
+	return &LiveMetrics{Window: window, started: time.Now()}
+}
+
+// reset the start time of m.
+func (m *LiveMetrics) reset(started time.Time) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.started = started
+}
+
+// scenario returns the metrics of the no'th scenario (0-based).
+func (m *LiveMetrics) scenario(no int, name string) *scenarioMetrics {
+	for len(m.scenarios) <= no {
+		m.scenarios = append(m.scenarios, &scenarioMetrics{})
+	}
+	sm := m.scenarios[no]
+	if sm.name == "" {
+		sm.name = name
+	}
+	return sm
+}
+
+// record the outcome of one request.
+func (m *LiveMetrics) record(d TestData) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sm := m.scenario(d.ID.Scenario-1, d.ID.ScenarioName)
+	sm.requests++
+	failed := d.Status > ht.Pass
+	if failed {
+		sm.errors++
+	}
+	now := time.Now()
+	sm.recent = append(sm.recent, sample{now, d.ReqDuration, failed})
+	sm.prune(now.Add(-m.Window))
+}
+
+// setThreads records the number of threads of the no'th scenario.
+func (m *LiveMetrics) setThreads(no int, name string, threads int) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scenario(no, name).threads = threads
+}
+
+// prune samples older than limit.
+func (sm *scenarioMetrics) prune(limit time.Time) {
+	i := 0
+	for i < len(sm.recent) && sm.recent[i].at.Before(limit) {
+		i++
+	}
+	sm.recent = sm.recent[i:]
+}
+
+// Snapshot returns the current metrics of all scenarios.
+func (m *LiveMetrics) Snapshot() []MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	window := m.Window
+	if running := now.Sub(m.started); running < window {
+		window = running
+	}
+	snapshots := make([]MetricsSnapshot, 0, len(m.scenarios))
+	for _, sm := range m.scenarios {
+		sm.prune(now.Add(-m.Window))
+		snap := MetricsSnapshot{
+			Scenario: sm.name,
+			Requests: sm.requests,
+			Errors:   sm.errors,
+			Threads:  sm.threads,
+		}
+		if n := len(sm.recent); n > 0 {
+			hist := hdr.New(3)
+			failed := 0
+			for _, s := range sm.recent {
+				hist.Record(int64(s.duration / time.Microsecond))
+				if s.failed {
+					failed++
+				}
+			}
+			if window > 0 {
+				snap.Rate = float64(n) / window.Seconds()
+			}
+			snap.ErrorRate = float64(failed) / float64(n)
+			snap.P50 = time.Duration(hist.ValueAtPercentile(50)) * time.Microsecond
+			snap.P90 = time.Duration(hist.ValueAtPercentile(90)) * time.Microsecond
+			snap.P99 = time.Duration(hist.ValueAtPercentile(99)) * time.Microsecond
+		}
+		snapshots = append(snapshots, snap)
+	}
+	return snapshots
+}
+
+// ServeHTTP serves the current metrics in the Prometheus text format.
+func (m *LiveMetrics) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(m.prometheus())
+}
+
+func (m *LiveMetrics) prometheus() []byte {
+	snapshots := m.Snapshot()
+	buf := &bytes.Buffer{}
+	metric := func(name, typ, help string, value func(s MetricsSnapshot) float64) {
+		fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+		for _, s := range snapshots {
+			fmt.Fprintf(buf, "%s{scenario=%s} %g\n", name,
+				promLabel(s.Scenario), value(s))
+		}
+	}
+	metric("ht_requests_total", "counter", "Number of requests made.",
+		func(s MetricsSnapshot) float64 { return float64(s.Requests) })
+	metric("ht_errors_total", "counter", "Number of requests which did not pass.",
+		func(s MetricsSnapshot) float64 { return float64(s.Errors) })
+	metric("ht_requests_per_second", "gauge", "Current request rate.",
+		func(s MetricsSnapshot) float64 { return s.Rate })
+	metric("ht_error_ratio", "gauge", "Current fraction of requests which did not pass.",
+		func(s MetricsSnapshot) float64 { return s.ErrorRate })
+	metric("ht_virtual_users", "gauge", "Number of threads generating load.",
+		func(s MetricsSnapshot) float64 { return float64(s.Threads) })
+
+	fmt.Fprintf(buf, "# HELP ht_latency_seconds Current request latency.\n# TYPE ht_latency_seconds summary\n")
+	for _, s := range snapshots {
+		for _, q := range []struct {
+			q string
+			d time.Duration
+		}{{"0.5", s.P50}, {"0.9", s.P90}, {"0.99", s.P99}} {
+			fmt.Fprintf(buf, "ht_latency_seconds{scenario=%s,quantile=%q} %g\n",
+				promLabel(s.Scenario), q.q, q.d.Seconds())
+		}
+	}
+	return buf.Bytes()
+}
+
+// promLabel quotes s as a label value in the Prometheus text format.
+func promLabel(s string) string {
+	return `"` + promEscaper.Replace(s) + `"`
+}
+
+var promEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// PushStatsD sends the current metrics as StatsD gauges over UDP to addr
+// every interval until stop is closed. Gauges are named like
+// "ht.<scenario>.rps" with the scenario name sanitised.
+func (m *LiveMetrics) PushStatsD(addr string, interval time.Duration, stop chan bool) error {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		defer conn.Close()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				conn.Write(m.statsd())
+			}
+		}
+	}()
+	return nil
+}
+
+func (m *LiveMetrics) statsd() []byte {
+	buf := &bytes.Buffer{}
+	for _, s := range m.Snapshot() {
+		prefix := "ht." + statsdName(s.Scenario)
+		fmt.Fprintf(buf, "%s.requests:%d|g\n", prefix, s.Requests)
+		fmt.Fprintf(buf, "%s.errors:%d|g\n", prefix, s.Errors)
+		fmt.Fprintf(buf, "%s.rps:%g|g\n", prefix, s.Rate)
+		fmt.Fprintf(buf, "%s.error_ratio:%g|g\n", prefix, s.ErrorRate)
+		fmt.Fprintf(buf, "%s.vus:%d|g\n", prefix, s.Threads)
+		fmt.Fprintf(buf, "%s.p50:%g|g\n", prefix, s.P50.Seconds()*1000)
+		fmt.Fprintf(buf, "%s.p90:%g|g\n", prefix, s.P90.Seconds()*1000)
+		fmt.Fprintf(buf, "%s.p99:%g|g\n", prefix, s.P99.Seconds()*1000)
+	}
+	return buf.Bytes()
+}
+
+// statsdName replaces everything except letters, digits, '-' and '_' in
+// name by '_'.
+func statsdName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+			r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,97 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vdobler/ht/ht"
+)
+
+func liveMetricsForTest() *LiveMetrics {
+	m := NewLiveMetrics(time.Minute)
+	m.setThreads(0, `A "quoted" name`, 3)
+	for i := 1; i <= 100; i++ {
+		status := ht.Pass
+		if i%10 == 0 {
+			status = ht.Error
+		}
+		m.record(TestData{
+			Status:      status,
+			ReqDuration: time.Duration(i) * time.Millisecond,
+			ID:          TestIdentifier{Scenario: 1, ScenarioName: `A "quoted" name`},
+		})
+	}
+	m.record(TestData{
+		Status:      ht.Pass,
+		ReqDuration: time.Millisecond,
+		ID:          TestIdentifier{Scenario: 2, ScenarioName: "B"},
+	})
+	return m
+}
+
+func TestLiveMetricsSnapshot(t *testing.T) {
+	snaps := liveMetricsForTest().Snapshot()
+	if len(snaps) != 2 {
+		t.Fatalf("Got %d snapshots, want 2", len(snaps))
+	}
+	a := snaps[0]
+	if a.Requests != 100 || a.Errors != 10 || a.Threads != 3 ||
+		a.ErrorRate != 0.1 || a.Rate <= 0 {
+		t.Errorf("Bad snapshot %+v", a)
+	}
+	// HDR histograms are accurate to 3 significant digits.
+	if a.P50 < 50*time.Millisecond || a.P50 > 50050*time.Microsecond ||
+		a.P99 < 99*time.Millisecond || a.P99 > 99099*time.Microsecond {
+		t.Errorf("Bad percentiles %s %s", a.P50, a.P99)
+	}
+}
+
+func TestLiveMetricsPrometheus(t *testing.T) {
+	rr := httptest.NewRecorder()
+	liveMetricsForTest().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	body := rr.Body.String()
+	for _, want := range []string{
+		"# TYPE ht_requests_total counter\n",
+		`ht_requests_total{scenario="A \"quoted\" name"} 100` + "\n",
+		`ht_errors_total{scenario="A \"quoted\" name"} 10` + "\n",
+		`ht_virtual_users{scenario="B"} 0` + "\n",
+		`ht_latency_seconds{scenario="A \"quoted\" name",quantile="0.5"} 0.050`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Missing %q in\n%s", want, body)
+		}
+	}
+}
+
+func TestLiveMetricsStatsD(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Cannot listen on UDP: %s", err)
+	}
+	defer conn.Close()
+
+	stop := make(chan bool)
+	defer close(stop)
+	err = liveMetricsForTest().PushStatsD(conn.LocalAddr().String(), 10*time.Millisecond, stop)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 4096)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	got := string(buf[:n])
+	if !strings.Contains(got, "ht.A__quoted__name.requests:100|g\n") ||
+		!strings.Contains(got, "ht.B.vus:0|g\n") {
+		t.Errorf("Got %s", got)
+	}
+}
@@ -155,3 +155,62 @@ func TestMockSuite(t *testing.T) {
 		}
 	}
 }
+
+func TestMockJournal(t *testing.T) {
+	txt := `
+# journal.suite
+{
+    Name: Suite with mock journal
+    Main: [
+        { File: "pipe.ht", Mocks: [ "surename.mock", "lastname.mock" ] }
+    ]
+}
+
+# pipe.ht
+{
+    Name: "Pipe"
+    Request: { URL: "{{BASEURL}}/users/penny" }
+    Checks: [
+        {Check: "StatusCode", Expect: 200}
+        {Check: "MockRequest", Mock: "Surename", Count: 1, Method: "GET",
+            Header: {"X-Authorized": {Equals: "okay"}}}
+        {Check: "MockRequest", Mock: "Lastname", Body: {Equals: "userid=penny"}}
+    ]
+    DataExtraction: {
+        AUTH: {Extractor: "MockExtractor", Mock: "Surename", Header: "X-Authorized"}
+        USER: {Extractor: "MockExtractor", Mock: "Lastname", Regexp: "userid=(.*)", Submatch: 1}
+    }
+}
+
+# surename.mock
+{
+    Name: "Surename"
+    URL:  "http://localhost:9901/surenameservice/{USERID}"
+    Response: { Body: '''{"status": "okay", "surename": "Penny", "userid": "{{USERID}}"}''' }
+}
+
+# lastname.mock
+{
+    Name: "Lastname"
+    Method: POST
+    URL:  "http://localhost:9902/rest/v7/lookup"
+    ParseForm: true
+    Response: { Body: "{{userid}},Hofstadter" }
+}`
+
+	rs, err := parseRawSuite("journal.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(pipeHandler))
+	defer ts.Close()
+
+	s := rs.Execute(map[string]string{"BASEURL": ts.URL}, nil, logger())
+	if s.Status != ht.Pass {
+		s.PrintReport(os.Stdout)
+		t.Fatalf("Got status %s, want Pass: %v", s.Status, s.Error)
+	}
+	if got := s.FinalVariables["AUTH"] + " " + s.FinalVariables["USER"]; got != "okay penny" {
+		t.Errorf("Got extracted %q", got)
+	}
+}
@@ -0,0 +1,291 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/scope"
+)
+
+// ----------------------------------------------------------------------------
+//   Notifier
+
+// DefaultNotifyTemplate is the message template used by a Notifier
+// without a Template.
+var DefaultNotifyTemplate = `ht run {{.Status}}: {{.Pass}} of {{.Total}} tests passed
+{{- if .Fail}}, {{.Fail}} failed{{end}}
+{{- if .Error}}, {{.Error}} errored{{end}}
+{{- if .Bogus}}, {{.Bogus}} bogus{{end}}
+{{- if .Skipped}}, {{.Skipped}} skipped{{end}}
+{{- if .Previous}} (previous run: {{.Previous}}){{end}}
+{{range .Suites}}
+{{.Status}}  {{.Name}}{{end}}
+{{range .Problems}}
+{{.}}{{end}}
+{{if .Details}}
+{{.Details}}{{end}}`
+
+// A Notifier posts a summary of a run of suites to a webhook, e.g. of
+// Slack or Microsoft Teams.
+type Notifier struct {
+	// URL of the webhook.
+	URL string
+
+	// Format of the posted JSON payload:
+	//     "slack":   {"text": message}
+	//     "teams":   a MessageCard with the message as text
+	//     "generic": {"status": ..., "text": message, "suites": [...]}
+	//                with the suites in the format of SuiteResult.
+	// An empty Format is guessed from the URL.
+	Format string
+
+	// Template is the text/template producing the message from
+	// NotifyData. The empty string selects DefaultNotifyTemplate.
+	Template string
+
+	// MaxDetails limits the size in bytes of the failure details in
+	// the message. Zero means 2000 bytes, a negative value omits the
+	// details.
+	MaxDetails int
+
+	// StateFile records the overall status of the last run. If set, a
+	// notification is posted only if the status of this run is worse
+	// than the one recorded.
+	StateFile string
+
+	// Client used to post; nil means http.DefaultClient.
+	Client *http.Client
+}
+
+// NotifyData is the data the message template is executed on.
+type NotifyData struct {
+	Status   ht.Status // Status of the whole run.
+	Previous string    // Previous status if recorded in the state file.
+
+	Total, Pass, Fail, Error, Bogus, Skipped int // Number of tests.
+
+	Suites []*SuiteResult
+
+	// Problems of the run not tied to a test, e.g. insufficient
+	// coverage. Any problem makes the Status at least Fail.
+	Problems []string
+
+	// Details lists the failed tests with their errors, truncated to
+	// the size limit of the Notifier.
+	Details string
+}
+
+// Notify posts a summary of suites to the webhook unless n.StateFile
+// records a status of a previous run which is at least as bad as the
+// status of suites. It reports whether a notification was posted. The
+// status is recorded in n.StateFile unless posting the notification
+// failed. Errors do not contain the secret parts of n.URL. See NotifyData
+// for problems.
+func (n *Notifier) Notify(suites []*Suite, problems ...string) (bool, error) {
+	data := n.summary(suites)
+	data.Problems = problems
+	if len(problems) > 0 && data.Status < ht.Fail {
+		data.Status = ht.Fail
+	}
+
+	if n.StateFile != "" {
+		previous, err := readNotifyState(n.StateFile)
+		if err != nil {
+			return false, err
+		}
+		if previous != nil {
+			data.Previous = previous.String()
+		}
+		if previous != nil && data.Status <= *previous {
+			return false, writeNotifyState(n.StateFile, data.Status)
+		}
+	}
+
+	text := n.Template
+	if text == "" {
+		text = DefaultNotifyTemplate
+	}
+	tmpl, err := template.New("notify").Parse(text)
+	if err != nil {
+		return false, err
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		return false, err
+	}
+	message := scope.Mask(strings.TrimSpace(buf.String()))
+
+	payload, err := n.payload(message, data)
+	if err != nil {
+		return false, err
+	}
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Post(n.URL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		if ue, ok := err.(*url.Error); ok {
+			ue.URL = n.Redacted()
+		}
+		return false, err
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return false, fmt.Errorf("notification to %s failed: %s %s",
+			n.Redacted(), resp.Status, bytes.TrimSpace(body))
+	}
+	if n.StateFile != "" {
+		// Record the status only once it has been notified.
+		if err := writeNotifyState(n.StateFile, data.Status); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// Redacted returns the URL of the webhook without path, query and user
+// information as these typically contain the secret token of the webhook.
+func (n *Notifier) Redacted() string {
+	u, err := url.Parse(n.URL)
+	if err != nil || u.Host == "" {
+		return "webhook"
+	}
+	redacted := url.URL{Scheme: u.Scheme, Host: u.Host}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+		redacted.Path = "/..."
+	}
+	return redacted.String()
+}
+
+// summary collects the NotifyData of suites.
+func (n *Notifier) summary(suites []*Suite) NotifyData {
+	data := NotifyData{Status: ht.NotRun}
+	details := &bytes.Buffer{}
+	limit := n.MaxDetails
+	if limit == 0 {
+		limit = 2000
+	}
+	omitted := 0
+	for _, s := range suites {
+		if s.Status > data.Status {
+			data.Status = s.Status
+		}
+		data.Suites = append(data.Suites, s.Result())
+		for _, test := range s.Tests {
+			data.Total++
+			switch test.Result.Status {
+			case ht.Pass:
+				data.Pass++
+			case ht.Fail:
+				data.Fail++
+			case ht.Error:
+				data.Error++
+			case ht.Bogus:
+				data.Bogus++
+			case ht.Skipped:
+				data.Skipped++
+			}
+			if test.Result.Status <= ht.Pass {
+				continue
+			}
+			detail := fmt.Sprintf("%s %s: %s %s\n", test.Result.Status, s.Name,
+				test.GetStringMetadata("SeqNo"), test.Name)
+			for _, msg := range errorStrings(test.Result.Error) {
+				detail += "    " + msg + "\n"
+			}
+			if limit < 0 || details.Len()+len(detail) > limit {
+				omitted++
+				continue
+			}
+			details.WriteString(detail)
+		}
+	}
+	if omitted > 0 && limit >= 0 {
+		fmt.Fprintf(details, "... and %d more failed tests\n", omitted)
+	}
+	data.Details = strings.TrimSpace(details.String())
+	return data
+}
+
+// payload formats message as JSON payload for n.Format.
+func (n *Notifier) payload(message string, data NotifyData) ([]byte, error) {
+	format := n.Format
+	if format == "" {
+		format = guessNotifyFormat(n.URL)
+	}
+	switch format {
+	case "slack":
+		return json.Marshal(map[string]string{"text": message})
+	case "teams":
+		color := "2EB886" // green
+		if data.Status > ht.Pass {
+			color = "E01E5A" // red
+		}
+		return json.Marshal(map[string]string{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    "ht run " + data.Status.String(),
+			"themeColor": color,
+			"text":       strings.Replace(message, "\n", "  \n", -1),
+		})
+	case "generic":
+		p, err := json.Marshal(map[string]interface{}{
+			"status": data.Status.String(),
+			"text":   message,
+			"suites": data.Suites,
+		})
+		return []byte(scope.Mask(string(p))), err
+	}
+	return nil, fmt.Errorf("unknown notification format %q", format)
+}
+
+// guessNotifyFormat returns the format of the webhook at url.
+func guessNotifyFormat(url string) string {
+	switch {
+	case strings.Contains(url, "hooks.slack.com"):
+		return "slack"
+	case strings.Contains(url, ".office.com"), strings.Contains(url, ".logic.azure.com"):
+		return "teams"
+	}
+	return "generic"
+}
+
+// readNotifyState returns the status recorded in filename or nil if
+// filename does not exist.
+func readNotifyState(filename string) (*ht.Status, error) {
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	state := struct{ Status string }{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("malformed notification state %s: %s", filename, err)
+	}
+	for s := ht.NotRun; s <= ht.Bogus; s++ {
+		if s.String() == state.Status {
+			return &s, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown status %q in %s", state.Status, filename)
+}
+
+func writeNotifyState(filename string, status ht.Status) error {
+	data, _ := json.Marshal(struct{ Status string }{status.String()})
+	return ioutil.WriteFile(filename, data, 0666)
+}
@@ -0,0 +1,181 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vdobler/ht/errorlist"
+	"github.com/vdobler/ht/ht"
+)
+
+func notifySuite(status ht.Status, failures int) *Suite {
+	s := &Suite{Name: "Shop", Status: status}
+	for i := 0; i < 3; i++ {
+		test := &ht.Test{Name: fmt.Sprintf("Test %d", i+1)}
+		test.SetMetadata("SeqNo", fmt.Sprintf("Main-%02d", i+1))
+		test.Result.Status = ht.Pass
+		if i < failures {
+			test.Result.Status = status
+			test.Result.Error = errorlist.List{fmt.Errorf("error %d", i+1)}
+		}
+		s.Tests = append(s.Tests, test)
+	}
+	return s
+}
+
+func TestNotifier(t *testing.T) {
+	var posted []map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		p := map[string]interface{}{}
+		if err := json.Unmarshal(body, &p); err != nil {
+			t.Errorf("Bad payload: %s\n%s", err, body)
+		}
+		posted = append(posted, p)
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "notify")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	n := &Notifier{
+		URL:        ts.URL,
+		Format:     "slack",
+		MaxDetails: 60,
+		StateFile:  filepath.Join(dir, "state.json"),
+	}
+	for i, tc := range []struct {
+		status   ht.Status
+		failures int
+		want     bool
+	}{
+		{ht.Pass, 0, true},  // no previous run
+		{ht.Pass, 0, false}, // not worse
+		{ht.Fail, 2, true},  // worse
+		{ht.Fail, 1, false}, // not worse
+		{ht.Error, 3, true}, // worse
+	} {
+		got, err := n.Notify([]*Suite{notifySuite(tc.status, tc.failures)})
+		if err != nil {
+			t.Fatalf("%d. Unexpected error: %s", i, err)
+		}
+		if got != tc.want {
+			t.Errorf("%d. Got %t, want %t", i, got, tc.want)
+		}
+	}
+	if len(posted) != 3 {
+		t.Fatalf("Got %d notifications", len(posted))
+	}
+
+	text := posted[1]["text"].(string)
+	for _, want := range []string{
+		"ht run Fail: 1 of 3 tests passed, 2 failed (previous run: Pass)",
+		"Fail  Shop",
+		"Fail Shop: Main-01 Test 1\n    error 1",
+		"... and 1 more failed tests",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Missing %q in\n%s", want, text)
+		}
+	}
+}
+
+func TestNotifierFailure(t *testing.T) {
+	status := http.StatusInternalServerError
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "notify")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	n := &Notifier{
+		URL:       ts.URL + "/services/T00/B00/secret-token",
+		Format:    "slack",
+		StateFile: filepath.Join(dir, "state.json"),
+	}
+	failed := []*Suite{notifySuite(ht.Fail, 1)}
+	posted, err := n.Notify(failed)
+	if posted || err == nil || strings.Contains(err.Error(), "secret-token") ||
+		!strings.Contains(err.Error(), ts.URL+"/...") {
+		t.Errorf("Got %t, %v", posted, err)
+	}
+	if _, err := os.Stat(n.StateFile); !os.IsNotExist(err) {
+		t.Errorf("State recorded although notification failed: %v", err)
+	}
+
+	// The failed notification is retried with the next run.
+	status = http.StatusOK
+	posted, err = n.Notify(failed)
+	if !posted || err != nil {
+		t.Errorf("Got %t, %v", posted, err)
+	}
+
+	n.URL = "http://127.0.0.1:0/hook?token=secret-token"
+	_, err = n.Notify([]*Suite{notifySuite(ht.Error, 1)})
+	if err == nil || strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("Got %v", err)
+	}
+}
+
+func TestNotifierProblems(t *testing.T) {
+	var text string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := map[string]string{}
+		json.NewDecoder(r.Body).Decode(&p)
+		text = p["text"]
+	}))
+	defer ts.Close()
+
+	n := &Notifier{URL: ts.URL, Format: "slack"}
+	problem := "OpenAPI coverage of 40.0% is below the required 80.0%"
+	if _, err := n.Notify([]*Suite{notifySuite(ht.Pass, 0)}, problem); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !strings.HasPrefix(text, "ht run Fail: 3 of 3 tests passed") ||
+		!strings.Contains(text, "\n"+problem) {
+		t.Errorf("Got %s", text)
+	}
+}
+
+func TestNotifierPayload(t *testing.T) {
+	data := NotifyData{Status: ht.Fail}
+	for _, tc := range []struct {
+		url, format, want string
+	}{
+		{"https://hooks.slack.com/services/X", "", `{"text":"hello"}`},
+		{"https://example.webhook.office.com/webhookb2/X", "", `"@type":"MessageCard"`},
+		{"https://example.org/hook", "", `"status":"Fail"`},
+		{"https://example.org/hook", "teams", `"themeColor":"E01E5A"`},
+	} {
+		n := &Notifier{URL: tc.url, Format: tc.format}
+		p, err := n.payload("hello", data)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", tc.url, err)
+		} else if !strings.Contains(string(p), tc.want) {
+			t.Errorf("%s: got %s", tc.url, p)
+		}
+	}
+	n := &Notifier{URL: "https://example.org", Format: "irc"}
+	if _, err := n.payload("hello", data); err == nil {
+		t.Errorf("Missing error for unknown format")
+	}
+}
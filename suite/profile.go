@@ -0,0 +1,314 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vdobler/ht/scope"
+)
+
+// ----------------------------------------------------------------------------
+// Rate profiles
+
+// A RateStage is one stage of a RateProfile.
+type RateStage struct {
+	// Duration of this stage.
+	Duration time.Duration
+
+	// Rate is the request rate (QPS) during this stage.
+	Rate float64
+
+	// Ramp the request rate linearly from the rate at the end of the
+	// previous stage (0 for the first stage) to Rate during this stage
+	// instead of jumping to Rate immediately.
+	Ramp bool
+}
+
+// A RateProfile describes how the request rate of a scenario changes during
+// a load test as a sequence of stages:
+//   - A ramp-up from 0 to 50 requests/second during two minutes is a
+//     single stage {Duration: "2m", Rate: 50, Ramp: true}.
+//   - A step profile is a sequence of stages without Ramp and increasing
+//     Rates.
+//   - A spike is a short stage with a high Rate, e.g. {Duration: "5s",
+//     Rate: 500}, followed by a stage with the normal rate.
+// After the last stage the rate of the last stage is kept.
+type RateProfile []RateStage
+
+// Rate returns the request rate of the profile after the given elapsed time.
+func (p RateProfile) Rate(elapsed time.Duration) float64 {
+	prev := 0.0
+	for _, stage := range p {
+		if elapsed < stage.Duration {
+			if !stage.Ramp || stage.Duration <= 0 {
+				return stage.Rate
+			}
+			f := float64(elapsed) / float64(stage.Duration)
+			return prev + f*(stage.Rate-prev)
+		}
+		elapsed -= stage.Duration
+		prev = stage.Rate
+	}
+	return prev
+}
+
+// ramping reports whether the profile is in a Ramp stage after the given
+// elapsed time.
+func (p RateProfile) ramping(elapsed time.Duration) bool {
+	for _, stage := range p {
+		if elapsed < stage.Duration {
+			return stage.Ramp
+		}
+		elapsed -= stage.Duration
+	}
+	return false
+}
+
+// resume returns the time after elapsed at which the rate of the profile
+// becomes positive or -1 if it stays zero.
+func (p RateProfile) resume(elapsed time.Duration) time.Duration {
+	start := time.Duration(0)
+	for _, stage := range p {
+		end := start + stage.Duration
+		if end > elapsed && stage.Rate > 0 {
+			if start < elapsed {
+				return 0
+			}
+			return start - elapsed
+		}
+		start = end
+	}
+	return -1
+}
+
+// Peak returns the maximal request rate of the profile.
+func (p RateProfile) Peak() float64 {
+	peak := 0.0
+	for _, stage := range p {
+		if stage.Rate > peak {
+			peak = stage.Rate
+		}
+	}
+	return peak
+}
+
+// String returns a compact representation of p like "0→50/2m0s 500/5s".
+func (p RateProfile) String() string {
+	parts := make([]string, len(p))
+	prev := 0.0
+	for i, stage := range p {
+		if stage.Ramp {
+			parts[i] = fmt.Sprintf("%g→%g/%s", prev, stage.Rate, stage.Duration)
+		} else {
+			parts[i] = fmt.Sprintf("%g/%s", stage.Rate, stage.Duration)
+		}
+		prev = stage.Rate
+	}
+	return strings.Join(parts, " ")
+}
+
+// validate p.
+func (p RateProfile) validate() error {
+	for i, stage := range p {
+		if stage.Duration <= 0 {
+			return fmt.Errorf("stage %d of rate profile has no Duration", i+1)
+		}
+		if stage.Rate < 0 {
+			return fmt.Errorf("stage %d of rate profile has negative Rate", i+1)
+		}
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+// Think time
+
+// ThinkTime describes the pause a simulated user takes between two
+// consecutive tests of a scenario.
+type ThinkTime struct {
+	// Distribution of the think time:
+	//   "fixed" (or empty): always Mean
+	//   "uniform":          uniformly distributed between Min and Max
+	//   "exponential":      exponentially distributed with average Mean,
+	//                       but never longer than Max (if Max > 0)
+	Distribution string
+
+	Mean, Min, Max time.Duration
+}
+
+// Duration returns a random think time drawn from tt's distribution.
+func (tt ThinkTime) Duration() time.Duration {
+	switch tt.Distribution {
+	case "uniform":
+		if tt.Max <= tt.Min {
+			return tt.Min
+		}
+		return tt.Min + time.Duration(scope.RandomInt63n(int64(tt.Max-tt.Min)))
+	case "exponential":
+		d := time.Duration(scope.RandomExpFloat64() * float64(tt.Mean))
+		if tt.Max > 0 && d > tt.Max {
+			d = tt.Max
+		}
+		return d
+	}
+	return tt.Mean
+}
+
+// validate tt.
+func (tt ThinkTime) validate() error {
+	switch tt.Distribution {
+	case "", "fixed", "exponential":
+	case "uniform":
+		if tt.Max < tt.Min {
+			return fmt.Errorf("think time Max %s less than Min %s", tt.Max, tt.Min)
+		}
+	default:
+		return fmt.Errorf("unknown think time distribution %q", tt.Distribution)
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+// Schedule
+
+// A schedule determines the request rate of each scenario of a throughput
+// test over time: Scenarios with a Profile follow their profile; the other
+// scenarios share the target rate according to their Percentage after
+// an initial linear ramp.
+type schedule struct {
+	scenarios []Scenario
+	rate      float64       // target rate of scenarios without profile
+	ramp      time.Duration // ramp of scenarios without profile
+}
+
+func newSchedule(scenarios []Scenario, opts ThroughputOptions) *schedule {
+	return &schedule{
+		scenarios: scenarios,
+		rate:      opts.Rate,
+		ramp:      opts.Ramp,
+	}
+}
+
+// rates returns the request rate of each scenario after elapsed.
+func (s *schedule) rates(elapsed time.Duration) []float64 {
+	factor := 1.0
+	if s.ramp > 0 && elapsed < s.ramp {
+		factor = float64(elapsed) / float64(s.ramp)
+	}
+	rates := make([]float64, len(s.scenarios))
+	for i, sc := range s.scenarios {
+		if len(sc.Profile) > 0 {
+			rates[i] = sc.Profile.Rate(elapsed)
+		} else {
+			rates[i] = factor * s.rate * float64(sc.Percentage) / 100
+		}
+	}
+	return rates
+}
+
+// peak returns the maximal total request rate.
+func (s *schedule) peak() float64 {
+	peak := 0.0
+	for _, sc := range s.scenarios {
+		if len(sc.Profile) > 0 {
+			peak += sc.Profile.Peak()
+		} else {
+			peak += s.rate * float64(sc.Percentage) / 100
+		}
+	}
+	return peak
+}
+
+// total returns the total request rate after elapsed. Do not let the
+// effective rate drop below 5% of the peak rate during ramps: Otherwise
+// a ramp from 0 does not really start as basically no requests are
+// generated. Stages with an explicit zero rate are kept.
+func (s *schedule) total(elapsed time.Duration) float64 {
+	total := 0.0
+	for _, r := range s.rates(elapsed) {
+		total += r
+	}
+	if min := 0.05 * s.peak(); total < min && s.ramping(elapsed) {
+		total = min
+	}
+	return total
+}
+
+// ramping reports whether any scenario is ramping up or down after elapsed.
+func (s *schedule) ramping(elapsed time.Duration) bool {
+	for _, sc := range s.scenarios {
+		if len(sc.Profile) > 0 {
+			if sc.Profile.ramping(elapsed) {
+				return true
+			}
+		} else if elapsed < s.ramp && sc.Percentage > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// pause returns how long after elapsed the total request rate becomes
+// positive again; this is 0 if the rate is positive and -1 if it stays 0.
+func (s *schedule) pause(elapsed time.Duration) time.Duration {
+	if s.total(elapsed) > 0 {
+		return 0
+	}
+	pause := time.Duration(-1)
+	for _, sc := range s.scenarios {
+		if len(sc.Profile) == 0 {
+			continue // has a positive rate after the ramp
+		}
+		if r := sc.Profile.resume(elapsed); r >= 0 && (pause < 0 || r < pause) {
+			pause = r
+		}
+	}
+	return pause
+}
+
+// choose randomly one of the scenarios with a probability proportional
+// to its rate after elapsed.
+func (s *schedule) choose(elapsed time.Duration) int {
+	rates := s.rates(elapsed)
+	sum := 0.0
+	for _, r := range rates {
+		sum += r
+	}
+	if sum <= 0 {
+		return scope.RandomIntn(len(rates))
+	}
+	x := scope.RandomFloat64() * sum
+	for i, r := range rates {
+		if x < r {
+			return i
+		}
+		x -= r
+	}
+	return len(rates) - 1
+}
+
+// percentages returns the percentage of requests each scenario is
+// expected to contribute to the requests made between from and to.
+func (s *schedule) percentages(from, to time.Duration) []float64 {
+	const steps = 1000
+	sums := make([]float64, len(s.scenarios))
+	total := 0.0
+	for k := 0; k < steps; k++ {
+		elapsed := from + time.Duration((float64(k)+0.5)*float64(to-from)/steps)
+		for i, r := range s.rates(elapsed) {
+			sums[i] += r
+			total += r
+		}
+	}
+	for i := range sums {
+		if total > 0 {
+			sums[i] = 100 * sums[i] / total
+		}
+	}
+	return sums
+}
@@ -0,0 +1,192 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/vdobler/ht/scope"
+)
+
+func TestRateProfile(t *testing.T) {
+	profile := RateProfile{
+		{Duration: 10 * time.Second, Rate: 20, Ramp: true},
+		{Duration: 10 * time.Second, Rate: 20},
+		{Duration: 2 * time.Second, Rate: 200},
+		{Duration: 10 * time.Second, Rate: 0, Ramp: true},
+	}
+
+	for i, tc := range []struct {
+		elapsed time.Duration
+		want    float64
+	}{
+		{0, 0},
+		{5 * time.Second, 10},
+		{10 * time.Second, 20},
+		{19 * time.Second, 20},
+		{21 * time.Second, 200},
+		{22 * time.Second, 200}, // ramp down starts from spike
+		{27 * time.Second, 100},
+		{40 * time.Second, 0},
+	} {
+		if got := profile.Rate(tc.elapsed); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("%d. Rate(%s)=%g, want %g", i, tc.elapsed, got, tc.want)
+		}
+	}
+
+	if got := profile.Peak(); got != 200 {
+		t.Errorf("Peak()=%g, want 200", got)
+	}
+	if got, want := profile.String(), "0→20/10s 20/10s 200/2s 200→0/10s"; got != want {
+		t.Errorf("String()=%q, want %q", got, want)
+	}
+	if err := (RateProfile{{Rate: 10}}).validate(); err == nil {
+		t.Errorf("Missing error for stage without Duration")
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	scenarios := []Scenario{
+		{Name: "A", Percentage: 100},
+		{Name: "B", Profile: RateProfile{
+			{Duration: 5 * time.Second, Rate: 30},
+			{Duration: 5 * time.Second, Rate: 10},
+		}},
+	}
+	sched := newSchedule(scenarios, ThroughputOptions{Rate: 10, Ramp: 0})
+
+	if got := sched.peak(); got != 40 {
+		t.Errorf("peak()=%g, want 40", got)
+	}
+	if got := sched.total(2 * time.Second); got != 40 {
+		t.Errorf("total(2s)=%g, want 40", got)
+	}
+
+	// The floor of 5% of the peak rate applies to ramps only.
+	paused := newSchedule([]Scenario{{Name: "C", Profile: RateProfile{
+		{Duration: 10 * time.Second, Rate: 100, Ramp: true},
+		{Duration: 5 * time.Second, Rate: 0},
+		{Duration: 5 * time.Second, Rate: 50},
+	}}}, ThroughputOptions{})
+	if got := paused.total(0); got != 5 {
+		t.Errorf("total(0)=%g, want 5", got)
+	}
+	if got := paused.total(12 * time.Second); got != 0 {
+		t.Errorf("total(12s)=%g, want 0", got)
+	}
+	if got := paused.pause(12 * time.Second); got != 3*time.Second {
+		t.Errorf("pause(12s)=%s, want 3s", got)
+	}
+	if got := paused.pause(16 * time.Second); got != 0 {
+		t.Errorf("pause(16s)=%s, want 0", got)
+	}
+	if got := (RateProfile{{Duration: time.Second, Rate: 0}}).resume(0); got != -1 {
+		t.Errorf("resume(0)=%s, want -1", got)
+	}
+
+	// During 10 seconds A contributes 100 and B 200 requests.
+	p := sched.percentages(0, 10*time.Second)
+	if math.Abs(p[0]-33.333) > 0.1 || math.Abs(p[1]-66.667) > 0.1 {
+		t.Errorf("percentages = %v", p)
+	}
+
+	// After a warm-up of 5 seconds both contribute 50 requests.
+	p = sched.percentages(5*time.Second, 10*time.Second)
+	if math.Abs(p[0]-50) > 0.1 || math.Abs(p[1]-50) > 0.1 {
+		t.Errorf("percentages after warm-up = %v", p)
+	}
+
+	// Choosing at 7s must pick both equally often.
+	cnt := make([]int, 2)
+	for i := 0; i < 10000; i++ {
+		cnt[sched.choose(7*time.Second)]++
+	}
+	if cnt[0] < 4500 || cnt[0] > 5500 {
+		t.Errorf("Bad distribution %v", cnt)
+	}
+}
+
+func TestRawScenarioProfile(t *testing.T) {
+	txt := `
+# profile.load
+{
+    Scenarios: [
+        {
+            File: "s.suite"
+            Profile: [
+                { Duration: "1m", Rate: 20, Ramp: true }
+                { Duration: "10s", Rate: 200 }
+            ]
+        }
+    ]
+}
+
+# s.suite
+{
+    Main: [ {File: "t.ht"} ]
+}
+
+# t.ht
+{
+    Request: { URL: "http://localhost" }
+}`
+
+	raw, err := parseRawLoadtest("profile.load", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	scenarios := raw.ToScenario(nil)
+	if got := scenarios[0].Profile.String(); got != "0→20/1m0s 200/10s" {
+		t.Errorf("Got profile %s", got)
+	}
+}
+
+func TestThinkTime(t *testing.T) {
+	for i, tc := range []struct {
+		tt       ThinkTime
+		min, max time.Duration
+	}{
+		{ThinkTime{}, 0, 0},
+		{ThinkTime{Mean: time.Second}, time.Second, time.Second},
+		{ThinkTime{Distribution: "uniform", Min: time.Second, Max: 2 * time.Second},
+			time.Second, 2 * time.Second},
+		{ThinkTime{Distribution: "exponential", Mean: time.Second, Max: 3 * time.Second},
+			0, 3 * time.Second},
+	} {
+		if err := tc.tt.validate(); err != nil {
+			t.Errorf("%d. Unexpected error: %s", i, err)
+		}
+		for k := 0; k < 100; k++ {
+			if d := tc.tt.Duration(); d < tc.min || d > tc.max {
+				t.Errorf("%d. Got %s, want in [%s,%s]", i, d, tc.min, tc.max)
+				break
+			}
+		}
+	}
+
+	if err := (ThinkTime{Distribution: "gauss"}).validate(); err == nil {
+		t.Errorf("Missing error for unknown distribution")
+	}
+}
+
+func TestThinkTimeSeed(t *testing.T) {
+	tt := ThinkTime{Distribution: "exponential", Mean: time.Second}
+	draw := func() []time.Duration {
+		scope.Seed(7)
+		d := make([]time.Duration, 10)
+		for i := range d {
+			d[i] = tt.Duration()
+		}
+		return d
+	}
+	first, second := draw(), draw()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("Same seed, different think times:\n%v\n%v", first, second)
+		}
+	}
+}
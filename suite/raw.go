@@ -5,17 +5,22 @@
 package suite
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/vdobler/ht/cookiejar"
 	"github.com/vdobler/ht/errorlist"
 	"github.com/vdobler/ht/ht"
 	"github.com/vdobler/ht/internal/hjson"
+	"github.com/vdobler/ht/logging"
 	"github.com/vdobler/ht/mock"
 	"github.com/vdobler/ht/populate"
 	"github.com/vdobler/ht/scope"
@@ -35,14 +40,22 @@ type File struct {
 	Name string
 }
 
-// LoadFile reads the given file and returns it as a File.
+// LoadFile reads the given file and returns it as a File. Filename may
+// name a remote file.
 func LoadFile(filename string) (*File, error) {
-	filename = filepath.ToSlash(filename)
-	filename = path.Clean(filename)
-	data, err := ioutil.ReadFile(filename)
+	var data []byte
+	var err error
+	if isRemote(filename) {
+		data, err = loadRemote(filename)
+		filename, _ = splitPin(filename)
+	} else {
+		filename = filepath.ToSlash(filename)
+		data, err = ioutil.ReadFile(path.Clean(filename))
+	}
 	if err != nil {
 		return nil, err
 	}
+	filename = cleanName(filename)
 
 	// Make sure this is decodable HJSON.
 	var soup interface{}
@@ -53,14 +66,14 @@ func LoadFile(filename string) (*File, error) {
 
 	return &File{
 		Data: string(data),
-		Name: filepath.ToSlash(filename),
+		Name: filename,
 	}, nil
 
 }
 
 // Dirname of f.
 func (f *File) Dirname() string {
-	return path.Dir(f.Name)
+	return dirName(f.Name)
 }
 
 // Basename of f.
@@ -82,6 +95,31 @@ func (f *File) decode() (map[string]interface{}, error) {
 	return m, nil
 }
 
+// locate prefixes the populate.Errors in err with the file name and their
+// line and column in f.
+func (f *File) locate(err error) error {
+	doc, perr := hjson.ParseDocument([]byte(f.Data))
+	if perr != nil {
+		return fmt.Errorf("error decoding file %s: %s", f.Name, err)
+	}
+	var loc func(err error) error
+	loc = func(err error) error {
+		switch e := err.(type) {
+		case errorlist.List:
+			list := make(errorlist.List, len(e))
+			for i, err := range e {
+				list[i] = loc(err)
+			}
+			return list
+		case populate.Error:
+			line, col := doc.Position(e.Path...)
+			return fmt.Errorf("%s:%d:%d: %s", f.Name, line, col, e.Err)
+		}
+		return fmt.Errorf("%s: %s", f.Name, err)
+	}
+	return loc(err)
+}
+
 // populate x with the decoded f, ignoring excess properties.
 func (f *File) decodeLaxTo(x interface{}) error {
 	var soup interface{}
@@ -95,7 +133,7 @@ func (f *File) decodeLaxTo(x interface{}) error {
 	}
 	err = populate.Lax(x, m)
 	if err != nil {
-		return fmt.Errorf("error decoding file %s: %s", f.Name, err)
+		return f.locate(err)
 	}
 
 	return nil
@@ -118,7 +156,7 @@ func (f *File) decodeStrictTo(x interface{}, drop []string) error {
 	}
 	err = populate.Strict(x, m)
 	if err != nil {
-		return fmt.Errorf("error decoding file %s: %s", f.Name, err)
+		return f.locate(err)
 	}
 
 	return nil
@@ -130,6 +168,55 @@ func (f *File) decodeStrictTo(x interface{}, drop []string) error {
 // Mixin of a test.
 type Mixin struct {
 	*File
+
+	// Namespace of the mixin, defaults to the filename without extension.
+	// The Variables of the mixin are available as {{Namespace.NAME}}
+	// in the mixin and in the including test.
+	Namespace string
+
+	// Variables are the parameters passed to the mixin. They override
+	// the variables of the including test inside this mixin only.
+	Variables map[string]string
+}
+
+// MixinRef references a mixin in a test. It can be written as just the
+// filename or as an object like
+//     { File: "login.mix", Namespace: "admin", Variables: { USER: "admin" } }
+// which allows to include the same mixin several times with different
+// parameters.
+type MixinRef struct {
+	File      string
+	Namespace string
+	Variables map[string]string
+}
+
+// Populate implements populate.Populator.
+func (ref *MixinRef) Populate(src interface{}) error {
+	if file, ok := src.(string); ok {
+		*ref = MixinRef{File: file}
+		return nil
+	}
+	type plain MixinRef
+	var p plain
+	if err := populate.Strict(&p, src); err != nil {
+		return err
+	}
+	if p.File == "" {
+		return fmt.Errorf("missing File of mixin")
+	}
+	*ref = MixinRef(p)
+	return nil
+}
+
+// PopulateSchema implements populate.Schemer.
+func (ref *MixinRef) PopulateSchema(g *populate.SchemaGenerator) map[string]interface{} {
+	type plain MixinRef
+	return map[string]interface{}{
+		"anyOf": []interface{}{
+			map[string]interface{}{"type": "string"},
+			g.StructSchema(plain{}),
+		},
+	}
 }
 
 // LoadMixin with the given filename.
@@ -139,7 +226,31 @@ func loadMixin(filename string, fs FileSystem) (*Mixin, error) {
 		return nil, err
 	}
 
-	return &Mixin{File: file}, nil
+	return &Mixin{File: file, Namespace: defaultNamespace(file.Name)}, nil
+}
+
+// defaultNamespace of the mixin filename: Its basename without extension.
+func defaultNamespace(filename string) string {
+	base := path.Base(filename)
+	return strings.TrimSuffix(base, path.Ext(base))
+}
+
+// scope returns the variables used to substitute m when included in a test
+// with the given variables: The parameters of m override the test's
+// variables.
+func (m *Mixin) scope(variables scope.Variables) scope.Variables {
+	if len(m.Variables) == 0 {
+		return variables
+	}
+	replacer := variables.Replacer()
+	mixScope := make(scope.Variables, len(variables)+len(m.Variables))
+	for n, v := range variables {
+		mixScope[n] = v
+	}
+	for n, v := range m.Variables {
+		mixScope[n] = replacer.Replace(v)
+	}
+	return mixScope
 }
 
 // ----------------------------------------------------------------------------
@@ -152,6 +263,7 @@ type RawTest struct {
 	Mixins      []*Mixin          // Mixins of this test.
 	Variables   map[string]string // Variables are the defaults of the variables.
 	contextVars map[string]string
+	exports     []string
 	mocks       []*RawMock
 	disabled    bool
 }
@@ -178,13 +290,16 @@ func LoadRawTest(filename string, fs FileSystem) (*RawTest, error) {
 
 	// Unmarshal to find the Mixins and Variables
 	x := &struct {
-		Mixin     []string
+		Mixin     []MixinRef
 		Variables map[string]string
 	}{}
 	err = raw.decodeLaxTo(x)
 	if err != nil {
 		return nil, err
 	}
+	if err := resolveSecrets(raw, x.Variables); err != nil {
+		return nil, err
+	}
 
 	// Load all mixins from disk.
 	testdir := raw.Dirname()
@@ -200,15 +315,19 @@ func LoadRawTest(filename string, fs FileSystem) (*RawTest, error) {
 	}, nil
 }
 
-func loadMixins(mixs []string, dir string, fs FileSystem) ([]*Mixin, error) {
+func loadMixins(refs []MixinRef, dir string, fs FileSystem) ([]*Mixin, error) {
 	mixins := []*Mixin{}
-	for _, file := range mixs {
-		mixpath := path.Join(dir, file)
+	for _, ref := range refs {
+		mixpath := joinName(dir, ref.File)
 		mixin, err := loadMixin(mixpath, fs)
 		if err != nil {
 			return nil, fmt.Errorf("cannot load mixin %q: %s",
-				file, err)
+				ref.File, err)
+		}
+		if ref.Namespace != "" {
+			mixin.Namespace = ref.Namespace
 		}
+		mixin.Variables = ref.Variables
 		mixins = append(mixins, mixin)
 	}
 	return mixins, nil
@@ -217,22 +336,22 @@ func loadMixins(mixs []string, dir string, fs FileSystem) ([]*Mixin, error) {
 // ToTest produces a ht.Test from a raw test rt.
 func (rt *RawTest) ToTest(variables scope.Variables) (*ht.Test, error) {
 	bogus := &ht.Test{Result: ht.Result{Status: ht.Bogus}}
-	replacer := variables.Replacer()
 
 	// Make substituted a copy of rt with variables substituted.
 	// Dropping the Variabels field as this is no longer useful.
+	namespaced := rt.namespaced(variables)
 	substituted := &RawTest{
 		File: &File{
-			Data: replacer.Replace(rt.File.Data),
+			Data: namespaced.Replacer().Replace(rt.File.Data),
 			Name: rt.File.Name,
 		},
 		Mixins: make([]*Mixin, len(rt.Mixins)),
 	}
-	for i := range rt.Mixins {
+	for i, mixin := range rt.Mixins {
 		substituted.Mixins[i] = &Mixin{
 			File: &File{
-				Data: replacer.Replace(rt.Mixins[i].File.Data),
-				Name: rt.Mixins[i].File.Name,
+				Data: mixin.scope(namespaced).Replacer().Replace(mixin.File.Data),
+				Name: mixin.File.Name,
 			},
 		}
 	}
@@ -266,6 +385,28 @@ func (rt *RawTest) ToTest(variables scope.Variables) (*ht.Test, error) {
 	return merged, nil
 }
 
+// namespaced returns variables extended by the scope of each mixin of rt
+// under its namespaced name, i.e. {{Namespace.NAME}} is the value of
+// {{NAME}} inside the mixin. Explicitly set variables are not overwritten.
+func (rt *RawTest) namespaced(variables scope.Variables) scope.Variables {
+	if len(rt.Mixins) == 0 {
+		return variables
+	}
+	extended := make(scope.Variables, len(variables)*(1+len(rt.Mixins)))
+	for n, v := range variables {
+		extended[n] = v
+	}
+	for _, mixin := range rt.Mixins {
+		for n, v := range mixin.scope(variables) {
+			name := mixin.Namespace + "." + n
+			if _, explicit := variables[name]; !explicit {
+				extended[name] = v
+			}
+		}
+	}
+	return extended
+}
+
 func (m *Mixin) toTest() (*ht.Test, error) {
 	rt := &RawTest{
 		File: &File{
@@ -277,6 +418,9 @@ func (m *Mixin) toTest() (*ht.Test, error) {
 }
 
 func (rt *RawTest) toTest(variables map[string]string) (*ht.Test, error) {
+	if err := rt.File.checkSchema("test"); err != nil {
+		return nil, err
+	}
 	m, err := rt.File.decode()
 	if err != nil {
 		return nil, err
@@ -288,7 +432,7 @@ func (rt *RawTest) toTest(variables map[string]string) (*ht.Test, error) {
 
 	err = populate.Strict(test, m)
 	if err != nil {
-		return nil, err // better error message here
+		return nil, rt.File.locate(err)
 	}
 	test.Variables = make(map[string]string, len(variables))
 	for n, v := range variables {
@@ -307,6 +451,10 @@ type RawElement struct {
 	Variables map[string]string
 	Mocks     []string
 
+	// Exports is the list of variables the test may extract into the
+	// suite scope. A nil Exports allows to extract any variable.
+	Exports []string
+
 	Test map[string]interface{}
 }
 
@@ -320,7 +468,201 @@ type RawSuite struct {
 	Variables             map[string]string
 	Verbosity             int
 
-	tests []*RawTest
+	// AllowedHosts switches on hermetic mode: HTTP requests to hosts
+	// not in this list are not sent but result in an Error.
+	AllowedHosts []string
+
+	// ReadOnly lists variables which must not be changed by a
+	// DataExtraction of any test in the suite.
+	ReadOnly []string
+
+	// VariablesFrom lists files (relative to the suite) with additional
+	// variable defaults. Variables take precedence over these files.
+	VariablesFrom []string
+
+	// Profiles are named sets of variables like "dev" or "staging"
+	// one of which can be selected during execution via SelectProfile.
+	Profiles map[string]Profile
+
+	// Declarations declare the type and constraints of variables the
+	// suite expects, e.g. that HOST is required. Executions which do not
+	// satisfy the declarations fail before any test is run.
+	Declarations map[string]VarDecl
+
+	// LoadCookies and SaveCookies are files (relative to the suite) the
+	// cookie jar is seeded from before the first test and saved to after
+	// execution of the suite. Both require KeepCookies.
+	LoadCookies, SaveCookies string
+
+	// CookiePolicy restricts the cookies stored in the cookie jar, e.g.
+	// to block third-party cookies. It requires KeepCookies.
+	CookiePolicy cookiejar.Policy
+
+	// ClientPool configures the HTTP connections shared by all tests
+	// of the suite. Nil uses the global ht.Transport.
+	ClientPool *ht.ClientPool
+
+	// Timeout limits the execution of the suite: Once it expires the
+	// running test is canceled and the remaining Setup and Main tests
+	// are skipped. Teardown tests are still run during the
+	// TeardownGracePeriod. Zero means no limit.
+	Timeout time.Duration
+
+	// FreezeNow freezes the time used by the NOW function and by the
+	// SetTimestamp extractors during the execution of the suite: "start"
+	// freezes it to the start of the execution, other values are a fixed
+	// time like "2017-03-24T15:30:00+01:00". A FROZEN_NOW variable set
+	// from outside takes precedence.
+	FreezeNow string
+
+	// WireLog dumps the requests and responses of all tests which do
+	// not have their own WireLog. A relative Dir is relative to the suite.
+	WireLog *ht.WireLog
+
+	tests   []*RawTest
+	profile scope.Variables // variables of the selected profile
+	logger  logging.Logger  // structured logger, see SetLogger
+}
+
+// Profile is a named set of variables of a RawSuite. The variables of the
+// selected profile are merged into the global variables: They override
+// the suite's defaults but not the global variables.
+type Profile struct {
+	Variables     map[string]string
+	VariablesFrom []string // read like RawSuite.VariablesFrom
+}
+
+// SetLogger sets the structured logger used by executions of rs, see
+// Suite.Logger. A nil logger restores logging to the plain Log.
+func (rs *RawSuite) SetLogger(logger logging.Logger) {
+	rs.logger = logger
+}
+
+// SelectProfile selects the profile name for executions of rs. The empty
+// name deselects any profile.
+func (rs *RawSuite) SelectProfile(name string) error {
+	if name == "" {
+		rs.profile = nil
+		return nil
+	}
+	p, ok := rs.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(rs.Profiles))
+		for n := range rs.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("suite %s has no profile %q (available: %s)",
+			rs.File.Name, name, strings.Join(names, ", "))
+	}
+	rs.profile = p.Variables
+	return nil
+}
+
+// globals merges the variables of the selected profile into global.
+func (rs *RawSuite) globals(global map[string]string) scope.Variables {
+	if len(rs.profile) == 0 {
+		return global
+	}
+	return scope.New(global, rs.profile, false)
+}
+
+// freezeNow sets the variable FROZEN_NOW in vars according to FreezeNow
+// unless it is already set.
+func (rs *RawSuite) freezeNow(vars scope.Variables) error {
+	if rs.FreezeNow == "" {
+		return nil
+	}
+	if _, ok := vars[scope.FrozenNow]; ok {
+		return nil
+	}
+	now := scope.Now()
+	if freeze := vars.Replacer().Replace(rs.FreezeNow); freeze != "start" {
+		var err error
+		now, err = scope.ParseTime(freeze)
+		if err != nil {
+			return fmt.Errorf("bad FreezeNow: %s", err)
+		}
+	}
+	vars[scope.FrozenNow] = now.Format(time.RFC3339Nano)
+	return nil
+}
+
+// LoadVariables reads a variable file, i.e. a HJSON object with string
+// values, with the given filename from fs. Files encrypted with
+// "ht vault encrypt" are decrypted with the configured vault passphrase
+// and all their values are marked as secret.
+func LoadVariables(filename string, fs FileSystem) (map[string]string, error) {
+	f, err := fs.Load(filename)
+	if err != nil {
+		return nil, err
+	}
+	encrypted := scope.IsEncrypted([]byte(f.Data))
+	if encrypted {
+		plaintext, err := scope.DecryptVault([]byte(f.Data))
+		if err != nil {
+			return nil, fmt.Errorf("file %s: %s", f.Name, err)
+		}
+		f = &File{Data: string(plaintext), Name: f.Name}
+	}
+	m, err := f.decode()
+	if err != nil {
+		return nil, err
+	}
+	vars := map[string]string{}
+	if err := populate.Strict(&vars, m); err != nil {
+		return nil, f.locate(err)
+	}
+	if encrypted {
+		for _, v := range vars {
+			scope.MarkSecret(v)
+		}
+	}
+	if err := resolveSecrets(f, vars); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// resolveSecrets resolves the secret references (see scope.SecretPrefix)
+// in the variables vars read from f. Files loaded from a URL or git must
+// not reference secrets as they could send them anywhere.
+func resolveSecrets(f *File, vars map[string]string) error {
+	if isUntrusted(f.Name) {
+		for name, v := range vars {
+			if strings.HasPrefix(v, scope.SecretPrefix) {
+				return fmt.Errorf("file %s: variable %s: downloaded files cannot reference secrets",
+					f.Name, name)
+			}
+		}
+		return nil
+	}
+	if err := scope.ResolveSecrets(vars); err != nil {
+		return fmt.Errorf("file %s: %s", f.Name, err)
+	}
+	return nil
+}
+
+// mergeVariablesFrom returns vars merged with the variables read from
+// files. Later files override earlier ones and vars override all files.
+func mergeVariablesFrom(vars map[string]string, files []string, dir string, fs FileSystem) (map[string]string, error) {
+	if len(files) == 0 {
+		return vars, nil
+	}
+	merged := make(map[string]string)
+	for _, file := range files {
+		fromFile, err := LoadVariables(joinName(dir, file), fs)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read variables from %q: %s", file, err)
+		}
+		for n, v := range fromFile {
+			merged[n] = v
+		}
+	}
+	for n, v := range vars {
+		merged[n] = v
+	}
+	return merged, nil
 }
 
 // RawTests return all tests in rs.
@@ -349,6 +691,9 @@ func LoadRawSuite(filename string, fs FileSystem) (*RawSuite, error) {
 		return nil, err
 	}
 
+	if err := raw.checkSchema("suite"); err != nil {
+		return nil, err
+	}
 	rs := &RawSuite{}
 	err = raw.decodeStrictTo(rs, nil)
 	if err != nil {
@@ -356,13 +701,30 @@ func LoadRawSuite(filename string, fs FileSystem) (*RawSuite, error) {
 	}
 	rs.File = raw // re-set as decodeStritTo clears rs
 	dir := rs.File.Dirname()
+	if err := resolveSecrets(raw, rs.Variables); err != nil {
+		return nil, err
+	}
+	rs.Variables, err = mergeVariablesFrom(rs.Variables, rs.VariablesFrom, dir, fs)
+	if err != nil {
+		return nil, err
+	}
+	for name, p := range rs.Profiles {
+		if err := resolveSecrets(raw, p.Variables); err != nil {
+			return nil, fmt.Errorf("profile %s: %s", name, err)
+		}
+		p.Variables, err = mergeVariablesFrom(p.Variables, p.VariablesFrom, dir, fs)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %s", name, err)
+		}
+		rs.Profiles[name] = p
+	}
 	load := func(elems []RawElement, which string) error {
 		for i, elem := range elems {
 			var err error
 			var rt *RawTest
 			var filename string
 			if elem.File != "" {
-				filename = path.Join(dir, elem.File)
+				filename = joinName(dir, elem.File)
 				rt, err = LoadRawTest(filename, fs)
 				if err != nil {
 					return fmt.Errorf("cannot load test %q (%d. %s): %s",
@@ -381,8 +743,9 @@ func LoadRawSuite(filename string, fs FileSystem) (*RawSuite, error) {
 				return fmt.Errorf("File and Test must not both be empty in %d. %s", i+1, which)
 			}
 			rt.contextVars = elem.Variables
+			rt.exports = elem.Exports
 			for _, mockname := range elem.Mocks {
-				mf, err := LoadRawMock(path.Join(dir, mockname), fs)
+				mf, err := LoadRawMock(joinName(dir, mockname), fs)
 				if err != nil {
 					return fmt.Errorf("cannot instantiate test (%d. %s): cannot load mock %q: %s",
 						i+1, which, mockname, err)
@@ -412,7 +775,7 @@ func LoadRawSuite(filename string, fs FileSystem) (*RawSuite, error) {
 func rawTestFromInline(name, dir string, fs FileSystem, inline map[string]interface{}) (*RawTest, error) {
 	mixins := []*Mixin{}
 	if m, ok := inline["Mixins"]; ok {
-		mixs := []string{}
+		mixs := []MixinRef{}
 		err := populate.Strict(&mixs, m)
 		if err != nil {
 			fmt.Println("Mixins issue", err)
@@ -443,9 +806,17 @@ func rawTestFromInline(name, dir string, fs FileSystem, inline map[string]interf
 
 // Validate rs to make sure it can be decoded into welformed ht.Tests.
 func (rs *RawSuite) Validate(global map[string]string) error {
-	suiteScope := scope.New(global, rs.Variables, true)
+	suiteScope := scope.New(rs.globals(global), rs.Variables, true)
 	suiteScope["SUITE_DIR"] = rs.File.Dirname()
 	suiteScope["SUITE_NAME"] = rs.File.Basename()
+	if err := rs.checkDeclarations(suiteScope); err != nil {
+		return err
+	}
+
+	readOnly := make(map[string]bool, len(rs.ReadOnly))
+	for _, name := range rs.ReadOnly {
+		readOnly[name] = true
+	}
 
 	el := errorlist.List{}
 	for _, rt := range rs.tests {
@@ -453,7 +824,10 @@ func (rs *RawSuite) Validate(global map[string]string) error {
 		testScope := scope.New(callScope, rt.Variables, false)
 		testScope["TEST_DIR"] = rt.File.Dirname()
 		testScope["TEST_NAME"] = rt.File.Basename()
-		_, err := rt.ToTest(testScope)
+		test, err := rt.ToTest(testScope)
+		if err == nil {
+			err = checkExtractions(test, rt.exports, readOnly)
+		}
 		if err != nil {
 			err := fmt.Errorf("invalid test %s (included by %s): %s",
 				rt.File.Name, rs.File.Name, err)
@@ -467,6 +841,90 @@ func (rs *RawSuite) Validate(global map[string]string) error {
 	return nil
 }
 
+// ScopeLayer is one layer of the variable scope of a test in a suite.
+type ScopeLayer struct {
+	Name      string            // Name of the layer: global, profile, suite, call or test
+	Defined   map[string]string // Defined are the variables set in this layer.
+	Effective scope.Variables   // Effective is the resulting scope.
+}
+
+// Inspection explains how the variables of a test in a suite are set up.
+type Inspection struct {
+	Layers      []ScopeLayer // Layers of the scope from outermost to innermost.
+	Substituted string       // Substituted test file.
+	Unresolved  []string     // Unresolved variables in Substituted.
+	Test        *ht.Test     // Test after substitution, nil if invalid.
+	Error       error        // Error producing Test.
+}
+
+var unresolvedVarRe = regexp.MustCompile(`\{\{[^{}]+\}\}`)
+
+// Inspect the variable scope of the n'th (0-based, counting setup, main and
+// teardown tests) test of rs when executed with the given global variables.
+// Variables extracted by previous tests of the suite are not included.
+func (rs *RawSuite) Inspect(global map[string]string, n int) (*Inspection, error) {
+	if n < 0 || n >= len(rs.tests) {
+		return nil, fmt.Errorf("no test %d in suite %s", n, rs.File.Name)
+	}
+	rt := rs.tests[n]
+
+	profileScope := rs.globals(global)
+	suiteScope := scope.New(profileScope, rs.Variables, true)
+	suiteScope["SUITE_DIR"] = rs.File.Dirname()
+	suiteScope["SUITE_NAME"] = rs.File.Basename()
+	callScope := scope.New(suiteScope, rt.contextVars, true)
+	testScope := scope.New(callScope, rt.Variables, false)
+	testScope["TEST_DIR"] = rt.File.Dirname()
+	testScope["TEST_NAME"] = rt.File.Basename()
+
+	// Automatic variables are reported as defined in the layer setting them.
+	defined := func(vars, scope map[string]string, auto ...string) map[string]string {
+		d := make(map[string]string, len(vars)+len(auto))
+		for n, v := range vars {
+			d[n] = v
+		}
+		for _, n := range auto {
+			d[n] = scope[n]
+		}
+		return d
+	}
+	insp := &Inspection{
+		Layers: []ScopeLayer{
+			{Name: "global", Defined: defined(global, nil),
+				Effective: scope.New(global, nil, false)},
+			{Name: "suite", Defined: defined(rs.Variables, suiteScope,
+				"COUNTER", "RANDOM", "SUITE_DIR", "SUITE_NAME"),
+				Effective: suiteScope},
+			{Name: "call", Defined: defined(rt.contextVars, callScope,
+				"COUNTER", "RANDOM"),
+				Effective: callScope},
+			{Name: "test", Defined: defined(rt.Variables, testScope,
+				"TEST_DIR", "TEST_NAME"),
+				Effective: testScope},
+		},
+		Substituted: rt.namespaced(testScope).Replacer().Replace(rt.File.Data),
+	}
+	if len(rs.profile) > 0 {
+		profileLayer := ScopeLayer{Name: "profile",
+			Defined: defined(rs.profile, nil), Effective: profileScope}
+		insp.Layers = append(insp.Layers[:1],
+			append([]ScopeLayer{profileLayer}, insp.Layers[1:]...)...)
+	}
+	seen := make(map[string]bool)
+	for _, v := range unresolvedVarRe.FindAllString(insp.Substituted, -1) {
+		if !seen[v] {
+			insp.Unresolved = append(insp.Unresolved, v)
+			seen[v] = true
+		}
+	}
+	insp.Test, insp.Error = rt.ToTest(testScope)
+	if insp.Error != nil {
+		insp.Test = nil
+	}
+
+	return insp, nil
+}
+
 // Execute the raw suite rs and capture the outcome in a Suite.
 //
 // Tests are executed linearely, first the Setup, then the Main and finally
@@ -485,7 +943,59 @@ func (rs *RawSuite) Validate(global map[string]string) error {
 //      Teardown-2    Fail     Error
 //      Teardown-3    Pass     Pass
 func (rs *RawSuite) Execute(global map[string]string, jar *cookiejar.Jar, logger *log.Logger) *Suite {
-	suite := NewFromRaw(rs, global, jar, logger)
+	return rs.ExecuteWith(global, ExecOptions{Jar: jar, Logger: logger})
+}
+
+// ExecOptions control the execution of a RawSuite by ExecuteWith.
+// The zero value executes the suite like Execute with a nil jar and logger.
+type ExecOptions struct {
+	// Context cancels the execution once it is done: The running test is
+	// aborted and the remaining Setup and Main tests are skipped. If any
+	// Setup or Main test was run the Teardown tests are executed
+	// nevertheless but are canceled after TeardownGracePeriod. A canceled
+	// suite has status Error. A nil Context is never done.
+	Context context.Context
+
+	// Jar and Logger are used like in Execute.
+	Jar    *cookiejar.Jar
+	Logger *log.Logger
+
+	// Checkpoint records the outcome of each test. Main tests recorded as
+	// passed in the Checkpoint (from a previous, interrupted execution)
+	// are skipped and their extracted variables are reused.
+	Checkpoint *Checkpoint
+
+	// Observers are notified about the progress of the execution.
+	Observers []Observer
+}
+
+// TeardownGracePeriod is the time Teardown tests are given to run once the
+// execution of a suite got canceled.
+var TeardownGracePeriod = 10 * time.Second
+
+// ExecuteWith works like Execute but the execution is controlled by opts.
+func (rs *RawSuite) ExecuteWith(global map[string]string, opts ExecOptions) *Suite {
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	suite := NewFromRaw(rs, global, opts.Jar, opts.Logger)
+	suite.checkpoint = opts.Checkpoint
+	suite.Observers = opts.Observers
+	return rs.execute(ctx, suite)
+}
+
+// execute suite (which must have been set up from rs) as described in
+// Execute and ExecOptions.
+func (rs *RawSuite) execute(ctx context.Context, suite *Suite) *Suite {
+	if rs.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rs.Timeout)
+		defer cancel()
+	}
+	teardownCtx, stopTeardown := graceContext(ctx, TeardownGracePeriod)
+	defer stopTeardown()
+
 	N := len(rs.tests)
 	setup, main, teardown := len(rs.Setup), len(rs.Main), len(rs.Teardown)
 	i := 0
@@ -493,6 +1003,7 @@ func (rs *RawSuite) Execute(global map[string]string, jar *cookiejar.Jar, logger
 	isMain := func() bool { return i > setup && i <= setup+main }
 	isSetupOrMain := func() bool { return i <= setup+main }
 	setupfailures := false
+	started, canceled := false, false
 
 	executor := func(test *ht.Test) error {
 		i++
@@ -504,11 +1015,16 @@ func (rs *RawSuite) Execute(global map[string]string, jar *cookiejar.Jar, logger
 			test.SetMetadata("SeqNo", fmt.Sprintf("Teardown-%02d", i-setup-main))
 		}
 
+		if isSetupOrMain() && ctx.Err() != nil {
+			canceled = true
+		}
 		switch {
 		case test.Result.Status == ht.Skipped:
 			fallthrough
 		case !rs.tests[i-1].IsEnabled():
 			fallthrough
+		case canceled && (isSetupOrMain() || !started):
+			fallthrough
 		case setupfailures && isSetupOrMain():
 			test.Result.Status = ht.Skipped
 			return nil
@@ -517,7 +1033,13 @@ func (rs *RawSuite) Execute(global map[string]string, jar *cookiejar.Jar, logger
 		if test.Result.Status != ht.Bogus {
 			// Run only non-bogus tests.
 			test.Execution.Verbosity = rs.Verbosity
-			test.Run()
+			if isSetupOrMain() {
+				started = true
+				test.RunContext(ctx)
+				canceled = ctx.Err() != nil
+			} else {
+				test.RunContext(teardownCtx)
+			}
 		}
 		if test.Result.Status > ht.Pass && isSetup() {
 			setupfailures = true
@@ -527,7 +1049,13 @@ func (rs *RawSuite) Execute(global map[string]string, jar *cookiejar.Jar, logger
 	}
 
 	// Overall Suite status is computetd from Setup and Main tests only.
-	suite.Iterate(executor)
+	suite.IterateContext(ctx, executor)
+	if suite.setupErr != nil {
+		for _, o := range suite.Observers {
+			o.OnSuiteDone(suite)
+		}
+		return suite
+	}
 	status := ht.NotRun
 	errors := errorlist.List{}
 	for i := 0; i < N-teardown && i < len(suite.Tests); i++ {
@@ -539,6 +1067,24 @@ func (rs *RawSuite) Execute(global map[string]string, jar *cookiejar.Jar, logger
 		}
 	}
 
+	if canceled {
+		errors = append(errors, errorlist.New(errorlist.Network, "suite.canceled", "",
+			fmt.Errorf("suite canceled: %s", ctx.Err())))
+		if status < ht.Error {
+			status = ht.Error
+		}
+	}
+
+	if suite.ClientPool != nil {
+		suite.ClientPool.Close()
+	}
+	if err := suite.saveCookies(); err != nil {
+		errors = append(errors, err)
+		if status < ht.Error {
+			status = ht.Error
+		}
+	}
+
 	suite.Status = status
 	if len(errors) == 0 {
 		suite.Error = nil
@@ -546,6 +1092,10 @@ func (rs *RawSuite) Execute(global map[string]string, jar *cookiejar.Jar, logger
 		suite.Error = errors
 	}
 
+	for _, o := range suite.Observers {
+		o.OnSuiteDone(suite)
+	}
+
 	return suite
 }
 
@@ -556,15 +1106,33 @@ func (rs *RawSuite) Execute(global map[string]string, jar *cookiejar.Jar, logger
 // A empty FileSystem accesses the real OS file system.
 type FileSystem map[string]*File
 
-// Load the file with the given name from fs.
+// Load the file with the given name from fs. Include directives in the
+// file are resolved.
 func (fs FileSystem) Load(name string) (*File, error) {
+	f, err := fs.loadPlain(name)
+	if err != nil || !f.hasIncludes() {
+		return f, err
+	}
+	return f.resolveIncludes(fs, nil)
+}
+
+// loadPlain loads the file with the given name from fs as is. Files loaded
+// from a URL or git must not read local files or the environment.
+func (fs FileSystem) loadPlain(name string) (*File, error) {
+	var f *File
 	if len(fs) == 0 {
-		return LoadFile(name)
+		var err error
+		if f, err = LoadFile(name); err != nil {
+			return nil, err
+		}
+	} else if f = fs[name]; f == nil {
+		return nil, fmt.Errorf("file %s not found", name)
 	}
-	if f, ok := fs[name]; ok {
-		return f, nil
+	if isUntrusted(f.Name) && scope.UsesLocalFuncs(f.Data) {
+		return nil, fmt.Errorf("file %s: downloaded files cannot call %s",
+			f.Name, strings.Join(scope.LocalFuncs, " or "))
 	}
-	return nil, fmt.Errorf("file %s not found", name)
+	return f, nil
 }
 
 // NewFileSystem parses txt which must be of the form
@@ -617,8 +1185,13 @@ type RawScenario struct {
 	MaxThreads int               // MaxThreads to use for this scenario. 0 means unlimited.
 	Variables  map[string]string // Variables used.
 	OmitChecks bool              // OmitChecks in the tests.
+	Profile    RateProfile       // Profile of the request rate; overrides Percentage.
+	ThinkTime  ThinkTime         // ThinkTime between two tests.
+	Pacing     time.Duration     // Pacing is the minimal duration of one iteration.
+	DataPool   string            // DataPool is a CSV or JSON file with records.
 
 	rawSuite *RawSuite
+	dataPool *DataPool
 }
 
 // RawLoadTest as read from disk.
@@ -628,6 +1201,14 @@ type RawLoadTest struct {
 	Description string
 	Scenarios   []RawScenario
 	Variables   map[string]string
+
+	// SLA thresholds like "p99 < 800ms" or "error rate < 1%" which
+	// the load test must fulfill.
+	SLA []string
+
+	// Abort the load test early if the error rate or the request
+	// duration exceed the given limits for a sustained period.
+	Abort AbortCondition
 }
 
 func parseRawLoadtest(name string, txt string) (*RawLoadTest, error) {
@@ -646,6 +1227,9 @@ func LoadRawLoadtest(filename string, fs FileSystem) (*RawLoadTest, error) {
 		return nil, err
 	}
 
+	if err := raw.checkSchema("load"); err != nil {
+		return nil, err
+	}
 	rlt := &RawLoadTest{}
 	err = raw.decodeStrictTo(rlt, nil)
 	if err != nil {
@@ -653,10 +1237,13 @@ func LoadRawLoadtest(filename string, fs FileSystem) (*RawLoadTest, error) {
 	}
 	rlt.File = raw // re-set as decodeStritTo clears rs
 	dir := rlt.File.Dirname()
+	if err := resolveSecrets(raw, rlt.Variables); err != nil {
+		return nil, err
+	}
 
 	for i, s := range rlt.Scenarios {
 		if s.File != "" {
-			filename := path.Join(dir, s.File)
+			filename := joinName(dir, s.File)
 			rs, err := LoadRawSuite(filename, fs)
 			if err != nil {
 				return nil, fmt.Errorf("cannot load suite %q (%d. scenario): %s",
@@ -666,6 +1253,14 @@ func LoadRawLoadtest(filename string, fs FileSystem) (*RawLoadTest, error) {
 		} else {
 			panic("File must not be empty")
 		}
+		if s.DataPool != "" {
+			filename := joinName(dir, s.DataPool)
+			dp, err := LoadDataPool(filename, fs)
+			if err != nil {
+				return nil, fmt.Errorf("%d. scenario: %s", i+1, err)
+			}
+			rlt.Scenarios[i].dataPool = dp
+		}
 	}
 
 	return rlt, nil
@@ -682,6 +1277,10 @@ func (raw *RawLoadTest) ToScenario(globals map[string]string) []Scenario {
 			RawSuite:   rs.rawSuite,
 			Percentage: rs.Percentage,
 			MaxThreads: rs.MaxThreads,
+			Profile:    rs.Profile,
+			ThinkTime:  rs.ThinkTime,
+			Pacing:     rs.Pacing,
+			DataPool:   rs.dataPool,
 			globals:    callscope,
 		}
 
@@ -735,6 +1334,9 @@ func (rm *RawMock) ToMock(variables scope.Variables, auto bool) (*mock.Mock, err
 		Name: rm.File.Name,
 	}
 
+	if err := substituted.checkSchema("mock"); err != nil {
+		return nil, err
+	}
 	var m = &mock.Mock{}
 	if err := substituted.decodeStrictTo(m, nil); err != nil {
 		return nil, err
@@ -76,13 +76,54 @@ func TestErrorReporting(t *testing.T) {
 	if err == nil {
 		t.Fatalf("no error")
 	}
-	want := "unknown field FollowAllRedirects in Test.Request"
+	want := "testdata/wrong2.ht:5:2: unknown field FollowAllRedirects in Test.Request"
 	if got := err.Error(); got != want {
 		t.Errorf("Got:  %q\n,Want: %q", got, want)
 	}
 
 }
 
+func TestErrorPositionAndSuggestion(t *testing.T) {
+	fs, err := NewFileSystem(`
+# typo.ht
+{
+    Name: "Typo"
+    Request: {
+        URL: "http://example.org"
+        FollowRedirect: true
+    }
+    Checks: [
+        {Check: "StatusCode", Expect: 200}
+        {
+            Check: "Body"
+            Contians: "foo"
+        }
+        {Check: "Bodyy"}
+    ]
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := LoadRawTest("typo.ht", fs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	_, err = raw.ToTest(nil)
+	if err == nil {
+		t.Fatalf("no error")
+	}
+	got := err.Error()
+	for _, want := range []string{
+		"typo.ht:5:9: unknown field FollowRedirect in Test.Request (did you mean FollowRedirects?)",
+		"typo.ht:11:13: unknown field Contians in Body (did you mean Contains?)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Missing %q in\n%s", want, got)
+		}
+	}
+}
+
 func TestRawTestToTest(t *testing.T) {
 	raw, err := LoadRawTest("./testdata/a.ht", nil)
 	if err != nil {
@@ -582,3 +623,390 @@ func TestDecodeErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestInspect(t *testing.T) {
+	txt := `
+# inspect.suite
+{
+    Name: "Inspect"
+    Main: [
+        {File: "test.ht", Variables: {PATH: "/call", HOST: "call.org"}}
+    ]
+    Variables: {
+        HOST: "suite.org"
+    }
+}
+
+# test.ht
+{
+    Name: "Test {{USER}}"
+    Request: { URL: "http://{{HOST}}{{PATH}}?q={{QUERY}}" }
+    Variables: {
+        PATH: "/test"
+        QUERY: "x"
+    }
+}
+`
+	rs, err := parseRawSuite("inspect.suite", txt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	insp, err := rs.Inspect(map[string]string{"USER": "joe"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(insp.Layers) != 4 {
+		t.Fatalf("Got %d layers", len(insp.Layers))
+	}
+	for i, tc := range []struct{ name, host, path string }{
+		{"global", "", ""},
+		{"suite", "suite.org", ""},
+		{"call", "suite.org", "/call"}, // suite wins over call defaults
+		{"test", "suite.org", "/call"},
+	} {
+		layer := insp.Layers[i]
+		if layer.Name != tc.name || layer.Effective["HOST"] != tc.host ||
+			layer.Effective["PATH"] != tc.path {
+			t.Errorf("Layer %d: got %s %q %q", i, layer.Name,
+				layer.Effective["HOST"], layer.Effective["PATH"])
+		}
+	}
+	if insp.Layers[3].Effective["USER"] != "joe" {
+		t.Errorf("Missing global variable")
+	}
+	if insp.Error != nil || insp.Test.Request.URL != "http://suite.org/call?q=x" {
+		t.Errorf("Got %v %v", insp.Error, insp.Test)
+	}
+	if len(insp.Unresolved) != 0 {
+		t.Errorf("Unresolved %v", insp.Unresolved)
+	}
+
+	insp, _ = rs.Inspect(nil, 0)
+	if len(insp.Unresolved) != 1 || insp.Unresolved[0] != "{{USER}}" {
+		t.Errorf("Got unresolved %v", insp.Unresolved)
+	}
+	if _, err := rs.Inspect(nil, 1); err == nil {
+		t.Errorf("Missing error for nonexisting test")
+	}
+}
+
+func TestVariablesFromAndProfiles(t *testing.T) {
+	fs, err := NewFileSystem(`
+# suite.suite
+{
+    Name: "Profiles"
+    Main: [ {File: "test.ht"} ]
+    Variables: { A: "suite-a", B: "suite-b" }
+    VariablesFrom: [ "common.vars", "more.vars" ]
+    Profiles: {
+        staging: {
+            VariablesFrom: [ "staging.vars" ]
+            Variables: { B: "staging-b" }
+        }
+        prod: {
+            Variables: { HOST: "prod.example.org" }
+        }
+    }
+}
+
+# common.vars
+{ A: "common-a", C: "common-c", D: "common-d", HOST: "localhost" }
+
+# more.vars
+{ D: "more-d" }
+
+# staging.vars
+{ HOST: "staging.example.org", B: "file-b" }
+
+# test.ht
+{
+    Name: "Test"
+    Request: { URL: "http://{{HOST}}/{{A}}/{{B}}/{{C}}/{{D}}" }
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := LoadRawSuite("suite.suite", fs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	url := func(global map[string]string) string {
+		insp, err := rs.Inspect(global, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if insp.Error != nil {
+			t.Fatalf("Unexpected error: %s", insp.Error)
+		}
+		return insp.Test.Request.URL
+	}
+
+	if got := url(nil); got != "http://localhost/suite-a/suite-b/common-c/more-d" {
+		t.Errorf("No profile: got %s", got)
+	}
+
+	if err := rs.SelectProfile("staging"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if got := url(nil); got != "http://staging.example.org/suite-a/staging-b/common-c/more-d" {
+		t.Errorf("Staging: got %s", got)
+	}
+	if got := url(map[string]string{"HOST": "cmdline"}); got != "http://cmdline/suite-a/staging-b/common-c/more-d" {
+		t.Errorf("Staging with global: got %s", got)
+	}
+	insp, _ := rs.Inspect(nil, 0)
+	if len(insp.Layers) != 5 || insp.Layers[1].Name != "profile" {
+		t.Errorf("Got layers %v", insp.Layers)
+	}
+
+	err = rs.SelectProfile("dev")
+	if err == nil || !strings.Contains(err.Error(), "available: prod, staging") {
+		t.Errorf("Got %v", err)
+	}
+	rs.SelectProfile("")
+	if got := url(nil); got != "http://localhost/suite-a/suite-b/common-c/more-d" {
+		t.Errorf("Deselected: got %s", got)
+	}
+}
+
+func TestLoadEncryptedVariables(t *testing.T) {
+	encrypted, err := scope.Encrypt([]byte(`{ USER: "admin", PASS: "vault-pass-42" }`),
+		[]byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := FileSystem{
+		"secret.vars": &File{Name: "secret.vars", Data: string(encrypted)},
+	}
+
+	scope.VaultPassphrase = "wrong"
+	_, err = LoadVariables("secret.vars", fs)
+	if err == nil || !strings.Contains(err.Error(), "secret.vars") {
+		t.Errorf("Got %v", err)
+	}
+
+	scope.VaultPassphrase = "passphrase"
+	defer func() { scope.VaultPassphrase = "" }()
+	vars, err := LoadVariables("secret.vars", fs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if vars["USER"] != "admin" || vars["PASS"] != "vault-pass-42" {
+		t.Errorf("Got %v", vars)
+	}
+	if !scope.IsSecret("vault-pass-42") {
+		t.Errorf("Decrypted value not marked as secret")
+	}
+}
+
+func TestSecretVariables(t *testing.T) {
+	os.Setenv("HT_TEST_SUITE_SECRET", "suite-secret-17")
+	defer os.Unsetenv("HT_TEST_SUITE_SECRET")
+	fs, err := NewFileSystem(`
+# suite.suite
+{
+    Main: [ {File: "test.ht"} ]
+    Variables: { PASS: "@secret:env:HT_TEST_SUITE_SECRET" }
+}
+
+# test.ht
+{
+    Request: { URL: "http://localhost/{{PASS}}" }
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := LoadRawSuite("suite.suite", fs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if rs.Variables["PASS"] != "suite-secret-17" || !scope.IsSecret("suite-secret-17") {
+		t.Errorf("Got %v", rs.Variables)
+	}
+
+	for _, name := range []string{"https://example.org/suite.suite",
+		"git:https://example.org/repo.git@main:suite.suite"} {
+		downloaded := &File{Name: name}
+		err = resolveSecrets(downloaded, map[string]string{"PASS": "@secret:env:HOME"})
+		if err == nil || !strings.Contains(err.Error(), "cannot reference secrets") {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+}
+
+func TestDownloadedLocalFuncs(t *testing.T) {
+	fs, err := NewFileSystem(`
+# https://example.org/env.ht
+{
+    Request: { URL: "http://localhost/{{ENV HOME}}" }
+}
+
+# git:https://example.org/repo.git@main:env.ht
+{
+    Request: { URL: "http://localhost/{{ENV HOME}}" }
+}
+
+# https://example.org/token.ht
+{
+    Request: { Header: { Authorization: "Bearer {{GCP_ID_TOKEN https://evil.example}}" } }
+}
+
+# https://example.org/plain.ht
+{
+    Request: { URL: "http://localhost/{{UUID}}" }
+}
+
+# local.ht
+{
+    Request: { URL: "http://localhost/{{ENV HOME}}" }
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"https://example.org/env.ht",
+		"git:https://example.org/repo.git@main:env.ht",
+		"https://example.org/token.ht"} {
+		_, err = LoadRawTest(name, fs)
+		if err == nil || !strings.Contains(err.Error(), "cannot call ENV or FILE") {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+	for _, name := range []string{"https://example.org/plain.ht", "local.ht"} {
+		if _, err := LoadRawTest(name, fs); err != nil {
+			t.Errorf("%s: unexpected error %s", name, err)
+		}
+	}
+}
+
+func TestDeclarations(t *testing.T) {
+	fs, err := NewFileSystem(`
+# suite.suite
+{
+    Name: "Declarations"
+    Main: [ {File: "test.ht"} ]
+    Variables: { RETRIES: "3" }
+    Declarations: {
+        HOST:    { required: true, pattern: "^https?://", description: "base URL" }
+        TIMEOUT: "duration"
+        RETRIES: { Type: "int", Required: true }
+    }
+}
+
+# test.ht
+{
+    Name: "Test"
+    Request: { URL: "{{HOST}}/" }
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := LoadRawSuite("suite.suite", fs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	for i, tc := range []struct {
+		global map[string]string
+		want   string
+	}{
+		{map[string]string{"HOST": "http://localhost"}, ""},
+		{map[string]string{"HOST": "http://localhost", "TIMEOUT": "2s"}, ""},
+		{nil, "variable HOST: required but not set (base URL)"},
+		{map[string]string{"HOST": "localhost"}, `variable HOST: value "localhost" does not match pattern`},
+		{map[string]string{"HOST": "http://x", "TIMEOUT": "soon"}, `variable TIMEOUT: value "soon" is not of type duration`},
+		{map[string]string{"HOST": "http://x", "RETRIES": "many"}, `variable RETRIES: value "many" is not of type int`},
+	} {
+		err := rs.Validate(tc.global)
+		if tc.want == "" {
+			if err != nil {
+				t.Errorf("%d. Unexpected error: %s", i, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%d. Got %v, want %s", i, err, tc.want)
+		}
+	}
+
+	s := rs.Execute(nil, nil, nil)
+	if s.Status != ht.Bogus || len(s.Tests) != 0 {
+		t.Errorf("Got status %s with %d tests", s.Status, len(s.Tests))
+	}
+
+	fs["bad.suite"] = &File{Name: "bad.suite",
+		Data: `{ Main: [], Declarations: { X: "number" } }`}
+	_, err = LoadRawSuite("bad.suite", fs)
+	if err == nil || !strings.Contains(err.Error(), `unknown variable type "number"`) {
+		t.Errorf("Got %v", err)
+	}
+}
+
+func TestMixinParameters(t *testing.T) {
+	fs, err := NewFileSystem(`
+# test.ht
+{
+    Name: "Test"
+    Mixin: [
+        "login.mix"
+        { File: "login.mix", Namespace: "admin", Variables: { USER: "root" } }
+    ]
+    Request: { URL: "http://example.org/{{admin.USER}}/{{login.USER}}" }
+    Variables: { USER: "default" }
+}
+
+# login.mix
+{
+    Request: { Header: { "X-User": "{{USER}}", "X-Admin": "{{admin.USER}}" } }
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt, err := LoadRawTest("test.ht", fs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(rt.Mixins) != 2 || rt.Mixins[0].Namespace != "login" ||
+		rt.Mixins[1].Namespace != "admin" {
+		t.Fatalf("Got mixins %v", rt.Mixins)
+	}
+
+	test, err := rt.ToTest(scope.Variables{"USER": "default"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if got := test.Request.URL; got != "http://example.org/root/default" {
+		t.Errorf("Got URL %s", got)
+	}
+	if got := test.Request.Header["X-User"]; len(got) != 2 ||
+		got[0] != "default" || got[1] != "root" {
+		t.Errorf("Got X-User %v", got)
+	}
+	if got := test.Request.Header["X-Admin"]; len(got) != 2 ||
+		got[0] != "root" || got[1] != "root" {
+		t.Errorf("Got X-Admin %v", got)
+	}
+
+	rs := &RawSuite{File: &File{Name: "suite.suite"}, tests: []*RawTest{rt}}
+	insp, err := rs.Inspect(nil, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !strings.Contains(insp.Substituted, "http://example.org/root/default") ||
+		len(insp.Unresolved) != 0 {
+		t.Errorf("Got substituted %s, unresolved %v",
+			insp.Substituted, insp.Unresolved)
+	}
+
+	fs["bad.ht"] = &File{Name: "bad.ht", Data: `{ Mixin: [ { Namespace: "x" } ] }`}
+	_, err = LoadRawTest("bad.ht", fs)
+	if err == nil || !strings.Contains(err.Error(), "missing File of mixin") {
+		t.Errorf("Got %v", err)
+	}
+}
@@ -0,0 +1,319 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+//   Remote files
+
+// Files can be loaded from URLs, git repositories and archives, see the
+// package documentation for the syntax of such remote names.
+
+// RemoteCacheDir is the directory used to cache pinned remote artifacts
+// on disk. Caching on disk is disabled if empty.
+var RemoteCacheDir = ""
+
+// RemoteClient is the client used to download remote files.
+var RemoteClient = &http.Client{Timeout: 30 * time.Second}
+
+var remoteCache = struct {
+	sync.Mutex
+	data map[string][]byte
+}{data: make(map[string][]byte)}
+
+// archiveSuffixes are the recognised archive formats.
+var archiveSuffixes = []string{".zip", ".tar", ".tar.gz", ".tgz"}
+
+// isRemote reports whether name is not a plain file in the local file system.
+func isRemote(name string) bool {
+	prefix, _ := splitLocation(name)
+	return prefix != ""
+}
+
+// isDownloaded reports whether name is loaded from a URL, either directly
+// or from an archive at this URL.
+func isDownloaded(name string) bool {
+	return strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://")
+}
+
+// isUntrusted reports whether name is loaded from a URL or from git, i.e.
+// from a source outside the local file system. Such files must not
+// reference secrets or read local files and the environment.
+func isUntrusted(name string) bool {
+	return isDownloaded(name) || strings.HasPrefix(name, "git:")
+}
+
+// LocalFile returns the file in the local file system name is read from:
+// name itself for plain files and the archive for files inside a local
+// archive. The second return value is false for files loaded via HTTP or
+// from git.
+func LocalFile(name string) (string, bool) {
+	if isUntrusted(name) {
+		return "", false
+	}
+	if i := archiveSeparator(name); i >= 0 {
+		archive, _ := splitPin(name[:i])
+		return archive, true
+	}
+	return name, true
+}
+
+// splitLocation splits name into the location prefix and the path inside
+// this location. The prefix is empty for local files.
+func splitLocation(name string) (prefix, rest string) {
+	if i := archiveSeparator(name); i >= 0 {
+		return name[:i+1], name[i+1:]
+	}
+	if strings.HasPrefix(name, "git:") {
+		if at := strings.LastIndex(name, "@"); at > 0 {
+			if colon := strings.Index(name[at:], ":"); colon > 0 {
+				return name[:at+colon+1], name[at+colon+1:]
+			}
+		}
+	}
+	for _, scheme := range []string{"http://", "https://"} {
+		if strings.HasPrefix(name, scheme) {
+			host := name[len(scheme):]
+			if slash := strings.Index(host, "/"); slash >= 0 {
+				return name[:len(scheme)+slash], host[slash:]
+			}
+			return name, "/"
+		}
+	}
+	return "", name
+}
+
+// archiveSeparator returns the index of the '!' separating an archive from
+// the path inside or -1.
+func archiveSeparator(name string) int {
+	for _, suffix := range archiveSuffixes {
+		if i := strings.Index(name, suffix+"!"); i >= 0 {
+			return i + len(suffix)
+		}
+	}
+	return -1
+}
+
+// splitPin splits a trailing "#sha256=<digest>" from name.
+func splitPin(name string) (string, string) {
+	if i := strings.LastIndex(name, "#sha256="); i >= 0 {
+		return name[:i], strings.ToLower(name[i+len("#sha256="):])
+	}
+	return name, ""
+}
+
+// cleanName cleans the path part of name.
+func cleanName(name string) string {
+	prefix, rest := splitLocation(name)
+	if prefix == "" {
+		return path.Clean(filepath.ToSlash(name))
+	}
+	return prefix + path.Clean(rest)
+}
+
+// dirName returns the directory part of name.
+func dirName(name string) string {
+	prefix, rest := splitLocation(name)
+	return prefix + path.Dir(rest)
+}
+
+// joinName joins the directory dir with the relative file name.
+// Remote names and absolute local names are used as is; absolute names
+// inside a remote location are resolved against the root of the location.
+func joinName(dir, name string) string {
+	if isRemote(name) {
+		return name
+	}
+	prefix, rest := splitLocation(dir)
+	switch {
+	case prefix == "" && filepath.IsAbs(name):
+		return name
+	case prefix != "" && path.IsAbs(name):
+		if isDownloaded(prefix) && archiveSeparator(prefix) < 0 {
+			return prefix + path.Clean(name)
+		}
+		return prefix + path.Clean(name)[1:]
+	}
+	return prefix + path.Join(rest, name)
+}
+
+// loadRemote loads the content of the remote file name.
+func loadRemote(name string) ([]byte, error) {
+	name, pin := splitPin(name)
+	prefix, rest := splitLocation(name)
+
+	if archiveSeparator(name) >= 0 {
+		archive := prefix[:len(prefix)-1]
+		data, err := fetchArtifact(archive, pin)
+		if err != nil {
+			return nil, err
+		}
+		return extract(archive, data, rest)
+	}
+	return fetchArtifact(name, pin)
+}
+
+// fetchArtifact retrieves the artifact at location (a URL, a git location
+// or a local file) and checks its digest against pin if given.
+func fetchArtifact(location, pin string) ([]byte, error) {
+	if pin != "" && !isSHA256(pin) {
+		return nil, fmt.Errorf("%s: malformed sha256 pin %q", location, pin)
+	}
+
+	remoteCache.Lock()
+	data, ok := remoteCache.data[location]
+	remoteCache.Unlock()
+
+	cachefile := ""
+	if !ok && pin != "" && RemoteCacheDir != "" {
+		cachefile = filepath.Join(RemoteCacheDir, pin)
+		if cached, err := ioutil.ReadFile(cachefile); err == nil {
+			data, ok = cached, true
+		}
+	}
+
+	if !ok {
+		var err error
+		switch {
+		case strings.HasPrefix(location, "http://"), strings.HasPrefix(location, "https://"):
+			data, err = download(location)
+		case strings.HasPrefix(location, "git:"):
+			data, err = gitShow(location)
+		default:
+			data, err = ioutil.ReadFile(location)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if pin != "" {
+		sum := sha256.Sum256(data)
+		if got := hex.EncodeToString(sum[:]); got != pin {
+			return nil, fmt.Errorf("%s: sha256 is %s, pinned to %s",
+				location, got, pin)
+		}
+		if cachefile != "" {
+			if err := os.MkdirAll(RemoteCacheDir, 0755); err == nil {
+				ioutil.WriteFile(cachefile, data, 0644)
+			}
+		}
+	}
+
+	remoteCache.Lock()
+	remoteCache.data[location] = data
+	remoteCache.Unlock()
+	return data, nil
+}
+
+// isSHA256 reports whether s is a hex encoded SHA-256 digest.
+func isSHA256(s string) bool {
+	if len(s) != 2*sha256.Size {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+func download(url string) ([]byte, error) {
+	resp, err := RemoteClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot download %s: %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// gitShow reads a file from a git repository, location has the form
+// git:<repository>@<ref>:<path>.
+func gitShow(location string) ([]byte, error) {
+	prefix, file := splitLocation(location)
+	spec := strings.TrimSuffix(strings.TrimPrefix(prefix, "git:"), ":")
+	at := strings.LastIndex(spec, "@")
+	if at < 0 || file == "" {
+		return nil, fmt.Errorf("malformed git location %q", location)
+	}
+	repo, ref := spec[:at], spec[at+1:]
+	if ref == "" || strings.HasPrefix(ref, "-") {
+		return nil, fmt.Errorf("malformed git ref %q in %q", ref, location)
+	}
+	cmd := exec.Command("git", "-C", repo, "show", "--end-of-options",
+		ref+":"+strings.TrimPrefix(file, "/"))
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	data, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %s %s", location, err,
+			strings.TrimSpace(stderr.String()))
+	}
+	return data, nil
+}
+
+// extract the file name from the archive data.
+func extract(archive string, data []byte, name string) ([]byte, error) {
+	name = path.Clean(strings.TrimPrefix(name, "/"))
+	if strings.HasSuffix(archive, ".zip") {
+		r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", archive, err)
+		}
+		for _, f := range r.File {
+			if path.Clean(f.Name) != name {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			return ioutil.ReadAll(rc)
+		}
+		return nil, fmt.Errorf("file %s not found in %s", name, archive)
+	}
+
+	var r io.Reader = bytes.NewReader(data)
+	if strings.HasSuffix(archive, ".gz") || strings.HasSuffix(archive, ".tgz") {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", archive, err)
+		}
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %s", archive, err)
+		}
+		if path.Clean(hdr.Name) == name {
+			return ioutil.ReadAll(tr)
+		}
+	}
+	return nil, fmt.Errorf("file %s not found in %s", name, archive)
+}
@@ -0,0 +1,240 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var remoteFiles = map[string]string{
+	"api/main.suite": `{
+    Name: "Remote Suite"
+    Main: [ {File: "tests/a.ht"} ]
+}`,
+	"api/tests/a.ht": `{
+    Name: "Remote Test"
+    Mixin: [ "../common.mix" ]
+    Request: { URL: "http://localhost/" }
+}`,
+	"api/common.mix": `{
+    Request: { Header: { "Accept": "text/html" } }
+}`,
+}
+
+func zipBundle(t *testing.T) []byte {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, content := range remoteFiles {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tgzBundle(t *testing.T) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range remoteFiles {
+		tw.WriteHeader(&tar.Header{Name: "./" + name, Mode: 0644, Size: int64(len(content))})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func checkRemoteSuite(t *testing.T, name string) {
+	rs, err := LoadRawSuite(name, nil)
+	if err != nil {
+		t.Fatalf("%s: Unexpected error: %s", name, err)
+	}
+	if len(rs.tests) != 1 || len(rs.tests[0].Mixins) != 1 {
+		t.Fatalf("%s: Got %+v", name, rs.tests)
+	}
+	if got := rs.tests[0].Mixins[0].Name; !strings.HasSuffix(got, "api/common.mix") {
+		t.Errorf("%s: Got mixin %s", name, got)
+	}
+}
+
+func TestRemoteLoading(t *testing.T) {
+	zipData, tgzData := zipBundle(t), tgzBundle(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch p := strings.TrimPrefix(r.URL.Path, "/"); p {
+		case "bundle.zip":
+			w.Write(zipData)
+		case "bundle.tgz":
+			w.Write(tgzData)
+		default:
+			content, ok := remoteFiles[p]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(content))
+		}
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	local := filepath.ToSlash(filepath.Join(dir, "local.zip"))
+	if err := ioutil.WriteFile(local, zipData, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	checkRemoteSuite(t, ts.URL+"/api/main.suite")
+	checkRemoteSuite(t, ts.URL+"/bundle.zip!api/main.suite")
+	checkRemoteSuite(t, ts.URL+"/bundle.tgz!api/main.suite")
+	checkRemoteSuite(t, local+"!api/main.suite")
+
+	if _, err := LoadFile(ts.URL + "/api/missing.ht"); err == nil {
+		t.Errorf("Missing error for 404")
+	}
+}
+
+func TestRemotePinning(t *testing.T) {
+	content := remoteFiles["api/tests/a.ht"]
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(content))
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "remotecache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(d string) { RemoteCacheDir = d }(RemoteCacheDir)
+	RemoteCacheDir = dir
+
+	sum := sha256.Sum256([]byte(content))
+	pin := hex.EncodeToString(sum[:])
+
+	if _, err := LoadFile(ts.URL + "/bad.ht#sha256=" + strings.Repeat("0", 64)); err == nil ||
+		!strings.Contains(err.Error(), "pinned") {
+		t.Errorf("Got error %v", err)
+	}
+	for _, bad := range []string{"0123", "../../../tmp/" + pin[:51]} {
+		if _, err := LoadFile(ts.URL + "/bad.ht#sha256=" + bad); err == nil ||
+			!strings.Contains(err.Error(), "malformed sha256 pin") {
+			t.Errorf("%s: got error %v", bad, err)
+		}
+	}
+
+	f, err := LoadFile(ts.URL + "/good.ht#sha256=" + pin)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if f.Name != ts.URL+"/good.ht" || f.Data != content {
+		t.Errorf("Got %s %q", f.Name, f.Data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, pin)); err != nil {
+		t.Errorf("Not cached on disk: %s", err)
+	}
+
+	// Served from cache.
+	calls = 0
+	if _, err := LoadFile(ts.URL + "/good.ht#sha256=" + pin); err != nil || calls != 0 {
+		t.Errorf("Got %v, %d calls", err, calls)
+	}
+}
+
+func TestRemoteGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir, err := ioutil.TempDir("", "remotegit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s %s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	for name, content := range remoteFiles {
+		full := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(full), 0755)
+		ioutil.WriteFile(full, []byte(content), 0644)
+	}
+	git("add", ".")
+	git("-c", "user.name=test", "-c", "user.email=test@example.org",
+		"commit", "-q", "-m", "suites")
+	git("tag", "v1")
+
+	checkRemoteSuite(t, "git:"+filepath.ToSlash(dir)+"@v1:api/main.suite")
+
+	_, err = LoadFile("git:" + filepath.ToSlash(dir) + "@--output=/tmp/x:api/main.suite")
+	if err == nil || !strings.Contains(err.Error(), "malformed git ref") {
+		t.Errorf("Got error %v", err)
+	}
+}
+
+func TestJoinName(t *testing.T) {
+	for _, tc := range []struct{ dir, name, want string }{
+		{"testdata", "a.ht", "testdata/a.ht"},
+		{"http://host/x", "../y/a.ht", "http://host/y/a.ht"},
+		{"b.zip!.", "a.ht", "b.zip!a.ht"},
+		{"git:repo@main:suites", "a.ht", "git:repo@main:suites/a.ht"},
+		{"testdata", "https://host/a.ht", "https://host/a.ht"},
+		{"testdata", "/abs/dir/a.ht", "/abs/dir/a.ht"},
+		{"http://host/x", "/y/a.ht", "http://host/y/a.ht"},
+		{"b.zip!x/y", "/a.ht", "b.zip!a.ht"},
+		{"git:repo@main:suites", "/a.ht", "git:repo@main:a.ht"},
+	} {
+		if got := joinName(tc.dir, tc.name); got != tc.want {
+			t.Errorf("joinName(%q, %q)=%q, want %q", tc.dir, tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestLocalFile(t *testing.T) {
+	for _, tc := range []struct {
+		name, want string
+		local      bool
+	}{
+		{"testdata/a.ht", "testdata/a.ht", true},
+		{"testdata/b.zip!x/a.ht", "testdata/b.zip", true},
+		{"b.tar.gz!a.ht", "b.tar.gz", true},
+		{"http://host/b.zip!a.ht", "", false},
+		{"https://host/a.ht", "", false},
+		{"git:repo@main:a.ht", "", false},
+	} {
+		got, local := LocalFile(tc.name)
+		if got != tc.want || local != tc.local {
+			t.Errorf("LocalFile(%q)=%q,%t, want %q,%t",
+				tc.name, got, local, tc.want, tc.local)
+		}
+	}
+}
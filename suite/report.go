@@ -18,10 +18,12 @@ import (
 	"strings"
 	"text/template"
 	"time"
+	"unicode/utf8"
 
 	"github.com/vdobler/ht/errorlist"
 	"github.com/vdobler/ht/ht"
 	mimelist "github.com/vdobler/ht/mime"
+	"github.com/vdobler/ht/scope"
 )
 
 // ----------------------------------------------------------------------------
@@ -37,7 +39,8 @@ var htmlCheckTmpl = `{{define "CHECK"}}
   </label>
   <div class="toggle-content">
     <div class="checkDetails">
-      <div>Checking took {{niceduration .Check.Duration}}</div>
+      <div>Checking took {{niceduration .Check.Duration}}{{if .Check.PrepareDuration}}
+        (preparation {{niceduration .Check.PrepareDuration}}){{end}}</div>
       <div><code>{{.Check.JSON}}</code></div>
       {{if eq .Check.Status 3 5}}<code>Error: {{errlist .Check.Error}}</code>{{end}}
     </div>
@@ -597,13 +600,29 @@ func init() {
 }
 
 // PrintReport outputs a textual report of s to w.
+// Secret values are masked.
 func (s *Suite) PrintReport(w io.Writer) error {
-	return SuiteTmpl.Execute(w, s)
+	return executeMasked(SuiteTmpl, w, s)
 }
 
 // PrintShortReport outputs a short textual report of s to w.
+// Secret values are masked.
 func (s *Suite) PrintShortReport(w io.Writer) error {
-	return ShortSuiteTmpl.Execute(w, s)
+	return executeMasked(ShortSuiteTmpl, w, s)
+}
+
+// executeMasked executes tmpl with data and writes the output with all
+// secret values masked to w.
+func executeMasked(tmpl interface {
+	Execute(io.Writer, interface{}) error
+}, w io.Writer, data interface{}) error {
+	buf := &bytes.Buffer{}
+	err := tmpl.Execute(buf, data)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, scope.Mask(buf.String()))
+	return err
 }
 
 // TODO: sniff if unavailable
@@ -656,6 +675,9 @@ func augmentMetadataAndDumpBody(s *Suite, dir string, prefix string) error {
 		}
 		fn := fmt.Sprintf("%s.ResponseBody.%s", seqno, extension)
 		name := path.Join(dir, fn)
+		if utf8.Valid(body) {
+			body = []byte(scope.Mask(string(body)))
+		}
 		err := ioutil.WriteFile(name, body, 0666)
 		errs = errs.Append(err)
 	}
@@ -673,8 +695,9 @@ func HTMLReport(dir string, s *Suite) error {
 	report, err := os.Create(path.Join(dir, "_Report_.html"))
 	errs = errs.Append(err)
 	if err == nil {
-		err = HtmlSuiteTmpl.Execute(report, s)
+		err = executeMasked(HtmlSuiteTmpl, report, s)
 		errs = errs.Append(err)
+		errs = errs.Append(report.Close())
 	}
 
 	return errs.AsError()
@@ -809,7 +832,7 @@ func (s *Suite) JUnit4XML() (string, error) {
 
 	data, err := xml.MarshalIndent(ts, "", "  ")
 	if err != nil {
-		return string(data), err
+		return scope.Mask(string(data)), err
 	}
-	return xml.Header + string(data) + "\n", nil
+	return xml.Header + scope.Mask(string(data)) + "\n", nil
 }
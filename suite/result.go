@@ -0,0 +1,304 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/vdobler/ht/errorlist"
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/scope"
+)
+
+// ----------------------------------------------------------------------------
+//   Reporter
+
+// A Reporter writes the result of an executed suite in some format to a
+// folder, e.g. as part of the results saved by 'ht exec'.
+type Reporter interface {
+	Report(dir string, s *Suite) error
+}
+
+// ReporterFunc adapts an ordinary function to the Reporter interface.
+type ReporterFunc func(dir string, s *Suite) error
+
+// Report implements Reporter.
+func (f ReporterFunc) Report(dir string, s *Suite) error { return f(dir, s) }
+
+// ----------------------------------------------------------------------------
+//   JSON Result
+
+// ResultVersion is the version of the JSON result format described by
+// SuiteResult. It is incremented on incompatible changes only: Fields
+// may be added to the format without changing the version, but fields
+// are never removed or changed in meaning within one version.
+const ResultVersion = 1
+
+// SuiteResult is the outcome of an executed suite in a stable format
+// intended for consumption by other tools. All durations are given in
+// milliseconds, all times in RFC 3339 format and the statuses are one of
+// "NotRun", "Skipped", "Pass", "Fail", "Error" or "Bogus". Values of
+// secret variables are masked.
+type SuiteResult struct {
+	Version     int    // Version is ResultVersion.
+	Name        string // Name of the suite.
+	Description string // Description of the suite.
+	Status      string // Status is the overall status of the suite.
+
+	// Errors encountered during execution of the suite (not of
+	// individual tests).
+	Errors []string `json:",omitempty"`
+
+	// Details are the Errors with their code, category and field.
+	Details []errorlist.Error `json:",omitempty"`
+
+	Started    time.Time // Started is the start of the execution.
+	DurationMS int64     // DurationMS is the duration of the execution.
+
+	// Connections are the statistics of the suite's ClientPool.
+	Connections *ht.ConnStats `json:",omitempty"`
+
+	// Variables are the initial, FinalVariables the final variables
+	// of the suite.
+	Variables      map[string]string
+	FinalVariables map[string]string
+
+	Tests []TestResult // Tests are the executed tests in order.
+}
+
+// TestResult is the outcome of one executed test.
+type TestResult struct {
+	SeqNo       string // SeqNo of the test like "Main-03".
+	Name        string // Name of the test.
+	Description string `json:",omitempty"`
+	File        string `json:",omitempty"` // File the test was read from.
+	Status      string // Status of the test.
+	TraceID     string `json:",omitempty"` // TraceID if the test was traced.
+
+	// Errors are the reasons for a status of Fail, Error or Bogus.
+	Errors []string `json:",omitempty"`
+
+	// Details are the Errors with their code, category and field.
+	Details []errorlist.Error `json:",omitempty"`
+
+	// Diagnostics collected by the OnFailure hook of the test.
+	Diagnostics string `json:",omitempty"`
+
+	Started        time.Time
+	DurationMS     int64 // DurationMS of the last try.
+	FullDurationMS int64 // FullDurationMS of all tries.
+	Tries          int
+
+	Method     string // Method and URL of the request sent.
+	URL        string
+	StatusCode int `json:",omitempty"` // StatusCode of the response.
+
+	// Connections used for the request of the last try.
+	Connections *ht.ConnStats `json:",omitempty"`
+
+	// Variables are the variables used to produce the test and
+	// Extracted the variables extracted from the response.
+	Variables map[string]string `json:",omitempty"`
+	Extracted map[string]string `json:",omitempty"`
+
+	Checks []CheckResult // Checks contains the result of each check.
+}
+
+// CheckResult is the outcome of one check of a test.
+type CheckResult struct {
+	Name       string          // Name of the check like "StatusCode".
+	Check      json.RawMessage // Check is the check in JSON.
+	Status     string
+	DurationMS int64
+	PrepareMS  int64             `json:",omitempty"` // PrepareMS is the preparation time.
+	Errors     []string          `json:",omitempty"`
+	Details    []errorlist.Error `json:",omitempty"`
+}
+
+// Result returns the outcome of s in the stable format of SuiteResult.
+func (s *Suite) Result() *SuiteResult {
+	result := &SuiteResult{
+		Version:        ResultVersion,
+		Name:           s.Name,
+		Description:    s.Description,
+		Status:         s.Status.String(),
+		Errors:         errorStrings(s.Error),
+		Details:        errorlist.Details(s.Error),
+		Started:        s.Started,
+		DurationMS:     milliseconds(s.Duration),
+		Variables:      scope.Variables(s.Variables).MaskedCopy(),
+		FinalVariables: scope.Variables(s.FinalVariables).MaskedCopy(),
+		Tests:          make([]TestResult, 0, len(s.Tests)),
+	}
+	if s.ClientPool != nil {
+		stats := s.ClientPool.Stats()
+		result.Connections = &stats
+	}
+	for _, test := range s.Tests {
+		result.Tests = append(result.Tests, testResult(test))
+	}
+	return result
+}
+
+func testResult(test *ht.Test) TestResult {
+	tr := TestResult{
+		SeqNo:          test.GetStringMetadata("SeqNo"),
+		Name:           test.Name,
+		Description:    test.Description,
+		File:           test.GetStringMetadata("Filename"),
+		Status:         test.Result.Status.String(),
+		TraceID:        test.GetStringMetadata("TraceID"),
+		Errors:         errorStrings(test.Result.Error),
+		Details:        errorlist.Details(test.Result.Error),
+		Diagnostics:    test.Result.Diagnostics,
+		Started:        test.Result.Started,
+		DurationMS:     milliseconds(test.Result.Duration),
+		FullDurationMS: milliseconds(test.Result.FullDuration),
+		Tries:          test.Result.Tries,
+		Method:         test.Request.Method,
+		URL:            test.Request.URL,
+		Variables:      scope.Variables(test.Variables).MaskedCopy(),
+		Checks:         make([]CheckResult, 0, len(test.Result.CheckResults)),
+	}
+	if tr.Method == "" {
+		tr.Method = "GET"
+	}
+	if test.Response.Response != nil {
+		tr.StatusCode = test.Response.Response.StatusCode
+	}
+	if conns := test.Response.Connections; conns.Requests > 0 {
+		tr.Connections = &conns
+	}
+	if len(test.Result.Extractions) > 0 {
+		tr.Extracted = map[string]string{}
+		for name, ex := range test.Result.Extractions {
+			if ex.Error == nil {
+				tr.Extracted[name] = scope.Mask(ex.Value)
+			}
+		}
+	}
+	for _, cr := range test.Result.CheckResults {
+		check := json.RawMessage(cr.JSON)
+		if !json.Valid(check) {
+			check = json.RawMessage("null")
+		}
+		tr.Checks = append(tr.Checks, CheckResult{
+			Name:       cr.Name,
+			Check:      check,
+			Status:     cr.Status.String(),
+			DurationMS: milliseconds(cr.Duration),
+			PrepareMS:  milliseconds(cr.PrepareDuration),
+			Errors:     errorStrings(cr.Error.AsError()),
+			Details:    cr.Error.Details(),
+		})
+	}
+	return tr
+}
+
+// Trimmed returns a copy of s whose tests keep at most maxBody bytes of
+// their response bodies. It allows to report on many suites without
+// keeping all response bodies in memory.
+func (s *Suite) Trimmed(maxBody int) *Suite {
+	trimmed := *s
+	trimmed.Tests = make([]*ht.Test, len(s.Tests))
+	for i, test := range s.Tests {
+		t := *test
+		t.Response = test.Response.Trimmed(maxBody)
+		trimmed.Tests[i] = &t
+	}
+	return &trimmed
+}
+
+// WriteJSON writes the result of s as indented JSON to w.
+func (s *Suite) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(s.Result(), "", "    ")
+	if err != nil {
+		return err
+	}
+	data = append([]byte(scope.Mask(string(data))), '\n')
+	_, err = w.Write(data)
+	return err
+}
+
+// JSONReporter writes the result of a suite as result.json.
+var JSONReporter = ReporterFunc(func(dir string, s *Suite) error {
+	buf := &bytes.Buffer{}
+	if err := s.WriteJSON(buf); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "result.json"), buf.Bytes(), 0666)
+})
+
+// errorStrings returns the messages in err or nil if err is nil.
+func errorStrings(err error) []string {
+	if err == nil {
+		return nil
+	}
+	if el, ok := err.(errorlist.List); ok {
+		return el.AsStrings()
+	}
+	return []string{err.Error()}
+}
+
+func milliseconds(d time.Duration) int64 {
+	return int64(d / time.Millisecond)
+}
+
+// wireRequest returns the request sent by test in HTTP/1.1 wire format
+// or the empty string if no request was sent.
+func wireRequest(test *ht.Test) string {
+	req := test.Request.Request
+	if req == nil {
+		return ""
+	}
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "%s %s HTTP/1.1\n", req.Method, req.URL.String())
+	writeSortedHeader(buf, req.Header)
+	if test.Request.SentBody != "" {
+		fmt.Fprintf(buf, "\n%s\n", test.Request.SentBody)
+	}
+	return buf.String()
+}
+
+// wireResponse returns the response received by test or the empty
+// string if no response was received.
+func wireResponse(test *ht.Test) string {
+	resp := test.Response.Response
+	if resp == nil {
+		return ""
+	}
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "%s %s\n", resp.Proto, resp.Status)
+	writeSortedHeader(buf, resp.Header)
+	if test.Response.BodyStr != "" {
+		fmt.Fprintf(buf, "\n%s\n", test.Response.BodyStr)
+	}
+	if omitted := test.Response.BodySize - int64(len(test.Response.BodyStr)); omitted > 0 {
+		fmt.Fprintf(buf, "[%d more bytes omitted]\n", omitted)
+	}
+	return buf.String()
+}
+
+// writeSortedHeader writes header to buf sorted by name.
+func writeSortedHeader(buf *bytes.Buffer, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range header[name] {
+			fmt.Fprintf(buf, "%s: %s\n", name, v)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/vdobler/ht/errorlist"
+	"github.com/vdobler/ht/ht"
+)
+
+func TestSuiteResult(t *testing.T) {
+	test := &ht.Test{
+		Name:      "Login",
+		Request:   ht.Request{Method: "POST", URL: "http://example.org/login"},
+		Variables: map[string]string{"USER": "joe"},
+		Result: ht.Result{
+			Status:       ht.Fail,
+			Error:        errorlist.List{fmt.Errorf("Got 404, want 200")},
+			Duration:     1500 * time.Millisecond,
+			FullDuration: 3 * time.Second,
+			Tries:        2,
+			CheckResults: []ht.CheckResult{
+				{
+					Name:     "StatusCode",
+					JSON:     `{"Expect":200}`,
+					Status:   ht.Fail,
+					Duration: 2 * time.Millisecond,
+					Error: errorlist.List{errorlist.Error{
+						Code:     "check.StatusCode",
+						Category: errorlist.Check,
+						Field:    "Checks[0]",
+						Err:      fmt.Errorf("Got 404, want 200"),
+					}},
+				},
+			},
+			Extractions: map[string]ht.Extraction{
+				"TOKEN": {Value: "abc"},
+				"OTHER": {Error: fmt.Errorf("not found")},
+			},
+		},
+	}
+	test.SetMetadata("SeqNo", "Main-01")
+	s := &Suite{
+		Name:     "Shop",
+		Status:   ht.Fail,
+		Duration: 3 * time.Second,
+		Tests:    []*ht.Test{test},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := s.WriteJSON(buf); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	got := SuiteResult{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Unexpected error: %s\n%s", err, buf)
+	}
+	if got.Version != ResultVersion || got.Status != "Fail" || got.DurationMS != 3000 ||
+		len(got.Tests) != 1 {
+		t.Fatalf("Got %+v", got)
+	}
+	tr := got.Tests[0]
+	if tr.SeqNo != "Main-01" || tr.Method != "POST" || tr.Status != "Fail" ||
+		tr.DurationMS != 1500 || tr.FullDurationMS != 3000 || tr.Tries != 2 ||
+		len(tr.Errors) != 1 || tr.Variables["USER"] != "joe" {
+		t.Errorf("Got %+v", tr)
+	}
+	if len(tr.Extracted) != 1 || tr.Extracted["TOKEN"] != "abc" {
+		t.Errorf("Got extracted %v", tr.Extracted)
+	}
+	if len(tr.Checks) != 1 {
+		t.Fatalf("Got %d checks", len(tr.Checks))
+	}
+	check := &bytes.Buffer{}
+	json.Compact(check, tr.Checks[0].Check)
+	if cr := tr.Checks[0]; cr.Name != "StatusCode" || check.String() != `{"Expect":200}` ||
+		cr.Status != "Fail" || cr.DurationMS != 2 || len(cr.Errors) != 1 {
+		t.Errorf("Got check %+v", cr)
+	}
+	if d := tr.Checks[0].Details; len(d) != 1 || d[0].Code != "check.StatusCode" ||
+		d[0].Category != errorlist.Check || d[0].Field != "Checks[0]" ||
+		d[0].Error() != "Got 404, want 200" {
+		t.Errorf("Got details %+v", d)
+	}
+	if d := tr.Details; len(d) != 1 || d[0].Code != "" || d[0].Error() != "Got 404, want 200" {
+		t.Errorf("Got details %+v", d)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/mock"
+	"github.com/vdobler/ht/populate"
+)
+
+// ----------------------------------------------------------------------------
+//   JSON Schema
+
+// SchemaKinds lists the kinds of files a JSON Schema is available for.
+var SchemaKinds = []string{"test", "mixin", "suite", "mock", "load"}
+
+// ValidateSchema switches on validation of suite, test, mock and load test
+// files against their JSON Schema before populating them.
+var ValidateSchema = false
+
+// Schema returns the JSON Schema document for files of the given kind
+// which must be one of SchemaKinds.
+func Schema(kind string) (map[string]interface{}, error) {
+	g := populate.NewSchemaGenerator()
+	var schema map[string]interface{}
+	switch kind {
+	case "test", "mixin":
+		schema = g.StructSchema(ht.Test{})
+		props := schema["properties"].(map[string]interface{})
+		props["Mixin"] = g.Schema([]MixinRef{})
+	case "suite":
+		schema = g.StructSchema(RawSuite{})
+		dropFileProperties(schema)
+	case "mock":
+		schema = g.StructSchema(mock.Mock{})
+	case "load":
+		schema = g.StructSchema(RawLoadTest{})
+		dropFileProperties(schema)
+	default:
+		return nil, fmt.Errorf("no schema for kind %q, use one of %v",
+			kind, SchemaKinds)
+	}
+	doc := g.Document(schema)
+	if defs, ok := doc["definitions"].(map[string]interface{}); ok {
+		delete(defs, "suite.File")
+	}
+	return doc, nil
+}
+
+// dropFileProperties removes the properties of the embedded *File which
+// are not part of the file content.
+func dropFileProperties(schema map[string]interface{}) {
+	props := schema["properties"].(map[string]interface{})
+	delete(props, "File")
+	delete(props, "Data")
+}
+
+var schemaCache = struct {
+	sync.Mutex
+	schemas map[string]map[string]interface{}
+}{schemas: make(map[string]map[string]interface{})}
+
+// CheckSchema validates the content of f against the JSON Schema of the
+// given kind. All violations are reported.
+func (f *File) CheckSchema(kind string) error {
+	schemaCache.Lock()
+	schema, ok := schemaCache.schemas[kind]
+	if !ok {
+		var err error
+		schema, err = Schema(kind)
+		if err != nil {
+			schemaCache.Unlock()
+			return err
+		}
+		schemaCache.schemas[kind] = schema
+	}
+	schemaCache.Unlock()
+	m, err := f.decode()
+	if err != nil {
+		return err
+	}
+	err = populate.Validate(schema, m, kind)
+	if err != nil {
+		return fmt.Errorf("file %s does not match schema: %s", f.Name, err)
+	}
+	return nil
+}
+
+// checkSchema validates f against the schema of kind if ValidateSchema
+// is set.
+func (f *File) checkSchema(kind string) error {
+	if !ValidateSchema {
+		return nil
+	}
+	return f.CheckSchema(kind)
+}
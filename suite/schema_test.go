@@ -0,0 +1,110 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSchemaTestdata(t *testing.T) {
+	kinds := map[string]string{
+		".ht":    "test",
+		".mix":   "mixin",
+		".suite": "suite",
+		".mock":  "mock",
+		".load":  "load",
+	}
+	// These files still use the outdated fields BasedOn and VarEx.
+	outdated := map[string]bool{
+		"testdata/m1.mock": true,
+		"testdata/c/d.ht":  true,
+		"testdata/c/n.mix": true,
+	}
+	filenames, err := filepath.Glob("testdata/*.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	more, _ := filepath.Glob("testdata/c/*")
+	for _, filename := range append(filenames, more...) {
+		kind, ok := kinds[filepath.Ext(filename)]
+		if !ok || outdated[filepath.ToSlash(filename)] ||
+			strings.HasPrefix(filepath.Base(filename), "wrong") {
+			continue
+		}
+		file, err := LoadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := file.CheckSchema(kind); err != nil {
+			t.Errorf("%s: %s", filename, err)
+		}
+	}
+}
+
+var schemaErrorTests = []struct {
+	kind, data string
+	want       []string
+}{
+	{"test", `{Name: "x", Reqest: {URL: "http://example.org"}}`,
+		[]string{"unknown field Reqest in test (did you mean Request?)"}},
+	{"test", `{Name: "x", Checks: [{Check: "StatusCode", Expekt: 200}]}`,
+		[]string{"unknown field Expekt in test.Checks[0] (did you mean Expect?)"}},
+	{"test", `{Name: "x", Checks: [{Check: "StatusCod"}]}`,
+		[]string{"test.Checks[0].Check"}},
+	{"test", `{Name: "x", Request: {Method: ["GET"]}}`,
+		[]string{"test.Request.Method must be"}},
+	{"suite", `{Name: "x", Main: [{File: "a.ht"}], Verbosity: "high"}`,
+		nil},
+	{"suite", `{Name: "x", Mian: [{File: "a.ht"}]}`,
+		[]string{"unknown field Mian in suite (did you mean Main?)"}},
+	{"suite", `{Name: "x", Data: "a"}`,
+		[]string{"unknown field Data in suite"}},
+	{"mock", `{Name: "m", Method: "GET", URL: "http://localhost/", Response: {StatusCode: 200}}`,
+		nil},
+}
+
+func TestSchemaErrors(t *testing.T) {
+	for i, tc := range schemaErrorTests {
+		file := &File{Name: "file", Data: tc.data}
+		err := file.CheckSchema(tc.kind)
+		if len(tc.want) == 0 {
+			if err != nil {
+				t.Errorf("%d. unexpected error %s", i, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%d. missing error", i)
+			continue
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%d. got %q, want %q", i, err, want)
+			}
+		}
+	}
+}
+
+func TestValidateSchemaOnLoad(t *testing.T) {
+	fs, err := NewFileSystem(`
+# bad.suite
+{
+    Name: "Bad suite"
+    Mian: [ {File: "a.ht"} ]
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ValidateSchema = true
+	defer func() { ValidateSchema = false }()
+	_, err = LoadRawSuite("bad.suite", fs)
+	if err == nil || !strings.Contains(err.Error(), "did you mean Main?") {
+		t.Errorf("Got %v", err)
+	}
+}
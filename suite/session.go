@@ -0,0 +1,120 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/vdobler/ht/cookiejar"
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/mock"
+	"github.com/vdobler/ht/scope"
+)
+
+// ----------------------------------------------------------------------------
+//   Session
+
+// A Session executes the tests of a suite individually and in any order.
+// The variable scope and the cookies are kept between the executions just
+// like during the execution of the whole suite. This allows to develop and
+// debug the tests of a suite interactively.
+type Session struct {
+	// Suite collects the executed tests.
+	*Suite
+
+	// Last is the most recently executed test, nil if no test has
+	// been executed yet.
+	Last *ht.Test
+
+	raw    *RawSuite
+	global map[string]string
+}
+
+// NewSession sets up a session for the tests of rs executed with the given
+// global variables. A nil logger discards all log output.
+func NewSession(rs *RawSuite, global map[string]string, logger *log.Logger) (*Session, error) {
+	jar, _ := cookiejar.New(nil)
+	suite := NewFromRaw(rs, global, jar, logger)
+	if suite.setupErr != nil {
+		return nil, suite.setupErr
+	}
+	return &Session{Suite: suite, raw: rs, global: global}, nil
+}
+
+// RawTests returns the tests which can be executed in s.
+func (s *Session) RawTests() []*RawTest {
+	return s.raw.tests
+}
+
+// Scope returns the current variable scope of s. Changes to the returned
+// variables affect the following executions.
+func (s *Session) Scope() scope.Variables {
+	return s.globals
+}
+
+// Test produces the n'th (0-based, counting setup, main and teardown
+// tests) test of the suite in the current variable scope without
+// executing it.
+func (s *Session) Test(n int) (*ht.Test, error) {
+	if n < 0 || n >= len(s.raw.tests) {
+		return nil, fmt.Errorf("no test %d in suite %s", n+1, s.raw.File.Name)
+	}
+	test, _, err := s.newTest(s.raw.tests[n])
+	return test, err
+}
+
+// Run executes the n'th (0-based) test of the suite in the current variable
+// scope. Variables extracted by a passing test are added to the scope.
+func (s *Session) Run(n int) (*ht.Test, error) {
+	return s.RunContext(context.Background(), n)
+}
+
+// RunContext works like Run but executes the test with ctx, see
+// ht.Test.RunContext.
+func (s *Session) RunContext(ctx context.Context, n int) (*ht.Test, error) {
+	if n < 0 || n >= len(s.raw.tests) {
+		return nil, fmt.Errorf("no test %d in suite %s", n+1, s.raw.File.Name)
+	}
+	rt := s.raw.tests[n]
+	test, testScope, _ := s.newTest(rt)
+	ctrl, merr := mock.Provide(s.newMocks(rt, test, testScope), s.Log)
+	if merr != nil {
+		test.Result.Status = ht.Bogus
+		test.Result.Error = merr
+	}
+	test.Journal = ctrl.Journal()
+
+	if test.Result.Status != ht.Bogus {
+		test.Execution.Verbosity = s.raw.Verbosity
+		test.RunContext(ctx)
+	}
+	if merr == nil {
+		analyseMocks(test, ctrl)
+	}
+	s.updateVariables(test)
+	s.Tests = append(s.Tests, test)
+	s.Last = test
+	return test, nil
+}
+
+// Reload reads the suite and all its tests again from fs. The variable
+// scope and the cookies of s are kept, changes to the suite variables in
+// the file are applied.
+func (s *Session) Reload(fs FileSystem) error {
+	rs, err := LoadRawSuite(s.raw.File.Name, fs)
+	if err != nil {
+		return err
+	}
+	for name, value := range rs.Variables {
+		if s.raw.Variables[name] != value {
+			s.globals[name] = value
+		}
+	}
+	rs.profile = s.raw.profile
+	s.raw = rs
+	return nil
+}
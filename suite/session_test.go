@@ -0,0 +1,104 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vdobler/ht/ht"
+)
+
+var sessionFS = `
+# session.suite
+{
+    Main: [ {File: "login.ht"}, {File: "greet.ht"} ]
+    Variables: { USER: "joe" }
+}
+
+# login.ht
+{
+    Request: { URL: "{{URL}}/login?user={{USER}}" }
+    DataExtraction: { TOKEN: {Extractor: "BodyExtractor", Regexp: "[a-z0-9]+"} }
+}
+
+# greet.ht
+{
+    Request: { URL: "{{URL}}/greet?token={{TOKEN}}" }
+    Checks: [ {Check: "Body", Equals: "Hello joe"} ]
+}
+`
+
+func TestSession(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			fmt.Fprintf(w, "tok%s", r.FormValue("user"))
+		case "/greet":
+			if token := r.FormValue("token"); len(token) > 3 {
+				fmt.Fprintf(w, "Hello %s", token[3:])
+			}
+		}
+	}))
+	defer ts.Close()
+
+	fs, err := NewFileSystem(sessionFS)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	rs, err := LoadRawSuite("session.suite", fs)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	s, err := NewSession(rs, map[string]string{"URL": ts.URL}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+
+	// Running greet.ht without login must fail.
+	test, err := s.Run(1)
+	if err != nil || test.Result.Status != ht.Fail {
+		t.Errorf("Got %v %s", err, test.Result.Status)
+	}
+
+	test, _ = s.Run(0)
+	if test.Result.Status != ht.Pass || s.Scope()["TOKEN"] != "tokjoe" {
+		t.Fatalf("Got %s %v, TOKEN=%q", test.Result.Status,
+			test.Result.Error, s.Scope()["TOKEN"])
+	}
+	test, _ = s.Run(1)
+	if test.Result.Status != ht.Pass || s.Last != test {
+		t.Errorf("Got %s %v", test.Result.Status, test.Result.Error)
+	}
+
+	// Modified scope.
+	s.Scope()["TOKEN"] = "tokjim"
+	test, _ = s.Run(1)
+	if test.Result.Status != ht.Fail {
+		t.Errorf("Got %s", test.Result.Status)
+	}
+	if len(s.Tests) != 4 {
+		t.Errorf("Got %d tests", len(s.Tests))
+	}
+
+	// Reload with edited test.
+	fs["greet.ht"].Data = `{
+    Request: { URL: "{{URL}}/greet?token={{TOKEN}}" }
+    Checks: [ {Check: "Body", Equals: "Hello jim"} ]
+}`
+	if err := s.Reload(fs); err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	test, _ = s.Run(1)
+	if test.Result.Status != ht.Pass {
+		t.Errorf("Got %s %v", test.Result.Status, test.Result.Error)
+	}
+
+	if _, err := s.Run(2); err == nil {
+		t.Errorf("Missing error for nonexisting test")
+	}
+}
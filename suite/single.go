@@ -0,0 +1,271 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/vdobler/ht/ht"
+)
+
+// ----------------------------------------------------------------------------
+//   Single File Report
+
+// SingleFileReport writes a self-contained HTML report of the executed
+// suites to w. All styles and scripts are inlined so the report is a
+// single file which can be attached to a ticket or sent by mail. The
+// suites and tests are collapsible, can be filtered by status and searched
+// and each test shows the full request and response, the details of each
+// check and the variables. A timing waterfall shows when each test of a
+// suite was executed. Values of secret variables are masked.
+func SingleFileReport(w io.Writer, title string, suites []*Suite) error {
+	data := singleReport{
+		Title:     title,
+		Generated: time.Now().Format(time.RFC1123),
+		Status:    ht.NotRun,
+		Count:     map[string]int{},
+	}
+	for _, s := range suites {
+		if s.Status > data.Status {
+			data.Status = s.Status
+		}
+		ss := singleSuite{
+			SuiteResult: s.Result(),
+			Index:       len(data.Suites) + 1,
+		}
+		ss.Vars = variableRows(ss.Variables, ss.FinalVariables)
+		origin, span := testSpan(s.Tests)
+		for i, test := range s.Tests {
+			data.Count[test.Result.Status.String()]++
+			data.Total++
+			ss.Tests = append(ss.Tests, singleTest{
+				TestResult: &ss.SuiteResult.Tests[i],
+				ID:         fmt.Sprintf("s%dt%d", ss.Index, i+1),
+				Tags:       test.Tags,
+				Request:    wireRequest(test),
+				Response:   wireResponse(test),
+				Vars: variableRows(ss.SuiteResult.Tests[i].Variables,
+					ss.SuiteResult.Tests[i].Extracted),
+				Left:  waterfallPercent(test.Result.Started.Sub(origin), span),
+				Width: waterfallPercent(test.Result.Duration, span),
+			})
+		}
+		data.Suites = append(data.Suites, ss)
+	}
+	return executeMasked(singleReportTmpl, w, data)
+}
+
+// singleReport is the data rendered by singleReportTmpl.
+type singleReport struct {
+	Title     string
+	Generated string
+	Status    ht.Status
+	Total     int
+	Count     map[string]int // Count the tests by status.
+	Suites    []singleSuite
+}
+
+type singleSuite struct {
+	*SuiteResult
+	Index int
+	Vars  []variableRow // initial and final values
+	Tests []singleTest
+}
+
+type singleTest struct {
+	*TestResult
+	ID                string
+	Tags              []string
+	Request, Response string        // in wire format
+	Vars              []variableRow // used and extracted values
+
+	// Left and Width of the bar in the timing waterfall in percent of
+	// the suite duration.
+	Left, Width float64
+}
+
+// variableRow is a row in a table of variables showing two values A and B.
+type variableRow struct {
+	Name, A, B string
+}
+
+// variableRows joins a and b into rows sorted by variable name.
+func variableRows(a, b map[string]string) []variableRow {
+	names := map[string]string{}
+	for name := range a {
+		names[name] = name
+	}
+	for name := range b {
+		names[name] = name
+	}
+	rows := make([]variableRow, 0, len(names))
+	for _, name := range sortedNames(names) {
+		rows = append(rows, variableRow{Name: name, A: a[name], B: b[name]})
+	}
+	return rows
+}
+
+// testSpan returns the start of the first executed test and the duration
+// until the end of the last executed test. (The start of a suite is
+// truncated to full seconds and not suitable as origin of the waterfall.)
+func testSpan(tests []*ht.Test) (origin time.Time, span time.Duration) {
+	var end time.Time
+	for _, test := range tests {
+		started := test.Result.Started
+		if started.IsZero() {
+			continue
+		}
+		if origin.IsZero() || started.Before(origin) {
+			origin = started
+		}
+		if stop := started.Add(test.Result.Duration); stop.After(end) {
+			end = stop
+		}
+	}
+	return origin, end.Sub(origin)
+}
+
+// waterfallPercent returns d in percent of total, clipped to [0,100].
+// Nonzero durations are at least 0.2 percent to keep them visible.
+func waterfallPercent(d, total time.Duration) float64 {
+	if total <= 0 || d < 0 {
+		return 0
+	}
+	p := 100 * float64(d) / float64(total)
+	switch {
+	case p > 100:
+		p = 100
+	case d > 0 && p < 0.2:
+		p = 0.2
+	}
+	return float64(int(10*p)) / 10
+}
+
+var singleReportTmpl = template.Must(template.New("single").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta http-equiv="content-type" content="text/html; charset=UTF-8" />
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 1em 2em; }
+pre { background: #f4f4f4; padding: 0.5em; overflow-x: auto; white-space: pre-wrap; }
+table { border-collapse: collapse; margin: 0.5em 0; }
+td, th { border: 1px solid #ccc; padding: 2px 6px; text-align: left; vertical-align: top; font-size: 90%; }
+details { margin: 0.3em 0; }
+details.suite > summary { font-size: 130%; font-weight: bold; }
+details.test { margin-left: 1.5em; border-left: 3px solid #ddd; padding-left: 0.5em; }
+summary { cursor: pointer; }
+.Pass { color: green; } .Fail { color: red; } .Error { color: magenta; }
+.NotRun, .Skipped { color: grey; } .Bogus { color: brown; }
+.tag { background: #e0e8f0; border-radius: 3px; padding: 0 4px; font-size: 80%; }
+.waterfall { position: relative; height: 1.1em; background: #f4f4f4; width: 40em; }
+.bar { position: absolute; height: 100%; background: #8ab; }
+.bar.Fail, .bar.Error, .bar.Bogus { background: #e88; }
+#filter { position: sticky; top: 0; background: white; padding: 0.5em 0; border-bottom: 1px solid #ccc; }
+.hidden { display: none; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}: <span class="{{.Status}}">{{.Status}}</span></h1>
+<p>Total {{.Total}},
+  <span class="Pass">Passed {{index .Count "Pass"}}</span>,
+  <span class="Skipped">Skipped {{index .Count "Skipped"}}</span>,
+  <span class="Error">Errored {{index .Count "Error"}}</span>,
+  <span class="Fail">Failed {{index .Count "Fail"}}</span>,
+  <span class="Bogus">Bogus {{index .Count "Bogus"}}</span>
+  &mdash; generated {{.Generated}}</p>
+
+<div id="filter">
+  <label><input type="checkbox" class="status" value="Pass" checked> <span class="Pass">Pass</span></label>
+  <label><input type="checkbox" class="status" value="Fail" checked> <span class="Fail">Fail</span></label>
+  <label><input type="checkbox" class="status" value="Error" checked> <span class="Error">Error</span></label>
+  <label><input type="checkbox" class="status" value="Bogus" checked> <span class="Bogus">Bogus</span></label>
+  <label><input type="checkbox" class="status" value="Skipped" checked> <span class="Skipped">Skipped</span></label>
+  <label><input type="checkbox" class="status" value="NotRun" checked> <span class="NotRun">NotRun</span></label>
+  &nbsp; <input type="search" id="search" placeholder="Search" size="30">
+  &nbsp; <button type="button" id="expand">Expand all</button>
+  <button type="button" id="collapse">Collapse all</button>
+</div>
+
+{{range .Suites}}
+<details class="suite" open>
+<summary><span class="{{.Status}}">{{.Status}}</span> {{.Index}}. {{.Name}}
+  <small>({{.DurationMS}} ms)</small></summary>
+{{if .Description}}<p>{{.Description}}</p>{{end}}
+{{range .Errors}}<pre class="Error">{{.}}</pre>{{end}}
+
+<h3>Timing</h3>
+<table>
+{{range .Tests}}<tr><td>{{.SeqNo}}</td><td>{{.Name}}</td><td>{{.DurationMS}} ms</td>
+<td><div class="waterfall"><div class="bar {{.Status}}" style="left: {{.Left}}%; width: {{.Width}}%;"></div></div></td></tr>
+{{end}}</table>
+
+{{range .Tests}}
+<details class="test" id="{{.ID}}" data-status="{{.Status}}" {{if ne .Status "Pass"}}{{if ne .Status "Skipped"}}{{if ne .Status "NotRun"}}open{{end}}{{end}}{{end}}>
+<summary><span class="{{.Status}}">{{.Status}}</span> {{.SeqNo}} {{.Name}}
+  {{range .Tags}}<span class="tag">{{.}}</span> {{end}}
+  <small>{{.Method}} {{.URL}} {{if .StatusCode}}&rarr; {{.StatusCode}}{{end}} ({{.DurationMS}} ms{{if gt .Tries 1}}, {{.Tries}} tries{{end}})</small></summary>
+{{if .Description}}<p>{{.Description}}</p>{{end}}
+{{range .Errors}}<pre class="Fail">{{.}}</pre>{{end}}
+
+{{if .Checks}}<h4>Checks</h4>
+<table>
+<tr><th>Status</th><th>Check</th><th>Duration</th><th>Errors</th></tr>
+{{range .Checks}}<tr><td class="{{.Status}}">{{.Status}}</td>
+<td><code>{{.Name}} {{printf "%s" .Check}}</code></td><td>{{.DurationMS}} ms</td>
+<td>{{range .Errors}}{{.}}<br>{{end}}</td></tr>
+{{end}}</table>{{end}}
+
+{{if .Request}}<details><summary>Request</summary><pre>{{.Request}}</pre></details>{{end}}
+{{if .Response}}<details><summary>Response</summary><pre>{{.Response}}</pre></details>{{end}}
+
+{{if .Vars}}<details><summary>Variables</summary>
+<table>
+<tr><th>Variable</th><th>Used</th><th>Extracted</th></tr>
+{{range .Vars}}<tr><td>{{.Name}}</td><td>{{.A}}</td><td>{{.B}}</td></tr>
+{{end}}</table>
+</details>{{end}}
+</details>
+{{end}}
+
+<details><summary>Suite Variables</summary>
+<table>
+<tr><th>Variable</th><th>Initial</th><th>Final</th></tr>
+{{range .Vars}}<tr><td>{{.Name}}</td><td>{{.A}}</td><td>{{.B}}</td></tr>
+{{end}}</table>
+</details>
+</details>
+{{end}}
+
+<script>
+(function() {
+  var search = document.getElementById("search");
+  function update() {
+    var show = {};
+    document.querySelectorAll("input.status").forEach(function(cb) { show[cb.value] = cb.checked; });
+    var q = search.value.toLowerCase();
+    document.querySelectorAll("details.test").forEach(function(t) {
+      var ok = show[t.getAttribute("data-status")] &&
+        (q === "" || t.textContent.toLowerCase().indexOf(q) >= 0);
+      t.classList.toggle("hidden", !ok);
+    });
+  }
+  document.querySelectorAll("input.status").forEach(function(cb) { cb.addEventListener("change", update); });
+  search.addEventListener("input", update);
+  function all(open) {
+    return function() {
+      document.querySelectorAll("details").forEach(function(d) { d.open = open; });
+    };
+  }
+  document.getElementById("expand").addEventListener("click", all(true));
+  document.getElementById("collapse").addEventListener("click", all(false));
+})();
+</script>
+</body>
+</html>
+`))
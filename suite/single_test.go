@@ -0,0 +1,135 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vdobler/ht/errorlist"
+	"github.com/vdobler/ht/ht"
+)
+
+func TestSingleFileReport(t *testing.T) {
+	started := time.Date(2017, 5, 1, 12, 0, 0, 0, time.UTC)
+	test := &ht.Test{
+		Name:      "Search",
+		Tags:      []string{"smoke"},
+		Variables: map[string]string{"QUERY": "shoes"},
+		Request: ht.Request{
+			Method: "GET",
+			URL:    "http://example.org/search?q=shoes",
+			Request: &http.Request{
+				Method: "GET",
+				URL:    &url.URL{Scheme: "http", Host: "example.org", Path: "/search"},
+			},
+		},
+		Response: ht.Response{
+			Response: &http.Response{Proto: "HTTP/1.1", Status: "200 OK", StatusCode: 200},
+			BodyStr:  "<b>Found</b> 3 items",
+		},
+		Result: ht.Result{
+			Status:   ht.Fail,
+			Started:  started.Add(500 * time.Millisecond),
+			Duration: 500 * time.Millisecond,
+			Error:    errorlist.List{fmt.Errorf("not found")},
+			CheckResults: []ht.CheckResult{
+				{Name: "Body", JSON: `{"Contains":"4 items"}`, Status: ht.Fail,
+					Error: errorlist.List{fmt.Errorf("not found")}},
+			},
+		},
+	}
+	test.SetMetadata("SeqNo", "Main-02")
+	login := &ht.Test{
+		Name: "Login",
+		Result: ht.Result{
+			Status:   ht.Pass,
+			Started:  started,
+			Duration: 250 * time.Millisecond,
+		},
+	}
+	login.SetMetadata("SeqNo", "Main-01")
+	s := &Suite{
+		Name:     "Shop",
+		Status:   ht.Fail,
+		Started:  started,
+		Duration: time.Second,
+		Tests:    []*ht.Test{login, test},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := SingleFileReport(buf, "Nightly", []*Suite{s}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		`<h1>Nightly: <span class="Fail">Fail</span></h1>`,
+		`<span class="Pass">Passed 1</span>`,
+		`<span class="Fail">Failed 1</span>`,
+		`<details class="test" id="s1t2" data-status="Fail" open>`,
+		`<span class="tag">smoke</span>`,
+		`left: 0%; width: 25%;`,
+		`left: 50%; width: 50%;`,
+		`&lt;b&gt;Found&lt;/b&gt; 3 items`,
+		`<td>QUERY</td><td>shoes</td>`,
+		`<code>Body {&#34;Contains&#34;:&#34;4 items&#34;}</code>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Missing %s", want)
+		}
+	}
+	if strings.Contains(got, `<link`) || strings.Contains(got, `src="`) {
+		t.Errorf("Report references external resources")
+	}
+}
+
+func TestWaterfallPercent(t *testing.T) {
+	for i, tc := range []struct {
+		d, total time.Duration
+		want     float64
+	}{
+		{0, 0, 0},
+		{time.Second, 0, 0},
+		{time.Second, 4 * time.Second, 25},
+		{time.Millisecond, time.Hour, 0.2},
+		{2 * time.Second, time.Second, 100},
+		{-time.Second, time.Second, 0},
+	} {
+		if got := waterfallPercent(tc.d, tc.total); got != tc.want {
+			t.Errorf("%d. Got %g, want %g", i, got, tc.want)
+		}
+	}
+}
+
+func TestTrimmed(t *testing.T) {
+	test := &ht.Test{
+		Name: "Large",
+		Response: ht.Response{
+			Response: &http.Response{Proto: "HTTP/1.1", Status: "200 OK", StatusCode: 200},
+			BodyStr:  "aäb",
+			BodySize: 4,
+		},
+	}
+	s := &Suite{Name: "Big", Tests: []*ht.Test{test}}
+
+	trimmed := s.Trimmed(2)
+	if got := trimmed.Tests[0].Response.BodyStr; got != "a" {
+		t.Errorf("Got trimmed body %q", got)
+	}
+	if test.Response.BodyStr != "aäb" || trimmed.Name != "Big" {
+		t.Errorf("Original modified or name lost")
+	}
+	if got := wireResponse(trimmed.Tests[0]); !strings.HasSuffix(got, "\na\n[3 more bytes omitted]\n") {
+		t.Errorf("Got response %q", got)
+	}
+	if got := wireResponse(test); strings.Contains(got, "omitted") {
+		t.Errorf("Got response %q", got)
+	}
+}
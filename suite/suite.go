@@ -9,15 +9,20 @@
 package suite
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
+	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/vdobler/ht/cookiejar"
 	"github.com/vdobler/ht/errorlist"
 	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/logging"
 	"github.com/vdobler/ht/mock"
 	"github.com/vdobler/ht/scope"
 )
@@ -29,6 +34,22 @@ type Suite struct {
 	Description string // Description of what's going on here.
 	KeepCookies bool   // KeepCookies in a cookie jar common to all Tests.
 
+	// AllowedHosts restricts outgoing HTTP requests of all Tests to
+	// the given hosts, see ht.Test.AllowedHosts. Nil means unrestricted.
+	AllowedHosts []string
+
+	// LoadCookies and SaveCookies are the files the Jar is loaded from
+	// before and saved to after the execution, see cookiejar.Jar.Load
+	// and Save. Empty means no loading or saving.
+	LoadCookies, SaveCookies string
+
+	// ClientPool provides the HTTP connections of all Tests. Nil uses
+	// the global ht.Transport.
+	ClientPool *ht.ClientPool
+
+	// WireLog is the WireLog of all Tests which do not have their own.
+	WireLog *ht.WireLog
+
 	Status   ht.Status     // Status is the overall status of the whole suite.
 	Error    error         // Error encountered during execution of the suite.
 	Started  time.Time     // Start of the execution.
@@ -45,8 +66,19 @@ type Suite struct {
 		Printf(format string, a ...interface{})
 	}
 
+	// Logger receives the log messages of the suite, its tests and mocks
+	// as structured records. If Logger is nil the messages go to Log.
+	Logger logging.Logger
+
+	// Observers are notified about the progress of the execution.
+	Observers []Observer
+
 	globals          scope.Variables
+	readOnly         map[string]bool
+	checkpoint       *Checkpoint
+	setupErr         error // violated variable declarations or unloadable cookies
 	tests            []*RawTest
+	setupTests       int
 	noneTeardownTest int
 }
 
@@ -78,22 +110,57 @@ func NewFromRaw(rs *RawSuite, global map[string]string, jar *cookiejar.Jar, logg
 		FinalVariables:   make(map[string]string),
 		Jar:              jar,
 		Log:              logger,
+		Logger:           rs.logger,
 		Verbosity:        rs.Verbosity,
+		readOnly:         make(map[string]bool, len(rs.ReadOnly)),
 		tests:            rs.tests,
+		setupTests:       len(rs.Setup),
 		noneTeardownTest: len(rs.Setup) + len(rs.Main),
 	}
 
-	suite.globals = scope.New(global, rs.Variables, true)
+	suite.globals = scope.New(rs.globals(global), rs.Variables, true)
 	suite.globals["SUITE_DIR"] = rs.File.Dirname()
 	suite.globals["SUITE_NAME"] = rs.File.Basename()
+	suite.setupErr = rs.checkDeclarations(suite.globals)
+	if err := rs.freezeNow(suite.globals); err != nil && suite.setupErr == nil {
+		suite.setupErr = err
+	}
 	replacer := suite.globals.Replacer()
 
 	suite.Name = replacer.Replace(rs.Name)
 	suite.Description = replacer.Replace(rs.Description)
+	if rs.AllowedHosts != nil {
+		suite.AllowedHosts = make([]string, len(rs.AllowedHosts))
+		for i, host := range rs.AllowedHosts {
+			suite.AllowedHosts[i] = replacer.Replace(host)
+		}
+	}
+
+	if rs.ClientPool != nil {
+		suite.ClientPool = rs.ClientPool.Clone()
+	}
+
+	if rs.WireLog != nil {
+		wl := *rs.WireLog
+		wl.Dir = relativeFile(wl.Dir, rs.File.Dirname(), replacer)
+		suite.WireLog = &wl
+	}
+
+	if jar != nil {
+		jar.SetPolicy(cookiePolicy(rs.CookiePolicy, replacer))
+		suite.LoadCookies = relativeFile(rs.LoadCookies, rs.File.Dirname(), replacer)
+		suite.SaveCookies = relativeFile(rs.SaveCookies, rs.File.Dirname(), replacer)
+		if err := suite.loadCookies(); err != nil && suite.setupErr == nil {
+			suite.setupErr = err
+		}
+	}
 
 	for n, v := range suite.globals {
 		suite.Variables[n] = v
 	}
+	for _, name := range rs.ReadOnly {
+		suite.readOnly[name] = true
+	}
 
 	return suite
 }
@@ -108,51 +175,87 @@ var (
 	ErrAbortExecution = errors.New("Abort Execution")
 )
 
+// An Observer is notified about the progress of a Suite's execution.
+// This allows to stream progress to a dashboard or to alert on failing
+// tests without wrapping the Executor.
+//
+// OnTestStart and OnTestDone are called by Iterate right before the test
+// is handed to the Executor and once the test's final status is known.
+// The test metadata "SeqNo" (e.g. "Setup-02") set by RawSuite.Execute is
+// available in OnTestDone. OnSuiteDone is called by RawSuite.Execute once
+// the overall status of the suite has been determined; Iterate itself
+// does not call OnSuiteDone.
+//
+// Observers are called synchronously, so they should not block.
+type Observer interface {
+	OnTestStart(suite *Suite, test *ht.Test)
+	OnTestDone(suite *Suite, test *ht.Test)
+	OnSuiteDone(suite *Suite)
+}
+
 // Iterate the suite through the given executor.
 func (suite *Suite) Iterate(executor Executor) {
+	suite.IterateContext(context.Background(), executor)
+}
+
+// IterateContext works like Iterate but the callbacks of the mocks of the
+// tests are canceled once ctx is done. Canceling the tests themselves is
+// up to the executor.
+func (suite *Suite) IterateContext(ctx context.Context, executor Executor) {
 	now := time.Now()
 	now = now.Add(-time.Duration(now.Nanosecond()))
 	suite.Started = now
 
+	if suite.setupErr != nil {
+		// Fail fast instead of executing tests with unusable variables.
+		suite.Status = ht.Bogus
+		suite.Error = suite.setupErr
+		return
+	}
+
 	overall := ht.NotRun
 	errors := errorlist.List{}
 
-	for _, rt := range suite.tests {
+	suite.checkpoint.restoreCookies(suite.Jar)
+
+	for idx, rt := range suite.tests {
 		// suite.Log.Printf("Executing Test %q\n", rt.File.Name)
-		callScope := scope.New(suite.globals, rt.contextVars, true)
-		testScope := scope.New(callScope, rt.Variables, false)
-		testScope["TEST_DIR"] = rt.File.Dirname()
-		testScope["TEST_NAME"] = rt.File.Basename()
-		test, err := rt.ToTest(testScope)
-		test.SetMetadata("Filename", rt.File.Name)
-		if err != nil {
-			test.Result.Status = ht.Bogus
-			test.Result.Error = err
-		}
-		test.Jar = suite.Jar
-		test.Log = suite.Log
-
-		// Mocks requested for this test: We expect each mock to be
-		// called exactly once (and this call should pass).
-		mocks := make([]*mock.Mock, 0, len(rt.mocks))
-		for _, m := range rt.mocks {
-			mockScope := scope.New(testScope, rt.Variables, false)
-			mockScope["MOCK_DIR"] = m.Dirname()
-			mockScope["MOCK_NAME"] = m.Basename()
-			mk, err := m.ToMock(mockScope, true)
-			if err != nil {
-				test.Result.Status = ht.Bogus
-				test.Result.Error = err
-				break
+		test, testScope, err := suite.newTest(rt)
+
+		// Main tests which passed in a previous, interrupted run are not
+		// executed again but their extracted variables are reused. Setup
+		// and Teardown tests are always executed.
+		isMain := idx >= suite.setupTests && idx < suite.noneTeardownTest
+		entry, resumed := suite.checkpoint.passed(idx, rt.File.Name)
+		if resumed && isMain && err == nil {
+			test.Result.Status = ht.Skipped
+			test.SetMetadata("Resumed", "passed in previous run")
+			for varname, value := range entry.Extracted {
+				suite.globals[varname] = value
 			}
-			mocks = append(mocks, mk)
+		} else {
+			resumed = false
 		}
 
-		ctrl, merr := mock.Provide(mocks, suite.Log)
+		var mocks []*mock.Mock
+		if !resumed {
+			mocks = suite.newMocks(rt, test, testScope)
+		}
+		var mockLog mock.Log = suite.Log
+		if suite.Logger != nil {
+			mockLog = logging.Printer(suite.Logger, logging.Info,
+				logging.F(logging.SuiteKey, suite.Name), logging.F(logging.TestKey, test.Name))
+		}
+		ctrl, merr := mock.ProvideContext(ctx, mocks, mockLog)
 		if merr != nil {
 			test.Result.Status = ht.Bogus
 			test.Result.Error = merr
 		}
+		test.Journal = ctrl.Journal()
+
+		for _, o := range suite.Observers {
+			o.OnTestStart(suite, test)
+		}
 
 		// Execute the test (if not bogus).
 		exstat := executor(test)
@@ -163,6 +266,12 @@ func (suite *Suite) Iterate(executor Executor) {
 		if test.Result.Status == ht.Pass {
 			suite.updateVariables(test)
 		}
+		if !resumed {
+			err := suite.checkpoint.record(idx, rt.File.Name, test, suite.Jar)
+			if err != nil {
+				suite.logf(logging.Error, "Cannot record checkpoint: %s", err)
+			}
+		}
 
 		suite.Tests = append(suite.Tests, test)
 		if test.Result.Status > overall {
@@ -171,6 +280,9 @@ func (suite *Suite) Iterate(executor Executor) {
 		if err := test.Result.Error; err != nil {
 			errors = append(errors, err)
 		}
+		for _, o := range suite.Observers {
+			o.OnTestDone(suite, test)
+		}
 
 		if exstat == ErrAbortExecution {
 			break
@@ -191,6 +303,161 @@ func (suite *Suite) Iterate(executor Executor) {
 	}
 }
 
+// logf logs the message to the Logger of suite or, if nil, to its Log.
+func (suite *Suite) logf(level logging.Level, format string, a ...interface{}) {
+	if suite.Logger != nil {
+		suite.Logger.Log(level, fmt.Sprintf(format, a...),
+			logging.F(logging.SuiteKey, suite.Name))
+		return
+	}
+	if suite.Log != nil {
+		suite.Log.Printf(format+"\n", a...)
+	}
+}
+
+// graceContext returns a context which is done grace after ctx is done
+// (or once cancel is called).
+func graceContext(ctx context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	gctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-ctx.Done():
+			timer := time.NewTimer(grace)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+				cancel()
+			case <-gctx.Done():
+			}
+		case <-gctx.Done():
+		}
+	}()
+	return gctx, cancel
+}
+
+// loadCookies seeds the Jar from the LoadCookies file if it exists.
+func (suite *Suite) loadCookies() error {
+	if suite.LoadCookies == "" {
+		return nil
+	}
+	err := suite.Jar.Load(suite.LoadCookies)
+	if os.IsNotExist(err) {
+		suite.logf(logging.Info, "No cookies to load from %s", suite.LoadCookies)
+		return nil
+	} else if err != nil {
+		return errorlist.New(errorlist.Bogus, "cookies.load", "LoadCookies",
+			fmt.Errorf("cannot load cookies: %s", err))
+	}
+	return nil
+}
+
+// saveCookies saves the Jar to the SaveCookies file.
+func (suite *Suite) saveCookies() error {
+	if suite.SaveCookies == "" {
+		return nil
+	}
+	if err := suite.Jar.Save(suite.SaveCookies); err != nil {
+		return fmt.Errorf("cannot save cookies: %s", err)
+	}
+	return nil
+}
+
+// cookiePolicy returns p with variables replaced in all domains.
+func cookiePolicy(p cookiejar.Policy, replacer *scope.Replacer) cookiejar.Policy {
+	replace := func(domains []string) []string {
+		if domains == nil {
+			return nil
+		}
+		r := make([]string, len(domains))
+		for i, d := range domains {
+			r[i] = replacer.Replace(d)
+		}
+		return r
+	}
+	return cookiejar.Policy{
+		Allow:      replace(p.Allow),
+		Reject:     replace(p.Reject),
+		FirstParty: replace(p.FirstParty),
+	}
+}
+
+// relativeFile returns the file (or directory) name given relative to dir
+// with variables replaced.
+func relativeFile(name, dir string, replacer *scope.Replacer) string {
+	if name == "" {
+		return ""
+	}
+	name = replacer.Replace(name)
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(dir, name)
+	}
+	return name
+}
+
+// newTest produces the test from rt in the current scope of suite. Tests
+// which cannot be produced are marked as Bogus. The error is returned
+// together with the scope of the test.
+func (suite *Suite) newTest(rt *RawTest) (*ht.Test, scope.Variables, error) {
+	callScope := scope.New(suite.globals, rt.contextVars, true)
+	testScope := rt.testScope(callScope)
+	test, err := rt.ToTest(testScope)
+	test.SetMetadata("Filename", rt.File.Name)
+	if err == nil {
+		err = checkExtractions(test, rt.exports, suite.readOnly)
+	}
+	if err != nil {
+		test.Result.Status = ht.Bogus
+		test.Result.Error = err
+	}
+	test.Jar = suite.Jar
+	test.ClientPool = suite.ClientPool
+	test.Log = suite.Log
+	test.Logger = logging.With(suite.Logger, logging.F(logging.SuiteKey, suite.Name))
+	test.AllowedHosts = suite.AllowedHosts
+	if test.WireLog == nil {
+		test.WireLog = suite.WireLog
+	}
+	test.Renew = func() (*ht.Test, error) {
+		// Keep COUNTER, only RANDOM and functions like NOW are renewed.
+		outer := make(scope.Variables, len(suite.globals)+2)
+		for n, v := range suite.globals {
+			outer[n] = v
+		}
+		outer["COUNTER"] = callScope["COUNTER"]
+		outer["RANDOM"] = scope.RandomNumber()
+		return rt.ToTest(rt.testScope(scope.New(outer, rt.contextVars, false)))
+	}
+	return test, testScope, err
+}
+
+// testScope returns the scope of rt called in callScope.
+func (rt *RawTest) testScope(callScope scope.Variables) scope.Variables {
+	testScope := scope.New(callScope, rt.Variables, false)
+	testScope["TEST_DIR"] = rt.File.Dirname()
+	testScope["TEST_NAME"] = rt.File.Basename()
+	return testScope
+}
+
+// newMocks produces the mocks requested for rt: We expect each mock to be
+// called exactly once (and this call should pass). If a mock cannot be
+// produced test is marked as Bogus.
+func (suite *Suite) newMocks(rt *RawTest, test *ht.Test, testScope scope.Variables) []*mock.Mock {
+	mocks := make([]*mock.Mock, 0, len(rt.mocks))
+	for _, m := range rt.mocks {
+		mockScope := scope.New(testScope, rt.Variables, false)
+		mockScope["MOCK_DIR"] = m.Dirname()
+		mockScope["MOCK_NAME"] = m.Basename()
+		mk, err := m.ToMock(mockScope, true)
+		if err != nil {
+			test.Result.Status = ht.Bogus
+			test.Result.Error = err
+			break
+		}
+		mocks = append(mocks, mk)
+	}
+	return mocks
+}
+
 // The following cases can happen
 //   - Mock executed and okay  --> Pass,  recorde in mockResults
 //   - Mock executed and fail  --> Fail,  recorde in mockResults
@@ -235,6 +502,40 @@ func analyseMocks(test *ht.Test, ctrl mock.Control) {
 	test.SetMetadata("Subsuite", subsuite)
 }
 
+// checkExtractions makes sure that test extracts only variables listed in
+// exports (if exports is non-nil) and none of the read-only variables.
+func checkExtractions(test *ht.Test, exports []string, readOnly map[string]bool) error {
+	varnames := make([]string, 0, len(test.DataExtraction))
+	for varname := range test.DataExtraction {
+		varnames = append(varnames, varname)
+	}
+	sort.Strings(varnames)
+
+	el := errorlist.List{}
+	for _, varname := range varnames {
+		if readOnly[varname] {
+			el = el.Append(errorlist.New(errorlist.Validation, "variable.read-only",
+				"DataExtraction."+varname, fmt.Errorf("variable %q is read-only and must not be extracted", varname)))
+			continue
+		}
+		if exports == nil {
+			continue
+		}
+		exported := false
+		for _, e := range exports {
+			if e == varname {
+				exported = true
+				break
+			}
+		}
+		if !exported {
+			el = el.Append(errorlist.New(errorlist.Validation, "variable.not-exported",
+				"DataExtraction."+varname, fmt.Errorf("variable %q is extracted but not listed in Exports", varname)))
+		}
+	}
+	return el.AsError()
+}
+
 func (suite *Suite) updateVariables(test *ht.Test) {
 	if test.Result.Status != ht.Pass {
 		return
@@ -244,14 +545,14 @@ func (suite *Suite) updateVariables(test *ht.Test) {
 		if suite.Verbosity >= 2 {
 			if old, ok := suite.globals[varname]; ok {
 				if value != old {
-					suite.Log.Printf("Updating variable %q to %q\n",
+					suite.logf(logging.Debug, "Updating variable %q to %q",
 						varname, value)
 				} else {
-					suite.Log.Printf("Keeping  variable %q as %q\n",
+					suite.logf(logging.Debug, "Keeping  variable %q as %q",
 						varname, value)
 				}
 			} else {
-				suite.Log.Printf("Setting  variable %q to %q\n",
+				suite.logf(logging.Debug, "Setting  variable %q to %q",
 					varname, value)
 			}
 		}
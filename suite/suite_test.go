@@ -5,15 +5,24 @@
 package suite
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/logging"
 	"github.com/vdobler/ht/scope"
+	"github.com/vdobler/ht/tracing"
 )
 
 func logger() *log.Logger {
@@ -257,3 +266,788 @@ func matchVars(got map[string]string, want string) string {
 
 	return ""
 }
+
+// Hermetic mode turns requests to not allowed hosts into errors.
+func TestAllowedHosts(t *testing.T) {
+	txt := `
+# hermetic.suite
+{
+    Name: Testsuite for hermetic mode
+    Variables: {
+        HOST: "www.example.org"
+    }
+    AllowedHosts: [ "localhost", "{{LOCAL}}" ]
+    Main: [
+        { File: "test.ht" }
+    ]
+}
+
+# test.ht
+{
+    Name: Test of hermetic mode
+    Request: { URL: "http://{{HOST}}/path" }
+}`
+
+	globals := map[string]string{"LOCAL": "127.0.0.1"}
+	rs, err := parseRawSuite("hermetic.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	s := rs.Execute(globals, nil, logger())
+
+	if got := s.AllowedHosts; len(got) != 2 || got[1] != "127.0.0.1" {
+		t.Errorf("Bad AllowedHosts, got %v", got)
+	}
+	if s.Status != ht.Error || s.Tests[0].Result.Status != ht.Error {
+		s.PrintReport(os.Stdout)
+		t.Fatalf("Got status %s, want Error", s.Status)
+	}
+	if _, ok := s.Tests[0].Result.Error.(ht.ErrHostNotAllowed); !ok {
+		t.Errorf("Got error %T %v", s.Tests[0].Result.Error, s.Tests[0].Result.Error)
+	}
+}
+
+// Tests may extract only variables from their Exports list and never
+// any of the suite's read-only variables.
+func TestExportsAndReadOnly(t *testing.T) {
+	txt := `
+# lifecycle.suite
+{
+    Name: Testsuite for variable lifecycle
+    Variables: {
+        HOST: "localhost"
+    }
+    ReadOnly: [ "HOST" ]
+    Main: [
+        { File: "test.ht", Variables: { VAR: "A" }, Exports: [ "A" ] }
+        { File: "test.ht", Variables: { VAR: "B" }, Exports: [ "A" ] }
+        { File: "test.ht", Variables: { VAR: "C" } }
+        { File: "test.ht", Variables: { VAR: "HOST" } }
+    ]
+}
+
+# test.ht
+{
+    Name: Test of variable lifecycle
+    Request: { URL: "file:///etc/passwd" }
+    DataExtraction: {
+        "{{VAR}}": {Extractor: "SetVariable", To: "extracted" }
+    }
+}`
+
+	rs, err := parseRawSuite("lifecycle.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := rs.Validate(nil); err == nil {
+		t.Errorf("Missing validation error")
+	}
+
+	s := rs.Execute(nil, nil, logger())
+	want := []ht.Status{ht.Pass, ht.Bogus, ht.Pass, ht.Bogus}
+	for i, w := range want {
+		if got := s.Tests[i].Result.Status; got != w {
+			t.Errorf("Test %d: got status %s, want %s (%v)",
+				i+1, got, w, s.Tests[i].Result.Error)
+		}
+	}
+	if s.FinalVariables["A"] != "extracted" ||
+		s.FinalVariables["B"] != "" ||
+		s.FinalVariables["C"] != "extracted" ||
+		s.FinalVariables["HOST"] != "localhost" {
+		t.Errorf("Bad final variables. Got %v", s.FinalVariables)
+	}
+}
+
+// Tests which passed in an interrupted run are not executed again when
+// resuming from a checkpoint but their extracted variables are reused.
+func TestCheckpointResume(t *testing.T) {
+	txt := `
+# resume.suite
+{
+    Name: Testsuite for checkpoints
+    Setup: [ { File: "plain.ht" } ]
+    Main: [
+        { File: "extract.ht" }
+        { File: "secret.ht" }
+        { File: "target.ht" }
+    ]
+    Teardown: [ { File: "plain.ht" } ]
+}
+
+# plain.ht
+{
+    Name: Plain test
+    Request: { URL: "file:///etc/passwd" }
+}
+
+# extract.ht
+{
+    Name: Extracting test
+    Request: { URL: "file:///etc/passwd" }
+    DataExtraction: {
+        X: {Extractor: "SetVariable", To: "extracted" }
+    }
+}
+
+# secret.ht
+{
+    Name: Extracting a secret
+    Request: { URL: "file:///etc/passwd" }
+    DataExtraction: {
+        S: {Extractor: "SetVariable", To: "checkpoint-secret-42" }
+    }
+}
+
+# target.ht
+{
+    Name: Test of {{TARGET}}
+    Request: { URL: "file://{{TARGET}}" }
+    Checks: [ {Check: "StatusCode", Expect: 200} ]
+}`
+
+	scope.MarkSecret("checkpoint-secret-42")
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "checkpoint.jsonl")
+	target := filepath.Join(dir, "target.txt")
+
+	rs, err := parseRawSuite("resume.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// First, "interrupted" run: Target test fails as target is missing.
+	cp, err := OpenCheckpoint(filename, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	s := rs.ExecuteWith(map[string]string{"TARGET": target},
+		ExecOptions{Logger: logger(), Checkpoint: cp})
+	cp.Close()
+	if s.Tests[1].Result.Status != ht.Pass || s.Tests[3].Result.Status == ht.Pass {
+		t.Fatalf("Unexpected status %s and %s",
+			s.Tests[1].Result.Status, s.Tests[3].Result.Status)
+	}
+	recorded, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if strings.Contains(string(recorded), "checkpoint-secret-42") {
+		t.Errorf("Secret recorded in checkpoint:\n%s", recorded)
+	}
+
+	// Resumed run: Only the extracting test is skipped; Setup and Teardown
+	// and the test which extracted a secret are executed again.
+	if err := ioutil.WriteFile(target, []byte("Hello"), 0666); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	cp, err = OpenCheckpoint(filename, true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	s = rs.ExecuteWith(map[string]string{"TARGET": target},
+		ExecOptions{Logger: logger(), Checkpoint: cp})
+	cp.Close()
+	for i, want := range []ht.Status{ht.Pass, ht.Skipped, ht.Pass, ht.Pass, ht.Pass} {
+		if got := s.Tests[i].Result.Status; got != want {
+			t.Errorf("Test %d: got status %s, want %s (%v)",
+				i, got, want, s.Tests[i].Result.Error)
+		}
+	}
+	if s.Status != ht.Pass {
+		t.Errorf("Got suite status %s, want Pass", s.Status)
+	}
+	if got := s.FinalVariables["X"]; got != "extracted" {
+		t.Errorf("Got X=%q, want \"extracted\"", got)
+	}
+	if got := s.FinalVariables["S"]; got != "checkpoint-secret-42" {
+		t.Errorf("Got S=%q", got)
+	}
+}
+
+// The cookie jar of a suite can be seeded from and saved to a file.
+func TestLoadSaveCookies(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("seed"); err != nil || c.Value != "1" {
+			http.Error(w, "no seed", http.StatusForbidden)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s2", Path: "/"})
+	}))
+	defer ts.Close()
+
+	txt := `
+# cookies.suite
+{
+    Name: Testsuite for cookie files
+    KeepCookies: true
+    LoadCookies: "{{DIR}}/in.txt"
+    SaveCookies: "{{DIR}}/out.json"
+    Main: [
+        { Test: { Request: { URL: "{{URL}}/login" }, Checks: [ {Check: "StatusCode", Expect: 200} ] } }
+    ]
+}`
+
+	dir, err := ioutil.TempDir("", "cookies")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	seed := "127.0.0.1\tFALSE\t/\tFALSE\t0\tseed\t1\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "in.txt"), []byte(seed), 0666); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	rs, err := parseRawSuite("cookies.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	s := rs.Execute(map[string]string{"DIR": dir, "URL": ts.URL}, nil, logger())
+	if s.Status != ht.Pass {
+		t.Fatalf("Got status %s: %v", s.Status, s.Error)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "out.json"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	for _, want := range []string{`"Name": "seed"`, `"Name": "session"`, `"Value": "s2"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Missing %s in\n%s", want, data)
+		}
+	}
+
+	// A broken cookie file renders the suite bogus.
+	if err := ioutil.WriteFile(filepath.Join(dir, "in.txt"), []byte("garbage\n"), 0666); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	s = rs.Execute(map[string]string{"DIR": dir, "URL": ts.URL}, nil, logger())
+	if s.Status != ht.Bogus || s.Error == nil ||
+		!strings.Contains(s.Error.Error(), "cannot load cookies") {
+		t.Errorf("Got status %s: %v", s.Status, s.Error)
+	}
+}
+
+type recordingObserver struct {
+	events []string
+}
+
+func (o *recordingObserver) OnTestStart(s *Suite, test *ht.Test) {
+	o.events = append(o.events, "start "+test.Name)
+}
+
+func (o *recordingObserver) OnTestDone(s *Suite, test *ht.Test) {
+	o.events = append(o.events, fmt.Sprintf("done %s %s %s",
+		test.Name, test.GetStringMetadata("SeqNo"), test.Result.Status))
+}
+
+func (o *recordingObserver) OnSuiteDone(s *Suite) {
+	o.events = append(o.events, "suite "+s.Status.String())
+}
+
+// Observers see the progress of the suite execution.
+func TestObserver(t *testing.T) {
+	txt := `
+# observed.suite
+{
+    Name: Testsuite for observers
+    Setup: [
+        { File: "test.ht", Variables: { NAME: "A", FILE: "/nonexisting" } }
+    ]
+    Main: [
+        { File: "test.ht", Variables: { NAME: "B" } }
+    ]
+    Teardown: [
+        { File: "test.ht", Variables: { NAME: "C" } }
+    ]
+}
+
+# test.ht
+{
+    Name: "{{NAME}}"
+    Request: { URL: "file://{{FILE}}" }
+    Checks: [ {Check: "StatusCode", Expect: 200} ]
+    Variables: { FILE: "{{CWD}}/suite.go" }
+}`
+
+	rs, err := parseRawSuite("observed.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	o := &recordingObserver{}
+	rs.ExecuteWith(map[string]string{"CWD": cwd},
+		ExecOptions{Logger: logger(), Observers: []Observer{o}})
+
+	got := strings.Join(o.events, "\n")
+	want := `start A
+done A Setup-01 Fail
+start B
+done B Main-01 Skipped
+start C
+done C Teardown-01 Pass
+suite Fail`
+	if got != want {
+		t.Errorf("Got events\n%s\nwant\n%s", got, want)
+	}
+}
+
+// The TracingObserver traces suite, tests, requests and checks and
+// propagates the traceparent header.
+func TestTracingObserver(t *testing.T) {
+	var traceparent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer ts.Close()
+
+	txt := `
+# traced.suite
+{
+    Name: Traced
+    Main: [ { File: "test.ht" } ]
+}
+
+# test.ht
+{
+    Name: "Get"
+    Request: { URL: "{{URL}}" }
+    Checks: [ {Check: "StatusCode", Expect: 200} ]
+}`
+	rs, err := parseRawSuite("traced.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	tracer := tracing.NewTracer("test")
+	s := rs.ExecuteWith(map[string]string{"URL": ts.URL}, ExecOptions{
+		Logger:    logger(),
+		Observers: []Observer{NewTracingObserver(tracer)},
+	})
+	if s.Status != ht.Pass {
+		t.Fatalf("Got status %s: %v", s.Status, s.Error)
+	}
+
+	spans := map[string]*tracing.Span{}
+	for _, span := range tracer.Spans() {
+		spans[span.Name] = span
+	}
+	suite, test, req, check := spans["Suite Traced"], spans["Test Get"],
+		spans["HTTP GET"], spans["Check StatusCode"]
+	if suite == nil || test == nil || req == nil || check == nil || len(spans) != 4 {
+		t.Fatalf("Got spans %v", spans)
+	}
+	if test.ParentID != suite.SpanID || req.ParentID != test.SpanID ||
+		check.ParentID != test.SpanID {
+		t.Errorf("Wrong span hierarchy")
+	}
+	tid, sid, err := tracing.ParseTraceparent(traceparent)
+	if err != nil || tid != suite.TraceID || sid != req.SpanID {
+		t.Errorf("Got traceparent %q (%v)", traceparent, err)
+	}
+	if got := s.Tests[0].GetStringMetadata("TraceID"); got != suite.TraceID.String() {
+		t.Errorf("Got TraceID metadata %q", got)
+	}
+}
+
+func TestCookiePolicy(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+		http.SetCookie(w, &http.Cookie{Name: "tracking", Value: "t1", Path: "/"})
+	}))
+	defer ts.Close()
+
+	txt := `
+# policy.suite
+{
+    Name: Testsuite for cookie policies
+    KeepCookies: true
+    CookiePolicy: { Reject: [ "{{REJECT}}" ] }
+    Main: [
+        { Test: {
+            Request: { URL: "{{URL}}/" }
+            Checks: [
+                {Check: "CookieJar", Name: "session", Value: {Equals: "s1"} }
+            ]
+        } }
+    ]
+}`
+
+	rs, err := parseRawSuite("policy.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	for _, tc := range []struct {
+		reject string
+		want   ht.Status
+	}{
+		{"example.org", ht.Pass},
+		{"127.0.0.1", ht.Fail},
+	} {
+		s := rs.Execute(map[string]string{"URL": ts.URL, "REJECT": tc.reject},
+			nil, logger())
+		if s.Status != tc.want {
+			t.Errorf("Reject %s: got status %s: %v", tc.reject, s.Status, s.Error)
+		}
+	}
+}
+
+func TestClientPool(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Hello"))
+	}))
+	defer ts.Close()
+
+	txt := `
+# pool.suite
+{
+    Name: Testsuite for client pools
+    ClientPool: { DisableKeepAlives: NOKEEPALIVE }
+    Main: [
+        { Test: { Request: { URL: "{{URL}}/a" }, Checks: [ {Check: "StatusCode", Expect: 200} ] } }
+        { Test: { Request: { URL: "{{URL}}/b" }, Checks: [ {Check: "StatusCode", Expect: 200} ] } }
+        { Test: { Request: { URL: "{{URL}}/c" }, Checks: [ {Check: "StatusCode", Expect: 200} ] } }
+    ]
+}`
+
+	for _, tc := range []struct {
+		noKeepAlive string
+		want        ht.ConnStats
+	}{
+		{"false", ht.ConnStats{Requests: 3, New: 1, Reused: 2}},
+		{"true", ht.ConnStats{Requests: 3, New: 3, Reused: 0}},
+	} {
+		rs, err := parseRawSuite("pool.suite",
+			strings.Replace(txt, "NOKEEPALIVE", tc.noKeepAlive, 1))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		s := rs.Execute(map[string]string{"URL": ts.URL}, nil, logger())
+		if s.Status != ht.Pass {
+			t.Fatalf("Got status %s: %v", s.Status, s.Error)
+		}
+		result := s.Result()
+		if result.Connections == nil || *result.Connections != tc.want {
+			t.Errorf("NoKeepAlive=%s: got %+v, want %+v",
+				tc.noKeepAlive, result.Connections, tc.want)
+		}
+		if c := result.Tests[0].Connections; c == nil || c.New != 1 {
+			t.Errorf("NoKeepAlive=%s: got first test connections %+v",
+				tc.noKeepAlive, c)
+		}
+	}
+}
+
+func TestSuiteTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-time.After(5 * time.Second):
+			case <-r.Context().Done():
+			}
+		}
+	}))
+	defer ts.Close()
+
+	txt := `
+# timeout.suite
+{
+    Name: Testsuite with a timeout
+    Timeout: "200ms"
+    Setup: [
+        { Test: { Request: { URL: "{{URL}}/fast" }, Checks: [ {Check: "StatusCode", Expect: 200} ] } }
+    ]
+    Main: [
+        { Test: { Request: { URL: "{{URL}}/slow" }, Checks: [ {Check: "StatusCode", Expect: 200} ] } }
+        { Test: { Request: { URL: "{{URL}}/fast" }, Checks: [ {Check: "StatusCode", Expect: 200} ] } }
+    ]
+    Teardown: [
+        { Test: { Request: { URL: "{{URL}}/fast" }, Checks: [ {Check: "StatusCode", Expect: 200} ] } }
+    ]
+}`
+
+	rs, err := parseRawSuite("timeout.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	start := time.Now()
+	s := rs.Execute(map[string]string{"URL": ts.URL}, nil, logger())
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("Took too long: %s", d)
+	}
+	if s.Status != ht.Error {
+		t.Errorf("Got suite status %s: %v", s.Status, s.Error)
+	}
+	want := []ht.Status{ht.Pass, ht.Error, ht.Skipped, ht.Pass}
+	if len(s.Tests) != len(want) {
+		t.Fatalf("Got %d tests", len(s.Tests))
+	}
+	for i, test := range s.Tests {
+		if test.Result.Status != want[i] {
+			t.Errorf("Test %d: got %s, want %s", i, test.Result.Status, want[i])
+		}
+	}
+}
+
+func TestGraceContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	gctx, stop := graceContext(ctx, 50*time.Millisecond)
+	defer stop()
+	cancel()
+	select {
+	case <-gctx.Done():
+		t.Fatalf("Grace context done immediately")
+	case <-time.After(20 * time.Millisecond):
+	}
+	select {
+	case <-gctx.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("Grace context not done after grace period")
+	}
+}
+
+func TestStructuredLogger(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Hello"))
+	}))
+	defer ts.Close()
+
+	txt := `
+# logging.suite
+{
+    Name: Logging Suite
+    Main: [
+        {
+            Test: {
+                Name: "Hello"
+                Request: { URL: "{{URL}}/hello" }
+                Checks: [ {Check: "StatusCode", Expect: 200} ]
+            }
+        }
+    ]
+}`
+
+	rs, err := parseRawSuite("logging.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	rs.Verbosity = 1
+	var mu sync.Mutex
+	records := []map[string]interface{}{}
+	rs.SetLogger(logging.LoggerFunc(func(level logging.Level, msg string, fields ...logging.Field) {
+		record := map[string]interface{}{"level": level, "msg": msg}
+		for _, f := range fields {
+			record[f.Key] = f.Value
+		}
+		mu.Lock()
+		records = append(records, record)
+		mu.Unlock()
+	}))
+	s := rs.Execute(map[string]string{"URL": ts.URL}, nil, logger())
+	if s.Status != ht.Pass {
+		t.Fatalf("Got status %s: %v", s.Status, s.Error)
+	}
+
+	found := false
+	for _, r := range records {
+		if r[logging.TestKey] != "Hello" {
+			continue
+		}
+		found = true
+		if r[logging.SuiteKey] != "Logging Suite" ||
+			r[logging.SeqNoKey] != "Main-01" ||
+			r["level"] != logging.Info {
+			t.Errorf("Bad record %v", r)
+		}
+	}
+	if !found {
+		t.Errorf("No record of test Hello in %v", records)
+	}
+}
+
+func TestFreshVariables(t *testing.T) {
+	var mu sync.Mutex
+	ids, counters := []string{}, []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		ids = append(ids, r.FormValue("id"))
+		counters = append(counters, r.FormValue("counter"))
+		if len(ids) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	txt := `
+# fresh.suite
+{
+    Name: Fresh Variables
+    Main: [
+        {
+            Test: {
+                Name: "Poll"
+                Request: {
+                    URL: "{{URL}}/poll"
+                    Params: { id: "{{RANDOM}}", counter: "{{COUNTER}}" }
+                }
+                Checks: [ {Check: "StatusCode", Expect: 200} ]
+                Execution: { Tries: 3, FreshVariables: true }
+            }
+        }
+    ]
+}`
+
+	rs, err := parseRawSuite("fresh.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	s := rs.Execute(map[string]string{"URL": ts.URL}, nil, logger())
+	if s.Status != ht.Pass {
+		t.Fatalf("Got status %s: %v", s.Status, s.Error)
+	}
+	if len(ids) != 3 || ids[0] == ids[1] || ids[1] == ids[2] {
+		t.Errorf("Got ids %v", ids)
+	}
+	if len(counters) != 3 || counters[0] != counters[1] || counters[1] != counters[2] {
+		t.Errorf("Got counters %v", counters)
+	}
+}
+
+func TestFreezeNow(t *testing.T) {
+	var mu sync.Mutex
+	stamps := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		stamps = append(stamps, r.FormValue("now"), r.FormValue("stamp"))
+	}))
+	defer ts.Close()
+
+	txt := `
+# frozen.suite
+{
+    Name: Frozen Now
+    FreezeNow: "{{FREEZE}}"
+    Main: [
+        {File: "first.ht"}
+        {File: "second.ht"}
+    ]
+}
+
+# first.ht
+{
+    Name: "First"
+    Request: {
+        URL: "{{URL}}/first"
+        Params: { now: "{{NOW 2006-01-02T15:04:05.999999999}}" }
+    }
+    DataExtraction: {
+        STAMP: {Extractor: "SetTimestamp", Format: "2006-01-02T15:04:05.999999999"}
+    }
+}
+
+# second.ht
+{
+    Name: "Second"
+    Request: {
+        URL: "{{URL}}/second"
+        Params: { now: "{{NOW 2006-01-02T15:04:05.999999999}}", stamp: "{{STAMP}}" }
+    }
+}`
+
+	for i, tc := range []struct {
+		freeze, want string
+	}{
+		{"start", ""},
+		{"2017-03-24T15:30:00+01:00", "2017-03-24T15:30:00"},
+	} {
+		stamps = stamps[:0]
+		rs, err := parseRawSuite("frozen.suite", txt)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		s := rs.Execute(map[string]string{"URL": ts.URL, "FREEZE": tc.freeze}, nil, logger())
+		if s.Status != ht.Pass {
+			t.Fatalf("%d. Got status %s: %v", i, s.Status, s.Error)
+		}
+		want := tc.want
+		if want == "" {
+			want = stamps[0]
+		}
+		if len(stamps) != 4 || stamps[0] != want || stamps[2] != want || stamps[3] != want {
+			t.Errorf("%d. Got %q, want %q", i, stamps, want)
+		}
+	}
+
+	rs, err := parseRawSuite("frozen.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	s := rs.Execute(map[string]string{"URL": ts.URL, "FREEZE": "tomorrow"}, nil, logger())
+	if s.Status != ht.Bogus || s.Error == nil || !strings.Contains(s.Error.Error(), "FreezeNow") {
+		t.Errorf("Got status %s: %v", s.Status, s.Error)
+	}
+}
+
+func TestSuiteWireLog(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Hello"))
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "suite-wirelog-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	txt := `
+# wire.suite
+{
+    Name: Wire Log
+    WireLog: { Dir: "{{DIR}}/suite", Redact: [ "X-Token" ] }
+    Main: [
+        {File: "plain.ht"}
+        {File: "own.ht"}
+    ]
+}
+
+# plain.ht
+{
+    Name: "Plain"
+    Request: { URL: "{{URL}}/plain", Header: { X-Token: "abc123" } }
+}
+
+# own.ht
+{
+    Name: "Own"
+    Request: { URL: "{{URL}}/own" }
+    WireLog: { Dir: "{{DIR}}/own" }
+}`
+
+	rs, err := parseRawSuite("wire.suite", txt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	s := rs.Execute(map[string]string{"URL": ts.URL, "DIR": dir}, nil, logger())
+	if s.Status != ht.Pass {
+		t.Fatalf("Got status %s: %v", s.Status, s.Error)
+	}
+	plain, err := filepath.Glob(filepath.Join(dir, "suite", "*Plain.wire"))
+	if err != nil || len(plain) != 1 {
+		t.Fatalf("Got wire logs %v, %v", plain, err)
+	}
+	data, _ := ioutil.ReadFile(plain[0])
+	if !strings.Contains(string(data), "X-Token: <redacted>") {
+		t.Errorf("Bad wire log\n%s", data)
+	}
+	if own, _ := filepath.Glob(filepath.Join(dir, "own", "*Own.wire")); len(own) != 1 {
+		t.Errorf("Got wire logs %v", own)
+	}
+}
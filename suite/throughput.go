@@ -34,13 +34,13 @@ package suite
 
 import (
 	"bufio"
+	"context"
 	"encoding/csv"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
-	"math/rand"
 	"os"
 	"sort"
 	"strconv"
@@ -52,6 +52,7 @@ import (
 	"github.com/vdobler/ht/errorlist"
 	"github.com/vdobler/ht/ht"
 	"github.com/vdobler/ht/internal/bender"
+	"github.com/vdobler/ht/scope"
 )
 
 // Scenario describes a single scenario to be run as part of a throughput test.
@@ -63,9 +64,14 @@ type Scenario struct {
 	*RawSuite
 
 	// Percentage of requests in the throughput test taken from this
-	// scenario.
+	// scenario. Ignored if the scenario has a Profile.
 	Percentage int
 
+	// Profile of the request rate of this scenario. If set the scenario
+	// generates requests at the rate given by the profile, independent
+	// of the overall rate of the throughput test.
+	Profile RateProfile
+
 	// MaxThreads limits the number of threads used to generate load from
 	// this scenario. The value 0 indicates unlimited number of threads.
 	MaxThreads int
@@ -74,12 +80,25 @@ type Scenario struct {
 	// is made but no checks are performed on the response.
 	OmitChecks bool
 
+	// ThinkTime is the pause between two consecutive tests of this
+	// scenario.
+	ThinkTime ThinkTime
+
+	// Pacing is the minimal duration of one iteration of the Main tests
+	// of this scenario. A thread finishing an iteration faster waits
+	// before starting the next one.
+	Pacing time.Duration
+
+	// DataPool, if set, provides one exclusive record per iteration of
+	// the Main tests; the fields of the record are available as variables.
+	DataPool *DataPool
+
 	globals map[string]string
 	jar     *cookiejar.Jar
 }
 
 // setup runs the Setup tests of sc.
-func (sc *Scenario) setup(logger *log.Logger) *Suite {
+func (sc *Scenario) setup(ctx context.Context, logger *log.Logger) *Suite {
 	suite := NewFromRaw(sc.RawSuite, sc.globals, sc.jar, logger)
 	// Cap tests to setup-tests.
 	suite.tests = suite.tests[:len(sc.RawSuite.Setup)]
@@ -94,7 +113,7 @@ func (sc *Scenario) setup(logger *log.Logger) *Suite {
 			test.Result.Status = ht.Skipped
 			return nil
 		}
-		test.Run()
+		test.RunContext(ctx)
 		if test.Result.Status > ht.Pass {
 			return ErrAbortExecution
 		}
@@ -108,7 +127,7 @@ func (sc *Scenario) setup(logger *log.Logger) *Suite {
 }
 
 // teardown runs the Teardown tests of sc.
-func (sc *Scenario) teardown(logger *log.Logger) *Suite {
+func (sc *Scenario) teardown(ctx context.Context, logger *log.Logger) *Suite {
 	suite := NewFromRaw(sc.RawSuite, sc.globals, sc.jar, logger)
 	// Cap tests to setup-tests.
 	suite.tests = suite.tests[len(suite.tests)-len(sc.RawSuite.Teardown):]
@@ -123,7 +142,7 @@ func (sc *Scenario) teardown(logger *log.Logger) *Suite {
 			test.Result.Status = ht.Skipped
 			return nil
 		}
-		test.Run()
+		test.RunContext(ctx)
 		return nil
 	}
 	suite.Iterate(executor)
@@ -142,6 +161,8 @@ type pool struct {
 	mu      *sync.Mutex
 	Threads int
 	Misses  int
+	metrics *LiveMetrics
+	ctx     context.Context
 }
 
 // IDSep is the separator string used in constructing IDs for the individual
@@ -165,6 +186,7 @@ func (p *pool) newThread(stop chan bool, logger *log.Logger) {
 		return
 	}
 	p.Threads++
+	p.metrics.setThreads(p.No, p.Scenario.Name, p.Threads)
 	if p.Scenario.Verbosity >= 1 {
 		logger.Printf("Scenario %d %q: Starting new thread %d\n",
 			p.No+1, p.Scenario.Name, p.Threads)
@@ -191,6 +213,17 @@ func (p *pool) newThread(stop chan bool, logger *log.Logger) {
 				break
 			}
 			thglobals["REPETITION"] = strconv.Itoa(repetition)
+			handle := -1
+			if dp := p.Scenario.DataPool; dp != nil {
+				record, h, ok := dp.Checkout(stop)
+				if !ok {
+					break
+				}
+				for n, v := range record {
+					thglobals[n] = v
+				}
+				handle = h
+			}
 			executed := make(chan bool)
 
 			t := 0
@@ -212,11 +245,15 @@ func (p *pool) newThread(stop chan bool, logger *log.Logger) {
 					test.Result.Status = ht.Skipped
 					return nil
 				}
+				if t > 1 && !sleep(p.Scenario.ThinkTime.Duration(), stop) {
+					done = true
+					return ErrAbortExecution
+				}
 				select {
 				case <-stop:
 					done = true
 					return ErrAbortExecution
-				case p.Chan <- bender.Test{Test: test, Done: executed}:
+				case p.Chan <- bender.Test{Test: test, Done: executed, Context: p.ctx}:
 					<-executed
 				}
 
@@ -226,7 +263,14 @@ func (p *pool) newThread(stop chan bool, logger *log.Logger) {
 
 			nSetup, nMain := len(p.Scenario.RawSuite.Setup), len(p.Scenario.RawSuite.Main)
 			suite.tests = suite.tests[nSetup : nSetup+nMain]
+			iterationStart := time.Now()
 			suite.Iterate(executor)
+			if handle != -1 {
+				p.Scenario.DataPool.Return(handle)
+			}
+			if !done && p.Scenario.Pacing > 0 {
+				done = !sleep(p.Scenario.Pacing-time.Since(iterationStart), stop)
+			}
 			if p.Scenario.Verbosity >= 2 {
 				logger.Printf("Scenario %d %q: Finished repetition %d of thread %d: %s\n",
 					p.No+1, p.Scenario.Name, repetition, thread, suite.Status)
@@ -242,20 +286,30 @@ func (p *pool) newThread(stop chan bool, logger *log.Logger) {
 	}(p.Threads)
 }
 
+// sleep for d unless stop is closed earlier in which case false is returned.
+func sleep(d time.Duration, stop chan bool) bool {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-stop:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
 // makeRequests is responsible for generating a stream of request and providing
 // these requests on the requests channel until the stop channel is closed.
-// The request are drawn randoemly from the given scenarios (while each suite
-// the scenario consists of executes linearely on each thread).
+// The request are drawn randoemly from the given scenarios according to
+// the schedule (while each suite the scenario consists of executes
+// linearely on each thread).
 // The thread pool of the scenarios is returned for cleanup purpose.
-func makeRequest(scenarios []Scenario, rate float64, requests chan bender.Test, stop chan bool, logger *log.Logger) ([]*pool, error) {
-	// Choosing a scenario to contribute to the total set of request is done
-	// by looking up a (thread) pool with the desired probability: Pool indices
-	// are distributed in 100 selectors.
-	selector := make([]int, 100)
-
+func makeRequest(ctx context.Context, scenarios []Scenario, sched *schedule, start time.Time, metrics *LiveMetrics, requests chan bender.Test, stop chan bool, logger *log.Logger) ([]*pool, error) {
 	// Set up a pool for each scenario and start an initial thread per pool.
 	pools := make([]*pool, len(scenarios))
-	cummulatedPercentage := 0
 	for i, s := range scenarios {
 		pool := pool{
 			Scenario: s,
@@ -263,30 +317,21 @@ func makeRequest(scenarios []Scenario, rate float64, requests chan bender.Test,
 			Chan:     make(chan bender.Test, 2),
 			wg:       &sync.WaitGroup{},
 			mu:       &sync.Mutex{},
+			metrics:  metrics,
+			ctx:      ctx,
 		}
 		pools[i] = &pool
 		pools[i].newThread(stop, logger)
-		for k := 0; k < s.Percentage; k++ {
-			if cummulatedPercentage+k > 99 {
-				return nil, fmt.Errorf("suite: sum of Percentage greater than 100%%")
-			}
-			selector[cummulatedPercentage+k] = i
-		}
-		cummulatedPercentage += s.Percentage
-	}
-	if cummulatedPercentage <= 99 {
-		return nil, fmt.Errorf("suite: sum of Percentage less than 100%%")
 	}
 
-	gracetime := time.Second / time.Duration(5*rate)
+	gracetime := time.Duration(float64(time.Second) / (5 * sched.peak()))
 	go func() {
-		// Select a pool (i.e. a scenario) and take a request from
-		// this pool. If pool cannot deliver: Start a new thread in
-		// this pool. Repeat until stop signaled.
+		// Select a pool (i.e. a scenario) according to the schedule
+		// and take a request from this pool. If pool cannot deliver:
+		// Start a new thread in this pool. Repeat until stop signaled.
 		counter := 0
 		for {
-			rn := rand.Intn(100)
-			pool := pools[selector[rn]]
+			pool := pools[sched.choose(time.Since(start))]
 			var test bender.Test
 			select {
 			case <-stop:
@@ -317,9 +362,16 @@ type ThroughputOptions struct {
 	// Rate is the target rate of request per second (QPS).
 	Rate float64
 
-	// Duration of the whole throughput test.
+	// Duration of the whole throughput test (excluding Warmup).
 	Duration time.Duration
 
+	// Warmup is run with the full load before the actual throughput
+	// test starts. Requests started during the warm-up are recorded
+	// in the live CSV but are excluded from the returned data, the
+	// collected tests and SLA evaluation. Ramp and rate profiles start
+	// at the beginning of the warm-up.
+	Warmup time.Duration
+
 	// Ramp duration during which the actual rate (QPS) is linearily
 	// increased to the target rate.
 	Ramp time.Duration
@@ -332,6 +384,23 @@ type ThroughputOptions struct {
 	// CollectFrom limit collection of tests to those test with a
 	// status equal or bader.
 	CollectFrom ht.Status
+
+	// SLA thresholds like "p99 < 800ms" or "error rate < 1%" the
+	// throughput test must fulfill, see SLA for details.
+	SLA []string
+
+	// Metrics, if non-nil, are updated live during the throughput test.
+	Metrics *LiveMetrics
+
+	// Abort the throughput test early if the error rate or the request
+	// duration exceed the given limits for a sustained period. An
+	// aborted throughput test fails.
+	Abort AbortCondition
+
+	// Context, if non-nil, cancels the throughput test once done: No new
+	// requests are generated and the Teardown tests are given
+	// TeardownGracePeriod to finish. The Setup tests run with Context.
+	Context context.Context
 }
 
 // Throughput runs a throughput load test with requests taken from the given
@@ -363,7 +432,7 @@ type ThroughputOptions struct {
 //
 // It returns a summary of all request, the aggregated tests and an error
 // indicating if the throughput test reached the targeted rate and the desired
-// distribution between scenarios.
+// distribution between scenarios and whether the SLAs in opts have been met.
 func Throughput(scenarios []Scenario, opts ThroughputOptions, csvout io.Writer) ([]TestData, *Suite, error) {
 	bufferedStdout := bufio.NewWriterSize(os.Stdout, 1)
 	logger := log.New(bufferedStdout, "", 256)
@@ -376,40 +445,86 @@ func Throughput(scenarios []Scenario, opts ThroughputOptions, csvout io.Writer)
 	}
 
 	// Make sure all request come from some scenario.
-	sum := 0
+	sum, unprofiled := 0, 0
 	for i := range scenarios {
+		if err := scenarios[i].ThinkTime.validate(); err != nil {
+			return nil, nil, fmt.Errorf("Scenario %d %q: %s",
+				i+1, scenarios[i].Name, err)
+		}
+		if dp := scenarios[i].DataPool; dp != nil {
+			if dp.Len() == 0 {
+				return nil, nil, fmt.Errorf("Scenario %d %q: empty data pool %s",
+					i+1, scenarios[i].Name, dp.Name)
+			}
+			if scenarios[i].MaxThreads <= 0 || scenarios[i].MaxThreads > dp.Len() {
+				scenarios[i].MaxThreads = dp.Len()
+			}
+		}
+		if len(scenarios[i].Profile) > 0 {
+			if err := scenarios[i].Profile.validate(); err != nil {
+				return nil, nil, fmt.Errorf("Scenario %d %q: %s",
+					i+1, scenarios[i].Name, err)
+			}
+			continue
+		}
 		sum += scenarios[i].Percentage
+		unprofiled++
 	}
-	if sum != 100 {
+	if unprofiled > 0 && sum != 100 {
 		return nil, nil, fmt.Errorf("Sum of Percentage = %d%% (must be 100)", sum)
 	}
+	slas := make([]SLA, len(opts.SLA))
+	for i, s := range opts.SLA {
+		sla, err := ParseSLA(s)
+		if err != nil {
+			return nil, nil, err
+		}
+		slas[i] = sla
+	}
+	sched := newSchedule(scenarios, opts)
+	if sched.peak() <= 0 {
+		return nil, nil, fmt.Errorf("Request rate must be positive")
+	}
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
 
 	// Execute Teardown code on any case.
 	defer func() {
+		teardownCtx, stopTeardown := graceContext(ctx, TeardownGracePeriod)
+		defer stopTeardown()
 		for i := range scenarios {
 			if len(scenarios[i].RawSuite.Teardown) == 0 {
 				continue
 			}
 			logger.Printf("Scenario %d %q: Running Teardown\n",
 				i+1, scenarios[i].Name)
-			(&scenarios[i]).teardown(logger)
+			(&scenarios[i]).teardown(teardownCtx, logger)
 		}
 	}()
 
 	// Execute Setup code.
+	ht.ResetWireLogs()
 	for i := range scenarios {
 		// Setup must be called for all scenarios!
 		logger.Printf("Scenario %d %q: Running setup\n",
 			i+1, scenarios[i].Name)
-		ss := (&scenarios[i]).setup(logger)
+		ss := (&scenarios[i]).setup(ctx, logger)
 		if ss.Error != nil {
 			return nil, ss, fmt.Errorf("Setup of scenario %d %q failed: %s",
 				i+1, scenarios[i].Name, ss.Error)
 		}
 	}
 
-	logger.Printf("Starting Throughput test for %s (ramp %s) at average of %.1f requests/second \n",
-		opts.Duration, opts.Ramp, opts.Rate)
+	logger.Printf("Starting Throughput test for %s (ramp %s, warm-up %s) at average of %.1f requests/second \n",
+		opts.Duration, opts.Ramp, opts.Warmup, opts.Rate)
+	for i := range scenarios {
+		if len(scenarios[i].Profile) > 0 {
+			logger.Printf("Scenario %d %q: Rate profile %s\n",
+				i+1, scenarios[i].Name, scenarios[i].Profile)
+		}
+	}
 	bufferedStdout.Flush()
 
 	recorder := make(chan bender.Event)
@@ -422,18 +537,36 @@ func Throughput(scenarios []Scenario, opts ThroughputOptions, csvout io.Writer)
 	}
 	statusRing := NewStatusRing(50, maxer)
 	defer csvWriter.Flush()
+	start := time.Now()
+	opts.Metrics.reset(start)
+	monitor := newAbortMonitor(opts.Abort, start)
 	recordingDone := make(chan bool)
 	go bender.Record(recorder, recordingDone,
-		newRecorder(&data, &collectedTests, opts.CollectFrom, csvWriter, statusRing))
+		newRecorder(&data, &collectedTests, opts.CollectFrom, csvWriter, statusRing,
+			opts.Metrics, monitor, start.Add(opts.Warmup)))
 
 	request := make(chan bender.Test, 2*len(scenarios))
 	stop := make(chan bool)
-	intervals := bender.ExponentialIntervalGenerator(opts.Rate)
-	if opts.Ramp > 0 {
-		intervals = bender.RampedExponentialIntervalGenerator(opts.Rate, opts.Ramp)
+	scheduled := bender.ScheduledExponentialIntervalGenerator(start, sched.total,
+		scope.RandomExpFloat64)
+	intervals := func(now int64) int64 {
+		// Skip stages with a zero rate.
+		elapsed := time.Duration(now - start.UnixNano())
+		pause := sched.pause(elapsed)
+		if pause < 0 {
+			// Zero rate till the end: Wait for the end of the test.
+			if pause = opts.Warmup + opts.Duration - elapsed; pause <= 0 {
+				pause = time.Second
+			}
+			return int64(pause)
+		}
+		if pause == 0 {
+			return scheduled(now)
+		}
+		return int64(pause) + scheduled(now+int64(pause))
 	}
 
-	pools, err := makeRequest(scenarios, opts.Rate, request, stop, logger)
+	pools, err := makeRequest(ctx, scenarios, sched, start, opts.Metrics, request, stop, logger)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -442,23 +575,45 @@ func Throughput(scenarios []Scenario, opts ThroughputOptions, csvout io.Writer)
 	started, loopCnt := time.Now(), 0
 	elapsed := time.Duration(0)
 	statusCounts := make([]int, int(ht.Bogus)+1)
-	for elapsed < opts.Duration {
+	total := opts.Warmup + opts.Duration
+	warm := opts.Warmup <= 0
+	var abortErr error
+	for elapsed < total {
 		elapsed = time.Since(started)
 		elins := ((elapsed + 500 + time.Millisecond) / time.Second) * time.Second
+		if !warm && elapsed >= opts.Warmup {
+			logger.Printf("Warm-up finished after %s", elins)
+			warm = true
+		}
 		if loopCnt%10 == 0 {
 			logger.Printf("Throughput test running for %s (%d%% completed)",
-				elins, 100*elapsed/opts.Duration)
+				elins, 100*elapsed/total)
 		}
 		if bad := statusRing.Status(statusCounts); bad {
 			logger.Printf("Throughput test aborted after %s (%d%% completed): Too many errors",
-				elins, 100*elapsed/opts.Duration)
+				elins, 100*elapsed/total)
 			for s, n := range statusCounts {
 				logger.Printf("  Status %-7s: %d", ht.Status(s), n)
 			}
 			break
 		}
+		if err := monitor.check(time.Now()); err != nil {
+			logger.Printf("Throughput test aborted after %s (%d%% completed): %s",
+				elins, 100*elapsed/total, err)
+			abortErr = fmt.Errorf("aborted after %s: %s", elins, err)
+			break
+		}
 		loopCnt++
-		time.Sleep(1 * time.Second)
+		select {
+		case <-ctx.Done():
+			logger.Printf("Throughput test canceled after %s (%d%% completed)",
+				elins, 100*elapsed/total)
+			abortErr = fmt.Errorf("canceled after %s", elins)
+		case <-time.After(1 * time.Second):
+		}
+		if abortErr != nil {
+			break
+		}
 	}
 	close(stop)
 	logger.Println("Finished Throughput test.")
@@ -486,7 +641,17 @@ func Throughput(scenarios []Scenario, opts ThroughputOptions, csvout io.Writer)
 	}
 	time.Sleep(50 * time.Millisecond)
 	bufferedStdout.Flush()
-	err = analyseOutcome(data, pools)
+	err = analyseOutcome(data, pools, sched.percentages(opts.Warmup, time.Since(start)))
+	if len(slas) > 0 || abortErr != nil {
+		errors := errorlist.List{}
+		errors = errors.Append(abortErr)
+		errors = errors.Append(err)
+		latencies := Latencies(data)
+		for _, sla := range slas {
+			errors = errors.Append(sla.Check(latencies))
+		}
+		err = errors.AsError()
+	}
 
 	return data, makeCollectedSuite(collectedTests, opts.CollectFrom), err
 }
@@ -555,7 +720,9 @@ func makeCollectedSuite(tests []*ht.Test, from ht.Status) *Suite {
 	return &suite
 }
 
-func analyseOutcome(data []TestData, pools []*pool) error {
+// analyseOutcome of a throughput test. The percentages are the expected
+// contribution of each scenario (pool) to the total requests.
+func analyseOutcome(data []TestData, pools []*pool, percentages []float64) error {
 	errors := errorlist.List{}
 
 	N := len(data)
@@ -569,12 +736,12 @@ func analyseOutcome(data []TestData, pools []*pool) error {
 		errors = append(errors, err)
 	}
 
-	err = analyseMisses(data, pools)
+	err = analyseMisses(data, pools, percentages)
 	if err != nil {
 		errors = append(errors, err)
 	}
 
-	derr := analyseDistribution(data, pools)
+	derr := analyseDistribution(data, pools, percentages)
 	if derr != nil {
 		errors = append(errors, derr...)
 	}
@@ -585,11 +752,11 @@ func analyseOutcome(data []TestData, pools []*pool) error {
 	return nil
 }
 
-func analyseMisses(data []TestData, pools []*pool) error {
+func analyseMisses(data []TestData, pools []*pool, percentages []float64) error {
 	errors := errorlist.List{}
 	N := len(data)
 	for i, p := range pools {
-		expected := N * p.Scenario.Percentage / 100
+		expected := int(float64(N) * percentages[i] / 100)
 		if p.Misses <= expected/50 {
 			continue
 		}
@@ -603,7 +770,7 @@ func analyseMisses(data []TestData, pools []*pool) error {
 	return nil
 }
 
-func analyseDistribution(data []TestData, pools []*pool) errorlist.List {
+func analyseDistribution(data []TestData, pools []*pool, percentages []float64) errorlist.List {
 	errors := errorlist.List{}
 	N := len(data)
 
@@ -628,33 +795,34 @@ func analyseDistribution(data []TestData, pools []*pool) errorlist.List {
 	}
 
 	// Check scenario percentages: Each scenario should contribute
-	// approximately as much as given in its Percentage field.
+	// approximately as much as given in its Percentage field (or as
+	// determined by its rate profile).
 	// Statistical fluctuations happen and are larger if only a few
 	// request are made.
-	tolerance := percentageTolerance(N)
+	tolerance := float64(percentageTolerance(N))
 	for i, p := range pools {
 		actual := cnt[i]
-		fmt.Printf("Scenario %d %q: %d requests = %.1f%% (target %d%%), %d threads created, %d thread misses, repetitions",
+		fmt.Printf("Scenario %d %q: %d requests = %.1f%% (target %.0f%%), %d threads created, %d thread misses, repetitions",
 			i+1, p.Scenario.Name,
 			actual, float64(100*actual)/float64(N),
-			p.Scenario.Percentage,
+			percentages[i],
 			p.Threads, p.Misses)
 		for t := 1; t <= p.Threads; t++ {
 			fmt.Printf(" %d", repPerThread[i][t])
 		}
 		fmt.Println()
-		low := N * (p.Scenario.Percentage - tolerance) / 100
+		low := int(float64(N) * (percentages[i] - tolerance) / 100)
 		if low <= 0 {
 			low = 1
 		}
-		high := N * (p.Scenario.Percentage + tolerance) / 100
+		high := int(float64(N) * (percentages[i] + tolerance) / 100)
 		if low <= actual && actual <= high {
 			continue
 		}
 		errors = append(errors,
-			fmt.Errorf("scenario %d %q contributed %.1f%% (want %d%%)",
+			fmt.Errorf("scenario %d %q contributed %.1f%% (want %.0f%%)",
 				i+1, p.Scenario.Name, float64(100*actual)/float64(N),
-				p.Scenario.Percentage))
+				percentages[i]))
 	}
 
 	// Check each scenario is repeated at least three times which mean it was
@@ -950,7 +1118,10 @@ func (s ByStarted) Len() int           { return len(s) }
 func (s ByStarted) Less(i, j int) bool { return s[i].Started.Before(s[j].Started) }
 func (s ByStarted) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 
-func newRecorder(data *[]TestData, tests *[]*ht.Test, from ht.Status, w *csv.Writer, sr *StatusRing) bender.Recorder {
+// newRecorder returns a recorder which writes all requests to w, sr,
+// metrics and monitor. Only requests started at or after warmupEnd are
+// appended to data and tests.
+func newRecorder(data *[]TestData, tests *[]*ht.Test, from ht.Status, w *csv.Writer, sr *StatusRing, metrics *LiveMetrics, monitor *abortMonitor, warmupEnd time.Time) bender.Recorder {
 	cnt := 0
 	header := []string{
 		"Started",      // 0
@@ -985,11 +1156,13 @@ func newRecorder(data *[]TestData, tests *[]*ht.Test, from ht.Status, w *csv.Wri
 			Wait:         time.Duration(e.Wait),
 			Overage:      time.Duration(e.Overage),
 		}
-		*data = append(*data, d)
+		if !d.Started.Before(warmupEnd) {
+			*data = append(*data, d)
 
-		// Test Recorder
-		if e.Test.Result.Status >= from {
-			*tests = append(*tests, e.Test)
+			// Test Recorder
+			if e.Test.Result.Status >= from {
+				*tests = append(*tests, e.Test)
+			}
 		}
 
 		// CSV Recording
@@ -1001,6 +1174,10 @@ func newRecorder(data *[]TestData, tests *[]*ht.Test, from ht.Status, w *csv.Wri
 
 		// StatusRing
 		sr.Store(e.Test.Result.Status)
+
+		// Live metrics and early abort
+		metrics.record(d)
+		monitor.record(time.Now(), d)
 	}
 }
 
@@ -0,0 +1,71 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package suite
+
+import (
+	"github.com/vdobler/ht/ht"
+	"github.com/vdobler/ht/tracing"
+)
+
+// TracingObserver is an Observer which traces the execution of a suite:
+// The suite is the root span of a new trace with one child span per test
+// which in turn is the parent of the spans of the requests and checks of
+// the test. The ID of the trace is recorded in the "TraceID" metadata of
+// each test. A TracingObserver must not be shared between suites executed
+// concurrently.
+type TracingObserver struct {
+	Tracer *tracing.Tracer
+	span   *tracing.Span
+}
+
+// NewTracingObserver returns a TracingObserver recording to tracer.
+func NewTracingObserver(tracer *tracing.Tracer) *TracingObserver {
+	return &TracingObserver{Tracer: tracer}
+}
+
+func (o *TracingObserver) suiteSpan(s *Suite) *tracing.Span {
+	if o.span == nil {
+		o.span = o.Tracer.Start("Suite " + s.Name)
+		o.span.Set("ht.suite.name", s.Name)
+	}
+	return o.span
+}
+
+// OnTestStart implements Observer.
+func (o *TracingObserver) OnTestStart(s *Suite, test *ht.Test) {
+	span := o.suiteSpan(s).Child("Test "+test.Name, tracing.Internal)
+	span.Set("ht.test.name", test.Name)
+	if file := test.GetStringMetadata("Filename"); file != "" {
+		span.Set("ht.test.file", file)
+	}
+	test.Span = span
+	test.SetMetadata("TraceID", span.TraceID.String())
+}
+
+// OnTestDone implements Observer.
+func (o *TracingObserver) OnTestDone(s *Suite, test *ht.Test) {
+	span := test.Span
+	if span == nil {
+		return
+	}
+	span.Set("ht.test.seqno", test.GetStringMetadata("SeqNo"))
+	span.Set("ht.status", test.Result.Status.String())
+	span.Set("ht.tries", test.Result.Tries)
+	if test.Result.Status > ht.Pass && test.Result.Error != nil {
+		span.Fail(test.Result.Error.Error())
+	}
+	span.Finish()
+}
+
+// OnSuiteDone implements Observer.
+func (o *TracingObserver) OnSuiteDone(s *Suite) {
+	span := o.suiteSpan(s)
+	span.Set("ht.status", s.Status.String())
+	if s.Status > ht.Pass && s.Error != nil {
+		span.Fail(s.Error.Error())
+	}
+	span.Finish()
+	o.span = nil
+}
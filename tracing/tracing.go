@@ -0,0 +1,366 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package tracing provides minimal OpenTelemetry compatible tracing.
+//
+// Spans are identified by W3C Trace Context IDs and can be propagated to
+// the system under test via the traceparent header. The spans collected by
+// a Tracer can be exported to any OpenTelemetry collector (or to Jaeger or
+// Tempo directly) via the OTLP/HTTP protocol in its JSON encoding.
+package tracing
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceID is the 16 byte ID of a trace.
+type TraceID [16]byte
+
+// SpanID is the 8 byte ID of a span.
+type SpanID [8]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+
+// IsZero reports whether id is the invalid all zero SpanID.
+func (id SpanID) IsZero() bool { return id == SpanID{} }
+
+// Kind is the kind of a span as defined by OpenTelemetry.
+type Kind int
+
+// The kinds of spans.
+const (
+	Internal Kind = 1
+	Server   Kind = 2
+	Client   Kind = 3
+)
+
+// Span is a timed operation in a trace.
+type Span struct {
+	TraceID  TraceID
+	SpanID   SpanID
+	ParentID SpanID // ParentID is zero for root spans.
+	Name     string
+	Kind     Kind
+	Start    time.Time
+	End      time.Time
+
+	// Attributes of the span. Values must be strings, bools or ints.
+	Attributes map[string]interface{}
+
+	// Failed and Message describe the status of the span.
+	Failed  bool
+	Message string
+
+	tracer *Tracer
+}
+
+// Tracer creates and collects spans.
+type Tracer struct {
+	// Service is reported as service.name resource attribute.
+	Service string
+
+	// MaxSpans limits the number of finished spans kept until they are
+	// exported; the oldest spans are dropped if the limit is reached,
+	// e.g. because the collector is unreachable. Zero means
+	// DefaultMaxSpans.
+	MaxSpans int
+
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// DefaultMaxSpans is the default for Tracer.MaxSpans.
+const DefaultMaxSpans = 10000
+
+// ExportClient is the http.Client used to export spans.
+var ExportClient = &http.Client{Timeout: 10 * time.Second}
+
+// NewTracer returns a new Tracer for service.
+func NewTracer(service string) *Tracer {
+	return &Tracer{Service: service}
+}
+
+// keep adds spans to the finished spans dropping the oldest ones if
+// there are more than MaxSpans. The caller must hold t.mu.
+func (t *Tracer) keep(spans ...*Span) {
+	t.spans = append(t.spans, spans...)
+	max := t.MaxSpans
+	if max <= 0 {
+		max = DefaultMaxSpans
+	}
+	if n := len(t.spans) - max; n > 0 {
+		t.spans = append([]*Span(nil), t.spans[n:]...)
+	}
+}
+
+// Start starts a new root span with the given name in a new trace.
+func (t *Tracer) Start(name string) *Span {
+	s := t.newSpan(name, Internal, time.Now())
+	s.TraceID = newTraceID()
+	return s
+}
+
+func (t *Tracer) newSpan(name string, kind Kind, start time.Time) *Span {
+	return &Span{
+		SpanID:     newSpanID(),
+		Name:       name,
+		Kind:       kind,
+		Start:      start,
+		Attributes: map[string]interface{}{},
+		tracer:     t,
+	}
+}
+
+// Child starts a new span of the given kind as a child of s.
+func (s *Span) Child(name string, kind Kind) *Span {
+	return s.ChildAt(name, kind, time.Now())
+}
+
+// ChildAt starts a new span of the given kind and start time as a child
+// of s.
+func (s *Span) ChildAt(name string, kind Kind, start time.Time) *Span {
+	c := s.tracer.newSpan(name, kind, start)
+	c.TraceID = s.TraceID
+	c.ParentID = s.SpanID
+	return c
+}
+
+// Set the attribute key to value.
+func (s *Span) Set(key string, value interface{}) {
+	s.Attributes[key] = value
+}
+
+// Fail marks s as failed with the given message.
+func (s *Span) Fail(message string) {
+	s.Failed, s.Message = true, message
+}
+
+// Finish ends s now and hands it to the tracer for export.
+func (s *Span) Finish() {
+	s.FinishAt(time.Now())
+}
+
+// FinishAt ends s at the given time and hands it to the tracer for export.
+func (s *Span) FinishAt(end time.Time) {
+	s.End = end
+	s.tracer.mu.Lock()
+	s.tracer.keep(s)
+	s.tracer.mu.Unlock()
+}
+
+// Traceparent returns the W3C Trace Context traceparent header value
+// which makes s the parent of the spans created by the server receiving
+// the header.
+func (s *Span) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-01", s.TraceID, s.SpanID)
+}
+
+// ParseTraceparent parses the trace and parent span ID from a W3C Trace
+// Context traceparent header value.
+func ParseTraceparent(value string) (TraceID, SpanID, error) {
+	var tid TraceID
+	var sid SpanID
+	parts := strings.Split(value, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return tid, sid, fmt.Errorf("tracing: malformed traceparent %q", value)
+	}
+	t, err := hex.DecodeString(parts[1])
+	if err != nil || len(t) != len(tid) {
+		return tid, sid, fmt.Errorf("tracing: malformed trace-id in %q", value)
+	}
+	s, err := hex.DecodeString(parts[2])
+	if err != nil || len(s) != len(sid) {
+		return tid, sid, fmt.Errorf("tracing: malformed parent-id in %q", value)
+	}
+	copy(tid[:], t)
+	copy(sid[:], s)
+	return tid, sid, nil
+}
+
+// Spans returns the finished spans not exported yet.
+func (t *Tracer) Spans() []*Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*Span(nil), t.spans...)
+}
+
+// ----------------------------------------------------------------------------
+// OTLP export
+
+// WriteOTLP writes the finished spans as OTLP JSON ExportTraceServiceRequest
+// to w.
+func (t *Tracer) WriteOTLP(w io.Writer) error {
+	return t.writeOTLP(w, t.Spans())
+}
+
+func (t *Tracer) writeOTLP(w io.Writer, finished []*Span) error {
+	type value struct {
+		StringValue *string `json:"stringValue,omitempty"`
+		IntValue    *string `json:"intValue,omitempty"` // int64 are strings in OTLP JSON
+		BoolValue   *bool   `json:"boolValue,omitempty"`
+	}
+	type keyValue struct {
+		Key   string `json:"key"`
+		Value value  `json:"value"`
+	}
+	type status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	type span struct {
+		TraceID           string     `json:"traceId"`
+		SpanID            string     `json:"spanId"`
+		ParentSpanID      string     `json:"parentSpanId,omitempty"`
+		Name              string     `json:"name"`
+		Kind              Kind       `json:"kind"`
+		StartTimeUnixNano string     `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+		Attributes        []keyValue `json:"attributes,omitempty"`
+		Status            status     `json:"status"`
+	}
+
+	attributes := func(m map[string]interface{}) []keyValue {
+		kvs := make([]keyValue, 0, len(m))
+		for _, k := range sortedKeys(m) {
+			kv := keyValue{Key: k}
+			switch v := m[k].(type) {
+			case bool:
+				kv.Value.BoolValue = &v
+			case int:
+				s := strconv.Itoa(v)
+				kv.Value.IntValue = &s
+			default:
+				s := fmt.Sprint(v)
+				kv.Value.StringValue = &s
+			}
+			kvs = append(kvs, kv)
+		}
+		return kvs
+	}
+
+	spans := []span{}
+	for _, s := range finished {
+		sp := span{
+			TraceID:           s.TraceID.String(),
+			SpanID:            s.SpanID.String(),
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        attributes(s.Attributes),
+			Status:            status{Code: 1}, // Ok
+		}
+		if !s.ParentID.IsZero() {
+			sp.ParentSpanID = s.ParentID.String()
+		}
+		if s.Failed {
+			sp.Status = status{Code: 2, Message: s.Message} // Error
+		}
+		spans = append(spans, sp)
+	}
+
+	request := map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": attributes(map[string]interface{}{
+						"service.name": t.Service,
+					}),
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]string{"name": "github.com/vdobler/ht"},
+						"spans": spans,
+					},
+				},
+			},
+		},
+	}
+	return json.NewEncoder(w).Encode(request)
+}
+
+// Export sends the finished spans via OTLP/HTTP to the collector at
+// endpoint and forgets them. If endpoint has no path the standard path
+// /v1/traces is used. Spans which could not be exported are kept for the
+// next export (up to MaxSpans).
+func (t *Tracer) Export(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+
+	t.mu.Lock()
+	finished := t.spans
+	t.spans = nil
+	t.mu.Unlock()
+
+	err = t.export(u, finished)
+	if err != nil {
+		t.mu.Lock()
+		pending := t.spans
+		t.spans = nil
+		t.keep(append(finished, pending...)...)
+		t.mu.Unlock()
+	}
+	return err
+}
+
+func (t *Tracer) export(u *url.URL, finished []*Span) error {
+	buf := &bytes.Buffer{}
+	if err := t.writeOTLP(buf, finished); err != nil {
+		return err
+	}
+	resp, err := ExportClient.Post(u.String(), "application/json", buf)
+	if err != nil {
+		return err
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("tracing: export to %s failed: %s %s",
+			u, resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+// Helpers
+
+func newTraceID() TraceID {
+	var id TraceID
+	rand.Read(id[:])
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	rand.Read(id[:])
+	return id
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,136 @@
+// Copyright 2017 Volker Dobler.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tracing
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTraceparent(t *testing.T) {
+	tracer := NewTracer("test")
+	root := tracer.Start("root")
+	child := root.Child("child", Client)
+	if child.TraceID != root.TraceID || child.ParentID != root.SpanID {
+		t.Fatalf("Bad child %+v of %+v", child, root)
+	}
+
+	tp := child.Traceparent()
+	if len(tp) != 55 || !strings.HasPrefix(tp, "00-") || !strings.HasSuffix(tp, "-01") {
+		t.Errorf("Malformed traceparent %q", tp)
+	}
+	tid, sid, err := ParseTraceparent(tp)
+	if err != nil || tid != child.TraceID || sid != child.SpanID {
+		t.Errorf("Got %s %s %v", tid, sid, err)
+	}
+
+	for _, bad := range []string{"", "00-abc-def-01", "ff-" + tp[3:],
+		"00-" + strings.Repeat("x", 32) + "-" + strings.Repeat("0", 16) + "-01"} {
+		if _, _, err := ParseTraceparent(bad); err == nil {
+			t.Errorf("Missing error for %q", bad)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	var got map[string]interface{}
+	var path string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Errorf("Bad body: %s\n%s", err, body)
+		}
+	}))
+	defer ts.Close()
+
+	tracer := NewTracer("shop-tests")
+	root := tracer.Start("Suite")
+	child := root.Child("Test", Internal)
+	child.Set("ht.tries", 2)
+	child.Set("ht.status", "Fail")
+	child.Fail("bad status")
+	child.Finish()
+	root.Finish()
+
+	if err := tracer.Export(ts.URL); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if path != "/v1/traces" {
+		t.Errorf("Got path %q", path)
+	}
+	if n := len(tracer.Spans()); n != 0 {
+		t.Errorf("Got %d unexported spans", n)
+	}
+
+	data, _ := json.Marshal(got)
+	for _, want := range []string{
+		`"key":"service.name","value":{"stringValue":"shop-tests"}`,
+		`"key":"ht.tries","value":{"intValue":"2"}`,
+		`"parentSpanId":"` + root.SpanID.String() + `"`,
+		`"status":{"code":2,"message":"bad status"}`,
+		`"traceId":"` + root.TraceID.String() + `"`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Missing %s in\n%s", want, data)
+		}
+	}
+}
+
+func TestExportFailure(t *testing.T) {
+	release := make(chan bool)
+	status := http.StatusInternalServerError
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hang" {
+			<-release
+		}
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	tracer := NewTracer("shop-tests")
+	tracer.MaxSpans = 3
+	for _, name := range []string{"A", "B", "C", "D"} {
+		tracer.Start(name).Finish()
+	}
+	names := func() string {
+		s := []string{}
+		for _, span := range tracer.Spans() {
+			s = append(s, span.Name)
+		}
+		return strings.Join(s, " ")
+	}
+	if got := names(); got != "B C D" {
+		t.Errorf("Got spans %s", got)
+	}
+
+	if err := tracer.Export(ts.URL); err == nil {
+		t.Errorf("Missing error")
+	}
+	tracer.Start("E").Finish()
+	if got := names(); got != "C D E" {
+		t.Errorf("Got spans %s after failed export", got)
+	}
+
+	defer func(c *http.Client) { ExportClient = c }(ExportClient)
+	ExportClient = &http.Client{Timeout: 50 * time.Millisecond}
+	if err := tracer.Export(ts.URL + "/hang"); err == nil {
+		t.Errorf("Missing timeout error")
+	}
+
+	status = http.StatusOK
+	if err := tracer.Export(ts.URL); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+	if got := names(); got != "" {
+		t.Errorf("Got spans %s after export", got)
+	}
+}